@@ -0,0 +1,126 @@
+// Command qualityeval scores a labeled corpus with the quality scorers and
+// reports how often each agrees with the labels, with precision and recall
+// per bucket and the score distribution. The rule-based scorer always runs;
+// the AI scorer and the composite run when an Ollama URL is given. A weight
+// of the rule-based scorer can be swept to see how agreement responds.
+//
+//	go run ./cmd/qualityeval -sweep SubstantialBonus=0.1,0.2,0.3
+//	go run ./cmd/qualityeval -ollama-url http://localhost:11434 -sample 20
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/docutag/textanalyzer/internal/analyzer"
+	"github.com/docutag/textanalyzer/internal/evaluation"
+	"github.com/docutag/textanalyzer/internal/ollama"
+)
+
+func main() {
+	var (
+		corpus      = flag.String("corpus", "internal/evaluation/testdata/quality_corpus.jsonl", "JSONL corpus of {id, text, expected_bucket} samples")
+		ollamaURL   = flag.String("ollama-url", "", "Ollama API URL; when set the AI and composite scorers are evaluated too")
+		ollamaModel = flag.String("ollama-model", ollama.DefaultModel, "Ollama model to use")
+		sample      = flag.Int("sample", 0, "Evaluate a random sample of this many texts (0 for the whole corpus)")
+		seed        = flag.Int64("seed", 1, "Random seed for -sample")
+		sweep       = flag.String("sweep", "", "Sweep a rule-based weight over values, e.g. SpamPenalty=0.2,0.4,0.6")
+		jsonOutput  = flag.Bool("json", false, "Write reports as JSON")
+	)
+	flag.Parse()
+
+	// Keep the analyzer's progress logs out of the report
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn})))
+
+	if err := run(*corpus, *ollamaURL, *ollamaModel, *sample, *seed, *sweep, *jsonOutput); err != nil {
+		fmt.Fprintln(os.Stderr, "qualityeval:", err)
+		os.Exit(1)
+	}
+}
+
+func run(corpus, ollamaURL, ollamaModel string, sample int, seed int64, sweep string, jsonOutput bool) error {
+	samples, err := evaluation.LoadCorpus(corpus)
+	if err != nil {
+		return err
+	}
+	samples = evaluation.SampleCorpus(samples, sample, seed)
+
+	ctx := context.Background()
+	textAnalyzer := analyzer.New()
+	ruleBased := evaluation.RuleBasedScorer(textAnalyzer, analyzer.DefaultQualityWeights())
+
+	if sweep != "" {
+		weight, values, err := parseSweep(sweep)
+		if err != nil {
+			return err
+		}
+		results, err := evaluation.SweepWeight(ctx, textAnalyzer, samples, analyzer.DefaultQualityWeights(), weight, values)
+		if err != nil {
+			return err
+		}
+		reports := make([]evaluation.Report, len(results))
+		for i, result := range results {
+			reports[i] = result.Report
+		}
+		return write(reports, jsonOutput)
+	}
+
+	scorers := []evaluation.Scorer{ruleBased}
+	if ollamaURL != "" {
+		client, err := ollama.New(ollamaURL, ollamaModel)
+		if err != nil {
+			return fmt.Errorf("failed to create Ollama client: %w", err)
+		}
+		ai := evaluation.AIScorer(client)
+		scorers = append(scorers, ai, evaluation.CompositeScorer(ai, ruleBased, evaluation.AICleaner(client)))
+	}
+
+	reports := make([]evaluation.Report, 0, len(scorers))
+	for _, scorer := range scorers {
+		report, err := evaluation.Evaluate(ctx, samples, scorer)
+		if err != nil {
+			return err
+		}
+		reports = append(reports, report)
+	}
+	return write(reports, jsonOutput)
+}
+
+// parseSweep parses a sweep spec such as "SpamPenalty=0.2,0.4"
+func parseSweep(spec string) (string, []float64, error) {
+	weight, list, ok := strings.Cut(spec, "=")
+	if !ok || weight == "" || list == "" {
+		return "", nil, fmt.Errorf("invalid sweep %q: expected Weight=value,value", spec)
+	}
+
+	var values []float64
+	for _, field := range strings.Split(list, ",") {
+		value, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid sweep value %q: %w", field, err)
+		}
+		values = append(values, value)
+	}
+	return weight, values, nil
+}
+
+func write(reports []evaluation.Report, jsonOutput bool) error {
+	if jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(reports)
+	}
+	for i, report := range reports {
+		if i > 0 {
+			fmt.Println()
+		}
+		report.WriteText(os.Stdout)
+	}
+	return nil
+}
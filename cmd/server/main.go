@@ -4,11 +4,13 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -18,10 +20,17 @@ import (
 	"github.com/docutag/textanalyzer/internal/api"
 	"github.com/docutag/textanalyzer/internal/database"
 	"github.com/docutag/textanalyzer/internal/ollama"
+	"github.com/docutag/textanalyzer/internal/openai"
 	"github.com/docutag/textanalyzer/internal/queue"
+	"github.com/docutag/textanalyzer/internal/tags"
 	"github.com/docutag/textanalyzer/pkg/logging"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
+// Version is the service version, logged at startup and recorded in each
+// analysis's provenance. make build sets it with -X main.Version.
+var Version = "1.0.0"
+
 func main() {
 	// Setup structured logging with JSON output
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
@@ -29,7 +38,7 @@ func main() {
 	}))
 	slog.SetDefault(logger)
 
-	logger.Info("textanalyzer service initializing", "version", "1.0.0")
+	logger.Info("textanalyzer service initializing", "version", Version)
 
 	// Initialize tracing
 	tp, err := tracing.InitTracer("docutab-textanalyzer")
@@ -48,10 +57,88 @@ func main() {
 	portDefault := getEnv("PORT", "8080")
 	ollamaURLDefault := getEnv("OLLAMA_URL", "http://localhost:11434")
 	ollamaModelDefault := getEnv("OLLAMA_MODEL", "gpt-oss:20b")
+	ollamaModelFallbacksDefault := getEnv("OLLAMA_MODEL_FALLBACKS", "")
 	useOllamaDefault := getEnvBool("USE_OLLAMA", true)
+	llmBackendDefault := getEnv("LLM_BACKEND", llmBackendOllama)
+	openAIURLDefault := getEnv("OPENAI_URL", openai.DefaultURL)
+	openAIAPIKeyDefault := getEnv("OPENAI_API_KEY", "")
+	openAIModelDefault := getEnv("OPENAI_MODEL", "gpt-4o-mini")
+	llmChunkBudgetDefault := getEnvInt("LLM_CHUNK_BUDGET", ollama.DefaultChunkBudget)
+	llmChunkOverlapDefault := getEnvInt("LLM_CHUNK_OVERLAP", ollama.DefaultChunkOverlap)
+	llmMaxChunksDefault := getEnvInt("LLM_MAX_CHUNKS", ollama.DefaultMaxChunks)
+	llmCacheDefault := getEnv("LLM_CACHE", llmCacheOff)
+	llmCacheTTLDefault := getEnvDuration("LLM_CACHE_TTL", ollama.DefaultCacheTTL)
+	llmCacheMaxEntriesDefault := getEnvInt("LLM_CACHE_MAX_ENTRIES", ollama.DefaultCacheMaxEntries)
+	llmCacheMaxEntryBytesDefault := getEnvInt("LLM_CACHE_MAX_ENTRY_BYTES", ollama.DefaultCacheMaxEntryBytes)
+	shadowModelDefault := getEnv("SHADOW_MODEL", "")
+	shadowSampleRateDefault := getEnvFloat("SHADOW_SAMPLE_RATE", 0.1)
 	redisAddrDefault := getEnv("REDIS_ADDR", "localhost:6379")
 	workerConcurrencyDefault := getEnvInt("WORKER_CONCURRENCY", 5)
 	ollamaMaxRetriesDefault := getEnvInt("OLLAMA_MAX_RETRIES", 10)
+	ollamaRequestAttemptsDefault := getEnvInt("OLLAMA_REQUEST_ATTEMPTS", ollama.DefaultMaxAttempts)
+	ollamaRetryBackoffDefault := getEnvDuration("OLLAMA_RETRY_BACKOFF", ollama.DefaultRetryBackoff)
+	ollamaBreakerThresholdDefault := getEnvInt("OLLAMA_BREAKER_THRESHOLD", ollama.DefaultBreakerThreshold)
+	ollamaBreakerCooldownDefault := getEnvDuration("OLLAMA_BREAKER_COOLDOWN", ollama.DefaultBreakerCooldown)
+	ollamaHealthIntervalDefault := getEnvDuration("OLLAMA_HEALTH_INTERVAL", ollama.DefaultHealthInterval)
+	ollamaTimeoutDefault := getEnvDuration("OLLAMA_TIMEOUT", ollama.DefaultTimeout)
+	ollamaQualityTimeoutDefault := getEnvDuration("OLLAMA_QUALITY_TIMEOUT", 0)
+	ollamaKeepAliveDefault := getEnvDuration("OLLAMA_KEEP_ALIVE", 0)
+	ollamaTemperatureDefault := getEnvFloat("OLLAMA_TEMPERATURE", -1)
+	ollamaNumCtxDefault := getEnvInt("OLLAMA_NUM_CTX", 0)
+	ollamaMaxConcurrentDefault := getEnvInt("OLLAMA_MAX_CONCURRENT", ollama.DefaultMaxConcurrent)
+	// QUALITY_THRESHOLD is read as another name for ENRICHMENT_THRESHOLD
+	enrichmentThresholdDefault := getEnvFloat("ENRICHMENT_THRESHOLD", getEnvFloat("QUALITY_THRESHOLD", analyzer.DefaultEnrichmentThreshold))
+	sourceThresholdsDefault := getEnv("SOURCE_THRESHOLDS", "")
+	sourceThresholdsFileDefault := getEnv("SOURCE_THRESHOLDS_FILE", "")
+	heartbeatIntervalDefault := getEnvDuration("HEARTBEAT_INTERVAL", queue.DefaultHeartbeatInterval)
+	heartbeatStaleAfterDefault := getEnvDuration("HEARTBEAT_STALE_AFTER", 3*queue.DefaultHeartbeatInterval)
+	maxImagesDefault := getEnvInt("MAX_IMAGES", analyzer.DefaultMaxImages)
+	truncateImagesDefault := getEnvBool("TRUNCATE_IMAGES", false)
+	maxBodyBytesDefault := getEnvInt("MAX_BODY_BYTES", 10<<20)
+	maxHTMLBytesDefault := getEnvInt("MAX_HTML_BYTES", 20<<20)
+	fetchTimeoutDefault := getEnvDuration("FETCH_TIMEOUT", analyzer.DefaultPageFetchTimeout)
+	fetchMaxBytesDefault := getEnvInt("FETCH_MAX_BYTES", analyzer.DefaultPageMaxBytes)
+	fetchMaxRedirectsDefault := getEnvInt("FETCH_MAX_REDIRECTS", analyzer.DefaultPageMaxRedirects)
+	fetchUserAgentDefault := getEnv("FETCH_USER_AGENT", analyzer.DefaultPageUserAgent)
+	imageFetchDefault := getEnvBool("IMAGE_FETCH", true)
+	imageFetchTimeoutDefault := getEnvDuration("IMAGE_FETCH_TIMEOUT", analyzer.DefaultImageFetchTimeout)
+	imageFetchMaxBytesDefault := getEnvInt("IMAGE_FETCH_MAX_BYTES", analyzer.DefaultImageFetchMaxBytes)
+	describeImagesDefault := getEnvBool("DESCRIBE_IMAGES", false)
+	ollamaVisionModelDefault := getEnv("OLLAMA_VISION_MODEL", "llama3.2-vision")
+	webhookSecretDefault := getEnv("WEBHOOK_SECRET", "")
+	webhookMaxAttemptsDefault := getEnvInt("WEBHOOK_MAX_ATTEMPTS", queue.DefaultWebhookMaxAttempts)
+	webhookAllowPrivateDefault := getEnvBool("WEBHOOK_ALLOW_PRIVATE", false)
+	enrichmentStepsDefault := getEnv("ENRICHMENT_STEPS", "all")
+	enrichmentConcurrencyDefault := getEnvInt("ENRICHMENT_CONCURRENCY", analyzer.DefaultEnrichmentConcurrency)
+	translateToDefault := getEnv("TRANSLATE_TO", "")
+	maxSectionsDefault := getEnvInt("MAX_SECTIONS", analyzer.DefaultMaxSections)
+	maxTrackedWordsDefault := getEnvInt("MAX_TRACKED_WORDS", analyzer.DefaultMaxTrackedWords)
+	maxTrackedPhrasesDefault := getEnvInt("MAX_TRACKED_PHRASES", analyzer.DefaultMaxTrackedPhrases)
+	paragraphChunkSentencesDefault := getEnvInt("PARAGRAPH_CHUNK_SENTENCES", analyzer.DefaultParagraphChunkSentences)
+	readabilityFormulaDefault := getEnv("READABILITY_FORMULA", analyzer.ReadabilityFleschReadingEase)
+	readingWPMDefault := getEnvInt("READING_WPM", analyzer.DefaultReadingWPM)
+	dateOrderDefault := getEnv("DATE_ORDER", analyzer.DateOrderMDY)
+	resolveRelativeDatesDefault := getEnvBool("RESOLVE_RELATIVE_DATES", false)
+	stripURLTrackingDefault := getEnvBool("STRIP_URL_TRACKING", false)
+	tagBlacklistDefault := getEnv("TAG_BLACKLIST", "")
+	tagWhitelistDefault := getEnv("TAG_WHITELIST", "")
+	tagAliasesDefault := getEnv("TAG_ALIASES", "")
+	redactPIIDefault := getEnvBool("REDACT_PII", false)
+	stopWordsPathDefault := getEnv("STOPWORDS_PATH", "")
+	sentimentLexiconPathDefault := getEnv("SENTIMENT_LEXICON_PATH", "")
+	lexiconModeDefault := getEnv("LEXICON_MODE", analyzer.LexiconMerge)
+	adminTokenDefault := getEnv("ADMIN_TOKEN", "")
+	storeTextDefault := getEnvBool("STORE_TEXT_DEFAULT", true)
+	backPressureModeDefault := getEnv("BACKPRESSURE_MODE", api.BackPressureOff)
+	backPressureMaxEnrichmentDefault := getEnvInt("BACKPRESSURE_MAX_PENDING_ENRICHMENT", 0)
+	backPressureMaxOfflineDefault := getEnvInt("BACKPRESSURE_MAX_PENDING_OFFLINE", 0)
+	backPressureRetryAfterDefault := getEnvDuration("BACKPRESSURE_RETRY_AFTER", 30*time.Second)
+	queueDepthIntervalDefault := getEnvDuration("QUEUE_DEPTH_INTERVAL", queue.DefaultDepthPollInterval)
+	retentionDaysDefault := getEnvInt("RETENTION_DAYS", 0)
+	retentionMinQualityDefault := getEnvFloat("RETENTION_MIN_QUALITY", queue.DefaultRetentionMinQuality)
+	retentionIntervalDefault := getEnvDuration("RETENTION_INTERVAL", queue.DefaultRetentionInterval)
+	retentionBatchSizeDefault := getEnvInt("RETENTION_BATCH_SIZE", queue.DefaultRetentionBatchSize)
+	retentionMaxPerRunDefault := getEnvInt("RETENTION_MAX_PER_RUN", queue.DefaultRetentionMaxPerRun)
 
 	// PostgreSQL environment variables
 	dbHost := getEnv("DB_HOST", "localhost")
@@ -59,31 +146,227 @@ func main() {
 	dbUser := getEnv("DB_USER", "docutab")
 	dbPassword := getEnv("DB_PASSWORD", "docutab_dev_pass")
 	dbName := getEnv("DB_NAME", "docutab")
+	dbOptions := database.Options{
+		MaxOpenConns:    getEnvInt("DB_MAX_OPEN_CONNS", database.DefaultMaxOpenConns),
+		MaxIdleConns:    getEnvInt("DB_MAX_IDLE_CONNS", database.DefaultMaxIdleConns),
+		ConnMaxLifetime: getEnvDuration("DB_CONN_MAX_LIFETIME", database.DefaultConnMaxLifetime),
+		ConnMaxIdleTime: getEnvDuration("DB_CONN_MAX_IDLE_TIME", database.DefaultConnMaxIdleTime),
+		Registerer:      prometheus.DefaultRegisterer,
+	}
 
 	var (
 		port              = flag.String("port", portDefault, "Server port (env: PORT)")
 		ollamaURL         = flag.String("ollama-url", ollamaURLDefault, "Ollama API URL (env: OLLAMA_URL)")
 		ollamaModel       = flag.String("ollama-model", ollamaModelDefault, "Ollama model to use (env: OLLAMA_MODEL)")
-		useOllama         = flag.Bool("use-ollama", useOllamaDefault, "Enable Ollama for AI-powered analysis (env: USE_OLLAMA)")
+		useOllama         = flag.Bool("use-ollama", useOllamaDefault, "Enable AI-powered analysis with the LLM backend (env: USE_OLLAMA)")
 		redisAddr         = flag.String("redis-addr", redisAddrDefault, "Redis address for queue (env: REDIS_ADDR)")
 		workerConcurrency = flag.Int("worker-concurrency", workerConcurrencyDefault, "Worker concurrency (env: WORKER_CONCURRENCY)")
 		ollamaMaxRetries  = flag.Int("ollama-max-retries", ollamaMaxRetriesDefault, "Max retries for Ollama tasks (env: OLLAMA_MAX_RETRIES)")
+
+		ollamaModelFallbacks = flag.String("ollama-model-fallbacks", ollamaModelFallbacksDefault, "Comma-separated Ollama models tried in turn when the model is missing or fails to load, e.g. for lack of memory (env: OLLAMA_MODEL_FALLBACKS)")
+
+		ollamaRequestAttempts = flag.Int("ollama-request-attempts", ollamaRequestAttemptsDefault, "Times a request to the Ollama API is sent before a connection error, timeout or 5xx response fails the step (env: OLLAMA_REQUEST_ATTEMPTS)")
+		ollamaRetryBackoff    = flag.Duration("ollama-retry-backoff", ollamaRetryBackoffDefault, "Wait before retrying a failed request to the Ollama API, doubled after each retry (env: OLLAMA_RETRY_BACKOFF)")
+
+		ollamaBreakerThreshold = flag.Int("ollama-breaker-threshold", ollamaBreakerThresholdDefault, "Calls to the Ollama API failing in a row before further calls fail fast; 0 disables the circuit breaker (env: OLLAMA_BREAKER_THRESHOLD)")
+		ollamaBreakerCooldown  = flag.Duration("ollama-breaker-cooldown", ollamaBreakerCooldownDefault, "Time the Ollama circuit breaker stays open before a probe call is let through (env: OLLAMA_BREAKER_COOLDOWN)")
+		ollamaHealthInterval   = flag.Duration("ollama-health-interval", ollamaHealthIntervalDefault, "How often the Ollama server is pinged to update the ollama_up gauge (env: OLLAMA_HEALTH_INTERVAL)")
+
+		ollamaTimeout        = flag.Duration("ollama-timeout", ollamaTimeoutDefault, "Time a call to the Ollama model may take, retries included (env: OLLAMA_TIMEOUT)")
+		ollamaQualityTimeout = flag.Duration("ollama-quality-timeout", ollamaQualityTimeoutDefault, "Time a quality scoring call to the Ollama model may take; 0 uses -ollama-timeout (env: OLLAMA_QUALITY_TIMEOUT)")
+		ollamaKeepAlive      = flag.Duration("ollama-keep-alive", ollamaKeepAliveDefault, "How long Ollama keeps the model loaded after a call; 0 uses the server's default (env: OLLAMA_KEEP_ALIVE)")
+		ollamaTemperature    = flag.Float64("ollama-temperature", ollamaTemperatureDefault, "Sampling temperature of the Ollama model; negative uses the model's default. Tags are always generated at 0 (env: OLLAMA_TEMPERATURE)")
+		ollamaNumCtx         = flag.Int("ollama-num-ctx", ollamaNumCtxDefault, "Context window of the Ollama model in tokens; 0 uses the model's default (env: OLLAMA_NUM_CTX)")
+		ollamaMaxConcurrent  = flag.Int("ollama-max-concurrent", ollamaMaxConcurrentDefault, "Generations an Ollama client runs at a time across all workers, the others waiting their turn; 0 for no limit (env: OLLAMA_MAX_CONCURRENT)")
+
+		llmBackend   = flag.String("llm-backend", llmBackendDefault, "LLM backend for AI enrichment: ollama or openai (env: LLM_BACKEND)")
+		openAIURL    = flag.String("openai-url", openAIURLDefault, "Base URL of the OpenAI-compatible API, e.g. http://localhost:8000/v1 for vLLM (env: OPENAI_URL)")
+		openAIAPIKey = flag.String("openai-api-key", openAIAPIKeyDefault, "API key sent as a bearer token to the OpenAI-compatible API; omitted when empty (env: OPENAI_API_KEY)")
+		openAIModel  = flag.String("openai-model", openAIModelDefault, "Model to use with the openai backend (env: OPENAI_MODEL)")
+
+		llmChunkBudget  = flag.Int("llm-chunk-budget", llmChunkBudgetDefault, "Most characters of text sent to the model in one prompt; longer texts are split into chunks at paragraph boundaries (env: LLM_CHUNK_BUDGET)")
+		llmChunkOverlap = flag.Int("llm-chunk-overlap", llmChunkOverlapDefault, "Characters from the end of a chunk repeated at the start of the next (env: LLM_CHUNK_OVERLAP)")
+		llmMaxChunks    = flag.Int("llm-max-chunks", llmMaxChunksDefault, "Most chunks of a text sent to the model; text past the last chunk is dropped (env: LLM_MAX_CHUNKS)")
+
+		llmCache              = flag.String("llm-cache", llmCacheDefault, "Cache of Ollama responses for synopses, tags, references, AI detection and quality scores: off, memory, or redis at -redis-addr (env: LLM_CACHE)")
+		llmCacheTTL           = flag.Duration("llm-cache-ttl", llmCacheTTLDefault, "How long a cached Ollama response is reused (env: LLM_CACHE_TTL)")
+		llmCacheMaxEntries    = flag.Int("llm-cache-max-entries", llmCacheMaxEntriesDefault, "Most responses held by the memory cache, the least recently used evicted first (env: LLM_CACHE_MAX_ENTRIES)")
+		llmCacheMaxEntryBytes = flag.Int("llm-cache-max-entry-bytes", llmCacheMaxEntryBytesDefault, "Largest Ollama response cached, in bytes; 0 for no limit (env: LLM_CACHE_MAX_ENTRY_BYTES)")
+
+		shadowModel      = flag.String("shadow-model", shadowModelDefault, "Candidate model of the LLM backend that also tags and scores a sample of enrichments for comparison; disabled when empty (env: SHADOW_MODEL)")
+		shadowSampleRate = flag.Float64("shadow-sample-rate", shadowSampleRateDefault, "Fraction of text enrichments run against the shadow model, from 0 to 1 (env: SHADOW_SAMPLE_RATE)")
+
+		enrichmentThreshold  = flag.Float64("enrichment-threshold", enrichmentThresholdDefault, "Default quality score required for AI enrichment (env: ENRICHMENT_THRESHOLD or QUALITY_THRESHOLD)")
+		sourceThresholds     = flag.String("source-thresholds", sourceThresholdsDefault, "Per-source enrichment thresholds, e.g. memo=0,forum=0.5 (env: SOURCE_THRESHOLDS)")
+		sourceThresholdsFile = flag.String("source-thresholds-file", sourceThresholdsFileDefault, "JSON file mapping sources to enrichment thresholds (env: SOURCE_THRESHOLDS_FILE)")
+
+		heartbeatInterval   = flag.Duration("heartbeat-interval", heartbeatIntervalDefault, "How often the queue worker writes a heartbeat (env: HEARTBEAT_INTERVAL)")
+		heartbeatStaleAfter = flag.Duration("heartbeat-stale-after", heartbeatStaleAfterDefault, "Heartbeat age after which /ready reports a worker as stalled (env: HEARTBEAT_STALE_AFTER)")
+
+		maxImages      = flag.Int("max-images", maxImagesDefault, "Maximum unique images enriched per analysis (env: MAX_IMAGES)")
+		truncateImages = flag.Bool("truncate-images", truncateImagesDefault, "Keep the first max-images images and warn instead of rejecting larger requests (env: TRUNCATE_IMAGES)")
+
+		maxBodyBytes = flag.Int64("max-body-bytes", int64(maxBodyBytesDefault), "Largest request body accepted by /api/analyze, /api/analyze/batch and /api/segment, in bytes (env: MAX_BODY_BYTES)")
+		maxHTMLBytes = flag.Int64("max-html-bytes", int64(maxHTMLBytesDefault), "Largest original_html accepted once decompressed, in bytes (env: MAX_HTML_BYTES)")
+
+		fetchTimeout      = flag.Duration("fetch-timeout", fetchTimeoutDefault, "Time allowed to fetch a page for /api/analyze/url, redirects included (env: FETCH_TIMEOUT)")
+		fetchMaxBytes     = flag.Int64("fetch-max-bytes", int64(fetchMaxBytesDefault), "Largest page fetched for /api/analyze/url, in bytes (env: FETCH_MAX_BYTES)")
+		fetchMaxRedirects = flag.Int("fetch-max-redirects", fetchMaxRedirectsDefault, "Redirects followed when fetching a page for /api/analyze/url (env: FETCH_MAX_REDIRECTS)")
+		fetchUserAgent    = flag.String("fetch-user-agent", fetchUserAgentDefault, "User-Agent sent when fetching pages for /api/analyze/url (env: FETCH_USER_AGENT)")
+
+		imageFetch         = flag.Bool("image-fetch", imageFetchDefault, "Fetch submitted images to read their type and dimensions; when false, image metadata is guessed from the URL (env: IMAGE_FETCH)")
+		imageFetchTimeout  = flag.Duration("image-fetch-timeout", imageFetchTimeoutDefault, "Time allowed to probe an image, redirects included (env: IMAGE_FETCH_TIMEOUT)")
+		imageFetchMaxBytes = flag.Int64("image-fetch-max-bytes", int64(imageFetchMaxBytesDefault), "Most bytes downloaded from an image to decode its dimensions (env: IMAGE_FETCH_MAX_BYTES)")
+		describeImages     = flag.Bool("describe-images", describeImagesDefault, "Caption and tag images with an Ollama vision model during image enrichment (env: DESCRIBE_IMAGES)")
+		ollamaVisionModel  = flag.String("ollama-vision-model", ollamaVisionModelDefault, "Ollama vision model describing images when -describe-images is set (env: OLLAMA_VISION_MODEL)")
+
+		webhookSecret       = flag.String("webhook-secret", webhookSecretDefault, "Shared secret signing callback notifications with HMAC-SHA256; unsigned when empty (env: WEBHOOK_SECRET)")
+		webhookMaxAttempts  = flag.Int("webhook-max-attempts", webhookMaxAttemptsDefault, "Attempts to deliver a callback notification before giving up (env: WEBHOOK_MAX_ATTEMPTS)")
+		webhookAllowPrivate = flag.Bool("webhook-allow-private", webhookAllowPrivateDefault, "Allow callback URLs on private, loopback and link-local addresses, for development and tests (env: WEBHOOK_ALLOW_PRIVATE)")
+
+		enrichmentSteps       = flag.String("enrichment-steps", enrichmentStepsDefault, "AI enrichment steps to run by default: all, none, or a list of synopsis,clean,editorial,tags,references,ai_detection,quality (env: ENRICHMENT_STEPS)")
+		enrichmentConcurrency = flag.Int("enrichment-concurrency", enrichmentConcurrencyDefault, "AI enrichment steps of one document that call Ollama at the same time after cleaning; 1 runs them in order (env: ENRICHMENT_CONCURRENCY)")
+
+		translateTo = flag.String("translate-to", translateToDefault, "ISO 639-1 code of the language documents in other languages are translated into before the synopsis, tags and editorial analysis, e.g. en; disabled when empty (env: TRANSLATE_TO)")
+
+		maxSections = flag.Int("max-sections", maxSectionsDefault, "Maximum sections summarized per document when a request asks for sections (env: MAX_SECTIONS)")
+
+		maxTrackedWords   = flag.Int("max-tracked-words", maxTrackedWordsDefault, "Maximum distinct words counted per document; rarer words are dropped beyond it (env: MAX_TRACKED_WORDS)")
+		maxTrackedPhrases = flag.Int("max-tracked-phrases", maxTrackedPhrasesDefault, "Maximum distinct phrases counted per document; rarer phrases are dropped beyond it (env: MAX_TRACKED_PHRASES)")
+
+		paragraphChunkSentences = flag.Int("paragraph-chunk-sentences", paragraphChunkSentencesDefault, "Sentences per pseudo-paragraph when offline cleaning chunks long text without newlines (env: PARAGRAPH_CHUNK_SENTENCES)")
+
+		readabilityFormula   = flag.String("readability-formula", readabilityFormulaDefault, "Formula English text's readability score and level are reported with: flesch_reading_ease, flesch_kincaid_grade, gunning_fog, smog or coleman_liau (env: READABILITY_FORMULA)")
+		readingWPM           = flag.Int("reading-wpm", readingWPMDefault, "Words per minute reading_time_seconds is estimated at (env: READING_WPM)")
+		dateOrder            = flag.String("date-order", dateOrderDefault, "Order of the day and month in numeric dates such as 03/04/2024 when both are 12 or less: mdy or dmy (env: DATE_ORDER)")
+		resolveRelativeDates = flag.Bool("resolve-relative-dates", resolveRelativeDatesDefault, "Resolve relative dates such as yesterday and last Tuesday against the analysis creation time in metadata.dates (env: RESOLVE_RELATIVE_DATES)")
+		stripURLTracking     = flag.Bool("strip-url-tracking", stripURLTrackingDefault, "Drop tracking parameters such as utm_source and fbclid from potential_urls (env: STRIP_URL_TRACKING)")
+		tagBlacklist         = flag.String("tag-blacklist", tagBlacklistDefault, "Comma-separated tags dropped from generated tags (env: TAG_BLACKLIST)")
+		tagWhitelist         = flag.String("tag-whitelist", tagWhitelistDefault, "Comma-separated tags generated tags are limited to; all tags when empty (env: TAG_WHITELIST)")
+		tagAliases           = flag.String("tag-aliases", tagAliasesDefault, "Comma-separated tag=canonical rewrites applied to generated tags, e.g. ml=machine-learning (env: TAG_ALIASES)")
+		redactPII            = flag.Bool("redact-pii", redactPIIDefault, "Mask emails, phone numbers, SSNs, card numbers, IBANs and street addresses in text sent to the model for every analysis, not only those with redact_pii (env: REDACT_PII)")
+
+		stopWordsPath        = flag.String("stopwords-path", stopWordsPathDefault, "JSON array or file of one stop word per line; built-in English stop words when empty (env: STOPWORDS_PATH)")
+		sentimentLexiconPath = flag.String("sentiment-lexicon-path", sentimentLexiconPathDefault, "JSON object of positive and negative words, or file of one word and its polarity per line; built-in English words when empty (env: SENTIMENT_LEXICON_PATH)")
+		lexiconMode          = flag.String("lexicon-mode", lexiconModeDefault, "Whether words from -stopwords-path and -sentiment-lexicon-path merge with or replace the built-in words: merge or replace (env: LEXICON_MODE)")
+
+		storeText = flag.Bool("store-text-default", storeTextDefault, "Store submitted text for requests that do not set store_text; when false only derived metadata and a hash are kept (env: STORE_TEXT_DEFAULT)")
+
+		adminToken = flag.String("admin-token", adminTokenDefault, "Bearer token for the admin worker config API; disabled when empty (env: ADMIN_TOKEN)")
+
+		backPressureMode          = flag.String("backpressure-mode", backPressureModeDefault, "Queue back-pressure on new submissions: off, strict (503 when saturated) or degraded (accept offline-only) (env: BACKPRESSURE_MODE)")
+		backPressureMaxEnrichment = flag.Int("backpressure-max-pending-enrichment", backPressureMaxEnrichmentDefault, "Pending text enrichment tasks above which back-pressure applies; 0 for no limit (env: BACKPRESSURE_MAX_PENDING_ENRICHMENT)")
+		backPressureMaxOffline    = flag.Int("backpressure-max-pending-offline", backPressureMaxOfflineDefault, "Pending offline processing tasks above which submissions are rejected; 0 for no limit (env: BACKPRESSURE_MAX_PENDING_OFFLINE)")
+		backPressureRetryAfter    = flag.Duration("backpressure-retry-after", backPressureRetryAfterDefault, "Retry-After sent with back-pressure rejections (env: BACKPRESSURE_RETRY_AFTER)")
+		queueDepthInterval        = flag.Duration("queue-depth-interval", queueDepthIntervalDefault, "How often queue depths are read for metrics and back-pressure (env: QUEUE_DEPTH_INTERVAL)")
+
+		retentionDays       = flag.Int("retention-days", retentionDaysDefault, "Age in days after which unenriched low-quality analyses are pruned; 0 disables pruning (env: RETENTION_DAYS)")
+		retentionMinQuality = flag.Float64("retention-min-quality", retentionMinQualityDefault, "Quality score at or above which old analyses are kept; unscored analyses count as 0 (env: RETENTION_MIN_QUALITY)")
+		retentionInterval   = flag.Duration("retention-interval", retentionIntervalDefault, "How often the retention job runs (env: RETENTION_INTERVAL)")
+		retentionBatchSize  = flag.Int("retention-batch-size", retentionBatchSizeDefault, "Analyses deleted per retention transaction (env: RETENTION_BATCH_SIZE)")
+		retentionMaxPerRun  = flag.Int("retention-max-per-run", retentionMaxPerRunDefault, "Most analyses deleted by one retention run (env: RETENTION_MAX_PER_RUN)")
+
+		migrateTo = flag.Int("migrate-to", -1, "Migrate the database schema to this version, applying or reverting migrations, and exit without starting the server")
 	)
 	flag.Parse()
 
+	// Build enrichment thresholds; entries from the flag override the file
+	if err := analyzer.ValidateThreshold(*enrichmentThreshold); err != nil {
+		logger.Error("invalid enrichment threshold", "error", err)
+		os.Exit(1)
+	}
+	sourceThresholdMap := map[string]float64{}
+	if *sourceThresholdsFile != "" {
+		fromFile, err := analyzer.LoadSourceThresholds(*sourceThresholdsFile)
+		if err != nil {
+			logger.Error("failed to load source thresholds", "error", err, "path", *sourceThresholdsFile)
+			os.Exit(1)
+		}
+		for source, threshold := range fromFile {
+			sourceThresholdMap[source] = threshold
+		}
+	}
+	fromSpec, err := analyzer.ParseSourceThresholds(*sourceThresholds)
+	if err != nil {
+		logger.Error("failed to parse source thresholds", "error", err)
+		os.Exit(1)
+	}
+	for source, threshold := range fromSpec {
+		sourceThresholdMap[source] = threshold
+	}
+	enrichmentThresholds := analyzer.NewEnrichmentThresholds(*enrichmentThreshold, sourceThresholdMap)
+	logger.Info("enrichment thresholds configured",
+		"default", *enrichmentThreshold,
+		"sources", sourceThresholdMap,
+	)
+
+	// Default AI enrichment steps; requests may enable or disable individual steps
+	defaultEnrichment, err := analyzer.ParseEnrichmentSteps(*enrichmentSteps)
+	if err != nil {
+		logger.Error("failed to parse enrichment steps", "error", err)
+		os.Exit(1)
+	}
+	logger.Info("enrichment steps configured", "skipped", analyzer.SkippedSteps(defaultEnrichment))
+
+	if err := analyzer.ValidateReadabilityFormula(*readabilityFormula); err != nil {
+		logger.Error("invalid readability formula", "error", err)
+		os.Exit(1)
+	}
+	if err := analyzer.ValidateDateOrder(*dateOrder); err != nil {
+		logger.Error("invalid date order", "error", err)
+		os.Exit(1)
+	}
+
+	// Stop words and sentiment words from files, merged with or replacing the built-ins
+	lexiconOptions, err := loadLexicons(*stopWordsPath, *sentimentLexiconPath, *lexiconMode)
+	if err != nil {
+		logger.Error("failed to load lexicons", "error", err)
+		os.Exit(1)
+	}
+	if len(lexiconOptions) > 0 {
+		logger.Info("lexicons loaded", "stopwords_path", *stopWordsPath, "sentiment_lexicon_path", *sentimentLexiconPath, "mode", *lexiconMode)
+	}
+
+	// Back-pressure on new submissions while the queues are saturated
+	*backPressureMode, err = api.ParseBackPressureMode(*backPressureMode)
+	if err != nil {
+		logger.Error("invalid back-pressure mode", "error", err)
+		os.Exit(1)
+	}
+	logger.Info("back-pressure configured",
+		"mode", *backPressureMode,
+		"max_pending_enrichment", *backPressureMaxEnrichment,
+		"max_pending_offline", *backPressureMaxOffline,
+	)
+
 	// Construct PostgreSQL connection string
 	dbConnStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
 		dbHost, dbPort, dbUser, dbPassword, dbName)
-	logger.Info("using PostgreSQL database", "host", dbHost, "port", dbPort, "database", dbName)
+	logger.Info("using PostgreSQL database", "host", dbHost, "port", dbPort, "database", dbName,
+		"max_open_conns", dbOptions.MaxOpenConns,
+		"max_idle_conns", dbOptions.MaxIdleConns,
+		"conn_max_lifetime", dbOptions.ConnMaxLifetime,
+		"conn_max_idle_time", dbOptions.ConnMaxIdleTime,
+	)
 
 	// Initialize database
-	db, err := database.New(dbConnStr)
+	db, err := database.NewWithOptions(dbConnStr, dbOptions)
 	if err != nil {
 		logger.Error("failed to initialize database", "error", err, "connection_string", dbConnStr)
 		os.Exit(1)
 	}
 	defer db.Close()
 
+	// Migrate to the requested version and stop, e.g. to roll back a deploy
+	if *migrateTo >= 0 {
+		if err := db.MigrateTo(*migrateTo); err != nil {
+			logger.Error("failed to migrate", "version", *migrateTo, "error", err)
+			os.Exit(1)
+		}
+		logger.Info("database migrated", "version", *migrateTo)
+		return
+	}
+
 	// Run migrations
 	if err := db.Migrate(); err != nil {
 		logger.Error("failed to run migrations", "error", err)
@@ -102,23 +385,120 @@ func main() {
 	logger.Info("database metrics initialized")
 
 	// Initialize analyzer
+	if err := ollama.RegisterMetrics(prometheus.DefaultRegisterer); err != nil {
+		logger.Warn("failed to register ollama metrics", "error", err)
+	}
+	responseCache, err := newLLMCache(*llmCache, *redisAddr, *llmCacheMaxEntries)
+	if err != nil {
+		logger.Error("invalid LLM cache", "error", err)
+		os.Exit(1)
+	}
+	if responseCache != nil {
+		logger.Info("LLM response cache enabled", "cache", *llmCache, "ttl", *llmCacheTTL, "max_entry_bytes", *llmCacheMaxEntryBytes)
+	}
+	llm := llmConfig{
+		backend:            *llmBackend,
+		ollamaURL:          *ollamaURL,
+		ollamaMaxAttempts:  *ollamaRequestAttempts,
+		ollamaRetryBackoff: *ollamaRetryBackoff,
+		ollamaTimeout:      *ollamaTimeout,
+		qualityTimeout:     *ollamaQualityTimeout,
+		ollamaKeepAlive:    *ollamaKeepAlive,
+		ollamaTemperature:  *ollamaTemperature,
+		ollamaNumCtx:       *ollamaNumCtx,
+		maxConcurrent:      *ollamaMaxConcurrent,
+		breakerThreshold:   *ollamaBreakerThreshold,
+		breakerCooldown:    *ollamaBreakerCooldown,
+		openAIURL:          *openAIURL,
+		openAIAPIKey:       *openAIAPIKey,
+		chunkBudget:        *llmChunkBudget,
+		chunkOverlap:       *llmChunkOverlap,
+		maxChunks:          *llmMaxChunks,
+		cache:              responseCache,
+		cacheTTL:           *llmCacheTTL,
+		cacheMaxEntryBytes: *llmCacheMaxEntryBytes,
+	}
+	primaryModel := *ollamaModel
+	if llm.backend == llmBackendOpenAI {
+		primaryModel = *openAIModel
+	}
 	var textAnalyzer *analyzer.Analyzer
+	var ollamaClient *ollama.Client // The primary client when it uses the Ollama API
 	if *useOllama {
-		ollamaClient, err := ollama.New(*ollamaURL, *ollamaModel)
+		llmClient, err := llm.newClient(primaryModel, ollama.WithFallbackModels(strings.Split(*ollamaModelFallbacks, ",")...))
 		if err != nil {
-			logger.Warn("failed to initialize Ollama client, falling back to rule-based analysis",
+			logger.Warn("failed to initialize LLM client, falling back to rule-based analysis",
 				"error", err,
-				"ollama_url", *ollamaURL,
-				"ollama_model", *ollamaModel,
+				"llm_backend", llm.backend,
+				"llm_url", llm.url(),
+				"llm_model", primaryModel,
 			)
-			textAnalyzer = analyzer.New()
+			textAnalyzer = analyzer.New(lexiconOptions...)
+		} else if client, ok := llmClient.(*ollama.Client); ok && !checkOllamaModel(client, logger) {
+			logger.Warn("falling back to rule-based analysis", "llm_model", primaryModel)
+			textAnalyzer = analyzer.New(lexiconOptions...)
 		} else {
-			logger.Info("Ollama client initialized", "model", *ollamaModel, "url", *ollamaURL)
-			textAnalyzer = analyzer.NewWithOllama(ollamaClient)
+			logger.Info("LLM client initialized", "backend", llm.backend, "model", primaryModel, "url", llm.url())
+			textAnalyzer = analyzer.NewWithOllama(llmClient, lexiconOptions...)
+			ollamaClient = client
 		}
 	} else {
-		logger.Info("Ollama disabled, using rule-based analysis")
-		textAnalyzer = analyzer.New()
+		logger.Info("AI enrichment disabled, using rule-based analysis")
+		textAnalyzer = analyzer.New(lexiconOptions...)
+	}
+	// Shadow a sample of enrichments with a candidate model before switching to it
+	var shadowClient analyzer.LLMClient
+	if *useOllama && *shadowModel != "" {
+		if *shadowSampleRate < 0 || *shadowSampleRate > 1 {
+			logger.Error("invalid shadow sample rate, must be between 0 and 1", "shadow_sample_rate", *shadowSampleRate)
+			os.Exit(1)
+		}
+		client, err := llm.newClient(*shadowModel)
+		if err != nil {
+			logger.Warn("failed to initialize shadow LLM client, shadow enrichment disabled", "error", err, "shadow_model", *shadowModel)
+		} else {
+			shadowClient = client
+			logger.Info("shadow enrichment enabled", "shadow_model", *shadowModel, "sample_rate", *shadowSampleRate)
+		}
+	}
+	textAnalyzer.SetMaxSections(*maxSections)
+	textAnalyzer.SetEnrichmentConcurrency(*enrichmentConcurrency)
+	textAnalyzer.SetFrequencyLimits(*maxTrackedWords, *maxTrackedPhrases)
+	textAnalyzer.SetParagraphChunkSentences(*paragraphChunkSentences)
+	textAnalyzer.SetReadabilityFormula(*readabilityFormula)
+	textAnalyzer.SetReadingSpeed(*readingWPM)
+	textAnalyzer.SetDateOrder(*dateOrder)
+	textAnalyzer.SetResolveRelativeDates(*resolveRelativeDates)
+	textAnalyzer.SetStripURLTracking(*stripURLTracking)
+	textAnalyzer.SetRedactPII(*redactPII)
+	tagPolicy, err := tags.ParsePolicy(*tagBlacklist, *tagWhitelist, *tagAliases)
+	if err != nil {
+		logger.Error("failed to parse tag policy", "error", err)
+		os.Exit(1)
+	}
+	textAnalyzer.SetTagPolicy(tagPolicy)
+	textAnalyzer.SetTranslateTo(*translateTo)
+	if *translateTo != "" {
+		logger.Info("translation for AI enrichment enabled", "translate_to", *translateTo)
+	}
+	textAnalyzer.SetServiceVersion(Version)
+	textAnalyzer.SetImageFetchConfig(analyzer.ImageFetchConfig{
+		Disabled: !*imageFetch,
+		Timeout:  *imageFetchTimeout,
+		MaxBytes: *imageFetchMaxBytes,
+	})
+	// Describe images with a vision model, only available through Ollama
+	if *describeImages {
+		if !*useOllama || llm.backend != llmBackendOllama {
+			logger.Warn("image description requires the ollama backend, image description disabled", "llm_backend", llm.backend)
+		} else if visionClient, err := llm.newOllamaClient(*ollamaVisionModel); err != nil {
+			logger.Warn("failed to initialize vision client, image description disabled", "error", err, "vision_model", *ollamaVisionModel)
+		} else if !checkOllamaModel(visionClient, logger) {
+			logger.Warn("image description disabled", "vision_model", *ollamaVisionModel)
+		} else {
+			textAnalyzer.SetImageDescriber(visionClient)
+			logger.Info("image description enabled", "vision_model", *ollamaVisionModel)
+		}
 	}
 
 	// Initialize queue client
@@ -127,13 +507,67 @@ func main() {
 	})
 	logger.Info("queue client initialized", "redis_addr", *redisAddr)
 
-	// Initialize queue worker
+	// Initialize queue inspector for job statuses and task listings
+	queueInspector := queue.NewInspector(queue.ClientConfig{
+		RedisAddr: *redisAddr,
+	})
+
+	// Poll queue depths for metrics and back-pressure checks
+	depthMonitor := queue.NewDepthMonitor(queueInspector, *queueDepthInterval, prometheus.DefaultRegisterer, logger)
+	depthMonitor.Start()
+
+	// Check Ollama in the background and in deep health checks
+	healthChecks := map[string]api.HealthCheck{"redis": queueInspector.Health}
+	var ollamaMonitor *ollama.HealthMonitor
+	if ollamaClient != nil {
+		ollamaMonitor = ollama.NewHealthMonitor(ollamaClient, *ollamaHealthInterval, prometheus.DefaultRegisterer, logger)
+		ollamaMonitor.Start()
+		healthChecks["ollama"] = ollamaClient.Ready
+	}
+
+	// Prune old analyses that were never enriched and scored low
+	retentionJob := queue.NewRetentionJob(db, queue.RetentionConfig{
+		Days:       *retentionDays,
+		MinQuality: *retentionMinQuality,
+		Interval:   *retentionInterval,
+		BatchSize:  *retentionBatchSize,
+		MaxPerRun:  *retentionMaxPerRun,
+	}, prometheus.DefaultRegisterer, logger)
+	retentionJob.Start()
+	if *retentionDays > 0 {
+		logger.Info("retention job started", "days", *retentionDays, "min_quality", *retentionMinQuality, "interval", *retentionInterval)
+	}
+
+	// Initialize queue worker, applying settings changed through the admin API
+	workerConfig := queue.WorkerConfig{
+		RedisAddr:   *redisAddr,
+		Concurrency: *workerConcurrency,
+		MaxRetries:  *ollamaMaxRetries,
+
+		HeartbeatInterval: *heartbeatInterval,
+		MaxImages:         *maxImages,
+
+		ShadowClient:     shadowClient,
+		ShadowSampleRate: *shadowSampleRate,
+
+		WebhookSecret:       *webhookSecret,
+		WebhookMaxAttempts:  *webhookMaxAttempts,
+		WebhookAllowPrivate: *webhookAllowPrivate,
+	}
+	if stored, err := db.GetWorkerSettings(context.Background()); err != nil {
+		logger.Warn("failed to load stored worker settings, using flags", "error", err)
+	} else if stored != nil {
+		if err := queue.ValidateWorkerSettings(*stored); err != nil {
+			logger.Warn("ignoring invalid stored worker settings", "error", err)
+		} else {
+			workerConfig.Concurrency = stored.Concurrency
+			workerConfig.QueueWeights = stored.QueueWeights
+			workerConfig.StrictPriority = stored.StrictPriority
+			logger.Info("using stored worker settings", "concurrency", stored.Concurrency)
+		}
+	}
 	queueWorker := queue.NewWorker(
-		queue.WorkerConfig{
-			RedisAddr:   *redisAddr,
-			Concurrency: *workerConcurrency,
-			MaxRetries:  *ollamaMaxRetries,
-		},
+		workerConfig,
 		db,
 		textAnalyzer,
 		queueClient,
@@ -148,8 +582,38 @@ func main() {
 		}
 	}()
 
+	// Pages submitted to /api/analyze/url are fetched with a bounded client
+	pageFetcher := analyzer.NewPageFetcher(analyzer.PageFetchConfig{
+		Timeout:      *fetchTimeout,
+		MaxBytes:     *fetchMaxBytes,
+		MaxRedirects: *fetchMaxRedirects,
+		UserAgent:    *fetchUserAgent,
+	})
+
 	// Initialize API handler with queue client
-	apiHandler := api.NewHandler(db, textAnalyzer, queueClient)
+	apiHandler := api.NewHandler(db, textAnalyzer, queueClient, api.Config{
+		EnrichmentThresholds:  enrichmentThresholds,
+		HeartbeatStaleAfter:   *heartbeatStaleAfter,
+		MaxImages:             *maxImages,
+		TruncateImages:        *truncateImages,
+		MaxBodyBytes:          *maxBodyBytes,
+		MaxHTMLBytes:          *maxHTMLBytes,
+		PageFetcher:           pageFetcher,
+		EnrichmentSteps:       &defaultEnrichment,
+		Inspector:             queueInspector,
+		RedactText:            !*storeText,
+		AllowPrivateCallbacks: *webhookAllowPrivate,
+		Worker:                queueWorker,
+		AdminToken:            *adminToken,
+		QueueDepth:            depthMonitor,
+		BackPressure: api.BackPressureConfig{
+			Mode:                 *backPressureMode,
+			MaxPendingEnrichment: *backPressureMaxEnrichment,
+			MaxPendingOffline:    *backPressureMaxOffline,
+			RetryAfter:           *backPressureRetryAfter,
+		},
+		HealthChecks: healthChecks,
+	})
 
 	// Setup server with middleware chain (applied bottom-up, executes top-down):
 	// Execution order: tracing -> metrics -> logging -> handlers
@@ -181,8 +645,9 @@ func main() {
 			"db_host", dbHost,
 			"db_name", dbName,
 			"ollama_enabled", *useOllama,
-			"ollama_url", *ollamaURL,
-			"ollama_model", *ollamaModel,
+			"llm_backend", llm.backend,
+			"llm_url", llm.url(),
+			"llm_model", primaryModel,
 		)
 
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -209,6 +674,25 @@ func main() {
 		logger.Error("error closing queue client", "error", err)
 	}
 
+	// Stop pruning, letting a run in progress finish
+	retentionJob.Stop()
+
+	// Stop polling queue depths before closing the inspector they are read through
+	depthMonitor.Stop()
+	if ollamaMonitor != nil {
+		ollamaMonitor.Stop()
+	}
+
+	// Close queue inspector
+	if err := queueInspector.Close(); err != nil {
+		logger.Error("error closing queue inspector", "error", err)
+	}
+	if closer, ok := responseCache.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			logger.Error("error closing LLM cache", "error", err)
+		}
+	}
+
 	// Shutdown HTTP server
 	if err := srv.Shutdown(ctx); err != nil {
 		logger.Error("server forced to shutdown", "error", err)
@@ -218,6 +702,207 @@ func main() {
 	logger.Info("server stopped")
 }
 
+// ollamaStartupCheckTimeout bounds the model check at startup
+const ollamaStartupCheckTimeout = 10 * time.Second
+
+// checkOllamaModel checks at startup that the Ollama server has pulled the
+// client's model or one of its fallback models. It reports false only when
+// the server answered without any of them; an unreachable server may still
+// come up.
+func checkOllamaModel(client *ollama.Client, logger *slog.Logger) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), ollamaStartupCheckTimeout)
+	defer cancel()
+
+	if err := client.Health(ctx); err != nil {
+		logger.Warn("ollama is unreachable at startup, AI enrichment fails until it is up",
+			"error", err,
+			"model", client.Model(),
+		)
+		return true
+	}
+	found, err := client.HasModel(ctx, client.Model())
+	if err != nil {
+		logger.Warn("failed to list ollama models at startup", "error", err, "model", client.Model())
+		return true
+	}
+	if found {
+		logger.Info("ollama model available", "model", client.Model())
+	} else {
+		models, _ := client.ListModels(ctx)
+		logger.Error("OLLAMA MODEL NOT FOUND: pull it with `ollama pull` or fix the model name",
+			"model", client.Model(),
+			"available_models", models,
+		)
+	}
+
+	for _, fallback := range client.FallbackModels() {
+		if ok, err := client.HasModel(ctx, fallback); err != nil || !ok {
+			logger.Warn("ollama fallback model not found", "fallback_model", fallback)
+			continue
+		}
+		if !found {
+			logger.Warn("serving AI enrichment with fallback models", "model", client.Model(), "fallback_model", fallback)
+			found = true
+		}
+	}
+	return found
+}
+
+// LLM backends selectable with LLM_BACKEND
+const (
+	llmBackendOllama = "ollama"
+	llmBackendOpenAI = "openai"
+)
+
+// llmConfig holds the settings of the LLM backend that serves AI enrichment
+type llmConfig struct {
+	backend            string
+	ollamaURL          string
+	ollamaMaxAttempts  int
+	ollamaRetryBackoff time.Duration
+	ollamaTimeout      time.Duration
+	qualityTimeout     time.Duration
+	ollamaKeepAlive    time.Duration
+	ollamaTemperature  float64
+	ollamaNumCtx       int
+	maxConcurrent      int
+	breakerThreshold   int
+	breakerCooldown    time.Duration
+	openAIURL          string
+	openAIAPIKey       string
+	chunkBudget        int
+	chunkOverlap       int
+	maxChunks          int
+	cache              ollama.Cache // Ollama responses are not cached when nil
+	cacheTTL           time.Duration
+	cacheMaxEntryBytes int
+}
+
+// newClient creates a client for model on the configured backend. opts
+// apply to Ollama clients only.
+func (c llmConfig) newClient(model string, opts ...ollama.Option) (analyzer.LLMClient, error) {
+	// Return a nil interface on error rather than one holding a nil client
+	switch c.backend {
+	case llmBackendOllama:
+		client, err := c.newOllamaClient(model, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
+	case llmBackendOpenAI:
+		client, err := openai.New(c.openAIURL, model, c.openAIAPIKey)
+		if err != nil {
+			return nil, err
+		}
+		client.SetChunking(c.chunkBudget, c.chunkOverlap, c.maxChunks)
+		return client, nil
+	default:
+		return nil, fmt.Errorf("unknown LLM backend %q, must be %s or %s", c.backend, llmBackendOllama, llmBackendOpenAI)
+	}
+}
+
+// newOllamaClient creates an Ollama client for model with the configured
+// generation options, request retries, circuit breaker and chunking, and
+// opts
+func (c llmConfig) newOllamaClient(model string, opts ...ollama.Option) (*ollama.Client, error) {
+	client, err := ollama.New(c.ollamaURL, model, append(c.ollamaOptions(), opts...)...)
+	if err != nil {
+		return nil, err
+	}
+	client.SetRetryPolicy(c.ollamaMaxAttempts, c.ollamaRetryBackoff)
+	client.SetCircuitBreaker(c.breakerThreshold, c.breakerCooldown)
+	client.SetChunking(c.chunkBudget, c.chunkOverlap, c.maxChunks)
+	return client, nil
+}
+
+// ollamaOptions returns the options shared by the configured Ollama clients
+func (c llmConfig) ollamaOptions() []ollama.Option {
+	modelOptions := map[string]any{}
+	if c.ollamaTemperature >= 0 {
+		modelOptions["temperature"] = c.ollamaTemperature
+	}
+	if c.ollamaNumCtx > 0 {
+		modelOptions["num_ctx"] = c.ollamaNumCtx
+	}
+
+	opts := []ollama.Option{
+		ollama.WithTimeout(c.ollamaTimeout),
+		ollama.WithOptions(modelOptions),
+		// The same text always gets the same tags
+		ollama.WithCallOptions(ollama.CallTags, ollama.CallOptions{Options: map[string]any{"temperature": 0}}),
+		ollama.WithCallOptions(ollama.CallQuality, ollama.CallOptions{Timeout: c.qualityTimeout}),
+		ollama.WithMaxConcurrent(c.maxConcurrent),
+	}
+	if c.ollamaKeepAlive > 0 {
+		opts = append(opts, ollama.WithKeepAlive(c.ollamaKeepAlive))
+	}
+	if c.cache != nil {
+		opts = append(opts, ollama.WithCache(c.cache, c.cacheTTL, c.cacheMaxEntryBytes))
+	}
+	return opts
+}
+
+// Response caches selectable with LLM_CACHE
+const (
+	llmCacheOff    = "off"
+	llmCacheMemory = "memory"
+	llmCacheRedis  = "redis"
+)
+
+// newLLMCache creates the response cache named by mode, or nil when caching
+// is off. The Redis cache shares the queue's Redis server.
+func newLLMCache(mode, redisAddr string, maxEntries int) (ollama.Cache, error) {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case llmCacheOff, "":
+		return nil, nil
+	case llmCacheMemory:
+		return ollama.NewMemoryCache(maxEntries), nil
+	case llmCacheRedis:
+		return ollama.NewRedisCache(redisAddr), nil
+	default:
+		return nil, fmt.Errorf("unknown LLM cache %q, must be %s, %s or %s", mode, llmCacheOff, llmCacheMemory, llmCacheRedis)
+	}
+}
+
+// loadLexicons reads the stop words and sentiment words files that are set,
+// returning the analyzer options applying them in mode
+func loadLexicons(stopWordsPath, sentimentLexiconPath, mode string) ([]analyzer.Option, error) {
+	if err := analyzer.ValidateLexiconMode(mode); err != nil {
+		return nil, err
+	}
+
+	var opts []analyzer.Option
+	if stopWordsPath != "" {
+		stopWords, err := analyzer.LoadStopWords(stopWordsPath)
+		if err != nil {
+			return nil, err
+		}
+		if mode == analyzer.LexiconMerge {
+			stopWords = analyzer.MergeStopWords(analyzer.DefaultStopWords(), stopWords)
+		}
+		opts = append(opts, analyzer.WithStopWords(stopWords))
+	}
+	if sentimentLexiconPath != "" {
+		lexicon, err := analyzer.LoadSentimentLexicon(sentimentLexiconPath)
+		if err != nil {
+			return nil, err
+		}
+		if mode == analyzer.LexiconMerge {
+			lexicon = analyzer.DefaultSentimentLexicon().Merge(lexicon)
+		}
+		opts = append(opts, analyzer.WithSentimentLexicon(lexicon))
+	}
+	return opts, nil
+}
+
+// url returns the API URL of the configured backend
+func (c llmConfig) url() string {
+	if c.backend == llmBackendOpenAI {
+		return c.openAIURL
+	}
+	return c.ollamaURL
+}
+
 // getEnv retrieves an environment variable or returns a default value
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -243,3 +928,23 @@ func getEnvInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+// getEnvFloat retrieves a float environment variable or returns a default value
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
+// getEnvDuration retrieves a duration environment variable (e.g. "15s") or returns a default value
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if duration, err := time.ParseDuration(value); err == nil {
+			return duration
+		}
+	}
+	return defaultValue
+}
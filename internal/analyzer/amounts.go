@@ -0,0 +1,149 @@
+package analyzer
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docutag/textanalyzer/internal/models"
+)
+
+// currencyNames are the ISO 4217 codes of currency symbols and of the
+// currency names written after an amount, as in "5 million dollars"
+var currencyNames = map[string]string{
+	"us$":     "USD",
+	"c$":      "CAD",
+	"a$":      "AUD",
+	"$":       "USD",
+	"€":       "EUR",
+	"£":       "GBP",
+	"¥":       "JPY",
+	"₹":       "INR",
+	"dollar":  "USD",
+	"dollars": "USD",
+	"euro":    "EUR",
+	"euros":   "EUR",
+}
+
+// amountScales are the multipliers of scale words and abbreviations
+// written after an amount, as in "$5 million" and "€1.2bn"
+var amountScales = map[string]float64{
+	"thousand": 1e3,
+	"k":        1e3,
+	"million":  1e6,
+	"m":        1e6,
+	"mn":       1e6,
+	"mln":      1e6,
+	"billion":  1e9,
+	"bn":       1e9,
+	"b":        1e9,
+	"trillion": 1e12,
+	"tn":       1e12,
+}
+
+// Parts of the monetary amount patterns
+const (
+	// A number with optional thousands separators and decimals
+	amountNumber = `\d{1,3}(?:,\d{3})+(?:\.\d+)?|\d+(?:\.\d+)?`
+	// An optional scale word or abbreviation (see amountScales)
+	amountScale = `(?:\s?(?i:thousand|million|billion|trillion|mln|mn|bn|tn|k|m|b)\b)?`
+	// ISO 4217 codes of common currencies
+	currencyCodes = `USD|EUR|GBP|JPY|CHF|CAD|AUD|CNY|INR`
+)
+
+// Patterns of the monetary amounts extractMonetaryAmounts reports. Each has
+// a "currency" and an "amount" group, and an optional "scale" group.
+var amountPatterns = []*regexp.Regexp{
+	// $5, €1.2bn, US$ 3.5 million
+	regexp.MustCompile(`(?P<currency>US\$|C\$|A\$|[$€£¥₹])\s?(?P<amount>` + amountNumber + `)(?P<scale>` + amountScale + `)`),
+	// USD 5 million, EUR 1,200
+	regexp.MustCompile(`\b(?P<currency>` + currencyCodes + `)\s?(?P<amount>` + amountNumber + `)(?P<scale>` + amountScale + `)`),
+	// 5 million USD, 20 euros, 300 €
+	regexp.MustCompile(`\b(?P<amount>` + amountNumber + `)(?P<scale>` + amountScale + `)\s?(?P<currency>(?:` + currencyCodes + `)\b|(?i:dollars?|euros?)\b|[€£])`),
+}
+
+// percentagePattern matches percentages such as 12%, 4.5 percent and
+// 30 per cent
+var percentagePattern = regexp.MustCompile(`\b(\d+(?:\.\d+)?)\s?(?:%|(?i:percent|per cent)\b)`)
+
+// extractMonetaryAmounts extracts the monetary amounts in text with their
+// ISO 4217 currency and value, in order of appearance. Amounts of the same
+// currency and value are reported once, as first written.
+func extractMonetaryAmounts(text string) []models.MonetaryAmount {
+	type found struct {
+		start  int
+		amount models.MonetaryAmount
+	}
+
+	var matches []found
+	taken := make([]bool, len(text))
+	for _, pattern := range amountPatterns {
+		for _, m := range pattern.FindAllStringSubmatchIndex(text, -1) {
+			start, end := m[0], m[1]
+			if taken[start] || taken[end-1] {
+				continue
+			}
+
+			group := func(name string) string {
+				i := pattern.SubexpIndex(name)
+				if i < 0 || m[2*i] < 0 {
+					return ""
+				}
+				return text[m[2*i]:m[2*i+1]]
+			}
+			value, err := strconv.ParseFloat(strings.ReplaceAll(group("amount"), ",", ""), 64)
+			if err != nil {
+				continue
+			}
+			if scale := strings.ToLower(strings.TrimSpace(group("scale"))); scale != "" {
+				value *= amountScales[scale]
+			}
+
+			markTaken(taken, start, end)
+			matches = append(matches, found{start, models.MonetaryAmount{
+				Raw:      text[start:end],
+				Currency: currencyCode(group("currency")),
+				Value:    math.Round(value*100) / 100,
+			}})
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool { return matches[i].start < matches[j].start })
+
+	var amounts []models.MonetaryAmount
+	seen := make(map[models.MonetaryAmount]bool)
+	for _, match := range matches {
+		key := models.MonetaryAmount{Currency: match.amount.Currency, Value: match.amount.Value}
+		if !seen[key] {
+			seen[key] = true
+			amounts = append(amounts, match.amount)
+		}
+	}
+	return amounts
+}
+
+// currencyCode returns the ISO 4217 code of a currency symbol, code or name
+func currencyCode(currency string) string {
+	if code, ok := currencyNames[strings.ToLower(currency)]; ok {
+		return code
+	}
+	return strings.ToUpper(currency)
+}
+
+// extractPercentages extracts the percentages in text, in order of
+// appearance. Each value is reported once, as first written.
+func extractPercentages(text string) []models.Percentage {
+	var percentages []models.Percentage
+	seen := make(map[float64]bool)
+	for _, m := range percentagePattern.FindAllStringSubmatch(text, -1) {
+		value, err := strconv.ParseFloat(m[1], 64)
+		if err != nil || seen[value] {
+			continue
+		}
+		seen[value] = true
+		percentages = append(percentages, models.Percentage{Raw: m[0], Value: value})
+	}
+	return percentages
+}
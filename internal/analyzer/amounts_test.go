@@ -0,0 +1,129 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/docutag/textanalyzer/internal/models"
+)
+
+func TestExtractMonetaryAmounts(t *testing.T) {
+	tests := []struct {
+		text     string
+		raw      string // Empty when no amount is found
+		currency string
+		value    float64
+	}{
+		// Symbols
+		{"$5", "$5", "USD", 5},
+		{"$1,234.56", "$1,234.56", "USD", 1234.56},
+		{"€1.2bn", "€1.2bn", "EUR", 1.2e9},
+		{"£300m", "£300m", "GBP", 300e6},
+		{"$2.5 million", "$2.5 million", "USD", 2.5e6},
+		{"$40k", "$40k", "USD", 40000},
+		{"$3 trillion", "$3 trillion", "USD", 3e12},
+		{"¥500", "¥500", "JPY", 500},
+		{"US$ 3.5 billion", "US$ 3.5 billion", "USD", 3.5e9},
+		{"C$20", "C$20", "CAD", 20},
+		{"A$ 7", "A$ 7", "AUD", 7},
+
+		// Codes before and after the amount
+		{"USD 5 million", "USD 5 million", "USD", 5e6},
+		{"EUR 1,200", "EUR 1,200", "EUR", 1200},
+		{"CHF 80", "CHF 80", "CHF", 80},
+		{"5 million USD", "5 million USD", "USD", 5e6},
+		{"1.2bn GBP", "1.2bn GBP", "GBP", 1.2e9},
+
+		// Names and symbols after the amount
+		{"5 million dollars", "5 million dollars", "USD", 5e6},
+		{"20 euros", "20 euros", "EUR", 20},
+		{"1 dollar", "1 dollar", "USD", 1},
+		{"300 €", "300 €", "EUR", 300},
+		{"45£", "45£", "GBP", 45},
+
+		// Numbers without a currency
+		{"5 million people", "", "", 0},
+		{"1.2bn", "", "", 0},
+		{"20 pounds of flour", "", "", 0},
+		{"in 2024", "", "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got := extractMonetaryAmounts("It came to " + tt.text + " in the end.")
+			if tt.raw == "" {
+				if len(got) != 0 {
+					t.Errorf("Expected no amount in %q, got %+v", tt.text, got)
+				}
+				return
+			}
+			want := []models.MonetaryAmount{{Raw: tt.raw, Currency: tt.currency, Value: tt.value}}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Expected %+v, got %+v", want, got)
+			}
+		})
+	}
+}
+
+func TestExtractMonetaryAmountsOrderAndDuplicates(t *testing.T) {
+	text := "Revenue reached €1.2bn, up from EUR 900 million. Costs were $40k, or $40,000, and " +
+		"the fund holds 5 million USD. Analysts expected €1.2 billion."
+	want := []models.MonetaryAmount{
+		{Raw: "€1.2bn", Currency: "EUR", Value: 1.2e9},
+		{Raw: "EUR 900 million", Currency: "EUR", Value: 9e8},
+		{Raw: "$40k", Currency: "USD", Value: 40000},
+		{Raw: "5 million USD", Currency: "USD", Value: 5e6},
+	}
+	if got := extractMonetaryAmounts(text); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+}
+
+func TestExtractPercentages(t *testing.T) {
+	text := "Sales grew 12% while costs rose 4.5 percent, and 30 per cent of staff left. " +
+		"Another 12 % were hired, against 100% of targets."
+	want := []models.Percentage{
+		{Raw: "12%", Value: 12},
+		{Raw: "4.5 percent", Value: 4.5},
+		{Raw: "30 per cent", Value: 30},
+		{Raw: "100%", Value: 100},
+	}
+	if got := extractPercentages(text); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+	if got := extractPercentages("No figures here, 1990 and 42 apples."); got != nil {
+		t.Errorf("Expected no percentages, got %+v", got)
+	}
+}
+
+func TestPercentagesAreNotStatisticReferences(t *testing.T) {
+	for _, ref := range extractReferences("Turnout fell to 42% and 3 percent of ballots were spoiled.") {
+		if ref.Type == "statistic" {
+			t.Errorf("Expected percentages left out of statistic references, got %+v", ref)
+		}
+	}
+}
+
+func TestFiguresInMetadata(t *testing.T) {
+	text := "The company reported revenue of $2.5 million, up 12% on last year. " +
+		"Investors can call +1 (555) 234-5678 or email ir@example.com for the full report."
+
+	a := New()
+	offline := a.AnalyzeOffline(text)
+	online := a.Analyze(text)
+
+	wantPhones := []string{"+15552345678"}
+	wantAmounts := []models.MonetaryAmount{{Raw: "$2.5 million", Currency: "USD", Value: 2.5e6}}
+	wantPercentages := []models.Percentage{{Raw: "12%", Value: 12}}
+	for name, metadata := range map[string]models.Metadata{"offline": offline, "online": online} {
+		if !reflect.DeepEqual(metadata.PhoneNumbers, wantPhones) {
+			t.Errorf("%s: expected phone numbers %v, got %v", name, wantPhones, metadata.PhoneNumbers)
+		}
+		if !reflect.DeepEqual(metadata.MonetaryAmounts, wantAmounts) {
+			t.Errorf("%s: expected monetary amounts %+v, got %+v", name, wantAmounts, metadata.MonetaryAmounts)
+		}
+		if !reflect.DeepEqual(metadata.Percentages, wantPercentages) {
+			t.Errorf("%s: expected percentages %+v, got %+v", name, wantPercentages, metadata.Percentages)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Offline stage benchmarks. Fixtures live in testdata:
+//   - bench_small.txt: about 1KB of prose
+//   - bench_medium.txt: about 50KB of articles
+//   - bench_scraped_page.txt: an article surrounded by navigation, cookie
+//     banners, share links and footer boilerplate
+//
+// The large (1MB) fixture is built by repeating the medium one.
+//
+// Run with `make bench-offline`.
+
+// largeFixtureSize is the size of the generated large fixture
+const largeFixtureSize = 1 << 20
+
+// defaultOfflineBudget is the wall-clock budget for AnalyzeOffline on the
+// medium fixture. It is deliberately generous, about ten times a typical run
+// on a laptop, so it only catches order-of-magnitude regressions. Override it
+// with TEXTANALYZER_OFFLINE_BUDGET (e.g. "10s") on slow CI machines.
+const defaultOfflineBudget = 3 * time.Second
+
+// loadFixture reads a benchmark fixture from testdata
+func loadFixture(tb testing.TB, name string) string {
+	tb.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		tb.Fatalf("Failed to read fixture %s: %v", name, err)
+	}
+	return string(data)
+}
+
+// largeFixture repeats the medium fixture until it reaches largeFixtureSize
+func largeFixture(tb testing.TB) string {
+	tb.Helper()
+
+	medium := loadFixture(tb, "bench_medium.txt")
+	var sb strings.Builder
+	for sb.Len() < largeFixtureSize {
+		sb.WriteString(medium)
+		sb.WriteString("\n\n")
+	}
+	return sb.String()
+}
+
+func BenchmarkAnalyzeOffline(b *testing.B) {
+	fixtures := []struct {
+		name string
+		text string
+	}{
+		{"small", loadFixture(b, "bench_small.txt")},
+		{"medium", loadFixture(b, "bench_medium.txt")},
+		{"large", largeFixture(b)},
+	}
+
+	a := New()
+	for _, fixture := range fixtures {
+		b.Run(fixture.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(fixture.text)))
+			for i := 0; i < b.N; i++ {
+				a.AnalyzeOffline(fixture.text)
+			}
+		})
+	}
+}
+
+// BenchmarkAnalyzeMedium runs Analyze, without Ollama, on the medium fixture
+func BenchmarkAnalyzeMedium(b *testing.B) {
+	a := New()
+	text := loadFixture(b, "bench_medium.txt")
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(text)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		a.Analyze(text)
+	}
+}
+
+func BenchmarkCleanTextOffline(b *testing.B) {
+	a := New()
+	text := loadFixture(b, "bench_scraped_page.txt")
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(text)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		a.cleanTextOffline(text)
+	}
+}
+
+func BenchmarkScoreTextQualityFallback(b *testing.B) {
+	a := New()
+	text := loadFixture(b, "bench_medium.txt")
+	words := extractWords(text)
+	readability := calculateReadability(words, countSentences(text))
+	coherence := a.coherenceMetrics(text, words)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(text)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		scoreTextQualityFallback(text, len(words), &readability, coherence, LanguageEnglish)
+	}
+}
+
+// BenchmarkCountSyllablesInWord counts the syllables of every word of the
+// medium fixture, since readability runs it once per word
+func BenchmarkCountSyllablesInWord(b *testing.B) {
+	words := extractWords(loadFixture(b, "bench_medium.txt"))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, word := range words {
+			countSyllablesInWord(word)
+		}
+	}
+}
+
+// TestAnalyzeOfflineBudget fails when AnalyzeOffline on the medium fixture
+// takes longer than the configured wall-clock budget. The best of a few runs
+// is compared so that a single slow run does not fail the test.
+func TestAnalyzeOfflineBudget(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping performance budget in short mode")
+	}
+
+	budget := defaultOfflineBudget
+	if value := os.Getenv("TEXTANALYZER_OFFLINE_BUDGET"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			t.Fatalf("Invalid TEXTANALYZER_OFFLINE_BUDGET %q: %v", value, err)
+		}
+		budget = parsed
+	}
+
+	a := New()
+	text := loadFixture(t, "bench_medium.txt")
+
+	best := time.Duration(0)
+	for i := 0; i < 3; i++ {
+		start := time.Now()
+		a.AnalyzeOffline(text)
+		if elapsed := time.Since(start); best == 0 || elapsed < best {
+			best = elapsed
+		}
+	}
+
+	t.Logf("AnalyzeOffline on %d bytes took %v (budget %v)", len(text), best, budget)
+	if best > budget {
+		t.Errorf("AnalyzeOffline on the medium fixture took %v, over the %v budget", best, budget)
+	}
+}
@@ -0,0 +1,51 @@
+package analyzer
+
+import (
+	"context"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// DefaultEnrichmentConcurrency is the default number of AI enrichment steps
+// of one document that call Ollama at the same time
+const DefaultEnrichmentConcurrency = 3
+
+// SetEnrichmentConcurrency sets how many AI enrichment steps of one document
+// call Ollama at the same time. Values of zero or less restore
+// DefaultEnrichmentConcurrency; 1 runs the steps one after another.
+func (a *Analyzer) SetEnrichmentConcurrency(n int) {
+	a.enrichmentConcurrency = n
+}
+
+// enrichmentStep is an AI enrichment step that runs after cleaning. run
+// writes only the step's own metadata fields and returns its outcome.
+type enrichmentStep struct {
+	name string
+	run  func(ctx context.Context) string
+}
+
+// runEnrichmentSteps runs steps concurrently, at most the configured number
+// at a time, and records their outcomes in status once all have finished.
+// Each step falls back on its own failures, so one failing never cancels
+// the others; a done ctx fails the model calls still to be made.
+func (a *Analyzer) runEnrichmentSteps(ctx context.Context, status map[string]string, steps []enrichmentStep) {
+	limit := a.enrichmentConcurrency
+	if limit <= 0 {
+		limit = DefaultEnrichmentConcurrency
+	}
+
+	outcomes := make([]string, len(steps))
+	var g errgroup.Group
+	g.SetLimit(limit)
+	for i, step := range steps {
+		g.Go(func() error {
+			outcomes[i] = step.run(ctx)
+			return nil
+		})
+	}
+	g.Wait()
+
+	for i, step := range steps {
+		status[step.name] = outcomes[i]
+	}
+}
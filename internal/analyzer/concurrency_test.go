@@ -0,0 +1,116 @@
+package analyzer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docutag/textanalyzer/internal/ollama"
+)
+
+// overlapServer is a fake Ollama server that answers each step's prompt from
+// stepResponses after a delay, recording how many calls were in flight at once
+type overlapServer struct {
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+	cleanAlone  bool // Whether cleaning ran with no other call in flight
+}
+
+func newOverlapAnalyzer(t *testing.T, concurrency int) (*Analyzer, *overlapServer) {
+	t.Helper()
+
+	recorder := &overlapServer{cleanAlone: true}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Prompt string `json:"prompt"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		step := ""
+		for phrase, name := range promptSteps {
+			if strings.Contains(req.Prompt, phrase) {
+				step = name
+			}
+		}
+
+		recorder.mu.Lock()
+		recorder.inFlight++
+		recorder.maxInFlight = max(recorder.maxInFlight, recorder.inFlight)
+		if step == StepClean && recorder.inFlight > 1 {
+			recorder.cleanAlone = false
+		}
+		recorder.mu.Unlock()
+
+		time.Sleep(50 * time.Millisecond)
+
+		recorder.mu.Lock()
+		recorder.inFlight--
+		recorder.mu.Unlock()
+
+		json.NewEncoder(w).Encode(map[string]interface{}{"response": stepResponses[step], "done": true})
+	}))
+	t.Cleanup(server.Close)
+
+	// The client is not limited, so the analyzer alone bounds the calls
+	client, err := ollama.New(server.URL, "test-model", ollama.WithMaxConcurrent(0))
+	if err != nil {
+		t.Fatalf("Failed to create Ollama client: %v", err)
+	}
+	a := NewWithOllama(client)
+	a.SetEnrichmentConcurrency(concurrency)
+	return a, recorder
+}
+
+func TestEnrichmentStepsRunConcurrently(t *testing.T) {
+	ctx := context.Background()
+
+	sequential, sequentialCalls := newOverlapAnalyzer(t, 1)
+	want := sequential.AnalyzeWithOptions(ctx, synopsisFixture, AnalysisOptions{})
+	if sequentialCalls.maxInFlight != 1 {
+		t.Errorf("Expected one call at a time with concurrency 1, got %d", sequentialCalls.maxInFlight)
+	}
+
+	for _, concurrency := range []int{2, DefaultEnrichmentConcurrency} {
+		a, calls := newOverlapAnalyzer(t, concurrency)
+		got := a.AnalyzeWithOptions(ctx, synopsisFixture, AnalysisOptions{})
+		if calls.maxInFlight < 2 || calls.maxInFlight > concurrency {
+			t.Errorf("Expected between 2 and %d calls at once, got %d", concurrency, calls.maxInFlight)
+		}
+		if !calls.cleanAlone {
+			t.Errorf("Expected cleaning to finish before the other steps start (concurrency %d)", concurrency)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected the same metadata as sequential analysis (concurrency %d)\nwant %+v\ngot  %+v", concurrency, want, got)
+		}
+	}
+
+	a, calls := newOverlapAnalyzer(t, 0)
+	got := a.AnalyzeWithHTMLContext(ctx, synopsisFixture, synopsisFixture, "<p>html</p>", AnalysisOptions{})
+	if calls.maxInFlight != DefaultEnrichmentConcurrency {
+		t.Errorf("Expected %d calls at once by default, got %d", DefaultEnrichmentConcurrency, calls.maxInFlight)
+	}
+	if got.Synopsis != stepResponses[StepSynopsis] || got.EditorialAnalysis != "Informational reporting on local transit." {
+		t.Errorf("Expected model results, got synopsis %q and editorial %q", got.Synopsis, got.EditorialAnalysis)
+	}
+}
+
+func TestEnrichmentStepsCancelled(t *testing.T) {
+	a, _ := newOverlapAnalyzer(t, DefaultEnrichmentConcurrency)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	metadata := a.AnalyzeWithOptions(ctx, synopsisFixture, AnalysisOptions{})
+	if result := SummarizeEnrichment(metadata.EnrichmentStatus); !result.AllFailed() {
+		t.Errorf("Expected every step to fail with a cancelled context, got %v", metadata.EnrichmentStatus)
+	}
+	if metadata.Synopsis == "" || metadata.QualityScore == nil || len(metadata.Tags) == 0 {
+		t.Errorf("Expected the steps to fall back to rule-based results, got %+v", metadata)
+	}
+}
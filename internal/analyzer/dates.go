@@ -0,0 +1,337 @@
+package analyzer
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docutag/textanalyzer/internal/models"
+)
+
+// Orders of the day and month in numeric dates such as 03/04/2024, used when
+// both are 12 or less
+const (
+	DateOrderMDY = "mdy" // March 4, as in the US
+	DateOrderDMY = "dmy" // 3 April, as in most other countries
+)
+
+// DateOrders are the orders numeric dates can be read in
+var DateOrders = []string{DateOrderMDY, DateOrderDMY}
+
+// Confidence of a normalized date
+const (
+	dateConfidenceHigh   = "high"   // Written in full and unambiguous
+	dateConfidenceMedium = "medium" // Day and month order or century assumed, or a relative day
+	dateConfidenceLow    = "low"    // Both assumed, or relative to a week, month or year
+)
+
+// ValidateDateOrder returns an error unless order is one of DateOrders
+func ValidateDateOrder(order string) error {
+	if !slices.Contains(DateOrders, order) {
+		return fmt.Errorf("date order must be one of %s, got %q", strings.Join(DateOrders, ", "), order)
+	}
+	return nil
+}
+
+// SetDateOrder sets the order ambiguous numeric dates are read in, one of
+// DateOrders. An empty order restores DateOrderMDY.
+func (a *Analyzer) SetDateOrder(order string) {
+	a.dateOrder = order
+}
+
+// SetResolveRelativeDates sets whether relative expressions such as
+// "yesterday" and "three weeks ago" are resolved into dates. Off by default,
+// as they are only as accurate as the analysis creation time is close to
+// when the text was written.
+func (a *Analyzer) SetResolveRelativeDates(resolve bool) {
+	a.resolveRelativeDates = resolve
+}
+
+// monthNames maps month names and their abbreviations to months
+var monthNames = map[string]time.Month{
+	"January": time.January, "Jan": time.January, "February": time.February, "Feb": time.February,
+	"March": time.March, "Mar": time.March, "April": time.April, "Apr": time.April, "May": time.May,
+	"June": time.June, "Jun": time.June, "July": time.July, "Jul": time.July,
+	"August": time.August, "Aug": time.August, "September": time.September, "Sept": time.September,
+	"Sep": time.September, "October": time.October, "Oct": time.October,
+	"November": time.November, "Nov": time.November, "December": time.December, "Dec": time.December,
+}
+
+// monthPattern matches the keys of monthNames
+const monthPattern = `(January|February|March|April|May|June|July|August|September|October|November|December|Jan|Feb|Mar|Apr|Jun|Jul|Aug|Sept|Sep|Oct|Nov|Dec)`
+
+// dateFormat is a written date format and how its matches are read
+type dateFormat struct {
+	pattern *regexp.Regexp
+	parse   func(a *Analyzer, groups []string) (date time.Time, confidence string, ok bool)
+}
+
+// dateFormats are the date formats extractDates reports and normalizeDates
+// reads
+var dateFormats = []dateFormat{
+	// 12/25/2024, 25-12-2024, 12/25/24
+	{regexp.MustCompile(`\b(\d{1,2})[/-](\d{1,2})[/-](\d{2}|\d{4})\b`), (*Analyzer).parseNumericDate},
+	// 2024-12-25, 2024/12/25, 2024-12-25T10:30:00Z
+	{regexp.MustCompile(`\b(\d{4})[/-](\d{1,2})[/-](\d{1,2})(?:T\d{2}:\d{2}(?::\d{2}(?:\.\d+)?)?(?:Z|[+-]\d{2}:?\d{2})?)?\b`), parseISODate},
+	// December 25, 2024, Dec. 25th 2024
+	{regexp.MustCompile(`\b` + monthPattern + `\.?\s+(\d{1,2})(?:st|nd|rd|th)?,?\s+(\d{4})\b`), parseMonthDayYear},
+	// 25 December 2024, 25th of Dec, 2024
+	{regexp.MustCompile(`\b(\d{1,2})(?:st|nd|rd|th)?\s+(?:of\s+)?` + monthPattern + `\.?,?\s+(\d{4})\b`), parseDayMonthYear},
+}
+
+// extractDates extracts potential dates as written, deduplicated and in
+// alphabetical order
+func extractDates(text string) []string {
+	unique := make(map[string]bool)
+	for _, format := range dateFormats {
+		for _, match := range format.pattern.FindAllString(text, -1) {
+			unique[match] = true
+		}
+	}
+
+	result := []string{}
+	for date := range unique {
+		result = append(result, date)
+	}
+
+	sort.Strings(result)
+	return result
+}
+
+// normalizeDates reads the dates in text as ISO 8601 days (YYYY-MM-DD).
+// With SetResolveRelativeDates, relative expressions such as "yesterday",
+// "last Tuesday" and "three weeks ago" are resolved against reference, and
+// left out when it is zero. Dates
+// naming the same day are reported once, as written with the most
+// confidence, and in chronological order. Matches that are not real days,
+// such as 02/30/2024, are skipped.
+func (a *Analyzer) normalizeDates(text string, reference time.Time) []models.DateMention {
+	byDay := make(map[string]models.DateMention)
+	add := func(raw string, date time.Time, confidence string) {
+		day := date.Format(time.DateOnly)
+		if seen, ok := byDay[day]; ok && dateConfidenceRank(seen.Confidence) >= dateConfidenceRank(confidence) {
+			return
+		}
+		byDay[day] = models.DateMention{Raw: raw, Normalized: day, Confidence: confidence}
+	}
+
+	for _, format := range dateFormats {
+		for _, groups := range format.pattern.FindAllStringSubmatch(text, -1) {
+			if date, confidence, ok := format.parse(a, groups); ok {
+				add(groups[0], date, confidence)
+			}
+		}
+	}
+	if a.resolveRelativeDates && !reference.IsZero() {
+		for _, format := range relativeDateFormats {
+			for _, groups := range format.pattern.FindAllStringSubmatch(text, -1) {
+				if date, confidence, ok := format.resolve(groups, reference); ok {
+					add(groups[0], date, confidence)
+				}
+			}
+		}
+	}
+
+	if len(byDay) == 0 {
+		return nil
+	}
+	dates := make([]models.DateMention, 0, len(byDay))
+	for _, date := range byDay {
+		dates = append(dates, date)
+	}
+	slices.SortFunc(dates, func(x, y models.DateMention) int {
+		return cmp.Compare(x.Normalized, y.Normalized)
+	})
+	return dates
+}
+
+// parseNumericDate reads a day and month in either order and a year. A
+// number over 12 can only be the day; otherwise the analyzer's date order
+// decides, with medium confidence unless day and month are equal.
+func (a *Analyzer) parseNumericDate(groups []string) (time.Time, string, bool) {
+	first, _ := strconv.Atoi(groups[1])
+	second, _ := strconv.Atoi(groups[2])
+	year, confidence := expandYear(groups[3])
+
+	month, day := first, second
+	switch {
+	case first > 12:
+		month, day = second, first
+	case second > 12 || first == second:
+	case a.dateOrder == DateOrderDMY:
+		month, day = second, first
+		confidence = lowerDateConfidence(confidence)
+	default:
+		confidence = lowerDateConfidence(confidence)
+	}
+	date, ok := validDate(year, month, day)
+	return date, confidence, ok
+}
+
+// parseISODate reads a year, month and day
+func parseISODate(_ *Analyzer, groups []string) (time.Time, string, bool) {
+	year, _ := strconv.Atoi(groups[1])
+	month, _ := strconv.Atoi(groups[2])
+	day, _ := strconv.Atoi(groups[3])
+	date, ok := validDate(year, month, day)
+	return date, dateConfidenceHigh, ok
+}
+
+// parseMonthDayYear reads a month name, a day and a year
+func parseMonthDayYear(_ *Analyzer, groups []string) (time.Time, string, bool) {
+	day, _ := strconv.Atoi(groups[2])
+	year, _ := strconv.Atoi(groups[3])
+	date, ok := validDate(year, int(monthNames[groups[1]]), day)
+	return date, dateConfidenceHigh, ok
+}
+
+// parseDayMonthYear reads a day, a month name and a year
+func parseDayMonthYear(_ *Analyzer, groups []string) (time.Time, string, bool) {
+	day, _ := strconv.Atoi(groups[1])
+	year, _ := strconv.Atoi(groups[3])
+	date, ok := validDate(year, int(monthNames[groups[2]]), day)
+	return date, dateConfidenceHigh, ok
+}
+
+// expandYear reads a two- or four-digit year. Like Go's time package, it
+// takes two-digit years from 69 as 1969-1999 and the others as 2000-2068,
+// lowering the confidence.
+func expandYear(digits string) (int, string) {
+	year, _ := strconv.Atoi(digits)
+	if len(digits) > 2 {
+		return year, dateConfidenceHigh
+	}
+	if year >= 69 {
+		return 1900 + year, dateConfidenceMedium
+	}
+	return 2000 + year, dateConfidenceMedium
+}
+
+// validDate returns the day of year, month and day, reporting false when it
+// does not exist
+func validDate(year, month, day int) (time.Time, bool) {
+	date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	return date, date.Year() == year && int(date.Month()) == month && date.Day() == day
+}
+
+// lowerDateConfidence returns the confidence one step below confidence
+func lowerDateConfidence(confidence string) string {
+	if confidence == dateConfidenceHigh {
+		return dateConfidenceMedium
+	}
+	return dateConfidenceLow
+}
+
+// dateConfidenceRank orders confidences from low to high
+func dateConfidenceRank(confidence string) int {
+	switch confidence {
+	case dateConfidenceHigh:
+		return 2
+	case dateConfidenceMedium:
+		return 1
+	}
+	return 0
+}
+
+// relativeDateFormat is a relative date expression and how its matches are
+// resolved against a reference time
+type relativeDateFormat struct {
+	pattern *regexp.Regexp
+	resolve func(groups []string, reference time.Time) (date time.Time, confidence string, ok bool)
+}
+
+// countPattern matches the counts of relative dates: a digit or a word
+const countPattern = `(a|an|one|two|three|four|five|six|seven|eight|nine|ten|eleven|twelve|\d{1,3})`
+
+// countWords are the counts of relative dates written as words
+var countWords = map[string]int{
+	"a": 1, "an": 1, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5, "six": 6,
+	"seven": 7, "eight": 8, "nine": 9, "ten": 10, "eleven": 11, "twelve": 12,
+}
+
+// weekdays maps lowercase weekday names to weekdays
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "monday": time.Monday, "tuesday": time.Tuesday, "wednesday": time.Wednesday,
+	"thursday": time.Thursday, "friday": time.Friday, "saturday": time.Saturday,
+}
+
+// relativeDateFormats are the relative date expressions normalizeDates
+// resolves
+var relativeDateFormats = []relativeDateFormat{
+	// yesterday, today, tomorrow
+	{regexp.MustCompile(`(?i)\b(yesterday|today|tomorrow)\b`), resolveNamedDay},
+	// last Tuesday, next Friday, this Monday
+	{regexp.MustCompile(`(?i)\b(last|next|this)\s+(monday|tuesday|wednesday|thursday|friday|saturday|sunday)\b`), resolveWeekday},
+	// three weeks ago, 2 days ago
+	{regexp.MustCompile(`(?i)\b` + countPattern + `\s+(day|week|month|year)s?\s+ago\b`), resolveOffset(-1)},
+	// in two weeks, in 3 days
+	{regexp.MustCompile(`(?i)\bin\s+` + countPattern + `\s+(day|week|month|year)s?\b`), resolveOffset(1)},
+}
+
+// referenceDay returns the day of reference, at midnight UTC
+func referenceDay(reference time.Time) time.Time {
+	year, month, day := reference.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// resolveNamedDay resolves yesterday, today and tomorrow. "today" often
+// means "nowadays", so it has low confidence.
+func resolveNamedDay(groups []string, reference time.Time) (time.Time, string, bool) {
+	day := referenceDay(reference)
+	switch strings.ToLower(groups[1]) {
+	case "yesterday":
+		return day.AddDate(0, 0, -1), dateConfidenceMedium, true
+	case "tomorrow":
+		return day.AddDate(0, 0, 1), dateConfidenceMedium, true
+	}
+	return day, dateConfidenceLow, true
+}
+
+// resolveWeekday resolves the last, next or this weekday: the one before the
+// reference day, the one after it, or the first from it on
+func resolveWeekday(groups []string, reference time.Time) (time.Time, string, bool) {
+	day := referenceDay(reference)
+	ahead := (int(weekdays[strings.ToLower(groups[2])]) - int(day.Weekday()) + 7) % 7
+	switch strings.ToLower(groups[1]) {
+	case "last":
+		behind := (7 - ahead) % 7
+		if behind == 0 {
+			behind = 7
+		}
+		return day.AddDate(0, 0, -behind), dateConfidenceMedium, true
+	case "next":
+		if ahead == 0 {
+			ahead = 7
+		}
+	}
+	return day.AddDate(0, 0, ahead), dateConfidenceMedium, true
+}
+
+// resolveOffset returns a resolver for counts of days, weeks, months or
+// years before (sign -1) or after (sign 1) the reference day. Months and
+// years do not say which day they mean, so they have low confidence.
+func resolveOffset(sign int) func(groups []string, reference time.Time) (time.Time, string, bool) {
+	return func(groups []string, reference time.Time) (time.Time, string, bool) {
+		count, ok := countWords[strings.ToLower(groups[1])]
+		if !ok {
+			count, _ = strconv.Atoi(groups[1])
+		}
+		count *= sign
+
+		day := referenceDay(reference)
+		switch strings.ToLower(groups[2]) {
+		case "day":
+			return day.AddDate(0, 0, count), dateConfidenceMedium, true
+		case "week":
+			return day.AddDate(0, 0, 7*count), dateConfidenceMedium, true
+		case "month":
+			return day.AddDate(0, count, 0), dateConfidenceLow, true
+		}
+		return day.AddDate(count, 0, 0), dateConfidenceLow, true
+	}
+}
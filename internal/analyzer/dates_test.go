@@ -0,0 +1,209 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/docutag/textanalyzer/internal/models"
+)
+
+// dateReference is a Wednesday
+var dateReference = time.Date(2024, time.May, 15, 16, 30, 0, 0, time.UTC)
+
+func TestNormalizeDates(t *testing.T) {
+	tests := []struct {
+		text       string
+		order      string
+		normalized string // Empty when no date is found
+		confidence string
+	}{
+		// Numeric, month first unless the first number is over 12
+		{"12/25/2024", "", "2024-12-25", "high"},
+		{"25/12/2024", "", "2024-12-25", "high"},
+		{"12-25-2024", "", "2024-12-25", "high"},
+		{"25-12-2024", DateOrderMDY, "2024-12-25", "high"},
+		{"4/4/2024", "", "2024-04-04", "high"},
+		{"1/5/2024", "", "2024-01-05", "medium"},
+		{"03/04/2024", DateOrderMDY, "2024-03-04", "medium"},
+		{"03/04/2024", DateOrderDMY, "2024-04-03", "medium"},
+		{"12/25/2024", DateOrderDMY, "2024-12-25", "high"},
+
+		// Two-digit years
+		{"12/25/24", "", "2024-12-25", "medium"},
+		{"03/04/24", "", "2024-03-04", "low"},
+		{"03/04/24", DateOrderDMY, "2024-04-03", "low"},
+		{"12/25/68", "", "2068-12-25", "medium"},
+		{"12/25/69", "", "1969-12-25", "medium"},
+		{"1/31/99", "", "1999-01-31", "medium"},
+		{"2/1/00", "", "2000-02-01", "low"},
+
+		// Year first
+		{"2024-12-25", "", "2024-12-25", "high"},
+		{"2024/12/25", "", "2024-12-25", "high"},
+		{"2024-1-5", "", "2024-01-05", "high"},
+		{"2024-12-25T10:30:00Z", "", "2024-12-25", "high"},
+		{"2024-12-25T10:30:00.123+02:00", "", "2024-12-25", "high"},
+		{"2024-12-25T10:30", "", "2024-12-25", "high"},
+
+		// Month names
+		{"December 25, 2024", "", "2024-12-25", "high"},
+		{"December 25 2024", "", "2024-12-25", "high"},
+		{"Dec 25, 2024", "", "2024-12-25", "high"},
+		{"Dec. 25, 2024", "", "2024-12-25", "high"},
+		{"Sept 5, 2024", "", "2024-09-05", "high"},
+		{"Sep 5, 2024", "", "2024-09-05", "high"},
+		{"December 25th, 2024", "", "2024-12-25", "high"},
+		{"May 1st 2024", "", "2024-05-01", "high"},
+		{"Tuesday, March 12, 2024", "", "2024-03-12", "high"},
+		{"25 December 2024", "", "2024-12-25", "high"},
+		{"25th December 2024", "", "2024-12-25", "high"},
+		{"25th of December, 2024", "", "2024-12-25", "high"},
+		{"1st of Jan. 2025", "", "2025-01-01", "high"},
+		{"3 Aug 2024", "", "2024-08-03", "high"},
+		{"February 29, 2024", "", "2024-02-29", "high"},
+
+		// Days that do not exist, and words that only look like months
+		{"02/30/2024", "", "", ""},
+		{"13/13/2024", "", "", ""},
+		{"2024-13-01", "", "", ""},
+		{"2024-02-30", "", "", ""},
+		{"February 29, 2023", "", "", ""},
+		{"31 April 2024", "", "", ""},
+		{"Mayor 5, 2024", "", "", ""},
+		{"Marching 12, 2024", "", "", ""},
+		{"12/25/202", "", "", ""},
+		{"version 1.2.2024", "", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text+"/"+tt.order, func(t *testing.T) {
+			a := New()
+			a.SetDateOrder(tt.order)
+			dates := a.normalizeDates("Filed on "+tt.text+" by the clerk.", time.Time{})
+
+			if tt.normalized == "" {
+				if len(dates) != 0 {
+					t.Errorf("Expected no date in %q, got %+v", tt.text, dates)
+				}
+				return
+			}
+			want := []models.DateMention{{Raw: tt.text, Normalized: tt.normalized, Confidence: tt.confidence}}
+			if tt.text == "Tuesday, March 12, 2024" {
+				want[0].Raw = "March 12, 2024"
+			}
+			if !reflect.DeepEqual(dates, want) {
+				t.Errorf("Expected %+v, got %+v", want, dates)
+			}
+		})
+	}
+}
+
+func TestNormalizeRelativeDates(t *testing.T) {
+	tests := []struct {
+		text       string
+		normalized string
+		confidence string
+	}{
+		{"yesterday", "2024-05-14", "medium"},
+		{"Yesterday", "2024-05-14", "medium"},
+		{"tomorrow", "2024-05-16", "medium"},
+		{"today", "2024-05-15", "low"},
+		{"last Tuesday", "2024-05-14", "medium"},
+		{"last Wednesday", "2024-05-08", "medium"},
+		{"last Thursday", "2024-05-09", "medium"},
+		{"next Friday", "2024-05-17", "medium"},
+		{"next Wednesday", "2024-05-22", "medium"},
+		{"next Monday", "2024-05-20", "medium"},
+		{"this Friday", "2024-05-17", "medium"},
+		{"this Wednesday", "2024-05-15", "medium"},
+		{"Last SUNDAY", "2024-05-12", "medium"},
+		{"2 days ago", "2024-05-13", "medium"},
+		{"a day ago", "2024-05-14", "medium"},
+		{"three weeks ago", "2024-04-24", "medium"},
+		{"one week ago", "2024-05-08", "medium"},
+		{"a month ago", "2024-04-15", "low"},
+		{"six months ago", "2023-11-15", "low"},
+		{"two years ago", "2022-05-15", "low"},
+		{"in two weeks", "2024-05-29", "medium"},
+		{"in 3 days", "2024-05-18", "medium"},
+		{"in a year", "2025-05-15", "low"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			a := New()
+			if got := a.normalizeDates("The order shipped "+tt.text+".", dateReference); got != nil {
+				t.Errorf("Expected relative dates left out unless enabled, got %+v", got)
+			}
+
+			a.SetResolveRelativeDates(true)
+			want := []models.DateMention{{Raw: tt.text, Normalized: tt.normalized, Confidence: tt.confidence}}
+			if got := a.normalizeDates("The order shipped "+tt.text+".", dateReference); !reflect.DeepEqual(got, want) {
+				t.Errorf("Expected %+v, got %+v", want, got)
+			}
+			if got := a.normalizeDates("The order shipped "+tt.text+".", time.Time{}); got != nil {
+				t.Errorf("Expected relative dates left out without a reference time, got %+v", got)
+			}
+		})
+	}
+}
+
+func TestNormalizeDatesDeduplicates(t *testing.T) {
+	text := "The hearing moved from 12/25/24 to 2024-03-04. Notices dated 03/04/24 and December 25, 2024 " +
+		"went out yesterday, and the clerk confirmed on 5/14/2024 that the 12/25/2024 date stands."
+	a := New()
+	a.SetResolveRelativeDates(true)
+	got := a.normalizeDates(text, dateReference)
+
+	// Each day is reported once, as written with the most confidence, and
+	// days are in order
+	want := []models.DateMention{
+		{Raw: "2024-03-04", Normalized: "2024-03-04", Confidence: "high"},
+		{Raw: "5/14/2024", Normalized: "2024-05-14", Confidence: "high"},
+		{Raw: "12/25/2024", Normalized: "2024-12-25", Confidence: "high"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+}
+
+func TestDatesInMetadata(t *testing.T) {
+	a := New()
+	text := "The board met on March 3, 2024 and will meet again next Friday."
+	if dates := a.AnalyzeOfflineWithOptions(text, OfflineOptions{ReferenceTime: dateReference}).Dates; len(dates) != 1 {
+		t.Errorf("Expected only the absolute date unless relative dates are resolved, got %+v", dates)
+	}
+
+	a.SetResolveRelativeDates(true)
+
+	metadata := a.AnalyzeOfflineWithOptions(text, OfflineOptions{ReferenceTime: dateReference})
+	want := []models.DateMention{
+		{Raw: "March 3, 2024", Normalized: "2024-03-03", Confidence: "high"},
+		{Raw: "next Friday", Normalized: "2024-05-17", Confidence: "medium"},
+	}
+	if !reflect.DeepEqual(metadata.Dates, want) {
+		t.Errorf("Expected dates %+v, got %+v", want, metadata.Dates)
+	}
+	// The raw list only holds the dates as written, as before
+	if !reflect.DeepEqual(metadata.PotentialDates, []string{"March 3, 2024"}) {
+		t.Errorf("Expected potential dates [March 3, 2024], got %v", metadata.PotentialDates)
+	}
+
+	if dates := a.AnalyzeOffline(text).Dates; len(dates) != 1 {
+		t.Errorf("Expected only the absolute date without a reference time, got %+v", dates)
+	}
+}
+
+func TestValidateDateOrder(t *testing.T) {
+	for _, order := range DateOrders {
+		if err := ValidateDateOrder(order); err != nil {
+			t.Errorf("ValidateDateOrder(%q) = %v, want nil", order, err)
+		}
+	}
+	for _, order := range []string{"", "ymd", "MDY"} {
+		if err := ValidateDateOrder(order); err == nil {
+			t.Errorf("ValidateDateOrder(%q) = nil, want an error", order)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package analyzer
+
+import (
+	"log/slog"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/docutag/textanalyzer/internal/models"
+)
+
+// QualityCategoryEmptyInput marks the quality score of text with no words
+const QualityCategoryEmptyInput = "empty_input"
+
+// isDegenerate reports whether text has no words to analyze: it is empty,
+// whitespace only, or made up entirely of punctuation and symbols. Letters
+// in any script count, so non-English text is never degenerate.
+func isDegenerate(text string) bool {
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// degenerateMetadata returns the minimal metadata for text with no words.
+// Extractors, ratios and AI steps are skipped; the result is flagged as
+// Degenerate with a zero, empty-input quality score.
+func degenerateMetadata(text string) models.Metadata {
+	slog.Warn("input has no words to analyze, returning minimal metadata",
+		"character_count", utf8.RuneCountInString(text))
+
+	qualityScore := emptyInputQualityScore()
+	return models.Metadata{
+		CharacterCount:   utf8.RuneCountInString(text),
+		Sentiment:        "neutral",
+		Language:         LanguageUnknown,
+		QuestionCount:    strings.Count(text, "?"),
+		ExclamationCount: strings.Count(text, "!"),
+		QualityScore:     &qualityScore,
+		Degenerate:       true,
+	}
+}
+
+// emptyInputQualityScore is the quality score of text with no words
+func emptyInputQualityScore() models.TextQualityScore {
+	return models.TextQualityScore{
+		Score:             0,
+		Reason:            "No words to analyze",
+		Categories:        []string{QualityCategoryEmptyInput, "low_quality"},
+		IsRecommended:     false,
+		QualityIndicators: []string{},
+		ProblemsDetected:  []string{"no_words"},
+		AIUsed:            false,
+	}
+}
+
+// reductionPercent returns how many fewer words cleaned text has than the
+// original, as a percentage, or 0 when the original had no words
+func reductionPercent(originalWords, cleanedWords int) float64 {
+	if originalWords == 0 {
+		return 0
+	}
+	return 100 * (1 - float64(cleanedWords)/float64(originalWords))
+}
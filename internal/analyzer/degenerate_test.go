@@ -0,0 +1,149 @@
+package analyzer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/docutag/textanalyzer/internal/models"
+)
+
+// degenerateInputs have no words to analyze
+var degenerateInputs = map[string]string{
+	"empty":            "",
+	"single space":     " ",
+	"whitespace":       " \n\t  \r\n ",
+	"single period":    ".",
+	"punctuation":      "!?.,;:-()[]{}",
+	"long punctuation": strings.Repeat("?! ... -- ", 20),
+	"symbols":          "— … · • © ® ™ € £",
+}
+
+// minimalInputs have words, but too few for most ratios to be meaningful
+var minimalInputs = map[string]string{
+	"single character": "a",
+	"single digit":     "7",
+	"single word":      "Hello",
+	"word in symbols":  "... !!! ok ???",
+	"accented":         "é",
+	"non-latin":        "日本語。",
+}
+
+// assertFiniteJSON fails if v cannot be encoded, which is the case when any
+// float is NaN or infinite
+func assertFiniteJSON(t *testing.T, v interface{}) {
+	t.Helper()
+
+	if _, err := json.Marshal(v); err != nil {
+		t.Errorf("Expected JSON-safe output, got %v", err)
+	}
+}
+
+func TestAnalyzeDegenerateInput(t *testing.T) {
+	a := New()
+
+	for name, text := range degenerateInputs {
+		t.Run(name, func(t *testing.T) {
+			for label, metadata := range map[string]models.Metadata{
+				"Analyze":        a.Analyze(text),
+				"AnalyzeOffline": a.AnalyzeOffline(text),
+			} {
+				if !metadata.Degenerate {
+					t.Errorf("%s: expected metadata to be flagged degenerate", label)
+				}
+				if metadata.WordCount != 0 || len(metadata.Tags) != 0 || len(metadata.TopWords) != 0 {
+					t.Errorf("%s: expected no extractor output, got %d words, tags %v", label, metadata.WordCount, metadata.Tags)
+				}
+				if metadata.QualityScore == nil || metadata.QualityScore.Score != 0 {
+					t.Fatalf("%s: expected a zero quality score, got %+v", label, metadata.QualityScore)
+				}
+				if metadata.QualityScore.Categories[0] != QualityCategoryEmptyInput {
+					t.Errorf("%s: expected category %q, got %v", label, QualityCategoryEmptyInput, metadata.QualityScore.Categories)
+				}
+				assertFiniteJSON(t, metadata)
+			}
+		})
+	}
+}
+
+func TestAnalyzeMinimalInput(t *testing.T) {
+	a := New()
+
+	for name, text := range minimalInputs {
+		t.Run(name, func(t *testing.T) {
+			for label, metadata := range map[string]models.Metadata{
+				"Analyze":        a.Analyze(text),
+				"AnalyzeOffline": a.AnalyzeOffline(text),
+			} {
+				if metadata.Degenerate {
+					t.Errorf("%s: input with words should not be flagged degenerate", label)
+				}
+				assertFiniteJSON(t, metadata)
+			}
+		})
+	}
+}
+
+func TestCleanTextOfflineDegenerateInput(t *testing.T) {
+	a := New()
+
+	for name, text := range mergeInputs(degenerateInputs, minimalInputs) {
+		t.Run(name, func(t *testing.T) {
+			report := a.CleanTextOfflineWithReport(text)
+			assertFiniteJSON(t, report)
+
+			for _, para := range splitIntoParagraphs(text) {
+				assertFiniteJSON(t, a.scoreParagraph(para))
+			}
+		})
+	}
+}
+
+func TestScoreTextQualityFallbackDegenerateInput(t *testing.T) {
+	for name, text := range mergeInputs(degenerateInputs, minimalInputs) {
+		t.Run(name, func(t *testing.T) {
+			score := New().ScoreQualityRuleBased(text, DefaultQualityWeights())
+			assertFiniteJSON(t, score)
+
+			emptyInput := score.Categories[0] == QualityCategoryEmptyInput
+			if emptyInput != isDegenerate(text) {
+				t.Errorf("Expected empty input category only for degenerate text, got %v", score.Categories)
+			}
+		})
+	}
+}
+
+func TestAnalyzeWithHTMLContextDegenerateInput(t *testing.T) {
+	a, prompts := newFakeOllamaAnalyzer(t, http.StatusOK, "unused")
+
+	metadata := a.AnalyzeWithHTMLContext(context.Background(), "   ", "", "<p></p>", AnalysisOptions{})
+	if !metadata.Degenerate {
+		t.Error("Expected metadata to be flagged degenerate")
+	}
+	if len(*prompts) != 0 {
+		t.Errorf("Expected no Ollama calls for degenerate input, got %d", len(*prompts))
+	}
+	assertFiniteJSON(t, metadata)
+}
+
+func TestReductionPercent(t *testing.T) {
+	if got := reductionPercent(0, 0); got != 0 {
+		t.Errorf("Expected 0 for empty input, got %v", got)
+	}
+	if got := reductionPercent(10, 4); got != 60 {
+		t.Errorf("Expected 60, got %v", got)
+	}
+}
+
+// mergeInputs combines named input sets
+func mergeInputs(sets ...map[string]string) map[string]string {
+	merged := map[string]string{}
+	for _, set := range sets {
+		for name, text := range set {
+			merged[name] = text
+		}
+	}
+	return merged
+}
@@ -0,0 +1,29 @@
+package analyzer
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/docutag/textanalyzer/internal/models"
+	"github.com/docutag/textanalyzer/internal/ollama"
+)
+
+// ValidateEditorialBias checks that bias is one of the biases editorial
+// analysis classifies texts with
+func ValidateEditorialBias(bias string) error {
+	if !slices.Contains(ollama.EditorialBiases, bias) {
+		return fmt.Errorf("bias must be one of %s", strings.Join(ollama.EditorialBiases, ", "))
+	}
+	return nil
+}
+
+// convertEditorial converts the model's editorial classification
+func convertEditorial(editorial *ollama.EditorialResult) *models.EditorialResult {
+	return &models.EditorialResult{
+		Purpose: editorial.Purpose,
+		Bias:    editorial.Bias,
+		Tone:    editorial.Tone,
+		Summary: editorial.Summary,
+	}
+}
@@ -0,0 +1,16 @@
+package analyzer
+
+import "testing"
+
+func TestValidateEditorialBias(t *testing.T) {
+	for _, bias := range []string{"left", "center-left", "none", "commercial"} {
+		if err := ValidateEditorialBias(bias); err != nil {
+			t.Errorf("Expected %q to be valid, got %v", bias, err)
+		}
+	}
+	for _, bias := range []string{"", "Left", "centre-left", "neutral"} {
+		if err := ValidateEditorialBias(bias); err == nil {
+			t.Errorf("Expected %q to be rejected", bias)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package analyzer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docutag/textanalyzer/internal/models"
+)
+
+// AI enrichment steps that can be enabled or disabled
+const (
+	StepSynopsis    = "synopsis"
+	StepClean       = "clean"
+	StepEditorial   = "editorial"
+	StepTags        = "tags"
+	StepReferences  = "references"
+	StepAIDetection = "ai_detection"
+	StepQuality     = "quality"
+)
+
+// Enrichment step outcomes recorded in Metadata.EnrichmentStatus
+const (
+	StepStatusDone              = "done"                // The model produced the result
+	StepStatusFailed            = "failed"              // The model call failed and the field is empty
+	StepStatusSkippedLowQuality = "skipped_low_quality" // The quality score was below the enrichment threshold
+	StepStatusSkippedDisabled   = "skipped_disabled"    // The step, or AI enrichment as a whole, was disabled
+	StepStatusFallback          = "fallback_rule_based" // The model was unavailable and a rule-based result was used
+)
+
+// Aggregate enrichment outcomes reported by AggregateEnrichmentStatus, in
+// addition to the skipped step statuses
+const (
+	EnrichmentStatusDone     = "done"     // Every enabled step produced a model result
+	EnrichmentStatusDegraded = "degraded" // At least one step failed or fell back to rules
+)
+
+// EnrichmentSteps lists every AI enrichment step in pipeline order
+var EnrichmentSteps = []string{
+	StepClean, StepSynopsis, StepEditorial, StepTags, StepReferences, StepAIDetection, StepQuality,
+}
+
+// AllEnrichmentSteps returns options with every AI enrichment step enabled
+func AllEnrichmentSteps() models.EnrichmentOptions {
+	return models.EnrichmentOptions{
+		Synopsis:    true,
+		Clean:       true,
+		Editorial:   true,
+		Tags:        true,
+		References:  true,
+		AIDetection: true,
+		Quality:     true,
+	}
+}
+
+// ResolveEnrichment returns the steps to run. Nil options, as recorded on
+// analyses created before steps were configurable, enable every step.
+func ResolveEnrichment(opts *models.EnrichmentOptions) models.EnrichmentOptions {
+	if opts == nil {
+		return AllEnrichmentSteps()
+	}
+	return *opts
+}
+
+// stepEnabled returns a pointer to the flag for a named step, or nil if the step is unknown
+func stepEnabled(opts *models.EnrichmentOptions, step string) *bool {
+	switch step {
+	case StepSynopsis:
+		return &opts.Synopsis
+	case StepClean:
+		return &opts.Clean
+	case StepEditorial:
+		return &opts.Editorial
+	case StepTags:
+		return &opts.Tags
+	case StepReferences:
+		return &opts.References
+	case StepAIDetection:
+		return &opts.AIDetection
+	case StepQuality:
+		return &opts.Quality
+	}
+	return nil
+}
+
+// ParseEnrichmentSteps parses a comma-separated list of enabled steps,
+// e.g. "synopsis,tags". An empty list or "all" enables every step and
+// "none" disables them all.
+func ParseEnrichmentSteps(spec string) (models.EnrichmentOptions, error) {
+	spec = strings.TrimSpace(spec)
+	switch strings.ToLower(spec) {
+	case "", "all":
+		return AllEnrichmentSteps(), nil
+	case "none":
+		return models.EnrichmentOptions{}, nil
+	}
+
+	var opts models.EnrichmentOptions
+	for _, step := range strings.Split(spec, ",") {
+		step = strings.ToLower(strings.TrimSpace(step))
+		if step == "" {
+			continue
+		}
+		enabled := stepEnabled(&opts, step)
+		if enabled == nil {
+			return models.EnrichmentOptions{}, unknownStepError(step)
+		}
+		*enabled = true
+	}
+	return opts, nil
+}
+
+// ApplyEnrichmentOverrides enables or disables individual steps on top of base
+func ApplyEnrichmentOverrides(base models.EnrichmentOptions, overrides map[string]bool) (models.EnrichmentOptions, error) {
+	opts := base
+	for step, value := range overrides {
+		enabled := stepEnabled(&opts, step)
+		if enabled == nil {
+			return base, unknownStepError(step)
+		}
+		*enabled = value
+	}
+	return opts, nil
+}
+
+// SkippedSteps returns the disabled steps in pipeline order
+func SkippedSteps(opts models.EnrichmentOptions) []string {
+	var skipped []string
+	for _, step := range EnrichmentSteps {
+		if !*stepEnabled(&opts, step) {
+			skipped = append(skipped, step)
+		}
+	}
+	return skipped
+}
+
+// EnabledSteps returns the enabled steps in pipeline order
+func EnabledSteps(opts models.EnrichmentOptions) []string {
+	enabled := []string{}
+	for _, step := range EnrichmentSteps {
+		if *stepEnabled(&opts, step) {
+			enabled = append(enabled, step)
+		}
+	}
+	return enabled
+}
+
+// unknownStepError reports an enrichment step name that is not recognized
+func unknownStepError(step string) error {
+	return fmt.Errorf("unknown enrichment step %q: must be one of %s", step, strings.Join(EnrichmentSteps, ", "))
+}
+
+// EnrichmentStatusFor returns step statuses with disabled steps marked
+// skipped_disabled and every enabled step set to status
+func EnrichmentStatusFor(steps models.EnrichmentOptions, status string) map[string]string {
+	statuses := make(map[string]string, len(EnrichmentSteps))
+	for _, step := range EnrichmentSteps {
+		if *stepEnabled(&steps, step) {
+			statuses[step] = status
+		} else {
+			statuses[step] = StepStatusSkippedDisabled
+		}
+	}
+	return statuses
+}
+
+// noModelEnrichmentStatus returns step statuses for analysis without Ollama:
+// tags, references and quality use rule-based results and the model-only
+// steps are skipped
+func noModelEnrichmentStatus() map[string]string {
+	statuses := EnrichmentStatusFor(models.EnrichmentOptions{}, StepStatusSkippedDisabled)
+	for _, step := range []string{StepTags, StepReferences, StepQuality} {
+		statuses[step] = StepStatusFallback
+	}
+	return statuses
+}
+
+// AggregateEnrichmentStatus summarizes step statuses: degraded when any step
+// failed or fell back to rules, done when the model produced at least one
+// result, and otherwise the reason steps were skipped, low quality taking
+// precedence. It returns an empty string when no statuses were recorded.
+func AggregateEnrichmentStatus(statuses map[string]string) string {
+	if len(statuses) == 0 {
+		return ""
+	}
+
+	counts := make(map[string]int)
+	for _, status := range statuses {
+		counts[status]++
+	}
+	switch {
+	case counts[StepStatusFailed] > 0 || counts[StepStatusFallback] > 0:
+		return EnrichmentStatusDegraded
+	case counts[StepStatusDone] > 0:
+		return EnrichmentStatusDone
+	case counts[StepStatusSkippedLowQuality] > 0:
+		return StepStatusSkippedLowQuality
+	}
+	return StepStatusSkippedDisabled
+}
+
+// EnrichmentResult counts the outcomes of the AI enrichment steps that ran
+type EnrichmentResult struct {
+	Succeeded int // Steps the model produced a result for
+	Failed    int // Steps whose model call failed, whether or not a rule-based result was used
+}
+
+// SummarizeEnrichment counts the step outcomes in statuses. Skipped steps are
+// not counted.
+func SummarizeEnrichment(statuses map[string]string) EnrichmentResult {
+	var result EnrichmentResult
+	for _, status := range statuses {
+		switch status {
+		case StepStatusDone:
+			result.Succeeded++
+		case StepStatusFailed, StepStatusFallback:
+			result.Failed++
+		}
+	}
+	return result
+}
+
+// AllFailed reports whether steps ran and none of them produced a model
+// result, as when Ollama is unreachable
+func (r EnrichmentResult) AllFailed() bool {
+	return r.Succeeded == 0 && r.Failed > 0
+}
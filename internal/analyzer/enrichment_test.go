@@ -0,0 +1,491 @@
+package analyzer
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docutag/textanalyzer/internal/models"
+	"github.com/docutag/textanalyzer/internal/ollama"
+)
+
+// promptSteps maps a phrase unique to each step's prompt to the step name
+var promptSteps = map[string]string{
+	"concise synopsis":                     StepSynopsis,
+	"clean the following text":             StepClean,
+	"expert text extraction":               StepClean,
+	"unbiased assessment of the nature":    StepEditorial,
+	"relevant tags":                        StepTags,
+	"extract factual claims":               StepReferences,
+	"written by an AI or a human":          StepAIDetection,
+	"content quality assessment assistant": StepQuality,
+}
+
+// promptedSteps returns the sorted, distinct steps that sent the given prompts
+func promptedSteps(t *testing.T, prompts []string) []string {
+	t.Helper()
+
+	seen := map[string]bool{}
+	for _, prompt := range prompts {
+		step := ""
+		for phrase, name := range promptSteps {
+			if strings.Contains(prompt, phrase) {
+				step = name
+			}
+		}
+		if step == "" {
+			t.Fatalf("Unrecognized prompt: %.80q", prompt)
+		}
+		seen[step] = true
+	}
+
+	steps := []string{}
+	for step := range seen {
+		steps = append(steps, step)
+	}
+	sort.Strings(steps)
+	return steps
+}
+
+// enabledSteps returns the sorted names of the enabled steps
+func enabledSteps(opts models.EnrichmentOptions) []string {
+	skipped := map[string]bool{}
+	for _, step := range SkippedSteps(opts) {
+		skipped[step] = true
+	}
+	steps := []string{}
+	for _, step := range EnrichmentSteps {
+		if !skipped[step] {
+			steps = append(steps, step)
+		}
+	}
+	sort.Strings(steps)
+	return steps
+}
+
+func TestEnrichmentStepsSendOnlyEnabledPrompts(t *testing.T) {
+	tests := []struct {
+		name string
+		opts models.EnrichmentOptions
+	}{
+		{"all", AllEnrichmentSteps()},
+		{"tags and synopsis", models.EnrichmentOptions{Synopsis: true, Tags: true}},
+		{"no editorial or detection", models.EnrichmentOptions{
+			Synopsis: true, Clean: true, Tags: true, References: true, Quality: true,
+		}},
+		{"clean only", models.EnrichmentOptions{Clean: true}},
+		{"none", models.EnrichmentOptions{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := AnalysisOptions{Enrichment: &tt.opts}
+
+			a, prompts := newFakeOllamaAnalyzer(t, http.StatusOK, "A short response.")
+			metadata := a.AnalyzeWithOptions(context.Background(), synopsisFixture, opts)
+			if got, want := promptedSteps(t, *prompts), enabledSteps(tt.opts); !reflect.DeepEqual(got, want) {
+				t.Errorf("AnalyzeWithOptions prompted %v, want %v", got, want)
+			}
+			assertSkippedFieldsEmpty(t, metadata, tt.opts)
+
+			a, prompts = newFakeOllamaAnalyzer(t, http.StatusOK, "A short response.")
+			metadata = a.AnalyzeWithHTMLContext(context.Background(), synopsisFixture, synopsisFixture, "<p>html</p>", opts)
+			if got, want := promptedSteps(t, *prompts), enabledSteps(tt.opts); !reflect.DeepEqual(got, want) {
+				t.Errorf("AnalyzeWithHTMLContext prompted %v, want %v", got, want)
+			}
+			assertSkippedFieldsEmpty(t, metadata, tt.opts)
+		})
+	}
+}
+
+// assertSkippedFieldsEmpty checks that disabled steps left their fields empty
+// and are listed in SkippedSteps
+func assertSkippedFieldsEmpty(t *testing.T, metadata models.Metadata, opts models.EnrichmentOptions) {
+	t.Helper()
+
+	if !reflect.DeepEqual(metadata.SkippedSteps, SkippedSteps(opts)) {
+		t.Errorf("Expected skipped steps %v, got %v", SkippedSteps(opts), metadata.SkippedSteps)
+	}
+	if !opts.Synopsis && metadata.Synopsis != "" {
+		t.Errorf("Expected empty synopsis, got %q", metadata.Synopsis)
+	}
+	if !opts.Clean && metadata.CleanedText != "" {
+		t.Errorf("Expected empty cleaned text, got %q", metadata.CleanedText)
+	}
+	if !opts.Editorial && metadata.EditorialAnalysis != "" {
+		t.Errorf("Expected empty editorial analysis, got %q", metadata.EditorialAnalysis)
+	}
+	if !opts.Tags && len(metadata.Tags) > 0 {
+		t.Errorf("Expected no tags, got %v", metadata.Tags)
+	}
+	if !opts.References && len(metadata.References) > 0 {
+		t.Errorf("Expected no references, got %v", metadata.References)
+	}
+	if !opts.AIDetection && metadata.AIDetection.Likelihood != "" {
+		t.Errorf("Expected empty AI detection, got %+v", metadata.AIDetection)
+	}
+	if !opts.Quality && metadata.QualityScore != nil {
+		t.Errorf("Expected no quality score, got %+v", metadata.QualityScore)
+	}
+}
+
+func TestEnrichmentStepsDefaultToAll(t *testing.T) {
+	a, prompts := newFakeOllamaAnalyzer(t, http.StatusOK, "A short response.")
+	metadata := a.AnalyzeWithOptions(context.Background(), synopsisFixture, AnalysisOptions{})
+
+	if got, want := promptedSteps(t, *prompts), enabledSteps(AllEnrichmentSteps()); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected every step to run without options, prompted %v", got)
+	}
+	if metadata.SkippedSteps != nil {
+		t.Errorf("Expected no skipped steps, got %v", metadata.SkippedSteps)
+	}
+}
+
+func TestParseEnrichmentSteps(t *testing.T) {
+	tests := []struct {
+		spec string
+		want models.EnrichmentOptions
+	}{
+		{"", AllEnrichmentSteps()},
+		{"all", AllEnrichmentSteps()},
+		{"none", models.EnrichmentOptions{}},
+		{"synopsis, tags", models.EnrichmentOptions{Synopsis: true, Tags: true}},
+		{"AI_DETECTION,quality,", models.EnrichmentOptions{AIDetection: true, Quality: true}},
+	}
+	for _, tt := range tests {
+		got, err := ParseEnrichmentSteps(tt.spec)
+		if err != nil {
+			t.Errorf("ParseEnrichmentSteps(%q) returned error: %v", tt.spec, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseEnrichmentSteps(%q) = %+v, want %+v", tt.spec, got, tt.want)
+		}
+	}
+
+	if _, err := ParseEnrichmentSteps("synopsis,summary"); err == nil {
+		t.Error("Expected error for unknown step")
+	}
+}
+
+func TestApplyEnrichmentOverrides(t *testing.T) {
+	base := models.EnrichmentOptions{Synopsis: true, Tags: true, Editorial: true}
+
+	got, err := ApplyEnrichmentOverrides(base, map[string]bool{"editorial": false, "quality": true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := models.EnrichmentOptions{Synopsis: true, Tags: true, Quality: true}
+	if got != want {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+	if skipped := SkippedSteps(got); !reflect.DeepEqual(skipped, []string{StepClean, StepEditorial, StepReferences, StepAIDetection}) {
+		t.Errorf("Unexpected skipped steps %v", skipped)
+	}
+
+	if _, err := ApplyEnrichmentOverrides(base, map[string]bool{"Editorial": false}); err == nil {
+		t.Error("Expected error for unknown step name")
+	}
+	if got, err := ApplyEnrichmentOverrides(base, nil); err != nil || got != base {
+		t.Errorf("Expected base options without overrides, got %+v, %v", got, err)
+	}
+}
+
+// stepResponses are valid Ollama responses for each enrichment step
+var stepResponses = map[string]string{
+	StepSynopsis:    "The council adds bus routes.",
+	StepClean:       "The council adds twelve bus routes.",
+	StepEditorial:   `{"purpose": "informational", "bias": "none", "tone": "neutral", "summary": "Informational reporting on local transit."}`,
+	StepTags:        `["transit", "council"]`,
+	StepReferences:  `[{"text": "twelve new routes", "type": "statistic", "context": "bus network", "confidence": "high"}]`,
+	StepAIDetection: `{"likelihood": "unlikely", "confidence": "high", "reasoning": "Specific local detail.", "human_score": 85}`,
+	StepQuality:     `{"score": 0.8, "reason": "Clear local news.", "categories": ["news"]}`,
+}
+
+// newStepOllamaAnalyzer creates an analyzer backed by a fake Ollama server
+// that answers each step's prompt with its entry in responses. Steps without
+// a response fail with a server error.
+func newStepOllamaAnalyzer(t *testing.T, responses map[string]string) *Analyzer {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Prompt string `json:"prompt"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		for phrase, step := range promptSteps {
+			if response, ok := responses[step]; ok && strings.Contains(req.Prompt, phrase) {
+				json.NewEncoder(w).Encode(map[string]interface{}{"response": response, "done": true})
+				return
+			}
+		}
+		http.Error(w, `{"error":"model unavailable"}`, http.StatusInternalServerError)
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := ollama.New(server.URL, "test-model")
+	if err != nil {
+		t.Fatalf("Failed to create Ollama client: %v", err)
+	}
+	return NewWithOllama(client)
+}
+
+// withStepResponse returns stepResponses with one step's response replaced,
+// or removed when response is empty
+func withStepResponse(step, response string) map[string]string {
+	responses := maps.Clone(stepResponses)
+	if response == "" {
+		delete(responses, step)
+	} else {
+		responses[step] = response
+	}
+	return responses
+}
+
+func TestEnrichmentStatusRecordsStepOutcomes(t *testing.T) {
+	allDone := EnrichmentStatusFor(AllEnrichmentSteps(), StepStatusDone)
+	withStatus := func(statuses map[string]string) map[string]string {
+		want := maps.Clone(allDone)
+		maps.Copy(want, statuses)
+		return want
+	}
+
+	tests := []struct {
+		name      string
+		responses map[string]string
+		want      map[string]string
+		aggregate string
+	}{
+		{"all steps succeed", stepResponses, allDone, EnrichmentStatusDone},
+		{"ollama down", map[string]string{}, map[string]string{
+			StepClean:       StepStatusFailed,
+			StepSynopsis:    StepStatusFallback,
+			StepEditorial:   StepStatusFailed,
+			StepTags:        StepStatusFallback,
+			StepReferences:  StepStatusFallback,
+			StepAIDetection: StepStatusFailed,
+			StepQuality:     StepStatusFallback,
+		}, EnrichmentStatusDegraded},
+		{"editorial fails", withStepResponse(StepEditorial, ""),
+			withStatus(map[string]string{StepEditorial: StepStatusFailed}), EnrichmentStatusDegraded},
+		{"malformed tags", withStepResponse(StepTags, "transit, council"),
+			withStatus(map[string]string{StepTags: StepStatusFallback}), EnrichmentStatusDegraded},
+		{"malformed quality score", withStepResponse(StepQuality, "good"),
+			withStatus(map[string]string{StepQuality: StepStatusFallback}), EnrichmentStatusDegraded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newStepOllamaAnalyzer(t, tt.responses)
+			ctx := context.Background()
+
+			results := map[string]models.Metadata{
+				"AnalyzeWithOptions":     a.AnalyzeWithOptions(ctx, synopsisFixture, AnalysisOptions{}),
+				"AnalyzeWithHTMLContext": a.AnalyzeWithHTMLContext(ctx, synopsisFixture, synopsisFixture, "<p>html</p>", AnalysisOptions{}),
+			}
+			for name, metadata := range results {
+				if !reflect.DeepEqual(metadata.EnrichmentStatus, tt.want) {
+					t.Errorf("%s: expected statuses %v, got %v", name, tt.want, metadata.EnrichmentStatus)
+				}
+				if got := AggregateEnrichmentStatus(metadata.EnrichmentStatus); got != tt.aggregate {
+					t.Errorf("%s: expected aggregate %q, got %q", name, tt.aggregate, got)
+				}
+			}
+		})
+	}
+}
+
+func TestEnrichmentStatusSkippedSteps(t *testing.T) {
+	a := newStepOllamaAnalyzer(t, stepResponses)
+	steps := models.EnrichmentOptions{Synopsis: true, Tags: true}
+
+	metadata := a.AnalyzeWithOptions(context.Background(), synopsisFixture, AnalysisOptions{Enrichment: &steps})
+	want := map[string]string{
+		StepClean:       StepStatusSkippedDisabled,
+		StepSynopsis:    StepStatusDone,
+		StepEditorial:   StepStatusSkippedDisabled,
+		StepTags:        StepStatusDone,
+		StepReferences:  StepStatusSkippedDisabled,
+		StepAIDetection: StepStatusSkippedDisabled,
+		StepQuality:     StepStatusSkippedDisabled,
+	}
+	if !reflect.DeepEqual(metadata.EnrichmentStatus, want) {
+		t.Errorf("Expected statuses %v, got %v", want, metadata.EnrichmentStatus)
+	}
+
+	// Below the threshold no step runs; disabled steps stay skipped_disabled
+	metadata = a.AnalyzeWithOptions(context.Background(), synopsisFixture, AnalysisOptions{Threshold: 1.1, Enrichment: &steps})
+	want[StepSynopsis] = StepStatusSkippedLowQuality
+	want[StepTags] = StepStatusSkippedLowQuality
+	if !reflect.DeepEqual(metadata.EnrichmentStatus, want) {
+		t.Errorf("Expected statuses %v, got %v", want, metadata.EnrichmentStatus)
+	}
+	if got := AggregateEnrichmentStatus(metadata.EnrichmentStatus); got != StepStatusSkippedLowQuality {
+		t.Errorf("Expected aggregate %q, got %q", StepStatusSkippedLowQuality, got)
+	}
+}
+
+func TestEnrichmentStatusWithoutOllama(t *testing.T) {
+	metadata := New().AnalyzeWithOptions(context.Background(), synopsisFixture, AnalysisOptions{})
+
+	want := map[string]string{
+		StepClean:       StepStatusSkippedDisabled,
+		StepSynopsis:    StepStatusSkippedDisabled,
+		StepEditorial:   StepStatusSkippedDisabled,
+		StepTags:        StepStatusFallback,
+		StepReferences:  StepStatusFallback,
+		StepAIDetection: StepStatusSkippedDisabled,
+		StepQuality:     StepStatusFallback,
+	}
+	if !reflect.DeepEqual(metadata.EnrichmentStatus, want) {
+		t.Errorf("Expected statuses %v, got %v", want, metadata.EnrichmentStatus)
+	}
+	if metadata.QualityScore == nil || metadata.QualityScore.AIUsed {
+		t.Errorf("Expected a rule-based quality score, got %+v", metadata.QualityScore)
+	}
+}
+
+func TestAggregateEnrichmentStatus(t *testing.T) {
+	tests := []struct {
+		statuses map[string]string
+		want     string
+	}{
+		{nil, ""},
+		{map[string]string{StepSynopsis: StepStatusDone, StepTags: StepStatusSkippedDisabled}, EnrichmentStatusDone},
+		{map[string]string{StepSynopsis: StepStatusDone, StepEditorial: StepStatusFailed}, EnrichmentStatusDegraded},
+		{map[string]string{StepSynopsis: StepStatusDone, StepTags: StepStatusFallback}, EnrichmentStatusDegraded},
+		{map[string]string{StepSynopsis: StepStatusSkippedLowQuality, StepTags: StepStatusSkippedDisabled}, StepStatusSkippedLowQuality},
+		{map[string]string{StepSynopsis: StepStatusSkippedDisabled}, StepStatusSkippedDisabled},
+	}
+	for _, tt := range tests {
+		if got := AggregateEnrichmentStatus(tt.statuses); got != tt.want {
+			t.Errorf("AggregateEnrichmentStatus(%v) = %q, want %q", tt.statuses, got, tt.want)
+		}
+	}
+}
+
+func TestSummarizeEnrichment(t *testing.T) {
+	tests := []struct {
+		name      string
+		statuses  map[string]string
+		want      EnrichmentResult
+		allFailed bool
+	}{
+		{"none recorded", nil, EnrichmentResult{}, false},
+		{"all done", EnrichmentStatusFor(AllEnrichmentSteps(), StepStatusDone), EnrichmentResult{Succeeded: 7}, false},
+		{"partial", map[string]string{StepSynopsis: StepStatusDone, StepTags: StepStatusFallback, StepEditorial: StepStatusFailed}, EnrichmentResult{Succeeded: 1, Failed: 2}, false},
+		{"ollama down", map[string]string{StepSynopsis: StepStatusFallback, StepClean: StepStatusFailed, StepQuality: StepStatusSkippedDisabled}, EnrichmentResult{Failed: 2}, true},
+		{"low quality", EnrichmentStatusFor(AllEnrichmentSteps(), StepStatusSkippedLowQuality), EnrichmentResult{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := SummarizeEnrichment(tt.statuses)
+			if result != tt.want {
+				t.Errorf("Expected %+v, got %+v", tt.want, result)
+			}
+			if result.AllFailed() != tt.allFailed {
+				t.Errorf("Expected AllFailed %v, got %v", tt.allFailed, result.AllFailed())
+			}
+		})
+	}
+}
+
+// storedOffline returns the offline analysis of text as the worker reads it
+// back from the database
+func storedOffline(t *testing.T, a *Analyzer, text string) *models.Metadata {
+	t.Helper()
+
+	data, err := json.Marshal(a.AnalyzeOffline(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var metadata models.Metadata
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		t.Fatal(err)
+	}
+	return &metadata
+}
+
+func TestEnrichmentReusesOfflineAnalysis(t *testing.T) {
+	analyzers := map[string]*Analyzer{
+		"without ollama":   New(),
+		"all steps":        newStepOllamaAnalyzer(t, stepResponses),
+		"quality fallback": newStepOllamaAnalyzer(t, withStepResponse(StepQuality, "good")),
+	}
+	ctx := context.Background()
+
+	for name, a := range analyzers {
+		for _, text := range []string{synopsisFixture, englishReadabilityFixture, spanishReadabilityFixture} {
+			for _, threshold := range []float64{0, 1.1} {
+				offline := storedOffline(t, a, text)
+				analyses := map[string]func(AnalysisOptions) models.Metadata{
+					"AnalyzeWithOptions": func(opts AnalysisOptions) models.Metadata {
+						return a.AnalyzeWithOptions(ctx, text, opts)
+					},
+					"AnalyzeWithHTMLContext": func(opts AnalysisOptions) models.Metadata {
+						return a.AnalyzeWithHTMLContext(ctx, text, text, "<p>html</p>", opts)
+					},
+				}
+				for method, analyze := range analyses {
+					recomputed, err := json.Marshal(analyze(AnalysisOptions{Threshold: threshold}))
+					if err != nil {
+						t.Fatal(err)
+					}
+					reused, err := json.Marshal(analyze(AnalysisOptions{Threshold: threshold, Offline: offline}))
+					if err != nil {
+						t.Fatal(err)
+					}
+					if string(reused) != string(recomputed) {
+						t.Errorf("%s, %s, threshold %v: expected the same metadata reusing the offline analysis\nrecomputed: %s\nreused:     %s",
+							name, method, threshold, recomputed, reused)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestEnrichmentUsesOfflineQualityScore(t *testing.T) {
+	a := New()
+	ctx := context.Background()
+
+	// The recorded score gates enrichment and stands in for Ollama
+	offline := storedOffline(t, a, synopsisFixture)
+	offline.QualityScore.Score = 0.05
+	metadata := a.AnalyzeWithOptions(ctx, synopsisFixture, AnalysisOptions{Threshold: 0.1, Offline: offline})
+	if metadata.QualityScore == nil || metadata.QualityScore.Score != 0.05 {
+		t.Fatalf("Expected the recorded quality score, got %+v", metadata.QualityScore)
+	}
+	if got := AggregateEnrichmentStatus(metadata.EnrichmentStatus); got != StepStatusSkippedLowQuality {
+		t.Errorf("Expected enrichment skipped on the recorded score, got %q", got)
+	}
+
+	// Offline results recorded for other text, without a rule-based quality
+	// score, or replaced by an earlier enrichment, are recomputed
+	want := a.AnalyzeWithOptions(ctx, synopsisFixture, AnalysisOptions{})
+	aiScored := storedOffline(t, a, synopsisFixture)
+	aiScored.QualityScore.AIUsed = true
+	aiScored.QualityScore.Score = 0.99
+	enriched := storedOffline(t, a, synopsisFixture)
+	enriched.QualityScore.Score = 0.99
+	enrichedAt := time.Now()
+	enriched.EnrichedAt = &enrichedAt
+	for name, offline := range map[string]*models.Metadata{
+		"other text": storedOffline(t, a, englishReadabilityFixture),
+		"AI score":   aiScored,
+		"enriched":   enriched,
+	} {
+		metadata := a.AnalyzeWithOptions(ctx, synopsisFixture, AnalysisOptions{Offline: offline})
+		if metadata.WordCount != want.WordCount || !reflect.DeepEqual(metadata.QualityScore, want.QualityScore) {
+			t.Errorf("%s: expected the offline analysis ignored, got %d words and %+v", name, metadata.WordCount, metadata.QualityScore)
+		}
+	}
+}
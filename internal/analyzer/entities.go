@@ -0,0 +1,497 @@
+package analyzer
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/docutag/textanalyzer/internal/models"
+)
+
+// entityTypes are the types entities are classified into
+var entityTypes = []string{models.EntityPerson, models.EntityOrganization, models.EntityLocation, models.EntityOther}
+
+// ValidateEntityType checks that entityType is one of the types named
+// entities are classified into
+func ValidateEntityType(entityType string) error {
+	if !slices.Contains(entityTypes, entityType) {
+		return fmt.Errorf("type must be one of %s", strings.Join(entityTypes, ", "))
+	}
+	return nil
+}
+
+// entityStopWords are the function words that are only capitalized because
+// they start a sentence or a title, never part of a name
+var entityStopWords = DefaultStopWords()
+
+// nameParticles are lowercase words that join the capitalized words on
+// either side into a person's name, as in "Ludwig van Beethoven" or "Maria
+// de la Cruz"
+var nameParticles = map[string]bool{
+	"van": true, "von": true, "der": true, "den": true, "de": true, "del": true, "della": true,
+	"di": true, "da": true, "du": true, "des": true, "la": true, "le": true, "dos": true, "das": true,
+	"bin": true, "ibn": true, "al": true, "ter": true,
+}
+
+// honorifics are titles before a person's name. They are left out of the
+// entity, and a period after them does not end the sentence.
+var honorifics = map[string]bool{
+	"Mr": true, "Mrs": true, "Ms": true, "Miss": true, "Dr": true, "Prof": true, "Professor": true,
+	"Sir": true, "Dame": true, "Lord": true, "Lady": true, "Rev": true, "Fr": true,
+	"President": true, "Vice": true, "Chancellor": true, "Premier": true, "Minister": true,
+	"Senator": true, "Sen": true, "Rep": true, "Representative": true, "Congressman": true,
+	"Congresswoman": true, "Governor": true, "Gov": true, "Mayor": true, "Judge": true, "Justice": true,
+	"General": true, "Gen": true, "Colonel": true, "Col": true, "Captain": true, "Capt": true,
+	"Lt": true, "Sgt": true, "King": true, "Queen": true, "Prince": true, "Princess": true, "Pope": true,
+}
+
+// organizationWords mark the names they appear in as organizations
+var organizationWords = map[string]bool{
+	"Inc": true, "Corp": true, "Corporation": true, "Ltd": true, "LLC": true, "PLC": true, "Co": true,
+	"Company": true, "Group": true, "Holdings": true, "Bank": true, "University": true, "College": true,
+	"Institute": true, "Association": true, "Agency": true, "Foundation": true, "Ministry": true,
+	"Department": true, "Council": true, "Committee": true, "Commission": true, "Party": true,
+	"Union": true, "Airlines": true, "Motors": true, "Technologies": true, "Systems": true,
+	"Partners": true, "Capital": true, "Times": true, "News": true, "Post": true, "Journal": true,
+	"Press": true, "Reserve": true, "Court": true, "Parliament": true, "Congress": true, "Senate": true,
+	"Police": true, "Army": true, "Navy": true, "Club": true, "Society": true, "Federation": true,
+	"Organization": true, "Organisation": true, "Authority": true, "Bureau": true, "Office": true,
+	"Laboratories": true, "Labs": true, "Pharmaceuticals": true, "Industries": true, "Enterprises": true,
+}
+
+// placeWords end the names of places, as in "Orange County" or "Hudson River"
+var placeWords = map[string]bool{
+	"City": true, "County": true, "State": true, "Province": true, "River": true, "Lake": true,
+	"Mountain": true, "Mountains": true, "Mount": true, "Island": true, "Islands": true, "Valley": true,
+	"Bay": true, "Sea": true, "Ocean": true, "Street": true, "Avenue": true, "Road": true, "Square": true,
+	"Park": true, "Beach": true, "Coast": true, "Desert": true, "Peninsula": true, "Canyon": true,
+	"Gulf": true, "Isle": true, "Strait": true, "Cape": true, "Republic": true, "Kingdom": true,
+}
+
+// places are countries, regions and cities recognized as locations by name
+var places = map[string]bool{
+	// Continents and regions
+	"Africa": true, "Asia": true, "Europe": true, "America": true, "North America": true,
+	"South America": true, "Latin America": true, "Antarctica": true, "Australia": true,
+	"Middle East": true, "Scandinavia": true, "Caribbean": true, "Arctic": true, "Balkans": true,
+	"Siberia": true, "Sahara": true,
+
+	// Countries
+	"Afghanistan": true, "Algeria": true, "Argentina": true, "Austria": true, "Bangladesh": true,
+	"Belgium": true, "Bolivia": true, "Brazil": true, "Britain": true, "Great Britain": true,
+	"Bulgaria": true, "Cambodia": true, "Canada": true, "Chile": true, "China": true, "Colombia": true,
+	"Croatia": true, "Cuba": true, "Czech Republic": true, "Denmark": true, "Ecuador": true,
+	"Egypt": true, "England": true, "Estonia": true, "Ethiopia": true, "Finland": true, "France": true,
+	"Germany": true, "Ghana": true, "Greece": true, "Hungary": true, "Iceland": true, "India": true,
+	"Indonesia": true, "Iran": true, "Iraq": true, "Ireland": true, "Israel": true, "Italy": true,
+	"Jamaica": true, "Japan": true, "Jordan": true, "Kenya": true, "Korea": true, "North Korea": true,
+	"South Korea": true, "Kuwait": true, "Latvia": true, "Lebanon": true, "Libya": true,
+	"Lithuania": true, "Luxembourg": true, "Malaysia": true, "Mexico": true, "Morocco": true,
+	"Nepal": true, "Netherlands": true, "New Zealand": true, "Nigeria": true, "Norway": true,
+	"Pakistan": true, "Palestine": true, "Peru": true, "Philippines": true, "Poland": true,
+	"Portugal": true, "Qatar": true, "Romania": true, "Russia": true, "Saudi Arabia": true,
+	"Scotland": true, "Serbia": true, "Singapore": true, "Slovakia": true, "Slovenia": true,
+	"Somalia": true, "South Africa": true, "Spain": true, "Sri Lanka": true, "Sudan": true,
+	"Sweden": true, "Switzerland": true, "Syria": true, "Taiwan": true, "Tanzania": true,
+	"Thailand": true, "Tunisia": true, "Turkey": true, "Uganda": true, "Ukraine": true,
+	"United Arab Emirates": true, "United Kingdom": true, "United States": true, "Uruguay": true,
+	"Venezuela": true, "Vietnam": true, "Wales": true, "Yemen": true, "Zimbabwe": true,
+	"US": true, "USA": true, "UK": true, "UAE": true, "U.S.": true, "U.K.": true,
+
+	// US states
+	"Alabama": true, "Alaska": true, "Arizona": true, "Arkansas": true, "California": true,
+	"Colorado": true, "Connecticut": true, "Delaware": true, "Florida": true, "Georgia": true,
+	"Hawaii": true, "Idaho": true, "Illinois": true, "Indiana": true, "Iowa": true, "Kansas": true,
+	"Kentucky": true, "Louisiana": true, "Maine": true, "Maryland": true, "Massachusetts": true,
+	"Michigan": true, "Minnesota": true, "Mississippi": true, "Missouri": true, "Montana": true,
+	"Nebraska": true, "Nevada": true, "New Hampshire": true, "New Jersey": true, "New Mexico": true,
+	"New York": true, "North Carolina": true, "North Dakota": true, "Ohio": true, "Oklahoma": true,
+	"Oregon": true, "Pennsylvania": true, "Rhode Island": true, "South Carolina": true,
+	"South Dakota": true, "Tennessee": true, "Texas": true, "Utah": true, "Vermont": true,
+	"Virginia": true, "Washington": true, "West Virginia": true, "Wisconsin": true, "Wyoming": true,
+
+	// Cities
+	"Amsterdam": true, "Athens": true, "Atlanta": true, "Baghdad": true, "Bangkok": true,
+	"Barcelona": true, "Beijing": true, "Beirut": true, "Berlin": true, "Boston": true,
+	"Brussels": true, "Budapest": true, "Buenos Aires": true, "Cairo": true, "Chicago": true,
+	"Copenhagen": true, "Dallas": true, "Delhi": true, "New Delhi": true, "Denver": true,
+	"Detroit": true, "Dubai": true, "Dublin": true, "Edinburgh": true, "Frankfurt": true,
+	"Geneva": true, "Hamburg": true, "Helsinki": true, "Hong Kong": true, "Houston": true,
+	"Istanbul": true, "Jakarta": true, "Jerusalem": true, "Johannesburg": true, "Kabul": true,
+	"Kyiv": true, "Lagos": true, "Lisbon": true, "London": true, "Los Angeles": true, "Madrid": true,
+	"Manchester": true, "Manila": true, "Melbourne": true, "Miami": true, "Milan": true,
+	"Montreal": true, "Moscow": true, "Mumbai": true, "Munich": true, "Nairobi": true,
+	"New Orleans": true, "Oslo": true, "Ottawa": true, "Paris": true, "Philadelphia": true,
+	"Phoenix": true, "Prague": true, "Rome": true, "San Francisco": true, "Santiago": true,
+	"Seattle": true, "Seoul": true, "Shanghai": true, "Stockholm": true, "Sydney": true,
+	"Tehran": true, "Tel Aviv": true, "Tokyo": true, "Toronto": true, "Vancouver": true,
+	"Vienna": true, "Warsaw": true, "Zurich": true, "Zürich": true,
+}
+
+// givenNames are common first names, marking the names they start as people
+var givenNames = map[string]bool{
+	"James": true, "John": true, "Robert": true, "Michael": true, "William": true, "David": true,
+	"Richard": true, "Joseph": true, "Thomas": true, "Charles": true, "Christopher": true,
+	"Daniel": true, "Matthew": true, "Anthony": true, "Mark": true, "Donald": true, "Steven": true,
+	"Paul": true, "Andrew": true, "Joshua": true, "Kevin": true, "Brian": true, "George": true,
+	"Edward": true, "Ronald": true, "Timothy": true, "Jason": true, "Jeffrey": true, "Ryan": true,
+	"Jacob": true, "Gary": true, "Eric": true, "Stephen": true, "Peter": true, "Jonathan": true,
+	"Samuel": true, "Benjamin": true, "Henry": true, "Alexander": true, "Patrick": true, "Jack": true,
+	"Mary": true, "Patricia": true, "Jennifer": true, "Linda": true, "Elizabeth": true,
+	"Barbara": true, "Susan": true, "Jessica": true, "Sarah": true, "Karen": true, "Nancy": true,
+	"Lisa": true, "Margaret": true, "Sandra": true, "Ashley": true, "Emily": true, "Donna": true,
+	"Michelle": true, "Carol": true, "Amanda": true, "Melissa": true, "Deborah": true,
+	"Stephanie": true, "Rebecca": true, "Laura": true, "Helen": true, "Anna": true, "Anne": true,
+	"Emma": true, "Olivia": true, "Sophie": true, "Rachel": true, "Catherine": true, "Julia": true,
+	"Maria": true, "Jane": true, "Alice": true, "Angela": true, "Hillary": true, "Kamala": true,
+	"Joe": true, "Barack": true, "Vladimir": true, "Emmanuel": true, "Boris": true, "Justin": true,
+	"Xi": true, "Narendra": true, "Olaf": true, "Giorgia": true, "Pedro": true, "Carlos": true,
+	"Juan": true, "José": true, "Luis": true, "Jean": true, "Pierre": true, "Marie": true,
+	"Hans": true, "Klaus": true,
+}
+
+// locationPrepositions come before places, as in "in Paris"
+var locationPrepositions = map[string]bool{
+	"in": true, "near": true, "across": true, "outside": true, "throughout": true, "around": true,
+	"toward": true, "towards": true,
+}
+
+// calendarNames are capitalized words of dates, left to PotentialDates
+var calendarNames = map[string]bool{
+	"Monday": true, "Tuesday": true, "Wednesday": true, "Thursday": true, "Friday": true,
+	"Saturday": true, "Sunday": true, "January": true, "February": true, "March": true,
+	"April": true, "June": true, "July": true, "August": true, "September": true, "October": true,
+	"November": true, "December": true,
+}
+
+// entityTokenKind is the shape of a word that decides whether it can be
+// part of a name
+type entityTokenKind int
+
+const (
+	plainToken   entityTokenKind = iota // Lowercase words, numbers and lone capitals
+	capitalToken                        // Capitalized words: John, McDonald, O'Brien
+	acronymToken                        // Two to six capitals: NASA, WHO
+	initialToken                        // A capital and a period: the F. of John F. Kennedy
+)
+
+// entityToken is a word of the text and its context
+type entityToken struct {
+	word          string // Without a possessive 's
+	start, end    int    // Byte span of word in the text
+	kind          entityTokenKind
+	sentenceStart bool // First word of a sentence, line or the text
+	joined        bool // Separated from the previous word by spaces alone
+}
+
+// entityTokens splits text into words, marking the words that start a
+// sentence and those joined to the previous word by spaces alone. Periods
+// after honorifics and initials neither end the sentence nor break the join,
+// and periods after other abbreviations only break the join.
+func entityTokens(text string) []entityToken {
+	var tokens []entityToken
+	sentenceStart, joined := true, false
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			switch {
+			case r == '.':
+				if len(tokens) == 0 || tokens[len(tokens)-1].end != i {
+					sentenceStart, joined = true, false
+					break
+				}
+				prev := tokens[len(tokens)-1]
+				if prev.kind != initialToken && !honorifics[prev.word] {
+					sentenceStart = !abbreviations[strings.ToLower(prev.word)]
+					joined = false
+				}
+			case r == '!' || r == '?' || r == '\n':
+				sentenceStart, joined = true, false
+			case !unicode.IsSpace(r):
+				joined = false
+			}
+			i += size
+			continue
+		}
+
+		start := i
+		for i < len(text) {
+			r, size := utf8.DecodeRuneInString(text[i:])
+			if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) {
+				i += size
+				continue
+			}
+			if r == '\'' || r == '’' || r == '-' {
+				if next, _ := utf8.DecodeRuneInString(text[i+size:]); unicode.IsLetter(next) {
+					i += size
+					continue
+				}
+			}
+			break
+		}
+
+		word := text[start:i]
+		for _, possessive := range []string{"'s", "’s"} {
+			if trimmed, ok := strings.CutSuffix(word, possessive); ok && trimmed != "" {
+				word = trimmed
+				break
+			}
+		}
+		tokens = append(tokens, entityToken{
+			word:          word,
+			start:         start,
+			end:           start + len(word),
+			kind:          entityTokenKindOf(word, i < len(text) && text[i] == '.'),
+			sentenceStart: sentenceStart,
+			joined:        joined,
+		})
+		sentenceStart, joined = false, true
+	}
+	return tokens
+}
+
+// entityTokenKindOf classifies word, followed by a period when period is set
+func entityTokenKindOf(word string, period bool) entityTokenKind {
+	first, _ := utf8.DecodeRuneInString(word)
+	if !unicode.IsUpper(first) {
+		return plainToken
+	}
+
+	letters, lower := 0, false
+	for _, r := range word {
+		if unicode.IsLetter(r) {
+			letters++
+			lower = lower || unicode.IsLower(r)
+		}
+	}
+	switch {
+	case lower:
+		return capitalToken
+	case letters == 1 && period:
+		return initialToken
+	case letters >= 2 && letters <= 6:
+		return acronymToken
+	}
+	return plainToken
+}
+
+// entityVotes tally the evidence for each type of an entity, indexed like
+// entityTypes
+type entityVotes [4]int
+
+// Indexes into entityVotes
+const (
+	personVote = iota
+	organizationVote
+	locationVote
+)
+
+// entityMention is one occurrence of a name and the evidence for its type
+type entityMention struct {
+	text  string
+	votes entityVotes
+}
+
+// extractEntities finds the names in text: runs of capitalized words and
+// acronyms, joined by particles such as "van" and "of". A capitalized word
+// that starts a sentence is only taken for a name when it also appears
+// capitalized mid-sentence, or starts a longer run and never appears in
+// lowercase. Names are classified from honorifics, company and place words,
+// common first and place names and a preceding "in", and surnames of people
+// named in full are people too. Entities are ordered by count, then
+// alphabetically.
+func extractEntities(text string) []models.NamedEntity {
+	tokens := entityTokens(text)
+
+	midSentence := make(map[string]bool)
+	lowercase := make(map[string]bool)
+	for _, token := range tokens {
+		switch {
+		case token.kind == plainToken:
+			lowercase[token.word] = true
+		case !token.sentenceStart:
+			midSentence[token.word] = true
+		}
+	}
+
+	counts := make(map[string]int)
+	votes := make(map[string]entityVotes)
+	for i := 0; i < len(tokens); {
+		if tokens[i].kind == plainToken {
+			i++
+			continue
+		}
+		j := i + 1
+		for j < len(tokens) && tokens[j].joined {
+			if startsTitledName(tokens, j) {
+				break
+			} else if tokens[j].kind != plainToken {
+				j++
+			} else if next := particlesEnd(tokens, j); next > j {
+				j = next
+			} else {
+				break
+			}
+		}
+
+		var prev string
+		if i > 0 && tokens[i].joined {
+			prev = strings.ToLower(tokens[i-1].word)
+		}
+		if mention, ok := entityMentionOf(text, tokens[i:j], prev, midSentence, lowercase); ok {
+			counts[mention.text]++
+			v := votes[mention.text]
+			for t := range v {
+				v[t] += mention.votes[t]
+			}
+			votes[mention.text] = v
+		}
+		i = j
+	}
+
+	entities := make([]models.NamedEntity, 0, len(counts))
+	surnames := make(map[string]bool)
+	for name, count := range counts {
+		entityType := entityTypeOf(votes[name])
+		entities = append(entities, models.NamedEntity{Text: name, Type: entityType, Count: count})
+		if entityType == models.EntityPerson && strings.Contains(name, " ") {
+			surnames[name[strings.LastIndex(name, " ")+1:]] = true
+		}
+	}
+	for i := range entities {
+		if entities[i].Type == models.EntityOther && surnames[entities[i].Text] {
+			entities[i].Type = models.EntityPerson
+		}
+	}
+
+	slices.SortFunc(entities, func(a, b models.NamedEntity) int {
+		if a.Count != b.Count {
+			return b.Count - a.Count
+		}
+		return cmp.Compare(a.Text, b.Text)
+	})
+	return entities
+}
+
+// particlesEnd returns the index of the capitalized word after the particles
+// starting at tokens[i], or i when tokens[i] does not join two words into a
+// name. "of" only follows the words of organizations and places, as in "Bank
+// of England", so that "Olaf Scholz of Germany" stays two names.
+func particlesEnd(tokens []entityToken, i int) int {
+	j := i
+	if tokens[j].word == "of" && (organizationWords[tokens[j-1].word] || placeWords[tokens[j-1].word]) {
+		j++
+	} else {
+		for j < len(tokens) && tokens[j].joined && nameParticles[tokens[j].word] {
+			j++
+		}
+	}
+	if j == i || j >= len(tokens) || !tokens[j].joined || tokens[j].kind != capitalToken {
+		return i
+	}
+	return j + 1
+}
+
+// startsTitledName reports whether tokens[i] is an honorific before a name
+// that follows another name rather than a first or middle name, as in
+// "U.S. President Joe Biden" but not "Martin Luther King"
+func startsTitledName(tokens []entityToken, i int) bool {
+	return honorifics[tokens[i].word] && tokens[i-1].kind != capitalToken &&
+		i+1 < len(tokens) && tokens[i+1].joined && tokens[i+1].kind == capitalToken
+}
+
+// entityMentionOf turns a run of capitalized words into a mention, dropping
+// the honorifics before a name and the capitalized words that only start a
+// sentence or a title. prev is the lowercased word before the run, if joined
+// to it. It reports false when no name is left.
+func entityMentionOf(text string, run []entityToken, prev string, midSentence, lowercase map[string]bool) (entityMention, bool) {
+	var mention entityMention
+	for len(run) > 0 && run[0].kind == capitalToken && honorifics[run[0].word] {
+		mention.votes[personVote] += 3
+		run = run[1:]
+	}
+	for len(run) > 0 && run[0].kind == capitalToken {
+		first := run[0]
+		lower := strings.ToLower(first.word)
+		sentenceWord := first.sentenceStart && (lowercase[lower] || len(run) == 1 && !midSentence[first.word])
+		if !entityStopWords[lower] && !sentenceWord {
+			break
+		}
+		run = run[1:]
+		if len(run) > 0 && run[0].kind == plainToken {
+			// A particle left leading the run
+			run = run[1:]
+		}
+	}
+	for len(run) > 0 && run[len(run)-1].kind == capitalToken && entityStopWords[strings.ToLower(run[len(run)-1].word)] {
+		// A sentence after an initial, as in "the U.S. The"
+		run = run[:len(run)-1]
+	}
+	if len(run) == 0 {
+		return mention, false
+	}
+
+	first, last := run[0], run[len(run)-1]
+	switch {
+	case len(run) == 1 && calendarNames[first.word]:
+		return mention, false
+	case len(run) == 1 && first.kind == capitalToken && len(first.word) < 3:
+		return mention, false
+	case len(run) == 1 && first.kind == initialToken:
+		return mention, false
+	}
+
+	mention.text = strings.Join(strings.Fields(text[first.start:last.end]), " ")
+	if last.kind == initialToken {
+		mention.text += "."
+	}
+
+	for _, token := range run {
+		if organizationWords[token.word] {
+			mention.votes[organizationVote] += 3
+		}
+		if nameParticles[token.word] {
+			mention.votes[personVote] += 2
+		}
+	}
+	if len(run) == 1 && first.kind == acronymToken {
+		mention.votes[organizationVote]++
+	}
+	if places[mention.text] {
+		mention.votes[locationVote] += 3
+	} else if len(run) > 1 && placeWords[last.word] {
+		mention.votes[locationVote] += 2
+	}
+	if locationPrepositions[prev] {
+		mention.votes[locationVote]++
+	}
+	if len(run) > 1 && givenNames[first.word] {
+		mention.votes[personVote] += 2
+	}
+	return mention, true
+}
+
+// entityTypeOf returns the type with the most votes: organizations win ties
+// over places and places over people. Entities without evidence are other.
+func entityTypeOf(votes entityVotes) string {
+	best, bestVotes := models.EntityOther, 0
+	for _, t := range []int{organizationVote, locationVote, personVote} {
+		if votes[t] > bestVotes {
+			best, bestVotes = entityTypes[t], votes[t]
+		}
+	}
+	return best
+}
+
+// entityNames returns the names of entities in alphabetical (byte-wise) order
+func entityNames(entities []models.NamedEntity) []string {
+	names := make([]string, 0, len(entities))
+	for _, entity := range entities {
+		names = append(names, entity.Text)
+	}
+	slices.Sort(names)
+	return names
+}
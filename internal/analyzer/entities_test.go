@@ -0,0 +1,121 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/docutag/textanalyzer/internal/models"
+)
+
+const newswireFixture = `WASHINGTON (Reuters) - U.S. President Joe Biden met Chancellor Olaf Scholz of Germany at the White House on Tuesday, the two leaders said in a joint statement.
+
+The talks in Washington focused on aid to Ukraine. Biden said NASA and the European Space Agency would expand their partnership, and Scholz praised the WHO for its work in Africa.
+
+Shares of Acme Corp. rose 4% after Mr. Tom McDonald, its chief executive, told investors in New York that the Bank of England had approved the deal. McDonald's remarks were echoed by Ludwig van Dam, an analyst at Goldman Sachs Group.
+
+However, critics in Brussels were unconvinced. "The plan is vague," said Dr. Maria de la Cruz of the University of Lisbon.`
+
+func TestExtractEntitiesNewswire(t *testing.T) {
+	types := make(map[string]string)
+	for _, entity := range extractEntities(newswireFixture) {
+		types[entity.Text] = entity.Type
+	}
+
+	want := map[string]string{
+		// Honorifics, first names and particles
+		"Joe Biden":        models.EntityPerson,
+		"Olaf Scholz":      models.EntityPerson,
+		"Tom McDonald":     models.EntityPerson,
+		"Maria de la Cruz": models.EntityPerson,
+		"Ludwig van Dam":   models.EntityPerson,
+		// Surnames of people named in full, possessives dropped
+		"Biden":    models.EntityPerson,
+		"Scholz":   models.EntityPerson,
+		"McDonald": models.EntityPerson,
+		// Company words and acronyms
+		"Acme Corp":             models.EntityOrganization,
+		"Bank of England":       models.EntityOrganization,
+		"European Space Agency": models.EntityOrganization,
+		"Goldman Sachs Group":   models.EntityOrganization,
+		"University of Lisbon":  models.EntityOrganization,
+		"NASA":                  models.EntityOrganization,
+		"WHO":                   models.EntityOrganization,
+		// Place names
+		"U.S.":       models.EntityLocation,
+		"Germany":    models.EntityLocation,
+		"Washington": models.EntityLocation,
+		"Ukraine":    models.EntityLocation,
+		"Africa":     models.EntityLocation,
+		"New York":   models.EntityLocation,
+		"Brussels":   models.EntityLocation,
+		// No evidence either way
+		"White House": models.EntityOther,
+		"Reuters":     models.EntityOther,
+	}
+	for text, entityType := range want {
+		if got, ok := types[text]; !ok {
+			t.Errorf("Expected entity %q, got %v", text, types)
+		} else if got != entityType {
+			t.Errorf("Expected %q to be a %s, got %s", text, entityType, got)
+		}
+	}
+
+	for _, text := range []string{
+		"The", "However", "Shares", "Tuesday", "WASHINGTON", "President", "Mr", "Dr",
+		"Olaf Scholz of Germany", "U.S. President Joe Biden", "Shares of Acme Corp", "McDonald's",
+	} {
+		if _, ok := types[text]; ok {
+			t.Errorf("Expected no entity %q", text)
+		}
+	}
+}
+
+func TestExtractEntitiesSentenceStart(t *testing.T) {
+	text := "Paris is crowded in summer. Visitors queue for hours. Many hotels in Paris are full. Lyon is quieter."
+	got := extractEntities(text)
+
+	// "Paris" also appears mid-sentence, so both occurrences count; "Lyon",
+	// "Visitors" and "Many" only start sentences
+	want := []models.NamedEntity{{Text: "Paris", Type: models.EntityLocation, Count: 2}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestExtractEntitiesOrder(t *testing.T) {
+	text := "Officials from the WHO met Oxfam staff. The WHO and UNICEF agreed, and the WHO and UNICEF will report to Oxfam."
+	got := extractEntities(text)
+
+	want := []models.NamedEntity{
+		{Text: "WHO", Type: models.EntityOrganization, Count: 3},
+		{Text: "Oxfam", Type: models.EntityOther, Count: 2},
+		{Text: "UNICEF", Type: models.EntityOrganization, Count: 2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected entities by count, then alphabetically: %v, got %v", want, got)
+	}
+}
+
+func TestEntitiesInMetadata(t *testing.T) {
+	metadata := New().AnalyzeOffline(newswireFixture)
+
+	if len(metadata.Entities) == 0 {
+		t.Fatal("Expected entities in the metadata")
+	}
+	if !reflect.DeepEqual(metadata.NamedEntities, entityNames(metadata.Entities)) {
+		t.Errorf("Expected NamedEntities to list the entities' names, got %v", metadata.NamedEntities)
+	}
+}
+
+func TestValidateEntityType(t *testing.T) {
+	for _, entityType := range []string{"person", "organization", "location", "other"} {
+		if err := ValidateEntityType(entityType); err != nil {
+			t.Errorf("ValidateEntityType(%q) = %v, want nil", entityType, err)
+		}
+	}
+	for _, entityType := range []string{"", "Person", "place"} {
+		if err := ValidateEntityType(entityType); err == nil {
+			t.Errorf("ValidateEntityType(%q) = nil, want an error", entityType)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package analyzer
+
+import (
+	"container/heap"
+
+	"github.com/docutag/textanalyzer/internal/models"
+)
+
+// Default caps on distinct entries tracked while counting frequencies
+const (
+	DefaultMaxTrackedWords   = 200000
+	DefaultMaxTrackedPhrases = 500000
+)
+
+// SetFrequencyLimits caps the distinct words and phrases tracked while
+// counting frequencies, bounding memory for inputs with millions of unique
+// tokens. Documents under the caps are counted exactly. Values of zero or
+// less restore DefaultMaxTrackedWords and DefaultMaxTrackedPhrases.
+func (a *Analyzer) SetFrequencyLimits(words, phrases int) {
+	a.maxTrackedWords = words
+	a.maxTrackedPhrases = phrases
+}
+
+// wordLimit returns the cap on distinct words tracked
+func (a *Analyzer) wordLimit() int {
+	if a.maxTrackedWords <= 0 {
+		return DefaultMaxTrackedWords
+	}
+	return a.maxTrackedWords
+}
+
+// phraseLimit returns the cap on distinct phrases tracked
+func (a *Analyzer) phraseLimit() int {
+	if a.maxTrackedPhrases <= 0 {
+		return DefaultMaxTrackedPhrases
+	}
+	return a.maxTrackedPhrases
+}
+
+// applyFrequencies sets top words, unique words and top phrases, recording
+// in FrequencyTruncation which counts hit their cap
+func (a *Analyzer) applyFrequencies(metadata *models.Metadata, text string, words []string) {
+	var topTruncated, uniqueTruncated, phrasesTruncated bool
+	metadata.TopWords, topTruncated = a.topWords(words, 20)
+	metadata.UniqueWords, uniqueTruncated = a.countUniqueWords(words)
+	metadata.TopPhrases, phrasesTruncated = a.topPhrases(text, 10)
+
+	if topTruncated || uniqueTruncated || phrasesTruncated {
+		metadata.FrequencyTruncation = &models.FrequencyTruncation{
+			Words:   topTruncated || uniqueTruncated,
+			Phrases: phrasesTruncated,
+		}
+	}
+}
+
+// frequencyCounter counts occurrences of at most limit distinct keys using
+// the Space-Saving algorithm. At the cap, a new key replaces the least
+// counted entry and inherits its count as error, so any key occurring more
+// than total/limit times is kept. Entries are reported with their guaranteed
+// count (count minus error), which is exact for keys tracked since their
+// first occurrence.
+type frequencyCounter struct {
+	entries   map[string]*counterEntry
+	least     counterHeap
+	limit     int
+	truncated bool // Entries were replaced; rare keys are missing from the counts
+}
+
+// counterEntry is a counted key, the overestimate it inherited and its
+// position in the heap
+type counterEntry struct {
+	key   string
+	count int
+	err   int
+	pos   int
+}
+
+func newFrequencyCounter(limit int) *frequencyCounter {
+	return &frequencyCounter{entries: make(map[string]*counterEntry), limit: limit}
+}
+
+// add counts one occurrence of key
+func (c *frequencyCounter) add(key string) {
+	if entry, ok := c.entries[key]; ok {
+		entry.count++
+		heap.Fix(&c.least, entry.pos)
+		return
+	}
+
+	if len(c.least) < c.limit {
+		entry := &counterEntry{key: key, count: 1}
+		c.entries[key] = entry
+		heap.Push(&c.least, entry)
+		return
+	}
+
+	// Reuse the least counted entry for the new key
+	entry := c.least[0]
+	delete(c.entries, entry.key)
+	entry.key, entry.err = key, entry.count
+	entry.count++
+	c.entries[key] = entry
+	heap.Fix(&c.least, 0)
+	c.truncated = true
+}
+
+// ranked returns the counted keys as ranked items scored by their guaranteed
+// count, keeping those counted at least minCount times
+func (c *frequencyCounter) ranked(minCount int) []rankedItem {
+	items := make([]rankedItem, 0, len(c.least))
+	for _, entry := range c.least {
+		if count := entry.count - entry.err; count >= minCount {
+			items = append(items, rankedItem{entry.key, count})
+		}
+	}
+	return items
+}
+
+// counterHeap is a min-heap of entries by count
+type counterHeap []*counterEntry
+
+func (h counterHeap) Len() int           { return len(h) }
+func (h counterHeap) Less(i, j int) bool { return h[i].count < h[j].count }
+
+func (h counterHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].pos = i
+	h[j].pos = j
+}
+
+func (h *counterHeap) Push(x any) {
+	entry := x.(*counterEntry)
+	entry.pos = len(*h)
+	*h = append(*h, entry)
+}
+
+func (h *counterHeap) Pop() any {
+	old := *h
+	entry := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return entry
+}
+
+// distinctCounter counts distinct strings using at most limit entries. Below
+// the cap every string's hash is kept and the count is exact. At the cap it
+// switches to adaptive sampling: only hashes whose low level bits are zero
+// are kept, and the count is estimated as the kept hashes times 2^level.
+type distinctCounter struct {
+	seen  map[uint64]struct{}
+	limit int
+	level uint
+}
+
+func newDistinctCounter(limit int) *distinctCounter {
+	return &distinctCounter{seen: make(map[uint64]struct{}), limit: limit}
+}
+
+// add records one occurrence of s
+func (c *distinctCounter) add(s string) {
+	h := hashString(s)
+	if h&(1<<c.level-1) != 0 {
+		return
+	}
+	c.seen[h] = struct{}{}
+
+	for len(c.seen) > c.limit {
+		c.level++
+		for kept := range c.seen {
+			if kept&(1<<c.level-1) != 0 {
+				delete(c.seen, kept)
+			}
+		}
+	}
+}
+
+// count returns the number of distinct strings, estimated once sampling began
+func (c *distinctCounter) count() int {
+	return len(c.seen) << c.level
+}
+
+// truncated reports whether the count is an estimate
+func (c *distinctCounter) truncated() bool {
+	return c.level > 0
+}
+
+// hashString returns the 64-bit FNV-1a hash of s, finalized so that its low
+// bits, which decide sampling, are well mixed
+func hashString(s string) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= 1099511628211
+	}
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	return h
+}
@@ -0,0 +1,147 @@
+package analyzer
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// uniqueTokens returns n distinct tokens such as "tok1a2", sharing one
+// backing string so that building them does not dominate allocations
+func uniqueTokens(n int) []string {
+	var buf []byte
+	ends := make([]int, n)
+	for i := range n {
+		buf = append(buf, "tok"...)
+		buf = strconv.AppendInt(buf, int64(i), 36)
+		ends[i] = len(buf)
+	}
+
+	all := string(buf)
+	tokens := make([]string, n)
+	start := 0
+	for i, end := range ends {
+		tokens[i] = all[start:end]
+		start = end
+	}
+	return tokens
+}
+
+// mixedTokens interleaves unique tokens with known words, each occurring
+// its count of times spread evenly from the first token
+func mixedTokens(unique int, counts map[string]int) []string {
+	tokens := uniqueTokens(unique)
+	mixed := make([]string, 0, unique*2)
+	for i, token := range tokens {
+		mixed = append(mixed, token)
+		for word, count := range counts {
+			if i%(unique/count) == 0 && i/(unique/count) < count {
+				mixed = append(mixed, word)
+			}
+		}
+	}
+	return mixed
+}
+
+// allocatedBytes returns the bytes allocated while running f
+func allocatedBytes(f func()) uint64 {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	f()
+	runtime.ReadMemStats(&after)
+	return after.TotalAlloc - before.TotalAlloc
+}
+
+func TestFrequencyCountsBoundedForUniqueTokens(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping 5 million token input in short mode")
+	}
+
+	words := uniqueTokens(5000000)
+	a := New()
+
+	// Unbounded maps of 5 million entries allocate over 1 GB
+	const ceiling = 128 << 20
+	var top []string
+	var topTruncated, uniqueTruncated bool
+	var unique int
+	allocated := allocatedBytes(func() {
+		frequencies, truncated := a.topWords(words, 20)
+		for _, f := range frequencies {
+			top = append(top, f.Word)
+		}
+		topTruncated = truncated
+		unique, uniqueTruncated = a.countUniqueWords(words)
+	})
+	if allocated > ceiling {
+		t.Errorf("Expected at most %d MB allocated, got %d MB", ceiling>>20, allocated>>20)
+	}
+	if !topTruncated || !uniqueTruncated {
+		t.Errorf("Expected truncation to be reported, got top words %v and unique words %v", topTruncated, uniqueTruncated)
+	}
+	if len(top) != 20 {
+		t.Errorf("Expected 20 top words, got %d", len(top))
+	}
+
+	// The sampled unique word count stays close to the true count
+	if unique < 4500000 || unique > 5500000 {
+		t.Errorf("Expected about 5000000 unique words, got %d", unique)
+	}
+}
+
+func TestFrequencyCountsTruncatedKeepTopWords(t *testing.T) {
+	counts := map[string]int{"transit": 2000, "council": 1500, "budget": 1000, "harbour": 500}
+	words := mixedTokens(200000, counts)
+
+	a := New()
+	a.SetFrequencyLimits(1000, 1000)
+	top, truncated := a.topWords(words, 4)
+	if !truncated {
+		t.Error("Expected word counts to be truncated")
+	}
+
+	want := []string{"transit", "council", "budget", "harbour"}
+	if len(top) != len(want) {
+		t.Fatalf("Expected top words %v, got %+v", want, top)
+	}
+	for i, word := range want {
+		if top[i].Word != word || top[i].Count != counts[word] {
+			t.Errorf("Expected top word %d to be %s x%d, got %s x%d", i, word, counts[word], top[i].Word, top[i].Count)
+		}
+	}
+
+	phrases, truncated := a.topPhrases(strings.Join(mixedTokens(200000, map[string]int{"light rail": 2000}), " "), 1)
+	if !truncated {
+		t.Error("Expected phrase counts to be truncated")
+	}
+	if len(phrases) != 1 || phrases[0].Phrase != "light rail" {
+		t.Errorf("Expected top phrase %q, got %+v", "light rail", phrases)
+	}
+}
+
+func TestFrequencyCountsExactBelowCap(t *testing.T) {
+	words := mixedTokens(5000, map[string]int{"transit": 50})
+
+	a := New()
+	a.SetFrequencyLimits(len(words), 0)
+	metadata := a.AnalyzeOffline(strings.Join(words, " "))
+
+	if metadata.FrequencyTruncation != nil {
+		t.Errorf("Expected no truncation below the cap, got %+v", metadata.FrequencyTruncation)
+	}
+	if metadata.UniqueWords != 5001 {
+		t.Errorf("Expected 5001 unique words, got %d", metadata.UniqueWords)
+	}
+	if top := metadata.TopWords[0]; top.Word != "transit" || top.Count != 50 {
+		t.Errorf("Expected transit x50 first, got %+v", top)
+	}
+
+	// The same document over a lower cap reports truncation
+	a.SetFrequencyLimits(1000, 1000)
+	metadata = a.AnalyzeOffline(strings.Join(words, " "))
+	if metadata.FrequencyTruncation == nil || !metadata.FrequencyTruncation.Words {
+		t.Errorf("Expected word truncation to be recorded, got %+v", metadata.FrequencyTruncation)
+	}
+}
@@ -0,0 +1,58 @@
+package analyzer
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Patterns used to extract the readable text of an HTML document
+var (
+	// Elements whose content is never readable text
+	htmlHiddenPattern = regexp.MustCompile(`(?is)<!--.*?-->|<(?:script|style|noscript|template|svg|head)\b.*?</(?:script|style|noscript|template|svg|head)\s*>`)
+	// Tags that start or end a block of text
+	htmlBlockPattern = regexp.MustCompile(`(?i)</?(?:p|div|br|hr|li|dd|dt|h[1-6]|tr|td|th|section|article|aside|header|footer|nav|main|blockquote|pre|ul|ol|dl|table|figure|figcaption|form)\b[^>]*>`)
+	htmlTagPattern   = regexp.MustCompile(`(?s)<[^>]*>`)
+	// The src attribute of an img tag, quoted or not
+	htmlImagePattern = regexp.MustCompile(`(?is)<img\b[^>]*?\bsrc\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+)
+
+// HTMLText returns the readable text of an HTML document. Scripts, styles
+// and the document head are dropped, each block element becomes a paragraph
+// of its own and character references are decoded.
+func HTMLText(document string) string {
+	text := htmlHiddenPattern.ReplaceAllString(document, " ")
+	text = htmlBlockPattern.ReplaceAllString(text, "\n")
+	text = htmlTagPattern.ReplaceAllString(text, "")
+	text = html.UnescapeString(text)
+
+	var paragraphs []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			paragraphs = append(paragraphs, line)
+		}
+	}
+	return strings.Join(paragraphs, "\n\n")
+}
+
+// HTMLImages returns the image URLs of an HTML document's img tags, resolved
+// against the URL it was fetched from. Duplicates are kept, and URLs that do
+// not resolve to http(s), such as data URIs, are dropped.
+func HTMLImages(document string, base *url.URL) []string {
+	var images []string
+	for _, match := range htmlImagePattern.FindAllStringSubmatch(document, -1) {
+		src := strings.TrimSpace(html.UnescapeString(match[1] + match[2] + match[3]))
+		ref, err := url.Parse(src)
+		if src == "" || err != nil {
+			continue
+		}
+		if base != nil {
+			ref = base.ResolveReference(ref)
+		}
+		if _, err := ValidateImageURL(ref.String()); err == nil {
+			images = append(images, ref.String())
+		}
+	}
+	return images
+}
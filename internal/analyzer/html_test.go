@@ -0,0 +1,78 @@
+package analyzer
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestHTMLText(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		expected string
+	}{
+		{
+			name: "article",
+			document: `<!DOCTYPE html>
+<html><head><title>Ignored</title><style>p { color: red; }</style></head>
+<body>
+  <nav><a href="/">Home</a></nav>
+  <h1>Heading</h1>
+  <p>First   paragraph with <b>bold</b> and <a href="#">a link</a>.</p>
+  <script>var x = "<p>not text</p>";</script>
+  <p>Second<br>line &amp; more&nbsp;text</p>
+  <!-- a comment -->
+</body></html>`,
+			expected: "Home\n\nHeading\n\nFirst paragraph with bold and a link.\n\nSecond\n\nline & more text",
+		},
+		{
+			name:     "plain text",
+			document: "No markup at all",
+			expected: "No markup at all",
+		},
+		{
+			name:     "empty",
+			document: "<html><body></body></html>",
+			expected: "",
+		},
+		{
+			name:     "list",
+			document: "<ul><li>One</li><li>Two</li></ul>",
+			expected: "One\n\nTwo",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HTMLText(tt.document); got != tt.expected {
+				t.Errorf("HTMLText() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestHTMLImages(t *testing.T) {
+	base, _ := url.Parse("https://example.com/articles/page.html")
+	document := `<p><img src="/images/a.png" alt="A">
+<IMG alt='B' SRC='b.jpg'>
+<img src=https://cdn.example.net/c.gif?w=1&amp;h=2>
+<img src="data:image/png;base64,AAAA">
+<img alt="no source">
+<img src="/images/a.png"></p>`
+
+	expected := []string{
+		"https://example.com/images/a.png",
+		"https://example.com/articles/b.jpg",
+		"https://cdn.example.net/c.gif?w=1&h=2",
+		"https://example.com/images/a.png",
+	}
+	got := HTMLImages(document, base)
+	if len(got) != len(expected) {
+		t.Fatalf("HTMLImages() = %v, want %v", got, expected)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("HTMLImages()[%d] = %q, want %q", i, got[i], expected[i])
+		}
+	}
+}
@@ -0,0 +1,425 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"  // Register GIF decoder for image.DecodeConfig
+	_ "image/jpeg" // Register JPEG decoder for image.DecodeConfig
+	_ "image/png"  // Register PNG decoder for image.DecodeConfig
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docutag/textanalyzer/internal/models"
+	"github.com/docutag/textanalyzer/internal/tags"
+	_ "golang.org/x/image/webp" // Register WebP decoder for image.DecodeConfig
+)
+
+// Image fetch defaults
+const (
+	DefaultImageFetchTimeout  = 10 * time.Second
+	DefaultImageFetchMaxBytes = 64 << 10
+)
+
+// ErrImageURL is an image URL that may not be fetched because it resolves to
+// a private, loopback or link-local address. Probes refused with it fall back
+// to URL heuristics.
+var ErrImageURL = errors.New("image URL not allowed")
+
+const (
+	// maxImageProbeBytes is the largest image we will download any part of
+	maxImageProbeBytes = 10 * 1024 * 1024
+
+	// Images no larger than trackingPixelMaxDimension in both dimensions, or
+	// smaller than trackingPixelMaxBytes when dimensions are unknown, are
+	// treated as tracking pixels and not worth an AI description
+	trackingPixelMaxDimension = 2
+	trackingPixelMaxBytes     = 100
+)
+
+// ImageFetchConfig bounds the image probes made by FetchImageMetadata
+type ImageFetchConfig struct {
+	Disabled bool          // Use URL heuristics only, without fetching images
+	Timeout  time.Duration // Total time for an image's requests, redirects included (default: 10s)
+	MaxBytes int64         // Most bytes downloaded from an image to decode its dimensions (default: 64 KiB)
+
+	// AllowPrivate lets probes reach private, loopback and link-local
+	// addresses, e.g. httptest servers in tests
+	AllowPrivate bool
+}
+
+// SetImageFetchConfig sets how images are probed, defaulting unset limits.
+// Unless cfg.AllowPrivate, only public addresses are fetched, so submitted
+// image URLs cannot reach internal services.
+func (a *Analyzer) SetImageFetchConfig(cfg ImageFetchConfig) {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultImageFetchTimeout
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = DefaultImageFetchMaxBytes
+	}
+	a.imageFetch = cfg
+
+	dialer := PublicDialer(cfg.Timeout, cfg.AllowPrivate, ErrImageURL)
+	a.httpClient = &http.Client{
+		Timeout: cfg.Timeout,
+		Transport: &http.Transport{
+			// No proxy: it would dial internal addresses on our behalf
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: cfg.Timeout,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > DefaultPageMaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", DefaultPageMaxRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("%w: redirect to %s", ErrImageURL, req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+}
+
+// SetImageDescriber sets the vision model that captions and tags images;
+// nil disables image description
+func (a *Analyzer) SetImageDescriber(describer ImageDescriber) {
+	a.imageDescriber = describer
+}
+
+// DescribesImages reports whether images are described: an image describer
+// is set and image fetching is not disabled
+func (a *Analyzer) DescribesImages() bool {
+	return a.imageDescriber != nil && !a.imageFetch.Disabled
+}
+
+// DescribeImage downloads an image, refusing images over the probe size
+// limit, and captions and tags it with the image describer. The tag policy
+// applies to the tags as it does to text tags.
+func (a *Analyzer) DescribeImage(ctx context.Context, imageURL string) (string, []string, error) {
+	if a.imageDescriber == nil {
+		return "", nil, errors.New("no image describer configured")
+	}
+	if a.imageFetch.Disabled {
+		return "", nil, errors.New("image fetching is disabled")
+	}
+
+	data, err := a.downloadImage(ctx, imageURL)
+	if err != nil {
+		return "", nil, err
+	}
+	caption, imageTags, err := a.imageDescriber.DescribeImage(ctx, data)
+	if err != nil {
+		return "", nil, err
+	}
+	return caption, tags.ApplyPolicy(imageTags, a.tagPolicy), nil
+}
+
+// downloadImage fetches a whole image of at most maxImageProbeBytes
+func (a *Analyzer) downloadImage(ctx context.Context, imageURL string) ([]byte, error) {
+	if a.imageFetch.Disabled {
+		return nil, errors.New("image fetching is disabled")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, imageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download image: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("image request failed with status %d", resp.StatusCode)
+	}
+	if resp.ContentLength > maxImageProbeBytes {
+		return nil, fmt.Errorf("image too large: %d bytes, maximum is %d", resp.ContentLength, maxImageProbeBytes)
+	}
+
+	// Read one byte past the limit to tell an image at the limit from one over it
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImageProbeBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download image: %w", err)
+	}
+	if len(data) > maxImageProbeBytes {
+		return nil, fmt.Errorf("image too large: maximum is %d bytes", maxImageProbeBytes)
+	}
+	return data, nil
+}
+
+// DefaultMaxImages is the default number of images enriched per analysis
+const DefaultMaxImages = 50
+
+// ImageSelection is the result of filtering a document's image URLs
+type ImageSelection struct {
+	Accepted   []string // Valid, unique URLs within the limit, in submission order
+	Invalid    []string // URLs that failed validation
+	Duplicates int      // URLs repeating an earlier URL
+	Overflow   int      // Valid, unique URLs beyond the limit
+}
+
+// Skipped returns how many URLs were not accepted
+func (s ImageSelection) Skipped() int {
+	return len(s.Invalid) + s.Duplicates + s.Overflow
+}
+
+// SelectImageURLs drops invalid and duplicate image URLs and keeps at most
+// maxImages of the rest, in order. A maxImages of 0 means no limit.
+func SelectImageURLs(imageURLs []string, maxImages int) ImageSelection {
+	var selection ImageSelection
+	seen := make(map[string]bool, len(imageURLs))
+	for _, imageURL := range imageURLs {
+		u, err := ValidateImageURL(imageURL)
+		if err != nil {
+			selection.Invalid = append(selection.Invalid, imageURL)
+			continue
+		}
+
+		key := u.String()
+		if seen[key] {
+			selection.Duplicates++
+			continue
+		}
+		seen[key] = true
+
+		if maxImages > 0 && len(selection.Accepted) >= maxImages {
+			selection.Overflow++
+			continue
+		}
+		selection.Accepted = append(selection.Accepted, strings.TrimSpace(imageURL))
+	}
+	return selection
+}
+
+// ValidateImageURL checks that an image URL is an absolute http(s) URL
+func ValidateImageURL(imageURL string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(imageURL))
+	if err != nil {
+		return nil, fmt.Errorf("invalid image URL %q: %w", imageURL, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid image URL %q: scheme must be http or https", imageURL)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid image URL %q: missing host", imageURL)
+	}
+	return u, nil
+}
+
+// FetchImageMetadata probes an image over HTTP to learn its type, size and
+// dimensions. It issues a HEAD request, then a ranged GET for the first
+// ImageFetchConfig.MaxBytes to decode the image header. Network failures,
+// refused addresses and HTTP errors are recorded on the result, which falls
+// back to URL heuristics, as it does when fetching is disabled; only an
+// invalid URL returns an error.
+func (a *Analyzer) FetchImageMetadata(ctx context.Context, imageURL string) (*models.ImageMetadata, error) {
+	u, err := ValidateImageURL(imageURL)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := &models.ImageMetadata{
+		URL:    imageURL,
+		Domain: u.Host,
+		Format: formatFromURL(u),
+	}
+
+	if a.imageFetch.Disabled {
+		return metadata, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, a.imageFetch.Timeout)
+	defer cancel()
+
+	if a.probeImage(ctx, metadata) {
+		a.decodeImageHeader(ctx, metadata)
+	}
+
+	metadata.IsTrackingPixel = isTrackingPixel(metadata)
+
+	slog.Info("image metadata fetched",
+		"url", imageURL,
+		"format", metadata.Format,
+		"status_code", metadata.StatusCode,
+		"content_length", metadata.ContentLength,
+		"width", metadata.Width,
+		"height", metadata.Height,
+		"tracking_pixel", metadata.IsTrackingPixel,
+		"error", metadata.Error,
+	)
+
+	return metadata, nil
+}
+
+// probeImage issues a HEAD request and records the response headers.
+// It reports whether the image header should be downloaded next.
+func (a *Analyzer) probeImage(ctx context.Context, metadata *models.ImageMetadata) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, metadata.URL, nil)
+	if err != nil {
+		metadata.Error = err.Error()
+		return false
+	}
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		metadata.Error = err.Error()
+		return false
+	}
+	resp.Body.Close()
+
+	metadata.Fetched = true
+	metadata.StatusCode = resp.StatusCode
+
+	// Some servers reject HEAD; let the ranged GET find out
+	if resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented {
+		return true
+	}
+	if resp.StatusCode >= 400 {
+		metadata.Error = fmt.Sprintf("image request failed with status %d", resp.StatusCode)
+		return false
+	}
+
+	applyImageHeaders(metadata, resp.Header, resp.ContentLength)
+	return metadata.Error == "" && !metadata.Oversized
+}
+
+// decodeImageHeader downloads the start of the image and decodes its
+// dimensions and format
+func (a *Analyzer) decodeImageHeader(ctx context.Context, metadata *models.ImageMetadata) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metadata.URL, nil)
+	if err != nil {
+		metadata.Error = err.Error()
+		return
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=0-%d", a.imageFetch.MaxBytes-1))
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		metadata.Error = err.Error()
+		return
+	}
+	defer resp.Body.Close()
+
+	metadata.StatusCode = resp.StatusCode
+	if resp.StatusCode >= 400 {
+		metadata.Error = fmt.Sprintf("image request failed with status %d", resp.StatusCode)
+		return
+	}
+
+	// A partial response carries the full size in Content-Range
+	contentLength := resp.ContentLength
+	if resp.StatusCode == http.StatusPartialContent {
+		contentLength = totalFromContentRange(resp.Header.Get("Content-Range"))
+	}
+	if metadata.ContentLength == 0 || metadata.ContentType == "" {
+		applyImageHeaders(metadata, resp.Header, contentLength)
+	}
+	if metadata.Error != "" || metadata.Oversized {
+		return
+	}
+
+	config, format, err := image.DecodeConfig(io.LimitReader(resp.Body, a.imageFetch.MaxBytes))
+	if err != nil {
+		// Formats like SVG have no decoder; keep what the headers told us
+		slog.Debug("could not decode image header", "url", metadata.URL, "error", err)
+		return
+	}
+
+	metadata.Format = format
+	metadata.Width = config.Width
+	metadata.Height = config.Height
+}
+
+// applyImageHeaders records Content-Type and Content-Length on the metadata,
+// flagging non-image responses and images over the size limit
+func applyImageHeaders(metadata *models.ImageMetadata, header http.Header, contentLength int64) {
+	if contentType := header.Get("Content-Type"); contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			mediaType = contentType
+		}
+		metadata.ContentType = mediaType
+
+		if !strings.HasPrefix(mediaType, "image/") {
+			metadata.Error = fmt.Sprintf("unexpected content type %q", mediaType)
+		} else if format := formatFromContentType(mediaType); format != "unknown" {
+			metadata.Format = format
+		}
+	}
+
+	if contentLength > 0 {
+		metadata.ContentLength = contentLength
+		metadata.Oversized = contentLength > maxImageProbeBytes
+	}
+}
+
+// totalFromContentRange extracts the total size from a header such as
+// "bytes 0-65535/1048576", returning -1 when it is absent or unknown
+func totalFromContentRange(contentRange string) int64 {
+	idx := strings.LastIndex(contentRange, "/")
+	if idx == -1 {
+		return -1
+	}
+	total, err := strconv.ParseInt(contentRange[idx+1:], 10, 64)
+	if err != nil {
+		return -1
+	}
+	return total
+}
+
+// isTrackingPixel reports whether an image is too small to be worth describing
+func isTrackingPixel(metadata *models.ImageMetadata) bool {
+	if metadata.Error != "" {
+		return false
+	}
+	if metadata.Width > 0 && metadata.Height > 0 {
+		return metadata.Width <= trackingPixelMaxDimension && metadata.Height <= trackingPixelMaxDimension
+	}
+	return metadata.ContentLength > 0 && metadata.ContentLength < trackingPixelMaxBytes
+}
+
+// formatFromURL guesses the image format from the URL path extension,
+// ignoring any query string
+func formatFromURL(u *url.URL) string {
+	switch strings.ToLower(path.Ext(u.Path)) {
+	case ".jpg", ".jpeg":
+		return "jpeg"
+	case ".png":
+		return "png"
+	case ".gif":
+		return "gif"
+	case ".webp":
+		return "webp"
+	case ".svg":
+		return "svg"
+	}
+	return "unknown"
+}
+
+// formatFromContentType maps an image media type to a format name
+func formatFromContentType(mediaType string) string {
+	switch mediaType {
+	case "image/jpeg", "image/jpg":
+		return "jpeg"
+	case "image/png":
+		return "png"
+	case "image/gif":
+		return "gif"
+	case "image/webp":
+		return "webp"
+	case "image/svg+xml":
+		return "svg"
+	}
+	return "unknown"
+}
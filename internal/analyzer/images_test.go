@@ -0,0 +1,431 @@
+package analyzer
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docutag/textanalyzer/internal/tags"
+)
+
+func encodeTestJPEG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height)), nil); err != nil {
+		t.Fatalf("Failed to encode JPEG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func encodeTestPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	img.Set(0, 0, color.White)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("Failed to encode PNG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// encodeTestWebP builds a lossless WebP header, which is all DecodeConfig reads
+func encodeTestWebP(width, height int) []byte {
+	bits := uint32(width-1) | uint32(height-1)<<14
+	chunk := make([]byte, 5)
+	chunk[0] = 0x2f // VP8L signature
+	binary.LittleEndian.PutUint32(chunk[1:], bits)
+
+	var buf bytes.Buffer
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(4+8+len(chunk)))
+	buf.WriteString("WEBP")
+	buf.WriteString("VP8L")
+	binary.Write(&buf, binary.LittleEndian, uint32(len(chunk)))
+	buf.Write(chunk)
+	return buf.Bytes()
+}
+
+func newImageServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	images := map[string]struct {
+		contentType string
+		data        []byte
+	}{
+		"/photo.jpg": {"image/jpeg", encodeTestJPEG(t, 640, 480)},
+		"/img":       {"image/png", encodeTestPNG(t, 320, 200)},
+		"/anim.webp": {"image/webp", encodeTestWebP(120, 90)},
+		"/pixel.png": {"image/png", encodeTestPNG(t, 1, 1)},
+		"/page.html": {"text/html; charset=utf-8", []byte("<html></html>")},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		img, ok := images[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", img.contentType)
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(img.data))
+	})
+	mux.HandleFunc("/huge.jpg", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			t.Errorf("Oversized image should not be downloaded, got %s", r.Method)
+		}
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Header().Set("Content-Length", "52428800")
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newImageTestAnalyzer returns an analyzer allowed to probe httptest servers
+func newImageTestAnalyzer(cfg ImageFetchConfig) *Analyzer {
+	a := New()
+	cfg.AllowPrivate = true
+	a.SetImageFetchConfig(cfg)
+	return a
+}
+
+func TestFetchImageMetadata(t *testing.T) {
+	server := newImageServer(t)
+	a := newImageTestAnalyzer(ImageFetchConfig{})
+
+	tests := []struct {
+		name           string
+		path           string
+		expectedFormat string
+		expectedWidth  int
+		expectedHeight int
+		expectTracking bool
+	}{
+		{"JPEG", "/photo.jpg", "jpeg", 640, 480, false},
+		{"PNG without extension", "/img?id=123", "png", 320, 200, false},
+		{"WebP", "/anim.webp", "webp", 120, 90, false},
+		{"tracking pixel", "/pixel.png", "png", 1, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metadata, err := a.FetchImageMetadata(context.Background(), server.URL+tt.path)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if metadata.Error != "" {
+				t.Fatalf("Unexpected probe error: %s", metadata.Error)
+			}
+			if !metadata.Fetched {
+				t.Error("Expected image to be fetched")
+			}
+			if metadata.Format != tt.expectedFormat {
+				t.Errorf("Expected format %s, got %s", tt.expectedFormat, metadata.Format)
+			}
+			if metadata.Width != tt.expectedWidth || metadata.Height != tt.expectedHeight {
+				t.Errorf("Expected %dx%d, got %dx%d", tt.expectedWidth, tt.expectedHeight, metadata.Width, metadata.Height)
+			}
+			if metadata.ContentLength <= 0 {
+				t.Errorf("Expected content length, got %d", metadata.ContentLength)
+			}
+			if metadata.IsTrackingPixel != tt.expectTracking {
+				t.Errorf("Expected tracking pixel %v, got %v", tt.expectTracking, metadata.IsTrackingPixel)
+			}
+		})
+	}
+}
+
+func TestFetchImageMetadataOversized(t *testing.T) {
+	server := newImageServer(t)
+
+	metadata, err := newImageTestAnalyzer(ImageFetchConfig{}).FetchImageMetadata(context.Background(), server.URL+"/huge.jpg")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !metadata.Oversized {
+		t.Error("Expected image to be flagged as oversized")
+	}
+	if metadata.ContentLength != 52428800 {
+		t.Errorf("Expected content length 52428800, got %d", metadata.ContentLength)
+	}
+	if metadata.Format != "jpeg" {
+		t.Errorf("Expected format jpeg, got %s", metadata.Format)
+	}
+	if metadata.Width != 0 || metadata.Height != 0 {
+		t.Errorf("Expected no dimensions for oversized image, got %dx%d", metadata.Width, metadata.Height)
+	}
+}
+
+func TestFetchImageMetadataErrors(t *testing.T) {
+	server := newImageServer(t)
+	a := newImageTestAnalyzer(ImageFetchConfig{})
+
+	metadata, err := a.FetchImageMetadata(context.Background(), server.URL+"/missing.jpg")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if metadata.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", metadata.StatusCode)
+	}
+	if metadata.Error == "" {
+		t.Error("Expected probe error for 404")
+	}
+	if metadata.Format != "jpeg" {
+		t.Errorf("Expected URL heuristic format jpeg, got %s", metadata.Format)
+	}
+	if metadata.IsTrackingPixel {
+		t.Error("404 should not be treated as a tracking pixel")
+	}
+
+	metadata, err = a.FetchImageMetadata(context.Background(), server.URL+"/page.html")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if metadata.Error == "" || metadata.ContentType != "text/html" {
+		t.Errorf("Expected non-image content type error, got %q (%s)", metadata.Error, metadata.ContentType)
+	}
+	if metadata.IsTrackingPixel {
+		t.Error("Non-image response should not be treated as a tracking pixel")
+	}
+
+	for _, invalid := range []string{"", "/relative/path.jpg", "ftp://example.com/a.png", "https:///a.png"} {
+		if _, err := a.FetchImageMetadata(context.Background(), invalid); err == nil {
+			t.Errorf("Expected error for invalid URL %q", invalid)
+		}
+	}
+}
+
+func TestFetchImageMetadataOfflineFallback(t *testing.T) {
+	// Nothing listens on this server once closed, so the probe fails
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	metadata, err := newImageTestAnalyzer(ImageFetchConfig{}).FetchImageMetadata(context.Background(), server.URL+"/photo.webp?w=200")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if metadata.Fetched {
+		t.Error("Expected image not to be fetched")
+	}
+	if metadata.Error == "" {
+		t.Error("Expected probe error to be recorded")
+	}
+	if metadata.Format != "webp" {
+		t.Errorf("Expected URL heuristic format webp, got %s", metadata.Format)
+	}
+}
+
+func TestFetchImageMetadataPrivateAddress(t *testing.T) {
+	server := newImageServer(t)
+
+	// The default analyzer refuses to dial the loopback test server
+	metadata, err := New().FetchImageMetadata(context.Background(), server.URL+"/photo.jpg")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if metadata.Fetched {
+		t.Error("Expected private address not to be fetched")
+	}
+	if !strings.Contains(metadata.Error, ErrImageURL.Error()) {
+		t.Errorf("Expected refused address error, got %q", metadata.Error)
+	}
+	if metadata.Format != "jpeg" || metadata.Width != 0 {
+		t.Errorf("Expected URL heuristics only, got format %s and width %d", metadata.Format, metadata.Width)
+	}
+}
+
+func TestFetchImageMetadataDisabled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Expected no request with fetching disabled, got %s %s", r.Method, r.URL.Path)
+	}))
+	defer server.Close()
+
+	a := newImageTestAnalyzer(ImageFetchConfig{Disabled: true})
+	metadata, err := a.FetchImageMetadata(context.Background(), server.URL+"/photo.png")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if metadata.Fetched || metadata.Error != "" {
+		t.Errorf("Expected no probe, got fetched %v and error %q", metadata.Fetched, metadata.Error)
+	}
+	if metadata.Format != "png" {
+		t.Errorf("Expected URL heuristic format png, got %s", metadata.Format)
+	}
+}
+
+func TestFetchImageMetadataLimits(t *testing.T) {
+	jpegData := encodeTestJPEG(t, 640, 480)
+	var ranges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow.jpg" {
+			<-r.Context().Done()
+			return
+		}
+		if r.Method == http.MethodGet {
+			ranges = append(ranges, r.Header.Get("Range"))
+		}
+		w.Header().Set("Content-Type", "image/jpeg")
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(jpegData))
+	}))
+	defer server.Close()
+
+	a := newImageTestAnalyzer(ImageFetchConfig{Timeout: 100 * time.Millisecond, MaxBytes: 1024})
+	metadata, err := a.FetchImageMetadata(context.Background(), server.URL+"/photo.jpg")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if metadata.Width != 640 || metadata.Height != 480 {
+		t.Errorf("Expected 640x480 from the first KiB, got %dx%d", metadata.Width, metadata.Height)
+	}
+	if !reflect.DeepEqual(ranges, []string{"bytes=0-1023"}) {
+		t.Errorf("Expected one ranged GET of 1 KiB, got %v", ranges)
+	}
+
+	start := time.Now()
+	metadata, err = a.FetchImageMetadata(context.Background(), server.URL+"/slow.jpg")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if metadata.Error == "" || metadata.Format != "jpeg" {
+		t.Errorf("Expected timeout recorded with URL heuristics, got error %q and format %s", metadata.Error, metadata.Format)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected probe to stop at the timeout, took %v", elapsed)
+	}
+}
+
+// fakeImageDescriber records the image it was given and returns fixed results
+type fakeImageDescriber struct {
+	received []byte
+	caption  string
+	tags     []string
+}
+
+func (f *fakeImageDescriber) DescribeImage(ctx context.Context, imageData []byte) (string, []string, error) {
+	f.received = imageData
+	return f.caption, f.tags, nil
+}
+
+func TestDescribeImage(t *testing.T) {
+	photo := encodeTestJPEG(t, 64, 48)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/photo.jpg", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Write(photo)
+	})
+	mux.HandleFunc("/huge.jpg", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Write(make([]byte, maxImageProbeBytes+1))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	a := newImageTestAnalyzer(ImageFetchConfig{})
+	if a.DescribesImages() {
+		t.Fatal("Expected no image describer by default")
+	}
+	if _, _, err := a.DescribeImage(context.Background(), server.URL+"/photo.jpg"); err == nil {
+		t.Error("Expected error without an image describer")
+	}
+
+	describer := &fakeImageDescriber{
+		caption: "A subway map of the city",
+		tags:    []string{"map", "ads", "transit"},
+	}
+	a.SetImageDescriber(describer)
+	a.SetTagPolicy(tags.Policy{Blacklist: []string{"ads"}})
+	if !a.DescribesImages() {
+		t.Fatal("Expected image describer to be set")
+	}
+
+	caption, imageTags, err := a.DescribeImage(context.Background(), server.URL+"/photo.jpg")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !bytes.Equal(describer.received, photo) {
+		t.Errorf("Expected describer to receive the %d image bytes, got %d", len(photo), len(describer.received))
+	}
+	if caption != describer.caption {
+		t.Errorf("Expected caption %q, got %q", describer.caption, caption)
+	}
+	if !reflect.DeepEqual(imageTags, []string{"map", "transit"}) {
+		t.Errorf("Expected tag policy to apply, got %v", imageTags)
+	}
+
+	t.Run("oversized", func(t *testing.T) {
+		describer.received = nil
+		if _, _, err := a.DescribeImage(context.Background(), server.URL+"/huge.jpg"); err == nil {
+			t.Error("Expected error for oversized image")
+		}
+		if describer.received != nil {
+			t.Error("Oversized image should not be described")
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		if _, _, err := a.DescribeImage(context.Background(), server.URL+"/missing.jpg"); err == nil {
+			t.Error("Expected error for missing image")
+		}
+	})
+
+	t.Run("fetching disabled", func(t *testing.T) {
+		disabled := newImageTestAnalyzer(ImageFetchConfig{Disabled: true})
+		disabled.SetImageDescriber(describer)
+		describer.received = nil
+		if disabled.DescribesImages() {
+			t.Error("Expected no image description with fetching disabled")
+		}
+		if _, _, err := disabled.DescribeImage(context.Background(), server.URL+"/photo.jpg"); err == nil {
+			t.Error("Expected error with fetching disabled")
+		}
+		if describer.received != nil {
+			t.Error("Image should not be downloaded with fetching disabled")
+		}
+	})
+}
+
+func TestSelectImageURLs(t *testing.T) {
+	urls := []string{
+		"https://example.com/a.jpg",
+		"ftp://example.com/b.jpg",
+		" https://example.com/a.jpg ",
+		"https://example.com/c.png",
+		"/relative/d.png",
+		"https://example.com/e.gif",
+		"https://example.com/c.png",
+		"https://example.com/f.webp",
+	}
+
+	selection := SelectImageURLs(urls, 3)
+	if want := []string{"https://example.com/a.jpg", "https://example.com/c.png", "https://example.com/e.gif"}; !reflect.DeepEqual(selection.Accepted, want) {
+		t.Errorf("Expected accepted %v, got %v", want, selection.Accepted)
+	}
+	if want := []string{"ftp://example.com/b.jpg", "/relative/d.png"}; !reflect.DeepEqual(selection.Invalid, want) {
+		t.Errorf("Expected invalid %v, got %v", want, selection.Invalid)
+	}
+	if selection.Duplicates != 2 {
+		t.Errorf("Expected 2 duplicates, got %d", selection.Duplicates)
+	}
+	if selection.Overflow != 1 {
+		t.Errorf("Expected 1 image over the limit, got %d", selection.Overflow)
+	}
+	if selection.Skipped() != 5 {
+		t.Errorf("Expected 5 skipped, got %d", selection.Skipped())
+	}
+
+	// No limit keeps every valid, unique URL
+	if unlimited := SelectImageURLs(urls, 0); len(unlimited.Accepted) != 4 || unlimited.Overflow != 0 {
+		t.Errorf("Expected 4 accepted without a limit, got %+v", unlimited)
+	}
+}
@@ -0,0 +1,169 @@
+package analyzer
+
+import (
+	"math"
+	"unicode"
+
+	"github.com/docutag/textanalyzer/internal/models"
+)
+
+// Languages recorded in Metadata.Language, as ISO 639-1 codes
+const (
+	LanguageEnglish  = "en"
+	LanguageSpanish  = "es"
+	LanguageFrench   = "fr"
+	LanguageGerman   = "de"
+	LanguageChinese  = "zh"
+	LanguageJapanese = "ja"
+	LanguageKorean   = "ko"
+	LanguageUnknown  = "unknown"
+)
+
+// minLanguageWords is the number of words a text must contain before its
+// language is recorded in metadata; shorter texts are reported as unknown
+// rather than guessed. Each CJK character counts as a word.
+const minLanguageWords = 50
+
+// minLanguageEvidence is the number of profile words a text must contain
+// before its language is guessed at all
+const minLanguageEvidence = 3
+
+var languageProfiles = getLanguageProfiles()
+
+// scriptCounts counts the CJK characters of a text by script, and the words
+// that contain none
+type scriptCounts struct {
+	han, kana, hangul int
+	otherWords        int
+}
+
+// countScripts counts the CJK characters and other words of text
+func countScripts(text string) scriptCounts {
+	var counts scriptCounts
+	for _, word := range unicodeWords(text) {
+		cjk := 0
+		for _, r := range word {
+			switch {
+			case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+				counts.kana++
+			case unicode.Is(unicode.Hangul, r):
+				counts.hangul++
+			case unicode.Is(unicode.Han, r):
+				counts.han++
+			default:
+				continue
+			}
+			cjk++
+		}
+		if cjk == 0 {
+			counts.otherWords++
+		}
+	}
+	return counts
+}
+
+// cjk returns the number of CJK characters
+func (c scriptCounts) cjk() int {
+	return c.han + c.kana + c.hangul
+}
+
+// words approximates the word count, counting each CJK character as a word
+func (c scriptCounts) words() int {
+	return c.cjk() + c.otherWords
+}
+
+// detectLanguage returns the language recorded in metadata and its
+// confidence. Texts of fewer than minLanguageWords words are unknown.
+func detectLanguage(text string) (string, float64) {
+	counts := countScripts(text)
+	if counts.words() < minLanguageWords {
+		return LanguageUnknown, 0
+	}
+	return detectCountedLanguage(text, counts)
+}
+
+// applyLanguage sets the detected language and its confidence on metadata
+func applyLanguage(metadata *models.Metadata, text string) {
+	metadata.Language, metadata.LanguageConfidence = detectLanguage(text)
+}
+
+// detectLanguageWithConfidence returns the dominant language of text and its
+// share of the evidence as a confidence between 0 and 1. Texts with too
+// little evidence are reported as unknown with zero confidence.
+func detectLanguageWithConfidence(text string) (string, float64) {
+	return detectCountedLanguage(text, countScripts(text))
+}
+
+// detectCountedLanguage is detectLanguageWithConfidence for text whose
+// scripts have been counted. Text written mostly in CJK characters is told
+// apart by script; other text by the function words it contains.
+func detectCountedLanguage(text string, counts scriptCounts) (string, float64) {
+	if counts.cjk() > counts.otherWords {
+		return detectCJKLanguage(counts)
+	}
+	return detectFunctionWordLanguage(text)
+}
+
+// detectCJKLanguage identifies text by its CJK characters: mostly Hangul is
+// Korean, Han with kana is Japanese and Han alone is Chinese. The confidence
+// is the share of the text's words written in that language's script.
+func detectCJKLanguage(counts scriptCounts) (string, float64) {
+	if counts.cjk() < minLanguageEvidence {
+		return LanguageUnknown, 0
+	}
+
+	language, characters := LanguageChinese, counts.han
+	switch {
+	case counts.hangul > counts.han+counts.kana:
+		language, characters = LanguageKorean, counts.hangul
+	case counts.kana > 0 && counts.kana*10 >= counts.han+counts.kana:
+		// Japanese mixes kana into Han text; Chinese never uses it
+		language, characters = LanguageJapanese, counts.han+counts.kana
+	}
+	return language, confidenceShare(characters, counts.words())
+}
+
+// detectFunctionWordLanguage returns the language whose function words make
+// up the largest share of the profile words found in text
+func detectFunctionWordLanguage(text string) (string, float64) {
+	hits := make(map[string]int, len(languageProfiles))
+	total := 0
+	for _, word := range unicodeWords(text) {
+		for language, profile := range languageProfiles {
+			if profile[word] {
+				hits[language]++
+				total++
+			}
+		}
+	}
+	if total < minLanguageEvidence {
+		return LanguageUnknown, 0
+	}
+
+	best, bestHits := LanguageUnknown, 0
+	for language, count := range hits {
+		// Break ties by code so the result is deterministic
+		if count > bestHits || (count == bestHits && language < best) {
+			best, bestHits = language, count
+		}
+	}
+	return best, confidenceShare(bestHits, total)
+}
+
+// confidenceShare returns n out of total as a confidence rounded to two places
+func confidenceShare(n, total int) float64 {
+	return math.Round(float64(n)/float64(total)*100) / 100
+}
+
+// usesEnglishLexicon reports whether the English word lists behind the stop
+// words and coherence measures describe text in language. Text of unknown
+// language is treated as English, as it was before detection.
+func usesEnglishLexicon(language string) bool {
+	return language == LanguageEnglish || language == LanguageUnknown || language == ""
+}
+
+// isStopWord reports whether word is a stop word in text of language. The
+// function words of a detected non-English language are stop words too.
+func (a *Analyzer) isStopWord(word, language string) bool {
+	return a.stopWords[word] || languageProfiles[language][word]
+}
@@ -0,0 +1,130 @@
+package analyzer
+
+import (
+	"strings"
+	"testing"
+)
+
+const frenchLanguageFixture = `Le conseil municipal a approuvé mardi le nouveau plan de transport pour la ville. Il ajoute douze lignes de bus dans les quartiers de l'est et il prolonge le service du soir sur les lignes les plus fréquentées. Les travaux des nouveaux arrêts commenceront au printemps. Les habitants qui sont venus à la réunion ont dit que ces changements étaient attendus depuis très longtemps.`
+
+const germanLanguageFixture = `Der Stadtrat hat am Dienstag den neuen Verkehrsplan für die Stadt beschlossen. Er fügt zwölf Buslinien in den östlichen Bezirken hinzu und verlängert den Abendverkehr auf den am stärksten genutzten Linien. Der Bau der neuen Haltestellen beginnt im Frühjahr. Die Bewohner, die bei der Sitzung waren, sagten, dass die Änderungen schon lange überfällig sind und dass sie auch die Pendler entlasten werden.`
+
+const japaneseLanguageFixture = `市議会は火曜日に新しい交通計画を承認しました。この計画では東部の地区に十二本のバス路線が追加され、最も混雑する路線の夜間運行も延長されます。新しい停留所の工事は春に始まる予定です。会議で発言した住民たちは、この変更はずっと前から必要だったと話しました。`
+
+const chineseLanguageFixture = `市议会星期二批准了新的交通计划。该计划为东部地区增加了十二条公交线路，并延长了最繁忙线路的夜间服务。新车站的建设将于春季开始。在会议上发言的居民表示，这些变化早就应该进行了。`
+
+const koreanLanguageFixture = `시의회는 화요일에 새로운 교통 계획을 승인했습니다. 이 계획은 동부 지역에 열두 개의 버스 노선을 추가하고 가장 붐비는 노선의 야간 운행을 연장합니다. 새 정류장 공사는 봄에 시작될 예정입니다. 회의에서 발언한 주민들은 이러한 변화가 오래전부터 필요했다고 말했습니다.`
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		language string
+		minConf  float64
+	}{
+		{"english", englishReadabilityFixture, LanguageEnglish, minLanguageConfidence},
+		{"spanish", spanishReadabilityFixture, LanguageSpanish, minLanguageConfidence},
+		{"french", frenchLanguageFixture, LanguageFrench, minLanguageConfidence},
+		{"german", germanLanguageFixture, LanguageGerman, minLanguageConfidence},
+		{"japanese", japaneseLanguageFixture, LanguageJapanese, minLanguageConfidence},
+		{"chinese", chineseLanguageFixture, LanguageChinese, minLanguageConfidence},
+		{"korean", koreanLanguageFixture, LanguageKorean, minLanguageConfidence},
+		{"mostly spanish", spanishReadabilityFixture + " The council said the plan was approved.", LanguageSpanish, minLanguageConfidence},
+		{"mostly japanese", japaneseLanguageFixture + " The plan was approved on Tuesday.", LanguageJapanese, minLanguageConfidence},
+		{"chinese names in english", englishReadabilityFixture + " The mayor, 王伟, spoke first.", LanguageEnglish, minLanguageConfidence},
+		{"short english", "The city council approved the new transit plan on Tuesday.", LanguageUnknown, 0},
+		{"short japanese", "市議会は火曜日に新しい交通計画を承認しました。", LanguageUnknown, 0},
+		{"no words", "2024 2025 2026", LanguageUnknown, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			language, confidence := detectLanguage(tt.text)
+			if language != tt.language {
+				t.Errorf("Expected language %q, got %q (confidence %.2f)", tt.language, language, confidence)
+			}
+			if confidence < tt.minConf || confidence > 1 {
+				t.Errorf("Expected confidence between %.2f and 1, got %.2f", tt.minConf, confidence)
+			}
+			if language == LanguageUnknown && confidence != 0 {
+				t.Errorf("Expected zero confidence for unknown language, got %.2f", confidence)
+			}
+		})
+	}
+}
+
+func TestDetectLanguageWithConfidence(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		language string
+		minConf  float64
+	}{
+		{"french", "Le conseil municipal a approuvé le nouveau plan de transport. Il ajoute des lignes dans les quartiers et il est très attendu par les habitants.", LanguageFrench, minLanguageConfidence},
+		{"german", "Der Stadtrat hat den neuen Verkehrsplan beschlossen. Er ist für die östlichen Bezirke gedacht und wird im Frühjahr gebaut.", LanguageGerman, minLanguageConfidence},
+		{"japanese", "市議会は火曜日に新しい交通計画を承認しました。", LanguageJapanese, minLanguageConfidence},
+		{"too little evidence", "Tokyo 2024", LanguageUnknown, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			language, confidence := detectLanguageWithConfidence(tt.text)
+			if language != tt.language {
+				t.Errorf("Expected language %q, got %q (confidence %.2f)", tt.language, language, confidence)
+			}
+			if confidence < tt.minConf {
+				t.Errorf("Expected confidence of at least %.2f, got %.2f", tt.minConf, confidence)
+			}
+		})
+	}
+}
+
+func TestAnalyzeRecordsLanguage(t *testing.T) {
+	a := New()
+
+	metadata := a.AnalyzeOffline(germanLanguageFixture)
+	if metadata.Language != LanguageGerman || metadata.LanguageConfidence < minLanguageConfidence {
+		t.Errorf("Expected language %q with confidence, got %q (%.2f)", LanguageGerman, metadata.Language, metadata.LanguageConfidence)
+	}
+
+	short := a.AnalyzeOffline("Der Stadtrat hat den neuen Verkehrsplan beschlossen.")
+	if short.Language != LanguageUnknown || short.LanguageConfidence != 0 {
+		t.Errorf("Expected unknown language for short text, got %q (%.2f)", short.Language, short.LanguageConfidence)
+	}
+}
+
+func TestKeyTermsSkipFunctionWordsOfLanguage(t *testing.T) {
+	words := extractWords(spanishReadabilityFixture)
+
+	english := New().extractKeyTerms(words, 50, LanguageEnglish)
+	if !containsStringSlice(english, "durante") {
+		t.Fatalf("Expected English stop words to keep %q, got %v", "durante", english)
+	}
+
+	spanish := New().extractKeyTerms(words, 50, LanguageSpanish)
+	for _, word := range []string{"durante", "también", "desde"} {
+		if containsStringSlice(spanish, word) {
+			t.Errorf("Expected Spanish function word %q to be skipped, got %v", word, spanish)
+		}
+	}
+}
+
+func TestQualityScoringSkipsEnglishCoherenceChecks(t *testing.T) {
+	text := strings.Repeat(spanishReadabilityFixture+"\n\n", 3)
+	wordCount := len(extractWords(text))
+
+	english := scoreTextQualityFallback(text, wordCount, nil, testCoherence(text), LanguageEnglish)
+	if !containsStringSlice(english.ProblemsDetected, "lacks_transitions") {
+		t.Fatalf("Expected English transition checks to flag the Spanish text, got %v", english.ProblemsDetected)
+	}
+
+	spanish := scoreTextQualityFallback(text, wordCount, nil, testCoherence(text), LanguageSpanish)
+	for _, problem := range []string{"lacks_transitions", "lacks_coherence_markers"} {
+		if containsStringSlice(spanish.ProblemsDetected, problem) {
+			t.Errorf("Expected no %q for Spanish text, got %v", problem, spanish.ProblemsDetected)
+		}
+	}
+	if spanish.Score <= english.Score {
+		t.Errorf("Expected Spanish scoring above %.2f, got %.2f", english.Score, spanish.Score)
+	}
+}
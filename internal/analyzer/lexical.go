@@ -0,0 +1,99 @@
+package analyzer
+
+import (
+	"math"
+	"unicode/utf8"
+
+	"github.com/docutag/textanalyzer/internal/models"
+)
+
+// DefaultReadingWPM is the default reading speed, in words per minute, behind
+// reading time estimates
+const DefaultReadingWPM = 230
+
+// Lexical measures
+const (
+	longWordRunes = 7    // Words of at least this many letters count as long
+	mtldThreshold = 0.72 // Type-token ratio at which an MTLD factor is complete
+)
+
+// SetReadingSpeed sets the words per minute reading time is estimated at.
+// Values of zero or less restore DefaultReadingWPM.
+func (a *Analyzer) SetReadingSpeed(wpm int) {
+	a.readingWPM = wpm
+}
+
+// applyLexicalMetrics sets the reading time, lexical diversity, stop word
+// ratio and long word ratio of metadata. words are the words already
+// extracted from the text, and metadata must already hold the word counts
+// and language.
+func (a *Analyzer) applyLexicalMetrics(metadata *models.Metadata, words []string) {
+	if len(words) == 0 {
+		return
+	}
+
+	wpm := a.readingWPM
+	if wpm <= 0 {
+		wpm = DefaultReadingWPM
+	}
+	metadata.ReadingTimeSeconds = int(math.Ceil(float64(len(words)) * 60 / float64(wpm)))
+
+	metadata.LexicalDiversity = float64(metadata.UniqueWords) / float64(len(words))
+	metadata.MTLD = mtld(words)
+
+	stop, long := 0, 0
+	for _, word := range words {
+		if a.isStopWord(word, metadata.Language) {
+			stop++
+		}
+		if utf8.RuneCountInString(word) >= longWordRunes {
+			long++
+		}
+	}
+	metadata.StopwordRatio = float64(stop) / float64(len(words))
+	metadata.LongWordRatio = float64(long) / float64(len(words))
+}
+
+// mtld returns the measure of textual lexical diversity of words: the mean
+// number of words it takes for the type-token ratio to fall to
+// mtldThreshold, averaged over a forward and a backward pass. Unlike the
+// type-token ratio it does not fall as texts grow longer. It returns 0 when
+// no word repeats.
+func mtld(words []string) float64 {
+	forward := mtldPass(words, false)
+	backward := mtldPass(words, true)
+	if forward == 0 || backward == 0 {
+		return 0
+	}
+	return (forward + backward) / 2
+}
+
+// mtldPass runs one MTLD pass over words, in reverse when backward is set. A
+// run left over at the end counts as the fraction of a factor its
+// type-token ratio has fallen towards the threshold.
+func mtldPass(words []string, backward bool) float64 {
+	types := make(map[string]struct{})
+	factors, tokens := 0.0, 0
+	ttr := 1.0
+	for i := range words {
+		word := words[i]
+		if backward {
+			word = words[len(words)-1-i]
+		}
+		tokens++
+		types[word] = struct{}{}
+		ttr = float64(len(types)) / float64(tokens)
+		if ttr <= mtldThreshold {
+			factors++
+			tokens = 0
+			clear(types)
+		}
+	}
+	if tokens > 0 {
+		factors += (1 - ttr) / (1 - mtldThreshold)
+	}
+	if factors == 0 {
+		return 0
+	}
+	return float64(len(words)) / factors
+}
@@ -0,0 +1,93 @@
+package analyzer
+
+import (
+	"math"
+	"testing"
+)
+
+// lexicalFixture has 10 words, 7 of them distinct. "the", "on" and "was" are
+// stop words (5 of the 10) and "elephant" is the only long word (2 of the 10).
+const lexicalFixture = "The elephant sat on the mat. The elephant was happy."
+
+func TestLexicalMetrics(t *testing.T) {
+	a := New()
+	metadata := a.AnalyzeOffline(lexicalFixture)
+
+	// 10 words at 230 words per minute is 2.6 seconds, rounded up
+	if metadata.ReadingTimeSeconds != 3 {
+		t.Errorf("Expected a reading time of 3 seconds, got %d", metadata.ReadingTimeSeconds)
+	}
+	if metadata.LexicalDiversity != 0.7 {
+		t.Errorf("Expected a lexical diversity of 0.7, got %v", metadata.LexicalDiversity)
+	}
+	// Forward, the ratio falls to 5/7 at the third "the", leaving "elephant
+	// was happy" with a ratio of 1; backward it falls to 7/10 at the last
+	// word. Both passes complete one factor over 10 words.
+	if metadata.MTLD != 10 {
+		t.Errorf("Expected an MTLD of 10, got %v", metadata.MTLD)
+	}
+	if metadata.StopwordRatio != 0.5 {
+		t.Errorf("Expected a stop word ratio of 0.5, got %v", metadata.StopwordRatio)
+	}
+	if metadata.LongWordRatio != 0.2 {
+		t.Errorf("Expected a long word ratio of 0.2, got %v", metadata.LongWordRatio)
+	}
+}
+
+func TestLexicalMetricsInBothPaths(t *testing.T) {
+	a := New()
+	offline := a.AnalyzeOffline(lexicalFixture)
+	online := a.Analyze(lexicalFixture)
+
+	if online.ReadingTimeSeconds != offline.ReadingTimeSeconds || online.LexicalDiversity != offline.LexicalDiversity ||
+		online.MTLD != offline.MTLD || online.StopwordRatio != offline.StopwordRatio ||
+		online.LongWordRatio != offline.LongWordRatio {
+		t.Errorf("Expected Analyze to match AnalyzeOffline, got %+v and %+v", online, offline)
+	}
+}
+
+func TestReadingSpeed(t *testing.T) {
+	a := New()
+	a.SetReadingSpeed(120)
+	if got := a.AnalyzeOffline(lexicalFixture).ReadingTimeSeconds; got != 5 {
+		t.Errorf("Expected 10 words at 120 words per minute to take 5 seconds, got %d", got)
+	}
+
+	a.SetReadingSpeed(0)
+	if got := a.AnalyzeOffline(lexicalFixture).ReadingTimeSeconds; got != 3 {
+		t.Errorf("Expected the default reading speed to be restored, got %d seconds", got)
+	}
+}
+
+func TestLexicalMetricsEmptyText(t *testing.T) {
+	metadata := New().AnalyzeOffline("")
+	if metadata.ReadingTimeSeconds != 0 || metadata.LexicalDiversity != 0 || metadata.MTLD != 0 ||
+		metadata.StopwordRatio != 0 || metadata.LongWordRatio != 0 {
+		t.Errorf("Expected no lexical metrics for empty text, got %+v", metadata)
+	}
+}
+
+func TestMTLD(t *testing.T) {
+	tests := []struct {
+		name  string
+		words []string
+		want  float64
+	}{
+		// Each pass completes a factor at every second word
+		{"pairs", []string{"a", "a", "b", "b"}, 2},
+		// Forward: one factor at the second "a" (2/3), then "c" left at a
+		// ratio of 1, so 4 words per factor. Backward: "c a b a" ends at
+		// 3/4, a partial factor of 0.25/0.28, so 4.48 words per factor.
+		{"partial factor", []string{"a", "b", "a", "c"}, (4 + 4/(0.25/0.28)) / 2},
+		{"no repeats", []string{"a", "b", "c"}, 0},
+		{"empty", nil, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mtld(tt.words); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("mtld(%v) = %v, want %v", tt.words, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,7 +1,7 @@
 package analyzer
 
-// getStopWords returns common English stop words
-func getStopWords() map[string]bool {
+// DefaultStopWords returns the built-in English stop words
+func DefaultStopWords() map[string]bool {
 	words := []string{
 		"a", "about", "above", "after", "again", "against", "all", "am", "an", "and", "any", "are", "aren't",
 		"as", "at", "be", "because", "been", "before", "being", "below", "between", "both", "but", "by",
@@ -27,6 +27,11 @@ func getStopWords() map[string]bool {
 	return stopWords
 }
 
+// DefaultSentimentLexicon returns the built-in English sentiment words
+func DefaultSentimentLexicon() SentimentLexicon {
+	return SentimentLexicon{Positive: getPositiveWords(), Negative: getNegativeWords()}
+}
+
 // getPositiveWords returns common positive sentiment words
 func getPositiveWords() map[string]bool {
 	words := []string{
@@ -62,3 +67,41 @@ func getNegativeWords() map[string]bool {
 	}
 	return negativeWords
 }
+
+// getLanguageProfiles returns, per language, frequent function words that
+// rarely occur in the other languages. They are used to detect the language
+// of a text from the share of its words each profile matches.
+func getLanguageProfiles() map[string]map[string]bool {
+	profiles := map[string][]string{
+		LanguageEnglish: {
+			"the", "and", "of", "to", "is", "that", "it", "for", "with", "was", "this", "are", "be", "by",
+			"have", "from", "or", "which", "they", "their", "has", "been", "were", "not", "but", "will",
+			"would", "there", "can", "an", "at", "its", "these", "who", "we", "you",
+		},
+		LanguageSpanish: {
+			"el", "los", "las", "del", "que", "y", "por", "una", "para", "con", "es", "al", "lo", "como",
+			"pero", "sus", "su", "fue", "este", "esta", "entre", "cuando", "muy", "sin", "sobre", "también",
+			"hasta", "hay", "donde", "desde", "todo", "nos", "durante", "ya", "porque", "ha",
+		},
+		LanguageFrench: {
+			"le", "les", "des", "est", "et", "du", "une", "dans", "qui", "pour", "pas", "sur", "au", "avec",
+			"ce", "il", "sont", "mais", "ou", "nous", "vous", "leur", "aux", "cette", "été", "ont", "ses",
+			"était", "comme", "plus", "elle", "très", "sans", "aussi", "fait", "être",
+		},
+		LanguageGerman: {
+			"der", "die", "und", "das", "ist", "nicht", "mit", "den", "von", "zu", "sich", "des", "auf", "für",
+			"dem", "ein", "eine", "auch", "es", "an", "wird", "im", "sind", "wie", "oder", "aber", "nach",
+			"bei", "einer", "um", "noch", "werden", "wurde", "hat", "ich", "wir",
+		},
+	}
+
+	result := make(map[string]map[string]bool, len(profiles))
+	for language, words := range profiles {
+		set := make(map[string]bool, len(words))
+		for _, word := range words {
+			set[word] = true
+		}
+		result[language] = set
+	}
+	return result
+}
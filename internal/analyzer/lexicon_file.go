@@ -0,0 +1,208 @@
+package analyzer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// Lexicon modes: words loaded from files are added to the built-in words
+// or used in place of them
+const (
+	LexiconMerge   = "merge"
+	LexiconReplace = "replace"
+)
+
+// ValidateLexiconMode returns an error unless mode is LexiconMerge or
+// LexiconReplace
+func ValidateLexiconMode(mode string) error {
+	if mode != LexiconMerge && mode != LexiconReplace {
+		return fmt.Errorf("lexicon mode must be %s or %s, got %q", LexiconMerge, LexiconReplace, mode)
+	}
+	return nil
+}
+
+// SentimentLexicon holds the words read as positive and as negative in
+// sentiment analysis
+type SentimentLexicon struct {
+	Positive map[string]bool
+	Negative map[string]bool
+}
+
+// Merge returns the words of l and other. A word in both takes its polarity
+// from other, so domain lexicons can turn a built-in word around.
+func (l SentimentLexicon) Merge(other SentimentLexicon) SentimentLexicon {
+	merged := SentimentLexicon{Positive: maps.Clone(l.Positive), Negative: maps.Clone(l.Negative)}
+	if merged.Positive == nil {
+		merged.Positive = map[string]bool{}
+	}
+	if merged.Negative == nil {
+		merged.Negative = map[string]bool{}
+	}
+	for word := range other.Positive {
+		merged.Positive[word] = true
+		delete(merged.Negative, word)
+	}
+	for word := range other.Negative {
+		merged.Negative[word] = true
+		delete(merged.Positive, word)
+	}
+	return merged
+}
+
+// MergeStopWords returns the words of base and extra
+func MergeStopWords(base, extra map[string]bool) map[string]bool {
+	merged := make(map[string]bool, len(base)+len(extra))
+	maps.Copy(merged, base)
+	maps.Copy(merged, extra)
+	return merged
+}
+
+// LoadStopWords reads stop words from a JSON array of words or a file of
+// one word per line, where blank lines and lines starting with # are skipped
+func LoadStopWords(path string) (map[string]bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stop words file: %w", err)
+	}
+
+	var words []string
+	if isJSON(data) {
+		if err := json.Unmarshal(data, &words); err != nil {
+			return nil, fmt.Errorf("failed to parse stop words file %s: expected a JSON array of words: %w", path, err)
+		}
+	} else {
+		err := eachLexiconLine(data, func(line int, fields []string) error {
+			if len(fields) != 1 {
+				return fmt.Errorf("line %d: expected one word, got %q", line, strings.Join(fields, " "))
+			}
+			words = append(words, fields[0])
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("invalid stop words file %s: %w", path, err)
+		}
+	}
+
+	stopWords := make(map[string]bool, len(words))
+	for _, word := range words {
+		word, err := lexiconWord(word)
+		if err != nil {
+			return nil, fmt.Errorf("invalid stop words file %s: %w", path, err)
+		}
+		stopWords[word] = true
+	}
+	if len(stopWords) == 0 {
+		return nil, fmt.Errorf("invalid stop words file %s: no words", path)
+	}
+	return stopWords, nil
+}
+
+// sentimentLexiconFile is the JSON form of a sentiment lexicon file
+type sentimentLexiconFile struct {
+	Positive []string `json:"positive"`
+	Negative []string `json:"negative"`
+}
+
+// LoadSentimentLexicon reads sentiment words from a JSON object with
+// "positive" and "negative" arrays of words, or a file of one word and its
+// polarity per line ("bullish positive"), where blank lines and lines
+// starting with # are skipped. Apostrophes are dropped from words, as they
+// are from the text scored.
+func LoadSentimentLexicon(path string) (SentimentLexicon, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return SentimentLexicon{}, fmt.Errorf("failed to read sentiment lexicon file: %w", err)
+	}
+
+	var file sentimentLexiconFile
+	if isJSON(data) {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&file); err != nil {
+			return SentimentLexicon{}, fmt.Errorf(`failed to parse sentiment lexicon file %s: expected a JSON object of "positive" and "negative" words: %w`, path, err)
+		}
+	} else {
+		err := eachLexiconLine(data, func(line int, fields []string) error {
+			if len(fields) != 2 {
+				return fmt.Errorf("line %d: expected a word and its polarity, got %q", line, strings.Join(fields, " "))
+			}
+			switch strings.ToLower(fields[1]) {
+			case "positive":
+				file.Positive = append(file.Positive, fields[0])
+			case "negative":
+				file.Negative = append(file.Negative, fields[0])
+			default:
+				return fmt.Errorf("line %d: polarity must be positive or negative, got %q", line, fields[1])
+			}
+			return nil
+		})
+		if err != nil {
+			return SentimentLexicon{}, fmt.Errorf("invalid sentiment lexicon file %s: %w", path, err)
+		}
+	}
+
+	lexicon := SentimentLexicon{Positive: map[string]bool{}, Negative: map[string]bool{}}
+	for _, list := range []struct {
+		words []string
+		into  map[string]bool
+	}{{file.Positive, lexicon.Positive}, {file.Negative, lexicon.Negative}} {
+		for _, word := range list.words {
+			word, err := lexiconWord(strings.NewReplacer("'", "", "’", "").Replace(word))
+			if err != nil {
+				return SentimentLexicon{}, fmt.Errorf("invalid sentiment lexicon file %s: %w", path, err)
+			}
+			list.into[word] = true
+		}
+	}
+	for word := range lexicon.Positive {
+		if lexicon.Negative[word] {
+			return SentimentLexicon{}, fmt.Errorf("invalid sentiment lexicon file %s: %q is both positive and negative", path, word)
+		}
+	}
+	if len(lexicon.Positive)+len(lexicon.Negative) == 0 {
+		return SentimentLexicon{}, fmt.Errorf("invalid sentiment lexicon file %s: no words", path)
+	}
+	return lexicon, nil
+}
+
+// isJSON reports whether a lexicon file holds JSON rather than lines of words
+func isJSON(data []byte) bool {
+	data = bytes.TrimSpace(data)
+	return len(data) > 0 && (data[0] == '[' || data[0] == '{')
+}
+
+// eachLexiconLine calls fn with the number and the fields of each line of
+// data that is not blank or a # comment
+func eachLexiconLine(data []byte, fn func(line int, fields []string) error) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if err := fn(line, strings.Fields(text)); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// lexiconWord lowercases a word read from a lexicon file, rejecting empty
+// words and words with spaces, which text is never split into
+func lexiconWord(word string) (string, error) {
+	word = strings.ToLower(strings.TrimSpace(word))
+	if word == "" {
+		return "", errors.New("empty word")
+	}
+	if strings.IndexFunc(word, unicode.IsSpace) >= 0 {
+		return "", fmt.Errorf("%q is not a single word", word)
+	}
+	return word, nil
+}
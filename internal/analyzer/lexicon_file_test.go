@@ -0,0 +1,125 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeLexicon writes content to a file named name in a temporary directory
+func writeLexicon(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadStopWords(t *testing.T) {
+	for name, content := range map[string]string{
+		"stopwords.json": `["Per", " Via ", "ibid"]`,
+		"stopwords.txt":  "# Citation words\nPer\n\n  via\nibid\n",
+	} {
+		words, err := LoadStopWords(writeLexicon(t, name, content))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		if len(words) != 3 || !words["per"] || !words["via"] || !words["ibid"] {
+			t.Errorf("%s: unexpected stop words: %v", name, words)
+		}
+	}
+
+	for name, content := range map[string]string{
+		"object.json": `{"per": true}`,
+		"broken.json": `["per",`,
+		"phrase.json": `["as per"]`,
+		"phrase.txt":  "per\nas per\n",
+		"empty.txt":   "# Nothing yet\n",
+	} {
+		if _, err := LoadStopWords(writeLexicon(t, name, content)); err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("%s: expected an error naming the file, got %v", name, err)
+		}
+	}
+	if _, err := LoadStopWords(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
+
+func TestLoadSentimentLexicon(t *testing.T) {
+	for name, content := range map[string]string{
+		"lexicon.json": `{"positive": ["Bullish", "outperform"], "negative": ["bearish", "can't"]}`,
+		"lexicon.txt":  "# Finance\nbullish positive\noutperform POSITIVE\nbearish negative\ncan't negative\n",
+	} {
+		lexicon, err := LoadSentimentLexicon(writeLexicon(t, name, content))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		if len(lexicon.Positive) != 2 || !lexicon.Positive["bullish"] || !lexicon.Positive["outperform"] {
+			t.Errorf("%s: unexpected positive words: %v", name, lexicon.Positive)
+		}
+		if len(lexicon.Negative) != 2 || !lexicon.Negative["bearish"] || !lexicon.Negative["cant"] {
+			t.Errorf("%s: unexpected negative words: %v", name, lexicon.Negative)
+		}
+	}
+
+	tests := map[string]struct {
+		content string
+		message string
+	}{
+		"unknown-key.json": {`{"positive": ["up"], "neutral": ["flat"]}`, "neutral"},
+		"list.json":        {`["up"]`, "JSON object"},
+		"both.json":        {`{"positive": ["volatile"], "negative": ["volatile"]}`, "both positive and negative"},
+		"polarity.txt":     {"up positive\nflat neutral\n", "line 2"},
+		"fields.txt":       {"up\n", "line 1"},
+		"empty.json":       {`{}`, "no words"},
+	}
+	for name, tt := range tests {
+		_, err := LoadSentimentLexicon(writeLexicon(t, name, tt.content))
+		if err == nil || !strings.Contains(err.Error(), name) || !strings.Contains(err.Error(), tt.message) {
+			t.Errorf("%s: expected an error naming the file and %q, got %v", name, tt.message, err)
+		}
+	}
+}
+
+func TestSentimentLexiconMerge(t *testing.T) {
+	base := SentimentLexicon{
+		Positive: map[string]bool{"good": true},
+		Negative: map[string]bool{"volatile": true, "bad": true},
+	}
+	merged := base.Merge(SentimentLexicon{Positive: map[string]bool{"volatile": true, "bullish": true}})
+
+	if !merged.Positive["good"] || !merged.Positive["bullish"] || !merged.Negative["bad"] {
+		t.Errorf("Expected the words of both lexicons, got %+v", merged)
+	}
+	if !merged.Positive["volatile"] || merged.Negative["volatile"] {
+		t.Errorf("Expected the merged lexicon's polarity to win, got %+v", merged)
+	}
+	if !base.Negative["volatile"] || base.Positive["bullish"] {
+		t.Errorf("Expected the base lexicon unchanged, got %+v", base)
+	}
+}
+
+func TestAnalyzerLexiconOptions(t *testing.T) {
+	text := "Analysts turned bullish as the shares rallied. Per the filing, outlook is bullish."
+
+	if metadata := New().Analyze(text); metadata.Sentiment != "neutral" {
+		t.Errorf("Expected built-in words to find no sentiment, got %s", metadata.Sentiment)
+	}
+
+	lexicon := DefaultSentimentLexicon().Merge(SentimentLexicon{Positive: map[string]bool{"bullish": true}})
+	a := New(WithSentimentLexicon(lexicon), WithStopWords(MergeStopWords(DefaultStopWords(), map[string]bool{"per": true})))
+	metadata := a.Analyze(text)
+	if metadata.Sentiment != "positive" {
+		t.Errorf("Expected the injected lexicon to read the text as positive, got %s", metadata.Sentiment)
+	}
+	for _, word := range metadata.TopWords {
+		if word.Word == "per" {
+			t.Errorf("Expected the injected stop word to be left out of top words, got %+v", metadata.TopWords)
+		}
+	}
+	if !a.stopWords["the"] {
+		t.Error("Expected merged stop words to keep the built-ins")
+	}
+}
@@ -0,0 +1,114 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/docutag/textanalyzer/internal/models"
+	"github.com/docutag/textanalyzer/internal/ollama"
+)
+
+// LLMClient is the language model behind AI enrichment. *ollama.Client
+// implements it against the Ollama API and *openai.Client against
+// OpenAI-compatible chat completion APIs; both share the prompts and the
+// parsing of responses.
+type LLMClient interface {
+	// Model returns the name of the model, recorded with enrichment results
+	Model() string
+
+	GenerateSynopsis(ctx context.Context, text, style string, maxWords int) (string, error)
+	CleanText(ctx context.Context, text string) (string, error)
+	CleanTextWithHTMLContext(ctx context.Context, text, offlineText, originalHTML string) (string, error)
+	EditorialAnalysis(ctx context.Context, text string) (*ollama.EditorialResult, error)
+	GenerateTags(ctx context.Context, text string, metadata map[string]interface{}) ([]string, error)
+	ExtractReferences(ctx context.Context, text string) ([]ollama.Reference, error)
+	DetectAIContent(ctx context.Context, text string) (*ollama.AIDetectionResult, error)
+	ScoreTextQuality(ctx context.Context, text string) (*ollama.TextQualityScoreResult, error)
+}
+
+var _ LLMClient = (*ollama.Client)(nil)
+
+// ImageDescriber captions and tags images with a vision model
+type ImageDescriber interface {
+	DescribeImage(ctx context.Context, imageData []byte) (caption string, tags []string, err error)
+}
+
+var _ ImageDescriber = (*ollama.Client)(nil)
+
+// TextChunker is implemented by LLM clients that split texts too long for
+// one prompt into chunks
+type TextChunker interface {
+	// ChunkCount returns how many chunks text is processed in, and whether
+	// text past the last chunk is dropped
+	ChunkCount(text string) (chunks int, truncated bool)
+}
+
+var _ TextChunker = (*ollama.Client)(nil)
+
+// applyChunking records in metadata whether the LLM client processes text
+// in chunks
+func (a *Analyzer) applyChunking(metadata *models.Metadata, text string) {
+	chunker, ok := a.llmClient.(TextChunker)
+	if !ok {
+		return
+	}
+	chunks, truncated := chunker.ChunkCount(text)
+	if chunks <= 1 {
+		return
+	}
+	metadata.Chunking = &models.Chunking{Chunks: chunks, Truncated: truncated}
+	slog.Info("text exceeds the prompt budget, processing it in chunks",
+		"chunks", chunks, "truncated", truncated)
+}
+
+// Translator is implemented by LLM clients that translate text
+type Translator interface {
+	// Translate translates text into targetLang, an ISO 639-1 code
+	Translate(ctx context.Context, text, targetLang string) (string, error)
+}
+
+var _ Translator = (*ollama.Client)(nil)
+
+// SetTranslateTo sets the language, as an ISO 639-1 code, that documents in
+// other languages are translated into before the synopsis, tags and
+// editorial analysis are generated. An empty language disables translation.
+func (a *Analyzer) SetTranslateTo(language string) {
+	a.translateTo = strings.ToLower(strings.TrimSpace(language))
+}
+
+// translateForEnrichment translates text for the synopsis, tags and
+// editorial analysis when the document is in another language than the
+// analyzer translates into, recording the translation in metadata. It
+// reports false when those steps should read text as is: translation is
+// disabled or not needed, the steps are disabled, or translating failed.
+func (a *Analyzer) translateForEnrichment(ctx context.Context, metadata *models.Metadata, steps models.EnrichmentOptions, text string) (string, bool) {
+	if a.translateTo == "" || !(steps.Synopsis || steps.Tags || steps.Editorial) {
+		return "", false
+	}
+	if metadata.Language == LanguageUnknown || metadata.Language == a.translateTo {
+		return "", false
+	}
+	// Cleaning may have translated the text already
+	if language, _ := detectLanguage(text); language == a.translateTo {
+		return "", false
+	}
+	translator, ok := a.llmClient.(Translator)
+	if !ok {
+		return "", false
+	}
+
+	slog.Info("translating text for AI enrichment", "from", metadata.Language, "to", a.translateTo)
+	translated, err := translator.Translate(ctx, text, a.translateTo)
+	if err == nil && strings.TrimSpace(translated) == "" {
+		err = fmt.Errorf("empty translation")
+	}
+	if err != nil {
+		slog.Warn("translation failed, analyzing the original text", "language", metadata.Language, "error", err)
+		return "", false
+	}
+	metadata.TranslatedText = translated
+	slog.Info("translation completed", "length", len(translated), "original_length", len(text))
+	return translated, true
+}
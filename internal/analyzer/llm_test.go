@@ -0,0 +1,368 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/docutag/textanalyzer/internal/models"
+	"github.com/docutag/textanalyzer/internal/ollama"
+)
+
+// mockLLM is an LLMClient with fixed results that fails the methods named in
+// fail, without any server
+type mockLLM struct {
+	fail map[string]bool
+}
+
+var errMockUnavailable = errors.New("mock model unavailable")
+
+const (
+	mockSynopsis  = "The council adds bus routes."
+	mockCleaned   = "The council adds twelve bus routes."
+	mockHTMLClean = "The council adds twelve bus routes in the east."
+	mockEditorial = "Informational reporting on local transit."
+	mockTag       = "mock-transit"
+	mockReference = "twelve new routes"
+)
+
+func (m *mockLLM) err(method string) error {
+	if m.fail[method] {
+		return errMockUnavailable
+	}
+	return nil
+}
+
+func (m *mockLLM) Model() string { return "mock-model" }
+
+func (m *mockLLM) GenerateSynopsis(ctx context.Context, text, style string, maxWords int) (string, error) {
+	return mockSynopsis, m.err("GenerateSynopsis")
+}
+
+func (m *mockLLM) CleanText(ctx context.Context, text string) (string, error) {
+	return mockCleaned, m.err("CleanText")
+}
+
+func (m *mockLLM) CleanTextWithHTMLContext(ctx context.Context, text, offlineText, originalHTML string) (string, error) {
+	return mockHTMLClean, m.err("CleanTextWithHTMLContext")
+}
+
+func (m *mockLLM) EditorialAnalysis(ctx context.Context, text string) (*ollama.EditorialResult, error) {
+	if err := m.err("EditorialAnalysis"); err != nil {
+		return nil, err
+	}
+	return &ollama.EditorialResult{Purpose: ollama.PurposeInformational, Bias: ollama.BiasNone, Tone: "neutral", Summary: mockEditorial}, nil
+}
+
+func (m *mockLLM) GenerateTags(ctx context.Context, text string, metadata map[string]interface{}) ([]string, error) {
+	return []string{mockTag}, m.err("GenerateTags")
+}
+
+func (m *mockLLM) ExtractReferences(ctx context.Context, text string) ([]ollama.Reference, error) {
+	return []ollama.Reference{{Text: mockReference, Type: "statistic", Context: "bus network", Confidence: "high"}}, m.err("ExtractReferences")
+}
+
+func (m *mockLLM) DetectAIContent(ctx context.Context, text string) (*ollama.AIDetectionResult, error) {
+	if err := m.err("DetectAIContent"); err != nil {
+		return nil, err
+	}
+	return &ollama.AIDetectionResult{Likelihood: "unlikely", Confidence: "high", HumanScore: 85}, nil
+}
+
+func (m *mockLLM) ScoreTextQuality(ctx context.Context, text string) (*ollama.TextQualityScoreResult, error) {
+	if err := m.err("ScoreTextQuality"); err != nil {
+		return nil, err
+	}
+	return &ollama.TextQualityScoreResult{Score: 0.8, Reason: "Clear local news."}, nil
+}
+
+func TestLLMClientFallbacks(t *testing.T) {
+	tests := []struct {
+		name   string
+		fail   []string
+		html   bool // Analyze with HTML context
+		step   string
+		status string
+		check  func(t *testing.T, metadata models.Metadata)
+	}{
+		{"synopsis", []string{"GenerateSynopsis"}, false, StepSynopsis, StepStatusFallback, func(t *testing.T, m models.Metadata) {
+			if m.Synopsis == "" || m.Synopsis == mockSynopsis {
+				t.Errorf("Expected an extractive synopsis, got %q", m.Synopsis)
+			}
+		}},
+		{"clean", []string{"CleanText"}, false, StepClean, StepStatusFailed, func(t *testing.T, m models.Metadata) {
+			if m.CleanedText != "" {
+				t.Errorf("Expected no cleaned text, got %q", m.CleanedText)
+			}
+		}},
+		{"clean with HTML falls back to clean", []string{"CleanTextWithHTMLContext"}, true, StepClean, StepStatusDone, func(t *testing.T, m models.Metadata) {
+			if m.CleanedText != mockCleaned {
+				t.Errorf("Expected standard cleaning, got %q", m.CleanedText)
+			}
+		}},
+		{"clean with HTML", []string{"CleanTextWithHTMLContext", "CleanText"}, true, StepClean, StepStatusFailed, func(t *testing.T, m models.Metadata) {
+			if m.CleanedText != "" {
+				t.Errorf("Expected no cleaned text, got %q", m.CleanedText)
+			}
+		}},
+		{"editorial", []string{"EditorialAnalysis"}, false, StepEditorial, StepStatusFailed, func(t *testing.T, m models.Metadata) {
+			if m.EditorialAnalysis != "" || m.Editorial != nil {
+				t.Errorf("Expected no editorial analysis, got %q and %+v", m.EditorialAnalysis, m.Editorial)
+			}
+		}},
+		{"tags", []string{"GenerateTags"}, true, StepTags, StepStatusFallback, func(t *testing.T, m models.Metadata) {
+			if len(m.Tags) == 0 || containsStringSlice(m.Tags, mockTag) {
+				t.Errorf("Expected computed tags only, got %v", m.Tags)
+			}
+		}},
+		{"references", []string{"ExtractReferences"}, false, StepReferences, StepStatusFallback, func(t *testing.T, m models.Metadata) {
+			for _, ref := range m.References {
+				if ref.Text == mockReference {
+					t.Errorf("Expected rule-based references, got %+v", m.References)
+				}
+			}
+		}},
+		{"ai detection", []string{"DetectAIContent"}, true, StepAIDetection, StepStatusFailed, func(t *testing.T, m models.Metadata) {
+			if m.AIDetection.Likelihood != "" {
+				t.Errorf("Expected no AI detection, got %+v", m.AIDetection)
+			}
+		}},
+		{"quality", []string{"ScoreTextQuality"}, false, StepQuality, StepStatusFallback, func(t *testing.T, m models.Metadata) {
+			if m.QualityScore == nil || m.QualityScore.AIUsed {
+				t.Errorf("Expected a rule-based quality score, got %+v", m.QualityScore)
+			}
+		}},
+		{"quality with HTML", []string{"ScoreTextQuality"}, true, StepQuality, StepStatusFallback, func(t *testing.T, m models.Metadata) {
+			if m.QualityScore == nil || m.QualityScore.AIUsed {
+				t.Errorf("Expected a rule-based quality score, got %+v", m.QualityScore)
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			llm := &mockLLM{fail: map[string]bool{}}
+			for _, method := range tt.fail {
+				llm.fail[method] = true
+			}
+			a := NewWithOllama(llm)
+
+			var metadata models.Metadata
+			if tt.html {
+				metadata = a.AnalyzeWithHTMLContext(context.Background(), synopsisFixture, synopsisFixture, "<p>html</p>", AnalysisOptions{})
+			} else {
+				metadata = a.AnalyzeWithOptions(context.Background(), synopsisFixture, AnalysisOptions{})
+			}
+
+			for _, step := range EnrichmentSteps {
+				want := StepStatusDone
+				if step == tt.step {
+					want = tt.status
+				}
+				if metadata.EnrichmentStatus[step] != want {
+					t.Errorf("Expected %s to be %q, got %q", step, want, metadata.EnrichmentStatus[step])
+				}
+			}
+			tt.check(t, metadata)
+
+			// The other steps keep the model's results
+			if tt.step != StepEditorial && (metadata.EditorialAnalysis != mockEditorial || metadata.Editorial == nil || metadata.Editorial.Purpose != ollama.PurposeInformational) {
+				t.Errorf("Expected the model's editorial analysis, got %q and %+v", metadata.EditorialAnalysis, metadata.Editorial)
+			}
+			if tt.step != StepTags && !containsStringSlice(metadata.Tags, mockTag) {
+				t.Errorf("Expected the model's tags, got %v", metadata.Tags)
+			}
+		})
+	}
+}
+
+func TestLLMClientModelName(t *testing.T) {
+	if got := NewWithOllama(&mockLLM{}).ModelName(); got != "mock-model" {
+		t.Errorf("Expected model %q, got %q", "mock-model", got)
+	}
+	if got := New().ModelName(); got != "" {
+		t.Errorf("Expected no model, got %q", got)
+	}
+}
+
+func TestAnalyzeChunkedText(t *testing.T) {
+	var mu sync.Mutex
+	var prompts []string
+	llm := ollama.NewWithGenerator("chunking-model", func(ctx context.Context, prompt string) (string, error) {
+		mu.Lock()
+		prompts = append(prompts, prompt)
+		mu.Unlock()
+		return `{"tags": ["transit"], "references": [], "score": 0.8}`, nil
+	})
+	a := NewWithOllama(llm)
+
+	var b strings.Builder
+	for i := 0; b.Len() < 100000; i++ {
+		fmt.Fprintf(&b, "The council debated route %d of the transit plan at length. ", i)
+		if i%6 == 5 {
+			b.WriteString("\n\n")
+		}
+	}
+	metadata := a.AnalyzeWithOptions(context.Background(), b.String(), AnalysisOptions{})
+
+	if metadata.Chunking == nil || metadata.Chunking.Chunks < 2 || metadata.Chunking.Truncated {
+		t.Errorf("Expected chunking to be recorded, got %+v", metadata.Chunking)
+	}
+	if metadata.AIModel != "chunking-model" {
+		t.Errorf("Expected the answering model to be recorded, got %q", metadata.AIModel)
+	}
+	if len(prompts) == 0 {
+		t.Fatal("Expected prompts to be sent")
+	}
+	// No prompt holds more than a chunk of text besides its instructions
+	const promptOverhead = 2500
+	for i, prompt := range prompts {
+		if n := utf8.RuneCountInString(prompt); n > ollama.DefaultChunkBudget+promptOverhead {
+			t.Errorf("Prompt %d has %d characters, over the chunk budget", i, n)
+		}
+	}
+
+	// Texts that fit in one prompt, and clients that do not chunk, record none
+	if metadata := a.AnalyzeWithOptions(context.Background(), synopsisFixture, AnalysisOptions{}); metadata.Chunking != nil {
+		t.Errorf("Expected no chunking for a short text, got %+v", metadata.Chunking)
+	}
+	if metadata := NewWithOllama(&mockLLM{}).AnalyzeWithOptions(context.Background(), b.String(), AnalysisOptions{}); metadata.Chunking != nil {
+		t.Errorf("Expected no chunking without a chunking client, got %+v", metadata.Chunking)
+	}
+}
+
+// translatingLLM is a mockLLM that translates text and records the text
+// each step was given
+type translatingLLM struct {
+	mockLLM
+
+	mu         sync.Mutex
+	translated []string          // Texts Translate was given
+	received   map[string]string // Text given to each step
+}
+
+const mockTranslation = "The city council approved the new transit plan on Tuesday."
+
+func (m *translatingLLM) record(step, text string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.received == nil {
+		m.received = map[string]string{}
+	}
+	m.received[step] = text
+}
+
+func (m *translatingLLM) Translate(ctx context.Context, text, targetLang string) (string, error) {
+	m.mu.Lock()
+	m.translated = append(m.translated, text)
+	m.mu.Unlock()
+	if targetLang != LanguageEnglish {
+		return "", fmt.Errorf("unexpected target language %q", targetLang)
+	}
+	return mockTranslation, m.err("Translate")
+}
+
+func (m *translatingLLM) GenerateSynopsis(ctx context.Context, text, style string, maxWords int) (string, error) {
+	m.record(StepSynopsis, text)
+	return m.mockLLM.GenerateSynopsis(ctx, text, style, maxWords)
+}
+
+func (m *translatingLLM) EditorialAnalysis(ctx context.Context, text string) (*ollama.EditorialResult, error) {
+	m.record(StepEditorial, text)
+	return m.mockLLM.EditorialAnalysis(ctx, text)
+}
+
+func (m *translatingLLM) GenerateTags(ctx context.Context, text string, metadata map[string]interface{}) ([]string, error) {
+	m.record(StepTags, text)
+	return m.mockLLM.GenerateTags(ctx, text, metadata)
+}
+
+func (m *translatingLLM) ExtractReferences(ctx context.Context, text string) ([]ollama.Reference, error) {
+	m.record(StepReferences, text)
+	return m.mockLLM.ExtractReferences(ctx, text)
+}
+
+func TestTranslateForEnrichment(t *testing.T) {
+	ctx := context.Background()
+	withoutCleaning := AllEnrichmentSteps()
+	withoutCleaning.Clean = false
+	opts := AnalysisOptions{Enrichment: &withoutCleaning}
+
+	llm := &translatingLLM{}
+	a := NewWithOllama(llm)
+	a.SetTranslateTo(" EN ")
+	metadata := a.AnalyzeWithHTMLContext(ctx, frenchLanguageFixture, frenchLanguageFixture, "<p>html</p>", opts)
+
+	if len(llm.translated) != 1 || llm.translated[0] != frenchLanguageFixture {
+		t.Fatalf("Expected the French text to be translated once, got %q", llm.translated)
+	}
+	if metadata.TranslatedText != mockTranslation {
+		t.Errorf("Expected the translation to be stored, got %q", metadata.TranslatedText)
+	}
+	// The synopsis, tags and editorial analysis read the translation, the
+	// other steps and the statistics the original
+	for _, step := range []string{StepSynopsis, StepTags, StepEditorial} {
+		if llm.received[step] != mockTranslation {
+			t.Errorf("Expected %s to read the translation, got %q", step, llm.received[step])
+		}
+	}
+	if llm.received[StepReferences] != frenchLanguageFixture {
+		t.Errorf("Expected references to be extracted from the original, got %q", llm.received[StepReferences])
+	}
+	if metadata.Language != LanguageFrench || metadata.WordCount != len(extractWords(frenchLanguageFixture)) {
+		t.Errorf("Expected statistics of the original text, got language %q and %d words", metadata.Language, metadata.WordCount)
+	}
+
+	// The cleaned text is translated when there is one
+	cleaning := &translatingLLM{}
+	a = NewWithOllama(cleaning)
+	a.SetTranslateTo("en")
+	a.AnalyzeWithOptions(ctx, frenchLanguageFixture, AnalysisOptions{})
+	if len(cleaning.translated) != 1 || cleaning.translated[0] != mockCleaned {
+		t.Errorf("Expected the cleaned text to be translated, got %q", cleaning.translated)
+	}
+}
+
+func TestTranslateForEnrichmentFallbacks(t *testing.T) {
+	ctx := context.Background()
+	englishText := synopsisFixture + "\n\n" + synopsisFixture
+
+	// A failed translation leaves the steps reading the original
+	failing := &translatingLLM{mockLLM: mockLLM{fail: map[string]bool{"Translate": true}}}
+	a := NewWithOllama(failing)
+	a.SetTranslateTo("en")
+	metadata := a.AnalyzeWithHTMLContext(ctx, frenchLanguageFixture, frenchLanguageFixture, "", AnalysisOptions{})
+	if metadata.TranslatedText != "" || failing.received[StepSynopsis] != mockHTMLClean {
+		t.Errorf("Expected the synopsis of the cleaned text without a translation, got %q and %q",
+			failing.received[StepSynopsis], metadata.TranslatedText)
+	}
+	if metadata.EnrichmentStatus[StepSynopsis] != StepStatusDone {
+		t.Errorf("Expected a failed translation not to fail the synopsis, got %q", metadata.EnrichmentStatus[StepSynopsis])
+	}
+
+	tests := []struct {
+		name        string
+		translateTo string
+		text        string
+		steps       models.EnrichmentOptions
+	}{
+		{"disabled", "", frenchLanguageFixture, AllEnrichmentSteps()},
+		{"same language", "en", englishText, AllEnrichmentSteps()},
+		{"steps disabled", "en", frenchLanguageFixture, models.EnrichmentOptions{References: true, Quality: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			llm := &translatingLLM{}
+			a := NewWithOllama(llm)
+			a.SetTranslateTo(tt.translateTo)
+			metadata := a.AnalyzeWithHTMLContext(ctx, tt.text, tt.text, "", AnalysisOptions{Enrichment: &tt.steps})
+			if len(llm.translated) != 0 || metadata.TranslatedText != "" {
+				t.Errorf("Expected no translation, got %q", llm.translated)
+			}
+		})
+	}
+}
@@ -0,0 +1,488 @@
+package analyzer
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Formats a text can be submitted in
+const (
+	FormatAuto     = "auto"     // Read as Markdown when it looks like Markdown
+	FormatMarkdown = "markdown" // Strip Markdown syntax and code blocks before analysis
+	FormatText     = "text"     // Analyze as written
+)
+
+// Formats are the formats a text can be submitted in
+var Formats = []string{FormatAuto, FormatMarkdown, FormatText}
+
+// ValidateFormat returns an error unless format is empty or one of Formats
+func ValidateFormat(format string) error {
+	if format != "" && !slices.Contains(Formats, format) {
+		return fmt.Errorf("format must be one of %s, got %q", strings.Join(Formats, ", "), format)
+	}
+	return nil
+}
+
+// preparedText is a text as rule-based analysis reads it
+type preparedText struct {
+	// The text, with any Markdown syntax and code blocks replaced by
+	// spaces, so it has the same length in runes and the same offsets
+	text string
+	// The text with the syntax and code blocks removed rather than blanked,
+	// for the checks that read whitespace
+	plain      string
+	original   string   // The text as submitted, for the personal data checks
+	markdown   bool     // Whether the text was read as Markdown
+	links      []string // Link and image targets and HTML tags removed from text
+	codeBlocks int      // Fenced and indented code blocks removed from text
+}
+
+// urlSource returns the text URLs are extracted from: the prepared text
+// and the link targets removed from it, one per line
+func (p preparedText) urlSource() string {
+	if len(p.links) == 0 {
+		return p.text
+	}
+	return p.text + "\n" + strings.Join(p.links, "\n")
+}
+
+// prepareText prepares text for rule-based analysis in format, one of
+// Formats or empty for FormatAuto
+func prepareText(text, format string) preparedText {
+	if format == FormatText || format != FormatMarkdown && !looksLikeMarkdown(text) {
+		return preparedText{text: text, plain: text, original: text}
+	}
+	return stripMarkdown(text)
+}
+
+// Markdown constructs looksLikeMarkdown counts. Code fences settle it; the
+// strong signals are rare in plain text, the weak ones are not.
+var (
+	markdownFencePattern = regexp.MustCompile("(?m)^ {0,3}(?:```|~~~)")
+
+	markdownStrongSignals = []*regexp.Regexp{
+		regexp.MustCompile(`(?m)^ {0,3}#{1,6}[ \t]+\S`),        // ATX heading
+		regexp.MustCompile(`!?\[[^\[\]\n]+\]\([^()\s]+\)`),     // Inline link or image
+		regexp.MustCompile("`[^`\n]+`"),                        // Code span
+		regexp.MustCompile(`\*\*[^*\s](?:[^*\n]*[^*\s])?\*\*`), // Strong emphasis
+	}
+	markdownWeakSignals = []*regexp.Regexp{
+		regexp.MustCompile(`(?m)^[ \t]*[-*+][ \t]+\S`), // Bullet list item
+		regexp.MustCompile(`(?m)^ {0,3}>[ \t]`),        // Block quote
+	}
+)
+
+// looksLikeMarkdown reports whether text has a code fence, two kinds of
+// strong Markdown signal, or a strong and a weak one
+func looksLikeMarkdown(text string) bool {
+	if markdownFencePattern.MatchString(text) {
+		return true
+	}
+	strong, weak := 0, 0
+	for _, pattern := range markdownStrongSignals {
+		if pattern.MatchString(text) {
+			strong++
+		}
+	}
+	for _, pattern := range markdownWeakSignals {
+		if pattern.MatchString(text) {
+			weak++
+		}
+	}
+	return strong >= 2 || strong >= 1 && weak >= 1
+}
+
+// Markdown syntax stripMarkdown removes outside code blocks
+var (
+	// Block syntax at the start of lines
+	headingMarkerPattern  = regexp.MustCompile(`(?m)^ {0,3}#{1,6}(?:[ \t]|\r?$)`)
+	thematicBreakPattern  = regexp.MustCompile(`(?m)^ {0,3}(?:[-*_=][ \t]*){3,}\r?$`)
+	blockQuotePattern     = regexp.MustCompile(`(?m)^ {0,3}((?:>[ \t]?)+)`)
+	listMarkerPattern     = regexp.MustCompile(`(?m)^[ \t]*((?:[-*+]|\d{1,9}[.)])[ \t]+(?:\[[ xX]\][ \t]+)?)`)
+	tableDelimiterPattern = regexp.MustCompile(`(?m)^[ \t]*\|?(?:[ \t]*:?-+:?[ \t]*\|)+(?:[ \t]*:?-+:?[ \t]*)?\r?$`)
+	tableRowPattern       = regexp.MustCompile(`(?m)^[ \t]*\|.*$`)
+	linkDefinitionPattern = regexp.MustCompile(`(?m)^ {0,3}\[[^\]\n]+\]:[ \t]*<?([^\s>]+)>?.*$`)
+
+	// Inline syntax
+	codeSpanPatterns = []*regexp.Regexp{
+		regexp.MustCompile("(``)[^\n]+?(``)"),
+		regexp.MustCompile("(`)[^`\n]+(`)"),
+	}
+	imagePattern         = regexp.MustCompile(`(!\[)[^\[\]\n]*(\]\(([^()\s]*)(?:[ \t]+"[^"\n]*")?\))`)
+	linkPattern          = regexp.MustCompile(`(\[)[^\[\]\n]+(\]\(([^()\s]*)(?:[ \t]+"[^"\n]*")?\))`)
+	referenceLinkPattern = regexp.MustCompile(`(\[)[^\[\]\n]+(\]\[[^\[\]\n]*\])`)
+	autolinkPattern      = regexp.MustCompile(`<(?:https?|ftp)://[^<>\s]+>`)
+	emailAutolinkPattern = regexp.MustCompile(`(<)[^<>\s@]+@[^<>\s]+(>)`)
+	htmlCommentPattern   = regexp.MustCompile(`<!--[\s\S]*?-->`)
+	inlineHTMLPattern    = regexp.MustCompile(`</?[A-Za-z][A-Za-z0-9-]*(?:\s[^<>]*)?/?>`)
+	emphasisPatterns     = []*regexp.Regexp{
+		regexp.MustCompile(`(\*\*)[^*\s](?:[^*\n]*[^*\s])?(\*\*)`),
+		regexp.MustCompile(`(?:^|\W)(__)[^_\s](?:[^_\n]*[^_\s])?(__)(?:\W|$)`),
+		regexp.MustCompile(`(~~)[^~\s](?:[^~\n]*[^~\s])?(~~)`),
+		regexp.MustCompile(`(\*)[^*\s](?:[^*\n]*[^*\s])?(\*)`),
+		regexp.MustCompile(`(?:^|\W)(_)[^_\s](?:[^_\n]*[^_\s])?(_)(?:\W|$)`),
+	}
+)
+
+// markdownStripper blanks out the Markdown syntax of a text
+type markdownStripper struct {
+	text  string
+	buf   []byte // text with the syntax blanked so far, which patterns match
+	mask  []bool // Bytes of text to replace with spaces
+	links []string
+
+	// Byte offsets of the lines that start a block, and of headings
+	blockLines, headingLines map[int]bool
+}
+
+// blank marks text[start:end] for replacement with spaces, keeping line
+// breaks
+func (s *markdownStripper) blank(start, end int) {
+	for i := start; i < end; i++ {
+		if s.buf[i] != '\n' {
+			s.buf[i] = ' '
+			s.mask[i] = true
+		}
+	}
+}
+
+// blankMatches blanks the given groups of the matches of pattern, or the
+// whole matches when no group is given, and reports whether any matched
+func (s *markdownStripper) blankMatches(pattern *regexp.Regexp, groups ...int) bool {
+	matches := pattern.FindAllSubmatchIndex(s.buf, -1)
+	for _, m := range matches {
+		if len(groups) == 0 {
+			s.blank(m[0], m[1])
+		}
+		for _, g := range groups {
+			if m[2*g] >= 0 {
+				s.blank(m[2*g], m[2*g+1])
+			}
+		}
+	}
+	return len(matches) > 0
+}
+
+// markLines records the lines the matches of the line-anchored pattern
+// start on in lines
+func (s *markdownStripper) markLines(pattern *regexp.Regexp, lines map[int]bool) {
+	for _, m := range pattern.FindAllIndex(s.buf, -1) {
+		lines[m[0]] = true
+	}
+}
+
+// collectLinks records the given group of the matches of pattern as links,
+// group 0 being the whole match
+func (s *markdownStripper) collectLinks(pattern *regexp.Regexp, group int) {
+	for _, m := range pattern.FindAllSubmatchIndex(s.buf, -1) {
+		if m[2*group+1] > m[2*group] {
+			s.links = append(s.links, s.text[m[2*group]:m[2*group+1]])
+		}
+	}
+}
+
+// stripMarkdown prepares Markdown text for analysis: code blocks and
+// syntax are replaced by spaces, leaving headings, link text, image
+// descriptions and the content of code spans, and link targets are kept
+// for URL extraction
+func stripMarkdown(text string) preparedText {
+	s := &markdownStripper{
+		text:         text,
+		buf:          []byte(text),
+		mask:         make([]bool, len(text)),
+		blockLines:   make(map[int]bool),
+		headingLines: make(map[int]bool),
+	}
+
+	codeBlocks := codeBlockSpans(text)
+	for _, span := range codeBlocks {
+		s.blank(span.start, span.end)
+	}
+
+	// Block syntax, with link definitions and delimiter rows dropped whole.
+	// Heading markers are words to no statistic and stay for splitSections.
+	s.markLines(headingMarkerPattern, s.headingLines)
+	for _, pattern := range []*regexp.Regexp{linkDefinitionPattern, headingMarkerPattern, thematicBreakPattern, tableRowPattern, listMarkerPattern} {
+		s.markLines(pattern, s.blockLines)
+	}
+	s.collectLinks(linkDefinitionPattern, 1)
+	s.blankMatches(linkDefinitionPattern)
+	s.blankMatches(thematicBreakPattern)
+	s.blankMatches(tableDelimiterPattern)
+	for _, m := range tableRowPattern.FindAllIndex(s.buf, -1) {
+		for i := m[0]; i < m[1]; i++ {
+			if s.buf[i] == '|' {
+				s.blank(i, i+1)
+			}
+		}
+	}
+	s.blankMatches(blockQuotePattern, 1)
+	s.blankMatches(listMarkerPattern, 1)
+
+	// Inline syntax. Images come before links so the images in linked
+	// badges leave plain link text behind.
+	for _, pattern := range codeSpanPatterns {
+		s.blankMatches(pattern, 1, 2)
+	}
+	for _, pattern := range []*regexp.Regexp{imagePattern, linkPattern} {
+		s.collectLinks(pattern, 3)
+		s.blankMatches(pattern, 1, 2)
+	}
+	s.blankMatches(referenceLinkPattern, 1, 2)
+	s.collectLinks(autolinkPattern, 0)
+	s.blankMatches(autolinkPattern)
+	s.blankMatches(emailAutolinkPattern, 1, 2)
+	s.blankMatches(htmlCommentPattern)
+	s.collectLinks(inlineHTMLPattern, 0)
+	s.blankMatches(inlineHTMLPattern)
+	// Emphasis patterns consume the characters around their matches, so
+	// adjacent spans are only found on a later pass
+	for _, pattern := range emphasisPatterns {
+		for s.blankMatches(pattern, 1, 2) {
+		}
+	}
+
+	stripped, plain := s.result()
+	return preparedText{
+		text:       stripped,
+		plain:      plain,
+		original:   text,
+		markdown:   true,
+		links:      s.links,
+		codeBlocks: len(codeBlocks),
+	}
+}
+
+// strippedLine is a line of stripped Markdown
+type strippedLine struct {
+	start, end int  // Rune offsets, the line break excluded
+	block      bool // Whether the line starts a list item, table row or other block
+	heading    bool // Whether the line is an ATX heading
+}
+
+// blankLinesPattern matches runs of lines left empty by removed code blocks
+var blankLinesPattern = regexp.MustCompile(`\n[ \t]*\n(?:[ \t]*\n)+`)
+
+// result returns the text with its syntax blanked and removed. Blocks are
+// ended as sentences by endSentences.
+func (s *markdownStripper) result() (stripped, plain string) {
+	runes := make([]rune, 0, len(s.text))
+	// Blanked runes outside headings, which endSentences may overwrite
+	free := make([]bool, 0, len(s.text))
+	var lines []strippedLine
+	for i, r := range s.text {
+		if i == 0 || s.text[i-1] == '\n' {
+			if n := len(lines); n > 0 {
+				lines[n-1].end = len(runes) - 1
+			}
+			lines = append(lines, strippedLine{start: len(runes), block: s.blockLines[i], heading: s.headingLines[i]})
+		}
+		if s.mask[i] {
+			r = ' '
+		}
+		runes = append(runes, r)
+		free = append(free, s.mask[i] && !lines[len(lines)-1].heading)
+	}
+	if n := len(lines); n > 0 {
+		lines[n-1].end = len(runes)
+	}
+	endSentences(runes, free, lines)
+
+	// The plain text drops the blanked runes, the spaces they leave doubled
+	// and trailing spaces, and moves the periods endSentences added up to
+	// their text
+	kept := make([]rune, 0, len(runes))
+	trimTrailingSpaces := func() {
+		for len(kept) > 0 && (kept[len(kept)-1] == ' ' || kept[len(kept)-1] == '\t') {
+			kept = kept[:len(kept)-1]
+		}
+	}
+	pos, dropped := 0, false
+	for i := range s.text {
+		r := runes[pos]
+		pos++
+		switch {
+		case !s.mask[i]:
+			if r == ' ' && dropped && (len(kept) == 0 || unicode.IsSpace(kept[len(kept)-1])) {
+				continue
+			}
+			if r == '\n' {
+				trimTrailingSpaces()
+			}
+		case r == ' ':
+			dropped = true
+			continue
+		case r == '.':
+			end := len(kept)
+			for end > 0 && unicode.IsSpace(kept[end-1]) {
+				end--
+			}
+			kept = append(kept[:end], append([]rune{'.'}, kept[end:]...)...)
+			continue
+		}
+		dropped = false
+		kept = append(kept, r)
+	}
+	trimTrailingSpaces()
+	plain = blankLinesPattern.ReplaceAllString(string(kept), "\n\n")
+	return string(runes), plain
+}
+
+// endSentences ends the lines that close a block without closing
+// punctuation with a period, so list items, table rows and the text before
+// a code block are not read as one sentence with the text after them. The
+// period takes the place of a free rune after the line, or the line moves
+// back one rune into a free rune before its end. Headings are left for
+// splitSections to find.
+func endSentences(runes []rune, free []bool, lines []strippedLine) {
+	for n, line := range lines {
+		last := line.end - 1
+		for last >= line.start && unicode.IsSpace(runes[last]) {
+			last--
+		}
+		if line.heading || last < line.start || strings.ContainsRune(".!?", runes[last]) {
+			continue
+		}
+		if n+1 < len(lines) && !lines[n+1].block && !isBlankLine(runes[lines[n+1].start:lines[n+1].end]) {
+			continue
+		}
+
+		// A free rune before the text that follows
+		next := last + 1
+		for next < len(runes) && unicode.IsSpace(runes[next]) && !free[next] {
+			next++
+		}
+		if next < len(runes) && free[next] {
+			runes[next], free[next] = '.', false
+			continue
+		}
+
+		for p := line.start; p < last; p++ {
+			if free[p] {
+				copy(runes[p:last], runes[p+1:last+1])
+				copy(free[p:last], free[p+1:last+1])
+				runes[last], free[last] = '.', false
+				break
+			}
+		}
+	}
+}
+
+// isBlankLine reports whether line holds only whitespace
+func isBlankLine(line []rune) bool {
+	for _, r := range line {
+		if !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// codeBlockSpans finds the fenced and indented code blocks in Markdown
+// text, fences included. An unclosed fence runs to the end of the text. An
+// indented block follows a blank line outside a list; indented lines in
+// lists and after paragraph lines continue those instead.
+func codeBlockSpans(text string) []byteSpan {
+	var spans []byteSpan
+	var fence string     // Opening fence of the open fenced block
+	open, last := -1, -1 // Start of the open block, and end of its last code line
+	prevBlank, inList := true, false
+
+	for start := 0; start <= len(text); {
+		end := strings.IndexByte(text[start:], '\n')
+		if end < 0 {
+			end = len(text)
+		} else {
+			end += start
+		}
+		line := strings.TrimRight(text[start:end], "\r")
+		blank := strings.TrimSpace(line) == ""
+
+		switch {
+		case fence != "":
+			if closesFence(line, fence) {
+				spans = append(spans, byteSpan{open, end})
+				fence, open = "", -1
+			}
+		case blank:
+			// Blank lines inside an indented block are part of it when
+			// another indented line follows
+		case isIndented(line) && (open >= 0 || prevBlank && !inList):
+			if open < 0 {
+				open = start
+			}
+			last = end
+		default:
+			if open >= 0 {
+				spans = append(spans, byteSpan{open, last})
+				open = -1
+			}
+			if f := openingFence(line); f != "" {
+				fence, open = f, start
+				break
+			}
+			if !isIndented(line) {
+				inList = listMarkerPattern.MatchString(line)
+			}
+		}
+		prevBlank = blank
+
+		if end == len(text) {
+			break
+		}
+		start = end + 1
+	}
+
+	switch {
+	case fence != "":
+		spans = append(spans, byteSpan{open, len(text)})
+	case open >= 0:
+		spans = append(spans, byteSpan{open, last})
+	}
+	return spans
+}
+
+// isIndented reports whether line is indented enough to be code: four
+// spaces or a tab
+func isIndented(line string) bool {
+	return strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "\t")
+}
+
+// openingFence returns the fence line opens a fenced code block with, three
+// or more backticks or tildes indented by up to three spaces, or an empty
+// string when it opens none. Backtick fences take no backticks after them.
+func openingFence(line string) string {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 || trimmed == "" || trimmed[0] != '`' && trimmed[0] != '~' {
+		return ""
+	}
+	n := len(trimmed) - len(strings.TrimLeft(trimmed, trimmed[:1]))
+	if n < 3 || trimmed[0] == '`' && strings.Contains(trimmed[n:], "`") {
+		return ""
+	}
+	return trimmed[:n]
+}
+
+// closesFence reports whether line closes the fenced code block opened by
+// fence: a run of the same character at least as long, alone on the line
+func closesFence(line, fence string) bool {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 {
+		return false
+	}
+	trimmed = strings.TrimRight(trimmed, " \t")
+	return len(trimmed) >= len(fence) && strings.Trim(trimmed, fence[:1]) == ""
+}
+
+// isCodeChunk reports whether chunk lies within one of the code block spans
+func isCodeChunk(chunk byteSpan, spans []byteSpan) bool {
+	for _, span := range spans {
+		if chunk.start >= span.start && chunk.end <= span.end {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,261 @@
+package analyzer
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestValidateFormat(t *testing.T) {
+	for _, format := range []string{"", FormatAuto, FormatMarkdown, FormatText} {
+		if err := ValidateFormat(format); err != nil {
+			t.Errorf("ValidateFormat(%q) returned %v", format, err)
+		}
+	}
+	for _, format := range []string{"html", "Markdown", "md"} {
+		if err := ValidateFormat(format); err == nil {
+			t.Errorf("Expected an error for format %q", format)
+		}
+	}
+}
+
+func TestLooksLikeMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want bool
+	}{
+		{"fence", "Run this:\n\n```\nmake build\n```\n", true},
+		{"heading and link", "## Install\n\nSee the [guide](https://example.com/guide).", true},
+		{"heading and list", "# Shopping\n\n- eggs\n- milk", true},
+		{"code span and quote", "> Use `go vet` before pushing.", true},
+		{"prose", "The council met on Tuesday. It approved the budget after a long debate.", false},
+		{"prose with a list", "We need:\n- eggs\n- milk\n* bread", false},
+		{"hashtag", "#golang is trending today, and #1 on the list.", false},
+		{"single emphasis", "This is **really** important.", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := looksLikeMarkdown(tt.text); got != tt.want {
+				t.Errorf("looksLikeMarkdown(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStripMarkdownWords(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"heading", "### Getting started", []string{"getting", "started"}},
+		{"link", "Read the [user guide](https://example.com/guide \"Guide\") first.", []string{"read", "the", "user", "guide", "first"}},
+		{"image", "![Build status](https://example.com/badge.svg)", []string{"build", "status"}},
+		{"badge", "[![Go Reference](https://pkg.go.dev/badge.svg)](https://pkg.go.dev/x)", []string{"go", "reference"}},
+		{"reference link", "See the [changelog][log].\n\n[log]: https://example.com/changelog", []string{"see", "the", "changelog"}},
+		{"autolink", "Visit <https://example.com> today.", []string{"visit", "today"}},
+		{"emphasis", "A **bold**, *italic*, __strong__ and ~~struck~~ word.", []string{"a", "bold", "italic", "strong", "and", "struck", "word"}},
+		{"snake case", "Set max_retries to three.", []string{"set", "max_retries", "to", "three"}},
+		{"code span", "Run `go test` now.", []string{"run", "go", "test", "now"}},
+		{"list", "- first item\n1. second item\n- [x] done", []string{"first", "item", "second", "item", "done"}},
+		{"table", "| Name | Value |\n|------|------:|\n| rate | 10 |", []string{"name", "value", "rate", "10"}},
+		{"quote", "> Quoted words", []string{"quoted", "words"}},
+		{"html", "<p align=\"center\">Centered</p>", []string{"centered"}},
+		{"fenced code", "Before.\n\n```go\nfunc main() {}\n```\n\nAfter.", []string{"before", "after"}},
+		{"tilde fence", "Before.\n\n~~~\nrm -rf tmp\n~~~\nAfter.", []string{"before", "after"}},
+		{"unclosed fence", "Before.\n\n```\nstill code", []string{"before"}},
+		{"indented code", "Before.\n\n    x := compute(y)\n\n    return x\n\nAfter.", []string{"before", "after"}},
+		{"indented list continuation", "- item\n\n    more of the item", []string{"item", "more", "of", "the", "item"}},
+		{"indented paragraph continuation", "Some text\n    continued here", []string{"some", "text", "continued", "here"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prepared := stripMarkdown(tt.text)
+			if got := extractWords(prepared.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected words %v, got %v from %q", tt.want, got, prepared.text)
+			}
+			if got, want := utf8.RuneCountInString(prepared.text), utf8.RuneCountInString(tt.text); got != want {
+				t.Errorf("Expected %d runes, got %d", want, got)
+			}
+		})
+	}
+}
+
+func TestStripMarkdownKeepsOffsets(t *testing.T) {
+	text := "Intro with **émphasis**.\n\n```\nçode – ünicode\n```\n\nThe end is here."
+	prepared := stripMarkdown(text)
+
+	want := strings.Index(text, "The end")
+	if got := strings.Index(prepared.text, "The end"); utf8.RuneCountInString(prepared.text[:got]) != utf8.RuneCountInString(text[:want]) {
+		t.Errorf("Expected the last sentence at the same rune offset, got %q", prepared.text)
+	}
+	if strings.Contains(prepared.text, "ünicode") {
+		t.Errorf("Expected the code block blanked out, got %q", prepared.text)
+	}
+}
+
+func TestStripMarkdownEndsBlocks(t *testing.T) {
+	text := "## Features\n\n- Fast buckets\n- Per-key limits\n\nInstall it with:\n\n```sh\ngo get example.com/x\n```\n\n| Option | Default |\n|---|---|\n| Rate | 10 |"
+	prepared := stripMarkdown(text)
+
+	// The heading runs into the first list item, as headings do in text
+	if got := countSentences(prepared.text); got != 5 {
+		t.Errorf("Expected 5 sentences, got %d in %q", got, prepared.text)
+	}
+	if !strings.Contains(prepared.text, "## Features\n") {
+		t.Errorf("Expected the heading left unchanged, got %q", prepared.text)
+	}
+	wantPlain := "## Features\n\nFast buckets.\nPer-key limits.\n\nInstall it with:.\n\nOption Default.\n\nRate 10."
+	if prepared.plain != wantPlain {
+		t.Errorf("Expected plain text %q, got %q", wantPlain, prepared.plain)
+	}
+}
+
+func TestCodeBlockSpans(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"backticks", "a\n```go\nx := 1\n```\nb", []string{"```go\nx := 1\n```"}},
+		{"longer closing fence", "````\n```\nnested\n```\n`````\nb", []string{"````\n```\nnested\n```\n`````"}},
+		{"tilde", "~~~\ncode\n~~~", []string{"~~~\ncode\n~~~"}},
+		{"unclosed", "a\n```\ncode\nmore", []string{"```\ncode\nmore"}},
+		{"backticks in info string", "``` not `a` fence\ntext", nil},
+		{"indented", "a\n\n    one\n\n    two\nb", []string{"    one\n\n    two"}},
+		{"tab indented", "\tcode", []string{"\tcode"}},
+		{"indented in list", "- item\n\n    continued", nil},
+		{"indented after paragraph", "a\n    continued", nil},
+		{"two blocks", "```\none\n```\n\ntext\n\n    two", []string{"```\none\n```", "    two"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, span := range codeBlockSpans(tt.text) {
+				got = append(got, tt.text[span.start:span.end])
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected code blocks %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestAnalyzeMarkdownReadme(t *testing.T) {
+	readme := loadFixture(t, "readme.md")
+	a := New()
+
+	asText := a.AnalyzeOfflineWithOptions(readme, OfflineOptions{Format: FormatText})
+	metadata := a.AnalyzeOfflineWithOptions(readme, OfflineOptions{})
+
+	if asText.Format != "" || asText.CodeBlocks != 0 {
+		t.Errorf("Expected plain text analysis without format or code blocks, got %q and %d", asText.Format, asText.CodeBlocks)
+	}
+	if metadata.Format != FormatMarkdown {
+		t.Errorf("Expected the README detected as Markdown, got format %q", metadata.Format)
+	}
+	if metadata.CodeBlocks != 3 {
+		t.Errorf("Expected 3 code blocks, got %d", metadata.CodeBlocks)
+	}
+	if metadata.CharacterCount != utf8.RuneCountInString(readme) {
+		t.Errorf("Expected the character count of the original text, got %d", metadata.CharacterCount)
+	}
+
+	// Code and link targets no longer count as words, and list items and
+	// table rows end sentences of their own
+	if metadata.WordCount < 250 || metadata.WordCount > 300 || metadata.WordCount >= asText.WordCount {
+		t.Errorf("Expected 250 to 300 words, fewer than the %d read as text, got %d", asText.WordCount, metadata.WordCount)
+	}
+	if metadata.SentenceCount < 20 || metadata.SentenceCount > 35 {
+		t.Errorf("Expected 20 to 35 sentences, got %d", metadata.SentenceCount)
+	}
+	if metadata.AvgSentenceLength < 8 || metadata.AvgSentenceLength > 16 {
+		t.Errorf("Expected an average sentence of 8 to 16 words, got %.1f", metadata.AvgSentenceLength)
+	}
+	if metadata.ReadabilityScore < 55 || metadata.ReadabilityScore > 80 {
+		t.Errorf("Expected a Flesch score of 55 to 80, got %.1f", metadata.ReadabilityScore)
+	}
+	if metadata.QualityScore.Score < asText.QualityScore.Score {
+		t.Errorf("Expected a quality score of at least %.2f, got %.2f (%s)", asText.QualityScore.Score, metadata.QualityScore.Score, metadata.QualityScore.Reason)
+	}
+	for _, word := range metadata.TopWords {
+		if word.Word == "func" || word.Word == "http" || word.Word == "github" {
+			t.Errorf("Expected code and URLs left out of the top words, got %+v", metadata.TopWords)
+		}
+	}
+
+	// Link and badge targets are extracted whole; relative links are not
+	wantURLs := []string{
+		"https://github.com/example/ratelimit/actions",
+		"https://github.com/example/ratelimit/actions/workflows/ci.yml/badge.svg",
+		"https://github.com/example/ratelimit/blob/main/CHANGELOG.md",
+		"https://pkg.go.dev/badge/github.com/example/ratelimit.svg",
+		"https://pkg.go.dev/github.com/example/ratelimit",
+	}
+	if !reflect.DeepEqual(metadata.PotentialURLs, wantURLs) {
+		t.Errorf("Expected URLs %v, got %v", wantURLs, metadata.PotentialURLs)
+	}
+
+	// Headings still split the README into sections
+	sections := a.AnalyzeOfflineWithOptions(readme, OfflineOptions{Sections: true}).Sections
+	var titles []string
+	for _, section := range sections {
+		titles = append(titles, section.Title)
+	}
+	for _, title := range []string{"Features", "Usage", "Options", "License"} {
+		if !strings.Contains(strings.Join(titles, "|"), title) {
+			t.Errorf("Expected a %q section, got %q", title, titles)
+		}
+	}
+}
+
+func TestAnalyzeMarkdownRoundTrip(t *testing.T) {
+	readme := loadFixture(t, "readme.md")
+	a := New()
+
+	for _, format := range []string{"", FormatText} {
+		offline := a.AnalyzeOfflineWithOptions(readme, OfflineOptions{Format: format})
+		opts := RecordedOptions(offline)
+
+		// Recomputed and reused rule-based results match the offline ones
+		for name, offlineResults := range map[string]bool{"recomputed": false, "reused": true} {
+			if offlineResults {
+				opts.Offline = &offline
+			}
+			online := a.AnalyzeWithOptions(context.Background(), readme, opts)
+			if online.Format != offline.Format || online.CodeBlocks != offline.CodeBlocks ||
+				online.WordCount != offline.WordCount || online.SentenceCount != offline.SentenceCount ||
+				online.ReadabilityScore != offline.ReadabilityScore || !reflect.DeepEqual(online.PotentialURLs, offline.PotentialURLs) {
+				t.Errorf("format %q, %s: expected the offline statistics, got format %q, %d code blocks, %d words, %d sentences, readability %.1f",
+					format, name, online.Format, online.CodeBlocks, online.WordCount, online.SentenceCount, online.ReadabilityScore)
+			}
+		}
+	}
+}
+
+func TestCleanTextOfflineKeepsCodeBlocks(t *testing.T) {
+	readme := loadFixture(t, "readme.md")
+	a := New()
+
+	for _, chunk := range splitIntoChunks(readme, DefaultParagraphChunkSentences) {
+		if !chunk.code {
+			continue
+		}
+		if score := scoreCodeBlock(chunk.text); score.Score != 0.5 || !reflect.DeepEqual(score.Reasons, []string{"code_block"}) {
+			t.Errorf("Expected a neutral score for %q, got %+v", chunk.text, score)
+		}
+	}
+
+	report := a.CleanTextOfflineWithReport(readme)
+	for _, code := range []string{"go get github.com/example/ratelimit@latest", "func main() {", "limiter.KeyFunc"} {
+		if !strings.Contains(report.CleanedText, code) {
+			t.Errorf("Expected %q kept by offline cleaning, got %q", code, report.CleanedText)
+		}
+	}
+}
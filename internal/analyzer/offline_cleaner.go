@@ -4,6 +4,7 @@ import (
 	"log/slog"
 	"regexp"
 	"strings"
+	"unicode/utf8"
 )
 
 // ParagraphScore represents the quality score for a paragraph
@@ -21,16 +22,79 @@ type ParagraphScore struct {
 	Reasons          []string
 }
 
+// Removal reasons used to bucket paragraphs dropped by offline cleaning
+const (
+	RemovalImageAttribution = "image_attribution"
+	RemovalBoilerplate      = "boilerplate_pattern"
+	RemovalAuthorByline     = "author_byline"
+	RemovalMetadataLine     = "metadata_line"
+	RemovalOther            = "other"
+)
+
+// removalReasonPriority lists removal reasons from largest to smallest score
+// penalty; the first one present on a paragraph is its dominant reason
+var removalReasonPriority = []string{
+	RemovalBoilerplate,
+	RemovalImageAttribution,
+	RemovalAuthorByline,
+	RemovalMetadataLine,
+}
+
+// DefaultParagraphChunkSentences is the default number of sentences grouped
+// into each pseudo-paragraph when a long block without newlines is chunked
+const DefaultParagraphChunkSentences = 5
+
+// chunkMinChars is the length above which a block without newlines is
+// chunked by sentence before scoring
+const chunkMinChars = 1000
+
+// SetParagraphChunkSentences sets how many sentences are grouped into each
+// pseudo-paragraph when offline cleaning chunks a long block without
+// newlines. Values of zero or less restore DefaultParagraphChunkSentences.
+func (a *Analyzer) SetParagraphChunkSentences(n int) {
+	a.paragraphChunkSentences = n
+}
+
+// chunkSentences returns the number of sentences per pseudo-paragraph
+func (a *Analyzer) chunkSentences() int {
+	if a.paragraphChunkSentences <= 0 {
+		return DefaultParagraphChunkSentences
+	}
+	return a.paragraphChunkSentences
+}
+
+// CleaningReport describes the outcome of offline cleaning, including the
+// paragraphs that were removed grouped by their dominant removal reason
+type CleaningReport struct {
+	CleanedText    string              `json:"cleaned_text"`
+	Threshold      float64             `json:"threshold"`
+	KeptCount      int                 `json:"kept_count"`
+	RemovedCount   int                 `json:"removed_count"`
+	RemovedContent map[string][]string `json:"removed_content"`
+}
+
 // cleanTextOffline performs sophisticated offline text cleaning using heuristics
 // This provides a clean article text that can be used as a template for AI enhancement
 func (a *Analyzer) cleanTextOffline(text string) string {
+	return a.CleanTextOfflineWithReport(text).CleanedText
+}
+
+// CleanTextOfflineWithReport cleans text like cleanTextOffline and also
+// returns the removed paragraphs, so callers can reuse noise such as image
+// credits and bylines as metadata
+func (a *Analyzer) CleanTextOfflineWithReport(text string) CleaningReport {
 	slog.Info("starting offline text cleaning with advanced heuristics")
 
+	report := CleaningReport{
+		RemovedContent: map[string][]string{},
+	}
+
 	// Split into paragraphs
-	paragraphs := splitIntoParagraphs(text)
+	paragraphs := splitIntoChunks(text, a.chunkSentences())
 	if len(paragraphs) == 0 {
 		slog.Info("no paragraphs found, returning original text")
-		return text
+		report.CleanedText = text
+		return report
 	}
 
 	slog.Info("analyzing paragraphs", "count", len(paragraphs))
@@ -38,37 +102,74 @@ func (a *Analyzer) cleanTextOffline(text string) string {
 	// Score each paragraph
 	scores := make([]ParagraphScore, 0, len(paragraphs))
 	for _, para := range paragraphs {
-		score := a.scoreParagraph(para)
+		if para.code {
+			scores = append(scores, scoreCodeBlock(para.text))
+			continue
+		}
+		score := a.scoreParagraph(para.text)
 		scores = append(scores, score)
 	}
 
 	// Calculate threshold - keep paragraphs above median score
 	threshold := calculateDynamicThreshold(scores)
+	report.Threshold = threshold
 	slog.Info("paragraph quality threshold", "threshold", threshold)
 
-	// Filter paragraphs and reconstruct clean text
-	cleanParagraphs := make([]string, 0, len(paragraphs))
-	kept := 0
-	removed := 0
+	// Filter paragraphs and reconstruct clean text. Chunks of the same block
+	// are joined with single newlines so no paragraph breaks are invented.
+	var cleaned strings.Builder
+	lastBlock := -1
 
 	for i, score := range scores {
 		if score.Score >= threshold && !score.IsBoilerplate {
-			cleanParagraphs = append(cleanParagraphs, score.Text)
-			kept++
+			if cleaned.Len() > 0 {
+				if paragraphs[i].block == lastBlock {
+					cleaned.WriteString("\n")
+				} else {
+					cleaned.WriteString("\n\n")
+				}
+			}
+			cleaned.WriteString(score.Text)
+			lastBlock = paragraphs[i].block
+			report.KeptCount++
 		} else {
-			removed++
+			report.RemovedCount++
+			reason := dominantRemovalReason(score)
+			report.RemovedContent[reason] = append(report.RemovedContent[reason], score.Text)
 			if len(score.Reasons) > 0 {
 				slog.Info("removed paragraph", "index", i+1, "score", score.Score, "reasons", strings.Join(score.Reasons, ", "))
 			}
 		}
 	}
 
-	slog.Info("offline cleaning complete", "kept", kept, "removed", removed)
+	slog.Info("offline cleaning complete", "kept", report.KeptCount, "removed", report.RemovedCount)
+
+	report.CleanedText = cleaned.String()
+	return report
+}
 
-	cleanText := strings.Join(cleanParagraphs, "\n\n")
-	return cleanText
+// dominantRemovalReason picks the removal bucket for a dropped paragraph
+func dominantRemovalReason(score ParagraphScore) string {
+	for _, reason := range removalReasonPriority {
+		for _, r := range score.Reasons {
+			if r == reason {
+				return reason
+			}
+		}
+	}
+	return RemovalOther
 }
 
+// Patterns of paragraphs that are usually not article content
+var (
+	// A numbered list item
+	listItemPattern = regexp.MustCompile(`^\d+\.`)
+	// A publication or update date line
+	metadataLinePattern = regexp.MustCompile(`(?i)posted on|published on|updated on|last modified|^\w+\s+\d{1,2},\s+\d{4}`)
+	// An author byline
+	bylinePattern = regexp.MustCompile(`(?i)^by\s+[A-Z][a-z]+|^written by|^author:`)
+)
+
 // scoreParagraph scores a paragraph based on multiple quality factors
 func (a *Analyzer) scoreParagraph(para string) ParagraphScore {
 	score := ParagraphScore{
@@ -77,15 +178,16 @@ func (a *Analyzer) scoreParagraph(para string) ParagraphScore {
 		Reasons: []string{},
 	}
 
-	// Quick reject: empty or too short
+	// Quick reject: empty or too short. The ratios below divide by the word
+	// count, so a paragraph without words is rejected here as well.
 	trimmed := strings.TrimSpace(para)
-	if len(trimmed) < 20 {
+	words := strings.Fields(para)
+	if len(trimmed) < 20 || len(words) == 0 {
 		score.Score = 0.0
 		score.Reasons = append(score.Reasons, "too_short")
 		return score
 	}
 
-	words := strings.Fields(para)
 	score.WordCount = len(words)
 
 	// Factor 1: Word count (sweet spot is 20-200 words per paragraph)
@@ -140,7 +242,7 @@ func (a *Analyzer) scoreParagraph(para string) ParagraphScore {
 	// Factor 5: Average word length (articles have balanced word length)
 	totalLength := 0
 	for _, word := range words {
-		totalLength += len(word)
+		totalLength += utf8.RuneCountInString(word)
 	}
 	score.AvgWordLength = float64(totalLength) / float64(score.WordCount)
 	if score.AvgWordLength >= 4.0 && score.AvgWordLength <= 6.0 {
@@ -215,7 +317,7 @@ func (a *Analyzer) scoreParagraph(para string) ParagraphScore {
 
 	// Factor 10: List-like structure (disconnected bullet points)
 	if strings.HasPrefix(trimmed, "•") || strings.HasPrefix(trimmed, "-") ||
-		strings.HasPrefix(trimmed, "*") || regexp.MustCompile(`^\d+\.`).MatchString(trimmed) {
+		strings.HasPrefix(trimmed, "*") || listItemPattern.MatchString(trimmed) {
 		// It's a list item - only bad if very short
 		if score.WordCount < 15 {
 			score.Score -= 0.2
@@ -234,15 +336,13 @@ func (a *Analyzer) scoreParagraph(para string) ParagraphScore {
 	}
 
 	// Factor 12: Date/timestamp patterns (often navigation)
-	datePattern := regexp.MustCompile(`(?i)posted on|published on|updated on|last modified|^\w+\s+\d{1,2},\s+\d{4}`)
-	if datePattern.MatchString(para) && score.WordCount < 20 {
+	if metadataLinePattern.MatchString(para) && score.WordCount < 20 {
 		score.Score -= 0.2
 		score.Reasons = append(score.Reasons, "metadata_line")
 	}
 
 	// Factor 13: Author bylines (not main content)
-	authorPattern := regexp.MustCompile(`(?i)^by\s+[A-Z][a-z]+|^written by|^author:`)
-	if authorPattern.MatchString(trimmed) && score.WordCount < 15 {
+	if bylinePattern.MatchString(trimmed) && score.WordCount < 15 {
 		score.Score -= 0.2
 		score.Reasons = append(score.Reasons, "author_byline")
 	}
@@ -258,34 +358,61 @@ func (a *Analyzer) scoreParagraph(para string) ParagraphScore {
 	return score
 }
 
+// scoreCodeBlock scores a paragraph of a Markdown code block as neutral:
+// code fails the prose heuristics of scoreParagraph without being noise
+func scoreCodeBlock(para string) ParagraphScore {
+	return ParagraphScore{
+		Text:      para,
+		Score:     0.5,
+		Reasons:   []string{"code_block"},
+		WordCount: len(strings.Fields(para)),
+	}
+}
+
 // splitIntoParagraphs splits text into paragraphs intelligently
 func splitIntoParagraphs(text string) []string {
-	// Split by double newlines (standard paragraph separator)
-	paragraphs := strings.Split(text, "\n\n")
+	chunks := splitIntoChunks(text, DefaultParagraphChunkSentences)
 
-	// Also split by single newlines if paragraphs are too long
-	result := make([]string, 0, len(paragraphs))
-	for _, para := range paragraphs {
-		trimmed := strings.TrimSpace(para)
-		if trimmed == "" {
+	result := make([]string, 0, len(chunks))
+	for _, chunk := range chunks {
+		result = append(result, chunk.text)
+	}
+
+	return result
+}
+
+// paragraphChunk is a paragraph scored by the offline cleaner, or part of one
+type paragraphChunk struct {
+	text  string
+	block int  // Index of the paragraph the chunk was cut from
+	code  bool // Whether the chunk lies within a Markdown code block
+}
+
+// splitIntoChunks splits text into paragraphs, grouping every
+// sentencesPerChunk sentences of a long block without newlines into a
+// pseudo-paragraph so a page scraped onto one line is not scored as a whole.
+// Paragraphs within Markdown code blocks are never split.
+func splitIntoChunks(text string, sentencesPerChunk int) []paragraphChunk {
+	var chunks []paragraphChunk
+	codeBlocks := codeBlockSpans(text)
+	for block, span := range paragraphSpans(text) {
+		para := text[span.start:span.end]
+		code := isCodeChunk(span, codeBlocks)
+		if len(para) <= chunkMinChars || strings.Contains(para, "\n") || code {
+			chunks = append(chunks, paragraphChunk{text: para, block: block, code: code})
 			continue
 		}
 
-		// If paragraph is very long, try splitting by single newline
-		if len(trimmed) > 1000 {
-			subParas := strings.Split(para, "\n")
-			for _, subPara := range subParas {
-				subTrimmed := strings.TrimSpace(subPara)
-				if subTrimmed != "" {
-					result = append(result, subTrimmed)
-				}
-			}
-		} else {
-			result = append(result, trimmed)
+		sentences := sentenceSpans(para)
+		for i := 0; i < len(sentences); i += sentencesPerChunk {
+			last := min(i+sentencesPerChunk, len(sentences)) - 1
+			chunks = append(chunks, paragraphChunk{
+				text:  para[sentences[i].start:sentences[last].end],
+				block: block,
+			})
 		}
 	}
-
-	return result
+	return chunks
 }
 
 // calculateDynamicThreshold calculates a threshold based on score distribution
@@ -244,11 +244,8 @@ func TestScoreParagraph_WordCount(t *testing.T) {
 	}
 }
 
-func TestCleanTextOffline(t *testing.T) {
-	analyzer := New()
-
-	// Test with mixed content: good paragraphs and noise
-	input := `This is a good article paragraph with substantial content about technology and innovation.
+// mixedContentFixture mixes good article paragraphs with common noise
+const mixedContentFixture = `This is a good article paragraph with substantial content about technology and innovation.
 
 Photo by: John Smith, Getty Images
 
@@ -262,6 +259,12 @@ Share this article → Facebook | Twitter | LinkedIn
 
 The study was published in Nature magazine last week.`
 
+func TestCleanTextOffline(t *testing.T) {
+	analyzer := New()
+
+	// Test with mixed content: good paragraphs and noise
+	input := mixedContentFixture
+
 	cleaned := analyzer.cleanTextOffline(input)
 
 	// Should keep the good paragraphs
@@ -292,6 +295,68 @@ The study was published in Nature magazine last week.`
 	}
 }
 
+func TestCleanTextOfflineWithReport(t *testing.T) {
+	analyzer := New()
+
+	// Prepend a byline and a timestamp line to the mixed-content fixture
+	input := "By Jane Doe, Science Correspondent\n\nPosted on March 3, 2024 at 10:00\n\n" + mixedContentFixture
+
+	report := analyzer.CleanTextOfflineWithReport(input)
+
+	if report.CleanedText != analyzer.cleanTextOffline(input) {
+		t.Error("report cleaned text should match cleanTextOffline")
+	}
+	if report.KeptCount != 4 {
+		t.Errorf("expected 4 kept paragraphs, got %d", report.KeptCount)
+	}
+	if report.RemovedCount != 5 {
+		t.Errorf("expected 5 removed paragraphs, got %d", report.RemovedCount)
+	}
+
+	expected := map[string][]string{
+		RemovalImageAttribution: {"Photo by: John Smith, Getty Images"},
+		RemovalBoilerplate: {
+			"Click here to subscribe to our newsletter!",
+			"Share this article → Facebook | Twitter | LinkedIn",
+		},
+		RemovalAuthorByline: {"By Jane Doe, Science Correspondent"},
+		RemovalMetadataLine: {"Posted on March 3, 2024 at 10:00"},
+	}
+	for reason, paragraphs := range expected {
+		got := report.RemovedContent[reason]
+		if len(got) != len(paragraphs) {
+			t.Errorf("%s: expected %d paragraphs, got %v", reason, len(paragraphs), got)
+			continue
+		}
+		for i := range paragraphs {
+			if got[i] != paragraphs[i] {
+				t.Errorf("%s[%d]: expected %q, got %q", reason, i, paragraphs[i], got[i])
+			}
+		}
+	}
+	if len(report.RemovedContent[RemovalOther]) != 0 {
+		t.Errorf("expected no uncategorized removals, got %v", report.RemovedContent[RemovalOther])
+	}
+}
+
+func TestDominantRemovalReason(t *testing.T) {
+	tests := []struct {
+		reasons  []string
+		expected string
+	}{
+		{[]string{"very_few_words", "image_attribution", "boilerplate_pattern"}, RemovalBoilerplate},
+		{[]string{"metadata_line", "author_byline"}, RemovalAuthorByline},
+		{[]string{"too_short"}, RemovalOther},
+		{nil, RemovalOther},
+	}
+
+	for _, tt := range tests {
+		if got := dominantRemovalReason(ParagraphScore{Reasons: tt.reasons}); got != tt.expected {
+			t.Errorf("dominantRemovalReason(%v) = %s, want %s", tt.reasons, got, tt.expected)
+		}
+	}
+}
+
 func TestCleanTextOffline_EmptyInput(t *testing.T) {
 	analyzer := New()
 
@@ -433,3 +498,102 @@ func TestScoreParagraph_EdgeCases(t *testing.T) {
 		})
 	}
 }
+
+// singleLineFixture builds about 100KB of article text without newlines, as
+// scraped pages sometimes arrive, with boilerplate sentences embedded
+func singleLineFixture() string {
+	article := []string{
+		"The city council approved the new transit plan after months of public hearings.",
+		"Dr. Alvarez, who chairs the planning committee, said the vote reflected broad support from residents.",
+		"The plan adds three bus routes and extends service hours on weekends.",
+		"Funding will come from a combination of state grants and a modest increase in parking fees.",
+		"Construction of the first new stops is expected to begin early next year.",
+	}
+	boilerplate := "Click here to subscribe to our newsletter for more stories like this one."
+
+	var b strings.Builder
+	for i := 0; b.Len() < 100*1024; i++ {
+		if i%40 == 20 {
+			b.WriteString(boilerplate + " ")
+		}
+		b.WriteString(article[i%len(article)] + " ")
+	}
+	return b.String()
+}
+
+func TestCleanTextOfflineLongSingleLine(t *testing.T) {
+	input := singleLineFixture()
+	if strings.Contains(input, "\n") || len(input) < 100*1024 {
+		t.Fatal("fixture should be at least 100KB without newlines")
+	}
+
+	analyzer := New()
+	report := analyzer.CleanTextOfflineWithReport(input)
+
+	if report.KeptCount+report.RemovedCount < 2 {
+		t.Fatalf("expected the blob to be scored as multiple chunks, got %d", report.KeptCount+report.RemovedCount)
+	}
+	if report.KeptCount == 0 {
+		t.Fatal("expected article chunks to be kept")
+	}
+	if len(report.RemovedContent[RemovalBoilerplate]) == 0 {
+		t.Error("expected chunks with boilerplate sentences to be removed")
+	}
+	if strings.Contains(report.CleanedText, "subscribe") {
+		t.Error("boilerplate sentences embedded in the blob should be removed")
+	}
+	if !strings.Contains(report.CleanedText, "transit plan") {
+		t.Error("article sentences should be kept")
+	}
+	if strings.Contains(report.CleanedText, "\n\n") {
+		t.Error("chunks of one block should be joined without paragraph breaks")
+	}
+}
+
+func TestSplitIntoChunks(t *testing.T) {
+	input := singleLineFixture()
+
+	sentences := len(sentenceSpans(input))
+	for _, size := range []int{1, 5, 10} {
+		chunks := splitIntoChunks(input, size)
+		if want := (sentences + size - 1) / size; len(chunks) != want {
+			t.Errorf("%d sentences per chunk: expected %d chunks, got %d", size, want, len(chunks))
+		}
+		for _, chunk := range chunks {
+			if chunk.block != 0 {
+				t.Fatalf("expected every chunk to come from block 0, got %d", chunk.block)
+			}
+		}
+	}
+
+	// Abbreviations do not end a chunk early
+	if chunks := splitIntoChunks(input, 2); !strings.HasPrefix(chunks[0].text, "The city council") || !strings.Contains(chunks[0].text, "Dr. Alvarez") {
+		t.Errorf("unexpected first chunk %q", chunks[0].text)
+	}
+
+	// Short paragraphs and blocks with newlines are left whole
+	if chunks := splitIntoChunks("First. Second. Third.\n\nFourth.", 1); len(chunks) != 2 {
+		t.Errorf("expected 2 paragraphs, got %d", len(chunks))
+	}
+}
+
+func TestSetParagraphChunkSentences(t *testing.T) {
+	input := singleLineFixture()
+
+	analyzer := New()
+	defaultReport := analyzer.CleanTextOfflineWithReport(input)
+
+	analyzer.SetParagraphChunkSentences(10)
+	largerReport := analyzer.CleanTextOfflineWithReport(input)
+
+	defaultChunks := defaultReport.KeptCount + defaultReport.RemovedCount
+	largerChunks := largerReport.KeptCount + largerReport.RemovedCount
+	if largerChunks >= defaultChunks {
+		t.Errorf("expected fewer chunks with 10 sentences per chunk, got %d vs %d", largerChunks, defaultChunks)
+	}
+
+	analyzer.SetParagraphChunkSentences(0)
+	if got := analyzer.chunkSentences(); got != DefaultParagraphChunkSentences {
+		t.Errorf("expected the default chunk size after reset, got %d", got)
+	}
+}
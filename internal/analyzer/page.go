@@ -0,0 +1,248 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/docutag/textanalyzer/internal/textutil"
+)
+
+// Page fetch defaults
+const (
+	DefaultPageFetchTimeout = 15 * time.Second
+	DefaultPageMaxBytes     = 5 << 20
+	DefaultPageMaxRedirects = 5
+	DefaultPageUserAgent    = "docutag-textanalyzer/1.0"
+)
+
+// Errors returned by PageFetcher.Fetch, wrapped with the details
+var (
+	// ErrPageURL is a URL that may not be fetched: not http(s), or resolving
+	// to a private, loopback or link-local address
+	ErrPageURL = errors.New("URL not allowed")
+	// ErrPageContentType is a response that is not HTML or plain text
+	ErrPageContentType = errors.New("unsupported content type")
+	// ErrPageTooLarge is a response over the size limit
+	ErrPageTooLarge = errors.New("page too large")
+)
+
+// PageFetchConfig bounds the pages fetched for analysis
+type PageFetchConfig struct {
+	Timeout      time.Duration // Total time for the fetch, redirects included (default: 15s)
+	MaxBytes     int64         // Largest response body read (default: 5 MiB)
+	MaxRedirects int           // Redirects followed before giving up (default: 5)
+	UserAgent    string        // User-Agent header sent with each request
+}
+
+// Page is a fetched web page
+type Page struct {
+	URL    string   // Where the page was fetched from after redirects
+	HTML   string   // Raw body; plain text pages are kept as they are
+	Text   string   // Readable text of the page
+	Images []string // Absolute http(s) image URLs, in document order
+}
+
+// PageFetcher fetches web pages for analysis. Only public http(s) addresses
+// are dialed, so submitted URLs cannot reach internal services.
+type PageFetcher struct {
+	client       *http.Client
+	maxBytes     int64
+	userAgent    string
+	allowPrivate bool
+}
+
+// NewPageFetcher creates a page fetcher, defaulting unset limits
+func NewPageFetcher(cfg PageFetchConfig) *PageFetcher {
+	return newPageFetcher(cfg, false)
+}
+
+// newPageFetcher creates a page fetcher; allowPrivate lets tests reach
+// httptest servers on the loopback address
+func newPageFetcher(cfg PageFetchConfig, allowPrivate bool) *PageFetcher {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultPageFetchTimeout
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = DefaultPageMaxBytes
+	}
+	if cfg.MaxRedirects <= 0 {
+		cfg.MaxRedirects = DefaultPageMaxRedirects
+	}
+	if cfg.UserAgent == "" {
+		cfg.UserAgent = DefaultPageUserAgent
+	}
+
+	dialer := PublicDialer(cfg.Timeout, allowPrivate, ErrPageURL)
+
+	f := &PageFetcher{
+		maxBytes:     cfg.MaxBytes,
+		userAgent:    cfg.UserAgent,
+		allowPrivate: allowPrivate,
+	}
+	f.client = &http.Client{
+		Timeout: cfg.Timeout,
+		Transport: &http.Transport{
+			// No proxy: it would dial internal addresses on our behalf
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: cfg.Timeout,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > cfg.MaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", cfg.MaxRedirects)
+			}
+			return f.checkURL(req.URL)
+		},
+	}
+	return f
+}
+
+// PublicDialer returns a dialer that only connects to public addresses,
+// unless allowPrivate, refusing others with an error wrapping refused. The
+// check is made on the resolved address, so DNS names pointing at private
+// addresses are caught too, including after redirects.
+func PublicDialer(timeout time.Duration, allowPrivate bool, refused error) *net.Dialer {
+	dialer := &net.Dialer{Timeout: timeout}
+	if allowPrivate {
+		return dialer
+	}
+	dialer.Control = func(network, address string, _ syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		if ip := net.ParseIP(host); ip == nil || !PublicIP(ip) {
+			return fmt.Errorf("%w: %s is not a public address", refused, host)
+		}
+		return nil
+	}
+	return dialer
+}
+
+// nonPublicNets are the special-purpose ranges the net.IP predicates miss:
+// carrier-grade NAT, "this network", benchmarking and NAT64, which reaches
+// IPv4 addresses, private ones included, through a translator
+var nonPublicNets = []*net.IPNet{
+	mustParseCIDR("100.64.0.0/10"),
+	mustParseCIDR("0.0.0.0/8"),
+	mustParseCIDR("198.18.0.0/15"),
+	mustParseCIDR("64:ff9b::/96"),
+}
+
+func mustParseCIDR(cidr string) *net.IPNet {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
+
+// PublicIP reports whether an address is routable on the public internet
+func PublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, network := range nonPublicNets {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkURL rejects URLs that are not absolute http(s) URLs, and hosts that
+// are literal non-public addresses
+func (f *PageFetcher) checkURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: scheme must be http or https", ErrPageURL)
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("%w: missing host", ErrPageURL)
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil && !PublicIP(ip) && !f.allowPrivate {
+		return fmt.Errorf("%w: %s is not a public address", ErrPageURL, ip)
+	}
+	return nil
+}
+
+// Fetch downloads an HTML or plain text page and extracts its text and
+// images. Errors wrap ErrPageURL, ErrPageContentType or ErrPageTooLarge
+// when the page is refused; anything else is a failed fetch.
+func (f *PageFetcher) Fetch(ctx context.Context, rawURL string) (*Page, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPageURL, err)
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if err := f.checkURL(u); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPageURL, err)
+	}
+	req.Header.Set("User-Agent", f.userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,text/plain;q=0.9")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		// Refusals from the dialer and redirect check still wrap ErrPageURL
+		return nil, fmt.Errorf("failed to fetch %s: %w", u, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("failed to fetch %s: status %d", u, resp.StatusCode)
+	}
+
+	isHTML := true
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", ErrPageContentType, contentType)
+		}
+		switch mediaType {
+		case "text/html", "application/xhtml+xml":
+		case "text/plain":
+			isHTML = false
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrPageContentType, mediaType)
+		}
+	}
+	if resp.ContentLength > f.maxBytes {
+		return nil, fmt.Errorf("%w: %d bytes, maximum is %d", ErrPageTooLarge, resp.ContentLength, f.maxBytes)
+	}
+
+	// Read one byte past the limit to tell a page at the limit from one over it
+	body, err := io.ReadAll(io.LimitReader(resp.Body, f.maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", u, err)
+	}
+	if int64(len(body)) > f.maxBytes {
+		return nil, fmt.Errorf("%w: maximum is %d bytes", ErrPageTooLarge, f.maxBytes)
+	}
+
+	page := &Page{
+		URL:  resp.Request.URL.String(),
+		HTML: textutil.ValidUTF8(string(body)),
+	}
+	if !isHTML {
+		page.Text = page.HTML
+		return page, nil
+	}
+	page.Text = HTMLText(page.HTML)
+	page.Images = HTMLImages(page.HTML, resp.Request.URL)
+	return page, nil
+}
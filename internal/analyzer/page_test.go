@@ -0,0 +1,178 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newPageServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/article", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<html><head><title>Title</title></head><body>
+<h1>` + r.UserAgent() + `</h1><p>Body text.</p><img src="/images/photo.jpg"></body></html>`))
+	})
+	mux.HandleFunc("/notes.txt", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("Plain <b>text</b>."))
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/article", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	mux.HandleFunc("/ftp", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "ftp://example.com/file", http.StatusFound)
+	})
+	mux.HandleFunc("/large", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(strings.Repeat("<p>word</p>", 100)))
+	})
+	mux.HandleFunc("/streamed", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		for i := 0; i < 100; i++ {
+			w.Write([]byte("<p>word</p>"))
+			w.(http.Flusher).Flush()
+		}
+	})
+	mux.HandleFunc("/image.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(encodeTestPNG(t, 4, 4))
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFetchPage(t *testing.T) {
+	server := newPageServer(t)
+	fetcher := newPageFetcher(PageFetchConfig{UserAgent: "test-agent"}, true)
+
+	page, err := fetcher.Fetch(context.Background(), server.URL+"/moved")
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if page.URL != server.URL+"/article" {
+		t.Errorf("Expected the URL after redirects, got %q", page.URL)
+	}
+	if page.Text != "test-agent\n\nBody text." {
+		t.Errorf("Expected the page text with the user agent, got %q", page.Text)
+	}
+	if !strings.Contains(page.HTML, "<title>Title</title>") {
+		t.Errorf("Expected the raw HTML, got %q", page.HTML)
+	}
+	if len(page.Images) != 1 || page.Images[0] != server.URL+"/images/photo.jpg" {
+		t.Errorf("Expected one resolved image, got %v", page.Images)
+	}
+
+	page, err = fetcher.Fetch(context.Background(), server.URL+"/notes.txt")
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if page.Text != "Plain <b>text</b>." || len(page.Images) != 0 {
+		t.Errorf("Expected plain text kept as it is, got %q %v", page.Text, page.Images)
+	}
+}
+
+func TestFetchPageBlocked(t *testing.T) {
+	server := newPageServer(t)
+	fetcher := NewPageFetcher(PageFetchConfig{})
+
+	urls := []string{
+		server.URL + "/article",
+		strings.Replace(server.URL, "127.0.0.1", "localhost", 1) + "/article",
+		"http://10.0.0.1/",
+		"http://192.168.1.1/",
+		"http://169.254.169.254/latest/meta-data/",
+		"http://[::1]/",
+		"http://0.0.0.0/",
+		"ftp://example.com/file",
+		"file:///etc/passwd",
+		"/relative/path",
+	}
+	for _, rawURL := range urls {
+		if _, err := fetcher.Fetch(context.Background(), rawURL); !errors.Is(err, ErrPageURL) {
+			t.Errorf("Fetch(%q) error = %v, want ErrPageURL", rawURL, err)
+		}
+	}
+}
+
+func TestFetchPageRefused(t *testing.T) {
+	server := newPageServer(t)
+	fetcher := newPageFetcher(PageFetchConfig{MaxBytes: 500, MaxRedirects: 3}, true)
+
+	tests := []struct {
+		path     string
+		expected error // nil for a failed fetch wrapping none of the sentinels
+	}{
+		{"/ftp", ErrPageURL},
+		{"/large", ErrPageTooLarge},
+		{"/streamed", ErrPageTooLarge},
+		{"/image.png", ErrPageContentType},
+		{"/loop", nil},
+		{"/missing", nil},
+	}
+	for _, tt := range tests {
+		_, err := fetcher.Fetch(context.Background(), server.URL+tt.path)
+		if err == nil {
+			t.Errorf("Fetch(%s) succeeded, want an error", tt.path)
+			continue
+		}
+		if tt.expected != nil && !errors.Is(err, tt.expected) {
+			t.Errorf("Fetch(%s) error = %v, want %v", tt.path, err, tt.expected)
+		}
+		if tt.expected == nil && (errors.Is(err, ErrPageURL) || errors.Is(err, ErrPageTooLarge) || errors.Is(err, ErrPageContentType)) {
+			t.Errorf("Fetch(%s) error = %v, want a failed fetch", tt.path, err)
+		}
+	}
+
+	// Redirects to internal addresses are refused even when the first URL is public
+	first := httptest.NewRequest(http.MethodGet, "https://example.com/metadata", nil)
+	redirect := httptest.NewRequest(http.MethodGet, "http://169.254.169.254/latest/meta-data/", nil)
+	if err := NewPageFetcher(PageFetchConfig{}).client.CheckRedirect(redirect, []*http.Request{first}); !errors.Is(err, ErrPageURL) {
+		t.Errorf("Expected the redirect to a link-local address refused, got %v", err)
+	}
+}
+
+func TestPublicIP(t *testing.T) {
+	tests := []struct {
+		ip     string
+		public bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"::1", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+		{"224.0.0.1", false},
+		{"0.0.0.0", false},
+		{"0.1.2.3", false},         // This network
+		{"100.64.0.1", false},      // Carrier-grade NAT
+		{"100.127.255.254", false}, // Carrier-grade NAT
+		{"100.128.0.1", true},
+		{"198.18.0.1", false}, // Benchmarking
+		{"198.19.255.254", false},
+		{"198.20.0.1", true},
+		{"64:ff9b::a00:1", false},     // NAT64 of 10.0.0.1
+		{"64:ff9b::5db8:d822", false}, // NAT64 of a public address
+		{"::ffff:10.0.0.1", false},
+		{"::ffff:100.64.0.1", false},
+	}
+	for _, tt := range tests {
+		if got := PublicIP(net.ParseIP(tt.ip)); got != tt.public {
+			t.Errorf("PublicIP(%s) = %v, expected %v", tt.ip, got, tt.public)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package analyzer
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Patterns of the phone numbers extractPhoneNumbers reports
+var (
+	// Numbers with a "+" country code, in groups of digits separated by
+	// spaces, dots or hyphens, with an optional trunk prefix such as the
+	// "(0)" of +44 (0)20 7946 0958
+	internationalPhonePattern = regexp.MustCompile(`\+\d{1,3}(?:[ .-]?\(0\))?(?:[ .-]?\(\d{1,4}\)|[ .-]?\d{1,4}){2,6}`)
+	// North American numbers such as (555) 234-5678, 555.234.5678 and
+	// 1-800-555-0199. The groups must be separated, so runs of digits such
+	// as account numbers are not taken for phone numbers.
+	usPhonePattern = regexp.MustCompile(`(?:\b1[ .-]?)?(?:\(\d{3}\)[ .-]?|\b\d{3}[ .-])\d{3}[ .-]\d{4}\b`)
+	// ISBN labels, and the start of the ISBN, before a number, which is
+	// then part of a book number and not a phone number
+	isbnLabelPattern = regexp.MustCompile(`(?i)\bISBN(?:-1[03])?:?[\s\d-]*$`)
+)
+
+// isbnLookBehind is how many bytes before a phone number are searched for
+// an ISBN label
+const isbnLookBehind = 32
+
+// Digits in an E.164 phone number, country code included, and in the
+// shortest international number reported
+const (
+	maxPhoneDigits = 15
+	minPhoneDigits = 8
+)
+
+// phoneMatch is a phone number found in a text
+type phoneMatch struct {
+	start, end int    // Byte offsets of the number as written
+	number     string // E.164 with a country code, as written otherwise
+}
+
+// extractPhoneNumbers extracts the phone numbers in text, deduplicated and
+// in alphabetical order. Numbers with a country code, including North
+// American numbers written with a leading 1, are normalized to E.164 such
+// as +442079460958; others are kept as written.
+func extractPhoneNumbers(text string) []string {
+	unique := make(map[string]bool)
+	for _, match := range findPhoneNumbers(text) {
+		unique[match.number] = true
+	}
+
+	result := []string{}
+	for number := range unique {
+		result = append(result, number)
+	}
+
+	sort.Strings(result)
+	return result
+}
+
+// findPhoneNumbers finds the phone numbers in text, international numbers
+// first and then North American numbers without a "+"
+func findPhoneNumbers(text string) []phoneMatch {
+	var matches []phoneMatch
+	taken := make([]bool, len(text))
+
+	for _, span := range internationalPhonePattern.FindAllStringIndex(text, -1) {
+		end := span[0] + len(strings.TrimRight(text[span[0]:span[1]], " .-"))
+		if !numberStandsAlone(text, span[0], end) {
+			continue
+		}
+		digits := digitsOf(strings.Replace(text[span[0]:end], "(0)", "", 1))
+		if len(digits) < minPhoneDigits || len(digits) > maxPhoneDigits {
+			continue
+		}
+		matches = append(matches, phoneMatch{span[0], end, "+" + digits})
+		markTaken(taken, span[0], end)
+	}
+
+	for _, span := range usPhonePattern.FindAllStringIndex(text, -1) {
+		if taken[span[0]] || !numberStandsAlone(text, span[0], span[1]) {
+			continue
+		}
+		number := text[span[0]:span[1]]
+		digits := digitsOf(number)
+		national := digits[len(digits)-10:]
+		if national[0] < '2' || national[3] < '2' {
+			// Area codes and exchanges never start with 0 or 1
+			continue
+		}
+		if len(digits) == 11 {
+			number = "+" + digits
+		}
+		matches = append(matches, phoneMatch{span[0], span[1], number})
+	}
+
+	return matches
+}
+
+// numberStandsAlone reports whether the number match text[start:end]
+// is neither part of a longer run of digits, such as an ISBN or a product
+// code written with hyphens, nor labeled as an ISBN
+func numberStandsAlone(text string, start, end int) bool {
+	if start > 0 && (isDigit(text[start-1]) || text[start-1] == '+') {
+		return false
+	}
+	if start > 1 && strings.IndexByte("-.", text[start-1]) >= 0 && isDigit(text[start-2]) {
+		return false
+	}
+	if end < len(text) && isDigit(text[end]) {
+		return false
+	}
+	if end+1 < len(text) && strings.IndexByte("-.", text[end]) >= 0 && isDigit(text[end+1]) {
+		return false
+	}
+	return !isbnLabelPattern.MatchString(text[max(0, start-isbnLookBehind):start])
+}
+
+// digitsOf returns the digits of a number as written
+func digitsOf(number string) string {
+	var digits strings.Builder
+	for i := 0; i < len(number); i++ {
+		if isDigit(number[i]) {
+			digits.WriteByte(number[i])
+		}
+	}
+	return digits.String()
+}
+
+// markTaken marks the bytes from start to end as part of a match
+func markTaken(taken []bool, start, end int) {
+	for i := start; i < end; i++ {
+		taken[i] = true
+	}
+}
+
+// isDigit reports whether c is an ASCII digit
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
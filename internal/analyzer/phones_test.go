@@ -0,0 +1,53 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractPhoneNumbers(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		// With a country code, normalized to E.164
+		{"international", "Call +44 20 7946 0958 today.", []string{"+442079460958"}},
+		{"trunk prefix", "Call +44 (0)20 7946 0958.", []string{"+442079460958"}},
+		{"hyphens", "Call +49-30-1234567.", []string{"+49301234567"}},
+		{"dots", "Call +33.1.42.68.53.00 now", []string{"+33142685300"}},
+		{"us with plus", "Call +1 (555) 234-5678.", []string{"+15552345678"}},
+		{"us with leading one", "Toll free: 1-800-555-0199", []string{"+18005550199"}},
+
+		// US numbers without a country code, as written
+		{"us parentheses", "Call (555) 234-5678.", []string{"(555) 234-5678"}},
+		{"us hyphens", "Call 555-234-5678.", []string{"555-234-5678"}},
+		{"us dots", "Call 555.234.5678 now", []string{"555.234.5678"}},
+
+		// Duplicates
+		{"same number twice", "Call +44 20 7946 0958 or +44 (0)20 7946 0958.", []string{"+442079460958"}},
+		{"sorted", "Call 555-234-5678 or +44 20 7946 0958.", []string{"+442079460958", "555-234-5678"}},
+
+		// Not phone numbers
+		{"isbn 13", "ISBN 978-0-306-40615-7", []string{}},
+		{"isbn labeled", "ISBN-10: 030-640-6152 in print", []string{}},
+		{"isbn grouped like a phone", "ISBN 978 555 234 5678", []string{}},
+		{"years", "From 1999 to 2024, and again in 2025 2026 2027.", []string{}},
+		{"year range", "Records for 2019-2020-2021 and 1999-2004.", []string{}},
+		{"date", "Filed on 2024-03-15 at 10:30.", []string{}},
+		{"digit run", "Order 5552345678 shipped.", []string{}},
+		{"longer hyphenated code", "Part 12-555-234-5678-9 is out.", []string{}},
+		{"area code starting with 1", "Call 155-234-5678.", []string{}},
+		{"exchange starting with 0", "Call 555-034-5678.", []string{}},
+		{"short international", "Room +12 34", []string{}},
+		{"money", "It cost $1,234.56 in 2024.", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := extractPhoneNumbers(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("extractPhoneNumbers(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package analyzer
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/docutag/textanalyzer/internal/models"
+)
+
+// SetRedactPII sets whether personal data is masked in the text sent to the
+// model for every analysis, rather than only for analyses that request it
+func (a *Analyzer) SetRedactPII(redact bool) {
+	a.redactPII = redact
+}
+
+// redactsPII reports whether personal data is masked in the text an
+// analysis with opts sends to the model
+func (a *Analyzer) redactsPII(opts AnalysisOptions) bool {
+	return a.redactPII || opts.RedactPII
+}
+
+// ModelText returns text as an analysis with opts may send it to the model,
+// with personal data masked when redaction is configured or requested
+func (a *Analyzer) ModelText(text string, opts AnalysisOptions) string {
+	if a.redactsPII(opts) {
+		return redactPII(text)
+	}
+	return text
+}
+
+// Patterns of the personal data findPII reports, besides email addresses
+// and phone numbers
+var (
+	// US Social Security numbers, with hyphens or spaces
+	ssnPattern = regexp.MustCompile(`\b\d{3}([- ])\d{2}[- ]\d{4}\b`)
+	// Card numbers in groups of four, Amex numbers in groups of 4, 6 and 5,
+	// and unseparated numbers of 13 to 19 digits
+	cardPattern = regexp.MustCompile(`\b(?:\d{4}[ -]?){3}\d{1,7}\b|\b\d{4}[ -]?\d{6}[ -]?\d{5}\b`)
+	// IBANs, unseparated or in groups of four
+	ibanPattern = regexp.MustCompile(`\b[A-Z]{2}\d{2}(?: ?[A-Z0-9]{4}){2,7}(?: ?[A-Z0-9]{1,3})?\b`)
+	// A house number, one to four capitalized words and a street type, as
+	// in 221B Baker Street and 1600 Pennsylvania Ave.
+	addressPattern = regexp.MustCompile(`\b\d{1,5}[A-Za-z]?(?:\s+[A-Z][A-Za-z'-]*){1,4}\s+` +
+		`(?:Street|St|Avenue|Ave|Road|Rd|Boulevard|Blvd|Lane|Ln|Drive|Dr|Court|Ct|Place|Pl|Way|Terrace|Parkway|Pkwy|Highway|Hwy|Square|Sq)\b\.?`)
+)
+
+// Lengths of card numbers and IBANs
+const (
+	minCardDigits = 13
+	maxCardDigits = 19
+	minIBANLength = 15
+	maxIBANLength = 34
+)
+
+// piiSpan is personal data found in a text
+type piiSpan struct {
+	start, end int // Byte offsets
+	category   string
+}
+
+// findPII finds the personal data in text in order of appearance. Where
+// matches overlap, the first category found wins: email addresses, IBANs,
+// card numbers, Social Security numbers, phone numbers and then street
+// addresses.
+func findPII(text string) []piiSpan {
+	var spans []piiSpan
+	taken := make([]bool, len(text))
+	add := func(start, end int, category string) {
+		for i := start; i < end; i++ {
+			if taken[i] {
+				return
+			}
+		}
+		markTaken(taken, start, end)
+		spans = append(spans, piiSpan{start, end, category})
+	}
+
+	for _, m := range emailPattern.FindAllStringIndex(text, -1) {
+		add(m[0], m[1], models.PIIEmail)
+	}
+	for _, m := range ibanPattern.FindAllStringIndex(text, -1) {
+		if validIBAN(text[m[0]:m[1]]) {
+			add(m[0], m[1], models.PIIIBAN)
+		}
+	}
+	for _, m := range cardPattern.FindAllStringIndex(text, -1) {
+		if numberStandsAlone(text, m[0], m[1]) && validCardNumber(digitsOf(text[m[0]:m[1]])) {
+			add(m[0], m[1], models.PIICreditCard)
+		}
+	}
+	for _, m := range ssnPattern.FindAllStringSubmatchIndex(text, -1) {
+		if numberStandsAlone(text, m[0], m[1]) && validSSN(text[m[0]:m[1]], text[m[2]:m[3]]) {
+			add(m[0], m[1], models.PIISSN)
+		}
+	}
+	for _, match := range findPhoneNumbers(text) {
+		add(match.start, match.end, models.PIIPhone)
+	}
+	for _, m := range addressPattern.FindAllStringIndex(text, -1) {
+		add(m[0], m[1], models.PIIAddress)
+	}
+
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	return spans
+}
+
+// summarizePII counts the personal data in text by category
+func summarizePII(text string) *models.PIISummary {
+	summary := &models.PIISummary{}
+	for _, span := range findPII(text) {
+		if summary.Counts == nil {
+			summary.Counts = make(map[string]int)
+		}
+		summary.Counts[span.category]++
+	}
+	summary.ContainsPII = len(summary.Counts) > 0
+	return summary
+}
+
+// redactPII masks the personal data in text, replacing every letter and
+// digit with "X" and keeping separators such as "@" and "-", so the masked
+// text has the same length in runes and the same offsets as text. Texts
+// without personal data are returned unchanged.
+func redactPII(text string) string {
+	spans := findPII(text)
+	if len(spans) == 0 {
+		return text
+	}
+
+	var masked strings.Builder
+	masked.Grow(len(text))
+	last := 0
+	for _, span := range spans {
+		masked.WriteString(text[last:span.start])
+		for _, r := range text[span.start:span.end] {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				r = 'X'
+			}
+			masked.WriteRune(r)
+		}
+		last = span.end
+	}
+	masked.WriteString(text[last:])
+	return masked.String()
+}
+
+// validSSN reports whether ssn, separated by sep, can be a Social Security
+// number: both separators match, and no part is all zeros or an area number
+// that is never assigned (666 and 900 and above)
+func validSSN(ssn, sep string) bool {
+	if strings.Count(ssn, sep) != 2 {
+		return false
+	}
+	parts := strings.Split(ssn, sep)
+	area, group, serial := parts[0], parts[1], parts[2]
+	return area != "000" && area != "666" && area[0] != '9' && group != "00" && serial != "0000"
+}
+
+// validCardNumber reports whether digits can be a payment card number: 13
+// to 19 digits starting with 2 to 6, as issued by the major networks, that
+// pass the Luhn check
+func validCardNumber(digits string) bool {
+	if len(digits) < minCardDigits || len(digits) > maxCardDigits || digits[0] < '2' || digits[0] > '6' {
+		return false
+	}
+	sum := 0
+	for i := 0; i < len(digits); i++ {
+		d := int(digits[len(digits)-1-i] - '0')
+		if i%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// validIBAN reports whether iban, with or without spaces, has a valid
+// length and ISO 13616 check digits
+func validIBAN(iban string) bool {
+	iban = strings.ReplaceAll(iban, " ", "")
+	if len(iban) < minIBANLength || len(iban) > maxIBANLength {
+		return false
+	}
+
+	// The country code and check digits move to the end, letters count as
+	// 10 to 35, and the number must leave a remainder of 1 modulo 97
+	remainder := 0
+	for _, c := range iban[4:] + iban[:4] {
+		switch {
+		case c >= '0' && c <= '9':
+			remainder = (remainder*10 + int(c-'0')) % 97
+		case c >= 'A' && c <= 'Z':
+			remainder = (remainder*100 + int(c-'A') + 10) % 97
+		default:
+			return false
+		}
+	}
+	return remainder == 1
+}
@@ -0,0 +1,345 @@
+package analyzer
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/docutag/textanalyzer/internal/models"
+	"github.com/docutag/textanalyzer/internal/ollama"
+)
+
+// Fixture SSNs, which must never reach a prompt when redaction is on
+const (
+	piiSSN      = "123-45-6789"
+	piiSSNSpace = "219 09 9999"
+)
+
+// piiFixture holds personal data of every category
+const piiFixture = "Jane Roe (SSN " + piiSSN + ", also filed as " + piiSSNSpace + ") lives at 742 Evergreen Terrace " +
+	"and can be reached at jane.roe@example.com or +1 (555) 234-5678. She paid with card 4111 1111 1111 1111 " +
+	"and was refunded to DE89 3704 0044 0532 0130 00. The city council approved the new budget on Tuesday " +
+	"after a long debate about public transport, schools and the parks department."
+
+func TestFindPII(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		category string // Empty when nothing is found
+		match    string
+	}{
+		{"email", "Write to jane.roe@example.com today", models.PIIEmail, "jane.roe@example.com"},
+		{"phone", "Call (555) 234-5678 today", models.PIIPhone, "(555) 234-5678"},
+		{"international phone", "Call +44 20 7946 0958 today", models.PIIPhone, "+44 20 7946 0958"},
+		{"ssn", "SSN " + piiSSN + " on file", models.PIISSN, piiSSN},
+		{"ssn with spaces", "SSN " + piiSSNSpace + " on file", models.PIISSN, piiSSNSpace},
+		{"visa", "Card 4111 1111 1111 1111 on file", models.PIICreditCard, "4111 1111 1111 1111"},
+		{"visa with hyphens", "Card 4111-1111-1111-1111 on file", models.PIICreditCard, "4111-1111-1111-1111"},
+		{"visa unseparated", "Card 4111111111111111 on file", models.PIICreditCard, "4111111111111111"},
+		{"amex", "Card 3782 822463 10005 on file", models.PIICreditCard, "3782 822463 10005"},
+		{"iban", "Pay DE89 3704 0044 0532 0130 00 today", models.PIIIBAN, "DE89 3704 0044 0532 0130 00"},
+		{"iban unseparated", "Pay GB82WEST12345698765432 today", models.PIIIBAN, "GB82WEST12345698765432"},
+		{"address", "She lives at 742 Evergreen Terrace now", models.PIIAddress, "742 Evergreen Terrace"},
+		{"address abbreviated", "Mail 1600 Pennsylvania Ave. NW", models.PIIAddress, "1600 Pennsylvania Ave."},
+		{"address with letter", "Visit 221B Baker Street, London", models.PIIAddress, "221B Baker Street"},
+
+		// Numbers that only look like personal data
+		{"card failing luhn", "Card 4111 1111 1111 1112 on file", "", ""},
+		{"isbn", "ISBN 978-0-306-40615-7 in print", "", ""},
+		{"isbn passing luhn", "Order 9780306406157 shipped", "", ""},
+		{"iban with bad check digits", "Pay DE88 3704 0044 0532 0130 00 today", "", ""},
+		{"ssn with area 000", "Ref 000-12-3456 only", "", ""},
+		{"ssn with area 666", "Ref 666-12-3456 only", "", ""},
+		{"ssn with area 9xx", "Ref 912-34-5678 only", "", ""},
+		{"ssn with mixed separators", "Ref 123-45 6789 only", "", ""},
+		{"date", "Filed on 2024-03-15", "", ""},
+		{"years", "From 1999 to 2024 and 2025", "", ""},
+		{"money", "It cost $1,234.56", "", ""},
+		{"street without number", "Main Street is closed", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spans := findPII(tt.text)
+			if tt.category == "" {
+				if len(spans) != 0 {
+					t.Errorf("Expected no personal data in %q, got %q as %s", tt.text,
+						tt.text[spans[0].start:spans[0].end], spans[0].category)
+				}
+				return
+			}
+			if len(spans) != 1 {
+				t.Fatalf("Expected one match in %q, got %+v", tt.text, spans)
+			}
+			if got := tt.text[spans[0].start:spans[0].end]; got != tt.match || spans[0].category != tt.category {
+				t.Errorf("Expected %q as %s, got %q as %s", tt.match, tt.category, got, spans[0].category)
+			}
+		})
+	}
+}
+
+func TestPIIInMetadata(t *testing.T) {
+	want := &models.PIISummary{
+		ContainsPII: true,
+		Counts: map[string]int{
+			models.PIIEmail:      1,
+			models.PIIPhone:      1,
+			models.PIISSN:        2,
+			models.PIICreditCard: 1,
+			models.PIIIBAN:       1,
+			models.PIIAddress:    1,
+		},
+	}
+	if got := New().AnalyzeOffline(piiFixture).PII; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+
+	clean := New().AnalyzeOffline("The council met on Tuesday to discuss the budget.").PII
+	if clean == nil || clean.ContainsPII || clean.Counts != nil {
+		t.Errorf("Expected no personal data, got %+v", clean)
+	}
+}
+
+func TestPIIInMarkdown(t *testing.T) {
+	text := "# Contact\n\nWrite to [the desk](mailto:desk@example.com) for details.\n\n" +
+		"```\nowner: jane.roe@example.com\nphone: +1 (555) 234-5678\n```\n"
+
+	metadata := New().AnalyzeOfflineWithOptions(text, OfflineOptions{Format: FormatMarkdown})
+	if metadata.Format != FormatMarkdown {
+		t.Fatalf("Expected the text read as Markdown, got %q", metadata.Format)
+	}
+	// Link targets and code blocks are not blanked out for personal data
+	wantEmails := []string{"desk@example.com", "jane.roe@example.com"}
+	if !reflect.DeepEqual(metadata.EmailAddresses, wantEmails) {
+		t.Errorf("Expected emails %v, got %v", wantEmails, metadata.EmailAddresses)
+	}
+	if len(metadata.PhoneNumbers) != 1 {
+		t.Errorf("Expected the phone number in the code block, got %v", metadata.PhoneNumbers)
+	}
+	if metadata.PII == nil || metadata.PII.Counts[models.PIIEmail] != 2 || metadata.PII.Counts[models.PIIPhone] != 1 {
+		t.Errorf("Expected 2 emails and 1 phone number counted, got %+v", metadata.PII)
+	}
+}
+
+func TestRedactPII(t *testing.T) {
+	redacted := redactPII(piiFixture)
+
+	for _, pii := range []string{piiSSN, piiSSNSpace, "742 Evergreen", "jane.roe", "234-5678", "4111", "DE89"} {
+		if strings.Contains(redacted, pii) {
+			t.Errorf("Expected %q masked, got %q", pii, redacted)
+		}
+	}
+	// Separators stay, so the masked text keeps its shape and offsets
+	for _, masked := range []string{"XXX-XX-XXXX", "XXX XX XXXX", "XXXX.XXX@XXXXXXX.XXX", "+X (XXX) XXX-XXXX"} {
+		if !strings.Contains(redacted, masked) {
+			t.Errorf("Expected %q in %q", masked, redacted)
+		}
+	}
+	if !strings.HasPrefix(redacted, "Jane Roe (SSN XXX-XX-XXXX") || !strings.Contains(redacted, "The city council approved") {
+		t.Errorf("Expected text around personal data unchanged, got %q", redacted)
+	}
+	if len(redacted) != len(piiFixture) {
+		t.Errorf("Expected %d bytes, got %d", len(piiFixture), len(redacted))
+	}
+
+	// Offsets hold in runes around letters outside ASCII
+	text := "Écrire à elodie@exemple.fr ou appeler le +33 1 42 68 53 00."
+	if got := redactPII(text); utf8.RuneCountInString(got) != utf8.RuneCountInString(text) ||
+		!strings.HasPrefix(got, "Écrire à XXXXXX@XXXXXXX.XX") || !strings.Contains(got, "+XX X XX XX XX XX.") {
+		t.Errorf("Expected personal data masked rune for rune, got %q", got)
+	}
+
+	if text := "Nothing personal here."; redactPII(text) != text {
+		t.Errorf("Expected text without personal data unchanged")
+	}
+}
+
+// promptRecorder is a mockLLM that records every text it is sent
+type promptRecorder struct {
+	mockLLM
+	mu      sync.Mutex
+	prompts []string
+}
+
+func (p *promptRecorder) record(texts ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.prompts = append(p.prompts, texts...)
+}
+
+func (p *promptRecorder) GenerateSynopsis(ctx context.Context, text, style string, maxWords int) (string, error) {
+	p.record(text)
+	return p.mockLLM.GenerateSynopsis(ctx, text, style, maxWords)
+}
+
+func (p *promptRecorder) CleanText(ctx context.Context, text string) (string, error) {
+	p.record(text)
+	return p.mockLLM.CleanText(ctx, text)
+}
+
+func (p *promptRecorder) CleanTextWithHTMLContext(ctx context.Context, text, offlineText, originalHTML string) (string, error) {
+	p.record(text, offlineText, originalHTML)
+	return p.mockLLM.CleanTextWithHTMLContext(ctx, text, offlineText, originalHTML)
+}
+
+func (p *promptRecorder) EditorialAnalysis(ctx context.Context, text string) (*ollama.EditorialResult, error) {
+	p.record(text)
+	return p.mockLLM.EditorialAnalysis(ctx, text)
+}
+
+func (p *promptRecorder) GenerateTags(ctx context.Context, text string, metadata map[string]interface{}) ([]string, error) {
+	p.record(text)
+	return p.mockLLM.GenerateTags(ctx, text, metadata)
+}
+
+func (p *promptRecorder) ExtractReferences(ctx context.Context, text string) ([]ollama.Reference, error) {
+	p.record(text)
+	return p.mockLLM.ExtractReferences(ctx, text)
+}
+
+func (p *promptRecorder) DetectAIContent(ctx context.Context, text string) (*ollama.AIDetectionResult, error) {
+	p.record(text)
+	return p.mockLLM.DetectAIContent(ctx, text)
+}
+
+func (p *promptRecorder) ScoreTextQuality(ctx context.Context, text string) (*ollama.TextQualityScoreResult, error) {
+	p.record(text)
+	return p.mockLLM.ScoreTextQuality(ctx, text)
+}
+
+// containsSSN reports whether any prompt holds a fixture SSN
+func (p *promptRecorder) containsSSN() bool {
+	for _, prompt := range p.prompts {
+		if strings.Contains(prompt, piiSSN) || strings.Contains(prompt, piiSSNSpace) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRedactedPrompts(t *testing.T) {
+	html := "<p>" + piiFixture + "</p>"
+	offlineText := New().CleanTextOfflineWithReport(piiFixture).CleanedText
+
+	tests := []struct {
+		name     string
+		global   bool // Analyzer setting
+		request  bool // Per-analysis option
+		html     bool // Analyze with HTML context
+		redacted bool
+	}{
+		{"off", false, false, false, false},
+		{"off with HTML", false, false, true, false},
+		{"requested", false, true, false, true},
+		{"requested with HTML", false, true, true, true},
+		{"configured", true, false, false, true},
+		{"configured with HTML", true, false, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Failing cleaning leaves every step to read the text itself
+			recorder := &promptRecorder{mockLLM: mockLLM{fail: map[string]bool{"CleanText": true, "CleanTextWithHTMLContext": true}}}
+			a := NewWithOllama(recorder)
+			a.SetRedactPII(tt.global)
+			opts := AnalysisOptions{Force: true, RedactPII: tt.request}
+
+			var metadata models.Metadata
+			if tt.html {
+				metadata = a.AnalyzeWithHTMLContext(context.Background(), piiFixture, offlineText, html, opts)
+			} else {
+				metadata = a.AnalyzeWithOptions(context.Background(), piiFixture, opts)
+			}
+
+			if len(recorder.prompts) == 0 {
+				t.Fatal("Expected prompts to be sent")
+			}
+			if recorder.containsSSN() == tt.redacted {
+				t.Errorf("Expected fixture SSNs in prompts: %v, got prompts %q", !tt.redacted, recorder.prompts)
+			}
+			// Rule-based results read the original
+			if metadata.PII == nil || metadata.PII.Counts[models.PIISSN] != 2 {
+				t.Errorf("Expected the original SSNs counted, got %+v", metadata.PII)
+			}
+			if !reflect.DeepEqual(metadata.EmailAddresses, []string{"jane.roe@example.com"}) {
+				t.Errorf("Expected the original email address extracted, got %v", metadata.EmailAddresses)
+			}
+		})
+	}
+}
+
+func TestRedactedShadowPrompts(t *testing.T) {
+	recorder := &promptRecorder{}
+	primary := New().AnalyzeOffline(piiFixture)
+	primary.RedactPII = true
+
+	if _, err := New().ShadowEnrich(context.Background(), recorder, piiFixture, primary); err != nil {
+		t.Fatalf("ShadowEnrich failed: %v", err)
+	}
+	if len(recorder.prompts) == 0 || recorder.containsSSN() {
+		t.Errorf("Expected masked shadow prompts, got %q", recorder.prompts)
+	}
+}
+
+func TestModelText(t *testing.T) {
+	configured := New()
+	configured.SetRedactPII(true)
+
+	tests := []struct {
+		name     string
+		analyzer *Analyzer
+		opts     AnalysisOptions
+		redacted bool
+	}{
+		{"off", New(), AnalysisOptions{}, false},
+		{"requested", New(), AnalysisOptions{RedactPII: true}, true},
+		{"configured", configured, AnalysisOptions{}, true},
+	}
+
+	for _, tt := range tests {
+		got := tt.analyzer.ModelText(piiFixture, tt.opts)
+		if masked := !strings.Contains(got, piiSSN); masked != tt.redacted {
+			t.Errorf("%s: expected masked %v, got %q", tt.name, tt.redacted, got)
+		}
+	}
+}
+
+func TestRecordedOptionsRedactPII(t *testing.T) {
+	if !RecordedOptions(models.Metadata{RedactPII: true}).RedactPII {
+		t.Error("Expected redact_pii recorded on the analysis to carry over to enrichment")
+	}
+}
+
+func TestValidCardNumber(t *testing.T) {
+	for digits, want := range map[string]bool{
+		"4111111111111111":     true,
+		"5555555555554444":     true,
+		"378282246310005":      true,
+		"6011111111111117":     true,
+		"4111111111111112":     false,
+		"9780306406157":        false, // Passes Luhn, but no card starts with 9
+		"411111111111":         false,
+		"41111111111111111111": false,
+	} {
+		if got := validCardNumber(digits); got != want {
+			t.Errorf("validCardNumber(%q) = %v, want %v", digits, got, want)
+		}
+	}
+}
+
+func TestValidIBAN(t *testing.T) {
+	for iban, want := range map[string]bool{
+		"DE89 3704 0044 0532 0130 00": true,
+		"GB82WEST12345698765432":      true,
+		"FR1420041010050500013M02606": true,
+		"DE88 3704 0044 0532 0130 00": false,
+		"GB82WEST1234":                false,
+	} {
+		if got := validIBAN(iban); got != want {
+			t.Errorf("validIBAN(%q) = %v, want %v", iban, got, want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package analyzer
+
+import (
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/docutag/textanalyzer/internal/models"
+	"github.com/docutag/textanalyzer/internal/ollama"
+)
+
+// SetServiceVersion sets the service version recorded in provenance snapshots
+func (a *Analyzer) SetServiceVersion(version string) {
+	a.serviceVersion = version
+}
+
+// Provenance returns a snapshot of the configuration an enrichment with opts
+// runs under: the model and prompt templates, the options and the analyzer
+// limits. Rule-based analysis records no model or prompt hashes.
+func (a *Analyzer) Provenance(opts AnalysisOptions) *models.Provenance {
+	provenance := &models.Provenance{
+		Model:               a.ModelName(),
+		EnrichmentThreshold: opts.Threshold,
+		EnrichmentSteps:     EnabledSteps(ResolveEnrichment(opts.Enrichment)),
+		SynopsisStyle:       opts.Synopsis.Style,
+		SynopsisMaxWords:    opts.Synopsis.MaxWords,
+		MaxSections:         a.maxSections,
+		MaxTrackedWords:     a.wordLimit(),
+		MaxTrackedPhrases:   a.phraseLimit(),
+		ServiceVersion:      a.serviceVersion,
+		CapturedAt:          time.Now(),
+	}
+	if provenance.MaxSections <= 0 {
+		provenance.MaxSections = DefaultMaxSections
+	}
+	if a.llmClient != nil {
+		provenance.PromptHashes = ollama.PromptHashes()
+	}
+	return provenance
+}
+
+// ProvenanceChanges lists the settings that differ between a stored snapshot
+// and the current one by their JSON names, with changed prompts reported as
+// "prompt_hashes.<step>". The service version and capture time are ignored,
+// since neither changes results by itself. A nil stored snapshot, from
+// analyses enriched before provenance was recorded, reports "provenance".
+func ProvenanceChanges(stored, current *models.Provenance) []string {
+	if stored == nil {
+		return []string{"provenance"}
+	}
+
+	changes := []string{}
+	if stored.Model != current.Model {
+		changes = append(changes, "model")
+	}
+	if stored.EnrichmentThreshold != current.EnrichmentThreshold {
+		changes = append(changes, "enrichment_threshold")
+	}
+	if !slices.Equal(stored.EnrichmentSteps, current.EnrichmentSteps) {
+		changes = append(changes, "enrichment_steps")
+	}
+	if stored.SynopsisStyle != current.SynopsisStyle {
+		changes = append(changes, "synopsis_style")
+	}
+	if stored.SynopsisMaxWords != current.SynopsisMaxWords {
+		changes = append(changes, "synopsis_max_words")
+	}
+	if stored.MaxSections != current.MaxSections {
+		changes = append(changes, "max_sections")
+	}
+	if stored.MaxTrackedWords != current.MaxTrackedWords {
+		changes = append(changes, "max_tracked_words")
+	}
+	if stored.MaxTrackedPhrases != current.MaxTrackedPhrases {
+		changes = append(changes, "max_tracked_phrases")
+	}
+
+	var prompts []string
+	for step, hash := range current.PromptHashes {
+		if stored.PromptHashes[step] != hash {
+			prompts = append(prompts, "prompt_hashes."+step)
+		}
+	}
+	for step := range stored.PromptHashes {
+		if _, ok := current.PromptHashes[step]; !ok {
+			prompts = append(prompts, "prompt_hashes."+step)
+		}
+	}
+	sort.Strings(prompts)
+	return append(changes, prompts...)
+}
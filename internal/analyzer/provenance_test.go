@@ -0,0 +1,98 @@
+package analyzer
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/docutag/textanalyzer/internal/models"
+	"github.com/docutag/textanalyzer/internal/ollama"
+)
+
+func TestProvenanceAfterEnrichment(t *testing.T) {
+	a := newStepOllamaAnalyzer(t, stepResponses)
+	a.SetServiceVersion("1.2.3")
+	a.SetMaxSections(4)
+	a.SetFrequencyLimits(1000, 0)
+
+	opts := AnalysisOptions{
+		Threshold:  0.15,
+		Synopsis:   SynopsisOptions{Style: ollama.SynopsisTeaser, MaxWords: 30},
+		Enrichment: &models.EnrichmentOptions{Synopsis: true, Tags: true},
+	}
+	metadata := a.AnalyzeWithOptions(context.Background(), synopsisFixture, opts)
+	if AggregateEnrichmentStatus(metadata.EnrichmentStatus) != EnrichmentStatusDone {
+		t.Fatalf("Expected the fake enrichment to succeed, got %v", metadata.EnrichmentStatus)
+	}
+
+	provenance := a.Provenance(opts)
+	if provenance.Model != "test-model" {
+		t.Errorf("Expected model test-model, got %q", provenance.Model)
+	}
+	if !reflect.DeepEqual(provenance.PromptHashes, ollama.PromptHashes()) {
+		t.Errorf("Expected the current prompt hashes, got %v", provenance.PromptHashes)
+	}
+	if provenance.EnrichmentThreshold != 0.15 {
+		t.Errorf("Expected threshold 0.15, got %v", provenance.EnrichmentThreshold)
+	}
+	if want := []string{StepSynopsis, StepTags}; !reflect.DeepEqual(provenance.EnrichmentSteps, want) {
+		t.Errorf("Expected steps %v, got %v", want, provenance.EnrichmentSteps)
+	}
+	if provenance.SynopsisStyle != ollama.SynopsisTeaser || provenance.SynopsisMaxWords != 30 {
+		t.Errorf("Expected a 30 word teaser synopsis, got %q and %d", provenance.SynopsisStyle, provenance.SynopsisMaxWords)
+	}
+	if provenance.MaxSections != 4 || provenance.MaxTrackedWords != 1000 || provenance.MaxTrackedPhrases != DefaultMaxTrackedPhrases {
+		t.Errorf("Expected analyzer limits 4, 1000 and %d, got %d, %d and %d", DefaultMaxTrackedPhrases,
+			provenance.MaxSections, provenance.MaxTrackedWords, provenance.MaxTrackedPhrases)
+	}
+	if provenance.ServiceVersion != "1.2.3" {
+		t.Errorf("Expected service version 1.2.3, got %q", provenance.ServiceVersion)
+	}
+	if provenance.CapturedAt.IsZero() {
+		t.Error("Expected the capture time to be set")
+	}
+}
+
+func TestProvenanceWithoutOllama(t *testing.T) {
+	provenance := New().Provenance(AnalysisOptions{Threshold: DefaultEnrichmentThreshold})
+
+	if provenance.Model != "" || provenance.PromptHashes != nil {
+		t.Errorf("Expected no model or prompt hashes, got %q and %v", provenance.Model, provenance.PromptHashes)
+	}
+	if !reflect.DeepEqual(provenance.EnrichmentSteps, EnrichmentSteps) {
+		t.Errorf("Expected every step enabled by default, got %v", provenance.EnrichmentSteps)
+	}
+	if provenance.MaxSections != DefaultMaxSections {
+		t.Errorf("Expected %d max sections, got %d", DefaultMaxSections, provenance.MaxSections)
+	}
+}
+
+func TestProvenanceChanges(t *testing.T) {
+	current := &models.Provenance{
+		Model:               "model-b",
+		PromptHashes:        map[string]string{"synopsis": "aaa", "tags": "bbb"},
+		EnrichmentThreshold: 0.3,
+		EnrichmentSteps:     EnrichmentSteps,
+		MaxSections:         DefaultMaxSections,
+		ServiceVersion:      "1.1.0",
+	}
+
+	same := *current
+	same.ServiceVersion = "1.0.0"
+	if changes := ProvenanceChanges(&same, current); len(changes) != 0 {
+		t.Errorf("Expected no changes for a different service version, got %v", changes)
+	}
+
+	stored := *current
+	stored.Model = "model-a"
+	stored.PromptHashes = map[string]string{"synopsis": "aaa", "tags": "old", "legacy": "ccc"}
+	stored.EnrichmentSteps = []string{StepSynopsis}
+	want := []string{"model", "enrichment_steps", "prompt_hashes.legacy", "prompt_hashes.tags"}
+	if changes := ProvenanceChanges(&stored, current); !reflect.DeepEqual(changes, want) {
+		t.Errorf("Expected changes %v, got %v", want, changes)
+	}
+
+	if changes := ProvenanceChanges(nil, current); !reflect.DeepEqual(changes, []string{"provenance"}) {
+		t.Errorf("Expected a missing snapshot to be reported, got %v", changes)
+	}
+}
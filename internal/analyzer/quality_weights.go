@@ -0,0 +1,101 @@
+package analyzer
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/docutag/textanalyzer/internal/models"
+)
+
+// QualityWeights are the adjustments the rule-based quality scorer makes to
+// its starting score. Bonuses are added and penalties subtracted when a
+// check fires; the result is clamped to [0, 1]. Exposed so the evaluation
+// harness can sweep them against a labeled corpus.
+type QualityWeights struct {
+	Base          float64 // Starting score
+	TooShortScore float64 // Score for content under 50 characters, returned as is
+
+	// Length
+	FewWordsPenalty  float64 // Under 20 words
+	ShortPenalty     float64 // Under 50 words
+	SubstantialBonus float64 // Over 200 words
+
+	// Coherence
+	ListLikePenalty            float64 // Disconnected, list-like sentences
+	WeakContinuityPenalty      float64 // Many sentences sharing no words with the previous one
+	TransitionsBonus           float64 // Frequent transition words
+	FewTransitionsPenalty      float64 // Almost no transition words in a longer text
+	CoherenceMarkersBonus      float64 // Pronouns and references in a healthy proportion
+	FewCoherenceMarkersPenalty float64 // Almost no pronouns or references in a longer text
+
+	// Spam and noise
+	SpamPenalty                float64 // More than three spam phrases
+	PromotionalPenalty         float64 // One to three spam phrases
+	ExclamationPenalty         float64 // Excessive exclamation marks
+	CapitalizationPenalty      float64 // Mostly capital letters
+	GibberishPenalty           float64 // Excessive repeated characters
+	ExcessiveDatesPenalty      float64 // Mostly dates, as in listings
+	DoubleSpacingPenalty       float64 // Most sentences double spaced
+	InconsistentSpacingPenalty float64 // Many sentences double spaced
+
+	// Readability and style
+	ReadabilityBonus      float64 // Flesch score between 60 and 70
+	ReadabilityPenalty    float64 // Flesch score under 30 or over 80
+	SentenceLengthBonus   float64 // 10 to 25 words per sentence
+	SentenceLengthPenalty float64 // Under 5 or over 40 words per sentence
+	AcademicBonus         float64 // Three or more research and evidence terms
+}
+
+// DefaultQualityWeights returns the weights the rule-based scorer uses
+func DefaultQualityWeights() QualityWeights {
+	return QualityWeights{
+		Base:                       0.5,
+		TooShortScore:              0.1,
+		FewWordsPenalty:            0.3,
+		ShortPenalty:               0.1,
+		SubstantialBonus:           0.2,
+		ListLikePenalty:            0.4,
+		WeakContinuityPenalty:      0.2,
+		TransitionsBonus:           0.1,
+		FewTransitionsPenalty:      0.15,
+		CoherenceMarkersBonus:      0.1,
+		FewCoherenceMarkersPenalty: 0.1,
+		SpamPenalty:                0.4,
+		PromotionalPenalty:         0.2,
+		ExclamationPenalty:         0.2,
+		CapitalizationPenalty:      0.3,
+		GibberishPenalty:           0.3,
+		ExcessiveDatesPenalty:      0.3,
+		DoubleSpacingPenalty:       0.3,
+		InconsistentSpacingPenalty: 0.1,
+		ReadabilityBonus:           0.1,
+		ReadabilityPenalty:         0.1,
+		SentenceLengthBonus:        0.1,
+		SentenceLengthPenalty:      0.1,
+		AcademicBonus:              0.2,
+	}
+}
+
+// With returns a copy of w with the named weight, e.g. "SpamPenalty", set to value
+func (w QualityWeights) With(name string, value float64) (QualityWeights, error) {
+	field := reflect.ValueOf(&w).Elem().FieldByName(name)
+	if !field.IsValid() {
+		return w, fmt.Errorf("unknown quality weight %q", name)
+	}
+	field.SetFloat(value)
+	return w, nil
+}
+
+// ScoreQualityRuleBased scores text with the rule-based quality checks using
+// weights, as enrichment does when Ollama is unavailable
+func (a *Analyzer) ScoreQualityRuleBased(text string, weights QualityWeights) models.TextQualityScore {
+	if isDegenerate(text) {
+		return emptyInputQualityScore()
+	}
+
+	words := extractWords(text)
+	metadata := models.Metadata{WordCount: len(words), SentenceCount: countSentences(text)}
+	applyLanguage(&metadata, text)
+	a.applyReadability(&metadata, text, words)
+	return scoreTextQualityWeighted(text, metadata.WordCount, fleschScore(metadata), a.coherenceMetrics(text, words), metadata.Language, weights)
+}
@@ -0,0 +1,44 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScoreQualityRuleBasedMatchesOfflineAnalysis(t *testing.T) {
+	a := New()
+	texts := []string{
+		synopsisFixture,
+		"CLICK HERE to claim your FREE prize!!! Limited offer, act now! Buy now and earn $$$ from home. Click here!",
+		"Too short.",
+		"   ",
+	}
+
+	for _, text := range texts {
+		want := *a.AnalyzeOffline(text).QualityScore
+		if got := a.ScoreQualityRuleBased(text, DefaultQualityWeights()); !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected default weights to score %q as offline analysis does (%v), got %v", text, want.Score, got.Score)
+		}
+	}
+}
+
+func TestQualityWeightsWith(t *testing.T) {
+	weights, err := DefaultQualityWeights().With("PromotionalPenalty", 0.4)
+	if err != nil {
+		t.Fatalf("With failed: %v", err)
+	}
+	if weights.PromotionalPenalty != 0.4 || weights.Base != DefaultQualityWeights().Base {
+		t.Errorf("Expected only the promotional penalty to change, got %+v", weights)
+	}
+
+	promotional := synopsisFixture + " Click here to subscribe."
+	lenient := New().ScoreQualityRuleBased(promotional, DefaultQualityWeights())
+	strict := New().ScoreQualityRuleBased(promotional, weights)
+	if strict.Score >= lenient.Score {
+		t.Errorf("Expected a heavier promotional penalty to lower the score, got %.2f and %.2f", lenient.Score, strict.Score)
+	}
+
+	if _, err := DefaultQualityWeights().With("Nonsense", 1); err == nil {
+		t.Error("Expected an error for an unknown weight")
+	}
+}
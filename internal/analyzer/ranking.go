@@ -0,0 +1,81 @@
+package analyzer
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// partialSelectFactor controls when topRanked switches from a full sort to a
+// bounded heap: inputs larger than partialSelectFactor*k use the heap
+const partialSelectFactor = 4
+
+// rankedItem is a string with a score, used for frequency rankings
+type rankedItem struct {
+	key   string
+	score int
+}
+
+// rankBefore reports whether a ranks ahead of b: higher scores first, then
+// alphabetical order. This gives frequency outputs a stable order regardless
+// of map iteration order.
+func rankBefore(a, b rankedItem) bool {
+	if a.score != b.score {
+		return a.score > b.score
+	}
+	return a.key < b.key
+}
+
+// topRanked returns up to k items in rank order. When only a small top-k is
+// needed from a large input it keeps a bounded heap of the best k items
+// instead of sorting the whole slice. items may be reordered.
+func topRanked(items []rankedItem, k int) []rankedItem {
+	if k <= 0 || len(items) == 0 {
+		return nil
+	}
+
+	if len(items) <= k*partialSelectFactor {
+		sort.Slice(items, func(i, j int) bool {
+			return rankBefore(items[i], items[j])
+		})
+		if len(items) > k {
+			items = items[:k]
+		}
+		return items
+	}
+
+	h := make(rankHeap, 0, k)
+	for _, item := range items {
+		if len(h) < k {
+			heap.Push(&h, item)
+			continue
+		}
+		// Replace the worst of the current top-k if this item beats it
+		if rankBefore(item, h[0]) {
+			h[0] = item
+			heap.Fix(&h, 0)
+		}
+	}
+
+	sort.Slice(h, func(i, j int) bool {
+		return rankBefore(h[i], h[j])
+	})
+	return h
+}
+
+// rankHeap is a heap with the lowest-ranked item at the root
+type rankHeap []rankedItem
+
+func (h rankHeap) Len() int           { return len(h) }
+func (h rankHeap) Less(i, j int) bool { return rankBefore(h[j], h[i]) }
+func (h rankHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *rankHeap) Push(x any) {
+	*h = append(*h, x.(rankedItem))
+}
+
+func (h *rankHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
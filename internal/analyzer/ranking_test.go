@@ -0,0 +1,143 @@
+package analyzer
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// tieHeavyText repeats every word the same number of times so rankings
+// depend entirely on tie-breaking
+const tieHeavyText = `Zebra mango apple kiwi banana. Kiwi zebra banana mango apple.
+Apple banana kiwi mango zebra. Grape melon grape melon.
+
+Alice Walker met Bob Stone in Paris. Bob Stone met Alice Walker in Berlin.
+Paris and Berlin welcomed Carol King.`
+
+func TestRankBefore(t *testing.T) {
+	items := []rankedItem{
+		{"pear", 2},
+		{"apple", 2},
+		{"fig", 5},
+		{"apples", 2},
+		{"kiwi", 1},
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return rankBefore(items[i], items[j])
+	})
+
+	expected := []string{"fig", "apple", "apples", "pear", "kiwi"}
+	for i, item := range items {
+		if item.key != expected[i] {
+			t.Errorf("Position %d: expected %s, got %s", i, expected[i], item.key)
+		}
+	}
+}
+
+func TestTopRankedHeapMatchesSort(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+
+	items := make([]rankedItem, 500)
+	for i := range items {
+		// Few distinct scores so most comparisons are ties
+		items[i] = rankedItem{fmt.Sprintf("word%03d", rng.Intn(1000)), rng.Intn(5)}
+	}
+
+	for _, k := range []int{1, 10, 100, 125, 500, 1000} {
+		sorted := append([]rankedItem(nil), items...)
+		sort.Slice(sorted, func(i, j int) bool {
+			return rankBefore(sorted[i], sorted[j])
+		})
+		if len(sorted) > k {
+			sorted = sorted[:k]
+		}
+
+		got := topRanked(append([]rankedItem(nil), items...), k)
+		if !reflect.DeepEqual(got, sorted) {
+			t.Errorf("k=%d: topRanked does not match full sort", k)
+		}
+	}
+
+	if got := topRanked(items, 0); got != nil {
+		t.Errorf("Expected nil for k=0, got %v", got)
+	}
+}
+
+func TestFrequencyOutputsDeterministic(t *testing.T) {
+	a := New()
+
+	first := a.AnalyzeOffline(tieHeavyText)
+	for i := 0; i < 20; i++ {
+		next := a.AnalyzeOffline(tieHeavyText)
+		if !reflect.DeepEqual(first.TopWords, next.TopWords) {
+			t.Fatalf("TopWords differ between runs:\n%v\n%v", first.TopWords, next.TopWords)
+		}
+		if !reflect.DeepEqual(first.TopPhrases, next.TopPhrases) {
+			t.Fatalf("TopPhrases differ between runs:\n%v\n%v", first.TopPhrases, next.TopPhrases)
+		}
+		if !reflect.DeepEqual(first.KeyTerms, next.KeyTerms) {
+			t.Fatalf("KeyTerms differ between runs:\n%v\n%v", first.KeyTerms, next.KeyTerms)
+		}
+		if !reflect.DeepEqual(first.NamedEntities, next.NamedEntities) {
+			t.Fatalf("NamedEntities differ between runs:\n%v\n%v", first.NamedEntities, next.NamedEntities)
+		}
+	}
+}
+
+func TestTopWordsTieBreaking(t *testing.T) {
+	a := New()
+
+	words := extractWords("zebra mango apple kiwi zebra mango apple kiwi grape")
+	top := a.getTopWords(words, 3)
+
+	expected := []string{"apple", "kiwi", "mango"}
+	if len(top) != len(expected) {
+		t.Fatalf("Expected %d words, got %d", len(expected), len(top))
+	}
+	for i, wf := range top {
+		if wf.Word != expected[i] || wf.Count != 2 {
+			t.Errorf("Position %d: expected %s (2), got %s (%d)", i, expected[i], wf.Word, wf.Count)
+		}
+	}
+}
+
+func TestExtractNamedEntitiesOrder(t *testing.T) {
+	entities := extractNamedEntities(tieHeavyText)
+	if !sort.StringsAreSorted(entities) {
+		t.Errorf("Expected entities in alphabetical order, got %v", entities)
+	}
+}
+
+func benchmarkRankItems(n int) []rankedItem {
+	rng := rand.New(rand.NewSource(1))
+	items := make([]rankedItem, n)
+	for i := range items {
+		items[i] = rankedItem{strings.Repeat("w", 1+rng.Intn(8)) + fmt.Sprint(i), rng.Intn(50)}
+	}
+	return items
+}
+
+func BenchmarkTopRanked(b *testing.B) {
+	items := benchmarkRankItems(20000)
+	scratch := make([]rankedItem, len(items))
+
+	b.Run("partial", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			copy(scratch, items)
+			topRanked(scratch, 20)
+		}
+	})
+
+	b.Run("full_sort", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			copy(scratch, items)
+			sort.Slice(scratch, func(i, j int) bool {
+				return rankBefore(scratch[i], scratch[j])
+			})
+		}
+	})
+}
@@ -0,0 +1,297 @@
+package analyzer
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/docutag/textanalyzer/internal/models"
+)
+
+// Readability formulas recorded in Metadata.ReadabilityFormula and
+// Metadata.ReadabilityScores
+const (
+	ReadabilityFleschReadingEase  = "flesch_reading_ease"  // English only; higher is easier
+	ReadabilityFleschKincaidGrade = "flesch_kincaid_grade" // English only; US school grade
+	ReadabilityGunningFog         = "gunning_fog"          // English only; US school grade
+	ReadabilitySMOG               = "smog"                 // English only; US school grade
+	ReadabilityColemanLiau        = "coleman_liau"         // English only; US school grade from letters rather than syllables
+	ReadabilityLIX                = "lix"                  // Language-agnostic; higher is harder
+)
+
+// EnglishReadabilityFormulas are the formulas scored for English text, any
+// of which can be the primary formula reported as the readability score
+var EnglishReadabilityFormulas = []string{
+	ReadabilityFleschReadingEase,
+	ReadabilityFleschKincaidGrade,
+	ReadabilityGunningFog,
+	ReadabilitySMOG,
+	ReadabilityColemanLiau,
+}
+
+// gradeFormulas are the formulas giving a US school grade, averaged into
+// Metadata.GradeLevel
+var gradeFormulas = []string{
+	ReadabilityFleschKincaidGrade,
+	ReadabilityGunningFog,
+	ReadabilitySMOG,
+	ReadabilityColemanLiau,
+}
+
+// minLanguageConfidence is the language confidence required before a
+// language-specific readability formula is used
+const minLanguageConfidence = 0.6
+
+// lixLanguages lists the non-English languages scored with LIX
+var lixLanguages = map[string]bool{
+	LanguageSpanish: true,
+	LanguageFrench:  true,
+	LanguageGerman:  true,
+}
+
+// ValidateReadabilityFormula returns an error unless formula is one of
+// EnglishReadabilityFormulas
+func ValidateReadabilityFormula(formula string) error {
+	if !slices.Contains(EnglishReadabilityFormulas, formula) {
+		return fmt.Errorf("readability formula must be one of %s, got %q", strings.Join(EnglishReadabilityFormulas, ", "), formula)
+	}
+	return nil
+}
+
+// SetReadabilityFormula sets the formula English text's readability score
+// and level are reported with, one of EnglishReadabilityFormulas. An empty
+// formula restores Flesch Reading Ease. Other languages are scored with LIX
+// whatever the formula.
+func (a *Analyzer) SetReadabilityFormula(formula string) {
+	a.readabilityFormula = formula
+}
+
+// assessReadability scores readability with the formulas suited to the
+// detected language: every English formula for confidently English text,
+// primary being reported, and LIX for the other supported languages. words
+// are the words extracted from text. It returns no scores and an empty
+// formula when no formula applies.
+func assessReadability(text string, words []string, sentenceCount int, primary string) (scores map[string]float64, formula string) {
+	language, confidence := detectLanguageWithConfidence(text)
+	if confidence < minLanguageConfidence {
+		return nil, ""
+	}
+
+	switch {
+	case language == LanguageEnglish:
+		if primary == "" {
+			primary = ReadabilityFleschReadingEase
+		}
+		scores = englishReadability(countReadability(words, sentenceCount))
+		if scores == nil {
+			return nil, ""
+		}
+		return scores, primary
+	case lixLanguages[language] && len(words) > 0 && sentenceCount > 0:
+		return map[string]float64{ReadabilityLIX: calculateLIX(text, sentenceCount)}, ReadabilityLIX
+	}
+	return nil, ""
+}
+
+// applyReadability sets the readability scores, the score, level and
+// formula of the primary formula, and the grade level on metadata. words
+// are the words extracted from text.
+func (a *Analyzer) applyReadability(metadata *models.Metadata, text string, words []string) {
+	scores, formula := assessReadability(text, words, metadata.SentenceCount, a.readabilityFormula)
+	metadata.ReadabilityScores = scores
+	metadata.ReadabilityFormula = formula
+	metadata.ReadabilityScore = scores[formula]
+	metadata.ReadabilityLevel = ""
+	if formula != "" {
+		metadata.ReadabilityLevel = getReadabilityLevel(formula, metadata.ReadabilityScore)
+	}
+	metadata.GradeLevel = gradeLevel(scores)
+}
+
+// fleschScore returns the Flesch Reading Ease score recorded on metadata, or
+// nil when the text was not scored with it, so that the fallback quality
+// scorer only judges readability it can interpret
+func fleschScore(metadata models.Metadata) *float64 {
+	if score, ok := metadata.ReadabilityScores[ReadabilityFleschReadingEase]; ok {
+		return &score
+	}
+	if metadata.ReadabilityFormula != ReadabilityFleschReadingEase {
+		return nil
+	}
+	score := metadata.ReadabilityScore
+	return &score
+}
+
+// readabilityCounts are the counts the English readability formulas are
+// computed from
+type readabilityCounts struct {
+	words         int
+	sentences     int
+	syllables     int
+	polysyllables int // Words of three or more syllables
+	letters       int
+}
+
+// countReadability counts the syllables, polysyllabic words and letters of
+// the words of a text, taking its sentence count as already counted
+func countReadability(words []string, sentenceCount int) readabilityCounts {
+	counts := readabilityCounts{words: len(words), sentences: sentenceCount}
+	for _, word := range words {
+		syllables := countSyllablesInWord(word)
+		counts.syllables += syllables
+		if syllables >= 3 {
+			counts.polysyllables++
+		}
+		for _, r := range word {
+			if unicode.IsLetter(r) {
+				counts.letters++
+			}
+		}
+	}
+	return counts
+}
+
+// englishReadability scores counts with every English formula, or returns
+// nil when there are no words or sentences to score
+func englishReadability(c readabilityCounts) map[string]float64 {
+	if c.words == 0 || c.sentences == 0 {
+		return nil
+	}
+
+	words, sentences := float64(c.words), float64(c.sentences)
+	wordsPerSentence := words / sentences
+	syllablesPerWord := float64(c.syllables) / words
+
+	scores := map[string]float64{
+		ReadabilityFleschReadingEase:  206.835 - 1.015*wordsPerSentence - 84.6*syllablesPerWord,
+		ReadabilityFleschKincaidGrade: 0.39*wordsPerSentence + 11.8*syllablesPerWord - 15.59,
+		ReadabilityGunningFog:         0.4 * (wordsPerSentence + 100*float64(c.polysyllables)/words),
+		ReadabilitySMOG:               1.043*math.Sqrt(float64(c.polysyllables)*30/sentences) + 3.1291,
+		ReadabilityColemanLiau:        0.0588*(100*float64(c.letters)/words) - 0.296*(100*sentences/words) - 15.8,
+	}
+	for formula, score := range scores {
+		scores[formula] = math.Round(score*100) / 100
+	}
+	return scores
+}
+
+// gradeLevel averages the grade formulas among scores into one US school
+// grade, never below 0. It returns 0 when none was scored.
+func gradeLevel(scores map[string]float64) float64 {
+	sum, n := 0.0, 0
+	for _, formula := range gradeFormulas {
+		if score, ok := scores[formula]; ok {
+			sum += score
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return math.Max(0, math.Round(sum/float64(n)*10)/10)
+}
+
+// getReadabilityLevel returns the readability level of a score from formula
+func getReadabilityLevel(formula string, score float64) string {
+	switch formula {
+	case ReadabilityLIX:
+		return getLIXLevel(score)
+	case ReadabilityFleschReadingEase:
+		return getFleschLevel(score)
+	default:
+		return getGradeLevel(score)
+	}
+}
+
+// getFleschLevel returns the readability level of a Flesch Reading Ease score
+func getFleschLevel(score float64) string {
+	switch {
+	case score >= 90:
+		return "very_easy"
+	case score >= 80:
+		return "easy"
+	case score >= 70:
+		return "fairly_easy"
+	case score >= 60:
+		return "standard"
+	case score >= 50:
+		return "fairly_difficult"
+	case score >= 30:
+		return "difficult"
+	default:
+		return "very_difficult"
+	}
+}
+
+// getGradeLevel maps a US school grade onto the readability levels used for
+// Flesch, following the grades Flesch gave his bands: 5th grade is very
+// easy, 8th and 9th standard and college graduate very difficult
+func getGradeLevel(grade float64) string {
+	switch {
+	case grade < 6:
+		return "very_easy"
+	case grade < 7:
+		return "easy"
+	case grade < 8:
+		return "fairly_easy"
+	case grade < 10:
+		return "standard"
+	case grade < 13:
+		return "fairly_difficult"
+	case grade < 16:
+		return "difficult"
+	default:
+		return "very_difficult"
+	}
+}
+
+// calculateLIX calculates the LIX readability index: average sentence length
+// plus the percentage of words longer than six letters. It does not depend
+// on syllables, so it works across European languages.
+func calculateLIX(text string, sentenceCount int) float64 {
+	words := unicodeWords(text)
+	if len(words) == 0 || sentenceCount == 0 {
+		return 0
+	}
+
+	longWords := 0
+	for _, word := range words {
+		if utf8.RuneCountInString(word) > 6 {
+			longWords++
+		}
+	}
+
+	score := float64(len(words))/float64(sentenceCount) + 100*float64(longWords)/float64(len(words))
+	return math.Round(score*100) / 100
+}
+
+// getLIXLevel maps a LIX score onto the readability levels used for Flesch
+func getLIXLevel(score float64) string {
+	switch {
+	case score < 25:
+		return "very_easy"
+	case score < 30:
+		return "easy"
+	case score < 35:
+		return "fairly_easy"
+	case score < 45:
+		return "standard"
+	case score < 50:
+		return "fairly_difficult"
+	case score < 60:
+		return "difficult"
+	default:
+		return "very_difficult"
+	}
+}
+
+// unicodeWords splits text into lowercase words, keeping accented letters
+// that extractWords would split on
+func unicodeWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	})
+}
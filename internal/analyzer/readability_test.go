@@ -0,0 +1,166 @@
+package analyzer
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+const englishReadabilityFixture = `The city council approved the new transit plan on Tuesday. The plan adds twelve bus routes to the eastern districts, and it extends the evening service on the busiest lines. Construction of the new stops will begin in the spring. Residents who spoke at the meeting said that the changes were long overdue.`
+
+const spanishReadabilityFixture = `El ayuntamiento aprobó el martes la ampliación del transporte público metropolitano. La propuesta incorpora doce nuevas líneas de autobuses para los distritos orientales y también prolonga considerablemente el servicio nocturno en las rutas más concurridas. Las obras de construcción de las nuevas paradas comenzarán durante la primavera, según explicaron los responsables municipales. Los vecinos que participaron en la reunión consideraron que estas modificaciones eran absolutamente necesarias desde hace muchísimo tiempo.`
+
+func TestReadabilityFormulaSelection(t *testing.T) {
+	a := New()
+
+	english := a.AnalyzeOffline(englishReadabilityFixture)
+	if english.ReadabilityFormula != ReadabilityFleschReadingEase {
+		t.Errorf("Expected English text to use %q, got %q", ReadabilityFleschReadingEase, english.ReadabilityFormula)
+	}
+	if want := calculateReadability(extractWords(englishReadabilityFixture), english.SentenceCount); english.ReadabilityScore != want {
+		t.Errorf("Expected Flesch score %.2f, got %.2f", want, english.ReadabilityScore)
+	}
+
+	spanish := a.AnalyzeOffline(spanishReadabilityFixture)
+	if spanish.Language != LanguageSpanish {
+		t.Errorf("Expected language %q, got %q", LanguageSpanish, spanish.Language)
+	}
+	if spanish.ReadabilityFormula != ReadabilityLIX {
+		t.Errorf("Expected Spanish text to use %q, got %q", ReadabilityLIX, spanish.ReadabilityFormula)
+	}
+	if want := calculateLIX(spanishReadabilityFixture, spanish.SentenceCount); spanish.ReadabilityScore != want {
+		t.Errorf("Expected LIX score %.2f, got %.2f", want, spanish.ReadabilityScore)
+	}
+	if spanish.ReadabilityLevel != getLIXLevel(spanish.ReadabilityScore) {
+		t.Errorf("Expected level %q for LIX %.2f, got %q",
+			getLIXLevel(spanish.ReadabilityScore), spanish.ReadabilityScore, spanish.ReadabilityLevel)
+	}
+
+	unknown := a.AnalyzeOffline("Tokyo 2024")
+	if unknown.ReadabilityFormula != "" || unknown.ReadabilityScore != 0 || unknown.ReadabilityLevel != "" {
+		t.Errorf("Expected no readability for undetected language, got %q %.2f %q",
+			unknown.ReadabilityFormula, unknown.ReadabilityScore, unknown.ReadabilityLevel)
+	}
+}
+
+func TestSpanishTextNotPenalizedForReadability(t *testing.T) {
+	a := New()
+	metadata := a.AnalyzeOffline(spanishReadabilityFixture)
+
+	// English syllable rules rate the Spanish text as very hard to read
+	flesch := calculateReadability(extractWords(spanishReadabilityFixture), metadata.SentenceCount)
+	penalized := scoreTextQualityFallback(spanishReadabilityFixture, metadata.WordCount, &flesch, *metadata.Coherence, metadata.Language)
+	if !containsStringSlice(penalized.ProblemsDetected, "difficult_to_read") {
+		t.Fatalf("Expected the Flesch score %.2f to be penalized, got problems %v", flesch, penalized.ProblemsDetected)
+	}
+
+	if containsStringSlice(metadata.QualityScore.ProblemsDetected, "difficult_to_read") {
+		t.Errorf("Spanish text should not be penalized for readability, got problems %v", metadata.QualityScore.ProblemsDetected)
+	}
+	if metadata.QualityScore.Score <= penalized.Score {
+		t.Errorf("Expected quality score above the Flesch-penalized %.2f, got %.2f", penalized.Score, metadata.QualityScore.Score)
+	}
+}
+
+func TestEnglishReadabilityFormulas(t *testing.T) {
+	// 10 words, 2 sentences, 20 syllables, 3 words of 3+ syllables
+	// (universities, encourage, independent) and 57 letters
+	text := "The cat sat on the mat. Universities encourage independent research."
+	counts := countReadability(extractWords(text), 2)
+	if counts != (readabilityCounts{words: 10, sentences: 2, syllables: 20, polysyllables: 3, letters: 57}) {
+		t.Fatalf("Unexpected counts: %+v", counts)
+	}
+
+	expected := map[string]float64{
+		ReadabilityFleschReadingEase:  32.56, // 206.835 - 1.015*5 - 84.6*2
+		ReadabilityFleschKincaidGrade: 9.96,  // 0.39*5 + 11.8*2 - 15.59
+		ReadabilityGunningFog:         14,    // 0.4 * (5 + 100*3/10)
+		ReadabilitySMOG:               10.13, // 1.043*sqrt(3*30/2) + 3.1291
+		ReadabilityColemanLiau:        11.8,  // 0.0588*570 - 0.296*20 - 15.8
+	}
+	scores := englishReadability(counts)
+	if !reflect.DeepEqual(scores, expected) {
+		t.Errorf("Expected %v, got %v", expected, scores)
+	}
+	if grade := gradeLevel(scores); grade != 11.5 {
+		t.Errorf("Expected grade level 11.5, got %v", grade)
+	}
+
+	// Degenerate counts score nothing rather than dividing by zero
+	if scores := englishReadability(readabilityCounts{words: 3}); scores != nil {
+		t.Errorf("Expected no scores without sentences, got %v", scores)
+	}
+	if scores := englishReadability(readabilityCounts{sentences: 1}); scores != nil {
+		t.Errorf("Expected no scores without words, got %v", scores)
+	}
+	for formula, score := range englishReadability(countReadability([]string{"hello"}, 1)) {
+		if math.IsNaN(score) || math.IsInf(score, 0) {
+			t.Errorf("Expected a finite %s for one word, got %v", formula, score)
+		}
+	}
+	if grade := gradeLevel(map[string]float64{ReadabilityFleschKincaidGrade: -3.4}); grade != 0 {
+		t.Errorf("Expected the grade level floored at 0, got %v", grade)
+	}
+}
+
+func TestPrimaryReadabilityFormula(t *testing.T) {
+	a := New()
+	flesch := a.AnalyzeOffline(englishReadabilityFixture)
+	if len(flesch.ReadabilityScores) != len(EnglishReadabilityFormulas) {
+		t.Errorf("Expected every English formula scored, got %v", flesch.ReadabilityScores)
+	}
+	if flesch.GradeLevel != gradeLevel(flesch.ReadabilityScores) || flesch.GradeLevel == 0 {
+		t.Errorf("Expected the averaged grade level, got %v", flesch.GradeLevel)
+	}
+
+	a.SetReadabilityFormula(ReadabilityFleschKincaidGrade)
+	grade := a.AnalyzeOffline(englishReadabilityFixture)
+	if grade.ReadabilityFormula != ReadabilityFleschKincaidGrade {
+		t.Errorf("Expected formula %q, got %q", ReadabilityFleschKincaidGrade, grade.ReadabilityFormula)
+	}
+	if want := grade.ReadabilityScores[ReadabilityFleschKincaidGrade]; grade.ReadabilityScore != want {
+		t.Errorf("Expected the Flesch-Kincaid grade %.2f as the score, got %.2f", want, grade.ReadabilityScore)
+	}
+	if grade.ReadabilityLevel != getGradeLevel(grade.ReadabilityScore) {
+		t.Errorf("Expected the level of grade %.2f, got %q", grade.ReadabilityScore, grade.ReadabilityLevel)
+	}
+	// The quality checks keep reading the Flesch score
+	if score := fleschScore(grade); score == nil || *score != flesch.ReadabilityScore {
+		t.Errorf("Expected Flesch score %.2f for quality scoring, got %v", flesch.ReadabilityScore, score)
+	}
+
+	// Other languages are scored with LIX whatever the primary formula
+	spanish := a.AnalyzeOffline(spanishReadabilityFixture)
+	if spanish.ReadabilityFormula != ReadabilityLIX || len(spanish.ReadabilityScores) != 1 || spanish.GradeLevel != 0 {
+		t.Errorf("Expected LIX alone, got %q %v grade %v", spanish.ReadabilityFormula, spanish.ReadabilityScores, spanish.GradeLevel)
+	}
+
+	if err := ValidateReadabilityFormula(ReadabilitySMOG); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := ValidateReadabilityFormula(ReadabilityLIX); err == nil {
+		t.Error("Expected LIX to be rejected as the primary English formula")
+	}
+}
+
+func TestGetReadabilityLevel(t *testing.T) {
+	tests := []struct {
+		formula  string
+		score    float64
+		expected string
+	}{
+		{ReadabilityFleschReadingEase, 95, "very_easy"},
+		{ReadabilityFleschReadingEase, 65, "standard"},
+		{ReadabilityFleschReadingEase, 10, "very_difficult"},
+		{ReadabilityFleschKincaidGrade, 5, "very_easy"},
+		{ReadabilitySMOG, 8.5, "standard"},
+		{ReadabilityGunningFog, 12, "fairly_difficult"},
+		{ReadabilityColemanLiau, 17, "very_difficult"},
+		{ReadabilityLIX, 40, "standard"},
+	}
+	for _, tt := range tests {
+		if level := getReadabilityLevel(tt.formula, tt.score); level != tt.expected {
+			t.Errorf("%s %v: expected %q, got %q", tt.formula, tt.score, tt.expected, level)
+		}
+	}
+}
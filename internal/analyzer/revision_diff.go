@@ -0,0 +1,189 @@
+package analyzer
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/docutag/textanalyzer/internal/models"
+)
+
+// Text edit operations in a word-level diff
+const (
+	EditEqual  = "equal"
+	EditInsert = "insert"
+	EditDelete = "delete"
+)
+
+// RevisionDiff is a field-by-field comparison of an analysis's AI-derived
+// fields before and after a re-enrichment
+type RevisionDiff struct {
+	Revision                 int          `json:"revision"`
+	FromModel                string       `json:"from_model,omitempty"`
+	ToModel                  string       `json:"to_model,omitempty"`
+	TagsAdded                []string     `json:"tags_added"`
+	TagsRemoved              []string     `json:"tags_removed"`
+	QualityScore             ScoreChange  `json:"quality_score"`
+	Synopsis                 TextChange   `json:"synopsis"`
+	EditorialAnalysisChanged bool         `json:"editorial_analysis_changed"`
+	AILikelihood             StringChange `json:"ai_likelihood"`
+}
+
+// ScoreChange compares two optional scores
+type ScoreChange struct {
+	Old   *float64 `json:"old"`
+	New   *float64 `json:"new"`
+	Delta *float64 `json:"delta,omitempty"` // New - Old, when both are present
+}
+
+// StringChange compares two string values
+type StringChange struct {
+	Old     string `json:"old"`
+	New     string `json:"new"`
+	Changed bool   `json:"changed"`
+}
+
+// TextChange compares two texts with a word-level diff
+type TextChange struct {
+	Old     string     `json:"old"`
+	New     string     `json:"new"`
+	Changed bool       `json:"changed"`
+	Edits   []TextEdit `json:"edits,omitempty"`
+}
+
+// TextEdit is a run of words that are unchanged, inserted or deleted
+type TextEdit struct {
+	Op   string `json:"op"` // equal, insert, delete
+	Text string `json:"text"`
+}
+
+// SnapshotRevisionFields copies the AI-derived fields of analysis metadata
+func SnapshotRevisionFields(metadata models.Metadata) models.RevisionFields {
+	fields := models.RevisionFields{
+		Synopsis:          metadata.Synopsis,
+		EditorialAnalysis: metadata.EditorialAnalysis,
+		Tags:              append([]string(nil), metadata.Tags...),
+		AIDetection:       metadata.AIDetection,
+	}
+	if metadata.QualityScore != nil {
+		score := *metadata.QualityScore
+		fields.QualityScore = &score
+	}
+	if metadata.Editorial != nil {
+		editorial := *metadata.Editorial
+		fields.Editorial = &editorial
+	}
+	return fields
+}
+
+// DiffRevisionFields compares the AI-derived fields of two revisions
+func DiffRevisionFields(before, after models.RevisionFields) RevisionDiff {
+	diff := RevisionDiff{
+		TagsAdded:                setDifference(after.Tags, before.Tags),
+		TagsRemoved:              setDifference(before.Tags, after.Tags),
+		QualityScore:             diffScores(before.QualityScore, after.QualityScore),
+		EditorialAnalysisChanged: before.EditorialAnalysis != after.EditorialAnalysis,
+		AILikelihood: StringChange{
+			Old:     before.AIDetection.Likelihood,
+			New:     after.AIDetection.Likelihood,
+			Changed: before.AIDetection.Likelihood != after.AIDetection.Likelihood,
+		},
+		Synopsis: TextChange{
+			Old:     before.Synopsis,
+			New:     after.Synopsis,
+			Changed: before.Synopsis != after.Synopsis,
+		},
+	}
+	if diff.Synopsis.Changed {
+		diff.Synopsis.Edits = diffWords(before.Synopsis, after.Synopsis)
+	}
+	return diff
+}
+
+// setDifference returns the sorted values in a that are not in b
+func setDifference(a, b []string) []string {
+	exclude := make(map[string]bool, len(b))
+	for _, value := range b {
+		exclude[value] = true
+	}
+
+	result := []string{}
+	seen := make(map[string]bool, len(a))
+	for _, value := range a {
+		if !exclude[value] && !seen[value] {
+			seen[value] = true
+			result = append(result, value)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// diffScores compares two optional quality scores
+func diffScores(before, after *models.TextQualityScore) ScoreChange {
+	var change ScoreChange
+	if before != nil {
+		change.Old = &before.Score
+	}
+	if after != nil {
+		change.New = &after.Score
+	}
+	if before != nil && after != nil {
+		delta := after.Score - before.Score
+		change.Delta = &delta
+	}
+	return change
+}
+
+// diffWords computes a word-level diff of two texts using the longest common
+// subsequence, merging adjacent words with the same operation
+func diffWords(before, after string) []TextEdit {
+	a, b := strings.Fields(before), strings.Fields(after)
+
+	// lcs[i][j] is the LCS length of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var edits []TextEdit
+	add := func(op, word string) {
+		if n := len(edits); n > 0 && edits[n-1].Op == op {
+			edits[n-1].Text += " " + word
+			return
+		}
+		edits = append(edits, TextEdit{Op: op, Text: word})
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			add(EditEqual, a[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			add(EditDelete, a[i])
+			i++
+		default:
+			add(EditInsert, b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		add(EditDelete, a[i])
+	}
+	for ; j < len(b); j++ {
+		add(EditInsert, b[j])
+	}
+
+	return edits
+}
@@ -0,0 +1,95 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/docutag/textanalyzer/internal/models"
+)
+
+func TestDiffRevisionFields(t *testing.T) {
+	before := models.RevisionFields{
+		Synopsis:          "The council approved a transit plan.",
+		EditorialAnalysis: "Neutral reporting.",
+		Tags:              []string{"transit", "council", "budget"},
+		QualityScore:      &models.TextQualityScore{Score: 0.6},
+		AIDetection:       models.AIDetectionResult{Likelihood: "unlikely"},
+	}
+	after := models.RevisionFields{
+		Synopsis:          "The city council approved a new transit plan.",
+		EditorialAnalysis: "Neutral reporting.",
+		Tags:              []string{"transit", "council", "infrastructure", "city"},
+		QualityScore:      &models.TextQualityScore{Score: 0.75},
+		AIDetection:       models.AIDetectionResult{Likelihood: "unlikely"},
+	}
+
+	diff := DiffRevisionFields(before, after)
+
+	if !reflect.DeepEqual(diff.TagsAdded, []string{"city", "infrastructure"}) {
+		t.Errorf("Unexpected tags added: %v", diff.TagsAdded)
+	}
+	if !reflect.DeepEqual(diff.TagsRemoved, []string{"budget"}) {
+		t.Errorf("Unexpected tags removed: %v", diff.TagsRemoved)
+	}
+	if diff.QualityScore.Old == nil || *diff.QualityScore.Old != 0.6 ||
+		diff.QualityScore.New == nil || *diff.QualityScore.New != 0.75 {
+		t.Errorf("Unexpected quality score change: %+v", diff.QualityScore)
+	}
+	if diff.QualityScore.Delta == nil || *diff.QualityScore.Delta < 0.149 || *diff.QualityScore.Delta > 0.151 {
+		t.Errorf("Expected delta 0.15, got %v", diff.QualityScore.Delta)
+	}
+	if diff.EditorialAnalysisChanged {
+		t.Error("Editorial analysis did not change")
+	}
+	if diff.AILikelihood.Changed {
+		t.Error("AI likelihood did not change")
+	}
+	if !diff.Synopsis.Changed {
+		t.Fatal("Expected synopsis to change")
+	}
+
+	expected := []TextEdit{
+		{Op: EditEqual, Text: "The"},
+		{Op: EditInsert, Text: "city"},
+		{Op: EditEqual, Text: "council approved a"},
+		{Op: EditInsert, Text: "new"},
+		{Op: EditEqual, Text: "transit plan."},
+	}
+	if !reflect.DeepEqual(diff.Synopsis.Edits, expected) {
+		t.Errorf("Unexpected synopsis edits:\n got %+v\nwant %+v", diff.Synopsis.Edits, expected)
+	}
+}
+
+func TestDiffRevisionFieldsMissingScore(t *testing.T) {
+	diff := DiffRevisionFields(models.RevisionFields{}, models.RevisionFields{
+		QualityScore: &models.TextQualityScore{Score: 0.5},
+	})
+	if diff.QualityScore.Old != nil || diff.QualityScore.New == nil || diff.QualityScore.Delta != nil {
+		t.Errorf("Unexpected quality score change: %+v", diff.QualityScore)
+	}
+	if diff.Synopsis.Changed || diff.Synopsis.Edits != nil {
+		t.Errorf("Expected unchanged empty synopsis, got %+v", diff.Synopsis)
+	}
+}
+
+func TestDiffWords(t *testing.T) {
+	tests := []struct {
+		before, after string
+		want          []TextEdit
+	}{
+		{"", "new text", []TextEdit{{Op: EditInsert, Text: "new text"}}},
+		{"old text", "", []TextEdit{{Op: EditDelete, Text: "old text"}}},
+		{"a b c", "a x c", []TextEdit{
+			{Op: EditEqual, Text: "a"},
+			{Op: EditDelete, Text: "b"},
+			{Op: EditInsert, Text: "x"},
+			{Op: EditEqual, Text: "c"},
+		}},
+	}
+
+	for _, tt := range tests {
+		if got := diffWords(tt.before, tt.after); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("diffWords(%q, %q) = %+v, want %+v", tt.before, tt.after, got, tt.want)
+		}
+	}
+}
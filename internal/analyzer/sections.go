@@ -0,0 +1,183 @@
+package analyzer
+
+import (
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/docutag/textanalyzer/internal/models"
+)
+
+// DefaultMaxSections is the default cap on sections summarized per document
+const DefaultMaxSections = 20
+
+// Sectioning limits
+const (
+	sectionWindowParagraphs = 5  // Paragraphs per section when the text has no headings
+	maxHeadingWords         = 12 // Longer lines are treated as body text
+	sectionTopWords         = 10
+	sectionKeyTerms         = 5
+)
+
+// OfflineOptions holds per-request settings for offline analysis
+type OfflineOptions struct {
+	Sections      bool      // Summarize top words, key terms and sentiment per section
+	ReferenceTime time.Time // Analysis creation time relative dates are resolved against; zero leaves them out
+	Format        string    // Format of the text, one of Formats (empty for FormatAuto)
+}
+
+// SetMaxSections sets how many sections are summarized per document. Values
+// of zero or less restore DefaultMaxSections.
+func (a *Analyzer) SetMaxSections(n int) {
+	a.maxSections = n
+}
+
+// sectionSpan is a section of text and its heading, if any
+type sectionSpan struct {
+	span  byteSpan
+	title string
+}
+
+// summarizeSections splits text into sections and summarizes each one.
+// words are the words already extracted from text; each section takes its
+// share of them in order instead of re-tokenizing the document. language is
+// the language detected for the whole document.
+func (a *Analyzer) summarizeSections(text string, words []string, language string) []models.SectionSummary {
+	maxSections := a.maxSections
+	if maxSections <= 0 {
+		maxSections = DefaultMaxSections
+	}
+
+	sections := splitSections(text, maxSections)
+	if len(sections) > maxSections {
+		sections = sections[:maxSections]
+	}
+
+	summaries := make([]models.SectionSummary, 0, len(sections))
+	wordPos, runePos, bytePos := 0, 0, 0
+	for _, section := range sections {
+		// Words before the section (only whitespace in practice) are skipped
+		wordPos += countWordTokens(text[bytePos:section.span.start])
+		runePos += utf8.RuneCountInString(text[bytePos:section.span.start])
+
+		n := countWordTokens(text[section.span.start:section.span.end])
+		start := min(wordPos, len(words))
+		end := min(wordPos+n, len(words))
+		sectionWords := words[start:end]
+
+		sentiment, _ := a.analyzeSentiment(text[section.span.start:section.span.end])
+		summaries = append(summaries, models.SectionSummary{
+			Title:     section.title,
+			Offset:    runePos,
+			TopWords:  a.getTopWords(sectionWords, sectionTopWords),
+			KeyTerms:  a.extractKeyTerms(sectionWords, sectionKeyTerms, language),
+			Sentiment: sentiment,
+		})
+
+		wordPos += n
+		runePos += utf8.RuneCountInString(text[section.span.start:section.span.end])
+		bytePos = section.span.end
+	}
+
+	return summaries
+}
+
+// splitSections divides text into sections at its headings. Text before the
+// first heading becomes an untitled section. Without headings, paragraphs
+// are grouped into untitled windows, widened when needed so that the whole
+// text fits in maxSections.
+func splitSections(text string, maxSections int) []sectionSpan {
+	paragraphs := paragraphSpans(text)
+	if len(paragraphs) == 0 {
+		return nil
+	}
+
+	var sections []sectionSpan
+	for i, para := range paragraphs {
+		title, ok := headingTitle(text, para, i == len(paragraphs)-1)
+		if !ok {
+			continue
+		}
+		if len(sections) == 0 && para.start > paragraphs[0].start {
+			sections = append(sections, sectionSpan{span: byteSpan{paragraphs[0].start, para.start}})
+		}
+		if len(sections) > 0 {
+			sections[len(sections)-1].span.end = para.start
+		}
+		sections = append(sections, sectionSpan{span: byteSpan{para.start, len(text)}, title: title})
+	}
+	if len(sections) > 0 {
+		return sections
+	}
+
+	window := sectionWindowParagraphs
+	if needed := (len(paragraphs) + maxSections - 1) / maxSections; needed > window {
+		window = needed
+	}
+	for i := 0; i < len(paragraphs); i += window {
+		end := len(text)
+		if i+window < len(paragraphs) {
+			end = paragraphs[i+window].start
+		}
+		sections = append(sections, sectionSpan{span: byteSpan{paragraphs[i].start, end}})
+	}
+	return sections
+}
+
+// headingTitle reports whether a paragraph starts with a heading and returns
+// its title. A heading is a Markdown heading line ("## Title"), or a short,
+// capitalized paragraph of one line that does not end like a sentence and is
+// followed by more text.
+func headingTitle(text string, para byteSpan, last bool) (string, bool) {
+	paragraph := text[para.start:para.end]
+	firstLine, _, multiline := strings.Cut(paragraph, "\n")
+	firstLine = strings.TrimSpace(firstLine)
+
+	if level := len(firstLine) - len(strings.TrimLeft(firstLine, "#")); level >= 1 && level <= 6 {
+		title := strings.TrimSpace(firstLine[level:])
+		if title != "" && firstLine[level] == ' ' {
+			return strings.TrimRight(title, " #"), true
+		}
+	}
+
+	if multiline || last {
+		return "", false
+	}
+	if words := len(strings.Fields(firstLine)); words == 0 || words > maxHeadingWords {
+		return "", false
+	}
+	first, _ := utf8.DecodeRuneInString(firstLine)
+	if !unicode.IsUpper(first) && !unicode.IsDigit(first) {
+		return "", false
+	}
+	lastRune, _ := utf8.DecodeLastRuneInString(firstLine)
+	if strings.ContainsRune(".!?,;\"'”", lastRune) {
+		return "", false
+	}
+	return firstLine, true
+}
+
+// countWordTokens counts the words extractWords finds in s, without
+// allocating them
+func countWordTokens(s string) int {
+	count := 0
+	inWord := false
+	for _, r := range s {
+		switch {
+		case isIdeograph(r):
+			count++
+			inWord = false
+		case isWordRune(r):
+			if !inWord {
+				count++
+			}
+			inWord = true
+		case unicode.IsMark(r) && inWord:
+			// Combining marks continue the word they follow
+		default:
+			inWord = false
+		}
+	}
+	return count
+}
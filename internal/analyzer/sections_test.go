@@ -0,0 +1,153 @@
+package analyzer
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+const sectionsFixture = `This café guide covers three topics, and the guide is written for new residents.
+
+# Gardens
+
+Community gardens grow vegetables on empty city lots. Gardeners share soil, compost and seeds, and the gardens hold harvest days every autumn. New gardeners should ask about soil testing before planting in the gardens.
+
+## Transit
+
+Buses and trains connect every district. Buses run every ten minutes at peak times, and night buses cover the main routes. Monthly passes work on buses and trains alike.
+
+Budget Planning
+
+The council budget funds libraries, parks and roads. Residents can comment on the budget each spring, and the final budget is published online.`
+
+// runeOffset returns the rune offset of the first occurrence of substr
+func runeOffset(t *testing.T, text, substr string) int {
+	t.Helper()
+
+	idx := strings.Index(text, substr)
+	if idx == -1 {
+		t.Fatalf("Fixture does not contain %q", substr)
+	}
+	return utf8.RuneCountInString(text[:idx])
+}
+
+func TestSectionsFollowHeadings(t *testing.T) {
+	a := New()
+	metadata := a.AnalyzeOfflineWithOptions(sectionsFixture, OfflineOptions{Sections: true})
+
+	sections := metadata.Sections
+	if len(sections) != 4 {
+		t.Fatalf("Expected 4 sections, got %d: %+v", len(sections), sections)
+	}
+
+	want := []struct {
+		title   string
+		start   string
+		topWord string
+	}{
+		{"", "This café guide", "guide"},
+		{"Gardens", "# Gardens", "gardens"},
+		{"Transit", "## Transit", "buses"},
+		{"Budget Planning", "Budget Planning", "budget"},
+	}
+	for i, w := range want {
+		section := sections[i]
+		if section.Title != w.title {
+			t.Errorf("Section %d: expected title %q, got %q", i, w.title, section.Title)
+		}
+		if offset := runeOffset(t, sectionsFixture, w.start); section.Offset != offset {
+			t.Errorf("Section %d: expected offset %d, got %d", i, offset, section.Offset)
+		}
+		if len(section.TopWords) == 0 || section.TopWords[0].Word != w.topWord {
+			t.Errorf("Section %d: expected top word %q, got %v", i, w.topWord, section.TopWords)
+		}
+	}
+
+	// Each section surfaces its own vocabulary
+	if reflect.DeepEqual(sections[1].KeyTerms, sections[2].KeyTerms) || reflect.DeepEqual(sections[2].KeyTerms, sections[3].KeyTerms) {
+		t.Errorf("Expected distinct key terms per section, got %v, %v and %v",
+			sections[1].KeyTerms, sections[2].KeyTerms, sections[3].KeyTerms)
+	}
+	for _, term := range sections[2].KeyTerms {
+		if strings.Contains(term, "garden") {
+			t.Errorf("Transit section should not surface garden terms, got %v", sections[2].KeyTerms)
+		}
+	}
+}
+
+func TestSectionsWithoutHeadings(t *testing.T) {
+	paragraphs := make([]string, 12)
+	for i := range paragraphs {
+		paragraphs[i] = fmt.Sprintf("Paragraph %d talks about topic%d in some detail.", i, i)
+	}
+	text := strings.Join(paragraphs, "\n\n")
+
+	sections := New().AnalyzeOfflineWithOptions(text, OfflineOptions{Sections: true}).Sections
+	if len(sections) != 3 {
+		t.Fatalf("Expected 3 windows of up to %d paragraphs, got %d", sectionWindowParagraphs, len(sections))
+	}
+	if sections[0].Title != "" || sections[1].Offset != runeOffset(t, text, paragraphs[5]) {
+		t.Errorf("Expected an untitled window starting at paragraph 5, got %+v", sections[1])
+	}
+
+	// A lower cap widens the windows so that the whole text is covered
+	a := New()
+	a.SetMaxSections(2)
+	sections = a.AnalyzeOfflineWithOptions(text, OfflineOptions{Sections: true}).Sections
+	if len(sections) != 2 || sections[1].Offset != runeOffset(t, text, paragraphs[6]) {
+		t.Errorf("Expected 2 windows of 6 paragraphs, got %+v", sections)
+	}
+}
+
+func TestSectionsCapped(t *testing.T) {
+	a := New()
+	a.SetMaxSections(2)
+
+	sections := a.AnalyzeOfflineWithOptions(sectionsFixture, OfflineOptions{Sections: true}).Sections
+	if len(sections) != 2 {
+		t.Errorf("Expected sections capped at 2, got %d", len(sections))
+	}
+}
+
+func TestSectionsDisabledByDefault(t *testing.T) {
+	if sections := New().AnalyzeOffline(sectionsFixture).Sections; sections != nil {
+		t.Errorf("Expected no sections unless requested, got %+v", sections)
+	}
+}
+
+func TestHeadingTitle(t *testing.T) {
+	tests := []struct {
+		paragraph string
+		last      bool
+		title     string
+		ok        bool
+	}{
+		{"# Introduction", false, "Introduction", true},
+		{"### Results ###", false, "Results", true},
+		{"## Methods\nWe sampled forty sites.", false, "Methods", true},
+		{"#hashtag", false, "", false},
+		{"Chapter 2: The Harbour", false, "Chapter 2: The Harbour", true},
+		{"Chapter 2: The Harbour", true, "", false},
+		{"The results were clear.", false, "", false},
+		{"lowercase words only", false, "", false},
+		{"A line\nwith two lines", false, "", false},
+		{"This line has far too many words to be a heading in any reasonable document", false, "", false},
+	}
+
+	for _, tt := range tests {
+		title, ok := headingTitle(tt.paragraph, byteSpan{0, len(tt.paragraph)}, tt.last)
+		if title != tt.title || ok != tt.ok {
+			t.Errorf("headingTitle(%q) = %q, %v; expected %q, %v", tt.paragraph, title, ok, tt.title, tt.ok)
+		}
+	}
+}
+
+func TestCountWordTokensMatchesExtractWords(t *testing.T) {
+	for _, text := range []string{sectionsFixture, synopsisFixture, "snake_case, x2 — naïve café!", "東京は日本の首都です。Tokyo 東京", "I ❤️ Cafe\u0301 \u0301", ""} {
+		if got, want := countWordTokens(text), len(extractWords(text)); got != want {
+			t.Errorf("countWordTokens(%.30q) = %d, extractWords found %d", text, got, want)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package analyzer
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Span represents a segment of text with rune offsets into the original input.
+// Start is inclusive and End is exclusive.
+type Span struct {
+	Text       string `json:"text"`
+	Start      int    `json:"start"`
+	End        int    `json:"end"`
+	TokenCount int    `json:"token_count"`
+}
+
+// Segmentation contains the sentence and paragraph structure of a text
+type Segmentation struct {
+	Sentences  []Span `json:"sentences"`
+	Paragraphs []Span `json:"paragraphs"`
+}
+
+// byteSpan is a half-open byte range [start, end) into a string
+type byteSpan struct {
+	start int
+	end   int
+}
+
+// abbreviations are tokens that end with a period but do not end a sentence
+var abbreviations = map[string]bool{
+	"mr": true, "mrs": true, "ms": true, "dr": true, "prof": true, "sr": true, "jr": true,
+	"st": true, "mt": true, "gen": true, "gov": true, "sen": true, "rep": true, "rev": true,
+	"capt": true, "col": true, "lt": true, "sgt": true, "hon": true,
+	"inc": true, "ltd": true, "co": true, "corp": true, "dept": true, "univ": true,
+	"vs": true, "etc": true, "approx": true, "fig": true, "no": true, "vol": true, "pp": true,
+	"jan": true, "feb": true, "mar": true, "apr": true, "jun": true, "jul": true, "aug": true,
+	"sep": true, "sept": true, "oct": true, "nov": true, "dec": true,
+	"e.g": true, "i.e": true, "u.s": true, "u.k": true, "a.m": true, "p.m": true, "ph.d": true,
+}
+
+// Segment splits text into paragraphs and sentences with rune offsets.
+// Sentences never cross paragraph boundaries.
+func (a *Analyzer) Segment(text string) Segmentation {
+	seg := Segmentation{
+		Sentences:  []Span{},
+		Paragraphs: []Span{},
+	}
+
+	offsets := newRuneOffsets(text)
+	for _, para := range paragraphSpans(text) {
+		seg.Paragraphs = append(seg.Paragraphs, offsets.span(text, para))
+		for _, sentence := range sentenceSpans(text[para.start:para.end]) {
+			sentence.start += para.start
+			sentence.end += para.start
+			seg.Sentences = append(seg.Sentences, offsets.span(text, sentence))
+		}
+	}
+
+	return seg
+}
+
+// paragraphSpans returns the byte spans of paragraphs in text. Paragraphs are
+// separated by blank lines; paragraphs over 1000 characters are further split
+// on single newlines. Spans are trimmed of surrounding whitespace.
+func paragraphSpans(text string) []byteSpan {
+	var spans []byteSpan
+
+	pos := 0
+	for pos <= len(text) {
+		next := strings.Index(text[pos:], "\n\n")
+		end := len(text)
+		if next != -1 {
+			end = pos + next
+		}
+
+		para := trimSpan(text, byteSpan{pos, end})
+		if para.end > para.start {
+			// If paragraph is very long, try splitting by single newline
+			if para.end-para.start > 1000 {
+				spans = append(spans, lineSpans(text, para)...)
+			} else {
+				spans = append(spans, para)
+			}
+		}
+
+		if next == -1 {
+			break
+		}
+		pos = end + 2
+	}
+
+	return spans
+}
+
+// lineSpans splits a span on single newlines, dropping blank lines
+func lineSpans(text string, span byteSpan) []byteSpan {
+	var spans []byteSpan
+	start := span.start
+	for i := span.start; i <= span.end; i++ {
+		if i == span.end || text[i] == '\n' {
+			line := trimSpan(text, byteSpan{start, i})
+			if line.end > line.start {
+				spans = append(spans, line)
+			}
+			start = i + 1
+		}
+	}
+	return spans
+}
+
+// trimSpan narrows a span so it excludes leading and trailing whitespace
+func trimSpan(text string, span byteSpan) byteSpan {
+	for span.start < span.end {
+		r, size := utf8.DecodeRuneInString(text[span.start:span.end])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		span.start += size
+	}
+	for span.end > span.start {
+		r, size := utf8.DecodeLastRuneInString(text[span.start:span.end])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		span.end -= size
+	}
+	return span
+}
+
+// sentenceSpans splits text into sentences using terminal punctuation,
+// skipping periods that belong to abbreviations, initials, or decimal numbers
+func sentenceSpans(text string) []byteSpan {
+	var spans []byteSpan
+
+	start := 0
+	i := 0
+	for i < len(text) {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if !isSentenceTerminal(r) {
+			i += size
+			continue
+		}
+
+		// Consume runs of terminal punctuation ("?!", "...")
+		end := i + size
+		for end < len(text) {
+			next, nextSize := utf8.DecodeRuneInString(text[end:])
+			if !isSentenceTerminal(next) {
+				break
+			}
+			end += nextSize
+		}
+
+		// Include closing quotes and brackets in the sentence
+		for end < len(text) {
+			next, nextSize := utf8.DecodeRuneInString(text[end:])
+			if !isClosingPunct(next) {
+				break
+			}
+			end += nextSize
+		}
+
+		if isSentenceBoundary(text, i, end) {
+			span := trimSpan(text, byteSpan{start, end})
+			if span.end > span.start {
+				spans = append(spans, span)
+			}
+			start = end
+		}
+		i = end
+	}
+
+	// Trailing text without terminal punctuation is still a sentence
+	if span := trimSpan(text, byteSpan{start, len(text)}); span.end > span.start {
+		spans = append(spans, span)
+	}
+
+	return spans
+}
+
+// isSentenceBoundary reports whether the terminal punctuation at text[punct:end]
+// actually ends a sentence
+func isSentenceBoundary(text string, punct, end int) bool {
+	// End of input always closes the sentence
+	if end >= len(text) {
+		return true
+	}
+
+	// Full-width CJK punctuation ends a sentence without trailing whitespace
+	if r, _ := utf8.DecodeRuneInString(text[punct:]); r == '。' || r == '！' || r == '？' {
+		return true
+	}
+
+	// A boundary must be followed by whitespace
+	next, _ := utf8.DecodeRuneInString(text[end:])
+	if !unicode.IsSpace(next) {
+		return false
+	}
+
+	// Only periods are ambiguous; ! and ? always end sentences
+	if text[punct] != '.' || (end > punct+1 && text[punct+1] == '.') {
+		return true
+	}
+
+	word := strings.ToLower(precedingWord(text, punct))
+	if word == "" {
+		return true
+	}
+
+	// Single-letter initials ("J. Smith") and known abbreviations ("Dr.")
+	if utf8.RuneCountInString(word) == 1 && unicode.IsLetter([]rune(word)[0]) {
+		return false
+	}
+	if abbreviations[word] {
+		return false
+	}
+
+	// Lowercase continuation after a period suggests an unknown abbreviation
+	rest := strings.TrimLeftFunc(text[end:], unicode.IsSpace)
+	if rest != "" {
+		first, _ := utf8.DecodeRuneInString(rest)
+		if unicode.IsLower(first) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// precedingWord returns the token immediately before byte index pos,
+// keeping internal periods so "e.g" and "U.S" are recognized
+func precedingWord(text string, pos int) string {
+	start := pos
+	for start > 0 {
+		r, size := utf8.DecodeLastRuneInString(text[:start])
+		if !unicode.IsLetter(r) && r != '.' {
+			break
+		}
+		start -= size
+	}
+	return strings.Trim(text[start:pos], ".")
+}
+
+// isSentenceTerminal reports whether r ends a sentence
+func isSentenceTerminal(r rune) bool {
+	switch r {
+	case '.', '!', '?', '…', '。', '！', '？':
+		return true
+	}
+	return false
+}
+
+// isClosingPunct reports whether r closes a quotation or parenthetical
+func isClosingPunct(r rune) bool {
+	switch r {
+	case '"', '\'', ')', ']', '”', '’', '»':
+		return true
+	}
+	return false
+}
+
+// runeOffsets converts byte offsets to rune offsets for a fixed string
+type runeOffsets struct {
+	byteToRune []int
+}
+
+// newRuneOffsets builds a byte-to-rune index for text
+func newRuneOffsets(text string) runeOffsets {
+	index := make([]int, len(text)+1)
+	runeIdx := 0
+	for i := 0; i < len(text); {
+		_, size := utf8.DecodeRuneInString(text[i:])
+		for j := 0; j < size; j++ {
+			index[i+j] = runeIdx
+		}
+		runeIdx++
+		i += size
+	}
+	index[len(text)] = runeIdx
+	return runeOffsets{byteToRune: index}
+}
+
+// span converts a byte span into a Span with rune offsets and a token count
+func (o runeOffsets) span(text string, s byteSpan) Span {
+	segment := text[s.start:s.end]
+	return Span{
+		Text:       segment,
+		Start:      o.byteToRune[s.start],
+		End:        o.byteToRune[s.end],
+		TokenCount: len(extractWords(segment)),
+	}
+}
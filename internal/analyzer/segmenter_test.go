@@ -0,0 +1,95 @@
+package analyzer
+
+import (
+	"testing"
+)
+
+func TestSegmentSentences(t *testing.T) {
+	a := New()
+
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{
+			name:     "simple sentences",
+			input:    "First sentence. Second one! Third?",
+			expected: []string{"First sentence.", "Second one!", "Third?"},
+		},
+		{
+			name:     "abbreviations and initials",
+			input:    "Mr. J. Smith met Dr. Jones, e.g. at the U.S. embassy. They talked.",
+			expected: []string{"Mr. J. Smith met Dr. Jones, e.g. at the U.S. embassy.", "They talked."},
+		},
+		{
+			name:     "decimal numbers",
+			input:    "Growth was 3.5 percent. Inflation was 2.1 percent.",
+			expected: []string{"Growth was 3.5 percent.", "Inflation was 2.1 percent."},
+		},
+		{
+			name:     "closing quotes stay with sentence",
+			input:    `She said "stop." Then she left.`,
+			expected: []string{`She said "stop."`, "Then she left."},
+		},
+		{
+			name:     "ellipsis and repeated punctuation",
+			input:    "Wait... What?! Fine.",
+			expected: []string{"Wait...", "What?!", "Fine."},
+		},
+		{
+			name:     "CJK punctuation without spaces",
+			input:    "今日は晴れです。明日は雨です。",
+			expected: []string{"今日は晴れです。", "明日は雨です。"},
+		},
+		{
+			name:     "trailing text without punctuation",
+			input:    "One sentence. Trailing fragment",
+			expected: []string{"One sentence.", "Trailing fragment"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seg := a.Segment(tt.input)
+			if len(seg.Sentences) != len(tt.expected) {
+				t.Fatalf("expected %d sentences, got %d: %+v", len(tt.expected), len(seg.Sentences), seg.Sentences)
+			}
+			runes := []rune(tt.input)
+			for i, s := range seg.Sentences {
+				if s.Text != tt.expected[i] {
+					t.Errorf("sentence %d: expected %q, got %q", i, tt.expected[i], s.Text)
+				}
+				if string(runes[s.Start:s.End]) != s.Text {
+					t.Errorf("sentence %d: offsets [%d:%d] do not match text", i, s.Start, s.End)
+				}
+			}
+		})
+	}
+}
+
+func TestSegmentParagraphs(t *testing.T) {
+	a := New()
+
+	seg := a.Segment("  First paragraph here.\n\n\n\nSecond paragraph. With two sentences.  ")
+
+	if len(seg.Paragraphs) != 2 {
+		t.Fatalf("expected 2 paragraphs, got %d", len(seg.Paragraphs))
+	}
+	if seg.Paragraphs[0].Start != 2 || seg.Paragraphs[0].Text != "First paragraph here." {
+		t.Errorf("unexpected first paragraph: %+v", seg.Paragraphs[0])
+	}
+	if len(seg.Sentences) != 3 {
+		t.Errorf("expected 3 sentences, got %d", len(seg.Sentences))
+	}
+	if seg.Sentences[1].TokenCount != 2 {
+		t.Errorf("expected 2 tokens in second sentence, got %d", seg.Sentences[1].TokenCount)
+	}
+}
+
+func TestSegmentEmpty(t *testing.T) {
+	seg := New().Segment("   \n\n  ")
+	if len(seg.Sentences) != 0 || len(seg.Paragraphs) != 0 {
+		t.Errorf("expected no segments for whitespace input, got %+v", seg)
+	}
+}
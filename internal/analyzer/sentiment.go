@@ -0,0 +1,256 @@
+package analyzer
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/docutag/textanalyzer/internal/models"
+)
+
+// negationWindow is how many words after a negator it flips the polarity of
+const negationWindow = 3
+
+// negationScale is how strongly a negated word counts in the opposite
+// direction: "not good" is milder than "bad"
+const negationScale = 0.75
+
+// Weights of the words before and after a contrast ("good, but slow") in
+// the same sentence; the clause after the contrast carries the sentiment
+const (
+	beforeContrastWeight = 0.5
+	afterContrastWeight  = 1.5
+)
+
+// maxSentenceSentiments is the most sentences recorded in
+// Metadata.SentenceSentiments, keeping the most strongly worded
+const maxSentenceSentiments = 100
+
+// negators flip the polarity of the sentiment words following them,
+// contractions spelled without their apostrophe
+var negators = map[string]bool{
+	"not": true, "no": true, "never": true, "none": true, "nobody": true, "nothing": true,
+	"neither": true, "nor": true, "without": true, "hardly": true, "cannot": true, "cant": true,
+	"dont": true, "doesnt": true, "didnt": true, "isnt": true, "wasnt": true, "arent": true,
+	"werent": true, "wont": true, "wouldnt": true, "shouldnt": true, "couldnt": true,
+	"havent": true, "hasnt": true, "hadnt": true, "aint": true, "mustnt": true, "neednt": true,
+}
+
+// intensities scale the weight of the sentiment word following them, above
+// one for intensifiers and below one for diminishers
+var intensities = map[string]float64{
+	"very": 1.5, "really": 1.4, "so": 1.3, "too": 1.3, "truly": 1.4, "highly": 1.5,
+	"extremely": 1.8, "absolutely": 1.8, "incredibly": 1.8, "totally": 1.5, "completely": 1.5,
+	"utterly": 1.8, "deeply": 1.5, "especially": 1.3, "particularly": 1.3, "most": 1.3,
+	"slightly": 0.5, "somewhat": 0.6, "barely": 0.4, "fairly": 0.7, "rather": 0.8,
+	"mildly": 0.5, "marginally": 0.5, "partly": 0.6, "bit": 0.6, "little": 0.6,
+}
+
+// contrasts shift the weight of a sentence to the clause that follows them
+var contrasts = map[string]bool{
+	"but": true, "however": true,
+}
+
+// sentimentTokens splits a sentence into lowercase words, with an empty
+// string marking each clause break (a comma, semicolon, colon or dash) that
+// ends the reach of negators and intensifiers. Apostrophes are dropped so
+// "don't" and "dont" read alike.
+func sentimentTokens(text string) []string {
+	var tokens []string
+	var word strings.Builder
+	flush := func() {
+		if word.Len() > 0 {
+			tokens = append(tokens, word.String())
+			word.Reset()
+		}
+	}
+
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word.WriteRune(r)
+		case r == '\'' || r == '’':
+			// Part of the word
+		case r == ',' || r == ';' || r == ':' || r == '—' || r == '–' || r == '(' || r == ')':
+			flush()
+			if len(tokens) > 0 && tokens[len(tokens)-1] != "" {
+				tokens = append(tokens, "")
+			}
+		default:
+			flush()
+		}
+	}
+	flush()
+	return tokens
+}
+
+// valence returns the summed polarity of a sentence's sentiment words,
+// weighted by the negators, intensifiers and contrasts around them, and the
+// number of words in it. It reports whether any sentiment word was found.
+func (l SentimentLexicon) valence(text string) (float64, int, bool) {
+	tokens := sentimentTokens(text)
+
+	// The last contrast of the sentence splits it
+	contrast := -1
+	for i, token := range tokens {
+		if contrasts[token] {
+			contrast = i
+		}
+	}
+
+	sum, words, found := 0.0, 0, false
+	for i, token := range tokens {
+		if token == "" {
+			continue
+		}
+		words++
+
+		polarity := 0.0
+		switch {
+		case l.Positive[token]:
+			polarity = 1
+		case l.Negative[token]:
+			polarity = -1
+		default:
+			continue
+		}
+		found = true
+
+		// Look back through the clause for modifiers
+		negated := false
+		for j := i - 1; j >= 0 && j >= i-negationWindow; j-- {
+			previous := tokens[j]
+			if previous == "" || contrasts[previous] {
+				break
+			}
+			if intensity, ok := intensities[previous]; ok && j >= i-2 {
+				polarity *= intensity
+			}
+			if negators[previous] {
+				negated = true
+			}
+		}
+		if negated {
+			polarity *= -negationScale
+		}
+
+		switch {
+		case contrast < 0:
+		case i < contrast:
+			polarity *= beforeContrastWeight
+		default:
+			polarity *= afterContrastWeight
+		}
+		sum += polarity
+	}
+	return sum, words, found
+}
+
+// sentimentScore scales a valence over a number of words to the -1 to 1
+// sentiment scale
+func sentimentScore(valence float64, words int) float64 {
+	if words == 0 {
+		return 0
+	}
+	return math.Max(-1.0, math.Min(1.0, valence/float64(words)*10))
+}
+
+// sentimentLabel names the sentiment of a score
+func sentimentLabel(score float64) string {
+	switch {
+	case score > 0.1:
+		return "positive"
+	case score < -0.1:
+		return "negative"
+	default:
+		return "neutral"
+	}
+}
+
+// analyzeSentiment scores the sentiment of text from its sentiment words,
+// sentence by sentence so negation, intensity and contrast are read in
+// context
+func (a *Analyzer) analyzeSentiment(text string) (string, float64) {
+	sum, words, found := 0.0, 0, false
+	for _, span := range sentenceSpans(text) {
+		valence, n, ok := a.sentiment.valence(text[span.start:span.end])
+		sum += valence
+		words += n
+		found = found || ok
+	}
+	if !found {
+		return "neutral", 0.0
+	}
+
+	score := sentimentScore(sum, words)
+	return sentimentLabel(score), math.Round(score*100) / 100
+}
+
+// scoredSentence is the sentiment of one sentence of a text
+type scoredSentence struct {
+	offset int // In runes
+	length int // In runes
+	score  float64
+	found  bool // Whether the sentence has sentiment words
+}
+
+// scoreSentences scores each sentence of text on the same scale as
+// analyzeSentiment, skipping sentences without words
+func (a *Analyzer) scoreSentences(text string) []scoredSentence {
+	var sentences []scoredSentence
+	runePos, bytePos := 0, 0
+	for _, span := range sentenceSpans(text) {
+		runePos += utf8.RuneCountInString(text[bytePos:span.start])
+		length := utf8.RuneCountInString(text[span.start:span.end])
+		valence, words, found := a.sentiment.valence(text[span.start:span.end])
+		if words > 0 {
+			sentences = append(sentences, scoredSentence{
+				offset: runePos,
+				length: length,
+				score:  sentimentScore(valence, words),
+				found:  found,
+			})
+		}
+		runePos += length
+		bytePos = span.end
+	}
+	return sentences
+}
+
+// sentenceSentimentBreakdown returns the sentiment of the sentences with
+// sentiment words, in text order. Past maxSentenceSentiments sentences only
+// the most strongly worded are kept.
+func sentenceSentimentBreakdown(sentences []scoredSentence) []models.SentenceSentiment {
+	var scored []scoredSentence
+	for _, sentence := range sentences {
+		if sentence.found {
+			scored = append(scored, sentence)
+		}
+	}
+	if len(scored) > maxSentenceSentiments {
+		sort.SliceStable(scored, func(i, j int) bool {
+			return math.Abs(scored[i].score) > math.Abs(scored[j].score)
+		})
+		scored = scored[:maxSentenceSentiments]
+		sort.SliceStable(scored, func(i, j int) bool {
+			return scored[i].offset < scored[j].offset
+		})
+	}
+
+	breakdown := make([]models.SentenceSentiment, 0, len(scored))
+	for _, sentence := range scored {
+		score := math.Round(sentence.score*100) / 100
+		breakdown = append(breakdown, models.SentenceSentiment{
+			Offset:    sentence.offset,
+			Length:    sentence.length,
+			Sentiment: sentimentLabel(score),
+			Score:     score,
+		})
+	}
+	if len(breakdown) == 0 {
+		return nil
+	}
+	return breakdown
+}
@@ -0,0 +1,99 @@
+package analyzer
+
+import (
+	"math"
+
+	"github.com/docutag/textanalyzer/internal/models"
+)
+
+// Sentiment arc shapes recorded in Metadata.SentimentArc
+const (
+	SentimentArcSteady  = "steady"
+	SentimentArcRising  = "rising"
+	SentimentArcFalling = "falling"
+	SentimentArcValley  = "valley"
+	SentimentArcPeak    = "peak"
+)
+
+// sentimentTrajectoryBuckets is the number of segments a document's
+// sentiment is averaged over
+const sentimentTrajectoryBuckets = 10
+
+// minArcSentences is the fewest sentences a document needs before its
+// sentiment trajectory means anything
+const minArcSentences = 5
+
+// arcThreshold is how far apart (on the -1 to 1 sentiment scale) two parts
+// of a document must be before the arc counts as moving
+const arcThreshold = 0.2
+
+// applySentenceSentiment sets the per-sentence sentiment, trajectory and
+// arc on metadata
+func (a *Analyzer) applySentenceSentiment(metadata *models.Metadata, text string) {
+	sentences := a.scoreSentences(text)
+	scores := make([]float64, len(sentences))
+	for i, sentence := range sentences {
+		scores[i] = sentence.score
+	}
+
+	metadata.SentenceSentiments = sentenceSentimentBreakdown(sentences)
+	metadata.SentimentTrajectory = sentimentTrajectory(scores)
+	metadata.SentimentArc = classifySentimentArc(metadata.SentimentTrajectory)
+}
+
+// sentimentTrajectory smooths per-sentence scores by splitting the sentences
+// into up to sentimentTrajectoryBuckets equal runs and averaging each run.
+// It returns nil for documents shorter than minArcSentences.
+func sentimentTrajectory(scores []float64) []float64 {
+	if len(scores) < minArcSentences {
+		return nil
+	}
+
+	buckets := min(sentimentTrajectoryBuckets, len(scores))
+	trajectory := make([]float64, buckets)
+	for i := range trajectory {
+		start := i * len(scores) / buckets
+		end := (i + 1) * len(scores) / buckets
+		trajectory[i] = math.Round(mean(scores[start:end])*100) / 100
+	}
+	return trajectory
+}
+
+// classifySentimentArc compares the average sentiment of the first, middle
+// and last thirds of a trajectory to name its shape. It returns an empty
+// string when there is no trajectory.
+func classifySentimentArc(trajectory []float64) string {
+	if len(trajectory) == 0 {
+		return ""
+	}
+
+	third := max(1, len(trajectory)/3)
+	start := mean(trajectory[:third])
+	middle := mean(trajectory[third : len(trajectory)-third])
+	end := mean(trajectory[len(trajectory)-third:])
+
+	switch {
+	case middle < start-arcThreshold && middle < end-arcThreshold:
+		return SentimentArcValley
+	case middle > start+arcThreshold && middle > end+arcThreshold:
+		return SentimentArcPeak
+	case end-start > arcThreshold:
+		return SentimentArcRising
+	case start-end > arcThreshold:
+		return SentimentArcFalling
+	default:
+		return SentimentArcSteady
+	}
+}
+
+// mean returns the average of values, or 0 when there are none
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, value := range values {
+		sum += value
+	}
+	return sum / float64(len(values))
+}
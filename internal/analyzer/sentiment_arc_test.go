@@ -0,0 +1,76 @@
+package analyzer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	positiveSentence = "The team had a wonderful day."
+	negativeSentence = "The team had a terrible day."
+	neutralSentence  = "The team had a long day."
+)
+
+// arcDocument builds a document from sentences written as '+', '-' or '0'
+func arcDocument(shape string) string {
+	sentences := make([]string, 0, len(shape))
+	for _, c := range shape {
+		switch c {
+		case '+':
+			sentences = append(sentences, positiveSentence)
+		case '-':
+			sentences = append(sentences, negativeSentence)
+		default:
+			sentences = append(sentences, neutralSentence)
+		}
+	}
+	return strings.Join(sentences, " ")
+}
+
+func TestSentimentTrajectoryValley(t *testing.T) {
+	// Twenty sentences averaged in pairs: falls from positive to negative,
+	// then rises back
+	text := arcDocument("++" + "+0" + "00" + "0-" + "--" + "--" + "-0" + "00" + "0+" + "++")
+
+	a := New()
+	metadata := a.AnalyzeOffline(text)
+
+	want := []float64{1, 0.5, 0, -0.5, -1, -1, -0.5, 0, 0.5, 1}
+	if !reflect.DeepEqual(metadata.SentimentTrajectory, want) {
+		t.Errorf("Expected trajectory %v, got %v", want, metadata.SentimentTrajectory)
+	}
+	if metadata.SentimentArc != SentimentArcValley {
+		t.Errorf("Expected arc %q, got %q", SentimentArcValley, metadata.SentimentArc)
+	}
+}
+
+func TestClassifySentimentArc(t *testing.T) {
+	tests := []struct {
+		name       string
+		trajectory []float64
+		want       string
+	}{
+		{"no trajectory", nil, ""},
+		{"steady", []float64{0.1, 0, 0.1, 0, 0.1, 0, 0.1, 0, 0.1, 0}, SentimentArcSteady},
+		{"rising", []float64{-0.8, -0.6, -0.4, -0.2, 0, 0.2, 0.4, 0.6, 0.8, 1}, SentimentArcRising},
+		{"falling", []float64{1, 0.8, 0.6, 0.4, 0.2, 0, -0.2, -0.4, -0.6, -0.8}, SentimentArcFalling},
+		{"valley", []float64{0.5, 0.5, 0, -0.5, -0.5, -0.5, 0, 0.5, 0.5, 0.5}, SentimentArcValley},
+		{"peak", []float64{-0.5, 0, 0.5, 0.8, 0.8, 0.5, 0, -0.5, -0.5, -0.5}, SentimentArcPeak},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifySentimentArc(tt.trajectory); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSentimentTrajectoryShortText(t *testing.T) {
+	metadata := New().AnalyzeOffline(arcDocument("+-+"))
+	if metadata.SentimentTrajectory != nil || metadata.SentimentArc != "" {
+		t.Errorf("Expected no trajectory for a short text, got %v (%q)", metadata.SentimentTrajectory, metadata.SentimentArc)
+	}
+}
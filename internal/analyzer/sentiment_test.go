@@ -0,0 +1,129 @@
+package analyzer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/docutag/textanalyzer/internal/models"
+)
+
+func TestSentimentTrickySentences(t *testing.T) {
+	a := New()
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// Negated sentiment words read as their opposite
+		{"The food was not good at all.", "negative"},
+		{"I don't love the new design.", "negative"},
+		{"I didn’t like the ending, it was never enjoyable.", "negative"},
+		{"The service was not bad.", "positive"},
+		{"Slow loading is no longer a problem.", "positive"},
+		{"We finished without any problems.", "positive"},
+		{"The staff were never annoying.", "positive"},
+		// Contrasts weigh the clause after "but"
+		{"The food was good but the service was terrible.", "negative"},
+		{"The start was slow and awkward, but the ending was wonderful.", "positive"},
+		{"It looked great; however, the battery was a failure.", "negative"},
+		// Clause breaks end the reach of a negator
+		{"Not once did it crash, and the battery is excellent.", "positive"},
+		{"No, the update is excellent.", "positive"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			sentiment, score := a.analyzeSentiment(tt.input)
+			if sentiment != tt.expected {
+				t.Errorf("Expected %s, got %s (score %v)", tt.expected, sentiment, score)
+			}
+			if score < -1 || score > 1 {
+				t.Errorf("Expected a score in [-1, 1], got %v", score)
+			}
+		})
+	}
+}
+
+func TestSentimentIntensity(t *testing.T) {
+	// Padding keeps the scores clear of the -1 and 1 bounds
+	padding := " The report covers the quarter, the teams involved and the plans for the coming months in some detail."
+	a := New()
+	score := func(sentence string) float64 {
+		_, score := a.analyzeSentiment(sentence + padding)
+		return score
+	}
+
+	if plain, intensified := score("The launch was terrible."), score("The launch was absolutely terrible."); intensified >= plain {
+		t.Errorf("Expected an intensifier to strengthen the sentiment, got %v and %v", plain, intensified)
+	}
+	if plain, diminished := score("The launch was disappointing."), score("The launch was slightly disappointing."); diminished <= plain {
+		t.Errorf("Expected a diminisher to weaken the sentiment, got %v and %v", plain, diminished)
+	}
+	if plain, negated := score("The launch was bad."), score("The launch was not bad."); negated <= 0 || negated >= -plain {
+		t.Errorf("Expected a negated word to count less in the opposite direction, got %v and %v", plain, negated)
+	}
+}
+
+func TestSentimentTokens(t *testing.T) {
+	tokens := sentimentTokens("Honestly, it isn't bad; it’s (mostly) fine.")
+	expected := []string{"honestly", "", "it", "isnt", "bad", "", "its", "", "mostly", "", "fine"}
+	if !reflect.DeepEqual(tokens, expected) {
+		t.Errorf("Expected %q, got %q", expected, tokens)
+	}
+}
+
+func TestSentenceSentiments(t *testing.T) {
+	text := "Café prices rose. The coffee was excellent. It was not good value, and the staff were annoying."
+	a := New()
+	metadata := &models.Metadata{}
+	a.applySentenceSentiment(metadata, text)
+
+	if len(metadata.SentenceSentiments) != 2 {
+		t.Fatalf("Expected the 2 sentences with sentiment words, got %+v", metadata.SentenceSentiments)
+	}
+	positive, negative := metadata.SentenceSentiments[0], metadata.SentenceSentiments[1]
+	if got := string([]rune(text)[positive.Offset : positive.Offset+positive.Length]); got != "The coffee was excellent." {
+		t.Errorf("Expected the offset and length of the second sentence, got %q", got)
+	}
+	if positive.Sentiment != "positive" || positive.Score <= 0 {
+		t.Errorf("Expected a positive sentence, got %+v", positive)
+	}
+	if !strings.HasPrefix(string([]rune(text)[negative.Offset:]), "It was not") {
+		t.Errorf("Expected the offset of the third sentence, got %d", negative.Offset)
+	}
+	if negative.Sentiment != "negative" || negative.Score >= 0 {
+		t.Errorf("Expected a negative sentence, got %+v", negative)
+	}
+
+	// Long documents keep the most strongly worded sentences, in order
+	sentences := make([]string, 0, maxSentenceSentiments+10)
+	for i := range maxSentenceSentiments + 10 {
+		if i%10 == 0 {
+			sentences = append(sentences, "It was terrible.")
+		} else {
+			sentences = append(sentences, "The long meeting covered a good number of the items on the agenda today.")
+		}
+	}
+	a.applySentenceSentiment(metadata, strings.Join(sentences, " "))
+	breakdown := metadata.SentenceSentiments
+	if len(breakdown) != maxSentenceSentiments {
+		t.Fatalf("Expected %d sentences, got %d", maxSentenceSentiments, len(breakdown))
+	}
+	negatives := 0
+	for i, sentence := range breakdown {
+		if sentence.Sentiment == "negative" {
+			negatives++
+		}
+		if i > 0 && sentence.Offset <= breakdown[i-1].Offset {
+			t.Fatalf("Expected sentences in text order, got %+v", breakdown)
+		}
+	}
+	if negatives != (maxSentenceSentiments+10)/10 {
+		t.Errorf("Expected every strongly negative sentence kept, got %d", negatives)
+	}
+
+	a.applySentenceSentiment(metadata, "The meeting covered the agenda.")
+	if metadata.SentenceSentiments != nil {
+		t.Errorf("Expected no breakdown without sentiment words, got %+v", metadata.SentenceSentiments)
+	}
+}
@@ -0,0 +1,69 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/docutag/textanalyzer/internal/models"
+	"github.com/docutag/textanalyzer/internal/tags"
+)
+
+// ShadowEnrich generates tags and scores quality with a candidate model the
+// way enrichment does with the primary model, and compares the results with
+// primary, the enriched metadata of the same text. primary is not modified.
+//
+// Like enrichment with HTML context, the AI steps read the cleaned text when
+// there is one, with personal data masked when enrichment masked it. Tag
+// overlap ignores structural tags such as sentiment and length, which both
+// models share.
+func (a *Analyzer) ShadowEnrich(ctx context.Context, shadow LLMClient, text string, primary models.Metadata) (*models.ShadowResult, error) {
+	analysisText := text
+	if primary.CleanedText != "" {
+		analysisText = primary.CleanedText
+	}
+	analysisText = a.ModelText(analysisText, AnalysisOptions{RedactPII: primary.RedactPII})
+
+	aiTags, err := shadow.GenerateTags(ctx, analysisText, map[string]interface{}{
+		"sentiment": primary.Sentiment,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("shadow tag generation failed: %w", err)
+	}
+
+	quality, err := shadow.ScoreTextQuality(ctx, analysisText)
+	if err != nil {
+		return nil, fmt.Errorf("shadow quality scoring failed: %w", err)
+	}
+
+	result := &models.ShadowResult{
+		Model:        shadow.Model(),
+		PrimaryModel: a.ModelName(),
+		Tags:         a.mergeTags(generateTags(text, primary), aiTags),
+		PrimaryTags:  append([]string{}, primary.Tags...),
+		QualityScore: &quality.Score,
+		CreatedAt:    time.Now(),
+	}
+	result.TagJaccard = tags.Jaccard(topicTags(result.Tags), topicTags(result.PrimaryTags))
+
+	// Rule-based fallback scores say nothing about the primary model
+	if primary.QualityScore != nil && primary.QualityScore.AIUsed {
+		primaryScore := primary.QualityScore.Score
+		delta := quality.Score - primaryScore
+		result.PrimaryQuality = &primaryScore
+		result.QualityScoreDelta = &delta
+	}
+
+	return result, nil
+}
+
+// topicTags returns the tags that describe topic rather than document shape
+func topicTags(list []string) []string {
+	topics := make([]string, 0, len(list))
+	for _, tag := range list {
+		if !tags.IsStructural(tag) {
+			topics = append(topics, tag)
+		}
+	}
+	return topics
+}
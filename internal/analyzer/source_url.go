@@ -0,0 +1,122 @@
+package analyzer
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// trackingParams are query parameters that identify a campaign or click
+// rather than a page, dropped when normalizing source URLs and, optionally,
+// from extracted URLs. Parameters starting with "utm_" are dropped too.
+var trackingParams = map[string]bool{
+	"fbclid":  true,
+	"gclid":   true,
+	"dclid":   true,
+	"gbraid":  true,
+	"wbraid":  true,
+	"msclkid": true,
+	"yclid":   true,
+	"mc_cid":  true,
+	"mc_eid":  true,
+	"igshid":  true,
+	"_ga":     true,
+	"_gl":     true,
+	"ref_src": true,
+}
+
+// NormalizeSourceURL returns the form of a page URL used to group analyses
+// of the same page: the scheme and host are lowercased, default ports,
+// credentials, fragments, tracking parameters and trailing slashes are
+// removed, and the remaining query parameters are sorted
+func NormalizeSourceURL(rawURL string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return "", fmt.Errorf("invalid source URL %q: %w", rawURL, err)
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("invalid source URL %q: scheme must be http or https", rawURL)
+	}
+
+	host, port := u.Hostname(), u.Port()
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "" {
+		return "", fmt.Errorf("invalid source URL %q: missing host", rawURL)
+	}
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	switch {
+	case port != "":
+		u.Host = net.JoinHostPort(host, port)
+	case strings.Contains(host, ":"):
+		u.Host = "[" + host + "]" // IPv6 literal
+	default:
+		u.Host = host
+	}
+
+	u.User = nil
+	u.Fragment, u.RawFragment = "", ""
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.RawPath = ""
+
+	query := u.Query()
+	for name := range query {
+		if isTrackingParam(name) {
+			query.Del(name)
+		}
+	}
+	u.RawQuery = query.Encode()
+	u.ForceQuery = false
+
+	return u.String(), nil
+}
+
+// isTrackingParam reports whether the query parameter name is a tracking
+// parameter (see trackingParams)
+func isTrackingParam(name string) bool {
+	name = strings.ToLower(name)
+	return trackingParams[name] || strings.HasPrefix(name, "utm_")
+}
+
+// CanonicalSourceURL returns the normalized URL an analysis is grouped
+// under. fetchedURL is where the page was actually fetched from after
+// redirects, if known. Trivial redirects, which only upgrade the scheme or
+// add or remove a "www." prefix, resolve to the fetched URL so both forms
+// group together; any other redirect keeps the requested URL, since the
+// fetched page may be a login wall or a different page altogether.
+func CanonicalSourceURL(requestedURL, fetchedURL string) (string, error) {
+	requested, err := NormalizeSourceURL(requestedURL)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(fetchedURL) == "" {
+		return requested, nil
+	}
+	fetched, err := NormalizeSourceURL(fetchedURL)
+	if err != nil {
+		return "", err
+	}
+	if isTrivialRedirect(requested, fetched) {
+		return fetched, nil
+	}
+	return requested, nil
+}
+
+// isTrivialRedirect reports whether two normalized URLs differ at most in
+// their scheme and a "www." host prefix
+func isTrivialRedirect(from, to string) bool {
+	fromURL, err := url.Parse(from)
+	if err != nil {
+		return false
+	}
+	toURL, err := url.Parse(to)
+	if err != nil {
+		return false
+	}
+	return strings.TrimPrefix(fromURL.Host, "www.") == strings.TrimPrefix(toURL.Host, "www.") &&
+		fromURL.EscapedPath() == toURL.EscapedPath() &&
+		fromURL.RawQuery == toURL.RawQuery
+}
@@ -0,0 +1,260 @@
+package analyzer
+
+import "strings"
+
+// syllableVowels are the vowels that start a syllable, including accented
+// Latin and Cyrillic vowels
+const syllableVowels = "aeiouyàáâãäåæèéêëìíîïòóôõöøœùúûüýÿаеёиоуыэюя"
+
+// silentESuffixes end words whose e before the suffix is silent after a
+// consonant, as in "lately" and "statement"
+var silentESuffixes = []string{"ely", "ement", "ements", "eness", "eful", "efully", "eless"}
+
+// syllableExceptions holds the syllable counts of frequent English words
+// that countEnglishSyllables gets wrong
+var syllableExceptions = map[string]int{
+	// Compounds whose inner e is silent
+	"every": 2, "everything": 3, "everybody": 4, "everywhere": 3,
+	"everyday": 3, "anyone": 3, "someone": 2, "something": 2, "sometimes": 2,
+	"sometime": 2, "somewhere": 2, "somebody": 3, "somehow": 2, "somewhat": 2,
+	"elsewhere": 2, "therefore": 2, "nevertheless": 4, "whatsoever": 4,
+	"likewise": 2, "homework": 2, "lifetime": 2, "lifestyle": 2,
+	"timeline": 2, "baseline": 2, "guideline": 2, "guidelines": 2,
+	"sidewalk": 2, "homeland": 2, "framework": 2, "frameworks": 2,
+	"statesman": 2, "homepage": 2, "someday": 2, "stakeholder": 3,
+	"stakeholders": 3, "shareholder": 3, "shareholders": 3, "livestock": 2,
+	"moreover": 3, "whereby": 2, "hereby": 2, "thereby": 2,
+
+	// Vowels spoken apart, final e spoken, and other spellings the rules misread
+	"business": 2, "businesses": 3, "people": 2, "peoples": 2, "area": 3,
+	"areas": 3, "idea": 3, "ideas": 3, "ideal": 3, "ideally": 4, "reality": 4,
+	"realize": 3, "realized": 3, "realizes": 4, "realise": 3, "realised": 3,
+	"realistic": 4, "create": 2, "created": 3, "creates": 2, "creating": 3,
+	"creation": 3, "creations": 3, "creative": 3, "creativity": 5,
+	"creator": 3, "creators": 3, "react": 2, "reacted": 3, "reaction": 3,
+	"reactions": 3, "reactor": 3, "theater": 3, "theatre": 3, "museum": 3,
+	"museums": 3, "european": 4, "europeans": 4, "korea": 3, "korean": 3,
+	"geography": 4, "geology": 4, "geometry": 4, "poem": 2, "poems": 2,
+	"poet": 2, "poets": 2, "poetry": 3, "poetic": 3, "beings": 2, "friend": 1,
+	"friends": 1, "friendly": 2, "friendship": 2, "friendships": 2,
+	"boyfriend": 2, "girlfriend": 2, "cooperate": 4, "cooperation": 5,
+	"coordinate": 4, "coordination": 5, "coordinator": 5, "recipe": 3,
+	"recipes": 3, "maybe": 2, "evening": 2, "evenings": 2, "wednesday": 2,
+	"element": 3, "elements": 3, "cement": 2, "vehement": 3, "ratio": 3,
+	"ratios": 3, "fuel": 2, "fuels": 2, "cruel": 2, "duel": 2, "naked": 2,
+	"wicked": 2, "crooked": 2, "rugged": 2, "ragged": 2, "dogged": 2,
+	"colonel": 2, "chaos": 2, "cafe": 2, "karate": 3, "finale": 3, "acne": 2,
+	"apostrophe": 4, "catastrophe": 4, "epitome": 4, "hyperbole": 4,
+	"simile": 3, "sesame": 3, "coyote": 3, "adobe": 3, "anemone": 4,
+	"naive": 2, "cooperative": 5, "coexist": 3, "coincide": 3,
+	"coincidence": 4, "reenter": 3, "reelect": 3, "preexisting": 4,
+	"reuse": 2, "reused": 2, "preempt": 2, "nuclear": 3, "genuine": 3,
+	"mosaic": 3, "archaic": 3, "prosaic": 3, "deity": 3, "duo": 2, "aorta": 3,
+	"chaotic": 3, "oasis": 3, "hierarchy": 4,
+
+	// Silent ue
+	"fatigue": 2, "colleague": 2, "colleagues": 2, "league": 1, "vague": 1,
+	"tongue": 1, "plague": 1, "rogue": 1, "dialogue": 3, "catalogue": 3,
+
+	// Negative contractions, split at the apostrophe when words are extracted
+	"didn": 2, "doesn": 2, "isn": 2, "wasn": 2, "hasn": 2, "hadn": 2,
+	"couldn": 2, "wouldn": 2, "shouldn": 2, "mightn": 2, "mustn": 2,
+	"needn": 2, "weren": 1, "aren": 1,
+}
+
+// countSyllablesInWord counts syllables in a single word. English words
+// are looked up in syllableExceptions, then counted with English spelling
+// rules; words with other letters count vowel groups.
+func countSyllablesInWord(word string) int {
+	word = strings.ToLower(word)
+	if len(word) == 0 {
+		return 0
+	}
+	if count, ok := syllableExceptions[word]; ok {
+		return count
+	}
+	if isASCIIWord(word) {
+		return max(1, countEnglishSyllables(word))
+	}
+
+	count := 0
+	prevWasVowel := false
+
+	for _, char := range word {
+		isVowel := strings.ContainsRune(syllableVowels, char)
+		if isVowel && !prevWasVowel {
+			count++
+		}
+		prevWasVowel = isVowel
+	}
+
+	// Adjust for silent e
+	if strings.HasSuffix(word, "e") && count > 1 {
+		count--
+	}
+
+	if count == 0 {
+		count = 1
+	}
+
+	return count
+}
+
+// isASCIIWord reports whether word is spelled with ASCII characters only
+func isASCIIWord(word string) bool {
+	for i := 0; i < len(word); i++ {
+		if word[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// countEnglishSyllables counts the vowel groups of a lowercase ASCII word,
+// then corrects for silent endings and vowel pairs spoken as two syllables
+func countEnglishSyllables(word string) int {
+	n := len(word)
+	count := 0
+	prevWasVowel := false
+	for i := 0; i < n; i++ {
+		isVowel := isEnglishVowel(word, i)
+		if isVowel && !prevWasVowel {
+			count++
+		}
+		prevWasVowel = isVowel
+
+		// The y of "lying" and "studying" is a syllable of its own
+		if word[i] == 'y' && isVowel && i+1 < n && word[i+1] == 'i' {
+			prevWasVowel = false
+		}
+	}
+
+	// Silent endings: "make", "makes", "jumped", but not "table", "boxes"
+	// or "wanted"
+	switch {
+	case strings.HasSuffix(word, "e"):
+		if count > 1 && isEnglishConsonant(word, n-2) && !isConsonantLiquid(word, n-2) {
+			count--
+		}
+	case strings.HasSuffix(word, "es") && n > 3:
+		if count > 1 && isEnglishConsonant(word, n-3) && !isConsonantLiquid(word, n-3) && !isSibilantBefore(word, n-2) {
+			count--
+		}
+	case strings.HasSuffix(word, "ed") && n > 3:
+		if count > 1 && isEnglishConsonant(word, n-3) && !isConsonantLiquid(word, n-3) && word[n-3] != 't' && word[n-3] != 'd' {
+			count--
+		}
+	}
+	for _, suffix := range silentESuffixes {
+		if strings.HasSuffix(word, suffix) {
+			if i := n - len(suffix) - 1; count > 1 && isEnglishConsonant(word, i) && !isConsonantLiquid(word, i) {
+				count--
+			}
+			break
+		}
+	}
+
+	// Vowels read together but spoken apart: "being", "media", "radio",
+	// "stadium", "video", "usual", "quiet", "easier", "criticism"
+	if n > 4 && strings.HasSuffix(word, "ing") && isEnglishVowel(word, n-4) && word[n-4] != 'y' {
+		count++
+	}
+	for i := 1; i+1 < n; i++ {
+		if splitsVowels(word, i) {
+			count++
+		}
+	}
+	if strings.HasSuffix(word, "ier") || strings.HasSuffix(word, "iers") || strings.HasSuffix(word, "iest") {
+		count++
+	}
+	if strings.HasSuffix(word, "ism") || strings.HasSuffix(word, "isms") || strings.HasSuffix(word, "thm") || strings.HasSuffix(word, "thms") {
+		count++
+	}
+	return count
+}
+
+// splitsVowels reports whether the vowels at word[i] and word[i+1] belong
+// to separate syllables
+func splitsVowels(word string, i int) bool {
+	before := word[i-1]
+	switch word[i : i+2] {
+	case "ia":
+		// Not "social", "partial" or "russia"
+		return before != 'c' && before != 't' && before != 's'
+	case "io":
+		// Not "nation", "vision", "region", "fashion", "senior" or "million"
+		if strings.IndexByte("tscgxhn", before) >= 0 || (before == 'l' && i >= 2 && word[i-2] == 'l') {
+			return false
+		}
+		return true
+	case "iu":
+		return true
+	case "eo":
+		// Not "gorgeous" or "ocean"-like "ceous"
+		return before != 'g' && before != 'c'
+	case "ua":
+		// Not "quality" or "language"
+		return before != 'q' && before != 'g'
+	case "ie":
+		// "quiet", "science", "client"; not "ancient" or "patient"
+		if i+2 < len(word) && word[i+2] == 't' {
+			return true
+		}
+		if i+2 < len(word) && word[i+2] == 'n' {
+			return before != 't' && (before != 'c' || (i >= 2 && word[i-2] == 's'))
+		}
+	case "uo":
+		// "continuous", "virtuous"
+		return i+2 < len(word) && word[i+2] == 'u'
+	}
+	return false
+}
+
+// isEnglishVowel reports whether word[i] is spoken as a vowel. The u of
+// "qu" is not, nor y at the start of a word or before another vowel, as in
+// "yes" and "beyond", except before i after a consonant, as in "lying".
+func isEnglishVowel(word string, i int) bool {
+	switch word[i] {
+	case 'a', 'e', 'i', 'o':
+		return true
+	case 'u':
+		return i == 0 || word[i-1] != 'q'
+	case 'y':
+		if i == 0 {
+			return false
+		}
+		if i+1 < len(word) && strings.IndexByte("aeiou", word[i+1]) >= 0 {
+			return word[i+1] == 'i' && strings.IndexByte("aeiou", word[i-1]) < 0
+		}
+		return true
+	}
+	return false
+}
+
+// isEnglishConsonant reports whether word[i] is a letter spoken as a
+// consonant
+func isEnglishConsonant(word string, i int) bool {
+	return i >= 0 && i < len(word) && word[i] >= 'a' && word[i] <= 'z' && !isEnglishVowel(word, i)
+}
+
+// isConsonantLiquid reports whether word[i] is an l or r following another
+// consonant, so a silent-looking e after it is spoken: "table", "centre",
+// "handled", but not "called"
+func isConsonantLiquid(word string, i int) bool {
+	if i < 1 || (word[i] != 'l' && word[i] != 'r') {
+		return false
+	}
+	return isEnglishConsonant(word, i-1) && word[i-1] != word[i]
+}
+
+// isSibilantBefore reports whether the es ending at word[i:] follows a
+// sibilant, making it a syllable: "boxes", "wishes", "pages", "places"
+func isSibilantBefore(word string, i int) bool {
+	switch word[i-1] {
+	case 's', 'x', 'z', 'c', 'g':
+		return true
+	case 'h':
+		return i >= 2 && (word[i-2] == 'c' || word[i-2] == 's')
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/docutag/textanalyzer/internal/ollama"
+)
+
+// MaxSynopsisWords is the largest accepted synopsis word limit
+const MaxSynopsisWords = 500
+
+// SynopsisOptions controls the length and style of a generated synopsis
+type SynopsisOptions struct {
+	Style    string // teaser, standard or abstract (default: standard)
+	MaxWords int    // Maximum words in the synopsis, 0 for no limit
+}
+
+// ValidateSynopsisOptions checks that a synopsis style and word limit are supported
+func ValidateSynopsisOptions(opts SynopsisOptions) error {
+	switch opts.Style {
+	case "", ollama.SynopsisTeaser, ollama.SynopsisStandard, ollama.SynopsisAbstract:
+	default:
+		return fmt.Errorf("synopsis style must be one of %q, %q or %q",
+			ollama.SynopsisTeaser, ollama.SynopsisStandard, ollama.SynopsisAbstract)
+	}
+	if opts.MaxWords < 0 || opts.MaxWords > MaxSynopsisWords {
+		return fmt.Errorf("synopsis max words must be between 0 and %d, got %d", MaxSynopsisWords, opts.MaxWords)
+	}
+	return nil
+}
+
+// GenerateSynopsis summarizes text in the requested style, using Ollama when
+// available and falling back to an extractive synopsis otherwise
+func (a *Analyzer) GenerateSynopsis(ctx context.Context, text string, opts SynopsisOptions) string {
+	synopsis, _ := a.GenerateSynopsisWithStatus(ctx, text, opts)
+	return synopsis
+}
+
+// GenerateSynopsisWithStatus is GenerateSynopsis, also returning the step
+// status: StepStatusDone for a model synopsis or StepStatusFallback for an
+// extractive one
+func (a *Analyzer) GenerateSynopsisWithStatus(ctx context.Context, text string, opts SynopsisOptions) (string, string) {
+	if a.llmClient != nil {
+		synopsis, err := a.llmClient.GenerateSynopsis(ctx, text, opts.Style, opts.MaxWords)
+		if err == nil {
+			slog.Info("synopsis generated", "length", len(synopsis), "style", opts.Style)
+			return truncateWords(synopsis, opts.MaxWords), StepStatusDone
+		}
+		slog.Warn("synopsis generation failed, using extractive synopsis", "error", err)
+	}
+
+	return extractiveSynopsis(text, opts), StepStatusFallback
+}
+
+// extractiveSynopsis builds a synopsis from the leading sentences of the
+// text: one for a teaser, three for standard and five for an abstract
+func extractiveSynopsis(text string, opts SynopsisOptions) string {
+	count := synopsisSentenceCount(opts.Style)
+
+	var sentences []string
+	for _, para := range paragraphSpans(text) {
+		paragraph := text[para.start:para.end]
+		for _, span := range sentenceSpans(paragraph) {
+			sentences = append(sentences, strings.Join(strings.Fields(paragraph[span.start:span.end]), " "))
+			if len(sentences) == count {
+				break
+			}
+		}
+		if len(sentences) == count {
+			break
+		}
+	}
+
+	return truncateWords(strings.Join(sentences, " "), opts.MaxWords)
+}
+
+// synopsisSentenceCount returns how many sentences an extractive synopsis uses
+func synopsisSentenceCount(style string) int {
+	switch style {
+	case ollama.SynopsisTeaser:
+		return 1
+	case ollama.SynopsisAbstract:
+		return 5
+	}
+	return 3
+}
+
+// truncateWords limits text to maxWords words, marking a cut with an
+// ellipsis. A maxWords of 0 means no limit.
+func truncateWords(text string, maxWords int) string {
+	words := strings.Fields(text)
+	if maxWords <= 0 || len(words) <= maxWords {
+		return text
+	}
+	return strings.TrimRight(strings.Join(words[:maxWords], " "), ",;:") + "..."
+}
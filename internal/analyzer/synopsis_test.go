@@ -0,0 +1,156 @@
+package analyzer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/docutag/textanalyzer/internal/ollama"
+)
+
+const synopsisFixture = `The city council approved the new transit plan on Tuesday. It adds twelve bus routes across the eastern districts. Construction begins in the spring.
+
+Critics say the plan ignores cyclists. Supporters point to faster commutes. The first routes open next year. Funding comes from a regional levy.`
+
+// newFakeOllamaAnalyzer creates an analyzer backed by a fake Ollama server.
+// It records prompts and responds with response, or fails when status is not 200.
+func newFakeOllamaAnalyzer(t *testing.T, status int, response string) (*Analyzer, *[]string) {
+	t.Helper()
+
+	var prompts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Prompt string `json:"prompt"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		prompts = append(prompts, req.Prompt)
+
+		if status != http.StatusOK {
+			http.Error(w, `{"error":"model unavailable"}`, status)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"response": response, "done": true})
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := ollama.New(server.URL, "test-model")
+	if err != nil {
+		t.Fatalf("Failed to create Ollama client: %v", err)
+	}
+	return NewWithOllama(client), &prompts
+}
+
+func TestExtractiveSynopsisSentenceCounts(t *testing.T) {
+	tests := []struct {
+		style     string
+		sentences int
+	}{
+		{ollama.SynopsisTeaser, 1},
+		{ollama.SynopsisStandard, 3},
+		{"", 3},
+		{ollama.SynopsisAbstract, 5},
+	}
+
+	for _, tt := range tests {
+		synopsis := extractiveSynopsis(synopsisFixture, SynopsisOptions{Style: tt.style})
+		if got := len(sentenceSpans(synopsis)); got != tt.sentences {
+			t.Errorf("Style %q: expected %d sentences, got %d: %q", tt.style, tt.sentences, got, synopsis)
+		}
+		if !strings.HasPrefix(synopsis, "The city council approved") {
+			t.Errorf("Style %q: expected synopsis to start with the first sentence, got %q", tt.style, synopsis)
+		}
+	}
+}
+
+func TestExtractiveSynopsisMaxWords(t *testing.T) {
+	for _, maxWords := range []int{1, 5, 12, 30} {
+		synopsis := extractiveSynopsis(synopsisFixture, SynopsisOptions{Style: ollama.SynopsisAbstract, MaxWords: maxWords})
+		if got := len(strings.Fields(synopsis)); got > maxWords {
+			t.Errorf("MaxWords %d: synopsis has %d words: %q", maxWords, got, synopsis)
+		}
+	}
+
+	// A limit longer than the synopsis leaves it untouched
+	full := extractiveSynopsis(synopsisFixture, SynopsisOptions{Style: ollama.SynopsisTeaser})
+	if got := extractiveSynopsis(synopsisFixture, SynopsisOptions{Style: ollama.SynopsisTeaser, MaxWords: 100}); got != full {
+		t.Errorf("Expected %q, got %q", full, got)
+	}
+	if strings.HasSuffix(full, "...") {
+		t.Errorf("Untruncated synopsis should not end with an ellipsis: %q", full)
+	}
+
+	truncated := extractiveSynopsis(synopsisFixture, SynopsisOptions{Style: ollama.SynopsisTeaser, MaxWords: 4})
+	if truncated != "The city council approved..." {
+		t.Errorf("Expected truncated teaser, got %q", truncated)
+	}
+}
+
+func TestGenerateSynopsisUsesStyle(t *testing.T) {
+	a, prompts := newFakeOllamaAnalyzer(t, http.StatusOK, "Council adds twelve eastern bus routes.")
+	ctx := context.Background()
+
+	teaser := a.GenerateSynopsis(ctx, synopsisFixture, SynopsisOptions{Style: ollama.SynopsisTeaser})
+	if teaser != "Council adds twelve eastern bus routes." {
+		t.Errorf("Expected Ollama synopsis, got %q", teaser)
+	}
+	abstract := a.GenerateSynopsis(ctx, synopsisFixture, SynopsisOptions{Style: ollama.SynopsisAbstract, MaxWords: 3})
+	if abstract != "Council adds twelve..." {
+		t.Errorf("Expected Ollama synopsis cut to 3 words, got %q", abstract)
+	}
+
+	if len(*prompts) != 2 {
+		t.Fatalf("Expected 2 prompts, got %d", len(*prompts))
+	}
+	if (*prompts)[0] == (*prompts)[1] {
+		t.Error("Expected teaser and abstract prompts to differ")
+	}
+	if !strings.Contains((*prompts)[1], "no more than 3 words") {
+		t.Error("Expected abstract prompt to carry the word limit")
+	}
+}
+
+func TestGenerateSynopsisFallback(t *testing.T) {
+	a, prompts := newFakeOllamaAnalyzer(t, http.StatusInternalServerError, "")
+
+	synopsis := a.GenerateSynopsis(context.Background(), synopsisFixture, SynopsisOptions{Style: ollama.SynopsisTeaser, MaxWords: 6})
+	if len(*prompts) == 0 {
+		t.Error("Expected Ollama to be tried first")
+	}
+	if synopsis != "The city council approved the new..." {
+		t.Errorf("Expected extractive teaser fallback, got %q", synopsis)
+	}
+
+	// Without Ollama the extractive synopsis is used directly
+	if got := New().GenerateSynopsis(context.Background(), synopsisFixture, SynopsisOptions{Style: ollama.SynopsisTeaser}); got != "The city council approved the new transit plan on Tuesday." {
+		t.Errorf("Unexpected offline synopsis %q", got)
+	}
+}
+
+func TestValidateSynopsisOptions(t *testing.T) {
+	valid := []SynopsisOptions{
+		{},
+		{Style: ollama.SynopsisTeaser},
+		{Style: ollama.SynopsisStandard, MaxWords: 50},
+		{Style: ollama.SynopsisAbstract, MaxWords: MaxSynopsisWords},
+	}
+	for _, opts := range valid {
+		if err := ValidateSynopsisOptions(opts); err != nil {
+			t.Errorf("Expected %+v to be valid, got %v", opts, err)
+		}
+	}
+
+	invalid := []SynopsisOptions{
+		{Style: "haiku"},
+		{Style: "Teaser"},
+		{MaxWords: -1},
+		{MaxWords: MaxSynopsisWords + 1},
+	}
+	for _, opts := range invalid {
+		if err := ValidateSynopsisOptions(opts); err == nil {
+			t.Errorf("Expected %+v to be rejected", opts)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package analyzer
 
 import (
 	"testing"
+
+	"github.com/docutag/textanalyzer/internal/tags"
 )
 
 /**
@@ -101,12 +103,12 @@ func TestTagMerge_NormalizationConsistency(t *testing.T) {
 			tagSet := make(map[string]bool)
 
 			for _, tag := range tc.computedTags {
-				normalized := normalizeTag(tag)
+				normalized := tags.Normalize(tag)
 				tagSet[normalized] = true
 			}
 
 			for _, tag := range tc.aiTags {
-				normalized := normalizeTag(tag)
+				normalized := tags.Normalize(tag)
 				tagSet[normalized] = true
 			}
 
@@ -186,9 +186,9 @@ func TestTagMerging_ReadabilityTagIncluded(t *testing.T) {
 	metadata := analyzer.Analyze(text)
 
 	// Readability level should be included as a tag
-	// Could be: "very-easy", "fairly-easy", "easy", "fairly-difficult", "difficult", "very-difficult"
+	// Could be: "very-easy", "fairly-easy", "easy", "standard", "fairly-difficult", "difficult", "very-difficult"
 	hasReadability := false
-	readabilityTags := []string{"very-easy", "fairly-easy", "easy", "fairly-difficult", "difficult", "very-difficult"}
+	readabilityTags := []string{"very-easy", "fairly-easy", "easy", "standard", "fairly-difficult", "difficult", "very-difficult"}
 
 	for _, tag := range metadata.Tags {
 		for _, readabilityTag := range readabilityTags {
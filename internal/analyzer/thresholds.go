@@ -0,0 +1,121 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/docutag/textanalyzer/internal/models"
+)
+
+// DefaultEnrichmentThreshold is the minimum quality score a document needs
+// before it is sent for AI enrichment when no other threshold applies
+const DefaultEnrichmentThreshold = 0.35
+
+// EnrichmentThresholds maps content sources to the minimum quality score
+// required for AI enrichment. A nil *EnrichmentThresholds resolves every
+// source to DefaultEnrichmentThreshold.
+type EnrichmentThresholds struct {
+	Default float64
+	Sources map[string]float64
+}
+
+// NewEnrichmentThresholds creates a threshold table with the given default and
+// per-source overrides. Source labels are matched case-insensitively.
+func NewEnrichmentThresholds(defaultThreshold float64, sources map[string]float64) *EnrichmentThresholds {
+	normalized := make(map[string]float64, len(sources))
+	for source, threshold := range sources {
+		normalized[normalizeSource(source)] = threshold
+	}
+	return &EnrichmentThresholds{
+		Default: defaultThreshold,
+		Sources: normalized,
+	}
+}
+
+// Resolve returns the effective threshold for a document. An explicit
+// override wins, then the threshold configured for the source, then the default.
+func (t *EnrichmentThresholds) Resolve(source string, override *float64) float64 {
+	if override != nil {
+		return *override
+	}
+	if t == nil {
+		return DefaultEnrichmentThreshold
+	}
+	if threshold, ok := t.Sources[normalizeSource(source)]; ok {
+		return threshold
+	}
+	return t.Default
+}
+
+// PassesEnrichmentGate reports whether a document with the given quality
+// score, nil when unscored, is sent for AI enrichment under threshold. A
+// forced enrichment passes whatever the score.
+func PassesEnrichmentGate(score *models.TextQualityScore, threshold float64, force bool) bool {
+	return force || (score != nil && score.Score >= threshold)
+}
+
+// ValidateThreshold returns an error if threshold is outside the 0-1 quality score range
+func ValidateThreshold(threshold float64) error {
+	if threshold < 0 || threshold > 1 {
+		return fmt.Errorf("threshold must be between 0 and 1, got %v", threshold)
+	}
+	return nil
+}
+
+// ParseSourceThresholds parses comma-separated source=threshold pairs,
+// e.g. "memo=0,forum=0.5"
+func ParseSourceThresholds(spec string) (map[string]float64, error) {
+	thresholds := make(map[string]float64)
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		source, value, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(source) == "" {
+			return nil, fmt.Errorf("invalid source threshold %q: expected source=threshold", pair)
+		}
+
+		threshold, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid threshold for source %q: %w", source, err)
+		}
+		if err := ValidateThreshold(threshold); err != nil {
+			return nil, fmt.Errorf("invalid threshold for source %q: %w", source, err)
+		}
+
+		thresholds[normalizeSource(source)] = threshold
+	}
+	return thresholds, nil
+}
+
+// LoadSourceThresholds reads a JSON object mapping source labels to thresholds
+func LoadSourceThresholds(path string) (map[string]float64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read source thresholds file: %w", err)
+	}
+
+	var raw map[string]float64
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse source thresholds file: %w", err)
+	}
+
+	thresholds := make(map[string]float64, len(raw))
+	for source, threshold := range raw {
+		if err := ValidateThreshold(threshold); err != nil {
+			return nil, fmt.Errorf("invalid threshold for source %q: %w", source, err)
+		}
+		thresholds[normalizeSource(source)] = threshold
+	}
+	return thresholds, nil
+}
+
+// normalizeSource canonicalizes a source label for lookup
+func normalizeSource(source string) string {
+	return strings.ToLower(strings.TrimSpace(source))
+}
@@ -0,0 +1,131 @@
+package analyzer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docutag/textanalyzer/internal/models"
+)
+
+func TestEnrichmentThresholdsResolve(t *testing.T) {
+	thresholds := NewEnrichmentThresholds(0.4, map[string]float64{
+		"Memo":  0,
+		"forum": 0.5,
+	})
+	override := 0.1
+
+	tests := []struct {
+		name     string
+		source   string
+		override *float64
+		expected float64
+	}{
+		{"default fallback", "", nil, 0.4},
+		{"unknown source", "blog", nil, 0.4},
+		{"source mapping", "forum", nil, 0.5},
+		{"source mapping is case-insensitive", " MEMO ", nil, 0},
+		{"override beats source", "forum", &override, 0.1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := thresholds.Resolve(tt.source, tt.override); got != tt.expected {
+				t.Errorf("Resolve(%q) = %v, want %v", tt.source, got, tt.expected)
+			}
+		})
+	}
+
+	var unconfigured *EnrichmentThresholds
+	if got := unconfigured.Resolve("forum", nil); got != DefaultEnrichmentThreshold {
+		t.Errorf("nil thresholds should resolve to default, got %v", got)
+	}
+	if got := unconfigured.Resolve("forum", &override); got != override {
+		t.Errorf("nil thresholds should honor override, got %v", got)
+	}
+}
+
+func TestPassesEnrichmentGate(t *testing.T) {
+	score := func(s float64) *models.TextQualityScore { return &models.TextQualityScore{Score: s} }
+
+	tests := []struct {
+		name     string
+		score    *models.TextQualityScore
+		force    bool
+		expected bool
+	}{
+		{"at the threshold", score(0.35), false, true},
+		{"below the threshold", score(0.34), false, false},
+		{"unscored", nil, false, false},
+		{"forced below the threshold", score(0.1), true, true},
+		{"forced unscored", nil, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PassesEnrichmentGate(tt.score, 0.35, tt.force); got != tt.expected {
+				t.Errorf("PassesEnrichmentGate() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+
+	// The early quality check is the same gate: forcing runs the model on
+	// text scoring below the threshold
+	a := NewWithOllama(&mockLLM{})
+	threshold := 1.0
+	skipped := a.AnalyzeWithOptions(context.Background(), synopsisFixture, AnalysisOptions{Threshold: threshold})
+	if skipped.Synopsis == mockSynopsis || skipped.EnrichmentStatus[StepSynopsis] != StepStatusSkippedLowQuality {
+		t.Errorf("Expected enrichment to be skipped below the threshold, got %v", skipped.EnrichmentStatus)
+	}
+	forced := a.AnalyzeWithOptions(context.Background(), synopsisFixture, RecordedOptions(models.Metadata{
+		EnrichmentThreshold: &threshold,
+		ForceEnrichment:     true,
+	}))
+	if forced.Synopsis != mockSynopsis {
+		t.Errorf("Expected forced enrichment to run the model, got synopsis %q", forced.Synopsis)
+	}
+}
+
+func TestParseSourceThresholds(t *testing.T) {
+	thresholds, err := ParseSourceThresholds("memo=0, Forum=0.5,,")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(thresholds) != 2 || thresholds["memo"] != 0 || thresholds["forum"] != 0.5 {
+		t.Errorf("Unexpected thresholds: %v", thresholds)
+	}
+
+	for _, spec := range []string{"memo", "=0.5", "memo=abc", "memo=1.5", "memo=-1"} {
+		if _, err := ParseSourceThresholds(spec); err == nil {
+			t.Errorf("Expected error for spec %q", spec)
+		}
+	}
+}
+
+func TestLoadSourceThresholds(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "thresholds.json")
+	if err := os.WriteFile(path, []byte(`{"Memo": 0, "forum": 0.5}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	thresholds, err := LoadSourceThresholds(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if thresholds["memo"] != 0 || thresholds["forum"] != 0.5 {
+		t.Errorf("Unexpected thresholds: %v", thresholds)
+	}
+
+	invalid := filepath.Join(dir, "invalid.json")
+	if err := os.WriteFile(invalid, []byte(`{"forum": 2}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadSourceThresholds(invalid); err == nil {
+		t.Error("Expected error for out-of-range threshold")
+	}
+
+	if _, err := LoadSourceThresholds(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("Expected error for missing file")
+	}
+}
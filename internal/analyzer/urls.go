@@ -0,0 +1,130 @@
+package analyzer
+
+import (
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// SetStripURLTracking sets whether tracking parameters, such as utm_source
+// and fbclid, are dropped from the URLs extracted from text
+func (a *Analyzer) SetStripURLTracking(strip bool) {
+	a.stripURLTracking = strip
+}
+
+// urlPattern matches http and https URLs and URLs starting with "www."
+// without a scheme. Whitespace, angle brackets, quotes and backticks end a
+// URL; other punctuation is trimmed by cleanURL.
+var urlPattern = regexp.MustCompile("(?i)(?:https?://|www\\.)[^\\s<>\"`]+")
+
+// urlTrailers are characters that end sentences or wrap URLs in prose and
+// Markdown rather than ending the URLs themselves
+const urlTrailers = ".,;:!?'\"*_"
+
+// extractURLs extracts the URLs in text, cleaned by cleanURL, deduplicated
+// and in alphabetical order. Tracking parameters are dropped when
+// stripTracking is set.
+func extractURLs(text string, stripTracking bool) []string {
+	unique := make(map[string]bool)
+	for _, span := range urlPattern.FindAllStringIndex(text, -1) {
+		if span[0] > 0 && urlContinues(text[span[0]-1]) {
+			// Inside a word, or the "www." of an email address or of a
+			// longer host name
+			continue
+		}
+		if cleaned, ok := cleanURL(text[span[0]:span[1]], stripTracking); ok {
+			unique[cleaned] = true
+		}
+	}
+
+	result := []string{}
+	for url := range unique {
+		result = append(result, url)
+	}
+
+	sort.Strings(result)
+	return result
+}
+
+// urlContinues reports whether a URL match preceded by c is part of a longer
+// word, email address or host name
+func urlContinues(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte("@./-", c) >= 0
+}
+
+// cleanURL trims the punctuation and Markdown around a matched URL, keeping
+// closing parentheses and brackets that balance opening ones in the URL, as
+// in https://en.wikipedia.org/wiki/Go_(programming_language). URLs without a
+// scheme get "http://", and the scheme and host are lowercased. It reports
+// false when no URL with a host is left, or when a URL without a scheme is
+// only "www.".
+func cleanURL(match string, stripTracking bool) (string, bool) {
+	raw := trimURLTrailers(match)
+	bare := !strings.Contains(raw, "://")
+	if bare {
+		raw = "http://" + raw
+	}
+	schemeEnd := strings.Index(raw, "://") + len("://")
+	hostEnd := len(raw)
+	if i := strings.IndexAny(raw[schemeEnd:], "/?#"); i >= 0 {
+		hostEnd = schemeEnd + i
+	}
+	raw = strings.ToLower(raw[:hostEnd]) + raw[hostEnd:]
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Hostname() == "" || (bare && !strings.Contains(strings.TrimSuffix(u.Hostname(), "."), ".")) {
+		return "", false
+	}
+	if stripTracking && u.RawQuery != "" {
+		raw = stripTrackingParams(raw)
+	}
+	return raw, true
+}
+
+// trimURLTrailers trims urlTrailers and unbalanced closing parentheses and
+// brackets from the end of raw
+func trimURLTrailers(raw string) string {
+	for raw != "" {
+		last := raw[len(raw)-1]
+		switch {
+		case strings.IndexByte(urlTrailers, last) >= 0:
+		case last == ')' && strings.Count(raw, "(") < strings.Count(raw, ")"):
+		case last == ']' && strings.Count(raw, "[") < strings.Count(raw, "]"):
+		default:
+			return raw
+		}
+		raw = raw[:len(raw)-1]
+	}
+	return raw
+}
+
+// stripTrackingParams drops tracking parameters from the query of rawURL,
+// leaving the other parameters as written and in order
+func stripTrackingParams(rawURL string) string {
+	queryStart := strings.IndexByte(rawURL, '?')
+	queryEnd := len(rawURL)
+	if i := strings.IndexByte(rawURL, '#'); i >= 0 {
+		if i < queryStart {
+			return rawURL
+		}
+		queryEnd = i
+	}
+
+	var kept []string
+	for _, param := range strings.Split(rawURL[queryStart+1:queryEnd], "&") {
+		name, _, _ := strings.Cut(param, "=")
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if param != "" && !isTrackingParam(name) {
+			kept = append(kept, param)
+		}
+	}
+
+	query := ""
+	if len(kept) > 0 {
+		query = "?" + strings.Join(kept, "&")
+	}
+	return rawURL[:queryStart] + query + rawURL[queryEnd:]
+}
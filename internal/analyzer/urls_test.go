@@ -0,0 +1,105 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractURLsCleaning(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		// Sentence punctuation
+		{"full stop", "See https://example.com/docs.", []string{"https://example.com/docs"}},
+		{"comma", "Visit https://example.com, then leave.", []string{"https://example.com"}},
+		{"several", "Really? https://example.com/faq?!", []string{"https://example.com/faq"}},
+		{"semicolon and colon", "One: https://a.example; two: https://b.example:", []string{"https://a.example", "https://b.example"}},
+		{"quotes", `He said "https://example.com/a" and 'https://example.com/b'.`, []string{"https://example.com/a", "https://example.com/b"}},
+
+		// Parentheses and brackets
+		{"parenthesized", "The docs (https://example.com/docs) explain it.", []string{"https://example.com/docs"}},
+		{"parenthesized with stop", "(see https://example.com/docs).", []string{"https://example.com/docs"}},
+		{"bracketed", "[https://example.com/docs]", []string{"https://example.com/docs"}},
+		{"wikipedia", "Read https://en.wikipedia.org/wiki/Go_(programming_language) first.",
+			[]string{"https://en.wikipedia.org/wiki/Go_(programming_language)"}},
+		{"wikipedia with stop", "Read https://en.wikipedia.org/wiki/Go_(programming_language).",
+			[]string{"https://en.wikipedia.org/wiki/Go_(programming_language)"}},
+		{"wikipedia parenthesized", "Go (https://en.wikipedia.org/wiki/Go_(programming_language)) is small.",
+			[]string{"https://en.wikipedia.org/wiki/Go_(programming_language)"}},
+
+		// Markup
+		{"angle brackets", "Mail <https://example.com/inbox> to us", []string{"https://example.com/inbox"}},
+		{"markdown link", "A [guide](https://example.com/guide) and [more](https://example.com/more).",
+			[]string{"https://example.com/guide", "https://example.com/more"}},
+		{"markdown bold", "**https://example.com/bold**", []string{"https://example.com/bold"}},
+		{"markdown italic", "_https://example.com/italic_", []string{"https://example.com/italic"}},
+		{"markdown code", "Run `https://example.com/code` now", []string{"https://example.com/code"}},
+		{"html attribute", `<a href="https://example.com/page">page</a>`, []string{"https://example.com/page"}},
+
+		// Scheme and host are lowercased, the rest kept as written
+		{"case", "HTTPS://Example.COM/Path?Q=A", []string{"https://example.com/Path?Q=A"}},
+		{"case without path", "Http://WWW.Example.com", []string{"http://www.example.com"}},
+		{"port", "http://localhost:8080/health", []string{"http://localhost:8080/health"}},
+
+		// www. without a scheme
+		{"www", "Go to www.example.com/start.", []string{"http://www.example.com/start"}},
+		{"www uppercase", "Go to WWW.Example.com", []string{"http://www.example.com"}},
+		{"www with scheme", "https://www.example.com", []string{"https://www.example.com"}},
+		{"www in email", "Write to info@www.example.com", []string{}},
+		{"www alone", "The www. prefix", []string{}},
+
+		// Duplicates after cleaning
+		{"duplicates", "https://example.com, https://EXAMPLE.com. (https://example.com)", []string{"https://example.com"}},
+		{"none", "No links here.", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := extractURLs(tt.text, false); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("extractURLs(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractURLsTracking(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://example.com/a?utm_source=news&utm_medium=email", "https://example.com/a"},
+		{"https://example.com/a?id=7&UTM_Campaign=spring&ref=home", "https://example.com/a?id=7&ref=home"},
+		{"https://example.com/a?fbclid=abc#top", "https://example.com/a#top"},
+		{"https://example.com/a?b=2&a=1&gclid=x", "https://example.com/a?b=2&a=1"},
+		{"https://example.com/a?q=go%20lang", "https://example.com/a?q=go%20lang"},
+		{"https://example.com/a#utm_source=x", "https://example.com/a#utm_source=x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			if got := extractURLs(tt.url, true); !reflect.DeepEqual(got, []string{tt.want}) {
+				t.Errorf("Expected %s, got %v", tt.want, got)
+			}
+			// Kept unless stripping is enabled
+			if got := extractURLs(tt.url, false); !reflect.DeepEqual(got, []string{tt.url}) {
+				t.Errorf("Expected %s, got %v", tt.url, got)
+			}
+		})
+	}
+}
+
+func TestStripURLTracking(t *testing.T) {
+	a := New()
+	text := "Details at https://example.com/post?utm_source=feed."
+
+	if got := a.AnalyzeOffline(text).PotentialURLs; !reflect.DeepEqual(got, []string{"https://example.com/post?utm_source=feed"}) {
+		t.Errorf("Expected tracking parameters kept by default, got %v", got)
+	}
+
+	a.SetStripURLTracking(true)
+	if got := a.AnalyzeOffline(text).PotentialURLs; !reflect.DeepEqual(got, []string{"https://example.com/post"}) {
+		t.Errorf("Expected tracking parameters dropped, got %v", got)
+	}
+}
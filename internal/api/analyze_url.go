@@ -0,0 +1,101 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/docutag/textanalyzer/internal/analyzer"
+	"github.com/docutag/textanalyzer/internal/queue"
+)
+
+// PageFetcher fetches the pages submitted to POST /api/analyze/url
+type PageFetcher interface {
+	Fetch(ctx context.Context, rawURL string) (*analyzer.Page, error)
+}
+
+// analyzeURLRequest is the body of POST /api/analyze/url: the page to fetch
+// and the options of POST /api/analyze. The text, original HTML and images
+// are taken from the page.
+type analyzeURLRequest struct {
+	URL string `json:"url"`
+	analyzeRequest
+}
+
+// handleAnalyzeURL fetches a page and queues its text for analysis like
+// POST /api/analyze, recording the page as the analysis source
+func (h *Handler) handleAnalyzeURL(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.pageFetcher == nil {
+		respondError(w, "Page fetching is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxBody)
+
+	var req analyzeURLRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		reqErr := bodyError(err)
+		respondError(w, reqErr.message, reqErr.status)
+		return
+	}
+	if strings.TrimSpace(req.URL) == "" {
+		respondError(w, "URL field is required", http.StatusBadRequest)
+		return
+	}
+	if req.Text != "" || req.OriginalHTML != "" || len(req.Images) > 0 || req.SourceURL != "" || req.FetchedURL != "" {
+		respondError(w, "text, original_html, images, source_url and fetched_url are taken from the fetched page", http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.pageFetcher.Fetch(r.Context(), req.URL)
+	if err != nil {
+		reqErr := fetchError(err)
+		respondError(w, reqErr.message, reqErr.status)
+		return
+	}
+	if strings.TrimSpace(page.Text) == "" {
+		respondError(w, "Fetched page has no text to analyze", http.StatusUnprocessableEntity)
+		return
+	}
+
+	req.Text = page.Text
+	req.SourceURL = req.URL
+	req.FetchedURL = page.URL
+	if req.OriginalHTML, err = queue.CompressHTML(page.HTML); err != nil {
+		respondError(w, "Failed to compress HTML: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// A page may embed any number of images; keep the first ones within the
+	// limit rather than rejecting the page
+	images := analyzer.SelectImageURLs(page.Images, h.maxImages)
+	req.Images = images.Accepted
+	var warnings []string
+	if images.Overflow > 0 {
+		unique := len(images.Accepted) + images.Overflow
+		warnings = append(warnings, fmt.Sprintf("Only the first %d of %d unique images will be processed", h.maxImages, unique))
+	}
+
+	h.submitAnalysis(w, r, &req.analyzeRequest, warnings...)
+}
+
+// fetchError maps a failed page fetch to the status it is responded to with
+func fetchError(err error) *requestError {
+	switch {
+	case errors.Is(err, analyzer.ErrPageURL):
+		return badRequest("Invalid url: " + err.Error())
+	case errors.Is(err, analyzer.ErrPageTooLarge):
+		return &requestError{message: "Fetched page is too large: " + err.Error(), status: http.StatusRequestEntityTooLarge}
+	case errors.Is(err, analyzer.ErrPageContentType):
+		return &requestError{message: "Fetched page is not HTML or plain text: " + err.Error(), status: http.StatusUnprocessableEntity}
+	default:
+		return &requestError{message: "Failed to fetch page: " + err.Error(), status: http.StatusBadGateway}
+	}
+}
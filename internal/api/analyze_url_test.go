@@ -0,0 +1,146 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/docutag/textanalyzer/internal/analyzer"
+	"github.com/docutag/textanalyzer/internal/queue"
+)
+
+// fakePageFetcher serves pages from memory, keyed by URL
+type fakePageFetcher struct {
+	pages map[string]*analyzer.Page
+	err   error
+}
+
+func (f *fakePageFetcher) Fetch(ctx context.Context, rawURL string) (*analyzer.Page, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	page, ok := f.pages[rawURL]
+	if !ok {
+		return nil, fmt.Errorf("failed to fetch %s: status 404", rawURL)
+	}
+	return page, nil
+}
+
+func postAnalyzeURL(handler *Handler, body string) (*httptest.ResponseRecorder, map[string]interface{}) {
+	req := httptest.NewRequest(http.MethodPost, "/api/analyze/url", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return w, response
+}
+
+func TestAnalyzeURL(t *testing.T) {
+	html := `<html><body><p>Fetched article text.</p><img src="https://example.com/a.png"></body></html>`
+	handler := setupStatelessHandler()
+	handler.pageFetcher = &fakePageFetcher{pages: map[string]*analyzer.Page{
+		"http://example.com/article?utm_source=feed": {
+			URL:    "https://www.example.com/article",
+			HTML:   html,
+			Text:   "Fetched article text.",
+			Images: []string{"https://example.com/a.png", "https://example.com/a.png"},
+		},
+	}}
+	mockQueue := handler.queueClient.(*mockQueueClient)
+
+	w, response := postAnalyzeURL(handler, `{"url": "http://example.com/article?utm_source=feed", "priority": "high"}`)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %s", w.Code, w.Body.String())
+	}
+	if response["job_id"] == nil || response["status"] != "queued" || response["priority"] != "high" {
+		t.Errorf("Expected the /api/analyze response shape, got %v", response)
+	}
+	// A trivial redirect resolves to the fetched URL
+	if response["source_url"] != "https://www.example.com/article" || mockQueue.lastOptions.SourceURL != "https://www.example.com/article" {
+		t.Errorf("Expected the fetched URL stored as the source, got %v and %q", response["source_url"], mockQueue.lastOptions.SourceURL)
+	}
+	if mockQueue.lastText != "Fetched article text." {
+		t.Errorf("Expected the page text enqueued, got %q", mockQueue.lastText)
+	}
+	if len(mockQueue.lastImages) != 1 || mockQueue.lastImages[0] != "https://example.com/a.png" {
+		t.Errorf("Expected the unique page images enqueued, got %v", mockQueue.lastImages)
+	}
+	if size, err := queue.DecompressedHTMLSize(mockQueue.lastHTML, 1<<20); err != nil || size != int64(len(html)) {
+		t.Errorf("Expected the page HTML compressed into original_html, got size %d, %v", size, err)
+	}
+}
+
+func TestAnalyzeURLImageLimit(t *testing.T) {
+	handler := setupStatelessHandler()
+	handler.maxImages = 2
+	handler.pageFetcher = &fakePageFetcher{pages: map[string]*analyzer.Page{
+		"https://example.com/gallery": {
+			URL:    "https://example.com/gallery",
+			Text:   "Gallery text.",
+			Images: []string{"https://example.com/1.png", "https://example.com/2.png", "https://example.com/3.png"},
+		},
+	}}
+
+	w, response := postAnalyzeURL(handler, `{"url": "https://example.com/gallery"}`)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %s", w.Code, w.Body.String())
+	}
+	warnings, _ := response["warnings"].([]interface{})
+	if response["images_accepted"] != float64(2) || len(warnings) != 1 {
+		t.Errorf("Expected the first 2 images accepted with a warning, got %v", response)
+	}
+}
+
+func TestAnalyzeURLRejected(t *testing.T) {
+	handler := setupStatelessHandler()
+	fetcher := &fakePageFetcher{pages: map[string]*analyzer.Page{
+		"https://example.com/empty": {URL: "https://example.com/empty", Text: "  "},
+	}}
+	handler.pageFetcher = fetcher
+	mockQueue := handler.queueClient.(*mockQueueClient)
+
+	tests := []struct {
+		name     string
+		body     string
+		err      error
+		expected int
+	}{
+		{"missing url", `{}`, nil, http.StatusBadRequest},
+		{"text given", `{"url": "https://example.com/empty", "text": "Other text"}`, nil, http.StatusBadRequest},
+		{"invalid json", `{"url":`, nil, http.StatusBadRequest},
+		{"blocked address", `{"url": "http://127.0.0.1/"}`, fmt.Errorf("%w: 127.0.0.1 is not a public address", analyzer.ErrPageURL), http.StatusBadRequest},
+		{"too large", `{"url": "https://example.com/big"}`, fmt.Errorf("%w: maximum is 5 bytes", analyzer.ErrPageTooLarge), http.StatusRequestEntityTooLarge},
+		{"not html", `{"url": "https://example.com/a.pdf"}`, fmt.Errorf("%w: application/pdf", analyzer.ErrPageContentType), http.StatusUnprocessableEntity},
+		{"fetch failed", `{"url": "https://example.com/missing"}`, nil, http.StatusBadGateway},
+		{"no text", `{"url": "https://example.com/empty"}`, nil, http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockQueue.enqueued = 0
+			fetcher.err = tt.err
+
+			w, response := postAnalyzeURL(handler, tt.body)
+			if w.Code != tt.expected {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expected, w.Code, w.Body.String())
+			}
+			if response["error"] == nil {
+				t.Errorf("Expected a JSON error, got %q", w.Body.String())
+			}
+			if mockQueue.enqueued != 0 {
+				t.Errorf("Expected nothing enqueued, got %d tasks", mockQueue.enqueued)
+			}
+		})
+	}
+
+	handler.pageFetcher = nil
+	if w, _ := postAnalyzeURL(handler, `{"url": "https://example.com/empty"}`); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 without a page fetcher, got %d", w.Code)
+	}
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/docutag/textanalyzer/internal/queue"
+)
+
+// Back-pressure modes
+const (
+	BackPressureOff      = "off"      // Accept every submission regardless of queue depth
+	BackPressureStrict   = "strict"   // Reject submissions with 503 while a queue is saturated
+	BackPressureDegraded = "degraded" // Accept submissions offline-only while text enrichment is saturated
+)
+
+// defaultRetryAfter is the Retry-After sent with back-pressure rejections
+const defaultRetryAfter = 30 * time.Second
+
+// Back-pressure decisions, as logged and counted
+const (
+	decisionAccepted = "accepted"
+	decisionDegraded = "degraded"
+	decisionRejected = "rejected"
+)
+
+// QueueDepthProvider reports the cached number of tasks waiting in a
+// processing stage, and false when the depth is not known yet
+type QueueDepthProvider interface {
+	Pending(stage string) (int, bool)
+}
+
+// BackPressureConfig limits new submissions while the queues are backed up
+type BackPressureConfig struct {
+	// Mode is off, strict or degraded (default: off)
+	Mode string
+
+	// MaxPendingEnrichment is the most text enrichment tasks that may be
+	// waiting before submissions are rejected (strict) or accepted without
+	// AI enrichment (degraded). Zero disables the limit.
+	MaxPendingEnrichment int
+
+	// MaxPendingOffline is the most offline processing tasks that may be
+	// waiting before submissions are rejected in either mode, since offline
+	// processing cannot be skipped. Zero disables the limit.
+	MaxPendingOffline int
+
+	// RetryAfter is sent with rejections (default: 30s)
+	RetryAfter time.Duration
+}
+
+// ParseBackPressureMode validates a back-pressure mode, defaulting to off when empty
+func ParseBackPressureMode(mode string) (string, error) {
+	switch mode {
+	case "":
+		return BackPressureOff, nil
+	case BackPressureOff, BackPressureStrict, BackPressureDegraded:
+		return mode, nil
+	}
+	return "", fmt.Errorf("back-pressure mode must be one of %q, %q or %q", BackPressureOff, BackPressureStrict, BackPressureDegraded)
+}
+
+// admission is the back-pressure decision for a submission
+type admission struct {
+	decision string
+	stage    string // The saturated stage, empty when accepted
+	pending  int    // Tasks waiting in the saturated stage
+}
+
+// admit decides whether a submission is accepted, accepted offline-only or
+// rejected, logging and counting every decision when back-pressure is on.
+// Unknown queue depths never block submissions.
+func (h *Handler) admit() admission {
+	cfg := h.backPressure
+	if cfg.Mode == BackPressureOff || h.queueDepth == nil {
+		return admission{decision: decisionAccepted}
+	}
+
+	result := admission{decision: decisionAccepted}
+	if pending, ok := h.queueDepth.Pending(queue.StageOfflineProcessing); ok && cfg.MaxPendingOffline > 0 && pending > cfg.MaxPendingOffline {
+		result = admission{decision: decisionRejected, stage: queue.StageOfflineProcessing, pending: pending}
+	} else if pending, ok := h.queueDepth.Pending(queue.StageTextEnrichment); ok && cfg.MaxPendingEnrichment > 0 && pending > cfg.MaxPendingEnrichment {
+		result = admission{decision: decisionRejected, stage: queue.StageTextEnrichment, pending: pending}
+		if cfg.Mode == BackPressureDegraded {
+			result.decision = decisionDegraded
+		}
+	}
+
+	h.metrics.BackPressureDecisions.WithLabelValues(cfg.Mode, result.decision).Inc()
+	if result.decision != decisionAccepted {
+		slog.Warn("queue back-pressure applied",
+			"mode", cfg.Mode,
+			"decision", result.decision,
+			"stage", result.stage,
+			"pending", result.pending,
+		)
+	}
+	return result
+}
+
+// rejectSaturated responds 503 with a Retry-After header for a rejected submission
+func (h *Handler) rejectSaturated(w http.ResponseWriter, result admission) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(h.backPressure.RetryAfter.Seconds())))
+	respondError(w, fmt.Sprintf("Queue %s is saturated with %d pending tasks, retry later", result.stage, result.pending), http.StatusServiceUnavailable)
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/docutag/textanalyzer/internal/queue"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// fakeQueueDepth serves fixed pending counts per stage; stages it does not
+// list have an unknown depth
+type fakeQueueDepth map[string]int
+
+func (f fakeQueueDepth) Pending(stage string) (int, bool) {
+	pending, ok := f[stage]
+	return pending, ok
+}
+
+func TestBackPressure(t *testing.T) {
+	limits := BackPressureConfig{MaxPendingEnrichment: 100, MaxPendingOffline: 500, RetryAfter: 45 * time.Second}
+
+	tests := []struct {
+		name     string
+		mode     string
+		depth    fakeQueueDepth
+		code     int
+		degraded bool
+		decision string
+	}{
+		{"below thresholds", BackPressureStrict, fakeQueueDepth{queue.StageTextEnrichment: 100, queue.StageOfflineProcessing: 500}, http.StatusAccepted, false, decisionAccepted},
+		{"strict rejects saturated enrichment", BackPressureStrict, fakeQueueDepth{queue.StageTextEnrichment: 101, queue.StageOfflineProcessing: 0}, http.StatusServiceUnavailable, false, decisionRejected},
+		{"degraded accepts offline-only", BackPressureDegraded, fakeQueueDepth{queue.StageTextEnrichment: 101, queue.StageOfflineProcessing: 0}, http.StatusAccepted, true, decisionDegraded},
+		{"degraded rejects saturated offline processing", BackPressureDegraded, fakeQueueDepth{queue.StageTextEnrichment: 0, queue.StageOfflineProcessing: 501}, http.StatusServiceUnavailable, false, decisionRejected},
+		{"unknown depth", BackPressureStrict, fakeQueueDepth{}, http.StatusAccepted, false, decisionAccepted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := setupStatelessHandler()
+			mockQueue := &mockQueueClient{}
+			handler.queueClient = mockQueue
+			handler.queueDepth = tt.depth
+			handler.backPressure = limits
+			handler.backPressure.Mode = tt.mode
+
+			req := httptest.NewRequest(http.MethodPost, "/api/analyze", bytes.NewReader([]byte(`{"text": "The council approved the plan."}`)))
+			w := httptest.NewRecorder()
+			handler.mux.ServeHTTP(w, req)
+			if w.Code != tt.code {
+				t.Fatalf("Expected status %d, got %d: %s", tt.code, w.Code, w.Body.String())
+			}
+
+			if tt.code == http.StatusServiceUnavailable {
+				if got := w.Header().Get("Retry-After"); got != "45" {
+					t.Errorf("Expected Retry-After 45, got %q", got)
+				}
+			} else {
+				var response map[string]interface{}
+				json.NewDecoder(w.Body).Decode(&response)
+				if degraded, _ := response["degraded"].(bool); degraded != tt.degraded {
+					t.Errorf("Expected degraded %v, got %v", tt.degraded, response["degraded"])
+				}
+				if mockQueue.lastOptions.OfflineOnly != tt.degraded {
+					t.Errorf("Expected offline_only %v on the queued task, got %v", tt.degraded, mockQueue.lastOptions.OfflineOnly)
+				}
+			}
+
+			if got := testutil.ToFloat64(handler.metrics.BackPressureDecisions.WithLabelValues(tt.mode, tt.decision)); got != 1 {
+				t.Errorf("Expected one %s decision counted in %s mode, got %v", tt.decision, tt.mode, got)
+			}
+		})
+	}
+}
+
+func TestBackPressureOff(t *testing.T) {
+	handler := setupStatelessHandler()
+	handler.queueDepth = fakeQueueDepth{queue.StageTextEnrichment: 1000, queue.StageOfflineProcessing: 1000}
+	handler.backPressure = BackPressureConfig{Mode: BackPressureOff, MaxPendingEnrichment: 1, MaxPendingOffline: 1}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/analyze", bytes.NewReader([]byte(`{"text": "The council approved the plan."}`)))
+	w := httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202 with back-pressure off, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := testutil.CollectAndCount(handler.metrics.BackPressureDecisions); got != 0 {
+		t.Errorf("Expected no decisions counted with back-pressure off, got %d", got)
+	}
+}
+
+func TestParseBackPressureMode(t *testing.T) {
+	for input, want := range map[string]string{"": BackPressureOff, "strict": BackPressureStrict, "degraded": BackPressureDegraded} {
+		if got, err := ParseBackPressureMode(input); err != nil || got != want {
+			t.Errorf("ParseBackPressureMode(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+	if _, err := ParseBackPressureMode("drop"); err == nil {
+		t.Error("Expected an error for an unknown mode")
+	}
+}
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/docutag/platform/pkg/tracing"
+	"github.com/docutag/textanalyzer/internal/queue"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// maxBatchDocuments is the most documents accepted by one batch request
+const maxBatchDocuments = 100
+
+// Statuses of the documents of a batch
+const (
+	batchStatusQueued = "queued"
+	batchStatusFailed = "failed"
+)
+
+// batchEnqueuer is implemented by queue clients that enqueue the documents of
+// a batch together. Other clients enqueue them one at a time.
+type batchEnqueuer interface {
+	EnqueueProcessDocumentBatch(ctx context.Context, docs []queue.ProcessDocumentRequest) []queue.EnqueueResult
+}
+
+// batchItem is the outcome of one document of a batch
+type batchItem struct {
+	JobID    string   `json:"job_id"`
+	TaskID   string   `json:"task_id,omitempty"`
+	Status   string   `json:"status"`
+	Error    string   `json:"error,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// handleAnalyzeBatch queues an analysis for each document of a batch. Every
+// document is validated before any is queued, so an invalid document rejects
+// the whole batch; a document that fails to enqueue is reported in its entry.
+//
+//	POST /api/analyze/batch
+func (h *Handler) handleAnalyzeBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Reject oversized bodies before they are decoded and enqueued
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxBody)
+
+	var req struct {
+		Documents []analyzeRequest `json:"documents"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		reqErr := bodyError(err)
+		respondError(w, reqErr.message, reqErr.status)
+		return
+	}
+
+	if len(req.Documents) == 0 {
+		respondError(w, "documents field is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Documents) > maxBatchDocuments {
+		respondError(w, fmt.Sprintf("Too many documents: %d submitted, maximum is %d", len(req.Documents), maxBatchDocuments), http.StatusBadRequest)
+		return
+	}
+
+	prepared := make([]*preparedAnalysis, len(req.Documents))
+	for i := range req.Documents {
+		analysis, reqErr := h.prepareAnalysis(&req.Documents[i])
+		if reqErr != nil {
+			respondError(w, fmt.Sprintf("documents[%d]: %s", i, reqErr.message), reqErr.status)
+			return
+		}
+		prepared[i] = analysis
+	}
+
+	// One back-pressure decision covers the whole batch
+	admitted := h.admit()
+	if admitted.decision == decisionRejected {
+		h.rejectSaturated(w, admitted)
+		return
+	}
+	offlineOnly := admitted.decision == decisionDegraded
+
+	tracing.SetSpanAttributes(r.Context(), attribute.Int("batch.size", len(prepared)))
+
+	docs := make([]queue.ProcessDocumentRequest, len(prepared))
+	for i, analysis := range prepared {
+		options := analysis.options
+		options.OfflineOnly = offlineOnly
+		docs[i] = queue.ProcessDocumentRequest{
+			AnalysisID:   generateID(),
+			Text:         analysis.req.Text,
+			OriginalHTML: analysis.req.OriginalHTML,
+			Images:       analysis.images,
+			Options:      options,
+		}
+	}
+
+	results := h.enqueueBatch(r.Context(), docs)
+	items := make([]batchItem, len(docs))
+	failed := 0
+	for i, result := range results {
+		items[i] = batchItem{JobID: docs[i].AnalysisID, Warnings: prepared[i].warnings}
+		if result.Err != nil {
+			h.metrics.observeEnqueueError(result.Err)
+			items[i].Status = batchStatusFailed
+			items[i].Error = fmt.Sprintf("Failed to enqueue analysis: %v", result.Err)
+			failed++
+			continue
+		}
+		items[i].TaskID = result.TaskID
+		items[i].Status = batchStatusQueued
+	}
+
+	response := map[string]interface{}{
+		"jobs":   items,
+		"queued": len(items) - failed,
+		"failed": failed,
+	}
+	if offlineOnly {
+		response["degraded"] = true
+		response["warnings"] = []string{degradedWarning}
+	}
+	respondJSON(w, response, http.StatusAccepted)
+}
+
+// enqueueBatch enqueues the documents of a batch, returning their outcomes
+// in the same order
+func (h *Handler) enqueueBatch(ctx context.Context, docs []queue.ProcessDocumentRequest) []queue.EnqueueResult {
+	if batch, ok := h.queueClient.(batchEnqueuer); ok {
+		return batch.EnqueueProcessDocumentBatch(ctx, docs)
+	}
+
+	results := make([]queue.EnqueueResult, len(docs))
+	for i, doc := range docs {
+		taskID, err := h.queueClient.EnqueueProcessDocument(ctx, doc.AnalysisID, doc.Text, doc.OriginalHTML, doc.Images, doc.Options)
+		results[i] = queue.EnqueueResult{TaskID: taskID, Err: err}
+	}
+	return results
+}
@@ -0,0 +1,207 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/docutag/textanalyzer/internal/queue"
+)
+
+// mockBatchQueueClient enqueues batches together, failing documents whose
+// text is failText
+type mockBatchQueueClient struct {
+	mockQueueClient
+	batches [][]queue.ProcessDocumentRequest
+}
+
+const failText = "This document fails to enqueue."
+
+func (m *mockBatchQueueClient) EnqueueProcessDocumentBatch(ctx context.Context, docs []queue.ProcessDocumentRequest) []queue.EnqueueResult {
+	m.batches = append(m.batches, docs)
+	results := make([]queue.EnqueueResult, len(docs))
+	for i, doc := range docs {
+		if doc.Text == failText {
+			results[i].Err = errors.New("redis unavailable")
+			continue
+		}
+		results[i].TaskID = "task-" + doc.AnalysisID
+	}
+	return results
+}
+
+// batchResponse is the body of a batch analysis response
+type batchResponse struct {
+	Jobs     []batchItem `json:"jobs"`
+	Queued   int         `json:"queued"`
+	Failed   int         `json:"failed"`
+	Degraded bool        `json:"degraded"`
+	Error    string      `json:"error"`
+}
+
+func postBatch(t *testing.T, handler *Handler, body string) (int, batchResponse) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/analyze/batch", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+
+	var response batchResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return w.Code, response
+}
+
+func TestAnalyzeBatch(t *testing.T) {
+	handler := setupStatelessHandler()
+	mockQueue := &mockBatchQueueClient{}
+	handler.queueClient = mockQueue
+
+	code, response := postBatch(t, handler, `{"documents": [
+		{"text": "The council approved the plan.", "priority": "low"},
+		{"text": "`+failText+`"},
+		{"text": "The mayor spoke first.", "images": ["https://example.com/a.jpg", "https://example.com/a.jpg"]}
+	]}`)
+	if code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %s", code, response.Error)
+	}
+	if len(mockQueue.batches) != 1 || len(mockQueue.batches[0]) != 3 {
+		t.Fatalf("Expected one batch of 3 documents, got %v", mockQueue.batches)
+	}
+	if response.Queued != 2 || response.Failed != 1 || len(response.Jobs) != 3 {
+		t.Fatalf("Expected 2 queued and 1 failed of 3 jobs, got %+v", response)
+	}
+
+	docs := mockQueue.batches[0]
+	for i, job := range response.Jobs {
+		if job.JobID != docs[i].AnalysisID {
+			t.Errorf("Expected job %d to be %q, got %q", i, docs[i].AnalysisID, job.JobID)
+		}
+	}
+	if response.Jobs[0].Status != batchStatusQueued || response.Jobs[0].TaskID != "task-"+docs[0].AnalysisID {
+		t.Errorf("Expected the first document queued, got %+v", response.Jobs[0])
+	}
+	if response.Jobs[1].Status != batchStatusFailed || !strings.Contains(response.Jobs[1].Error, "redis unavailable") || response.Jobs[1].TaskID != "" {
+		t.Errorf("Expected the second document to fail, got %+v", response.Jobs[1])
+	}
+	if docs[0].Options.Priority != queue.PriorityLow || docs[2].Options.Priority != queue.PriorityNormal {
+		t.Errorf("Expected per-document priorities, got %q and %q", docs[0].Options.Priority, docs[2].Options.Priority)
+	}
+	if len(docs[2].Images) != 1 || docs[2].Options.ImagesSubmitted != 2 {
+		t.Errorf("Expected duplicate images dropped, got %v of %d", docs[2].Images, docs[2].Options.ImagesSubmitted)
+	}
+	if docs[0].AnalysisID == docs[2].AnalysisID {
+		t.Errorf("Expected a job ID per document, got %q twice", docs[0].AnalysisID)
+	}
+}
+
+func TestAnalyzeBatchEnqueuesEachDocument(t *testing.T) {
+	handler := setupStatelessHandler()
+	mockQueue := &mockQueueClient{}
+	handler.queueClient = mockQueue
+
+	code, response := postBatch(t, handler, `{"documents": [{"text": "The council approved the plan."}, {"text": "The mayor spoke first."}]}`)
+	if code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %s", code, response.Error)
+	}
+	if response.Queued != 2 || response.Failed != 0 {
+		t.Fatalf("Expected 2 queued, got %+v", response)
+	}
+	for _, job := range response.Jobs {
+		if job.Status != batchStatusQueued || job.TaskID != "mock-task-id" {
+			t.Errorf("Expected a queued job, got %+v", job)
+		}
+	}
+
+	mockQueue.err = errors.New("redis unavailable")
+	code, response = postBatch(t, handler, `{"documents": [{"text": "The council approved the plan."}]}`)
+	if code != http.StatusAccepted || response.Failed != 1 || response.Jobs[0].Status != batchStatusFailed {
+		t.Errorf("Expected the failure reported per document, got %d %+v", code, response)
+	}
+}
+
+func TestAnalyzeBatchValidation(t *testing.T) {
+	tooMany := `{"documents": [` + strings.TrimSuffix(strings.Repeat(`{"text": "Text."},`, maxBatchDocuments+1), ",") + `]}`
+
+	tests := []struct {
+		name    string
+		body    string
+		code    int
+		message string
+	}{
+		{"no documents", `{"documents": []}`, http.StatusBadRequest, "documents field is required"},
+		{"too many documents", tooMany, http.StatusBadRequest, fmt.Sprintf("maximum is %d", maxBatchDocuments)},
+		{"missing text", `{"documents": [{"text": "Text."}, {"text": ""}]}`, http.StatusBadRequest, "documents[1]: Text field is required"},
+		{"invalid priority", `{"documents": [{"text": "Text.", "priority": "urgent"}]}`, http.StatusBadRequest, "documents[0]: Invalid priority"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := setupStatelessHandler()
+			mockQueue := &mockBatchQueueClient{}
+			handler.queueClient = mockQueue
+
+			code, response := postBatch(t, handler, tt.body)
+			if code != tt.code || !strings.Contains(response.Error, tt.message) {
+				t.Errorf("Expected %d with %q, got %d with %q", tt.code, tt.message, code, response.Error)
+			}
+			if len(mockQueue.batches) != 0 {
+				t.Errorf("Expected nothing queued for an invalid batch, got %v", mockQueue.batches)
+			}
+		})
+	}
+}
+
+func TestAnalyzeBatchBodyLimit(t *testing.T) {
+	handler := setupStatelessHandler()
+	handler.maxBody = 64 << 10
+	mockQueue := &mockBatchQueueClient{}
+	handler.queueClient = mockQueue
+
+	// Each document is within the limit, the batch is not
+	document := `{"text": "` + strings.Repeat("word ", 2000) + `"},`
+	body := `{"documents": [` + strings.TrimSuffix(strings.Repeat(document, 10), ",") + `]}`
+
+	code, response := postBatch(t, handler, body)
+	if code != http.StatusRequestEntityTooLarge || response.Error != "Request body exceeds maximum size of 65536 bytes" {
+		t.Errorf("Expected 413 for an oversized batch, got %d with %q", code, response.Error)
+	}
+	if len(mockQueue.batches) != 0 {
+		t.Errorf("Expected nothing queued for an oversized batch, got %d batches", len(mockQueue.batches))
+	}
+}
+
+func TestAnalyzeBatchBackPressure(t *testing.T) {
+	handler := setupStatelessHandler()
+	mockQueue := &mockBatchQueueClient{}
+	handler.queueClient = mockQueue
+	handler.queueDepth = fakeQueueDepth{queue.StageTextEnrichment: 101, queue.StageOfflineProcessing: 0}
+	handler.backPressure = BackPressureConfig{Mode: BackPressureDegraded, MaxPendingEnrichment: 100, RetryAfter: defaultRetryAfter}
+
+	code, response := postBatch(t, handler, `{"documents": [{"text": "The council approved the plan."}, {"text": "The mayor spoke first."}]}`)
+	if code != http.StatusAccepted || !response.Degraded {
+		t.Fatalf("Expected a degraded 202, got %d %+v", code, response)
+	}
+	for _, doc := range mockQueue.batches[0] {
+		if !doc.Options.OfflineOnly {
+			t.Errorf("Expected %s queued offline-only", doc.AnalysisID)
+		}
+	}
+
+	handler.backPressure.Mode = BackPressureStrict
+	req := httptest.NewRequest(http.MethodPost, "/api/analyze/batch", bytes.NewReader([]byte(`{"documents": [{"text": "Text."}]}`)))
+	w := httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected 503 with Retry-After, got %d", w.Code)
+	}
+	if len(mockQueue.batches) != 1 {
+		t.Errorf("Expected the rejected batch not to be queued, got %d batches", len(mockQueue.batches))
+	}
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/docutag/textanalyzer/internal/database"
+)
+
+// errInvalidCursor rejects a cursor that was not returned as next_cursor
+var errInvalidCursor = errors.New("invalid cursor")
+
+// encodeCursor returns the opaque next_cursor of a listing position: the
+// creation time and ID of the last analysis, base64url-encoded
+func encodeCursor(cursor database.Cursor) string {
+	raw := cursor.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + cursor.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor parses a cursor returned by encodeCursor
+func decodeCursor(encoded string) (database.Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return database.Cursor{}, errInvalidCursor
+	}
+	createdAt, id, ok := strings.Cut(string(raw), "|")
+	if !ok || id == "" {
+		return database.Cursor{}, errInvalidCursor
+	}
+	t, err := time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return database.Cursor{}, errInvalidCursor
+	}
+	return database.Cursor{CreatedAt: t, ID: id}, nil
+}
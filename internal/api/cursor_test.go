@@ -0,0 +1,42 @@
+package api
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/docutag/textanalyzer/internal/database"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	zone := time.FixedZone("CEST", 2*60*60)
+	cursor := database.Cursor{
+		CreatedAt: time.Date(2025, 3, 1, 14, 30, 15, 123456000, zone),
+		ID:        "550e8400-e29b-41d4-a716-446655440000",
+	}
+
+	decoded, err := decodeCursor(encodeCursor(cursor))
+	if err != nil {
+		t.Fatalf("Failed to decode cursor: %v", err)
+	}
+	if !decoded.CreatedAt.Equal(cursor.CreatedAt) || decoded.ID != cursor.ID {
+		t.Errorf("Expected %+v, got %+v", cursor, decoded)
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	encode := func(raw string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(raw))
+	}
+
+	for _, encoded := range []string{
+		"not base64!",
+		encode("2025-03-01T12:00:00Z"),
+		encode("2025-03-01T12:00:00Z|"),
+		encode("yesterday|abc"),
+	} {
+		if _, err := decodeCursor(encoded); err == nil {
+			t.Errorf("Expected %q rejected", encoded)
+		}
+	}
+}
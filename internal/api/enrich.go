@@ -0,0 +1,154 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/docutag/textanalyzer/internal/analyzer"
+	"github.com/docutag/textanalyzer/internal/models"
+	"github.com/docutag/textanalyzer/internal/queue"
+)
+
+// enrichmentEnqueuer is implemented by queue clients that can enqueue AI
+// enrichment for an analysis that has already been processed offline
+type enrichmentEnqueuer interface {
+	EnqueueEnrichText(ctx context.Context, analysisID, text, offlineText, originalHTML, priority string, redactText bool) (string, error)
+	EnqueueEnrichImage(ctx context.Context, analysisID, imageURL string, imageIndex int, priority string) (string, error)
+}
+
+// enrich re-enqueues AI enrichment for a stored analysis, e.g. one completed
+// offline-only while Ollama was down. The quality gate is applied again with
+// the analysis's recorded threshold unless force is set. Enrichment already
+// waiting to run, running or retrying for the analysis is refused with a 409;
+// finished enrichment tasks are removed from the queue so their IDs can be
+// reused.
+func (h *Handler) enrich(w http.ResponseWriter, r *http.Request, id string) {
+	var req struct {
+		Force bool `json:"force,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		respondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	enqueuer, ok := h.queueClient.(enrichmentEnqueuer)
+	if !ok || h.inspector == nil {
+		respondError(w, "Enrichment queueing is not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	analysis, err := h.db.GetAnalysisWithHTML(ctx, id)
+	if err != nil {
+		respondAnalysisError(ctx, w, err)
+		return
+	}
+	if analysis.Metadata.Redaction != nil {
+		respondError(w, "Analysis cannot be enriched: its text was not stored", http.StatusConflict)
+		return
+	}
+
+	imageCount := familyImageCount(analysis)
+	tasks, err := h.inspector.FamilyTasks(id, imageCount)
+	if err != nil {
+		respondError(w, fmt.Sprintf("Failed to inspect tasks: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if task := enrichmentInProgress(tasks); task != nil {
+		respondError(w, fmt.Sprintf("AI enrichment is already queued for this analysis: task %s is %s", task.TaskID, task.State), http.StatusConflict)
+		return
+	}
+
+	opts := analyzer.RecordedOptions(analysis.Metadata)
+	qualityScore := 0.0
+	if analysis.Metadata.QualityScore != nil {
+		qualityScore = analysis.Metadata.QualityScore.Score
+	}
+	if !analyzer.PassesEnrichmentGate(analysis.Metadata.QualityScore, opts.Threshold, req.Force) {
+		respondError(w, fmt.Sprintf("AI enrichment skipped: quality score %.2f is below threshold %.2f; set force to enrich anyway", qualityScore, opts.Threshold), http.StatusUnprocessableEntity)
+		return
+	}
+
+	images, err := h.db.GetAnalysisImages(ctx, id)
+	if err != nil {
+		respondError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := h.inspector.ClearEnrichmentTasks(id, imageCount); err != nil {
+		respondError(w, fmt.Sprintf("Failed to clear finished tasks: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Passing the gate starts a new run: the job reports processing again
+	// and the worker applies the same gate, forced or not
+	analysis.Metadata.ForceEnrichment = req.Force
+	analysis.Metadata.EnrichmentSkipped = false
+	analysis.Metadata.OfflineOnly = false
+	analysis.Metadata.SkipEnrichment = false
+	if err := h.db.SaveAnalysis(ctx, analysis); err != nil {
+		respondError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := h.db.UpdateProcessingStage(ctx, id, models.ProcessingStageOfflineComplete); err != nil {
+		respondError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Enrich the cleaned text when available, as offline processing does
+	offlineText := analysis.Text
+	if analysis.Metadata.CleanedText != "" {
+		offlineText = analysis.Metadata.CleanedText
+	}
+	priority := jobPriority(analysis)
+	taskID, err := enqueuer.EnqueueEnrichText(ctx, id, analysis.Text, offlineText, analysis.OriginalHTML, priority, false)
+	if err != nil {
+		h.metrics.observeEnqueueError(err)
+		respondError(w, fmt.Sprintf("Failed to enqueue enrichment: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	taskIDs := []string{taskID}
+	var warnings []string
+	for _, image := range images {
+		taskID, err := enqueuer.EnqueueEnrichImage(ctx, id, image.URL, image.ImageIndex, priority)
+		if err != nil {
+			h.metrics.observeEnqueueError(err)
+			warnings = append(warnings, fmt.Sprintf("Failed to enqueue enrichment of image %d: %v", image.ImageIndex, err))
+			continue
+		}
+		taskIDs = append(taskIDs, taskID)
+	}
+
+	response := map[string]interface{}{
+		"job_id":               id,
+		"task_ids":             taskIDs,
+		"status":               "queued",
+		"message":              "AI enrichment queued",
+		"quality_score":        qualityScore,
+		"enrichment_threshold": opts.Threshold,
+		"forced":               req.Force,
+		"priority":             priority,
+	}
+	if len(warnings) > 0 {
+		response["warnings"] = warnings
+	}
+	respondJSON(w, response, http.StatusAccepted)
+}
+
+// enrichmentInProgress returns the first enrichment task of a job that is
+// waiting to run, running or waiting to retry, or nil when there is none
+func enrichmentInProgress(tasks []queue.TaskStatus) *queue.TaskStatus {
+	for i, task := range tasks {
+		if task.Type == queue.TypeProcessDocument {
+			continue
+		}
+		switch task.State {
+		case "pending", "active", "scheduled", "retry":
+			return &tasks[i]
+		}
+	}
+	return nil
+}
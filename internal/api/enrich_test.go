@@ -0,0 +1,227 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/docutag/textanalyzer/internal/database"
+	"github.com/docutag/textanalyzer/internal/models"
+	"github.com/docutag/textanalyzer/internal/queue"
+)
+
+// mockEnrichQueueClient records the enrichment tasks it enqueues
+type mockEnrichQueueClient struct {
+	mockQueueClient
+	texts  []string // Offline texts of the text enrichment tasks
+	images []string // Image URLs of the image enrichment tasks
+}
+
+func (m *mockEnrichQueueClient) EnqueueEnrichText(ctx context.Context, analysisID, text, offlineText, originalHTML, priority string, redactText bool) (string, error) {
+	m.texts = append(m.texts, offlineText)
+	return queue.TextEnrichTaskID(analysisID), nil
+}
+
+func (m *mockEnrichQueueClient) EnqueueEnrichImage(ctx context.Context, analysisID, imageURL string, imageIndex int, priority string) (string, error) {
+	m.images = append(m.images, imageURL)
+	return queue.ImageEnrichTaskID(analysisID, imageIndex), nil
+}
+
+// enrichResponse is the body of an enrichment response
+type enrichResponse struct {
+	TaskIDs             []string `json:"task_ids"`
+	Status              string   `json:"status"`
+	QualityScore        float64  `json:"quality_score"`
+	EnrichmentThreshold float64  `json:"enrichment_threshold"`
+	Forced              bool     `json:"forced"`
+	Error               string   `json:"error"`
+}
+
+func postEnrich(t *testing.T, handler *Handler, id, body string) (int, enrichResponse) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/analyses/"+id+"/enrich", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+
+	var response enrichResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return w.Code, response
+}
+
+// saveOfflineAnalysis stores an analysis that was processed offline only,
+// with the given quality score and enrichment threshold
+func saveOfflineAnalysis(t *testing.T, db *database.DB, id string, score float64) {
+	t.Helper()
+	threshold := 0.35
+	analysis := &models.Analysis{
+		ID:   id,
+		Text: "The council approved the plan. It adds twelve routes.",
+		Metadata: models.Metadata{
+			QualityScore:        &models.TextQualityScore{Score: score},
+			EnrichmentThreshold: &threshold,
+			EnrichmentSkipped:   score < threshold,
+			OfflineOnly:         true,
+			CleanedText:         "The council approved the plan.",
+		},
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+		t.Fatalf("Failed to save test analysis: %v", err)
+	}
+	if err := db.UpdateProcessingStage(context.Background(), id, models.ProcessingStageFailed); err != nil {
+		t.Fatalf("Failed to update processing stage: %v", err)
+	}
+}
+
+func TestEnrichAnalysis(t *testing.T) {
+	handler, db, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	mockQueue := &mockEnrichQueueClient{}
+	handler.queueClient = mockQueue
+	inspector := &fakeTaskInspector{tasks: []queue.TaskStatus{
+		{TaskID: "test-enrich-001", Type: queue.TypeProcessDocument, State: "completed"},
+		{TaskID: "test-enrich-001-text-enrich", Type: queue.TypeEnrichText, State: "archived"},
+	}}
+	handler.inspector = inspector
+
+	saveOfflineAnalysis(t, db, "test-enrich-001", 0.6)
+	for i, url := range []string{"https://example.com/a.jpg", "https://example.com/b.jpg"} {
+		if err := db.SaveImageMetadata(context.Background(), &models.ImageMetadata{AnalysisID: "test-enrich-001", ImageIndex: i, URL: url, Format: "jpeg"}); err != nil {
+			t.Fatalf("Failed to save image metadata: %v", err)
+		}
+	}
+
+	code, response := postEnrich(t, handler, "test-enrich-001", "")
+	if code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %s", code, response.Error)
+	}
+	wantTasks := []string{"test-enrich-001-text-enrich", "test-enrich-001-image-enrich-0", "test-enrich-001-image-enrich-1"}
+	if !reflect.DeepEqual(response.TaskIDs, wantTasks) {
+		t.Errorf("Expected task IDs %v, got %v", wantTasks, response.TaskIDs)
+	}
+	if response.Forced || response.QualityScore != 0.6 || response.EnrichmentThreshold != 0.35 {
+		t.Errorf("Expected an unforced enrichment passing the 0.35 gate, got %+v", response)
+	}
+	if !reflect.DeepEqual(mockQueue.texts, []string{"The council approved the plan."}) {
+		t.Errorf("Expected the cleaned text enqueued, got %v", mockQueue.texts)
+	}
+	if len(mockQueue.images) != 2 {
+		t.Errorf("Expected both stored images enqueued, got %v", mockQueue.images)
+	}
+	if !reflect.DeepEqual(inspector.cleared, []string{"test-enrich-001"}) {
+		t.Errorf("Expected the finished enrichment tasks cleared, got %v", inspector.cleared)
+	}
+
+	// The job is processing again
+	stored, err := db.GetAnalysis(context.Background(), "test-enrich-001")
+	if err != nil {
+		t.Fatalf("Failed to get analysis: %v", err)
+	}
+	if stored.Metadata.OfflineOnly || stored.Metadata.EnrichmentSkipped || stored.Metadata.SkipEnrichment || stored.Metadata.ForceEnrichment {
+		t.Errorf("Expected the offline-only flags cleared, got %+v", stored.Metadata)
+	}
+	state, err := db.GetProcessingState(context.Background(), "test-enrich-001")
+	if err != nil {
+		t.Fatalf("Failed to get processing state: %v", err)
+	}
+	if state.Stage != models.ProcessingStageOfflineComplete || state.LastError != "" {
+		t.Errorf("Expected a new run from the offline stage, got %+v", state)
+	}
+
+	// Unknown analysis and wrong method
+	code, _ = postEnrich(t, handler, "missing", "")
+	if code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for unknown analysis, got %d", code)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/api/analyses/test-enrich-001/enrich", nil)
+	w := httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", w.Code)
+	}
+}
+
+func TestEnrichAnalysisForce(t *testing.T) {
+	handler, db, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	mockQueue := &mockEnrichQueueClient{}
+	handler.queueClient = mockQueue
+	handler.inspector = &fakeTaskInspector{}
+	saveOfflineAnalysis(t, db, "test-enrich-002", 0.2)
+
+	// Below the threshold the gate refuses again
+	code, response := postEnrich(t, handler, "test-enrich-002", `{"force": false}`)
+	if code != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected status 422 below the threshold, got %d", code)
+	}
+	if len(mockQueue.texts) != 0 {
+		t.Errorf("Expected nothing enqueued, got %v", mockQueue.texts)
+	}
+
+	code, response = postEnrich(t, handler, "test-enrich-002", `{"force": true}`)
+	if code != http.StatusAccepted {
+		t.Fatalf("Expected status 202 when forced, got %d: %s", code, response.Error)
+	}
+	if !response.Forced || len(response.TaskIDs) != 1 {
+		t.Errorf("Expected a forced text enrichment, got %+v", response)
+	}
+
+	// The worker reads the flag to skip its own gate
+	stored, err := db.GetAnalysis(context.Background(), "test-enrich-002")
+	if err != nil {
+		t.Fatalf("Failed to get analysis: %v", err)
+	}
+	if !stored.Metadata.ForceEnrichment || stored.Metadata.EnrichmentSkipped {
+		t.Errorf("Expected a forced enrichment recorded, got %+v", stored.Metadata)
+	}
+
+	code, _ = postEnrich(t, handler, "test-enrich-002", `{"force": "yes"}`)
+	if code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid body, got %d", code)
+	}
+}
+
+func TestEnrichAnalysisConflict(t *testing.T) {
+	handler, db, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	mockQueue := &mockEnrichQueueClient{}
+	handler.queueClient = mockQueue
+	inspector := &fakeTaskInspector{}
+	handler.inspector = inspector
+	saveOfflineAnalysis(t, db, "test-enrich-003", 0.6)
+
+	for _, state := range []string{"pending", "active", "retry"} {
+		inspector.tasks = []queue.TaskStatus{
+			{TaskID: "test-enrich-003", Type: queue.TypeProcessDocument, State: "completed"},
+			{TaskID: "test-enrich-003-text-enrich", Type: queue.TypeEnrichText, State: state},
+		}
+		code, response := postEnrich(t, handler, "test-enrich-003", `{"force": true}`)
+		if code != http.StatusConflict {
+			t.Errorf("Expected status 409 with a %s enrichment task, got %d: %s", state, code, response.Error)
+		}
+	}
+	if len(mockQueue.texts) != 0 || len(inspector.cleared) != 0 {
+		t.Errorf("Expected nothing cleared or enqueued, got %v and %v", inspector.cleared, mockQueue.texts)
+	}
+}
+
+func TestEnrichAnalysisWithoutInspector(t *testing.T) {
+	handler := setupStatelessHandler()
+	handler.queueClient = &mockEnrichQueueClient{}
+
+	code, _ := postEnrich(t, handler, "doc-1", "")
+	if code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, got %d", code)
+	}
+}
@@ -0,0 +1,164 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/docutag/textanalyzer/internal/queue"
+)
+
+// failedTasksPath prefixes the dead-letter admin endpoints
+const failedTasksPath = "/api/admin/failed-tasks"
+
+// Archived tasks listed per queue
+const (
+	defaultFailedTaskLimit = 50
+	maxFailedTaskLimit     = 500
+)
+
+// failedTaskManager is implemented by task inspectors that list archived
+// tasks and move them back to pending
+type failedTaskManager interface {
+	ArchivedTasks(queueName string, limit int) ([]queue.ArchivedQueue, error)
+	RetryArchivedTask(queueName, taskID string) (*queue.FailedTask, error)
+	RetryAllArchivedTasks(queueName string) ([]queue.FailedTask, error)
+}
+
+// handleFailedTasks lists the tasks archived after exhausting their retries
+// and moves them back to pending, one at a time or a queue at a time
+//
+//	GET  /api/admin/failed-tasks
+//	POST /api/admin/failed-tasks/{queue}/retry
+//	POST /api/admin/failed-tasks/{queue}/{task_id}/retry
+func (h *Handler) handleFailedTasks(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, failedTasksPath), "/")
+	parts := strings.Split(path, "/")
+
+	method := http.MethodPost
+	switch {
+	case path == "":
+		method = http.MethodGet
+	case len(parts) == 2 && parts[1] == "retry", len(parts) == 3 && parts[2] == "retry":
+	default:
+		respondError(w, "Not found", http.StatusNotFound)
+		return
+	}
+	if r.Method != method {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+
+	manager, ok := h.inspector.(failedTaskManager)
+	if !ok {
+		respondError(w, "Task inspection is not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch len(parts) {
+	case 2:
+		h.retryAllFailedTasks(w, manager, parts[0])
+	case 3:
+		h.retryFailedTask(w, manager, parts[0], parts[1])
+	default:
+		h.listFailedTasks(w, r, manager)
+	}
+}
+
+// listFailedTasks lists the archived tasks of every pipeline queue, or of
+// the queue named by ?queue=, up to ?limit= per queue
+func (h *Handler) listFailedTasks(w http.ResponseWriter, r *http.Request, manager failedTaskManager) {
+	limit := defaultFailedTaskLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			respondError(w, "Invalid limit: must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = min(parsed, maxFailedTaskLimit)
+	}
+
+	queues, err := manager.ArchivedTasks(r.URL.Query().Get("queue"), limit)
+	if err != nil {
+		if errors.Is(err, queue.ErrUnknownQueue) {
+			respondError(w, "Invalid queue: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		respondError(w, "Failed to list archived tasks: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	total := 0
+	for _, archived := range queues {
+		total += archived.Archived
+	}
+	respondJSON(w, map[string]interface{}{
+		"queues":         queues,
+		"total_archived": total,
+		"limit":          limit,
+	}, http.StatusOK)
+}
+
+// retryFailedTask moves one archived task back to pending
+func (h *Handler) retryFailedTask(w http.ResponseWriter, manager failedTaskManager, queueName, taskID string) {
+	task, err := manager.RetryArchivedTask(queueName, taskID)
+	if err != nil {
+		respondFailedTaskError(w, err)
+		return
+	}
+
+	logRequeue(queueName, task)
+	respondJSON(w, map[string]interface{}{
+		"queue":    queueName,
+		"requeued": task,
+	}, http.StatusOK)
+}
+
+// retryAllFailedTasks moves every archived task of a queue back to pending
+func (h *Handler) retryAllFailedTasks(w http.ResponseWriter, manager failedTaskManager, queueName string) {
+	tasks, err := manager.RetryAllArchivedTasks(queueName)
+	// Tasks requeued before a failure are logged all the same
+	for i := range tasks {
+		logRequeue(queueName, &tasks[i])
+	}
+	if err != nil {
+		respondFailedTaskError(w, err)
+		return
+	}
+
+	slog.Info("archived tasks requeued", "queue", queueName, "count", len(tasks))
+	respondJSON(w, map[string]interface{}{
+		"queue":    queueName,
+		"requeued": tasks,
+		"count":    len(tasks),
+	}, http.StatusOK)
+}
+
+// logRequeue records an archived task moved back to pending
+func logRequeue(queueName string, task *queue.FailedTask) {
+	slog.Info("archived task requeued",
+		"queue", queueName,
+		"task_id", task.TaskID,
+		"task_type", task.Type,
+		"analysis_id", task.Payload.AnalysisID,
+		"retried", task.Retried,
+		"last_error", task.LastError,
+	)
+}
+
+// respondFailedTaskError responds with the status matching a dead-letter error
+func respondFailedTaskError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, queue.ErrUnknownQueue), errors.Is(err, queue.ErrTaskNotFound):
+		respondError(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, queue.ErrTaskNotArchived):
+		respondError(w, err.Error(), http.StatusConflict)
+	default:
+		respondError(w, "Failed to requeue archived tasks: "+err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,206 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/docutag/textanalyzer/internal/queue"
+)
+
+// fakeFailedTasks serves archived tasks from memory, keyed by queue
+type fakeFailedTasks struct {
+	fakeTaskInspector
+	archived  map[string][]queue.FailedTask
+	lastLimit int
+	err       error
+}
+
+func (f *fakeFailedTasks) ArchivedTasks(queueName string, limit int) ([]queue.ArchivedQueue, error) {
+	f.lastLimit = limit
+	if f.err != nil {
+		return nil, f.err
+	}
+	if queueName != "" && f.archived[queueName] == nil {
+		return nil, fmt.Errorf("%w: %s", queue.ErrUnknownQueue, queueName)
+	}
+
+	var queues []queue.ArchivedQueue
+	for _, name := range queue.PipelineQueues() {
+		if tasks, ok := f.archived[name]; ok && (queueName == "" || queueName == name) {
+			queues = append(queues, queue.ArchivedQueue{Queue: name, Archived: len(tasks), Tasks: tasks})
+		}
+	}
+	return queues, nil
+}
+
+func (f *fakeFailedTasks) RetryArchivedTask(queueName, taskID string) (*queue.FailedTask, error) {
+	for i, task := range f.archived[queueName] {
+		if task.TaskID == taskID {
+			f.archived[queueName] = append(f.archived[queueName][:i], f.archived[queueName][i+1:]...)
+			return &task, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", queue.ErrTaskNotFound, taskID)
+}
+
+func (f *fakeFailedTasks) RetryAllArchivedTasks(queueName string) ([]queue.FailedTask, error) {
+	tasks, ok := f.archived[queueName]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", queue.ErrUnknownQueue, queueName)
+	}
+	f.archived[queueName] = []queue.FailedTask{}
+	return tasks, f.err
+}
+
+// setupFailedTasksHandler returns a stateless handler with two archived
+// text enrichment tasks and the admin token "secret"
+func setupFailedTasksHandler() (*Handler, *fakeFailedTasks) {
+	failed := &fakeFailedTasks{archived: map[string][]queue.FailedTask{
+		"text-enrichment": {
+			{TaskStatus: queue.TaskStatus{TaskID: "doc-1-text-enrich", Type: queue.TypeEnrichText, State: "archived", Retried: 10, LastError: "ollama timeout"},
+				Payload: queue.PayloadSummary{AnalysisID: "doc-1", TextLength: 1200}},
+			{TaskStatus: queue.TaskStatus{TaskID: "doc-2-text-enrich", Type: queue.TypeEnrichText, State: "archived", Retried: 10},
+				Payload: queue.PayloadSummary{AnalysisID: "doc-2", TextLength: 800}},
+		},
+		"image-enrichment-low": {},
+	}}
+
+	handler := setupStatelessHandler()
+	handler.inspector = failed
+	handler.adminToken = "secret"
+	return handler, failed
+}
+
+func failedTasksRequest(handler *Handler, method, path, token string) (*httptest.ResponseRecorder, map[string]interface{}) {
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return w, response
+}
+
+func TestFailedTasksAuth(t *testing.T) {
+	handler, _ := setupFailedTasksHandler()
+
+	for _, path := range []string{"/api/admin/failed-tasks", "/api/admin/failed-tasks/text-enrichment/retry"} {
+		method := http.MethodGet
+		if path != "/api/admin/failed-tasks" {
+			method = http.MethodPost
+		}
+		if w, _ := failedTasksRequest(handler, method, path, ""); w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected status 401 for %s without a token, got %d", path, w.Code)
+		}
+		if w, _ := failedTasksRequest(handler, method, path, "wrong"); w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected status 401 for %s with a wrong token, got %d", path, w.Code)
+		}
+	}
+
+	handler.adminToken = ""
+	if w, _ := failedTasksRequest(handler, http.MethodGet, "/api/admin/failed-tasks", "secret"); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 without a configured admin token, got %d", w.Code)
+	}
+}
+
+func TestFailedTasksRoutes(t *testing.T) {
+	handler, _ := setupFailedTasksHandler()
+
+	tests := []struct {
+		method string
+		path   string
+		code   int
+	}{
+		{http.MethodPost, "/api/admin/failed-tasks", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/api/admin/failed-tasks/text-enrichment/retry", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/api/admin/failed-tasks/text-enrichment", http.StatusNotFound},
+		{http.MethodPost, "/api/admin/failed-tasks/text-enrichment/doc-1-text-enrich/requeue", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if w, _ := failedTasksRequest(handler, tt.method, tt.path, "secret"); w.Code != tt.code {
+			t.Errorf("Expected status %d for %s %s, got %d", tt.code, tt.method, tt.path, w.Code)
+		}
+	}
+
+	// Inspectors that cannot manage archived tasks
+	handler.inspector = &fakeTaskInspector{}
+	if w, _ := failedTasksRequest(handler, http.MethodGet, "/api/admin/failed-tasks", "secret"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, got %d", w.Code)
+	}
+}
+
+func TestListFailedTasks(t *testing.T) {
+	handler, failed := setupFailedTasksHandler()
+
+	w, response := failedTasksRequest(handler, http.MethodGet, "/api/admin/failed-tasks", "secret")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	queues, _ := response["queues"].([]interface{})
+	if len(queues) != 2 || response["total_archived"] != float64(2) || failed.lastLimit != defaultFailedTaskLimit {
+		t.Fatalf("Expected 2 queues with 2 archived tasks at the default limit, got %v (limit %d)", response, failed.lastLimit)
+	}
+	text := queues[0].(map[string]interface{})
+	tasks, _ := text["tasks"].([]interface{})
+	first := tasks[0].(map[string]interface{})
+	payload, _ := first["payload"].(map[string]interface{})
+	if first["task_id"] != "doc-1-text-enrich" || first["last_error"] != "ollama timeout" || first["retried"] != float64(10) || payload["analysis_id"] != "doc-1" {
+		t.Errorf("Expected the archived task with its error, retries and payload summary, got %v", first)
+	}
+
+	w, response = failedTasksRequest(handler, http.MethodGet, "/api/admin/failed-tasks?queue=image-enrichment-low&limit=5000", "secret")
+	if queues, _ := response["queues"].([]interface{}); w.Code != http.StatusOK || len(queues) != 1 || failed.lastLimit != maxFailedTaskLimit {
+		t.Errorf("Expected one queue at the maximum limit, got %d %v (limit %d)", w.Code, response, failed.lastLimit)
+	}
+
+	for _, query := range []string{"queue=default", "limit=0", "limit=ten"} {
+		if w, _ := failedTasksRequest(handler, http.MethodGet, "/api/admin/failed-tasks?"+query, "secret"); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %q, got %d", query, w.Code)
+		}
+	}
+
+	failed.err = errors.New("connection refused")
+	if w, _ := failedTasksRequest(handler, http.MethodGet, "/api/admin/failed-tasks", "secret"); w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, got %d", w.Code)
+	}
+}
+
+func TestRetryFailedTask(t *testing.T) {
+	handler, failed := setupFailedTasksHandler()
+
+	w, response := failedTasksRequest(handler, http.MethodPost, "/api/admin/failed-tasks/text-enrichment/doc-1-text-enrich/retry", "secret")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	requeued, _ := response["requeued"].(map[string]interface{})
+	if requeued["task_id"] != "doc-1-text-enrich" || len(failed.archived["text-enrichment"]) != 1 {
+		t.Errorf("Expected doc-1-text-enrich requeued, got %v", response)
+	}
+
+	if w, _ := failedTasksRequest(handler, http.MethodPost, "/api/admin/failed-tasks/text-enrichment/doc-1-text-enrich/retry", "secret"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a task no longer archived, got %d", w.Code)
+	}
+}
+
+func TestRetryAllFailedTasks(t *testing.T) {
+	handler, failed := setupFailedTasksHandler()
+
+	w, response := failedTasksRequest(handler, http.MethodPost, "/api/admin/failed-tasks/text-enrichment/retry", "secret")
+	if w.Code != http.StatusOK || response["count"] != float64(2) {
+		t.Fatalf("Expected 2 tasks requeued, got %d %v", w.Code, response)
+	}
+	if len(failed.archived["text-enrichment"]) != 0 {
+		t.Errorf("Expected no archived tasks left, got %v", failed.archived["text-enrichment"])
+	}
+
+	if w, _ := failedTasksRequest(handler, http.MethodPost, "/api/admin/failed-tasks/default/retry", "secret"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown queue, got %d", w.Code)
+	}
+}
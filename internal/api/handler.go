@@ -5,42 +5,295 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"net"
 	"net/http"
+	"net/url"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/cors"
 	"github.com/docutag/platform/pkg/tracing"
 	"github.com/docutag/textanalyzer/internal/analyzer"
 	"github.com/docutag/textanalyzer/internal/database"
 	"github.com/docutag/textanalyzer/internal/models"
+	"github.com/docutag/textanalyzer/internal/queue"
 	"go.opentelemetry.io/otel/attribute"
 )
 
+// maxTextLength is the maximum number of characters accepted in a text field
+const maxTextLength = 1000000
+
+// queryTimeout bounds the database queries of a listing, search or analysis
+// lookup; they are cancelled with the request context when it ends sooner
+const queryTimeout = 30 * time.Second
+
+// defaultHeartbeatStaleAfter is how old a worker heartbeat may be before the
+// worker is considered stalled (three missed beats at the default interval)
+const defaultHeartbeatStaleAfter = 45 * time.Second
+
+// deepHealthTimeout bounds the dependency checks of GET /health?deep=true,
+// so the endpoint stays fast when a dependency hangs
+const deepHealthTimeout = 2 * time.Second
+
+// Default request size limits for /api/analyze and /api/segment
+const (
+	defaultMaxBodyBytes = 10 << 20 // JSON request body
+	defaultMaxHTMLBytes = 20 << 20 // original_html once decompressed
+)
+
+// Client metadata limits
+const (
+	maxClientMetadataKeys        = 20
+	maxClientMetadataValueLength = 256
+)
+
+// clientMetadataKeyPattern restricts client metadata keys to short
+// identifiers that are safe in query parameters and JSON paths
+var clientMetadataKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// maxCallbackURLLength is the longest callback_url accepted
+const maxCallbackURLLength = 2048
+
+// Analyses listing page sizes
+const (
+	defaultListLimit = 10
+	maxListLimit     = 100
+)
+
+// analysisPage is a page of the analyses listing with the total number of
+// analyses matching its filter. The listing sets NextCursor while analyses
+// remain after the page.
+type analysisPage struct {
+	Items      []*models.Analysis `json:"items"`
+	Total      int                `json:"total"`
+	Limit      int                `json:"limit"`
+	Offset     int                `json:"offset"`
+	HasMore    bool               `json:"has_more"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+}
+
+// Related analyses defaults
+const (
+	defaultRelatedAnalysesLimit = 10
+	maxRelatedAnalysesLimit     = 50
+)
+
+// searchPage is a page of full-text search matches, in the envelope of the
+// analyses listing
+type searchPage struct {
+	Items   []*models.SearchMatch `json:"items"`
+	Total   int                   `json:"total"`
+	Limit   int                   `json:"limit"`
+	Offset  int                   `json:"offset"`
+	HasMore bool                  `json:"has_more"`
+}
+
+// maxSearchQueryLength is the most characters accepted in a full-text search query
+const maxSearchQueryLength = 500
+
+// degradedWarning is returned with submissions accepted offline-only
+const degradedWarning = "The enrichment queue is saturated; only offline analysis will run"
+
+// languagePattern matches the languages recorded in metadata: ISO 639-1
+// codes, or "unknown"
+var languagePattern = regexp.MustCompile(`^([a-z]{2}|unknown)$`)
+
+// clientMetadataParamPrefix prefixes list query parameters that filter on
+// client metadata, e.g. ?client_metadata.customer=acme
+const clientMetadataParamPrefix = "client_metadata."
+
 // Handler handles HTTP requests
 type Handler struct {
 	db          *database.DB
 	analyzer    *analyzer.Analyzer
 	queueClient interface {
-		EnqueueProcessDocument(ctx context.Context, analysisID, text, originalHTML string, images []string) (string, error)
+		EnqueueProcessDocument(ctx context.Context, analysisID, text, originalHTML string, images []string, options models.ProcessingOptions) (string, error)
 	}
+	thresholds  *analyzer.EnrichmentThresholds
+	staleAfter  time.Duration
+	maxImages   int
+	truncate    bool
+	maxBody     int64
+	maxHTML     int64
+	enrichment  *models.EnrichmentOptions
+	inspector   TaskInspector
+	redactText  bool
+	queueDepth  QueueDepthProvider
+	relatedTags *relatedTagsCache
+	stats       *statsCache
+	pageFetcher PageFetcher
+	metrics     *Metrics
 	mux         *http.ServeMux
+
+	worker         WorkerConfigurer
+	workerSettings WorkerSettingsStore
+	adminToken     string
+
+	backPressure     BackPressureConfig
+	healthChecks     map[string]HealthCheck
+	privateCallbacks bool
+}
+
+// HealthCheck reports whether a dependency is reachable and usable
+type HealthCheck func(ctx context.Context) error
+
+// TaskInspector reports and cancels the queued tasks spawned for an analysis
+// and clears finished enrichment tasks before enrichment is enqueued again
+type TaskInspector interface {
+	FamilyTasks(analysisID string, imageCount int) ([]queue.TaskStatus, error)
+	CancelFamily(analysisID string, imageCount int) (*queue.CancelResult, error)
+	ClearEnrichmentTasks(analysisID string, imageCount int) error
+}
+
+// Config contains optional settings for the API handler
+type Config struct {
+	// EnrichmentThresholds maps request sources to AI enrichment thresholds.
+	// When nil, every request uses analyzer.DefaultEnrichmentThreshold.
+	EnrichmentThresholds *analyzer.EnrichmentThresholds
+
+	// HeartbeatStaleAfter is how old a worker heartbeat may be before /ready
+	// reports the worker as stalled (default: 45s)
+	HeartbeatStaleAfter time.Duration
+
+	// MaxImages is the most unique images accepted per analysis (default: analyzer.DefaultMaxImages)
+	MaxImages int
+
+	// TruncateImages accepts requests over MaxImages, keeping the first
+	// MaxImages images and returning a warning, instead of rejecting them
+	TruncateImages bool
+
+	// MaxBodyBytes is the largest request body accepted by /api/analyze,
+	// /api/analyze/batch and /api/segment (default: 10 MiB)
+	MaxBodyBytes int64
+
+	// MaxHTMLBytes is the largest original_html accepted once decompressed
+	// (default: 20 MiB)
+	MaxHTMLBytes int64
+
+	// EnrichmentSteps are the AI enrichment steps run when a request does not
+	// override them. When nil, every step runs.
+	EnrichmentSteps *models.EnrichmentOptions
+
+	// Inspector looks up queued tasks for GET /api/jobs/{id},
+	// GET /api/jobs/{id}/tasks and POST /api/analyses/{id}/enrich, and deletes
+	// them for DELETE /api/jobs/{id}. When nil, job statuses are read from the
+	// database alone and the task listing, cancellation and enrichment
+	// respond 503.
+	Inspector TaskInspector
+
+	// RedactText stores only derived metadata for requests that do not set
+	// store_text, replacing the text with its hash
+	RedactText bool
+
+	// AllowPrivateCallbacks accepts callback URLs whose host is a literal
+	// private, loopback or link-local address, for development and tests.
+	// Host names are checked when notifications are delivered.
+	AllowPrivateCallbacks bool
+
+	// Worker is reconfigured through /api/admin/worker/config. When nil,
+	// that endpoint responds 503.
+	Worker WorkerConfigurer
+
+	// AdminToken is the bearer token required by the /api/admin endpoints
+	// that change or report on the worker. When empty, they respond 403.
+	AdminToken string
+
+	// QueueDepth reports cached queue depths for back-pressure checks. When
+	// nil, submissions are never limited.
+	QueueDepth QueueDepthProvider
+
+	// BackPressure limits submissions while the queues are backed up
+	BackPressure BackPressureConfig
+
+	// PageFetcher fetches the pages submitted to /api/analyze/url. When nil,
+	// that endpoint responds 503.
+	PageFetcher PageFetcher
+
+	// Metrics records request durations and enqueue outcomes. When nil,
+	// collectors are registered with the default Prometheus registerer.
+	Metrics *Metrics
+
+	// HealthChecks are the dependencies checked by GET /health?deep=true
+	// besides the database, by name, e.g. "redis" and "ollama"
+	HealthChecks map[string]HealthCheck
 }
 
 // NewHandler creates a new API handler with CORS support and metrics
-func NewHandler(db *database.DB, analyzer *analyzer.Analyzer, queueClient interface {
-	EnqueueProcessDocument(ctx context.Context, analysisID, text, originalHTML string, images []string) (string, error)
-}) http.Handler {
+func NewHandler(db *database.DB, textAnalyzer *analyzer.Analyzer, queueClient interface {
+	EnqueueProcessDocument(ctx context.Context, analysisID, text, originalHTML string, images []string, options models.ProcessingOptions) (string, error)
+}, cfg Config) http.Handler {
 	// Initialize Prometheus metrics
+	apiMetrics := cfg.Metrics
+	if apiMetrics == nil {
+		apiMetrics = NewMetrics(prometheus.DefaultRegisterer)
+	}
+
+	staleAfter := cfg.HeartbeatStaleAfter
+	if staleAfter <= 0 {
+		staleAfter = defaultHeartbeatStaleAfter
+	}
+
+	maxImages := cfg.MaxImages
+	if maxImages <= 0 {
+		maxImages = analyzer.DefaultMaxImages
+	}
+
+	maxBody := cfg.MaxBodyBytes
+	if maxBody <= 0 {
+		maxBody = defaultMaxBodyBytes
+	}
+
+	maxHTML := cfg.MaxHTMLBytes
+	if maxHTML <= 0 {
+		maxHTML = defaultMaxHTMLBytes
+	}
+
+	backPressure := cfg.BackPressure
+	if backPressure.Mode == "" {
+		backPressure.Mode = BackPressureOff
+	}
+	if backPressure.RetryAfter <= 0 {
+		backPressure.RetryAfter = defaultRetryAfter
+	}
 
 	h := &Handler{
 		db:          db,
-		analyzer:    analyzer,
+		analyzer:    textAnalyzer,
 		queueClient: queueClient,
+		thresholds:  cfg.EnrichmentThresholds,
+		staleAfter:  staleAfter,
+		maxImages:   maxImages,
+		truncate:    cfg.TruncateImages,
+		maxBody:     maxBody,
+		maxHTML:     maxHTML,
+		enrichment:  cfg.EnrichmentSteps,
+		inspector:   cfg.Inspector,
+		redactText:  cfg.RedactText,
+		queueDepth:  cfg.QueueDepth,
+		relatedTags: newRelatedTagsCache(relatedTagsCacheTTL),
+		stats:       newStatsCache(statsCacheTTL),
+		pageFetcher: cfg.PageFetcher,
+		metrics:     apiMetrics,
 		mux:         http.NewServeMux(),
+		worker:      cfg.Worker,
+		adminToken:  cfg.AdminToken,
+
+		backPressure:     backPressure,
+		healthChecks:     cfg.HealthChecks,
+		privateCallbacks: cfg.AllowPrivateCallbacks,
+	}
+	if db != nil {
+		h.workerSettings = db
 	}
 
 	h.setupRoutes()
@@ -60,19 +313,53 @@ func NewHandler(db *database.DB, analyzer *analyzer.Analyzer, queueClient interf
 
 // setupRoutes configures all API routes
 func (h *Handler) setupRoutes() {
-	h.mux.Handle("/metrics", promhttp.Handler()) // Prometheus metrics endpoint
-	h.mux.HandleFunc("/api/analyze", h.handleAnalyze)
-	h.mux.HandleFunc("/api/jobs/", h.handleJobStatus)
-	h.mux.HandleFunc("/api/analyses", h.handleListAnalyses)
-	h.mux.HandleFunc("/api/analyses/", h.handleAnalysisOperations)
-	h.mux.HandleFunc("/api/uuid/", h.handleUUIDOperations)
-	h.mux.HandleFunc("/api/search", h.handleSearchByTag)
-	h.mux.HandleFunc("/api/search/reference", h.handleSearchByReference)
-	h.mux.HandleFunc("/health", h.handleHealth)
-}
-
-// handleHealth handles health check requests
+	// Prometheus metrics endpoint; OpenMetrics is needed to expose exemplars
+	h.mux.Handle("/metrics", promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{EnableOpenMetrics: true})))
+
+	// API routes record their durations, labeled by route pattern
+	routes := []struct {
+		pattern string
+		handler http.HandlerFunc
+	}{
+		{"/api/analyze", h.handleAnalyze},
+		{"/api/analyze/batch", h.handleAnalyzeBatch},
+		{"/api/analyze/url", h.handleAnalyzeURL},
+		{"/api/segment", h.handleSegment},
+		{"/api/jobs/", h.handleJobStatus},
+		{"/api/analyses", h.handleListAnalyses},
+		{"/api/analyses/", h.handleAnalysisOperations},
+		{"/api/uuid/", h.handleUUIDOperations},
+		{"/api/tags", h.handleListTags},
+		{"/api/tags/rename", h.handleRenameTag},
+		{"/api/tags/", h.handleTagOperations},
+		{"/api/search", h.handleSearchByTag},
+		{"/api/search/reference", h.handleSearchByReference},
+		{"/api/search/entity", h.handleSearchByEntity},
+		{"/api/search/text", h.handleSearchText},
+		{"/api/sources", h.handleSourceHistory},
+		{"/api/stats", h.handleStats},
+		{"/api/admin/queue", h.handleAdminQueue},
+		{"/api/admin/worker/config", h.handleWorkerConfig},
+		{failedTasksPath, h.handleFailedTasks},
+		{failedTasksPath + "/", h.handleFailedTasks},
+		{"/api/admin/shadow/report", h.handleShadowReport},
+		{"/health", h.handleHealth},
+		{"/ready", h.handleReady},
+	}
+	for _, route := range routes {
+		h.mux.HandleFunc(route.pattern, h.instrument(route.pattern, route.handler))
+	}
+}
+
+// handleHealth handles health check requests. With deep=true, the database
+// and the configured dependencies are also checked.
 func (h *Handler) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("deep") == "true" {
+		h.handleDeepHealth(w, r)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{
 		"status": "ok",
@@ -80,343 +367,1524 @@ func (h *Handler) handleHealth(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// handleAnalyze handles text analysis requests - now queue-based
-func (h *Handler) handleAnalyze(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
+// handleDeepHealth checks every dependency concurrently within
+// deepHealthTimeout, responding 503 when any of them fails
+func (h *Handler) handleDeepHealth(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), deepHealthTimeout)
+	defer cancel()
 
-	var req struct {
-		Text         string   `json:"text"`
-		OriginalHTML string   `json:"original_html,omitempty"` // Compressed + base64 encoded original HTML/raw text
-		Images       []string `json:"images,omitempty"`
+	checks := map[string]HealthCheck{
+		"database": func(ctx context.Context) error {
+			return h.db.Conn().PingContext(ctx)
+		},
+	}
+	for name, check := range h.healthChecks {
+		checks[name] = check
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondError(w, "Invalid request body", http.StatusBadRequest)
-		return
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results = make(map[string]string, len(checks))
+	)
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check HealthCheck) {
+			defer wg.Done()
+			result := "ok"
+			if err := check(ctx); err != nil {
+				result = err.Error()
+			}
+			mu.Lock()
+			results[name] = result
+			mu.Unlock()
+		}(name, check)
 	}
+	wg.Wait()
 
-	if req.Text == "" {
-		respondError(w, "Text field is required", http.StatusBadRequest)
-		return
+	status, code := "ok", http.StatusOK
+	for _, result := range results {
+		if result != "ok" {
+			status, code = "degraded", http.StatusServiceUnavailable
+		}
 	}
+	respondJSON(w, map[string]interface{}{
+		"status": status,
+		"checks": results,
+		"time":   time.Now().Format(time.RFC3339),
+	}, code)
+}
 
-	// Add text length to span
-	tracing.SetSpanAttributes(r.Context(),
-		attribute.Int("text.length", len(req.Text)),
-		attribute.Int("images.count", len(req.Images)))
+// workerStatus is a worker heartbeat annotated with its age
+type workerStatus struct {
+	*models.WorkerHeartbeat
+	AgeSeconds float64 `json:"age_seconds"`
+	Stale      bool    `json:"stale"`
+}
 
-	// Generate analysis ID
-	analysisID := generateID()
+// workerStatuses annotates heartbeats with their age and whether they are stale
+func workerStatuses(heartbeats []*models.WorkerHeartbeat, staleAfter time.Duration, now time.Time) []workerStatus {
+	statuses := make([]workerStatus, 0, len(heartbeats))
+	for _, hb := range heartbeats {
+		age := now.Sub(hb.HeartbeatAt)
+		statuses = append(statuses, workerStatus{
+			WorkerHeartbeat: hb,
+			AgeSeconds:      age.Seconds(),
+			Stale:           age > staleAfter,
+		})
+	}
+	return statuses
+}
 
-	// Enqueue document processing task
-	ctx := r.Context()
-	taskID, err := h.queueClient.EnqueueProcessDocument(ctx, analysisID, req.Text, req.OriginalHTML, req.Images)
+// handleReady reports whether the service can do work: the database must be
+// reachable and at least one queue worker must have a fresh heartbeat
+func (h *Handler) handleReady(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	checks := map[string]string{}
+
+	if err := h.db.Conn().PingContext(ctx); err != nil {
+		checks["database"] = err.Error()
+		checks["worker"] = "unknown"
+		respondJSON(w, map[string]interface{}{
+			"status": "not_ready",
+			"checks": checks,
+		}, http.StatusServiceUnavailable)
+		return
+	}
+	checks["database"] = "ok"
+
+	heartbeats, err := h.db.ListWorkerHeartbeats(ctx)
 	if err != nil {
-		respondError(w, fmt.Sprintf("Failed to enqueue analysis: %v", err), http.StatusInternalServerError)
+		respondError(w, err.Error(), http.StatusServiceUnavailable)
 		return
 	}
 
-	// Return job ID immediately
+	workers := workerStatuses(heartbeats, h.staleAfter, time.Now())
+	checks["worker"] = "no heartbeat"
+	for _, worker := range workers {
+		if !worker.Stale {
+			checks["worker"] = "ok"
+			break
+		}
+		checks["worker"] = "stale"
+	}
+
+	status, code := "ready", http.StatusOK
+	if checks["worker"] != "ok" {
+		status, code = "not_ready", http.StatusServiceUnavailable
+	}
 	respondJSON(w, map[string]interface{}{
-		"job_id":   analysisID,
-		"task_id":  taskID,
-		"status":   "queued",
-		"message":  "Analysis queued for processing",
-	}, http.StatusAccepted)
+		"status":  status,
+		"checks":  checks,
+		"workers": workers,
+	}, code)
 }
 
-// handleJobStatus handles job status requests
-func (h *Handler) handleJobStatus(w http.ResponseWriter, r *http.Request) {
+// handleAdminQueue reports the liveness and task counts of every queue worker
+func (h *Handler) handleAdminQueue(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Extract job ID from path
-	jobID := r.URL.Path[len("/api/jobs/"):]
-	if idx := strings.Index(jobID, "/"); idx != -1 {
-		jobID = jobID[:idx]
-	}
-
-	if jobID == "" {
-		respondError(w, "Job ID is required", http.StatusBadRequest)
-		return
-	}
-
-	// Try to retrieve the analysis
-	analysis, err := h.db.GetAnalysis(jobID)
+	heartbeats, err := h.db.ListWorkerHeartbeats(r.Context())
 	if err != nil {
-		if err.Error() == "analysis not found" {
-			respondJSON(w, map[string]interface{}{
-				"job_id": jobID,
-				"status": "not_found",
-				"message": "Analysis not found - it may still be queued or has expired",
-			}, http.StatusNotFound)
-			return
-		}
 		respondError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// Determine status based on analysis metadata
-	status := "completed"
-	if analysis.Metadata.Synopsis == "" && analysis.Metadata.CleanedText == "" {
-		// No AI enrichment yet
-		if analysis.Metadata.QualityScore != nil && analysis.Metadata.QualityScore.Score < 0.35 {
-			status = "completed_offline_only" // Below threshold, won't be enriched
-		} else {
-			status = "processing" // Offline complete, AI enrichment pending/in progress
-		}
-	}
+	respondJSON(w, map[string]interface{}{
+		"workers":             workerStatuses(heartbeats, h.staleAfter, time.Now()),
+		"stale_after_seconds": h.staleAfter.Seconds(),
+	}, http.StatusOK)
+}
 
-	response := map[string]interface{}{
-		"job_id":     jobID,
-		"status":     status,
-		"created_at": analysis.CreatedAt,
-		"updated_at": analysis.UpdatedAt,
-	}
+// analyzeRequest is the body of POST /api/analyze, and one document of
+// POST /api/analyze/batch
+type analyzeRequest struct {
+	Text         string   `json:"text"`
+	OriginalHTML string   `json:"original_html,omitempty"` // Compressed + base64 encoded original HTML/raw text
+	Images       []string `json:"images,omitempty"`
+	// Optional enrichment gating: an explicit threshold overrides the one configured for the source
+	Source              string   `json:"source,omitempty"`
+	EnrichmentThreshold *float64 `json:"enrichment_threshold,omitempty"`
+	// Run AI enrichment whatever the quality score, or never enqueue it
+	ForceEnrichment bool `json:"force_enrichment,omitempty"`
+	SkipEnrichment  bool `json:"skip_enrichment,omitempty"`
+	// Queue priority: "high" for interactive submissions, "low" for bulk backfill (default: "normal")
+	Priority string `json:"priority,omitempty"`
+	// Synopsis length and style: "teaser", "standard" (default) or "abstract"
+	SynopsisStyle    string `json:"synopsis_style,omitempty"`
+	SynopsisMaxWords int    `json:"synopsis_max_words,omitempty"`
+	// AI enrichment steps to enable or disable, e.g. {"editorial": false}
+	Enrichment map[string]bool `json:"enrichment,omitempty"`
+	// Caller identifiers returned with the analysis, e.g. {"crawl_id": "42"}
+	ClientMetadata map[string]string `json:"client_metadata,omitempty"`
+	// Page the text came from, and where it was fetched from after
+	// redirects; analyses of the same page are grouped by its normalized URL
+	SourceURL  string `json:"source_url,omitempty"`
+	FetchedURL string `json:"fetched_url,omitempty"`
+	// Summarize top words, key terms and sentiment per section
+	Sections bool `json:"sections,omitempty"`
+	// Set store_text to false to keep only derived metadata; store_cleaned_text
+	// false then drops the cleaned text too
+	StoreText        *bool `json:"store_text,omitempty"`
+	StoreCleanedText *bool `json:"store_cleaned_text,omitempty"`
+	// Analyze the text even when an identical text was analyzed before
+	Force bool `json:"force,omitempty"`
+	// URL notified with a POST once processing reaches a terminal state
+	CallbackURL string `json:"callback_url,omitempty"`
+	// Mask personal data in the text sent to the model during AI enrichment
+	RedactPII bool `json:"redact_pii,omitempty"`
+	// Text format: "markdown", "text" or "auto" (default) to detect Markdown
+	Format string `json:"format,omitempty"`
+}
 
-	// Include analysis if completed
-	if status == "completed" || status == "completed_offline_only" {
-		response["analysis"] = analysis
-	}
+// deduplicable reports whether an earlier analysis of the same text can
+// answer the request. A request asking to be notified, or setting options
+// that change the processing, is analyzed again so they are honoured.
+func (r *analyzeRequest) deduplicable() bool {
+	return r.CallbackURL == "" && r.EnrichmentThreshold == nil && !r.ForceEnrichment && !r.SkipEnrichment &&
+		r.SynopsisStyle == "" && r.SynopsisMaxWords == 0 && len(r.Enrichment) == 0 && !r.Sections && !r.RedactPII &&
+		(r.Format == "" || r.Format == analyzer.FormatAuto)
+}
 
-	respondJSON(w, response, http.StatusOK)
+// requestError is an invalid analysis request and the status it is rejected with
+type requestError struct {
+	message string
+	status  int
 }
 
-// handleListAnalyses handles listing all analyses with pagination
-func (h *Handler) handleListAnalyses(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+func (e *requestError) Error() string {
+	return e.message
+}
+
+// badRequest returns a requestError responded to with 400 Bad Request
+func badRequest(message string) *requestError {
+	return &requestError{message: message, status: http.StatusBadRequest}
+}
+
+// preparedAnalysis is a validated analysis request, ready to enqueue
+type preparedAnalysis struct {
+	req           *analyzeRequest
+	images        []string // Unique images within the limit
+	imagesSkipped int
+	enrichment    models.EnrichmentOptions
+	threshold     float64
+	options       models.ProcessingOptions
+	warnings      []string
+}
+
+// prepareAnalysis validates an analysis request and resolves its processing
+// options. The caller sets options.OfflineOnly from the back-pressure decision.
+func (h *Handler) prepareAnalysis(req *analyzeRequest) (*preparedAnalysis, *requestError) {
+	if err := checkText(req.Text); err != nil {
+		return nil, err
 	}
 
-	limit := 10
-	offset := 0
+	enrichment, err := analyzer.ApplyEnrichmentOverrides(analyzer.ResolveEnrichment(h.enrichment), req.Enrichment)
+	if err != nil {
+		return nil, badRequest("Invalid enrichment: " + err.Error())
+	}
 
-	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
-			limit = l
-		}
+	if err := h.checkOriginalHTML(req.OriginalHTML); err != nil {
+		return nil, err
 	}
 
-	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
-		if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
-			offset = o
-		}
+	if err := validateClientMetadata(req.ClientMetadata); err != nil {
+		return nil, badRequest("Invalid client_metadata: " + err.Error())
 	}
 
-	// Fetch analyses in a goroutine
-	resultChan := make(chan []*models.Analysis)
-	errorChan := make(chan error)
+	if err := validateCallbackURL(req.CallbackURL, h.privateCallbacks); err != nil {
+		return nil, badRequest("Invalid callback_url: " + err.Error())
+	}
 
-	go func() {
-		analyses, err := h.db.ListAnalyses(limit, offset)
-		if err != nil {
-			errorChan <- err
-			return
-		}
-		resultChan <- analyses
-	}()
+	sourceURL, err := resolveSourceURL(req.SourceURL, req.FetchedURL, req.ClientMetadata)
+	if err != nil {
+		return nil, badRequest("Invalid source_url: " + err.Error())
+	}
 
-	select {
-	case analyses := <-resultChan:
-		respondJSON(w, analyses, http.StatusOK)
-	case err := <-errorChan:
-		respondError(w, err.Error(), http.StatusInternalServerError)
-	case <-time.After(30 * time.Second):
-		respondError(w, "Request timeout", http.StatusRequestTimeout)
+	if req.EnrichmentThreshold != nil {
+		if err := analyzer.ValidateThreshold(*req.EnrichmentThreshold); err != nil {
+			return nil, badRequest("Invalid enrichment_threshold: " + err.Error())
+		}
+	}
+	if req.ForceEnrichment && req.SkipEnrichment {
+		return nil, badRequest("Invalid skip_enrichment: cannot be combined with force_enrichment")
 	}
-}
 
-// handleAnalysisOperations handles GET and DELETE for specific analyses
-func (h *Handler) handleAnalysisOperations(w http.ResponseWriter, r *http.Request) {
-	id := r.URL.Path[len("/api/analyses/"):]
-	if id == "" {
-		respondError(w, "Analysis ID is required", http.StatusBadRequest)
-		return
+	if err := analyzer.ValidateFormat(req.Format); err != nil {
+		return nil, badRequest("Invalid format: " + err.Error())
 	}
 
-	switch r.Method {
-	case http.MethodGet:
-		h.getAnalysis(w, r, id)
-	case http.MethodDelete:
-		h.deleteAnalysis(w, r, id)
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	priority, err := queue.ParsePriority(req.Priority)
+	if err != nil {
+		return nil, badRequest("Invalid priority: " + err.Error())
 	}
-}
 
-// getAnalysis retrieves a specific analysis
-func (h *Handler) getAnalysis(w http.ResponseWriter, r *http.Request, id string) {
-	resultChan := make(chan *models.Analysis)
-	errorChan := make(chan error)
+	synopsis := analyzer.SynopsisOptions{Style: req.SynopsisStyle, MaxWords: req.SynopsisMaxWords}
+	if err := analyzer.ValidateSynopsisOptions(synopsis); err != nil {
+		return nil, badRequest(err.Error())
+	}
 
-	go func() {
-		analysis, err := h.db.GetAnalysis(id)
-		if err != nil {
-			errorChan <- err
-			return
+	for _, imageURL := range req.Images {
+		if _, err := analyzer.ValidateImageURL(imageURL); err != nil {
+			return nil, badRequest(err.Error())
 		}
-		resultChan <- analysis
-	}()
+	}
 
-	select {
-	case analysis := <-resultChan:
-		respondJSON(w, analysis, http.StatusOK)
-	case err := <-errorChan:
-		if err.Error() == "analysis not found" {
-			respondError(w, err.Error(), http.StatusNotFound)
-		} else {
-			respondError(w, err.Error(), http.StatusInternalServerError)
+	// Drop duplicate images and enforce the per-analysis limit
+	images := analyzer.SelectImageURLs(req.Images, h.maxImages)
+	var warnings []string
+	if images.Overflow > 0 {
+		unique := len(images.Accepted) + images.Overflow
+		if !h.truncate {
+			return nil, badRequest(fmt.Sprintf("Too many images: %d unique images submitted, maximum is %d", unique, h.maxImages))
 		}
-	case <-time.After(30 * time.Second):
-		respondError(w, "Request timeout", http.StatusRequestTimeout)
+		warnings = append(warnings, fmt.Sprintf("Only the first %d of %d unique images will be processed", h.maxImages, unique))
 	}
-}
 
-// deleteAnalysis deletes a specific analysis
-func (h *Handler) deleteAnalysis(w http.ResponseWriter, r *http.Request, id string) {
-	errorChan := make(chan error)
-	doneChan := make(chan bool)
-
-	go func() {
-		if err := h.db.DeleteAnalysis(id); err != nil {
-			errorChan <- err
-			return
-		}
-		doneChan <- true
-	}()
+	redact := h.redactText
+	if req.StoreText != nil {
+		redact = !*req.StoreText
+	}
 
-	select {
-	case <-doneChan:
-		w.WriteHeader(http.StatusNoContent)
-	case err := <-errorChan:
-		if err.Error() == "analysis not found" {
-			respondError(w, err.Error(), http.StatusNotFound)
-		} else {
-			respondError(w, err.Error(), http.StatusInternalServerError)
-		}
-	case <-time.After(30 * time.Second):
-		respondError(w, "Request timeout", http.StatusRequestTimeout)
+	// A submission that stores no text keeps no fingerprint of it either, so
+	// it is never matched by deduplication
+	var textHash string
+	if !redact {
+		textHash = database.TextHash(req.Text)
 	}
+
+	threshold := h.thresholds.Resolve(req.Source, req.EnrichmentThreshold)
+	return &preparedAnalysis{
+		req:           req,
+		images:        images.Accepted,
+		imagesSkipped: images.Skipped(),
+		enrichment:    enrichment,
+		threshold:     threshold,
+		options: models.ProcessingOptions{
+			Source:              req.Source,
+			SourceURL:           sourceURL,
+			TextHash:            textHash,
+			CallbackURL:         strings.TrimSpace(req.CallbackURL),
+			EnrichmentThreshold: &threshold,
+			ForceEnrichment:     req.ForceEnrichment,
+			SkipEnrichment:      req.SkipEnrichment,
+			RedactPII:           req.RedactPII,
+			Priority:            priority,
+			SynopsisStyle:       synopsis.Style,
+			SynopsisMaxWords:    synopsis.MaxWords,
+			ImagesSubmitted:     len(req.Images),
+			Enrichment:          &enrichment,
+			ClientMetadata:      req.ClientMetadata,
+			Sections:            req.Sections,
+			Format:              req.Format,
+			RedactText:          redact,
+			DropCleanedText:     redact && req.StoreCleanedText != nil && !*req.StoreCleanedText,
+		},
+		warnings: warnings,
+	}, nil
 }
 
-// handleUUIDOperations handles GET and DELETE for analyses by UUID
-func (h *Handler) handleUUIDOperations(w http.ResponseWriter, r *http.Request) {
-	uuid := r.URL.Path[len("/api/uuid/"):]
-	if uuid == "" {
-		respondError(w, "UUID is required", http.StatusBadRequest)
+// handleAnalyze handles text analysis requests - now queue-based
+func (h *Handler) handleAnalyze(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	switch r.Method {
-	case http.MethodGet:
-		h.getAnalysisByUUID(w, uuid)
-	case http.MethodDelete:
-		h.deleteAnalysisByUUID(w, uuid)
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	// Reject oversized bodies before they are decoded and enqueued
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxBody)
+
+	var req analyzeRequest
+	if reqErr := decodeAnalyzeRequest(r, &req); reqErr != nil {
+		respondError(w, reqErr.message, reqErr.status)
+		return
 	}
+
+	h.submitAnalysis(w, r, &req)
 }
 
-// getAnalysisByUUID retrieves an analysis by UUID
-func (h *Handler) getAnalysisByUUID(w http.ResponseWriter, uuid string) {
-	resultChan := make(chan *models.Analysis)
-	errorChan := make(chan error)
+// submitAnalysis validates an analysis request and enqueues it, responding
+// with the queued job. extraWarnings are returned with the job's own warnings.
+func (h *Handler) submitAnalysis(w http.ResponseWriter, r *http.Request, req *analyzeRequest, extraWarnings ...string) {
+	prepared, reqErr := h.prepareAnalysis(req)
+	if reqErr != nil {
+		respondError(w, reqErr.message, reqErr.status)
+		return
+	}
 
-	go func() {
-		analysis, err := h.db.GetAnalysisByUUID(uuid)
-		if err != nil {
-			errorChan <- err
+	// Answer a resubmitted text with its earlier analysis
+	if !req.Force && req.deduplicable() && prepared.options.TextHash != "" {
+		if existing := h.findDuplicate(r.Context(), prepared.options.TextHash); existing != nil {
+			respondJSON(w, map[string]interface{}{
+				"job_id":       existing.ID,
+				"status":       "deduplicated",
+				"message":      "Identical text was already analyzed; set force to analyze it again",
+				"deduplicated": true,
+				"text_hash":    existing.TextHash,
+				"created_at":   existing.CreatedAt,
+			}, http.StatusOK)
 			return
 		}
-		resultChan <- analysis
-	}()
+	}
 
-	select {
-	case analysis := <-resultChan:
-		respondJSON(w, analysis, http.StatusOK)
-	case err := <-errorChan:
-		if err.Error() == "analysis not found" {
-			respondError(w, err.Error(), http.StatusNotFound)
-		} else {
-			respondError(w, err.Error(), http.StatusInternalServerError)
-		}
-	case <-time.After(30 * time.Second):
-		respondError(w, "Request timeout", http.StatusRequestTimeout)
+	// Shed or degrade load while the queues are backed up
+	admitted := h.admit()
+	if admitted.decision == decisionRejected {
+		h.rejectSaturated(w, admitted)
+		return
 	}
-}
+	options := prepared.options
+	options.OfflineOnly = admitted.decision == decisionDegraded
 
-// deleteAnalysisByUUID deletes an analysis by UUID
-func (h *Handler) deleteAnalysisByUUID(w http.ResponseWriter, uuid string) {
-	errorChan := make(chan error)
-	doneChan := make(chan bool)
+	// Add text length to span
+	tracing.SetSpanAttributes(r.Context(),
+		attribute.Int("text.length", len(req.Text)),
+		attribute.Int("images.count", len(req.Images)),
+		attribute.String("priority", options.Priority))
 
-	go func() {
-		if err := h.db.DeleteAnalysisByUUID(uuid); err != nil {
-			errorChan <- err
-			return
-		}
-		doneChan <- true
-	}()
+	// Generate analysis ID
+	analysisID := generateID()
 
-	select {
-	case <-doneChan:
-		w.WriteHeader(http.StatusNoContent)
-	case err := <-errorChan:
-		if err.Error() == "analysis not found" {
-			respondError(w, err.Error(), http.StatusNotFound)
-		} else {
-			respondError(w, err.Error(), http.StatusInternalServerError)
+	// Enqueue document processing task
+	ctx := r.Context()
+	taskID, err := h.queueClient.EnqueueProcessDocument(ctx, analysisID, req.Text, req.OriginalHTML, prepared.images, options)
+	if err != nil {
+		h.metrics.observeEnqueueError(err)
+		respondError(w, fmt.Sprintf("Failed to enqueue analysis: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Return job ID immediately
+	response := map[string]interface{}{
+		"job_id":               analysisID,
+		"task_id":              taskID,
+		"status":               "queued",
+		"message":              "Analysis queued for processing",
+		"enrichment_threshold": prepared.threshold,
+		"priority":             options.Priority,
+		"images_accepted":      len(prepared.images),
+		"images_skipped":       prepared.imagesSkipped,
+		"enrichment":           prepared.enrichment,
+	}
+	if len(req.ClientMetadata) > 0 {
+		response["client_metadata"] = req.ClientMetadata
+	}
+	if options.SourceURL != "" {
+		response["source_url"] = options.SourceURL
+	}
+	if options.CallbackURL != "" {
+		response["callback_url"] = options.CallbackURL
+	}
+	if options.RedactText {
+		response["store_text"] = false
+	}
+	if options.ForceEnrichment {
+		response["force_enrichment"] = true
+	}
+	if options.SkipEnrichment {
+		response["skip_enrichment"] = true
+	}
+	if options.RedactPII {
+		response["redact_pii"] = true
+	}
+	warnings := append(extraWarnings, prepared.warnings...)
+	if options.OfflineOnly {
+		response["degraded"] = true
+		warnings = append(warnings, degradedWarning)
+	}
+	if len(warnings) > 0 {
+		response["warnings"] = warnings
+	}
+	respondJSON(w, response, http.StatusAccepted)
+}
+
+// findDuplicate returns the analysis holding a text hash, or nil when there is
+// none. Lookup failures are logged and treated as no match, so the text is
+// analyzed again rather than rejected.
+func (h *Handler) findDuplicate(ctx context.Context, textHash string) *models.Analysis {
+	if h.db == nil {
+		return nil
+	}
+	existing, err := h.db.GetAnalysisByTextHash(ctx, textHash)
+	if err != nil {
+		if !errors.Is(err, database.ErrNotFound) {
+			slog.Warn("failed to look up analysis by text hash", "text_hash", textHash, "error", err)
 		}
-	case <-time.After(30 * time.Second):
-		respondError(w, "Request timeout", http.StatusRequestTimeout)
+		return nil
 	}
+	return existing
 }
 
-// handleSearchByTag handles searching analyses by tag
-func (h *Handler) handleSearchByTag(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
+// handleSegment splits text into sentences and paragraphs synchronously.
+// Segmentation is cheap and stateless, so nothing is queued or persisted.
+func (h *Handler) handleSegment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	tag := r.URL.Query().Get("tag")
-	if tag == "" {
-		respondError(w, "Tag parameter is required", http.StatusBadRequest)
+	// Accepts the same bodies as /api/analyze, reading only the text
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxBody)
+
+	var req analyzeRequest
+	if reqErr := decodeAnalyzeRequest(r, &req); reqErr != nil {
+		respondError(w, reqErr.message, reqErr.status)
 		return
 	}
 
-	// Search in a goroutine
-	resultChan := make(chan []*models.Analysis)
-	errorChan := make(chan error)
+	if !validateText(w, req.Text) {
+		return
+	}
 
-	go func() {
-		analyses, err := h.db.GetAnalysesByTag(tag)
-		if err != nil {
-			errorChan <- err
-			return
-		}
-		resultChan <- analyses
-	}()
+	segmentation := h.analyzer.Segment(req.Text)
 
-	select {
-	case analyses := <-resultChan:
-		respondJSON(w, analyses, http.StatusOK)
-	case err := <-errorChan:
-		respondError(w, err.Error(), http.StatusInternalServerError)
-	case <-time.After(30 * time.Second):
-		respondError(w, "Request timeout", http.StatusRequestTimeout)
-	}
+	respondJSON(w, map[string]interface{}{
+		"sentences":       segmentation.Sentences,
+		"paragraphs":      segmentation.Paragraphs,
+		"sentence_count":  len(segmentation.Sentences),
+		"paragraph_count": len(segmentation.Paragraphs),
+	}, http.StatusOK)
 }
 
-// handleSearchByReference handles searching analyses by reference text
-func (h *Handler) handleSearchByReference(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
+// handleJobStatus handles job status and cancellation requests
+//
+//	GET /api/jobs/{id}
+//	GET /api/jobs/{id}/tasks
+//	DELETE /api/jobs/{id}
+func (h *Handler) handleJobStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract job ID from path
+	jobID := r.URL.Path[len("/api/jobs/"):]
+	suffix := ""
+	if idx := strings.Index(jobID, "/"); idx != -1 {
+		jobID, suffix = jobID[:idx], jobID[idx+1:]
+	}
+
+	if jobID == "" {
+		respondError(w, "Job ID is required", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		if suffix != "" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.cancelJob(w, r, jobID)
+		return
+	}
+
+	if suffix == "tasks" {
+		h.listJobTasks(w, r, jobID)
+		return
+	}
+
+	// Try to retrieve the analysis; it is saved once offline processing ends
+	analysis, err := h.db.GetAnalysis(r.Context(), jobID)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		respondError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	queueState := h.inspectJob(jobID, analysis)
+
+	if analysis == nil {
+		if queueState.status == "" {
+			response := map[string]interface{}{
+				"job_id":  jobID,
+				"status":  "not_found",
+				"message": "Analysis not found - it may still be queued or has expired",
+			}
+			queueState.addTo(response)
+			respondJSON(w, response, http.StatusNotFound)
+			return
+		}
+
+		response := map[string]interface{}{
+			"job_id": jobID,
+			"status": queueState.status,
+		}
+		queueState.addTo(response)
+		respondJSON(w, response, http.StatusOK)
+		return
+	}
+
+	// Analyses saved before thresholds were recorded are judged by the
+	// threshold configured for their source
+	threshold := h.thresholds.Resolve(analysis.Metadata.Source, analysis.Metadata.EnrichmentThreshold)
+
+	state, err := h.db.GetProcessingState(r.Context(), jobID)
+	if err != nil {
+		respondError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	status, skipped := jobStatus(analysis, state.Stage, threshold)
+	// Only the queue can tell a job waiting for enrichment from one running
+	// or retrying it
+	if status == "processing" && queueState.status != "" {
+		status = queueState.status
+	}
+
+	response := map[string]interface{}{
+		"job_id":               jobID,
+		"status":               status,
+		"processing_stage":     state.Stage,
+		"retry_count":          state.RetryCount,
+		"created_at":           analysis.CreatedAt,
+		"updated_at":           analysis.UpdatedAt,
+		"enrichment_threshold": threshold,
+		"enrichment_skipped":   skipped,
+		"priority":             jobPriority(analysis),
+	}
+	if state.StartedAt != nil {
+		response["started_at"] = state.StartedAt
+	}
+	if state.CompletedAt != nil {
+		response["completed_at"] = state.CompletedAt
+	}
+	if state.LastError != "" {
+		response["last_error"] = state.LastError
+	}
+	if analysis.Metadata.OfflineOnly {
+		response["degraded"] = true
+	}
+	if len(analysis.Metadata.SkippedSteps) > 0 {
+		response["skipped_steps"] = analysis.Metadata.SkippedSteps
+	}
+	// Per-step outcomes and their summary, e.g. degraded when Ollama was down
+	if len(analysis.Metadata.EnrichmentStatus) > 0 {
+		response["enrichment_status"] = analyzer.AggregateEnrichmentStatus(analysis.Metadata.EnrichmentStatus)
+		response["enrichment_steps"] = analysis.Metadata.EnrichmentStatus
+	}
+	if len(analysis.ClientMetadata) > 0 {
+		response["client_metadata"] = analysis.ClientMetadata
+	}
+
+	if skipped && analysis.Metadata.SkipEnrichment {
+		response["message"] = "AI enrichment skipped: skip_enrichment was requested"
+	} else if skipped {
+		qualityScore := 0.0
+		if analysis.Metadata.QualityScore != nil {
+			qualityScore = analysis.Metadata.QualityScore.Score
+		}
+		response["message"] = fmt.Sprintf("AI enrichment skipped: quality score %.2f is below threshold %.2f", qualityScore, threshold)
+	}
+	if status == "failed" {
+		response["message"] = fmt.Sprintf("AI enrichment failed after %d retries: %s", state.RetryCount, state.LastError)
+	}
+	queueState.addTo(response)
+
+	// Include analysis if completed; a failed job keeps its offline results
+	if status == "completed" || status == "completed_offline_only" || status == "failed" {
+		if err := h.loadImages(r.Context(), analysis); err != nil {
+			respondError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		response["analysis"] = analysis
+	}
+
+	respondJSON(w, response, http.StatusOK)
+}
+
+// jobStatus returns the status of a job from the stored processing stage of
+// its analysis and whether AI enrichment was skipped. Analyses saved before
+// stages were recorded keep the default stage, so their status is inferred
+// from the enrichment results instead.
+func jobStatus(analysis *models.Analysis, stage string, threshold float64) (status string, skipped bool) {
+	switch stage {
+	case models.ProcessingStageEnriched:
+		return "completed", false
+	case models.ProcessingStageFailed:
+		return "failed", false // Retries exhausted
+	case models.ProcessingStageCancelled:
+		return "cancelled", false
+	case models.ProcessingStageEnriching, models.ProcessingStageEnrichmentFailed:
+		return "processing", false // Enrichment running or awaiting a retry
+	case models.ProcessingStageOfflineComplete:
+		// Offline results saved; enrichment is queued unless skipped
+	default:
+		// Enrichment may have disabled the synopsis and cleaning steps, so
+		// completion is read from EnrichedAt; older analyses lack it
+		if analysis.Metadata.EnrichedAt != nil || analysis.Metadata.Synopsis != "" || analysis.Metadata.CleanedText != "" {
+			return "completed", false
+		}
+	}
+
+	// Analyses saved before the gate's outcome was recorded are judged by
+	// the gate itself; an unscored one is still waiting for its score
+	skipped = analysis.Metadata.EnrichmentSkipped || analysis.Metadata.OfflineOnly || analysis.Metadata.SkipEnrichment ||
+		(analysis.Metadata.QualityScore != nil &&
+			!analyzer.PassesEnrichmentGate(analysis.Metadata.QualityScore, threshold, analysis.Metadata.ForceEnrichment))
+	if skipped {
+		return "completed_offline_only", true // Below threshold, degraded or not requested, won't be enriched
+	}
+	return "processing", false // Offline complete, AI enrichment pending/in progress
+}
+
+// Job statuses read from the queue, reported while the stored analysis
+// does not settle the job
+const (
+	jobStatusPending  = "pending"
+	jobStatusActive   = "active"
+	jobStatusRetrying = "retrying"
+	jobStatusArchived = "archived"
+)
+
+// jobQueueState is the queue state of a job's tasks
+type jobQueueState struct {
+	status string            // Job status from the queue, empty when no task is queued or archived
+	task   *queue.TaskStatus // Task that decided status
+	err    error             // Set when the queue could not be inspected
+}
+
+// inspectJob reads the queue state of a job's tasks. It is empty without an
+// inspector. analysis is nil until the offline analysis is saved.
+func (h *Handler) inspectJob(jobID string, analysis *models.Analysis) jobQueueState {
+	if h.inspector == nil {
+		return jobQueueState{}
+	}
+	tasks, err := h.inspector.FamilyTasks(jobID, familyImageCount(analysis))
+	if err != nil {
+		return jobQueueState{err: err}
+	}
+	status, task := queueJobStatus(tasks)
+	return jobQueueState{status: status, task: task}
+}
+
+// addTo adds the queue state to a job status response. When the queue
+// decided the status, the deciding task's retry count and error replace the
+// stored ones, which the worker records only for enrichment.
+func (q jobQueueState) addTo(response map[string]interface{}) {
+	if q.err != nil {
+		response["queue_error"] = fmt.Sprintf("Failed to inspect tasks: %v", q.err)
+	}
+	if q.task == nil {
+		return
+	}
+
+	response["queue"] = q.task
+	if response["status"] != q.status {
+		return
+	}
+	switch q.status {
+	case jobStatusRetrying:
+		response["retry_count"] = q.task.Retried
+		response["next_retry_at"] = q.task.NextProcessAt
+		response["last_error"] = q.task.LastError
+	case jobStatusArchived:
+		response["retry_count"] = q.task.Retried
+		response["last_error"] = q.task.LastError
+		response["message"] = fmt.Sprintf("Task %s was archived after %d retries: %s", q.task.TaskID, q.task.Retried, q.task.LastError)
+	}
+}
+
+// queueJobStatus summarizes the queue states of a job's tasks as a job
+// status and returns the task that decided it. A running task outranks one
+// waiting to retry, which outranks one waiting to run; an archived task
+// counts only when no other task is left to run. The status is empty when
+// every task has completed or is not in the queues.
+func queueJobStatus(tasks []queue.TaskStatus) (string, *queue.TaskStatus) {
+	ranks := map[string]int{
+		jobStatusArchived: 1,
+		jobStatusPending:  2,
+		jobStatusRetrying: 3,
+		jobStatusActive:   4,
+	}
+
+	status := ""
+	var decided *queue.TaskStatus
+	for i := range tasks {
+		var taskStatus string
+		switch tasks[i].State {
+		case "active":
+			taskStatus = jobStatusActive
+		case "retry":
+			taskStatus = jobStatusRetrying
+		case "pending", "scheduled", "aggregating":
+			taskStatus = jobStatusPending
+		case "archived":
+			taskStatus = jobStatusArchived
+		default:
+			continue
+		}
+		if ranks[taskStatus] > ranks[status] {
+			status, decided = taskStatus, &tasks[i]
+		}
+	}
+	return status, decided
+}
+
+// familyImageCount returns the number of image enrichment tasks spawned for
+// an analysis, or -1 while it is unsaved and the count is unknown; the
+// inspector then reads it from the queued processing task
+func familyImageCount(analysis *models.Analysis) int {
+	if analysis == nil {
+		return -1
+	}
+	if analysis.Metadata.Images == nil {
+		return 0
+	}
+	return analysis.Metadata.Images.Accepted
+}
+
+// cancelJob deletes the tasks of a job that are waiting to run or to retry
+// and marks its analysis cancelled, so that its remaining tasks skip their
+// work. Running tasks cannot be deleted; they are reported with a 202 and
+// finish. An analysis not saved yet cannot be marked, so its offline
+// processing task saves it if that task is already running.
+func (h *Handler) cancelJob(w http.ResponseWriter, r *http.Request, jobID string) {
+	if h.inspector == nil {
+		respondError(w, "Task inspection is not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	analysis, err := h.db.GetAnalysis(r.Context(), jobID)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		respondError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	result, err := h.inspector.CancelFamily(jobID, familyImageCount(analysis))
+	if err != nil {
+		respondError(w, fmt.Sprintf("Failed to cancel tasks: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if len(result.Deleted) == 0 && len(result.Active) == 0 {
+		if analysis == nil {
+			respondError(w, "Job not found", http.StatusNotFound)
+			return
+		}
+		respondError(w, "Job has no queued or running tasks to cancel", http.StatusConflict)
+		return
+	}
+
+	cancelled := result.Deleted
+	if cancelled == nil {
+		cancelled = []queue.TaskStatus{}
+	}
+	response := map[string]interface{}{
+		"job_id":    jobID,
+		"cancelled": cancelled,
+		"active":    len(result.Active) > 0,
+	}
+	if analysis != nil {
+		if err := h.db.UpdateProcessingStage(r.Context(), jobID, models.ProcessingStageCancelled); err != nil {
+			respondError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		response["processing_stage"] = models.ProcessingStageCancelled
+	}
+
+	if len(result.Active) > 0 {
+		response["active_tasks"] = result.Active
+		response["message"] = fmt.Sprintf("%d running task(s) cannot be cancelled and will finish", len(result.Active))
+		respondJSON(w, response, http.StatusAccepted)
+		return
+	}
+	respondJSON(w, response, http.StatusOK)
+}
+
+// listJobTasks returns the queue state of every task spawned for an analysis
+// (offline processing, text enrichment and one task per image) alongside the
+// enrichment state persisted for the analysis and its images
+func (h *Handler) listJobTasks(w http.ResponseWriter, r *http.Request, jobID string) {
+	if h.inspector == nil {
+		respondError(w, "Task inspection is not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	analysis, err := h.db.GetAnalysis(r.Context(), jobID)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		respondError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	tasks, err := h.inspector.FamilyTasks(jobID, familyImageCount(analysis))
+	if err != nil {
+		respondError(w, fmt.Sprintf("Failed to inspect tasks: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"job_id": jobID,
+		"tasks":  tasks,
+	}
+	if analysis != nil {
+		images, err := h.db.GetAnalysisImages(r.Context(), jobID)
+		if err != nil {
+			respondError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		response["enrichment"] = map[string]interface{}{
+			"text":   textEnrichmentState(analysis),
+			"images": images,
+		}
+	} else if !tasksFound(tasks) {
+		respondError(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	respondJSON(w, response, http.StatusOK)
+}
+
+// textEnrichmentState summarizes the persisted AI text enrichment state of an analysis
+func textEnrichmentState(analysis *models.Analysis) map[string]interface{} {
+	state := map[string]interface{}{"state": "pending"}
+	switch {
+	case analysis.Metadata.EnrichedAt != nil:
+		state["state"] = "completed"
+		state["enriched_at"] = analysis.Metadata.EnrichedAt
+	case analysis.Metadata.EnrichmentSkipped, analysis.Metadata.OfflineOnly, analysis.Metadata.SkipEnrichment:
+		state["state"] = "skipped"
+	}
+	if len(analysis.Metadata.SkippedSteps) > 0 {
+		state["skipped_steps"] = analysis.Metadata.SkippedSteps
+	}
+	return state
+}
+
+// tasksFound reports whether any task was found in the queues
+func tasksFound(tasks []queue.TaskStatus) bool {
+	for _, task := range tasks {
+		if task.State != queue.TaskStateNotFound {
+			return true
+		}
+	}
+	return false
+}
+
+// jobPriority returns the queue priority an analysis was processed at.
+// Analyses saved before priorities were recorded ran at normal priority.
+func jobPriority(analysis *models.Analysis) string {
+	if analysis.Metadata.Priority == "" {
+		return queue.PriorityNormal
+	}
+	return analysis.Metadata.Priority
+}
+
+// handleListAnalyses handles listing all analyses with pagination. The
+// response is a page envelope with the total count, or a bare array of
+// analyses with ?envelope=false for older clients. Pages are selected by the
+// cursor of the previous page, or by an offset.
+func (h *Handler) handleListAnalyses(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit, offset := parsePage(r.URL.Query())
+
+	listFilter, reqErr := parseListFilter(r.URL.Query())
+	if reqErr != nil {
+		respondError(w, reqErr.message, reqErr.status)
+		return
+	}
+	if encoded := r.URL.Query().Get("cursor"); encoded != "" {
+		if offset != 0 {
+			respondError(w, "Invalid cursor: cannot be combined with offset", http.StatusBadRequest)
+			return
+		}
+		cursor, err := decodeCursor(encoded)
+		if err != nil {
+			respondError(w, "Invalid cursor: pass the next_cursor of the previous page", http.StatusBadRequest)
+			return
+		}
+		listFilter.After = &cursor
+	}
+	listFilter.IncludeText = wantsText(r)
+	listFilter.IncludeHTML = listFilter.IncludeText || wantsHTML(r)
+	envelope := r.URL.Query().Get("envelope") != "false"
+
+	ctx, cancel := context.WithTimeout(r.Context(), queryTimeout)
+	defer cancel()
+
+	// One analysis past the page tells whether another page follows
+	analyses, err := h.db.ListAnalysesFiltered(ctx, limit+1, offset, listFilter)
+	if err != nil {
+		respondQueryError(ctx, w, err)
+		return
+	}
+	hasMore := len(analyses) > limit
+	if hasMore {
+		analyses = analyses[:limit]
+	}
+	if !envelope {
+		respondJSON(w, analyses, http.StatusOK)
+		return
+	}
+
+	// The total counts every analysis matching the filters, not only those
+	// after the cursor
+	countFilter := listFilter
+	countFilter.After = nil
+	page := analysisPage{Items: analyses, Limit: limit, Offset: offset, HasMore: hasMore}
+	if page.Total, err = h.db.CountAnalysesFiltered(ctx, countFilter); err != nil {
+		respondQueryError(ctx, w, err)
+		return
+	}
+	if page.Items == nil {
+		page.Items = []*models.Analysis{}
+	}
+	if hasMore {
+		last := analyses[len(analyses)-1]
+		page.NextCursor = encodeCursor(database.Cursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+	respondJSON(w, page, http.StatusOK)
+}
+
+// handleAnalysisOperations handles GET and DELETE for specific analyses and
+// their sub-resources:
+//
+//	POST /api/analyses/{id}/reanalyze
+//	POST /api/analyses/{id}/enrich
+//	GET  /api/analyses/{id}/revisions
+//	GET  /api/analyses/{id}/revisions/{n}/diff
+//	GET  /api/analyses/{id}/history
+func (h *Handler) handleAnalysisOperations(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(r.URL.Path[len("/api/analyses/"):], "/")
+	id := parts[0]
+	if id == "" {
+		respondError(w, "Analysis ID is required", http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case len(parts) == 1:
+		switch r.Method {
+		case http.MethodGet:
+			h.getAnalysis(w, r, id)
+		case http.MethodDelete:
+			h.deleteAnalysis(w, r, id)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	case len(parts) == 2 && parts[1] == "reanalyze":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.reanalyze(w, r, id)
+	case len(parts) == 2 && parts[1] == "enrich":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.enrich(w, r, id)
+	case len(parts) == 2 && parts[1] == "restore":
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.restoreAnalysis(w, r, id)
+	case len(parts) == 2 && parts[1] == "revisions":
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.listRevisions(w, r, id)
+	case len(parts) == 2 && parts[1] == "history":
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.listHistory(w, r, id)
+	case len(parts) == 2 && parts[1] == "related":
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.relatedAnalyses(w, r, id)
+	case len(parts) == 2 && parts[1] == "provenance":
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.getProvenance(w, r, id)
+	case len(parts) == 4 && parts[1] == "revisions" && parts[3] == "diff":
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		revision, err := strconv.Atoi(parts[2])
+		if err != nil || revision < 1 {
+			respondError(w, "Invalid revision number", http.StatusBadRequest)
+			return
+		}
+		h.diffRevision(w, r, id, revision)
+	default:
+		respondError(w, "Not found", http.StatusNotFound)
+	}
+}
+
+// relatedAnalyses lists the analyses sharing topic tags with an analysis,
+// most related first
+func (h *Handler) relatedAnalyses(w http.ResponseWriter, r *http.Request, id string) {
+	limit := defaultRelatedAnalysesLimit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil || l < 1 || l > maxRelatedAnalysesLimit {
+			respondError(w, "limit must be between 1 and 50", http.StatusBadRequest)
+			return
+		}
+		limit = l
+	}
+
+	if _, err := h.db.GetAnalysis(r.Context(), id); err != nil {
+		respondAnalysisError(r.Context(), w, err)
+		return
+	}
+
+	related, err := h.db.RelatedAnalyses(r.Context(), id, limit)
+	if err != nil {
+		respondError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, map[string]interface{}{
+		"analysis_id": id,
+		"related":     related,
+	}, http.StatusOK)
+}
+
+// listRevisions lists the stored revisions of an analysis, oldest first
+func (h *Handler) listRevisions(w http.ResponseWriter, r *http.Request, id string) {
+	if _, err := h.db.GetAnalysis(r.Context(), id); err != nil {
+		respondAnalysisError(r.Context(), w, err)
+		return
+	}
+
+	revisions, err := h.db.ListAnalysisRevisions(r.Context(), id)
+	if err != nil {
+		respondError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, map[string]interface{}{
+		"analysis_id": id,
+		"revisions":   revisions,
+	}, http.StatusOK)
+}
+
+// listHistory returns the metadata snapshots saved after each processing
+// stage of an analysis, oldest first
+func (h *Handler) listHistory(w http.ResponseWriter, r *http.Request, id string) {
+	if _, err := h.db.GetAnalysis(r.Context(), id); err != nil {
+		respondAnalysisError(r.Context(), w, err)
+		return
+	}
+
+	history, err := h.db.ListAnalysisHistory(r.Context(), id)
+	if err != nil {
+		respondError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, map[string]interface{}{
+		"analysis_id": id,
+		"history":     history,
+	}, http.StatusOK)
+}
+
+// getProvenance returns the configuration snapshot recorded when an analysis
+// was last enriched, the configuration it would be enriched with now, and the
+// settings that differ between them. Analyses not enriched since snapshots
+// were recorded have no provenance and report "provenance" as changed.
+func (h *Handler) getProvenance(w http.ResponseWriter, r *http.Request, id string) {
+	analysis, err := h.db.GetAnalysis(r.Context(), id)
+	if err != nil {
+		respondAnalysisError(r.Context(), w, err)
+		return
+	}
+
+	current := h.analyzer.Provenance(analyzer.RecordedOptions(analysis.Metadata))
+	respondJSON(w, map[string]interface{}{
+		"analysis_id": id,
+		"provenance":  analysis.Metadata.Provenance,
+		"current":     current,
+		"changes":     analyzer.ProvenanceChanges(analysis.Metadata.Provenance, current),
+	}, http.StatusOK)
+}
+
+// diffRevision compares a revision with the results that replaced it: the
+// next revision, or the current analysis for the latest revision
+func (h *Handler) diffRevision(w http.ResponseWriter, r *http.Request, id string, revisionNumber int) {
+	analysis, err := h.db.GetAnalysis(r.Context(), id)
+	if err != nil {
+		respondAnalysisError(r.Context(), w, err)
+		return
+	}
+
+	revision, err := h.db.GetAnalysisRevision(r.Context(), id, revisionNumber)
+	if err != nil {
+		if errors.Is(err, database.ErrRevisionNotFound) {
+			respondError(w, err.Error(), http.StatusNotFound)
+		} else {
+			respondError(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	after := analyzer.SnapshotRevisionFields(analysis.Metadata)
+	toModel := analysis.Metadata.EnrichmentModel
+
+	next, err := h.db.GetAnalysisRevision(r.Context(), id, revisionNumber+1)
+	switch {
+	case err == nil:
+		after, toModel = next.Fields, next.Model
+	case !errors.Is(err, database.ErrRevisionNotFound):
+		respondError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	diff := analyzer.DiffRevisionFields(revision.Fields, after)
+	diff.Revision = revisionNumber
+	diff.FromModel = revision.Model
+	diff.ToModel = toModel
+
+	respondJSON(w, diff, http.StatusOK)
+}
+
+// respondAnalysisError maps an analysis lookup error to a 404 response, or
+// to the response of any other query error
+func respondAnalysisError(ctx context.Context, w http.ResponseWriter, err error) {
+	if errors.Is(err, database.ErrNotFound) {
+		respondError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	respondQueryError(ctx, w, err)
+}
+
+// respondQueryError maps a failed query to a 408 response when its context
+// ended, as the query was cancelled, and to a 500 response otherwise
+func respondQueryError(ctx context.Context, w http.ResponseWriter, err error) {
+	if ctx.Err() != nil {
+		respondError(w, "Request timeout", http.StatusRequestTimeout)
+		return
+	}
+	respondError(w, err.Error(), http.StatusInternalServerError)
+}
+
+// getAnalysis retrieves a specific analysis
+func (h *Handler) getAnalysis(w http.ResponseWriter, r *http.Request, id string) {
+	ctx, cancel := context.WithTimeout(r.Context(), queryTimeout)
+	defer cancel()
+
+	load := h.db.GetAnalysis
+	if wantsHTML(r) {
+		load = h.db.GetAnalysisWithHTML
+	}
+
+	analysis, err := load(ctx, id)
+	if err != nil {
+		respondAnalysisError(ctx, w, err)
+		return
+	}
+	if err := h.loadImages(ctx, analysis); err != nil {
+		respondError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respondJSON(w, h.withIncludes(r, analysis), http.StatusOK)
+}
+
+// loadImages sets the stored image metadata of an analysis, which every
+// response returning a full analysis includes
+func (h *Handler) loadImages(ctx context.Context, analysis *models.Analysis) error {
+	images, err := h.db.GetAnalysisImages(ctx, analysis.ID)
+	if err != nil {
+		return err
+	}
+	analysis.Images = images
+	return nil
+}
+
+// reanalyze regenerates the synopsis of an existing analysis, optionally with
+// a new style or word limit, without resubmitting the text
+func (h *Handler) reanalyze(w http.ResponseWriter, r *http.Request, id string) {
+	var req struct {
+		SynopsisStyle    *string `json:"synopsis_style,omitempty"`
+		SynopsisMaxWords *int    `json:"synopsis_max_words,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		respondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	analysis, err := h.db.GetAnalysis(r.Context(), id)
+	if err != nil {
+		respondAnalysisError(r.Context(), w, err)
+		return
+	}
+	if analysis.Metadata.Redaction != nil {
+		respondError(w, "Analysis cannot be reanalyzed: its text was not stored", http.StatusConflict)
+		return
+	}
+
+	// Settings not given in the request keep their stored values
+	synopsis := analyzer.SynopsisOptions{
+		Style:    analysis.Metadata.SynopsisStyle,
+		MaxWords: analysis.Metadata.SynopsisMaxWords,
+	}
+	if req.SynopsisStyle != nil {
+		synopsis.Style = *req.SynopsisStyle
+	}
+	if req.SynopsisMaxWords != nil {
+		synopsis.MaxWords = *req.SynopsisMaxWords
+	}
+	if err := analyzer.ValidateSynopsisOptions(synopsis); err != nil {
+		respondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Summarize the cleaned text when available, as stage 2 enrichment does,
+	// masking personal data when the analysis or server redacts it
+	text := analysis.Text
+	if analysis.Metadata.CleanedText != "" {
+		text = analysis.Metadata.CleanedText
+	}
+	text = h.analyzer.ModelText(text, analyzer.RecordedOptions(analysis.Metadata))
+
+	analysis.Metadata.SynopsisStyle = synopsis.Style
+	analysis.Metadata.SynopsisMaxWords = synopsis.MaxWords
+	var status string
+	analysis.Metadata.Synopsis, status = h.analyzer.GenerateSynopsisWithStatus(r.Context(), text, synopsis)
+	if analysis.Metadata.EnrichmentStatus == nil {
+		analysis.Metadata.EnrichmentStatus = make(map[string]string)
+	}
+	analysis.Metadata.EnrichmentStatus[analyzer.StepSynopsis] = status
+	analysis.UpdatedAt = time.Now()
+
+	if err := h.db.SaveAnalysis(r.Context(), analysis); err != nil {
+		respondError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	entry := &models.AnalysisHistoryEntry{
+		AnalysisID: id,
+		Stage:      models.HistoryStageReanalysis,
+		Metadata:   analysis.Metadata,
+	}
+	if err := h.db.SaveAnalysisHistory(r.Context(), entry); err != nil {
+		// The new synopsis is already saved
+		slog.Warn("failed to record analysis history", "analysis_id", id, "error", err)
+	}
+
+	respondJSON(w, analysis, http.StatusOK)
+}
+
+// analysisResponse adds optional sections to an analysis without changing its default shape
+type analysisResponse struct {
+	*models.Analysis
+	CleaningReport *analyzer.CleaningReport `json:"cleaning_report,omitempty"`
+}
+
+// withIncludes adds the sections requested via the include query parameter
+// (e.g. ?include=cleaning_report) to an analysis response. Analyses without
+// stored text have no cleaning report.
+func (h *Handler) withIncludes(r *http.Request, analysis *models.Analysis) interface{} {
+	if !wantsInclude(r, "cleaning_report") || analysis.Metadata.Redaction != nil {
+		return analysis
+	}
+
+	report := h.analyzer.CleanTextOfflineWithReport(analysis.Text)
+	return analysisResponse{
+		Analysis:       analysis,
+		CleaningReport: &report,
+	}
+}
+
+// wantsInclude reports whether a comma-separated include parameter names section
+func wantsInclude(r *http.Request, section string) bool {
+	for _, include := range strings.Split(r.URL.Query().Get("include"), ",") {
+		if strings.TrimSpace(include) == section {
+			return true
+		}
+	}
+	return false
+}
+
+// wantsHTML reports whether the request asks for the original HTML with
+// include_html=true; it is left out of responses by default
+func wantsHTML(r *http.Request) bool {
+	include, _ := strconv.ParseBool(r.URL.Query().Get("include_html"))
+	return include
+}
+
+// wantsText reports whether a listing or search request asks for the text,
+// cleaned texts and original HTML of each analysis with include_text=true;
+// they are left out of those responses by default
+func wantsText(r *http.Request) bool {
+	include, _ := strconv.ParseBool(r.URL.Query().Get("include_text"))
+	return include
+}
+
+// deleteAnalysis soft-deletes a specific analysis, which can be restored.
+// With hard=true, an admin deletes it permanently, soft-deleted or not.
+func (h *Handler) deleteAnalysis(w http.ResponseWriter, r *http.Request, id string) {
+	del := h.db.DeleteAnalysis
+	if hard, _ := strconv.ParseBool(r.URL.Query().Get("hard")); hard {
+		if !h.authorizeAdmin(w, r) {
+			return
+		}
+		del = h.db.HardDeleteAnalysis
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), queryTimeout)
+	defer cancel()
+
+	if err := del(ctx, id); err != nil {
+		respondAnalysisError(ctx, w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// restoreAnalysis makes a soft-deleted analysis visible again and returns it
+func (h *Handler) restoreAnalysis(w http.ResponseWriter, r *http.Request, id string) {
+	ctx, cancel := context.WithTimeout(r.Context(), queryTimeout)
+	defer cancel()
+
+	if err := h.db.RestoreAnalysis(ctx, id); err != nil {
+		respondAnalysisError(ctx, w, err)
+		return
+	}
+	analysis, err := h.db.GetAnalysis(ctx, id)
+	if err != nil {
+		respondAnalysisError(ctx, w, err)
+		return
+	}
+	respondJSON(w, h.withIncludes(r, analysis), http.StatusOK)
+}
+
+// handleUUIDOperations handles GET and DELETE for analyses by UUID
+func (h *Handler) handleUUIDOperations(w http.ResponseWriter, r *http.Request) {
+	uuid := r.URL.Path[len("/api/uuid/"):]
+	if uuid == "" {
+		respondError(w, "UUID is required", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.getAnalysisByUUID(w, r, uuid)
+	case http.MethodDelete:
+		h.deleteAnalysisByUUID(w, r, uuid)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// getAnalysisByUUID retrieves an analysis by UUID
+func (h *Handler) getAnalysisByUUID(w http.ResponseWriter, r *http.Request, uuid string) {
+	ctx, cancel := context.WithTimeout(r.Context(), queryTimeout)
+	defer cancel()
+
+	load := h.db.GetAnalysisByUUID
+	if wantsHTML(r) {
+		load = h.db.GetAnalysisWithHTML
+	}
+
+	analysis, err := load(ctx, uuid)
+	if err != nil {
+		respondAnalysisError(ctx, w, err)
+		return
+	}
+	if err := h.loadImages(ctx, analysis); err != nil {
+		respondError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respondJSON(w, h.withIncludes(r, analysis), http.StatusOK)
+}
+
+// deleteAnalysisByUUID deletes an analysis by UUID, as deleteAnalysis
+func (h *Handler) deleteAnalysisByUUID(w http.ResponseWriter, r *http.Request, uuid string) {
+	h.deleteAnalysis(w, r, uuid)
+}
+
+// handleSearchByTag handles searching analyses by tag
+func (h *Handler) handleSearchByTag(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tag := r.URL.Query().Get("tag")
+	if tag == "" {
+		respondError(w, "Tag parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), queryTimeout)
+	defer cancel()
+
+	analyses, err := h.db.GetAnalysesByTag(ctx, tag, wantsText(r))
+	if err != nil {
+		respondQueryError(ctx, w, err)
+		return
+	}
+	respondJSON(w, analyses, http.StatusOK)
+}
+
+// handleSearchText handles full-text search over analysis text and cleaned
+// text, returning a page of matches ranked by relevance
+//
+//	GET /api/search/text?q=carbon+capture&limit=10&offset=0
+func (h *Handler) handleSearchText(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		respondError(w, "q parameter is required", http.StatusBadRequest)
+		return
+	}
+	if utf8.RuneCountInString(query) > maxSearchQueryLength {
+		respondError(w, fmt.Sprintf("q exceeds maximum length of %d characters", maxSearchQueryLength), http.StatusBadRequest)
+		return
+	}
+	limit, offset := parsePage(r.URL.Query())
+	includeText := wantsText(r)
+
+	ctx, cancel := context.WithTimeout(r.Context(), queryTimeout)
+	defer cancel()
+
+	matches, err := h.db.SearchAnalyses(ctx, query, limit, offset, includeText)
+	if err != nil {
+		respondQueryError(ctx, w, err)
+		return
+	}
+	total, err := h.db.CountSearchAnalyses(ctx, query)
+	if err != nil {
+		respondQueryError(ctx, w, err)
+		return
+	}
+
+	page := searchPage{Items: matches, Total: total, Limit: limit, Offset: offset}
+	if page.Items == nil {
+		page.Items = []*models.SearchMatch{}
+	}
+	page.HasMore = offset+len(page.Items) < page.Total
+	respondJSON(w, page, http.StatusOK)
+}
+
+// referenceTypes are the types of reference the analyzer and the LLM record
+var referenceTypes = map[string]bool{"claim": true, "statistic": true, "quote": true, "citation": true}
+
+// handleSearchByReference handles searching analyses by reference text,
+// returning a page in the envelope of the analyses listing
+//
+//	GET /api/search/reference?reference=75%25&type=statistic&limit=10&offset=0
+func (h *Handler) handleSearchByReference(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
@@ -426,43 +1894,346 @@ func (h *Handler) handleSearchByReference(w http.ResponseWriter, r *http.Request
 		respondError(w, "Reference parameter is required", http.StatusBadRequest)
 		return
 	}
+	refType := strings.ToLower(r.URL.Query().Get("type"))
+	if refType != "" && !referenceTypes[refType] {
+		respondError(w, "type must be claim, statistic, quote or citation", http.StatusBadRequest)
+		return
+	}
 
-	// Search in a goroutine
-	resultChan := make(chan []*models.Analysis)
-	errorChan := make(chan error)
-
-	go func() {
-		analyses, err := h.db.GetAnalysesByReference(reference)
+	includeText := wantsText(r)
+	h.respondAnalysisSearch(w, r, func(ctx context.Context, limit, offset int) ([]*models.Analysis, int, error) {
+		analyses, err := h.db.GetAnalysesByReference(ctx, reference, refType, limit, offset, includeText)
 		if err != nil {
-			errorChan <- err
+			return nil, 0, err
+		}
+		total, err := h.db.CountAnalysesByReference(ctx, reference, refType)
+		return analyses, total, err
+	})
+}
+
+// handleSearchByEntity handles searching analyses by named entity, optionally
+// of one type, returning a page in the envelope of the analyses listing
+//
+//	GET /api/search/entity?name=Paris&type=location&limit=10&offset=0
+func (h *Handler) handleSearchByEntity(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimSpace(r.URL.Query().Get("name"))
+	if name == "" {
+		respondError(w, "name parameter is required", http.StatusBadRequest)
+		return
+	}
+	if utf8.RuneCountInString(name) > maxSearchQueryLength {
+		respondError(w, fmt.Sprintf("name exceeds maximum length of %d characters", maxSearchQueryLength), http.StatusBadRequest)
+		return
+	}
+	entityType := strings.ToLower(r.URL.Query().Get("type"))
+	if entityType != "" {
+		if err := analyzer.ValidateEntityType(entityType); err != nil {
+			respondError(w, "Invalid type: "+err.Error(), http.StatusBadRequest)
 			return
 		}
-		resultChan <- analyses
-	}()
+	}
 
-	select {
-	case analyses := <-resultChan:
-		respondJSON(w, analyses, http.StatusOK)
-	case err := <-errorChan:
-		respondError(w, err.Error(), http.StatusInternalServerError)
-	case <-time.After(30 * time.Second):
-		respondError(w, "Request timeout", http.StatusRequestTimeout)
+	includeText := wantsText(r)
+	h.respondAnalysisSearch(w, r, func(ctx context.Context, limit, offset int) ([]*models.Analysis, int, error) {
+		analyses, err := h.db.GetAnalysesByEntity(ctx, name, entityType, limit, offset, includeText)
+		if err != nil {
+			return nil, 0, err
+		}
+		total, err := h.db.CountAnalysesByEntity(ctx, name, entityType)
+		return analyses, total, err
+	})
+}
+
+// respondAnalysisSearch runs a paginated search with the limit and offset of
+// the request and responds with the page, or a timeout after queryTimeout
+func (h *Handler) respondAnalysisSearch(w http.ResponseWriter, r *http.Request, search func(ctx context.Context, limit, offset int) ([]*models.Analysis, int, error)) {
+	limit, offset := parsePage(r.URL.Query())
+
+	ctx, cancel := context.WithTimeout(r.Context(), queryTimeout)
+	defer cancel()
+
+	analyses, total, err := search(ctx, limit, offset)
+	if err != nil {
+		respondQueryError(ctx, w, err)
+		return
+	}
+
+	page := analysisPage{Items: analyses, Total: total, Limit: limit, Offset: offset}
+	if page.Items == nil {
+		page.Items = []*models.Analysis{}
+	}
+	page.HasMore = offset+len(page.Items) < page.Total
+	respondJSON(w, page, http.StatusOK)
+}
+
+// validateText checks that a submitted text is present and within the size
+// limit, writing an error response and returning false otherwise
+func validateText(w http.ResponseWriter, text string) bool {
+	if err := checkText(text); err != nil {
+		respondError(w, err.message, err.status)
+		return false
+	}
+	return true
+}
+
+// checkText returns why a submitted text is rejected, or nil when it is
+// present and within the size limit
+func checkText(text string) *requestError {
+	if text == "" {
+		return badRequest("Text field is required")
+	}
+
+	if utf8.RuneCountInString(text) > maxTextLength {
+		return &requestError{
+			message: fmt.Sprintf("Text exceeds maximum length of %d characters", maxTextLength),
+			status:  http.StatusRequestEntityTooLarge,
+		}
+	}
+
+	return nil
+}
+
+// checkOriginalHTML returns why a submitted original_html is rejected, or nil
+// when it is absent or decompresses within the size limit
+func (h *Handler) checkOriginalHTML(encoded string) *requestError {
+	size, err := queue.DecompressedHTMLSize(encoded, h.maxHTML)
+	if err != nil {
+		return badRequest("Invalid original_html: " + err.Error())
+	}
+
+	if size > h.maxHTML {
+		return &requestError{
+			message: fmt.Sprintf("original_html exceeds maximum decompressed size of %d bytes", h.maxHTML),
+			status:  http.StatusRequestEntityTooLarge,
+		}
+	}
+
+	return nil
+}
+
+// validateClientMetadata checks the number of client metadata entries, their
+// keys and the length of their values
+func validateClientMetadata(clientMetadata map[string]string) error {
+	if len(clientMetadata) > maxClientMetadataKeys {
+		return fmt.Errorf("%d keys submitted, maximum is %d", len(clientMetadata), maxClientMetadataKeys)
 	}
+	for key, value := range clientMetadata {
+		if !clientMetadataKeyPattern.MatchString(key) {
+			return fmt.Errorf("key %q must be 1-64 letters, digits, '_' or '-'", key)
+		}
+		if utf8.RuneCountInString(value) > maxClientMetadataValueLength {
+			return fmt.Errorf("value for %q exceeds %d characters", key, maxClientMetadataValueLength)
+		}
+	}
+	return nil
+}
+
+// validateCallbackURL checks that a callback URL, if any, is an absolute
+// http(s) URL of reasonable length whose host is not a literal non-public
+// address, unless allowPrivate
+func validateCallbackURL(callbackURL string, allowPrivate bool) error {
+	if callbackURL == "" {
+		return nil
+	}
+	if len(callbackURL) > maxCallbackURLLength {
+		return fmt.Errorf("exceeds %d characters", maxCallbackURLLength)
+	}
+	u, err := url.Parse(strings.TrimSpace(callbackURL))
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("scheme must be http or https")
+	}
+	if u.Host == "" {
+		return fmt.Errorf("missing host")
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil && !analyzer.PublicIP(ip) && !allowPrivate {
+		return fmt.Errorf("%s is not a public address", ip)
+	}
+	return nil
+}
+
+// clientMetadataFilter collects client_metadata.{key}=value query parameters
+// into a filter, returning nil when there are none
+func clientMetadataFilter(query map[string][]string) (map[string]string, error) {
+	var filter map[string]string
+	for param, values := range query {
+		key, ok := strings.CutPrefix(param, clientMetadataParamPrefix)
+		if !ok {
+			continue
+		}
+		if !clientMetadataKeyPattern.MatchString(key) {
+			return nil, fmt.Errorf("key %q must be 1-64 letters, digits, '_' or '-'", key)
+		}
+		if filter == nil {
+			filter = make(map[string]string)
+		}
+		filter[key] = values[0]
+	}
+	return filter, nil
+}
+
+// parsePage reads the limit and offset of a page, ignoring invalid values
+// and capping the limit at maxListLimit
+func parsePage(query url.Values) (limit, offset int) {
+	limit = defaultListLimit
+	if l, err := strconv.Atoi(query.Get("limit")); err == nil && l > 0 {
+		limit = min(l, maxListLimit)
+	}
+	if o, err := strconv.Atoi(query.Get("offset")); err == nil && o >= 0 {
+		offset = o
+	}
+	return limit, offset
+}
+
+// parseListFilter reads the filters of the analyses listing from its query
+// parameters, naming the invalid parameter when one is rejected
+func parseListFilter(query url.Values) (database.ListFilter, *requestError) {
+	var filter database.ListFilter
+
+	clientMetadata, err := clientMetadataFilter(query)
+	if err != nil {
+		return filter, badRequest("Invalid client_metadata filter: " + err.Error())
+	}
+	filter.ClientMetadata = clientMetadata
+
+	var reqErr *requestError
+	if filter.CreatedAfter, reqErr = parseListTime(query, "created_after"); reqErr != nil {
+		return filter, reqErr
+	}
+	if filter.CreatedBefore, reqErr = parseListTime(query, "created_before"); reqErr != nil {
+		return filter, reqErr
+	}
+	if filter.CreatedAfter != nil && filter.CreatedBefore != nil && !filter.CreatedAfter.Before(*filter.CreatedBefore) {
+		return filter, badRequest("Invalid created_before: must be later than created_after")
+	}
+
+	if language := query.Get("language"); language != "" {
+		if !languagePattern.MatchString(language) {
+			return filter, badRequest("Invalid language: must be a lowercase ISO 639-1 code such as en, or unknown")
+		}
+		filter.Language = language
+	}
+
+	if bias := query.Get("bias"); bias != "" {
+		if err := analyzer.ValidateEditorialBias(bias); err != nil {
+			return filter, badRequest("Invalid bias: " + err.Error())
+		}
+		filter.Bias = bias
+	}
+
+	if filter.MinQuality, reqErr = parseListQuality(query, "min_quality"); reqErr != nil {
+		return filter, reqErr
+	}
+	if filter.MaxQuality, reqErr = parseListQuality(query, "max_quality"); reqErr != nil {
+		return filter, reqErr
+	}
+	if filter.MinQuality != nil && filter.MaxQuality != nil && *filter.MinQuality > *filter.MaxQuality {
+		return filter, badRequest("Invalid max_quality: must not be below min_quality")
+	}
+
+	return filter, nil
+}
+
+// parseListTime reads a time filter given as an RFC 3339 timestamp or a
+// date, which means midnight UTC, returning nil when it is not set
+func parseListTime(query url.Values, param string) (*time.Time, *requestError) {
+	raw := query.Get(param)
+	if raw == "" {
+		return nil, nil
+	}
+	for _, layout := range []string{time.RFC3339, time.DateOnly} {
+		if t, err := time.Parse(layout, raw); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, badRequest(fmt.Sprintf("Invalid %s: must be an RFC 3339 timestamp or a date such as 2025-01-15", param))
+}
+
+// parseListQuality reads a quality score bound between 0 and 1, returning
+// nil when it is not set
+func parseListQuality(query url.Values, param string) (*float64, *requestError) {
+	raw := query.Get(param)
+	if raw == "" {
+		return nil, nil
+	}
+	score, err := strconv.ParseFloat(raw, 64)
+	// Written so that NaN is rejected too
+	if err != nil || !(score >= 0 && score <= 1) {
+		return nil, badRequest(fmt.Sprintf("Invalid %s: must be a number between 0 and 1", param))
+	}
+	return &score, nil
 }
 
-// respondJSON sends a JSON response
+// respondJSON sends a JSON response with its Content-Length
 func respondJSON(w http.ResponseWriter, data interface{}, statusCode int) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		respondError(w, "Failed to encode response: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	body = append(body, '\n')
+
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
 	w.WriteHeader(statusCode)
-	json.NewEncoder(w).Encode(data)
+	w.Write(body)
+}
+
+// Machine-readable error codes, so clients can tell errors apart without
+// parsing messages
+const (
+	errorCodeValidation   = "validation_error"
+	errorCodeNotFound     = "not_found"
+	errorCodeTimeout      = "timeout"
+	errorCodeConflict     = "conflict"
+	errorCodeUnauthorized = "unauthorized"
+	errorCodeUnavailable  = "unavailable"
+	errorCodeUpstream     = "upstream_error"
+	errorCodeInternal     = "internal_error"
+)
+
+// errorResponse is the body of every error response
+type errorResponse struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+}
+
+// errorCode returns the error code of a response status
+func errorCode(statusCode int) string {
+	switch statusCode {
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity:
+		return errorCodeValidation
+	case http.StatusNotFound:
+		return errorCodeNotFound
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return errorCodeTimeout
+	case http.StatusConflict:
+		return errorCodeConflict
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return errorCodeUnauthorized
+	case http.StatusServiceUnavailable, http.StatusTooManyRequests:
+		return errorCodeUnavailable
+	case http.StatusBadGateway:
+		return errorCodeUpstream
+	default:
+		return errorCodeInternal
+	}
 }
 
-// respondError sends an error response
+// respondError sends an error response, its code derived from the status
 func respondError(w http.ResponseWriter, message string, statusCode int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
-	json.NewEncoder(w).Encode(map[string]string{
-		"error": message,
+	json.NewEncoder(w).Encode(errorResponse{
+		Error: message,
+		Code:  errorCode(statusCode),
 	})
 }
 
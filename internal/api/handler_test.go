@@ -4,9 +4,14 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -14,12 +19,28 @@ import (
 	"github.com/docutag/textanalyzer/internal/analyzer"
 	"github.com/docutag/textanalyzer/internal/database"
 	"github.com/docutag/textanalyzer/internal/models"
+	"github.com/docutag/textanalyzer/internal/queue"
 )
 
 // mockQueueClient implements the queue client interface for testing
-type mockQueueClient struct{}
+type mockQueueClient struct {
+	lastText    string
+	lastHTML    string
+	lastOptions models.ProcessingOptions
+	lastImages  []string
+	enqueued    int   // Calls to EnqueueProcessDocument
+	err         error // Returned by EnqueueProcessDocument when set
+}
 
-func (m *mockQueueClient) EnqueueProcessDocument(ctx context.Context, analysisID, text, originalHTML string, images []string) (string, error) {
+func (m *mockQueueClient) EnqueueProcessDocument(ctx context.Context, analysisID, text, originalHTML string, images []string, options models.ProcessingOptions) (string, error) {
+	m.lastText = text
+	m.lastHTML = originalHTML
+	m.lastOptions = options
+	m.lastImages = images
+	m.enqueued++
+	if m.err != nil {
+		return "", m.err
+	}
 	return "mock-task-id", nil
 }
 
@@ -41,13 +62,20 @@ func setupTestHandler(t *testing.T) (*Handler, *database.DB, func()) {
 
 	a := analyzer.New()
 	mockQueue := &mockQueueClient{}
-	_ = NewHandler(db, a, mockQueue)
+	_ = NewHandler(db, a, mockQueue, Config{})
 
 	// Create internal handler for testing
 	handler := &Handler{
 		db:          db,
 		analyzer:    a,
 		queueClient: mockQueue,
+		staleAfter:  defaultHeartbeatStaleAfter,
+		maxImages:   analyzer.DefaultMaxImages,
+		maxBody:     defaultMaxBodyBytes,
+		maxHTML:     defaultMaxHTMLBytes,
+		relatedTags: newRelatedTagsCache(relatedTagsCacheTTL),
+		stats:       newStatsCache(statsCacheTTL),
+		metrics:     NewMetrics(prometheus.NewRegistry()),
 		mux:         http.NewServeMux(),
 	}
 	handler.setupRoutes()
@@ -83,6 +111,196 @@ func TestHealthEndpoint(t *testing.T) {
 	}
 }
 
+func TestDeepHealthEndpoint(t *testing.T) {
+	handler, _, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	deepHealth := func() (int, map[string]interface{}) {
+		req := httptest.NewRequest(http.MethodGet, "/health?deep=true", nil)
+		w := httptest.NewRecorder()
+		handler.mux.ServeHTTP(w, req)
+
+		var response map[string]interface{}
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return w.Code, response
+	}
+
+	handler.healthChecks = map[string]HealthCheck{
+		"redis":  func(ctx context.Context) error { return nil },
+		"ollama": func(ctx context.Context) error { return nil },
+	}
+	code, response := deepHealth()
+	if code != http.StatusOK || response["status"] != "ok" {
+		t.Errorf("Expected status 200 ok, got %d %v", code, response["status"])
+	}
+	checks := response["checks"].(map[string]interface{})
+	for _, name := range []string{"database", "redis", "ollama"} {
+		if checks[name] != "ok" {
+			t.Errorf("Expected %s check 'ok', got %v", name, checks[name])
+		}
+	}
+
+	// A failing or hanging dependency degrades the service without
+	// holding up the response past the deadline
+	handler.healthChecks["ollama"] = func(ctx context.Context) error {
+		return errors.New("model llama3 not found on the Ollama server")
+	}
+	handler.healthChecks["redis"] = func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	start := time.Now()
+	code, response = deepHealth()
+	if elapsed := time.Since(start); elapsed > deepHealthTimeout+time.Second {
+		t.Errorf("Expected deep health to be bounded by %v, took %v", deepHealthTimeout, elapsed)
+	}
+	if code != http.StatusServiceUnavailable || response["status"] != "degraded" {
+		t.Errorf("Expected status 503 degraded, got %d %v", code, response["status"])
+	}
+	checks = response["checks"].(map[string]interface{})
+	if checks["ollama"] != "model llama3 not found on the Ollama server" {
+		t.Errorf("Expected ollama check to report the missing model, got %v", checks["ollama"])
+	}
+	if checks["redis"] != context.DeadlineExceeded.Error() {
+		t.Errorf("Expected redis check to time out, got %v", checks["redis"])
+	}
+	if checks["database"] != "ok" {
+		t.Errorf("Expected database check 'ok', got %v", checks["database"])
+	}
+}
+
+func TestReadyEndpoint(t *testing.T) {
+	handler, db, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	ready := func() (int, map[string]interface{}) {
+		req := httptest.NewRequest(http.MethodGet, "/ready", nil)
+		w := httptest.NewRecorder()
+		handler.mux.ServeHTTP(w, req)
+
+		var response map[string]interface{}
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return w.Code, response
+	}
+
+	// No worker has reported yet
+	code, response := ready()
+	if code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 without heartbeats, got %d", code)
+	}
+	if checks := response["checks"].(map[string]interface{}); checks["worker"] != "no heartbeat" {
+		t.Errorf("Expected worker check 'no heartbeat', got %v", checks["worker"])
+	}
+
+	// A stalled worker does not make the service ready
+	now := time.Now()
+	stale := &models.WorkerHeartbeat{
+		WorkerID:    "worker-stale",
+		HeartbeatAt: now.Add(-5 * time.Minute),
+		StartedAt:   now.Add(-time.Hour),
+	}
+	if err := db.SaveWorkerHeartbeat(context.Background(), stale); err != nil {
+		t.Fatalf("Failed to save heartbeat: %v", err)
+	}
+	code, _ = ready()
+	if code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 with only a stale heartbeat, got %d", code)
+	}
+
+	fresh := &models.WorkerHeartbeat{
+		WorkerID:    "worker-fresh",
+		HeartbeatAt: now,
+		StartedAt:   now.Add(-time.Minute),
+		ActiveTasks: 2,
+	}
+	if err := db.SaveWorkerHeartbeat(context.Background(), fresh); err != nil {
+		t.Fatalf("Failed to save heartbeat: %v", err)
+	}
+	code, response = ready()
+	if code != http.StatusOK {
+		t.Errorf("Expected status 200 with a fresh heartbeat, got %d", code)
+	}
+	if response["status"] != "ready" {
+		t.Errorf("Expected status 'ready', got %v", response["status"])
+	}
+	if workers := response["workers"].([]interface{}); len(workers) != 2 {
+		t.Errorf("Expected 2 workers, got %d", len(workers))
+	}
+}
+
+func TestAdminQueueEndpoint(t *testing.T) {
+	handler, db, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	now := time.Now()
+	completed := now.Add(-10 * time.Second)
+	heartbeat := &models.WorkerHeartbeat{
+		WorkerID:            "worker-1",
+		HeartbeatAt:         now,
+		StartedAt:           now.Add(-time.Hour),
+		ActiveTasks:         1,
+		ProcessedTasks:      42,
+		FailedTasks:         3,
+		LastTaskCompletedAt: &completed,
+	}
+	if err := db.SaveWorkerHeartbeat(context.Background(), heartbeat); err != nil {
+		t.Fatalf("Failed to save heartbeat: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/queue", nil)
+	w := httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var response struct {
+		Workers []workerStatus `json:"workers"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(response.Workers) != 1 {
+		t.Fatalf("Expected 1 worker, got %d", len(response.Workers))
+	}
+	worker := response.Workers[0]
+	if worker.WorkerID != "worker-1" || worker.ProcessedTasks != 42 || worker.FailedTasks != 3 {
+		t.Errorf("Unexpected worker status: %+v", worker.WorkerHeartbeat)
+	}
+	if worker.Stale {
+		t.Error("Expected fresh worker not to be stale")
+	}
+}
+
+func TestWorkerStatuses(t *testing.T) {
+	now := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	heartbeats := []*models.WorkerHeartbeat{
+		{WorkerID: "fresh", HeartbeatAt: now.Add(-10 * time.Second)},
+		{WorkerID: "boundary", HeartbeatAt: now.Add(-45 * time.Second)},
+		{WorkerID: "stale", HeartbeatAt: now.Add(-46 * time.Second)},
+	}
+
+	statuses := workerStatuses(heartbeats, 45*time.Second, now)
+	if len(statuses) != 3 {
+		t.Fatalf("Expected 3 statuses, got %d", len(statuses))
+	}
+
+	expected := map[string]bool{"fresh": false, "boundary": false, "stale": true}
+	for _, status := range statuses {
+		if status.Stale != expected[status.WorkerID] {
+			t.Errorf("Worker %s: expected stale=%v, got %v", status.WorkerID, expected[status.WorkerID], status.Stale)
+		}
+	}
+	if statuses[0].AgeSeconds != 10 {
+		t.Errorf("Expected age 10s, got %v", statuses[0].AgeSeconds)
+	}
+}
+
 func TestAnalyzeEndpoint(t *testing.T) {
 	handler, _, cleanup := setupTestHandler(t)
 	defer cleanup()
@@ -125,6 +343,80 @@ func TestAnalyzeEndpoint(t *testing.T) {
 	// so we can't verify the full analysis results in this test
 }
 
+func TestAnalyzeDeduplicatesText(t *testing.T) {
+	handler, db, cleanup := setupTestHandler(t)
+	defer cleanup()
+	mockQueue := handler.queueClient.(*mockQueueClient)
+
+	text := "The same page, scraped again. It has not changed."
+	existing := &models.Analysis{
+		ID:        "dedup-1",
+		Text:      text,
+		TextHash:  database.TextHash(text),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := db.SaveAnalysis(context.Background(), existing); err != nil {
+		t.Fatalf("Failed to save analysis: %v", err)
+	}
+
+	post := func(body map[string]interface{}) (*httptest.ResponseRecorder, map[string]interface{}) {
+		data, _ := json.Marshal(body)
+		req := httptest.NewRequest(http.MethodPost, "/api/analyze", bytes.NewReader(data))
+		w := httptest.NewRecorder()
+		handler.mux.ServeHTTP(w, req)
+
+		var response map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w, response
+	}
+
+	// Whitespace differences do not defeat deduplication
+	w, response := post(map[string]interface{}{"text": "  The same page,\n\nscraped again.  It has not changed.\n"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if response["job_id"] != existing.ID || response["deduplicated"] != true {
+		t.Errorf("Expected the existing analysis %s, got %v", existing.ID, response)
+	}
+	if mockQueue.enqueued != 0 {
+		t.Errorf("Expected nothing enqueued, got %d tasks", mockQueue.enqueued)
+	}
+
+	w, response = post(map[string]interface{}{"text": text, "force": true})
+	if w.Code != http.StatusAccepted || response["job_id"] == existing.ID || response["deduplicated"] != nil {
+		t.Fatalf("Expected a new analysis with force, got %d %v", w.Code, response)
+	}
+	if mockQueue.enqueued != 1 || mockQueue.lastOptions.TextHash != existing.TextHash {
+		t.Errorf("Expected one task carrying the text hash, got %d with %q", mockQueue.enqueued, mockQueue.lastOptions.TextHash)
+	}
+
+	if w, _ := post(map[string]interface{}{"text": "A different page."}); w.Code != http.StatusAccepted {
+		t.Errorf("Expected status 202 for a new text, got %d", w.Code)
+	}
+
+	// Callbacks and processing options are honoured by analyzing again
+	for name, body := range map[string]map[string]interface{}{
+		"callback_url":   {"text": text, "callback_url": "https://example.com/hook"},
+		"synopsis_style": {"text": text, "synopsis_style": "teaser"},
+		"redact_pii":     {"text": text, "redact_pii": true},
+	} {
+		if w, response := post(body); w.Code != http.StatusAccepted || response["deduplicated"] != nil {
+			t.Errorf("%s: expected a new analysis, got %d %v", name, w.Code, response)
+		}
+	}
+
+	// Submissions that store no text are neither hashed nor deduplicated
+	enqueued := mockQueue.enqueued
+	w, response = post(map[string]interface{}{"text": text, "store_text": false})
+	if w.Code != http.StatusAccepted || response["deduplicated"] != nil {
+		t.Errorf("Expected a new analysis for a redacted submission, got %d %v", w.Code, response)
+	}
+	if mockQueue.enqueued != enqueued+1 || mockQueue.lastOptions.TextHash != "" {
+		t.Errorf("Expected a task without a text hash, got %q", mockQueue.lastOptions.TextHash)
+	}
+}
+
 func TestAnalyzeEndpointEmptyText(t *testing.T) {
 	handler, _, cleanup := setupTestHandler(t)
 	defer cleanup()
@@ -175,7 +467,7 @@ func TestGetAnalysisEndpoint(t *testing.T) {
 		UpdatedAt: time.Now(),
 	}
 
-	if err := db.SaveAnalysis(analysis); err != nil {
+	if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
 		t.Fatalf("Failed to save test analysis: %v", err)
 	}
 
@@ -198,6 +490,93 @@ func TestGetAnalysisEndpoint(t *testing.T) {
 	}
 }
 
+func TestGetAnalysisCleaningReport(t *testing.T) {
+	handler, db, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	analysis := &models.Analysis{
+		ID:        "test-get-report-001",
+		Text:      "Scientists have discovered new methods for improving machine learning algorithms.\n\nPhoto by: John Smith, Getty Images",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+		t.Fatalf("Failed to save test analysis: %v", err)
+	}
+
+	// Default response does not include the report
+	req := httptest.NewRequest(http.MethodGet, "/api/analyses/test-get-report-001", nil)
+	w := httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+
+	var plain map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&plain); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if _, ok := plain["cleaning_report"]; ok {
+		t.Error("Expected no cleaning_report by default")
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/analyses/test-get-report-001?include=cleaning_report", nil)
+	w = httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+
+	var response struct {
+		ID             string                  `json:"id"`
+		CleaningReport analyzer.CleaningReport `json:"cleaning_report"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.ID != "test-get-report-001" {
+		t.Errorf("Expected ID 'test-get-report-001', got '%s'", response.ID)
+	}
+	if got := response.CleaningReport.RemovedContent[analyzer.RemovalImageAttribution]; len(got) != 1 {
+		t.Errorf("Expected one image attribution, got %v", got)
+	}
+}
+
+func TestGetAnalysisIncludeHTML(t *testing.T) {
+	handler, db, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	analysis := &models.Analysis{
+		ID:           "test-get-html-001",
+		Text:         "Scientists have discovered new methods for improving machine learning algorithms.",
+		OriginalHTML: "H4sIAAAAAAAA/7IpyEhUSM7PS0nNSy9JTVFIT1VIyclMzyxJzUkFAAAA//8=",
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+	if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+		t.Fatalf("Failed to save test analysis: %v", err)
+	}
+
+	for _, tt := range []struct {
+		query string
+		html  string
+	}{
+		{"", ""},
+		{"?include_html=false", ""},
+		{"?include_html=true", analysis.OriginalHTML},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api/analyses/test-get-html-001"+tt.query, nil)
+		w := httptest.NewRecorder()
+		handler.mux.ServeHTTP(w, req)
+
+		var response map[string]interface{}
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		html, ok := response["original_html"]
+		if tt.html == "" && ok {
+			t.Errorf("%q: expected no original_html, got %v", tt.query, html)
+		}
+		if tt.html != "" && html != tt.html {
+			t.Errorf("%q: expected original_html %q, got %v", tt.query, tt.html, html)
+		}
+	}
+}
+
 func TestGetAnalysisNotFound(t *testing.T) {
 	handler, _, cleanup := setupTestHandler(t)
 	defer cleanup()
@@ -210,6 +589,85 @@ func TestGetAnalysisNotFound(t *testing.T) {
 	if w.Code != http.StatusNotFound {
 		t.Errorf("Expected status 404, got %d", w.Code)
 	}
+	assertErrorCode(t, w, errorCodeNotFound)
+}
+
+// assertErrorCode checks that a response is an error response with code
+func assertErrorCode(t *testing.T, w *httptest.ResponseRecorder, code string) {
+	t.Helper()
+	var response errorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Expected a JSON error, got %q", w.Body.String())
+	}
+	if response.Code != code || response.Error == "" {
+		t.Errorf("Expected an error with code %q, got %+v", code, response)
+	}
+}
+
+func TestRespondAnalysisError(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{"not found", database.ErrNotFound, http.StatusNotFound, errorCodeNotFound},
+		{"wrapped not found", fmt.Errorf("failed to load analysis abc: %w", database.ErrNotFound), http.StatusNotFound, errorCodeNotFound},
+		{"twice wrapped not found", fmt.Errorf("lookup: %w", fmt.Errorf("by uuid: %w", database.ErrNotFound)), http.StatusNotFound, errorCodeNotFound},
+		{"same message, different error", errors.New("analysis not found"), http.StatusInternalServerError, errorCodeInternal},
+		{"other error", errors.New("connection refused"), http.StatusInternalServerError, errorCodeInternal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			respondAnalysisError(context.Background(), w, tt.err)
+
+			if w.Code != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, w.Code)
+			}
+			assertErrorCode(t, w, tt.code)
+		})
+	}
+
+	// A query cancelled by its context ending is a timeout, whatever the
+	// driver reports
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+	w := httptest.NewRecorder()
+	respondAnalysisError(ctx, w, errors.New("pq: canceling statement due to user request"))
+	if w.Code != http.StatusRequestTimeout {
+		t.Errorf("Expected status 408, got %d", w.Code)
+	}
+	assertErrorCode(t, w, errorCodeTimeout)
+}
+
+func TestRespondErrorCode(t *testing.T) {
+	tests := map[int]string{
+		http.StatusBadRequest:            errorCodeValidation,
+		http.StatusRequestEntityTooLarge: errorCodeValidation,
+		http.StatusUnprocessableEntity:   errorCodeValidation,
+		http.StatusNotFound:              errorCodeNotFound,
+		http.StatusRequestTimeout:        errorCodeTimeout,
+		http.StatusGatewayTimeout:        errorCodeTimeout,
+		http.StatusConflict:              errorCodeConflict,
+		http.StatusUnauthorized:          errorCodeUnauthorized,
+		http.StatusForbidden:             errorCodeUnauthorized,
+		http.StatusServiceUnavailable:    errorCodeUnavailable,
+		http.StatusBadGateway:            errorCodeUpstream,
+		http.StatusInternalServerError:   errorCodeInternal,
+	}
+
+	for status, code := range tests {
+		w := httptest.NewRecorder()
+		respondError(w, "message", status)
+
+		if w.Code != status {
+			t.Errorf("Expected status %d, got %d", status, w.Code)
+		}
+		assertErrorCode(t, w, code)
+	}
 }
 
 func TestListAnalysesEndpoint(t *testing.T) {
@@ -228,31 +686,229 @@ func TestListAnalysesEndpoint(t *testing.T) {
 			CreatedAt: time.Now(),
 			UpdatedAt: time.Now(),
 		}
-		if err := db.SaveAnalysis(analysis); err != nil {
+		if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
 			t.Fatalf("Failed to save test analysis: %v", err)
 		}
 		time.Sleep(10 * time.Millisecond)
 	}
 
-	req := httptest.NewRequest(http.MethodGet, "/api/analyses?limit=3&offset=0", nil)
-	w := httptest.NewRecorder()
+	listPage := func(query string) analysisPage {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/analyses?"+query, nil)
+		w := httptest.NewRecorder()
+		handler.mux.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", w.Code)
+		}
+		var page analysisPage
+		if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return page
+	}
 
-	handler.mux.ServeHTTP(w, req)
+	page := listPage("limit=3&offset=0")
+	if len(page.Items) != 3 || page.Total != 5 || page.Limit != 3 || page.Offset != 0 || !page.HasMore {
+		t.Errorf("Expected 3 of 5 analyses with more to come, got %d items, %+v", len(page.Items), page)
+	}
 
-	if w.Code != http.StatusOK {
-		t.Errorf("Expected status 200, got %d", w.Code)
+	page = listPage("limit=3&offset=3")
+	if len(page.Items) != 2 || page.Total != 5 || page.HasMore {
+		t.Errorf("Expected the last 2 of 5 analyses, got %d items, %+v", len(page.Items), page)
+	}
+
+	// Totals follow deletions
+	if err := db.DeleteAnalysis(context.Background(), "test-list-1"); err != nil {
+		t.Fatalf("Failed to delete analysis: %v", err)
+	}
+	page = listPage("limit=3&offset=3")
+	if len(page.Items) != 1 || page.Total != 4 || page.HasMore {
+		t.Errorf("Expected the last of 4 analyses, got %d items, %+v", len(page.Items), page)
+	}
+
+	page = listPage("offset=10")
+	if page.Items == nil || len(page.Items) != 0 || page.Total != 4 || page.Limit != defaultListLimit {
+		t.Errorf("Expected an empty page past the end, got %+v", page)
 	}
 
+	page = listPage("limit=1000")
+	if page.Limit != maxListLimit {
+		t.Errorf("Expected limit capped at %d, got %d", maxListLimit, page.Limit)
+	}
+
+	// Older clients can still ask for a bare array
+	req := httptest.NewRequest(http.MethodGet, "/api/analyses?limit=3&envelope=false", nil)
+	w := httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+
 	var response []*models.Analysis
 	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
 		t.Fatalf("Failed to decode response: %v", err)
 	}
-
 	if len(response) != 3 {
 		t.Errorf("Expected 3 analyses, got %d", len(response))
 	}
 }
 
+func TestListAnalysesCursorEndpoint(t *testing.T) {
+	handler, db, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	createdAt := time.Now().Add(-time.Hour)
+	for i := 1; i <= 7; i++ {
+		analysis := &models.Analysis{
+			ID:        fmt.Sprintf("test-cursor-%d", i),
+			Text:      "Test text",
+			CreatedAt: createdAt,
+			UpdatedAt: createdAt,
+		}
+		if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+			t.Fatalf("Failed to save test analysis: %v", err)
+		}
+	}
+
+	var seen []string
+	query := "limit=3"
+	for pages := 1; ; pages++ {
+		req := httptest.NewRequest(http.MethodGet, "/api/analyses?"+query, nil)
+		w := httptest.NewRecorder()
+		handler.mux.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var page analysisPage
+		if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if want := 7 + pages - 1; page.Total != want {
+			t.Errorf("Expected a total of %d analyses on page %d, got %d", want, pages, page.Total)
+		}
+		for _, analysis := range page.Items {
+			seen = append(seen, analysis.ID)
+		}
+		if page.HasMore != (page.NextCursor != "") {
+			t.Fatalf("Expected next_cursor exactly while has_more, got %+v", page)
+		}
+		if !page.HasMore {
+			if pages != 3 {
+				t.Errorf("Expected 3 pages, got %d", pages)
+			}
+			break
+		}
+
+		// Analyses added between pages count in the total but, being newer
+		// than the cursor, do not shift the later pages
+		analysis := &models.Analysis{ID: fmt.Sprintf("test-cursor-new-%d", pages), Text: "Test text", CreatedAt: time.Now(), UpdatedAt: time.Now()}
+		if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+			t.Fatalf("Failed to save test analysis: %v", err)
+		}
+		query = "limit=3&cursor=" + page.NextCursor
+	}
+
+	want := []string{"test-cursor-7", "test-cursor-6", "test-cursor-5", "test-cursor-4", "test-cursor-3", "test-cursor-2", "test-cursor-1"}
+	if !reflect.DeepEqual(seen, want) {
+		t.Errorf("Expected %v, got %v", want, seen)
+	}
+}
+
+func TestListAnalysesWithoutText(t *testing.T) {
+	handler, db, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	article := strings.Repeat("The pilot plant uses carbon capture to cut emissions. ", 200)
+	for i := 1; i <= 3; i++ {
+		analysis := &models.Analysis{
+			ID:           fmt.Sprintf("test-trim-%d", i),
+			Text:         article,
+			OriginalHTML: "H4sIAAAAAAAA/7IpyEhUSM7PS0nNSy9JTVFIT1VIyclMzyxJzUkFAAAA//8=",
+			Metadata: models.Metadata{
+				WordCount:            1800,
+				CleanedText:          article,
+				HeuristicCleanedText: article,
+				Tags:                 []string{"energy"},
+			},
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+		if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+			t.Fatalf("Failed to save test analysis: %v", err)
+		}
+	}
+
+	get := func(path string) (int, []map[string]interface{}) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		handler.mux.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200 from %s, got %d", path, w.Code)
+		}
+		length, err := strconv.Atoi(w.Header().Get("Content-Length"))
+		if err != nil || length != w.Body.Len() {
+			t.Fatalf("Expected Content-Length %d from %s, got %q", w.Body.Len(), path, w.Header().Get("Content-Length"))
+		}
+
+		var response struct {
+			Items []map[string]interface{} `json:"items"`
+		}
+		body := w.Body.Bytes()
+		if err := json.Unmarshal(body, &response); err != nil || response.Items == nil {
+			// Tag searches answer with a bare array
+			if err := json.Unmarshal(body, &response.Items); err != nil {
+				t.Fatalf("Failed to decode response from %s: %v", path, err)
+			}
+		}
+		return length, response.Items
+	}
+
+	for path, fullPath := range map[string]string{
+		"/api/analyses":                     "/api/analyses?include_text=true",
+		"/api/search?tag=energy":            "/api/search?tag=energy&include_text=true",
+		"/api/search/text?q=carbon+capture": "/api/search/text?q=carbon+capture&include_text=true",
+	} {
+		trimmedLength, trimmed := get(path)
+		fullLength, full := get(fullPath)
+
+		if len(trimmed) != 3 || len(full) != 3 {
+			t.Fatalf("Expected 3 analyses from %s, got %d and %d", path, len(trimmed), len(full))
+		}
+		for _, item := range trimmed {
+			if _, ok := item["text"]; ok {
+				t.Errorf("Expected %s to leave out the text, got it", path)
+			}
+			if _, ok := item["original_html"]; ok {
+				t.Errorf("Expected %s to leave out the original HTML, got it", path)
+			}
+			metadata, _ := item["metadata"].(map[string]interface{})
+			if metadata["cleaned_text"] != "" || metadata["heuristic_cleaned_text"] != "" {
+				t.Errorf("Expected %s to leave out the cleaned texts, got %v", path, metadata)
+			}
+			if metadata["word_count"] != float64(1800) {
+				t.Errorf("Expected %s to keep the rest of the metadata, got %v", path, metadata)
+			}
+		}
+		if full[0]["text"] != article {
+			t.Errorf("Expected %s with include_text=true to return the text", path)
+		}
+		if trimmedLength*10 > fullLength {
+			t.Errorf("Expected %s to shrink well below %d bytes without the text, got %d", path, fullLength, trimmedLength)
+		}
+	}
+
+	// A single analysis is still returned whole
+	req := httptest.NewRequest(http.MethodGet, "/api/analyses/test-trim-1", nil)
+	w := httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+
+	var analysis models.Analysis
+	if err := json.NewDecoder(w.Body).Decode(&analysis); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if analysis.Text != article || analysis.Metadata.CleanedText != article {
+		t.Errorf("Expected the single analysis to include its texts")
+	}
+}
+
 func TestDeleteAnalysisEndpoint(t *testing.T) {
 	handler, db, cleanup := setupTestHandler(t)
 	defer cleanup()
@@ -269,7 +925,7 @@ func TestDeleteAnalysisEndpoint(t *testing.T) {
 		UpdatedAt: time.Now(),
 	}
 
-	if err := db.SaveAnalysis(analysis); err != nil {
+	if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
 		t.Fatalf("Failed to save test analysis: %v", err)
 	}
 
@@ -283,43 +939,159 @@ func TestDeleteAnalysisEndpoint(t *testing.T) {
 	}
 
 	// Verify it's deleted
-	_, err := db.GetAnalysis("test-delete-001")
+	_, err := db.GetAnalysis(context.Background(), "test-delete-001")
 	if err == nil {
 		t.Error("Expected analysis to be deleted")
 	}
 }
 
-func TestSearchByTagEndpoint(t *testing.T) {
+func TestSoftDeleteRestoreEndpoint(t *testing.T) {
 	handler, db, cleanup := setupTestHandler(t)
 	defer cleanup()
+	handler.adminToken = "secret"
 
-	// Create test analyses with tags
-	analysis1 := &models.Analysis{
-		ID:   "test-search-001",
-		Text: "Test text 1",
+	analysis := &models.Analysis{
+		ID:   "test-restore-001",
+		Text: "Test text",
 		Metadata: models.Metadata{
 			WordCount: 2,
-			Tags:      []string{"positive", "long"},
+			Tags:      []string{"restorable"},
 		},
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
+	if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+		t.Fatalf("Failed to save test analysis: %v", err)
+	}
 
-	analysis2 := &models.Analysis{
-		ID:   "test-search-002",
-		Text: "Test text 2",
-		Metadata: models.Metadata{
-			WordCount: 2,
-			Tags:      []string{"positive", "short"},
+	serve := func(method, target, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		handler.mux.ServeHTTP(w, req)
+		return w
+	}
+	tagged := func() int {
+		w := serve(http.MethodGet, "/api/search?tag=restorable", "")
+		var analyses []models.Analysis
+		if err := json.NewDecoder(w.Body).Decode(&analyses); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return len(analyses)
+	}
+
+	if w := serve(http.MethodDelete, "/api/analyses/test-restore-001", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d", w.Code)
+	}
+	if w := serve(http.MethodGet, "/api/analyses/test-restore-001", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a deleted analysis, got %d", w.Code)
+	}
+	if n := tagged(); n != 0 {
+		t.Errorf("Expected a deleted analysis to be left out of tag search, got %d", n)
+	}
+
+	w := serve(http.MethodPost, "/api/analyses/test-restore-001/restore", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var restored models.Analysis
+	if err := json.NewDecoder(w.Body).Decode(&restored); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if restored.ID != analysis.ID {
+		t.Errorf("Expected the restored analysis, got %q", restored.ID)
+	}
+	if w := serve(http.MethodGet, "/api/analyses/test-restore-001", ""); w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for a restored analysis, got %d", w.Code)
+	}
+	if n := tagged(); n != 1 {
+		t.Errorf("Expected a restored analysis to keep its tag, got %d analyses", n)
+	}
+
+	// Hard delete needs the admin token and removes the analysis for good
+	if w := serve(http.MethodDelete, "/api/analyses/test-restore-001?hard=true", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 hard-deleting without a token, got %d", w.Code)
+	}
+	if w := serve(http.MethodDelete, "/api/analyses/test-restore-001?hard=true", "secret"); w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d", w.Code)
+	}
+	w = serve(http.MethodPost, "/api/analyses/test-restore-001/restore", "")
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 restoring a hard-deleted analysis, got %d", w.Code)
+	}
+	assertErrorCode(t, w, errorCodeNotFound)
+	if n := tagged(); n != 0 {
+		t.Errorf("Expected a hard-deleted analysis to lose its tag, got %d analyses", n)
+	}
+}
+
+func TestHardDeleteAuth(t *testing.T) {
+	handler := setupStatelessHandler()
+
+	for _, uri := range []string{"/api/analyses/abc?hard=true", "/api/uuid/abc?hard=true"} {
+		req := httptest.NewRequest(http.MethodDelete, uri, nil)
+		w := httptest.NewRecorder()
+		handler.mux.ServeHTTP(w, req)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("Expected status 403 for %s without a configured admin token, got %d", uri, w.Code)
+		}
+
+		handler.adminToken = "secret"
+		req = httptest.NewRequest(http.MethodDelete, uri, nil)
+		req.Header.Set("Authorization", "Bearer wrong")
+		w = httptest.NewRecorder()
+		handler.mux.ServeHTTP(w, req)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected status 401 for %s with a wrong token, got %d", uri, w.Code)
+		}
+		handler.adminToken = ""
+	}
+}
+
+func TestRestoreMethodNotAllowed(t *testing.T) {
+	handler := setupStatelessHandler()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/analyses/abc/restore", nil)
+	w := httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", w.Code)
+	}
+}
+
+func TestSearchByTagEndpoint(t *testing.T) {
+	handler, db, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	// Create test analyses with tags
+	analysis1 := &models.Analysis{
+		ID:   "test-search-001",
+		Text: "Test text 1",
+		Metadata: models.Metadata{
+			WordCount: 2,
+			Tags:      []string{"positive", "long"},
+		},
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+
+	analysis2 := &models.Analysis{
+		ID:   "test-search-002",
+		Text: "Test text 2",
+		Metadata: models.Metadata{
+			WordCount: 2,
+			Tags:      []string{"positive", "short"},
 		},
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
 
-	if err := db.SaveAnalysis(analysis1); err != nil {
+	if err := db.SaveAnalysis(context.Background(), analysis1); err != nil {
 		t.Fatalf("Failed to save test analysis 1: %v", err)
 	}
-	if err := db.SaveAnalysis(analysis2); err != nil {
+	if err := db.SaveAnalysis(context.Background(), analysis2); err != nil {
 		t.Fatalf("Failed to save test analysis 2: %v", err)
 	}
 
@@ -397,7 +1169,7 @@ func TestGetAnalysisByUUID(t *testing.T) {
 		UpdatedAt: time.Now(),
 	}
 
-	if err := db.SaveAnalysis(analysis); err != nil {
+	if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
 		t.Fatalf("Failed to save test analysis: %v", err)
 	}
 
@@ -442,7 +1214,7 @@ func TestDeleteAnalysisByUUID(t *testing.T) {
 		UpdatedAt: time.Now(),
 	}
 
-	if err := db.SaveAnalysis(analysis); err != nil {
+	if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
 		t.Fatalf("Failed to save test analysis: %v", err)
 	}
 
@@ -457,7 +1229,7 @@ func TestDeleteAnalysisByUUID(t *testing.T) {
 	}
 
 	// Verify it's deleted
-	_, err := db.GetAnalysisByUUID(uuid)
+	_, err := db.GetAnalysisByUUID(context.Background(), uuid)
 	if err == nil {
 		t.Error("Expected analysis to be deleted")
 	}
@@ -478,6 +1250,7 @@ func TestGetAnalysisByUUIDNotFound(t *testing.T) {
 	if w.Code != http.StatusNotFound {
 		t.Errorf("Expected status 404, got %d", w.Code)
 	}
+	assertErrorCode(t, w, errorCodeNotFound)
 }
 
 func TestDeleteAnalysisByUUIDNotFound(t *testing.T) {
@@ -495,4 +1268,2350 @@ func TestDeleteAnalysisByUUIDNotFound(t *testing.T) {
 	if w.Code != http.StatusNotFound {
 		t.Errorf("Expected status 404, got %d", w.Code)
 	}
+	assertErrorCode(t, w, errorCodeNotFound)
+}
+
+// setupStatelessHandler creates a handler without a database for endpoints
+// that never touch persistence
+func setupStatelessHandler() *Handler {
+	handler := &Handler{
+		analyzer:    analyzer.New(),
+		queueClient: &mockQueueClient{},
+		maxImages:   analyzer.DefaultMaxImages,
+		maxBody:     defaultMaxBodyBytes,
+		maxHTML:     defaultMaxHTMLBytes,
+		relatedTags: newRelatedTagsCache(relatedTagsCacheTTL),
+		stats:       newStatsCache(statsCacheTTL),
+		metrics:     NewMetrics(prometheus.NewRegistry()),
+		mux:         http.NewServeMux(),
+	}
+	handler.setupRoutes()
+	return handler
+}
+
+type segmentResponse struct {
+	Sentences      []analyzer.Span `json:"sentences"`
+	Paragraphs     []analyzer.Span `json:"paragraphs"`
+	SentenceCount  int             `json:"sentence_count"`
+	ParagraphCount int             `json:"paragraph_count"`
+}
+
+func TestSegmentEndpoint(t *testing.T) {
+	handler := setupStatelessHandler()
+
+	text := "Dr. Müller arrived at 3.30 p.m. today. He said “héllo wörld!” Then he left.\n\nZweiter Absatz über Café Zürich? Ja."
+
+	body, _ := json.Marshal(map[string]string{"text": text})
+	req := httptest.NewRequest(http.MethodPost, "/api/segment", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	handler.mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var response segmentResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	expectedSentences := []string{
+		"Dr. Müller arrived at 3.30 p.m. today.",
+		"He said “héllo wörld!”",
+		"Then he left.",
+		"Zweiter Absatz über Café Zürich?",
+		"Ja.",
+	}
+	if len(response.Sentences) != len(expectedSentences) {
+		t.Fatalf("Expected %d sentences, got %d: %+v", len(expectedSentences), len(response.Sentences), response.Sentences)
+	}
+	if response.SentenceCount != len(expectedSentences) || response.ParagraphCount != 2 {
+		t.Errorf("Unexpected counts: sentences=%d paragraphs=%d", response.SentenceCount, response.ParagraphCount)
+	}
+
+	// Offsets are rune-based, so slicing the rune array must reproduce each span
+	runes := []rune(text)
+	for i, sentence := range response.Sentences {
+		if sentence.Text != expectedSentences[i] {
+			t.Errorf("Sentence %d: expected %q, got %q", i, expectedSentences[i], sentence.Text)
+		}
+		if got := string(runes[sentence.Start:sentence.End]); got != sentence.Text {
+			t.Errorf("Sentence %d offsets [%d:%d] give %q, want %q", i, sentence.Start, sentence.End, got, sentence.Text)
+		}
+		if sentence.TokenCount == 0 {
+			t.Errorf("Sentence %d should have a token count", i)
+		}
+	}
+	for i, para := range response.Paragraphs {
+		if got := string(runes[para.Start:para.End]); got != para.Text {
+			t.Errorf("Paragraph %d offsets [%d:%d] give %q, want %q", i, para.Start, para.End, got, para.Text)
+		}
+	}
+}
+
+func TestSegmentEndpointSizeLimit(t *testing.T) {
+	handler := setupStatelessHandler()
+
+	tests := []struct {
+		name     string
+		text     string
+		expected int
+	}{
+		{"empty text", "", http.StatusBadRequest},
+		{"at limit", strings.Repeat("é", maxTextLength), http.StatusOK},
+		{"over limit", strings.Repeat("é", maxTextLength+1), http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(map[string]string{"text": tt.text})
+			req := httptest.NewRequest(http.MethodPost, "/api/segment", bytes.NewReader(body))
+			w := httptest.NewRecorder()
+
+			handler.mux.ServeHTTP(w, req)
+
+			if w.Code != tt.expected {
+				t.Errorf("Expected status %d, got %d", tt.expected, w.Code)
+			}
+		})
+	}
+}
+
+func TestSegmentEndpointInvalidMethod(t *testing.T) {
+	handler := setupStatelessHandler()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/segment", nil)
+	w := httptest.NewRecorder()
+
+	handler.mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", w.Code)
+	}
+}
+
+// gzipBase64 encodes HTML the way clients submit original_html
+func gzipBase64(t *testing.T, html string) string {
+	encoded, err := queue.CompressHTML(html)
+	if err != nil {
+		t.Fatalf("Failed to compress HTML: %v", err)
+	}
+	return encoded
+}
+
+func TestAnalyzeRequestSizeLimits(t *testing.T) {
+	handler := setupStatelessHandler()
+	handler.maxBody = 64 << 10
+	handler.maxHTML = 256 << 10
+	mockQueue := handler.queueClient.(*mockQueueClient)
+
+	tests := []struct {
+		name     string
+		body     map[string]string
+		expected int
+		message  string
+	}{
+		{
+			name:     "within limits",
+			body:     map[string]string{"text": "A short document.", "original_html": gzipBase64(t, "<p>A short document.</p>")},
+			expected: http.StatusAccepted,
+		},
+		{
+			name:     "body over limit",
+			body:     map[string]string{"text": strings.Repeat("word ", 20000)},
+			expected: http.StatusRequestEntityTooLarge,
+			message:  "Request body exceeds maximum size of 65536 bytes",
+		},
+		{
+			// Compresses to a few kilobytes, well within the body limit
+			name:     "decompressed HTML over limit",
+			body:     map[string]string{"text": "A short document.", "original_html": gzipBase64(t, strings.Repeat("<p>padding</p>", 20000))},
+			expected: http.StatusRequestEntityTooLarge,
+			message:  "original_html exceeds maximum decompressed size of 262144 bytes",
+		},
+		{
+			name:     "HTML not compressed",
+			body:     map[string]string{"text": "A short document.", "original_html": "<p>A short document.</p>"},
+			expected: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockQueue.enqueued = 0
+			body, _ := json.Marshal(tt.body)
+			req := httptest.NewRequest(http.MethodPost, "/api/analyze", bytes.NewReader(body))
+			w := httptest.NewRecorder()
+
+			handler.mux.ServeHTTP(w, req)
+
+			if w.Code != tt.expected {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expected, w.Code, w.Body.String())
+			}
+			if tt.expected != http.StatusAccepted && mockQueue.enqueued != 0 {
+				t.Errorf("Expected nothing enqueued, got %d tasks", mockQueue.enqueued)
+			}
+			if tt.message == "" {
+				return
+			}
+			var response map[string]interface{}
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Expected a JSON error, got %q", w.Body.String())
+			}
+			if response["error"] != tt.message {
+				t.Errorf("Expected error %q, got %v", tt.message, response["error"])
+			}
+			if response["code"] != errorCodeValidation {
+				t.Errorf("Expected code %q, got %v", errorCodeValidation, response["code"])
+			}
+		})
+	}
+}
+
+func TestAnalyzeEnrichmentThreshold(t *testing.T) {
+	thresholds := analyzer.NewEnrichmentThresholds(0.35, map[string]float64{
+		"memo":  0,
+		"forum": 0.5,
+	})
+
+	tests := []struct {
+		name              string
+		body              map[string]interface{}
+		expectedStatus    int
+		expectedThreshold float64
+	}{
+		{
+			name:              "default fallback",
+			body:              map[string]interface{}{},
+			expectedStatus:    http.StatusAccepted,
+			expectedThreshold: 0.35,
+		},
+		{
+			name:              "unknown source uses default",
+			body:              map[string]interface{}{"source": "blog"},
+			expectedStatus:    http.StatusAccepted,
+			expectedThreshold: 0.35,
+		},
+		{
+			name:              "source mapping",
+			body:              map[string]interface{}{"source": "Forum"},
+			expectedStatus:    http.StatusAccepted,
+			expectedThreshold: 0.5,
+		},
+		{
+			name:              "zero threshold source",
+			body:              map[string]interface{}{"source": "memo"},
+			expectedStatus:    http.StatusAccepted,
+			expectedThreshold: 0,
+		},
+		{
+			name:              "request override beats source",
+			body:              map[string]interface{}{"source": "forum", "enrichment_threshold": 0.2},
+			expectedStatus:    http.StatusAccepted,
+			expectedThreshold: 0.2,
+		},
+		{
+			name:           "override above range",
+			body:           map[string]interface{}{"enrichment_threshold": 1.5},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "override below range",
+			body:           map[string]interface{}{"enrichment_threshold": -0.1},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockQueue := &mockQueueClient{}
+			handler := setupStatelessHandler()
+			handler.queueClient = mockQueue
+			handler.thresholds = thresholds
+
+			tt.body["text"] = "This is a test text for analysis."
+			body, _ := json.Marshal(tt.body)
+			req := httptest.NewRequest(http.MethodPost, "/api/analyze", bytes.NewReader(body))
+			w := httptest.NewRecorder()
+
+			handler.mux.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedStatus != http.StatusAccepted {
+				return
+			}
+
+			if mockQueue.lastOptions.EnrichmentThreshold == nil {
+				t.Fatal("Expected enrichment threshold to be passed to the queue")
+			}
+			if got := *mockQueue.lastOptions.EnrichmentThreshold; got != tt.expectedThreshold {
+				t.Errorf("Expected threshold %v, got %v", tt.expectedThreshold, got)
+			}
+
+			var response map[string]interface{}
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response["enrichment_threshold"] != tt.expectedThreshold {
+				t.Errorf("Expected response threshold %v, got %v", tt.expectedThreshold, response["enrichment_threshold"])
+			}
+		})
+	}
+}
+
+func TestAnalyzeEnrichmentOverrides(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           map[string]interface{}
+		expectedStatus int
+		force, skip    bool
+	}{
+		{"gated", map[string]interface{}{"enrichment_threshold": 0.9}, http.StatusAccepted, false, false},
+		{"forced", map[string]interface{}{"enrichment_threshold": 0.9, "force_enrichment": true}, http.StatusAccepted, true, false},
+		{"skipped", map[string]interface{}{"skip_enrichment": true}, http.StatusAccepted, false, true},
+		{"forced and skipped", map[string]interface{}{"force_enrichment": true, "skip_enrichment": true}, http.StatusBadRequest, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockQueue := &mockQueueClient{}
+			handler := setupStatelessHandler()
+			handler.queueClient = mockQueue
+
+			tt.body["text"] = "This is a test text for analysis."
+			body, _ := json.Marshal(tt.body)
+			req := httptest.NewRequest(http.MethodPost, "/api/analyze", bytes.NewReader(body))
+			w := httptest.NewRecorder()
+
+			handler.mux.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedStatus != http.StatusAccepted {
+				if !strings.Contains(w.Body.String(), "skip_enrichment") {
+					t.Errorf("Expected the error to name skip_enrichment, got %s", w.Body.String())
+				}
+				return
+			}
+
+			if mockQueue.lastOptions.ForceEnrichment != tt.force || mockQueue.lastOptions.SkipEnrichment != tt.skip {
+				t.Errorf("Expected force %v and skip %v in the queued options, got %+v", tt.force, tt.skip, mockQueue.lastOptions)
+			}
+			var response map[string]interface{}
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if (response["force_enrichment"] == true) != tt.force || (response["skip_enrichment"] == true) != tt.skip {
+				t.Errorf("Expected force %v and skip %v in the response, got %v", tt.force, tt.skip, response)
+			}
+		})
+	}
+}
+
+func TestAnalyzeRedactPII(t *testing.T) {
+	for _, redact := range []bool{false, true} {
+		mockQueue := &mockQueueClient{}
+		handler := setupStatelessHandler()
+		handler.queueClient = mockQueue
+
+		body, _ := json.Marshal(map[string]interface{}{"text": "Call 555-234-5678 about the order.", "redact_pii": redact})
+		req := httptest.NewRequest(http.MethodPost, "/api/analyze", bytes.NewReader(body))
+		w := httptest.NewRecorder()
+
+		handler.mux.ServeHTTP(w, req)
+
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("Expected status 202, got %d: %s", w.Code, w.Body.String())
+		}
+		if mockQueue.lastOptions.RedactPII != redact {
+			t.Errorf("Expected redact_pii %v in the queued options, got %+v", redact, mockQueue.lastOptions)
+		}
+		var response map[string]interface{}
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if (response["redact_pii"] == true) != redact {
+			t.Errorf("Expected redact_pii %v in the response, got %v", redact, response)
+		}
+	}
+}
+
+func TestAnalyzeFormat(t *testing.T) {
+	tests := []struct {
+		format     string
+		wantStatus int
+	}{
+		{"", http.StatusAccepted},
+		{"auto", http.StatusAccepted},
+		{"markdown", http.StatusAccepted},
+		{"text", http.StatusAccepted},
+		{"html", http.StatusBadRequest},
+		{"Markdown", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			mockQueue := &mockQueueClient{}
+			handler := setupStatelessHandler()
+			handler.queueClient = mockQueue
+
+			body, _ := json.Marshal(map[string]string{"text": "# Notes\n\nSee the [guide](https://example.com/guide).", "format": tt.format})
+			req := httptest.NewRequest(http.MethodPost, "/api/analyze", bytes.NewReader(body))
+			w := httptest.NewRecorder()
+
+			handler.mux.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusAccepted {
+				if !strings.Contains(w.Body.String(), "Invalid format") {
+					t.Errorf("Expected an invalid format error, got %s", w.Body.String())
+				}
+				return
+			}
+			if mockQueue.lastOptions.Format != tt.format {
+				t.Errorf("Expected format %q in the queued options, got %q", tt.format, mockQueue.lastOptions.Format)
+			}
+		})
+	}
+}
+
+func TestAnalyzeEnrichmentThresholdUnconfigured(t *testing.T) {
+	mockQueue := &mockQueueClient{}
+	handler := setupStatelessHandler()
+	handler.queueClient = mockQueue
+
+	body, _ := json.Marshal(map[string]string{"text": "This is a test text.", "source": "memo"})
+	req := httptest.NewRequest(http.MethodPost, "/api/analyze", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	handler.mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := *mockQueue.lastOptions.EnrichmentThreshold; got != analyzer.DefaultEnrichmentThreshold {
+		t.Errorf("Expected default threshold %v, got %v", analyzer.DefaultEnrichmentThreshold, got)
+	}
+	if mockQueue.lastOptions.Source != "memo" {
+		t.Errorf("Expected source 'memo', got %q", mockQueue.lastOptions.Source)
+	}
+}
+
+func TestAnalyzePriority(t *testing.T) {
+	tests := []struct {
+		name         string
+		priority     string
+		wantStatus   int
+		wantPriority string
+	}{
+		{"default", "", http.StatusAccepted, "normal"},
+		{"high", "high", http.StatusAccepted, "high"},
+		{"normal", "normal", http.StatusAccepted, "normal"},
+		{"low", "low", http.StatusAccepted, "low"},
+		{"invalid", "urgent", http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockQueue := &mockQueueClient{}
+			handler := setupStatelessHandler()
+			handler.queueClient = mockQueue
+
+			reqBody := map[string]string{"text": "This is a test text."}
+			if tt.priority != "" {
+				reqBody["priority"] = tt.priority
+			}
+			body, _ := json.Marshal(reqBody)
+			req := httptest.NewRequest(http.MethodPost, "/api/analyze", bytes.NewReader(body))
+			w := httptest.NewRecorder()
+
+			handler.mux.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusAccepted {
+				return
+			}
+
+			if mockQueue.lastOptions.Priority != tt.wantPriority {
+				t.Errorf("Expected enqueued priority %q, got %q", tt.wantPriority, mockQueue.lastOptions.Priority)
+			}
+
+			var response map[string]interface{}
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response["priority"] != tt.wantPriority {
+				t.Errorf("Expected response priority %q, got %v", tt.wantPriority, response["priority"])
+			}
+		})
+	}
+}
+
+func TestAnalyzeImageLimits(t *testing.T) {
+	images := []string{
+		"https://example.com/a.jpg",
+		"https://example.com/b.jpg",
+		"https://example.com/a.jpg",
+		"https://example.com/c.jpg",
+		"https://example.com/d.jpg",
+	}
+
+	tests := []struct {
+		name         string
+		images       []string
+		truncate     bool
+		wantStatus   int
+		wantImages   []string
+		wantSkipped  float64
+		wantWarnings bool
+	}{
+		{"within limit", images[:3], false, http.StatusAccepted,
+			[]string{"https://example.com/a.jpg", "https://example.com/b.jpg"}, 1, false},
+		{"over limit rejected", images, false, http.StatusBadRequest, nil, 0, false},
+		{"over limit truncated", images, true, http.StatusAccepted,
+			[]string{"https://example.com/a.jpg", "https://example.com/b.jpg", "https://example.com/c.jpg"}, 2, true},
+		{"invalid scheme", []string{"javascript:alert(1)"}, true, http.StatusBadRequest, nil, 0, false},
+		{"missing host", []string{"https:///a.jpg"}, true, http.StatusBadRequest, nil, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockQueue := &mockQueueClient{}
+			handler := setupStatelessHandler()
+			handler.queueClient = mockQueue
+			handler.maxImages = 3
+			handler.truncate = tt.truncate
+
+			body, _ := json.Marshal(map[string]interface{}{"text": "This is a test text.", "images": tt.images})
+			req := httptest.NewRequest(http.MethodPost, "/api/analyze", bytes.NewReader(body))
+			w := httptest.NewRecorder()
+
+			handler.mux.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusAccepted {
+				return
+			}
+
+			if !reflect.DeepEqual(mockQueue.lastImages, tt.wantImages) {
+				t.Errorf("Expected enqueued images %v, got %v", tt.wantImages, mockQueue.lastImages)
+			}
+			if mockQueue.lastOptions.ImagesSubmitted != len(tt.images) {
+				t.Errorf("Expected %d submitted images, got %d", len(tt.images), mockQueue.lastOptions.ImagesSubmitted)
+			}
+
+			var response map[string]interface{}
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response["images_accepted"] != float64(len(tt.wantImages)) || response["images_skipped"] != tt.wantSkipped {
+				t.Errorf("Expected %d accepted and %v skipped, got %v and %v",
+					len(tt.wantImages), tt.wantSkipped, response["images_accepted"], response["images_skipped"])
+			}
+			if _, ok := response["warnings"]; ok != tt.wantWarnings {
+				t.Errorf("Expected warnings present=%v, got %v", tt.wantWarnings, response["warnings"])
+			}
+		})
+	}
+}
+
+func TestAnalyzeEnrichmentOptions(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured *models.EnrichmentOptions
+		overrides  map[string]bool
+		wantStatus int
+		want       models.EnrichmentOptions
+	}{
+		{"default enables all", nil, nil, http.StatusAccepted, analyzer.AllEnrichmentSteps()},
+		{"configured steps", &models.EnrichmentOptions{Synopsis: true, Tags: true}, nil, http.StatusAccepted,
+			models.EnrichmentOptions{Synopsis: true, Tags: true}},
+		{"request override", &models.EnrichmentOptions{Synopsis: true, Tags: true},
+			map[string]bool{"tags": false, "quality": true}, http.StatusAccepted,
+			models.EnrichmentOptions{Synopsis: true, Quality: true}},
+		{"unknown step", nil, map[string]bool{"summary": false}, http.StatusBadRequest, models.EnrichmentOptions{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockQueue := &mockQueueClient{}
+			handler := setupStatelessHandler()
+			handler.queueClient = mockQueue
+			handler.enrichment = tt.configured
+
+			body, _ := json.Marshal(map[string]interface{}{"text": "This is a test text.", "enrichment": tt.overrides})
+			req := httptest.NewRequest(http.MethodPost, "/api/analyze", bytes.NewReader(body))
+			w := httptest.NewRecorder()
+
+			handler.mux.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusAccepted {
+				return
+			}
+
+			if mockQueue.lastOptions.Enrichment == nil || *mockQueue.lastOptions.Enrichment != tt.want {
+				t.Errorf("Expected enqueued enrichment %+v, got %+v", tt.want, mockQueue.lastOptions.Enrichment)
+			}
+		})
+	}
+}
+
+func TestAnalyzeSynopsisOptions(t *testing.T) {
+	mockQueue := &mockQueueClient{}
+	handler := setupStatelessHandler()
+	handler.queueClient = mockQueue
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"text":               "This is a test text.",
+		"synopsis_style":     "teaser",
+		"synopsis_max_words": 20,
+	})
+	req := httptest.NewRequest(http.MethodPost, "/api/analyze", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %s", w.Code, w.Body.String())
+	}
+	if mockQueue.lastOptions.SynopsisStyle != "teaser" || mockQueue.lastOptions.SynopsisMaxWords != 20 {
+		t.Errorf("Expected teaser with 20 words, got %+v", mockQueue.lastOptions)
+	}
+
+	invalid := []map[string]interface{}{
+		{"text": "This is a test text.", "synopsis_style": "haiku"},
+		{"text": "This is a test text.", "synopsis_max_words": -5},
+	}
+	for _, reqBody := range invalid {
+		body, _ := json.Marshal(reqBody)
+		req := httptest.NewRequest(http.MethodPost, "/api/analyze", bytes.NewReader(body))
+		w := httptest.NewRecorder()
+		handler.mux.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %v, got %d", reqBody, w.Code)
+		}
+	}
+}
+
+func TestReanalyzeSynopsisStyle(t *testing.T) {
+	handler, db, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	analysis := &models.Analysis{
+		ID:   "test-reanalyze-001",
+		Text: "The council approved the plan. It adds twelve routes. Work begins in spring. Critics object. Supporters cheer.",
+		Metadata: models.Metadata{
+			Synopsis:      "The council approved the plan. It adds twelve routes. Work begins in spring.",
+			SynopsisStyle: "standard",
+		},
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+		t.Fatalf("Failed to save test analysis: %v", err)
+	}
+
+	body, _ := json.Marshal(map[string]string{"synopsis_style": "teaser"})
+	req := httptest.NewRequest(http.MethodPost, "/api/analyses/test-reanalyze-001/reanalyze", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	stored, err := db.GetAnalysis(context.Background(), "test-reanalyze-001")
+	if err != nil {
+		t.Fatalf("Failed to get analysis: %v", err)
+	}
+	if stored.Metadata.SynopsisStyle != "teaser" {
+		t.Errorf("Expected stored style 'teaser', got %q", stored.Metadata.SynopsisStyle)
+	}
+	if stored.Metadata.Synopsis != "The council approved the plan." {
+		t.Errorf("Expected one-sentence synopsis, got %q", stored.Metadata.Synopsis)
+	}
+	if status := stored.Metadata.EnrichmentStatus[analyzer.StepSynopsis]; status != analyzer.StepStatusFallback {
+		t.Errorf("Expected synopsis status %q without Ollama, got %q", analyzer.StepStatusFallback, status)
+	}
+
+	// Invalid style, unknown analysis and wrong method
+	body, _ = json.Marshal(map[string]string{"synopsis_style": "haiku"})
+	req = httptest.NewRequest(http.MethodPost, "/api/analyses/test-reanalyze-001/reanalyze", bytes.NewReader(body))
+	w = httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for invalid style, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/analyses/missing/reanalyze", nil)
+	w = httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for unknown analysis, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/analyses/test-reanalyze-001/reanalyze", nil)
+	w = httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", w.Code)
+	}
+}
+
+func TestAnalysisProvenance(t *testing.T) {
+	handler, db, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	threshold := 0.4
+	metadata := models.Metadata{
+		Synopsis:            "The council approved the plan.",
+		EnrichmentThreshold: &threshold,
+	}
+	metadata.Provenance = handler.analyzer.Provenance(analyzer.RecordedOptions(metadata))
+	metadata.Provenance.Model = "model-a"
+	analysis := &models.Analysis{
+		ID:        "test-provenance-001",
+		Text:      "The council approved the plan. It adds twelve routes. Work begins in spring.",
+		Metadata:  metadata,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+		t.Fatalf("Failed to save test analysis: %v", err)
+	}
+
+	// A synopsis-only reanalysis keeps the enrichment snapshot
+	body, _ := json.Marshal(map[string]string{"synopsis_style": "teaser"})
+	req := httptest.NewRequest(http.MethodPost, "/api/analyses/test-provenance-001/reanalyze", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 for reanalyze, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/analyses/test-provenance-001/provenance", nil)
+	w = httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var response struct {
+		Provenance *models.Provenance `json:"provenance"`
+		Current    *models.Provenance `json:"current"`
+		Changes    []string           `json:"changes"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Provenance == nil || response.Provenance.Model != "model-a" || response.Provenance.EnrichmentThreshold != 0.4 {
+		t.Fatalf("Expected the stored snapshot to survive reanalysis, got %+v", response.Provenance)
+	}
+
+	// The model and the reanalyzed synopsis style differ from the snapshot
+	want := []string{"model", "synopsis_style"}
+	if !reflect.DeepEqual(response.Changes, want) {
+		t.Errorf("Expected changes %v, got %v", want, response.Changes)
+	}
+	if response.Current == nil || response.Current.SynopsisStyle != "teaser" {
+		t.Errorf("Expected the current configuration with the teaser style, got %+v", response.Current)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/analyses/missing/provenance", nil)
+	w = httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for unknown analysis, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/analyses/test-provenance-001/provenance", nil)
+	w = httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", w.Code)
+	}
+}
+
+func TestAnalysisRevisionEndpoints(t *testing.T) {
+	handler, db, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	analysis := &models.Analysis{
+		ID:   "test-revisions-001",
+		Text: "The city council approved a new transit plan.",
+		Metadata: models.Metadata{
+			Synopsis:        "The city council approved a new transit plan.",
+			Tags:            []string{"transit", "infrastructure"},
+			EnrichmentModel: "model-b",
+		},
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+		t.Fatalf("Failed to save test analysis: %v", err)
+	}
+	revision := &models.AnalysisRevision{
+		AnalysisID: analysis.ID,
+		Fields: models.RevisionFields{
+			Synopsis: "The council approved a transit plan.",
+			Tags:     []string{"transit", "budget"},
+		},
+		Model: "model-a",
+	}
+	if err := db.SaveAnalysisRevision(context.Background(), revision); err != nil {
+		t.Fatalf("Failed to save revision: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/analyses/test-revisions-001/revisions", nil)
+	w := httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var list struct {
+		Revisions []models.AnalysisRevision `json:"revisions"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(list.Revisions) != 1 || list.Revisions[0].Model != "model-a" {
+		t.Fatalf("Unexpected revisions: %+v", list.Revisions)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/analyses/test-revisions-001/revisions/1/diff", nil)
+	w = httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var diff analyzer.RevisionDiff
+	if err := json.NewDecoder(w.Body).Decode(&diff); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if diff.FromModel != "model-a" || diff.ToModel != "model-b" {
+		t.Errorf("Expected model-a -> model-b, got %s -> %s", diff.FromModel, diff.ToModel)
+	}
+	if len(diff.TagsAdded) != 1 || diff.TagsAdded[0] != "infrastructure" {
+		t.Errorf("Unexpected tags added: %v", diff.TagsAdded)
+	}
+	if len(diff.TagsRemoved) != 1 || diff.TagsRemoved[0] != "budget" {
+		t.Errorf("Unexpected tags removed: %v", diff.TagsRemoved)
+	}
+	if !diff.Synopsis.Changed || len(diff.Synopsis.Edits) == 0 {
+		t.Errorf("Expected synopsis diff, got %+v", diff.Synopsis)
+	}
+
+	for path, code := range map[string]int{
+		"/api/analyses/test-revisions-001/revisions/2/diff":   http.StatusNotFound,
+		"/api/analyses/test-revisions-001/revisions/abc/diff": http.StatusBadRequest,
+		"/api/analyses/missing/revisions":                     http.StatusNotFound,
+	} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		handler.mux.ServeHTTP(w, req)
+		if w.Code != code {
+			t.Errorf("%s: expected status %d, got %d", path, code, w.Code)
+		}
+	}
+}
+
+func TestGetAnalysisIncludesImages(t *testing.T) {
+	handler, db, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	analysis := &models.Analysis{
+		ID:        "test-images-001",
+		Text:      "The city council approved a new transit plan.",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+		t.Fatalf("Failed to save test analysis: %v", err)
+	}
+	image := &models.ImageMetadata{
+		AnalysisID: analysis.ID,
+		URL:        "https://cdn.example.com/map.png",
+		Domain:     "cdn.example.com",
+		Format:     "png",
+		Width:      640,
+		Height:     480,
+	}
+	if err := db.SaveImageMetadata(context.Background(), image); err != nil {
+		t.Fatalf("Failed to save image: %v", err)
+	}
+
+	if err := db.UpdateProcessingStage(context.Background(), analysis.ID, models.ProcessingStageEnriched); err != nil {
+		t.Fatalf("Failed to update processing stage: %v", err)
+	}
+
+	// Every response returning the full analysis includes its images
+	for _, path := range []string{"/api/analyses/test-images-001", "/api/uuid/test-images-001", "/api/jobs/test-images-001"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		handler.mux.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d: %s", path, w.Code, w.Body.String())
+		}
+		var got struct {
+			models.Analysis
+			Job *models.Analysis `json:"analysis"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+			t.Fatalf("%s: failed to decode response: %v", path, err)
+		}
+		images := got.Images
+		if got.Job != nil {
+			images = got.Job.Images
+		}
+		if len(images) != 1 || images[0].URL != image.URL || images[0].Width != 640 {
+			t.Errorf("%s: unexpected images: %+v", path, images)
+		}
+	}
+}
+
+func TestAnalysisHistoryEndpoint(t *testing.T) {
+	handler, db, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	analysis := &models.Analysis{
+		ID:        "test-history-001",
+		Text:      "The city council approved a new transit plan.",
+		Metadata:  models.Metadata{Tags: []string{"transit"}, CleanedText: "The city council approved a new transit plan."},
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+		t.Fatalf("Failed to save test analysis: %v", err)
+	}
+	entry := &models.AnalysisHistoryEntry{
+		AnalysisID: analysis.ID,
+		Stage:      models.HistoryStageOffline,
+		TaskID:     "task-1",
+		Metadata:   analysis.Metadata,
+	}
+	if err := db.SaveAnalysisHistory(context.Background(), entry); err != nil {
+		t.Fatalf("Failed to save history entry: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/analyses/test-history-001/history", nil)
+	w := httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var list struct {
+		History []models.AnalysisHistoryEntry `json:"history"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(list.History) != 1 || list.History[0].Stage != models.HistoryStageOffline || list.History[0].TaskID != "task-1" {
+		t.Fatalf("Unexpected history: %+v", list.History)
+	}
+	if list.History[0].Metadata.CleanedText != "" {
+		t.Error("Expected history to leave out the cleaned text")
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/analyses/missing/history", nil)
+	w = httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a missing analysis, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/analyses/test-history-001/history", nil)
+	w = httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", w.Code)
+	}
+}
+
+func TestJobStatusEnrichmentStatus(t *testing.T) {
+	handler, db, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	enrichedAt := time.Now()
+	statuses := map[string]string{
+		analyzer.StepSynopsis:  analyzer.StepStatusDone,
+		analyzer.StepEditorial: analyzer.StepStatusFailed,
+		analyzer.StepTags:      analyzer.StepStatusSkippedDisabled,
+	}
+	analysis := &models.Analysis{
+		ID:   "test-enrichment-status-001",
+		Text: "The council approved the plan.",
+		Metadata: models.Metadata{
+			Synopsis:         "The council approved the plan.",
+			EnrichedAt:       &enrichedAt,
+			EnrichmentStatus: statuses,
+		},
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+		t.Fatalf("Failed to save test analysis: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/jobs/test-enrichment-status-001", nil)
+	w := httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var response struct {
+		EnrichmentStatus string            `json:"enrichment_status"`
+		EnrichmentSteps  map[string]string `json:"enrichment_steps"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.EnrichmentStatus != analyzer.EnrichmentStatusDegraded {
+		t.Errorf("Expected aggregate status %q, got %q", analyzer.EnrichmentStatusDegraded, response.EnrichmentStatus)
+	}
+	if !reflect.DeepEqual(response.EnrichmentSteps, statuses) {
+		t.Errorf("Expected step statuses %v, got %v", statuses, response.EnrichmentSteps)
+	}
+}
+
+func TestJobStatusFromStage(t *testing.T) {
+	enrichedAt := time.Now()
+	pending := &models.Analysis{Metadata: models.Metadata{CleanedText: "Offline cleaned text."}}
+	enriched := &models.Analysis{Metadata: models.Metadata{EnrichedAt: &enrichedAt}}
+	lowQuality := &models.Analysis{Metadata: models.Metadata{EnrichmentSkipped: true}}
+	notRequested := &models.Analysis{Metadata: models.Metadata{SkipEnrichment: true}}
+	legacyLowScore := &models.Analysis{Metadata: models.Metadata{QualityScore: &models.TextQualityScore{Score: 0.2}}}
+	legacyForced := &models.Analysis{Metadata: models.Metadata{QualityScore: &models.TextQualityScore{Score: 0.2}, ForceEnrichment: true}}
+
+	tests := []struct {
+		name     string
+		analysis *models.Analysis
+		stage    string
+		status   string
+		skipped  bool
+	}{
+		{"offline complete", pending, models.ProcessingStageOfflineComplete, "processing", false},
+		{"offline complete below threshold", lowQuality, models.ProcessingStageOfflineComplete, "completed_offline_only", true},
+		{"enriching", pending, models.ProcessingStageEnriching, "processing", false},
+		{"awaiting retry", pending, models.ProcessingStageEnrichmentFailed, "processing", false},
+		{"enriched", enriched, models.ProcessingStageEnriched, "completed", false},
+		{"failed", pending, models.ProcessingStageFailed, "failed", false},
+		{"cancelled", lowQuality, models.ProcessingStageCancelled, "cancelled", false},
+		{"legacy enriched", enriched, models.ProcessingStageOffline, "completed", false},
+		{"legacy cleaned", pending, models.ProcessingStageOffline, "completed", false},
+		{"legacy below threshold", lowQuality, models.ProcessingStageOffline, "completed_offline_only", true},
+		{"skip requested", notRequested, models.ProcessingStageOfflineComplete, "completed_offline_only", true},
+		{"unrecorded gate below threshold", legacyLowScore, models.ProcessingStageOfflineComplete, "completed_offline_only", true},
+		{"unrecorded gate forced", legacyForced, models.ProcessingStageOfflineComplete, "processing", false},
+		{"legacy pending", &models.Analysis{}, models.ProcessingStageOffline, "processing", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, skipped := jobStatus(tt.analysis, tt.stage, analyzer.DefaultEnrichmentThreshold)
+			if status != tt.status || skipped != tt.skipped {
+				t.Errorf("Expected %q (skipped %v), got %q (skipped %v)", tt.status, tt.skipped, status, skipped)
+			}
+		})
+	}
+}
+
+func TestJobStatusFailed(t *testing.T) {
+	handler, db, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	analysis := &models.Analysis{
+		ID:        "test-job-failed-001",
+		Text:      "The council approved the plan.",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+		t.Fatalf("Failed to save test analysis: %v", err)
+	}
+	if err := db.UpdateProcessingStage(context.Background(), analysis.ID, models.ProcessingStageEnriching); err != nil {
+		t.Fatalf("Failed to update processing stage: %v", err)
+	}
+	if err := db.MarkEnrichmentFailed(context.Background(), analysis.ID, 10, 10, fmt.Errorf("failed to update enriched analysis")); err != nil {
+		t.Fatalf("Failed to mark enrichment failed: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/jobs/test-job-failed-001", nil)
+	w := httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var response struct {
+		Status          string           `json:"status"`
+		ProcessingStage string           `json:"processing_stage"`
+		RetryCount      int              `json:"retry_count"`
+		LastError       string           `json:"last_error"`
+		CompletedAt     *time.Time       `json:"completed_at"`
+		Analysis        *models.Analysis `json:"analysis"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Status != "failed" || response.ProcessingStage != models.ProcessingStageFailed {
+		t.Errorf("Expected a failed job, got status %q stage %q", response.Status, response.ProcessingStage)
+	}
+	if response.RetryCount != 10 || response.LastError != "failed to update enriched analysis" {
+		t.Errorf("Expected 10 retries and the last error, got %d and %q", response.RetryCount, response.LastError)
+	}
+	if response.CompletedAt == nil {
+		t.Error("Expected completed_at for a failed job")
+	}
+	if response.Analysis == nil {
+		t.Error("Expected the offline analysis for a failed job")
+	}
+}
+
+// fakeTaskInspector returns fixed task states for every job. Cancelling
+// deletes the tasks waiting to run or to retry.
+type fakeTaskInspector struct {
+	tasks   []queue.TaskStatus
+	err     error
+	cleared []string // Jobs whose finished enrichment tasks were cleared
+}
+
+func (f *fakeTaskInspector) FamilyTasks(analysisID string, imageCount int) ([]queue.TaskStatus, error) {
+	return f.tasks, f.err
+}
+
+func (f *fakeTaskInspector) ClearEnrichmentTasks(analysisID string, imageCount int) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.cleared = append(f.cleared, analysisID)
+	return nil
+}
+
+func (f *fakeTaskInspector) CancelFamily(analysisID string, imageCount int) (*queue.CancelResult, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	result := &queue.CancelResult{}
+	for i, task := range f.tasks {
+		switch task.State {
+		case "active":
+			result.Active = append(result.Active, task)
+		case "pending", "scheduled", "retry":
+			result.Deleted = append(result.Deleted, task)
+			f.tasks[i].State = queue.TaskStateNotFound
+		}
+	}
+	return result, nil
+}
+
+func TestQueueJobStatus(t *testing.T) {
+	task := func(id, state string) queue.TaskStatus {
+		return queue.TaskStatus{TaskID: id, State: state}
+	}
+
+	tests := []struct {
+		name   string
+		tasks  []queue.TaskStatus
+		status string
+		taskID string
+	}{
+		{"not queued", []queue.TaskStatus{task("doc-1", queue.TaskStateNotFound), task("doc-1-text-enrich", queue.TaskStateNotFound)}, "", ""},
+		{"completed", []queue.TaskStatus{task("doc-1", "completed"), task("doc-1-text-enrich", "completed")}, "", ""},
+		{"pending", []queue.TaskStatus{task("doc-1", "pending"), task("doc-1-text-enrich", queue.TaskStateNotFound)}, jobStatusPending, "doc-1"},
+		{"scheduled", []queue.TaskStatus{task("doc-1", "completed"), task("doc-1-text-enrich", "scheduled")}, jobStatusPending, "doc-1-text-enrich"},
+		{"active", []queue.TaskStatus{task("doc-1", "completed"), task("doc-1-text-enrich", "pending"), task("doc-1-image-enrich-0", "active")}, jobStatusActive, "doc-1-image-enrich-0"},
+		{"retrying", []queue.TaskStatus{task("doc-1", "completed"), task("doc-1-text-enrich", "retry"), task("doc-1-image-enrich-0", "pending")}, jobStatusRetrying, "doc-1-text-enrich"},
+		{"archived", []queue.TaskStatus{task("doc-1", "archived"), task("doc-1-text-enrich", queue.TaskStateNotFound)}, jobStatusArchived, "doc-1"},
+		{"archived image while enriching", []queue.TaskStatus{task("doc-1", "completed"), task("doc-1-text-enrich", "active"), task("doc-1-image-enrich-0", "archived")}, jobStatusActive, "doc-1-text-enrich"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, decided := queueJobStatus(tt.tasks)
+			if status != tt.status {
+				t.Errorf("Expected status %q, got %q", tt.status, status)
+			}
+			if tt.taskID == "" && decided != nil {
+				t.Errorf("Expected no deciding task, got %s", decided.TaskID)
+			}
+			if tt.taskID != "" && (decided == nil || decided.TaskID != tt.taskID) {
+				t.Errorf("Expected task %s to decide the status, got %+v", tt.taskID, decided)
+			}
+		})
+	}
+}
+
+func TestJobStatusFromQueue(t *testing.T) {
+	handler, db, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	nextRetry := time.Now().Add(time.Minute).UTC().Truncate(time.Second)
+	inspector := &fakeTaskInspector{}
+	handler.inspector = inspector
+
+	getJob := func(id string) (int, map[string]interface{}) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/jobs/"+id, nil)
+		w := httptest.NewRecorder()
+		handler.mux.ServeHTTP(w, req)
+
+		var response map[string]interface{}
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return w.Code, response
+	}
+
+	// Queued before the analysis is saved
+	inspector.tasks = []queue.TaskStatus{{TaskID: "test-queued-001", Type: queue.TypeProcessDocument, State: "pending", Queue: "offline-processing"}}
+	code, response := getJob("test-queued-001")
+	if code != http.StatusOK || response["status"] != jobStatusPending {
+		t.Errorf("Expected a pending job, got %d %v", code, response)
+	}
+
+	// Archived after its retries ran out
+	inspector.tasks = []queue.TaskStatus{{TaskID: "test-queued-001", Type: queue.TypeProcessDocument, State: "archived", Retried: 3, MaxRetry: 3, LastError: "connection refused"}}
+	code, response = getJob("test-queued-001")
+	if code != http.StatusOK || response["status"] != jobStatusArchived || response["last_error"] != "connection refused" {
+		t.Errorf("Expected an archived job with its last error, got %d %v", code, response)
+	}
+	if !strings.Contains(fmt.Sprint(response["message"]), "archived after 3 retries") {
+		t.Errorf("Expected the archive explained, got %v", response["message"])
+	}
+
+	// Saved offline, with enrichment waiting to retry
+	analysis := &models.Analysis{
+		ID:        "test-queued-002",
+		Text:      "The council approved the plan.",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+		t.Fatalf("Failed to save test analysis: %v", err)
+	}
+	if err := db.UpdateProcessingStage(context.Background(), analysis.ID, models.ProcessingStageEnrichmentFailed); err != nil {
+		t.Fatalf("Failed to update processing stage: %v", err)
+	}
+	inspector.tasks = []queue.TaskStatus{
+		{TaskID: "test-queued-002", Type: queue.TypeProcessDocument, State: "completed"},
+		{TaskID: "test-queued-002-text-enrich", Type: queue.TypeEnrichText, State: "retry", Retried: 2, MaxRetry: 10, NextProcessAt: &nextRetry, LastError: "ollama timeout"},
+	}
+	code, response = getJob("test-queued-002")
+	if code != http.StatusOK || response["status"] != jobStatusRetrying {
+		t.Fatalf("Expected a retrying job, got %d %v", code, response)
+	}
+	if response["retry_count"] != float64(2) || response["next_retry_at"] != nextRetry.Format(time.RFC3339) || response["last_error"] != "ollama timeout" {
+		t.Errorf("Expected the retry count, next retry and error of the task, got %v", response)
+	}
+	if response["processing_stage"] != models.ProcessingStageEnrichmentFailed {
+		t.Errorf("Expected the stored stage, got %v", response["processing_stage"])
+	}
+
+	// A stored outcome is kept over the queue state
+	if err := db.MarkEnrichmentFailed(context.Background(), analysis.ID, 10, 10, fmt.Errorf("ollama timeout")); err != nil {
+		t.Fatalf("Failed to mark enrichment failed: %v", err)
+	}
+	inspector.tasks[1].State = "archived"
+	code, response = getJob("test-queued-002")
+	if code != http.StatusOK || response["status"] != "failed" || response["queue"] == nil {
+		t.Errorf("Expected a failed job with its queue state, got %d %v", code, response)
+	}
+
+	// Neither stored nor queued
+	inspector.tasks = []queue.TaskStatus{{TaskID: "test-queued-003", State: queue.TaskStateNotFound}}
+	code, response = getJob("test-queued-003")
+	if code != http.StatusNotFound || response["status"] != "not_found" {
+		t.Errorf("Expected a job not found, got %d %v", code, response)
+	}
+
+	inspector.err = errors.New("redis unavailable")
+	code, response = getJob("test-queued-002")
+	if code != http.StatusOK || response["status"] != "failed" || !strings.Contains(fmt.Sprint(response["queue_error"]), "redis unavailable") {
+		t.Errorf("Expected the stored status with the queue error, got %d %v", code, response)
+	}
+}
+
+func TestJobTasksWithoutInspector(t *testing.T) {
+	handler := setupStatelessHandler()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/jobs/doc-1/tasks", nil)
+	w := httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, got %d", w.Code)
+	}
+}
+
+func TestCancelJobWithoutInspector(t *testing.T) {
+	handler := setupStatelessHandler()
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/jobs/doc-1", nil)
+	w := httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/jobs/doc-1/tasks", nil)
+	w = httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", w.Code)
+	}
+}
+
+func TestCancelJob(t *testing.T) {
+	handler, db, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	inspector := &fakeTaskInspector{}
+	handler.inspector = inspector
+
+	cancel := func(id string) (int, map[string]interface{}) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodDelete, "/api/jobs/"+id, nil)
+		w := httptest.NewRecorder()
+		handler.mux.ServeHTTP(w, req)
+
+		var response map[string]interface{}
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return w.Code, response
+	}
+
+	analysis := &models.Analysis{
+		ID:        "test-cancel-001",
+		Text:      "The council approved the plan.",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+		t.Fatalf("Failed to save test analysis: %v", err)
+	}
+	if err := db.UpdateProcessingStage(context.Background(), analysis.ID, models.ProcessingStageOfflineComplete); err != nil {
+		t.Fatalf("Failed to update processing stage: %v", err)
+	}
+
+	// Enrichment is waiting to retry and one image is being enriched
+	inspector.tasks = []queue.TaskStatus{
+		{TaskID: "test-cancel-001", Type: queue.TypeProcessDocument, State: "completed"},
+		{TaskID: "test-cancel-001-text-enrich", Type: queue.TypeEnrichText, State: "retry", Retried: 1},
+		{TaskID: "test-cancel-001-image-enrich-0", Type: queue.TypeEnrichImage, State: "active"},
+	}
+	code, response := cancel("test-cancel-001")
+	if code != http.StatusAccepted || response["active"] != true {
+		t.Fatalf("Expected 202 with a running task, got %d %v", code, response)
+	}
+	cancelled, _ := response["cancelled"].([]interface{})
+	if len(cancelled) != 1 || cancelled[0].(map[string]interface{})["task_id"] != "test-cancel-001-text-enrich" {
+		t.Errorf("Expected the text enrichment cancelled, got %v", response["cancelled"])
+	}
+	if tasks, _ := response["active_tasks"].([]interface{}); len(tasks) != 1 {
+		t.Errorf("Expected the image enrichment reported running, got %v", response["active_tasks"])
+	}
+
+	state, err := db.GetProcessingState(context.Background(), analysis.ID)
+	if err != nil || state.Stage != models.ProcessingStageCancelled {
+		t.Errorf("Expected the analysis marked cancelled, got %+v (%v)", state, err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/jobs/test-cancel-001", nil)
+	w := httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+	var job map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&job); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if job["status"] != "cancelled" {
+		t.Errorf("Expected a cancelled job, got %v", job["status"])
+	}
+
+	// Once the running task finishes there is nothing left to cancel
+	inspector.tasks[2].State = "completed"
+	if code, response := cancel("test-cancel-001"); code != http.StatusConflict {
+		t.Errorf("Expected 409, got %d %v", code, response)
+	}
+
+	// A job still waiting for offline processing has no analysis to mark
+	inspector.tasks = []queue.TaskStatus{{TaskID: "test-cancel-002", Type: queue.TypeProcessDocument, State: "pending"}}
+	code, response = cancel("test-cancel-002")
+	if code != http.StatusOK || response["active"] != false || response["processing_stage"] != nil {
+		t.Errorf("Expected 200 without a stage, got %d %v", code, response)
+	}
+
+	inspector.tasks = []queue.TaskStatus{{TaskID: "test-cancel-003", State: queue.TaskStateNotFound}}
+	if code, response := cancel("test-cancel-003"); code != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d %v", code, response)
+	}
+}
+
+func TestAnalyzeClientMetadata(t *testing.T) {
+	tooMany := make(map[string]string)
+	for i := 0; i <= maxClientMetadataKeys; i++ {
+		tooMany[fmt.Sprintf("key%d", i)] = "value"
+	}
+
+	tests := []struct {
+		name           string
+		clientMetadata map[string]string
+		wantStatus     int
+	}{
+		{"valid", map[string]string{"crawl_id": "42", "customer": "acme"}, http.StatusAccepted},
+		{"too many keys", tooMany, http.StatusBadRequest},
+		{"value too long", map[string]string{"note": strings.Repeat("a", maxClientMetadataValueLength+1)}, http.StatusBadRequest},
+		{"unsafe key", map[string]string{"customer'; --": "acme"}, http.StatusBadRequest},
+		{"empty key", map[string]string{"": "acme"}, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockQueue := &mockQueueClient{}
+			handler := setupStatelessHandler()
+			handler.queueClient = mockQueue
+
+			body, _ := json.Marshal(map[string]interface{}{"text": "This is a test text.", "client_metadata": tt.clientMetadata})
+			req := httptest.NewRequest(http.MethodPost, "/api/analyze", bytes.NewReader(body))
+			w := httptest.NewRecorder()
+
+			handler.mux.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusAccepted {
+				return
+			}
+
+			if !reflect.DeepEqual(mockQueue.lastOptions.ClientMetadata, tt.clientMetadata) {
+				t.Errorf("Expected enqueued client metadata %v, got %v", tt.clientMetadata, mockQueue.lastOptions.ClientMetadata)
+			}
+
+			var response map[string]interface{}
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response["client_metadata"] == nil {
+				t.Error("Expected client_metadata in response")
+			}
+		})
+	}
+}
+
+func TestAnalyzeCallbackURL(t *testing.T) {
+	tests := []struct {
+		name         string
+		callbackURL  string
+		allowPrivate bool
+		wantStatus   int
+	}{
+		{"valid", "https://pipeline.example.com/hooks/analysis?token=abc", false, http.StatusAccepted},
+		{"internal host", "http://pipeline:9000/done", false, http.StatusAccepted},
+		{"loopback address", "http://127.0.0.1:9000/done", false, http.StatusBadRequest},
+		{"link-local address", "http://169.254.169.254/latest/meta-data", false, http.StatusBadRequest},
+		{"private IPv6 address", "http://[fd00::1]/done", false, http.StatusBadRequest},
+		{"private address allowed", "http://10.0.0.5:9000/done", true, http.StatusAccepted},
+		{"relative", "/hooks/analysis", false, http.StatusBadRequest},
+		{"unsupported scheme", "ftp://pipeline.example.com/done", false, http.StatusBadRequest},
+		{"too long", "https://pipeline.example.com/" + strings.Repeat("a", maxCallbackURLLength), false, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockQueue := &mockQueueClient{}
+			handler := setupStatelessHandler()
+			handler.queueClient = mockQueue
+			handler.privateCallbacks = tt.allowPrivate
+
+			body, _ := json.Marshal(map[string]interface{}{"text": "This is a test text.", "callback_url": tt.callbackURL})
+			req := httptest.NewRequest(http.MethodPost, "/api/analyze", bytes.NewReader(body))
+			w := httptest.NewRecorder()
+
+			handler.mux.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusAccepted {
+				if mockQueue.enqueued != 0 {
+					t.Errorf("Expected nothing enqueued, got %d tasks", mockQueue.enqueued)
+				}
+				return
+			}
+			if mockQueue.lastOptions.CallbackURL != tt.callbackURL {
+				t.Errorf("Expected enqueued callback URL %q, got %q", tt.callbackURL, mockQueue.lastOptions.CallbackURL)
+			}
+			var response map[string]interface{}
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response["callback_url"] != tt.callbackURL {
+				t.Errorf("Expected callback_url in response, got %v", response["callback_url"])
+			}
+		})
+	}
+}
+
+func TestListAnalysesClientMetadataFilter(t *testing.T) {
+	handler, db, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	for i, customer := range []string{"acme", "globex", "acme"} {
+		analysis := &models.Analysis{
+			ID:             fmt.Sprintf("test-client-%d", i),
+			Text:           "Test text",
+			ClientMetadata: map[string]string{"customer": customer},
+			CreatedAt:      time.Now(),
+			UpdatedAt:      time.Now(),
+		}
+		if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+			t.Fatalf("Failed to save test analysis: %v", err)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/analyses?client_metadata.customer=acme", nil)
+	w := httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var response analysisPage
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(response.Items) != 2 || response.Total != 2 {
+		t.Fatalf("Expected 2 of 2 analyses, got %d of %d", len(response.Items), response.Total)
+	}
+	for _, analysis := range response.Items {
+		if analysis.ClientMetadata["customer"] != "acme" {
+			t.Errorf("Expected customer acme, got %v", analysis.ClientMetadata)
+		}
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/analyses?client_metadata.bad%20key=acme", nil)
+	w = httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid filter key, got %d", w.Code)
+	}
+}
+
+func TestParseListFilter(t *testing.T) {
+	after := time.Date(2025, 1, 8, 0, 0, 0, 0, time.UTC)
+	before := time.Date(2025, 1, 15, 9, 30, 0, 0, time.UTC)
+
+	filter, reqErr := parseListFilter(url.Values{
+		"created_after":            {"2025-01-08"},
+		"created_before":           {"2025-01-15T10:30:00+01:00"},
+		"language":                 {"en"},
+		"bias":                     {"center-right"},
+		"min_quality":              {"0"},
+		"max_quality":              {"0.4"},
+		"client_metadata.customer": {"acme"},
+	})
+	if reqErr != nil {
+		t.Fatalf("Expected a valid filter, got %q", reqErr.message)
+	}
+	if filter.CreatedAfter == nil || !filter.CreatedAfter.Equal(after) {
+		t.Errorf("Expected created_after %v, got %v", after, filter.CreatedAfter)
+	}
+	if filter.CreatedBefore == nil || !filter.CreatedBefore.Equal(before) {
+		t.Errorf("Expected created_before %v, got %v", before, filter.CreatedBefore)
+	}
+	if filter.Language != "en" || filter.Bias != "center-right" || filter.ClientMetadata["customer"] != "acme" {
+		t.Errorf("Expected language, bias and client metadata filters, got %+v", filter)
+	}
+	if filter.MinQuality == nil || *filter.MinQuality != 0 || filter.MaxQuality == nil || *filter.MaxQuality != 0.4 {
+		t.Errorf("Expected quality between 0 and 0.4, got %v and %v", filter.MinQuality, filter.MaxQuality)
+	}
+
+	filter, reqErr = parseListFilter(url.Values{})
+	if reqErr != nil || filter.CreatedAfter != nil || filter.CreatedBefore != nil || filter.Language != "" || filter.Bias != "" || filter.MinQuality != nil || filter.MaxQuality != nil {
+		t.Errorf("Expected no filters, got %+v (%v)", filter, reqErr)
+	}
+
+	invalid := []struct {
+		query   url.Values
+		message string
+	}{
+		{url.Values{"created_after": {"last week"}}, "Invalid created_after"},
+		{url.Values{"created_before": {"2025-13-01"}}, "Invalid created_before"},
+		{url.Values{"created_after": {"2025-01-15"}, "created_before": {"2025-01-08"}}, "must be later than created_after"},
+		{url.Values{"language": {"English"}}, "Invalid language"},
+		{url.Values{"bias": {"Left"}}, "Invalid bias"},
+		{url.Values{"min_quality": {"high"}}, "Invalid min_quality"},
+		{url.Values{"max_quality": {"1.5"}}, "Invalid max_quality"},
+		{url.Values{"min_quality": {"NaN"}}, "Invalid min_quality"},
+		{url.Values{"min_quality": {"0.8"}, "max_quality": {"0.2"}}, "must not be below min_quality"},
+		{url.Values{"client_metadata.bad key": {"acme"}}, "Invalid client_metadata filter"},
+	}
+	for _, tt := range invalid {
+		_, reqErr := parseListFilter(tt.query)
+		if reqErr == nil || reqErr.status != http.StatusBadRequest || !strings.Contains(reqErr.message, tt.message) {
+			t.Errorf("Expected 400 with %q for %v, got %v", tt.message, tt.query, reqErr)
+		}
+	}
+}
+
+func TestListAnalysesInvalidFilter(t *testing.T) {
+	handler := setupStatelessHandler()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/analyses?min_quality=2", nil)
+	w := httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "Invalid min_quality") {
+		t.Errorf("Expected 400 naming min_quality, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestListAnalysesInvalidCursor(t *testing.T) {
+	handler := setupStatelessHandler()
+	cursor := encodeCursor(database.Cursor{CreatedAt: time.Now(), ID: "abc"})
+
+	tests := map[string]string{
+		"cursor=not-a-cursor":             "Invalid cursor: pass the next_cursor",
+		"cursor=" + cursor + "&offset=10": "Invalid cursor: cannot be combined with offset",
+	}
+	for query, message := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/analyses?"+query, nil)
+		w := httptest.NewRecorder()
+		handler.mux.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), message) {
+			t.Errorf("Expected 400 with %q for %s, got %d: %s", message, query, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestSearchTextEndpoint(t *testing.T) {
+	handler, db, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	for i := 1; i <= 3; i++ {
+		analysis := &models.Analysis{
+			ID:        fmt.Sprintf("test-search-%d", i),
+			Text:      "The pilot plant uses carbon capture to cut emissions.",
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+		if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+			t.Fatalf("Failed to save test analysis: %v", err)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/search/text?q=carbon+capture&limit=2", nil)
+	w := httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var page searchPage
+	if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(page.Items) != 2 || page.Total != 3 || page.Limit != 2 || !page.HasMore {
+		t.Fatalf("Expected 2 of 3 matches with more to come, got %d items, %+v", len(page.Items), page)
+	}
+	if page.Items[0].ID == "" || !strings.Contains(page.Items[0].Headline, "<mark>carbon</mark>") {
+		t.Errorf("Expected an analysis with a highlighted headline, got %+v", page.Items[0])
+	}
+}
+
+func TestSearchTextValidation(t *testing.T) {
+	handler := setupStatelessHandler()
+
+	for _, query := range []string{"", "q=", "q=%20%20", "q=" + strings.Repeat("a", maxSearchQueryLength+1)} {
+		req := httptest.NewRequest(http.MethodGet, "/api/search/text?"+query, nil)
+		w := httptest.NewRecorder()
+		handler.mux.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %q, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestSearchByEntityAndReferenceEndpoints(t *testing.T) {
+	handler, db, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	for i := 1; i <= 3; i++ {
+		analysis := &models.Analysis{
+			ID:   fmt.Sprintf("test-entity-search-%d", i),
+			Text: "Paris grew by 5% last year.",
+			Metadata: models.Metadata{
+				NamedEntities: []string{"Paris"},
+				Entities:      []models.NamedEntity{{Text: "Paris", Type: models.EntityLocation, Count: 1}},
+				References:    []models.Reference{{Text: "grew by 5% last year", Type: "statistic"}},
+			},
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+		if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+			t.Fatalf("Failed to save test analysis: %v", err)
+		}
+	}
+
+	tests := []struct {
+		path  string
+		items int
+		total int
+	}{
+		{"/api/search/entity?name=Paris&limit=2", 2, 3},
+		{"/api/search/entity?name=Berlin", 0, 0},
+		{"/api/search/entity?name=Paris&type=Location&limit=2", 2, 3},
+		{"/api/search/entity?name=Paris&type=person", 0, 0},
+		{"/api/search/reference?reference=5%25&type=Statistic&limit=2", 2, 3},
+		{"/api/search/reference?reference=5%25&type=quote", 0, 0},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		w := httptest.NewRecorder()
+		handler.mux.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200 for %s, got %d: %s", tt.path, w.Code, w.Body.String())
+		}
+		var page analysisPage
+		if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if page.Items == nil || len(page.Items) != tt.items || page.Total != tt.total || page.HasMore != (tt.items < tt.total) {
+			t.Errorf("Expected %d of %d analyses for %s, got %d items, %+v", tt.items, tt.total, tt.path, len(page.Items), page)
+		}
+	}
+}
+
+func TestSearchByEntityAndReferenceValidation(t *testing.T) {
+	handler := setupStatelessHandler()
+
+	for _, path := range []string{
+		"/api/search/entity",
+		"/api/search/entity?name=%20",
+		"/api/search/entity?name=" + strings.Repeat("a", maxSearchQueryLength+1),
+		"/api/search/entity?name=Paris&type=planet",
+		"/api/search/reference",
+		"/api/search/reference?reference=climate&type=rumor",
+	} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		handler.mux.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", path, w.Code)
+		}
+	}
+}
+
+func TestRelatedAnalysesEndpoint(t *testing.T) {
+	handler, db, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	for i, analysisTags := range [][]string{{"climate-change", "policy"}, {"climate-change", "policy"}, {"climate-change"}, {"sports"}} {
+		analysis := &models.Analysis{
+			ID:        fmt.Sprintf("test-related-analysis-%d", i),
+			Text:      "Test text",
+			Metadata:  models.Metadata{Tags: analysisTags},
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+		if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+			t.Fatalf("Failed to save test analysis: %v", err)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/analyses/test-related-analysis-0/related", nil)
+	w := httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var response struct {
+		AnalysisID string                   `json:"analysis_id"`
+		Related    []models.RelatedAnalysis `json:"related"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(response.Related) != 2 || response.Related[0].ID != "test-related-analysis-1" || response.Related[0].Score != 6 ||
+		response.Related[1].ID != "test-related-analysis-2" || len(response.Related[1].SharedTags) != 1 {
+		t.Errorf("Expected analyses 1 then 2, got %+v", response.Related)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/analyses/missing/related", nil)
+	w = httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for a missing analysis, got %d", w.Code)
+	}
+}
+
+func TestRelatedAnalysesInvalidLimit(t *testing.T) {
+	handler := setupStatelessHandler()
+
+	for _, path := range []string{"/api/analyses/a1/related?limit=0", "/api/analyses/a1/related?limit=51", "/api/analyses/a1/related?limit=abc"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		handler.mux.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", path, w.Code)
+		}
+	}
+}
+
+func TestRelatedTagsEndpoint(t *testing.T) {
+	handler, db, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	for i, analysisTags := range [][]string{{"climate-change", "policy"}, {"climate-change", "energy", "policy"}} {
+		analysis := &models.Analysis{
+			ID:        fmt.Sprintf("test-related-%d", i),
+			Text:      "Test text",
+			Metadata:  models.Metadata{Tags: analysisTags},
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+		if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+			t.Fatalf("Failed to save test analysis: %v", err)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/tags/Climate_Change/related", nil)
+	w := httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	var response struct {
+		Tag     string              `json:"tag"`
+		Related []models.RelatedTag `json:"related"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Tag != "climate-change" || len(response.Related) != 2 || response.Related[0].Tag != "policy" {
+		t.Errorf("Unexpected response: %+v", response)
+	}
+
+	// Unknown tags return an empty list rather than 404
+	req = httptest.NewRequest(http.MethodGet, "/api/tags/unknown-tag/related", nil)
+	w = httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 for unknown tag, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), `"related":[]`) {
+		t.Errorf("Expected empty related list, got %s", w.Body.String())
+	}
+}
+
+func TestListTagsEndpoint(t *testing.T) {
+	handler, db, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	for i, analysisTags := range [][]string{{"climate-change", "policy"}, {"climate-change", "climate-policy"}, {"sports"}} {
+		analysis := &models.Analysis{
+			ID:        fmt.Sprintf("test-list-tags-%d", i),
+			Text:      "Test text",
+			Metadata:  models.Metadata{Tags: analysisTags},
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+		if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+			t.Fatalf("Failed to save test analysis: %v", err)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/tags?prefix=Climate&limit=10", nil)
+	w := httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	var response struct {
+		Prefix string            `json:"prefix"`
+		Tags   []models.TagCount `json:"tags"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	want := []models.TagCount{{Tag: "climate-change", Count: 2}, {Tag: "climate-policy", Count: 1}}
+	if response.Prefix != "climate" || !reflect.DeepEqual(response.Tags, want) {
+		t.Errorf("Expected %+v for prefix climate, got %+v", want, response)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/tags?limit=1", nil)
+	w = httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+	if !strings.Contains(w.Body.String(), `"tags":[{"tag":"climate-change","count":2}]`) {
+		t.Errorf("Expected only the most used tag with limit 1, got %s", w.Body.String())
+	}
+}
+
+func TestListTagsInvalidLimit(t *testing.T) {
+	handler := setupStatelessHandler()
+
+	for _, path := range []string{"/api/tags?limit=0", "/api/tags?limit=1001", "/api/tags?limit=abc"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		handler.mux.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", path, w.Code)
+		}
+	}
+}
+
+func TestRenameTagEndpoint(t *testing.T) {
+	handler, db, cleanup := setupTestHandler(t)
+	defer cleanup()
+	handler.adminToken = "secret"
+
+	for i, analysisTags := range [][]string{{"ml", "python"}, {"machine-learning", "ml"}} {
+		analysis := &models.Analysis{
+			ID:        fmt.Sprintf("test-rename-tag-%d", i),
+			Text:      "Test text",
+			Metadata:  models.Metadata{Tags: analysisTags},
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+		if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+			t.Fatalf("Failed to save test analysis: %v", err)
+		}
+	}
+
+	for _, dryRun := range []bool{true, false} {
+		body := fmt.Sprintf(`{"from": "ML", "to": "Machine Learning", "dry_run": %t}`, dryRun)
+		req := httptest.NewRequest(http.MethodPost, "/api/tags/rename", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		handler.mux.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var result models.TagRename
+		if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		want := models.TagRename{From: "ml", To: "machine-learning", Analyses: 2, Merged: 1, DryRun: dryRun}
+		if result != want {
+			t.Errorf("Expected %+v, got %+v", want, result)
+		}
+	}
+
+	analysis, err := db.GetAnalysis(context.Background(), "test-rename-tag-1")
+	if err != nil {
+		t.Fatalf("Failed to get analysis: %v", err)
+	}
+	if !reflect.DeepEqual(analysis.Metadata.Tags, []string{"machine-learning"}) {
+		t.Errorf("Expected the tags merged, got %v", analysis.Metadata.Tags)
+	}
+}
+
+func TestRenameTagInvalid(t *testing.T) {
+	handler := setupStatelessHandler()
+	handler.adminToken = "secret"
+
+	for _, body := range []string{
+		`{"from": "ml"}`,
+		`{"to": "machine-learning"}`,
+		`{"from": "ml", "to": "no/slashes"}`,
+		`{"from": "Machine_Learning", "to": "machine-learning"}`,
+		`{"from": `,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/api/tags/rename", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		handler.mux.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", body, w.Code)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/tags/rename", nil)
+	w := httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405 for GET, got %d", w.Code)
+	}
+
+	// Renaming requires the admin token
+	req = httptest.NewRequest(http.MethodPost, "/api/tags/rename", strings.NewReader(`{"from": "ml", "to": "machine-learning"}`))
+	w = httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without a token, got %d", w.Code)
+	}
+	handler.adminToken = ""
+	req = httptest.NewRequest(http.MethodPost, "/api/tags/rename", strings.NewReader(`{"from": "ml", "to": "machine-learning"}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	w = httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 without a configured admin token, got %d", w.Code)
+	}
+}
+
+func TestRelatedTagsCached(t *testing.T) {
+	handler := setupStatelessHandler()
+	cached := []models.RelatedTag{{Tag: "policy", Count: 3, Lift: 1.5}}
+	handler.relatedTags.set("climate-change|10", cached)
+
+	// The stateless handler has no database, so only a cache hit can succeed
+	req := httptest.NewRequest(http.MethodGet, "/api/tags/climate-change/related", nil)
+	w := httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), `"tag":"policy"`) {
+		t.Errorf("Expected cached result, got %s", w.Body.String())
+	}
+
+	for _, path := range []string{"/api/tags/climate-change/related?limit=0", "/api/tags/climate-change/related?limit=abc"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		handler.mux.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", path, w.Code)
+		}
+	}
+}
+
+func TestRelatedTagsCacheExpiry(t *testing.T) {
+	now := time.Now()
+	cache := newRelatedTagsCache(time.Minute)
+	cache.now = func() time.Time { return now }
+
+	cache.set("policy|10", []models.RelatedTag{})
+	if _, ok := cache.get("policy|10"); !ok {
+		t.Fatal("Expected fresh entry to be cached")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, ok := cache.get("policy|10"); ok {
+		t.Error("Expected expired entry to be dropped")
+	}
+}
+
+func TestAnalyzeSections(t *testing.T) {
+	for _, sections := range []bool{false, true} {
+		mockQueue := &mockQueueClient{}
+		handler := setupStatelessHandler()
+		handler.queueClient = mockQueue
+
+		body, _ := json.Marshal(map[string]interface{}{"text": "This is a test text.", "sections": sections})
+		req := httptest.NewRequest(http.MethodPost, "/api/analyze", bytes.NewReader(body))
+		w := httptest.NewRecorder()
+
+		handler.mux.ServeHTTP(w, req)
+
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusAccepted, w.Code, w.Body.String())
+		}
+		if mockQueue.lastOptions.Sections != sections {
+			t.Errorf("Expected enqueued sections option %v, got %v", sections, mockQueue.lastOptions.Sections)
+		}
+	}
+}
+
+// fakeWorkerConfigurer validates and records settings like the queue worker
+type fakeWorkerConfigurer struct {
+	settings models.WorkerSettings
+	err      error
+}
+
+func (f *fakeWorkerConfigurer) Settings() models.WorkerSettings {
+	settings := f.settings
+	settings.QueueWeights = make(map[string]int)
+	for name, weight := range f.settings.QueueWeights {
+		settings.QueueWeights[name] = weight
+	}
+	return settings
+}
+
+func (f *fakeWorkerConfigurer) Reconfigure(settings models.WorkerSettings) error {
+	if err := queue.ValidateWorkerSettings(settings); err != nil {
+		return err
+	}
+	if f.err != nil {
+		return f.err
+	}
+	f.settings = settings
+	return nil
+}
+
+// fakeWorkerSettingsStore records persisted worker settings
+type fakeWorkerSettingsStore struct {
+	saved *models.WorkerSettings
+}
+
+func (f *fakeWorkerSettingsStore) SaveWorkerSettings(_ context.Context, settings *models.WorkerSettings) error {
+	f.saved = settings
+	return nil
+}
+
+// setupWorkerConfigHandler returns a stateless handler with a fake worker
+// and the admin token "secret"
+func setupWorkerConfigHandler() (*Handler, *fakeWorkerConfigurer, *fakeWorkerSettingsStore) {
+	worker := &fakeWorkerConfigurer{settings: models.WorkerSettings{
+		Concurrency:  10,
+		QueueWeights: queue.DefaultQueueWeights(),
+	}}
+	store := &fakeWorkerSettingsStore{}
+
+	handler := setupStatelessHandler()
+	handler.worker = worker
+	handler.workerSettings = store
+	handler.adminToken = "secret"
+	return handler, worker, store
+}
+
+func workerConfigRequest(handler *Handler, method, token string, body interface{}) *httptest.ResponseRecorder {
+	var data []byte
+	if body != nil {
+		data, _ = json.Marshal(body)
+	}
+
+	req := httptest.NewRequest(method, "/api/admin/worker/config", bytes.NewReader(data))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+	return w
+}
+
+func TestWorkerConfigAuth(t *testing.T) {
+	handler, _, _ := setupWorkerConfigHandler()
+
+	if w := workerConfigRequest(handler, http.MethodGet, "", nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without a token, got %d", w.Code)
+	}
+	if w := workerConfigRequest(handler, http.MethodGet, "wrong", nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 with a wrong token, got %d", w.Code)
+	}
+	if w := workerConfigRequest(handler, http.MethodGet, "secret", nil); w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 with the admin token, got %d", w.Code)
+	}
+
+	handler.adminToken = ""
+	if w := workerConfigRequest(handler, http.MethodGet, "secret", nil); w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 without a configured admin token, got %d", w.Code)
+	}
+}
+
+func TestWorkerConfigWithoutWorker(t *testing.T) {
+	handler, _, _ := setupWorkerConfigHandler()
+	handler.worker = nil
+
+	if w := workerConfigRequest(handler, http.MethodGet, "secret", nil); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, got %d", w.Code)
+	}
+}
+
+func TestWorkerConfigGet(t *testing.T) {
+	handler, _, _ := setupWorkerConfigHandler()
+
+	w := workerConfigRequest(handler, http.MethodGet, "secret", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var settings models.WorkerSettings
+	if err := json.Unmarshal(w.Body.Bytes(), &settings); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if settings.Concurrency != 10 || !reflect.DeepEqual(settings.QueueWeights, queue.DefaultQueueWeights()) {
+		t.Errorf("Expected the effective settings, got %+v", settings)
+	}
+}
+
+func TestWorkerConfigUpdate(t *testing.T) {
+	handler, worker, store := setupWorkerConfigHandler()
+
+	w := workerConfigRequest(handler, http.MethodPost, "secret", map[string]interface{}{
+		"concurrency":     4,
+		"queue_weights":   map[string]int{"image-enrichment": 9},
+		"strict_priority": true,
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	want := queue.DefaultQueueWeights()
+	want["image-enrichment"] = 9
+	if worker.settings.Concurrency != 4 || !worker.settings.StrictPriority {
+		t.Errorf("Expected concurrency 4 with strict priority, got %+v", worker.settings)
+	}
+	if !reflect.DeepEqual(worker.settings.QueueWeights, want) {
+		t.Errorf("Expected only the image-enrichment weight to change, got %v", worker.settings.QueueWeights)
+	}
+	if store.saved == nil || !reflect.DeepEqual(*store.saved, worker.settings) {
+		t.Errorf("Expected the applied settings to be persisted, got %+v", store.saved)
+	}
+
+	// Omitted fields keep their current values
+	w = workerConfigRequest(handler, http.MethodPost, "secret", map[string]interface{}{"concurrency": 6})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if worker.settings.Concurrency != 6 || !worker.settings.StrictPriority || worker.settings.QueueWeights["image-enrichment"] != 9 {
+		t.Errorf("Expected a partial update, got %+v", worker.settings)
+	}
+}
+
+func TestWorkerConfigUpdateErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   interface{}
+		err    error
+		status int
+	}{
+		{"zero concurrency", map[string]interface{}{"concurrency": 0}, nil, http.StatusBadRequest},
+		{"unknown queue", map[string]interface{}{"queue_weights": map[string]int{"default": 1}}, nil, http.StatusBadRequest},
+		{"zero weight", map[string]interface{}{"queue_weights": map[string]int{"text-enrichment": 0}}, nil, http.StatusBadRequest},
+		{"invalid body", "not an object", nil, http.StatusBadRequest},
+		{"in progress", map[string]interface{}{"concurrency": 4}, queue.ErrReconfigureInProgress, http.StatusConflict},
+		{"start failure", map[string]interface{}{"concurrency": 4}, fmt.Errorf("failed to start reconfigured worker: redis down"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, worker, store := setupWorkerConfigHandler()
+			worker.err = tt.err
+
+			w := workerConfigRequest(handler, http.MethodPost, "secret", tt.body)
+			if w.Code != tt.status {
+				t.Errorf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+			if worker.settings.Concurrency != 10 {
+				t.Errorf("Expected settings to be unchanged, got %+v", worker.settings)
+			}
+			if store.saved != nil {
+				t.Error("Expected rejected settings not to be persisted")
+			}
+		})
+	}
+}
+
+func TestAnalyzeStoreText(t *testing.T) {
+	tests := []struct {
+		name            string
+		redactByDefault bool
+		body            map[string]interface{}
+		redact          bool
+		dropCleanedText bool
+	}{
+		{"default", false, map[string]interface{}{}, false, false},
+		{"opt out", false, map[string]interface{}{"store_text": false}, true, false},
+		{"opt out with cleaned text", false, map[string]interface{}{"store_text": false, "store_cleaned_text": false}, true, true},
+		{"cleaned text ignored when storing text", false, map[string]interface{}{"store_cleaned_text": false}, false, false},
+		{"global default", true, map[string]interface{}{}, true, false},
+		{"opt in over global default", true, map[string]interface{}{"store_text": true}, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockQueue := &mockQueueClient{}
+			handler := setupStatelessHandler()
+			handler.queueClient = mockQueue
+			handler.redactText = tt.redactByDefault
+
+			tt.body["text"] = "This is a test text."
+			body, _ := json.Marshal(tt.body)
+			req := httptest.NewRequest(http.MethodPost, "/api/analyze", bytes.NewReader(body))
+			w := httptest.NewRecorder()
+			handler.mux.ServeHTTP(w, req)
+
+			if w.Code != http.StatusAccepted {
+				t.Fatalf("Expected status %d, got %d: %s", http.StatusAccepted, w.Code, w.Body.String())
+			}
+			if mockQueue.lastOptions.RedactText != tt.redact || mockQueue.lastOptions.DropCleanedText != tt.dropCleanedText {
+				t.Errorf("Expected redact %v and drop cleaned text %v, got %+v", tt.redact, tt.dropCleanedText, mockQueue.lastOptions)
+			}
+
+			var response map[string]interface{}
+			json.Unmarshal(w.Body.Bytes(), &response)
+			if _, ok := response["store_text"]; ok != tt.redact {
+				t.Errorf("Expected store_text in the response only when text is not stored, got %v", response)
+			}
+		})
+	}
+}
+
+func TestRedactedAnalysisEndpoints(t *testing.T) {
+	handler, db, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	analysis := &models.Analysis{
+		ID: "test-redacted-001",
+		Metadata: models.Metadata{
+			WordCount: 12,
+			Tags:      []string{"transit"},
+			Redaction: &models.TextRedaction{TextSHA256: "8dc1292a569d1968da194087e570b4a28d3212f0ac986c2e350e811ef02189cf"},
+		},
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+		t.Fatalf("Failed to save test analysis: %v", err)
+	}
+
+	// Responses omit the text but report the hash
+	req := httptest.NewRequest(http.MethodGet, "/api/analyses/test-redacted-001?include=cleaning_report", nil)
+	w := httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var response map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if _, ok := response["text"]; ok {
+		t.Errorf("Expected no text field, got %v", response["text"])
+	}
+	if _, ok := response["cleaning_report"]; ok {
+		t.Error("Expected no cleaning report without stored text")
+	}
+	metadata, _ := response["metadata"].(map[string]interface{})
+	redaction, _ := metadata["redaction"].(map[string]interface{})
+	if redaction["text_sha256"] != analysis.Metadata.Redaction.TextSHA256 {
+		t.Errorf("Expected the text hash in the response, got %v", metadata["redaction"])
+	}
+
+	// Reanalysis needs the source text
+	req = httptest.NewRequest(http.MethodPost, "/api/analyses/test-redacted-001/reanalyze", nil)
+	w = httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for reanalysis, got %d: %s", w.Code, w.Body.String())
+	}
 }
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/docutag/textanalyzer/internal/promutil"
+	"github.com/hibiken/asynq"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Metrics holds the API's Prometheus collectors. Request durations carry the
+// current trace ID as an exemplar so a slow dashboard panel links to its trace.
+type Metrics struct {
+	RequestDuration *prometheus.HistogramVec // By route, method and status code
+	EnqueueFailures prometheus.Counter       // Analyses that could not be queued
+	DedupeHits      prometheus.Counter       // Enqueues rejected because the task was already queued
+
+	BackPressureDecisions *prometheus.CounterVec // Submission admissions by back-pressure mode and decision
+}
+
+// NewMetrics creates the API collectors and registers them with registerer.
+// Collectors already registered, e.g. by an earlier handler, are reused.
+func NewMetrics(registerer prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		RequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "textanalyzer_api_request_duration_seconds",
+			Help:    "API request latency by route, with trace ID exemplars",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method", "code"}),
+		EnqueueFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "textanalyzer_api_enqueue_failures_total",
+			Help: "Analyses the API failed to enqueue",
+		}),
+		DedupeHits: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "textanalyzer_api_enqueue_dedupe_hits_total",
+			Help: "Enqueues rejected because a task with the same ID was already queued",
+		}),
+		BackPressureDecisions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "textanalyzer_api_backpressure_decisions_total",
+			Help: "Submissions accepted, degraded to offline-only or rejected by queue back-pressure",
+		}, []string{"mode", "decision"}),
+	}
+
+	m.RequestDuration = promutil.Register(registerer, m.RequestDuration)
+	m.EnqueueFailures = promutil.Register(registerer, m.EnqueueFailures)
+	m.DedupeHits = promutil.Register(registerer, m.DedupeHits)
+	m.BackPressureDecisions = promutil.Register(registerer, m.BackPressureDecisions)
+	return m
+}
+
+// observeEnqueueError counts a failed enqueue as a dedupe hit when the task
+// was already queued and as a failure otherwise
+func (m *Metrics) observeEnqueueError(err error) {
+	if errors.Is(err, asynq.ErrTaskIDConflict) || errors.Is(err, asynq.ErrDuplicateTask) {
+		m.DedupeHits.Inc()
+		return
+	}
+	m.EnqueueFailures.Inc()
+}
+
+// instrument wraps a route's handler to record its duration, attaching the
+// request's trace ID as an exemplar when a span is present
+func (h *Handler) instrument(route string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r)
+
+		observer := h.metrics.RequestDuration.WithLabelValues(route, r.Method, strconv.Itoa(rec.status))
+		duration := time.Since(start).Seconds()
+
+		spanContext := trace.SpanContextFromContext(r.Context())
+		if exemplarObserver, ok := observer.(prometheus.ExemplarObserver); ok && spanContext.HasTraceID() {
+			exemplarObserver.ObserveWithExemplar(duration, prometheus.Labels{"trace_id": spanContext.TraceID().String()})
+			return
+		}
+		observer.Observe(duration)
+	}
+}
+
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
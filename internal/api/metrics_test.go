@@ -0,0 +1,171 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/docutag/textanalyzer/internal/analyzer"
+	"github.com/hibiken/asynq"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// requestDurationSeries returns the request duration histogram for a route,
+// failing the test when the series does not exist
+func requestDurationSeries(t *testing.T, registry *prometheus.Registry, route, method, code string) *dto.Histogram {
+	t.Helper()
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != "textanalyzer_api_request_duration_seconds" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["route"] == route && labels["method"] == method && labels["code"] == code {
+				return metric.GetHistogram()
+			}
+		}
+	}
+	t.Fatalf("No request duration series for %s %s %s", method, route, code)
+	return nil
+}
+
+// exemplarTraceIDs returns the trace IDs of the exemplars on a histogram
+func exemplarTraceIDs(histogram *dto.Histogram) []string {
+	var ids []string
+	for _, bucket := range histogram.GetBucket() {
+		for _, label := range bucket.GetExemplar().GetLabel() {
+			if label.GetName() == "trace_id" {
+				ids = append(ids, label.GetValue())
+			}
+		}
+	}
+	return ids
+}
+
+func TestRequestDurationExemplars(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	handler := NewHandler(nil, analyzer.New(), &mockQueueClient{}, Config{Metrics: NewMetrics(registry)})
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/segment", bytes.NewReader([]byte(`{"text": "One. Two."}`))).WithContext(ctx)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	histogram := requestDurationSeries(t, registry, "/api/segment", http.MethodPost, "200")
+	if histogram.GetSampleCount() != 1 {
+		t.Errorf("Expected 1 observation, got %d", histogram.GetSampleCount())
+	}
+	if ids := exemplarTraceIDs(histogram); len(ids) != 1 || ids[0] != traceID.String() {
+		t.Errorf("Expected an exemplar with trace ID %s, got %v", traceID, ids)
+	}
+
+	// Requests without a span are observed without exemplars, and the status
+	// code is taken from the response
+	req = httptest.NewRequest(http.MethodGet, "/api/segment", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	histogram = requestDurationSeries(t, registry, "/api/segment", http.MethodGet, "405")
+	if ids := exemplarTraceIDs(histogram); len(ids) != 0 {
+		t.Errorf("Expected no exemplars without a span, got %v", ids)
+	}
+}
+
+func TestEnqueueMetrics(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		failures   float64
+		dedupeHits float64
+	}{
+		{"queue unavailable", errors.New("redis: connection refused"), 1, 0},
+		{"task already queued", fmt.Errorf("failed to enqueue process document task: %w", asynq.ErrTaskIDConflict), 0, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics := NewMetrics(prometheus.NewRegistry())
+			handler := setupStatelessHandler()
+			handler.metrics = metrics
+			handler.queueClient = &mockQueueClient{err: tt.err}
+
+			req := httptest.NewRequest(http.MethodPost, "/api/analyze", bytes.NewReader([]byte(`{"text": "The council approved the plan."}`)))
+			w := httptest.NewRecorder()
+			handler.mux.ServeHTTP(w, req)
+			if w.Code != http.StatusInternalServerError {
+				t.Fatalf("Expected status 500, got %d: %s", w.Code, w.Body.String())
+			}
+
+			if got := testutil.ToFloat64(metrics.EnqueueFailures); got != tt.failures {
+				t.Errorf("Expected %v enqueue failures, got %v", tt.failures, got)
+			}
+			if got := testutil.ToFloat64(metrics.DedupeHits); got != tt.dedupeHits {
+				t.Errorf("Expected %v dedupe hits, got %v", tt.dedupeHits, got)
+			}
+		})
+	}
+}
+
+func TestNewMetricsReusesRegisteredCollectors(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	first := NewMetrics(registry)
+	second := NewMetrics(registry)
+
+	second.EnqueueFailures.Inc()
+	if got := testutil.ToFloat64(first.EnqueueFailures); got != 1 {
+		t.Errorf("Expected both handlers to share the registered counter, got %v", got)
+	}
+}
+
+func TestMetricsEndpointExposesExemplars(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	previousRegisterer, previousGatherer := prometheus.DefaultRegisterer, prometheus.DefaultGatherer
+	prometheus.DefaultRegisterer, prometheus.DefaultGatherer = registry, registry
+	defer func() {
+		prometheus.DefaultRegisterer, prometheus.DefaultGatherer = previousRegisterer, previousGatherer
+	}()
+
+	handler := NewHandler(nil, analyzer.New(), &mockQueueClient{}, Config{})
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  spanID,
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil).WithContext(ctx))
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if !strings.Contains(w.Body.String(), `trace_id="`+traceID.String()+`"`) {
+		t.Errorf("Expected the OpenMetrics exposition to include the exemplar, got:\n%s", w.Body.String())
+	}
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docutag/textanalyzer/internal/models"
+	"github.com/docutag/textanalyzer/internal/tags"
+)
+
+// Related tag defaults
+const (
+	defaultRelatedTagsLimit = 10
+	maxRelatedTagsLimit     = 100
+	relatedTagsCacheTTL     = time.Minute
+)
+
+// relatedTagsCache briefly caches tag co-occurrence results, which need a
+// self-join over the whole tags table
+type relatedTagsCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	now     func() time.Time
+	entries map[string]relatedTagsEntry
+}
+
+type relatedTagsEntry struct {
+	related   []models.RelatedTag
+	expiresAt time.Time
+}
+
+func newRelatedTagsCache(ttl time.Duration) *relatedTagsCache {
+	return &relatedTagsCache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]relatedTagsEntry),
+	}
+}
+
+// get returns the cached result for key if it has not expired
+func (c *relatedTagsCache) get(key string) ([]models.RelatedTag, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if c.now().After(entry.expiresAt) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry.related, true
+}
+
+// set caches a result for key, dropping expired entries so the cache only
+// holds recently requested tags
+func (c *relatedTagsCache) set(key string, related []models.RelatedTag) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	for k, entry := range c.entries {
+		if now.After(entry.expiresAt) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = relatedTagsEntry{related: related, expiresAt: now.Add(c.ttl)}
+}
+
+// handleTagOperations handles tag sub-resources:
+//
+//	GET /api/tags/{tag}/related?limit=10
+func (h *Handler) handleTagOperations(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(r.URL.Path[len("/api/tags/"):], "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] != "related" {
+		respondError(w, "Not found", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit := defaultRelatedTagsLimit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil || l < 1 || l > maxRelatedTagsLimit {
+			respondError(w, "limit must be between 1 and 100", http.StatusBadRequest)
+			return
+		}
+		limit = l
+	}
+
+	tag := tags.Normalize(parts[0])
+	key := tag + "|" + strconv.Itoa(limit)
+	related, ok := h.relatedTags.get(key)
+	if !ok {
+		var err error
+		related, err = h.db.TagCooccurrence(r.Context(), tag, limit)
+		if err != nil {
+			respondError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		h.relatedTags.set(key, related)
+	}
+
+	respondJSON(w, map[string]interface{}{
+		"tag":     tag,
+		"related": related,
+	}, http.StatusOK)
+}
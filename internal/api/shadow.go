@@ -0,0 +1,114 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/docutag/textanalyzer/internal/models"
+)
+
+// defaultShadowWindow is the period summarized when a shadow report does not set window
+const defaultShadowWindow = 24 * time.Hour
+
+// recommendedQuality is the quality score at which a text is recommended
+const recommendedQuality = 0.5
+
+// shadowReport summarizes how far a shadow model agreed with the primary
+// model over a time window
+type shadowReport struct {
+	Since          time.Time      `json:"since"`
+	WindowSeconds  float64        `json:"window_seconds"`
+	Samples        int            `json:"samples"`
+	Models         map[string]int `json:"models"` // Samples per "primary -> shadow" model pair
+	MeanTagJaccard float64        `json:"mean_tag_jaccard"`
+	TagsIdentical  int            `json:"tags_identical"` // Samples with the same topic tags
+
+	// Quality comparisons cover only samples the primary model scored with AI
+	QualityScored               int     `json:"quality_scored"`
+	MeanQualityScoreDelta       float64 `json:"mean_quality_score_delta"`
+	MeanAbsQualityScoreDelta    float64 `json:"mean_abs_quality_score_delta"`
+	RecommendationAgreement     float64 `json:"recommendation_agreement"` // Fraction where both models agree whether the text is recommended
+	RecommendationDisagreements int     `json:"recommendation_disagreements"`
+}
+
+// summarizeShadow builds the shadow report for results recorded since a time
+func summarizeShadow(results []*models.ShadowResult, since time.Time, window time.Duration) shadowReport {
+	report := shadowReport{
+		Since:         since,
+		WindowSeconds: window.Seconds(),
+		Models:        map[string]int{},
+	}
+
+	var jaccard, delta, absDelta float64
+	agreements := 0
+	for _, result := range results {
+		report.Samples++
+		report.Models[result.PrimaryModel+" -> "+result.Model]++
+		jaccard += result.TagJaccard
+		if result.TagJaccard == 1 {
+			report.TagsIdentical++
+		}
+
+		if result.QualityScoreDelta == nil || result.QualityScore == nil || result.PrimaryQuality == nil {
+			continue
+		}
+		report.QualityScored++
+		delta += *result.QualityScoreDelta
+		absDelta += math.Abs(*result.QualityScoreDelta)
+		if (*result.QualityScore >= recommendedQuality) == (*result.PrimaryQuality >= recommendedQuality) {
+			agreements++
+		} else {
+			report.RecommendationDisagreements++
+		}
+	}
+
+	if report.Samples > 0 {
+		report.MeanTagJaccard = round3(jaccard / float64(report.Samples))
+	}
+	if report.QualityScored > 0 {
+		scored := float64(report.QualityScored)
+		report.MeanQualityScoreDelta = round3(delta / scored)
+		report.MeanAbsQualityScoreDelta = round3(absDelta / scored)
+		report.RecommendationAgreement = round3(float64(agreements) / scored)
+	}
+	return report
+}
+
+// round3 rounds to three decimal places
+func round3(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
+
+// handleShadowReport summarizes shadow enrichment over a time window. It
+// requires the admin token, like the other admin endpoints.
+//
+//	GET /api/admin/shadow/report?window=24h
+func (h *Handler) handleShadowReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+
+	window := defaultShadowWindow
+	if raw := r.URL.Query().Get("window"); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed <= 0 {
+			respondError(w, "window must be a positive duration such as 24h", http.StatusBadRequest)
+			return
+		}
+		window = parsed
+	}
+
+	since := time.Now().Add(-window)
+	results, err := h.db.ListShadowResults(r.Context(), since)
+	if err != nil {
+		respondError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, summarizeShadow(results, since, window), http.StatusOK)
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/docutag/textanalyzer/internal/models"
+)
+
+func TestSummarizeShadow(t *testing.T) {
+	score := func(v float64) *float64 { return &v }
+	results := []*models.ShadowResult{
+		// Agree on recommendation, shadow scores higher
+		{Model: "candidate", PrimaryModel: "primary", TagJaccard: 1, QualityScore: score(0.9), PrimaryQuality: score(0.7), QualityScoreDelta: score(0.2)},
+		// Disagree on recommendation
+		{Model: "candidate", PrimaryModel: "primary", TagJaccard: 0.5, QualityScore: score(0.3), PrimaryQuality: score(0.6), QualityScoreDelta: score(-0.3)},
+		// Primary quality was not AI scored
+		{Model: "candidate", PrimaryModel: "primary", TagJaccard: 0.25, QualityScore: score(0.4)},
+	}
+
+	since := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	report := summarizeShadow(results, since, 24*time.Hour)
+
+	if report.Samples != 3 || report.Models["primary -> candidate"] != 3 {
+		t.Errorf("Expected 3 samples of one model pair, got %d and %v", report.Samples, report.Models)
+	}
+	if report.MeanTagJaccard != 0.583 || report.TagsIdentical != 1 {
+		t.Errorf("Expected mean tag Jaccard 0.583 with 1 identical, got %v and %d", report.MeanTagJaccard, report.TagsIdentical)
+	}
+	if report.QualityScored != 2 {
+		t.Errorf("Expected 2 quality comparisons, got %d", report.QualityScored)
+	}
+	if report.MeanQualityScoreDelta != -0.05 || report.MeanAbsQualityScoreDelta != 0.25 {
+		t.Errorf("Expected mean delta -0.05 and mean absolute delta 0.25, got %v and %v", report.MeanQualityScoreDelta, report.MeanAbsQualityScoreDelta)
+	}
+	if report.RecommendationAgreement != 0.5 || report.RecommendationDisagreements != 1 {
+		t.Errorf("Expected half the recommendations to agree, got %v with %d disagreements", report.RecommendationAgreement, report.RecommendationDisagreements)
+	}
+	if report.WindowSeconds != 86400 || !report.Since.Equal(since) {
+		t.Errorf("Unexpected window %v since %v", report.WindowSeconds, report.Since)
+	}
+
+	empty := summarizeShadow(nil, since, time.Hour)
+	if empty.Samples != 0 || empty.MeanTagJaccard != 0 || empty.Models == nil {
+		t.Errorf("Expected an empty report, got %+v", empty)
+	}
+}
+
+func TestShadowReportInvalidWindow(t *testing.T) {
+	handler := setupStatelessHandler()
+	handler.adminToken = "secret"
+
+	for _, window := range []string{"soon", "-1h", "0s"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/admin/shadow/report?window="+window, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		handler.mux.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for window %q, got %d", window, w.Code)
+		}
+	}
+}
+
+func TestShadowReportAuth(t *testing.T) {
+	handler := setupStatelessHandler()
+	handler.adminToken = "secret"
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/shadow/report", nil)
+	w := httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without a token, got %d", w.Code)
+	}
+
+	handler.adminToken = ""
+	req = httptest.NewRequest(http.MethodGet, "/api/admin/shadow/report", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	w = httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 without a configured admin token, got %d", w.Code)
+	}
+}
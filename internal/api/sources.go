@@ -0,0 +1,164 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/docutag/textanalyzer/internal/analyzer"
+	"github.com/docutag/textanalyzer/internal/models"
+)
+
+// clientMetadataSourceURLKey is the client metadata key read as the source
+// URL when a request does not set source_url
+const clientMetadataSourceURLKey = "source_url"
+
+// Versions listed per page
+const (
+	defaultSourceHistoryLimit = 100
+	maxSourceHistoryLimit     = 1000
+)
+
+// sourceVersion is one analysis in the history of a page
+type sourceVersion struct {
+	AnalysisID       string         `json:"analysis_id"`
+	CreatedAt        time.Time      `json:"created_at"`
+	QualityScore     *float64       `json:"quality_score,omitempty"`
+	Tags             []string       `json:"tags"`
+	ContentUnchanged bool           `json:"content_unchanged"` // Same text as the previous version
+	Changes          *sourceChanges `json:"changes,omitempty"` // Nil for the first version
+}
+
+// sourceChanges compares a version with the one before it
+type sourceChanges struct {
+	PreviousAnalysisID string   `json:"previous_analysis_id"`
+	QualityScoreDelta  *float64 `json:"quality_score_delta,omitempty"` // Nil unless both versions were scored
+	TagsAdded          []string `json:"tags_added"`
+	TagsRemoved        []string `json:"tags_removed"`
+}
+
+// resolveSourceURL returns the canonical source URL of a submission. An
+// explicit source_url must be valid; one taken from client metadata is
+// ignored when it is not a URL, since client metadata is otherwise opaque.
+func resolveSourceURL(sourceURL, fetchedURL string, clientMetadata map[string]string) (string, error) {
+	if sourceURL != "" {
+		return analyzer.CanonicalSourceURL(sourceURL, fetchedURL)
+	}
+	if fromMetadata := clientMetadata[clientMetadataSourceURLKey]; fromMetadata != "" {
+		if canonical, err := analyzer.CanonicalSourceURL(fromMetadata, fetchedURL); err == nil {
+			return canonical, nil
+		}
+	}
+	return "", nil
+}
+
+// sourceHistory describes each analysis of a page, oldest first, with its
+// quality and tag changes since the previous one
+func sourceHistory(analyses []*models.Analysis) []sourceVersion {
+	versions := make([]sourceVersion, 0, len(analyses))
+	for i, analysis := range analyses {
+		version := sourceVersion{
+			AnalysisID:   analysis.ID,
+			CreatedAt:    analysis.CreatedAt,
+			QualityScore: qualityScore(analysis),
+			Tags:         analysis.Metadata.Tags,
+		}
+		if version.Tags == nil {
+			version.Tags = []string{}
+		}
+
+		if i > 0 {
+			previous := analyses[i-1]
+			version.ContentUnchanged = analysis.Metadata.ContentUnchanged && analysis.Metadata.PreviousAnalysisID == previous.ID
+			version.Changes = &sourceChanges{
+				PreviousAnalysisID: previous.ID,
+				TagsAdded:          tagDifference(analysis.Metadata.Tags, previous.Metadata.Tags),
+				TagsRemoved:        tagDifference(previous.Metadata.Tags, analysis.Metadata.Tags),
+			}
+			if before, after := qualityScore(previous), version.QualityScore; before != nil && after != nil {
+				delta := math.Round((*after-*before)*1000) / 1000
+				version.Changes.QualityScoreDelta = &delta
+			}
+		}
+		versions = append(versions, version)
+	}
+	return versions
+}
+
+// qualityScore returns an analysis's quality score, or nil if it has none
+func qualityScore(analysis *models.Analysis) *float64 {
+	if analysis.Metadata.QualityScore == nil {
+		return nil
+	}
+	score := analysis.Metadata.QualityScore.Score
+	return &score
+}
+
+// tagDifference returns the tags in a that are not in b, sorted
+func tagDifference(a, b []string) []string {
+	inB := make(map[string]bool, len(b))
+	for _, tag := range b {
+		inB[tag] = true
+	}
+	difference := []string{}
+	for _, tag := range a {
+		if !inB[tag] {
+			difference = append(difference, tag)
+		}
+	}
+	sort.Strings(difference)
+	return difference
+}
+
+// handleSourceHistory returns the latest ?limit= analyses of a page, oldest
+// first, with the changes between consecutive versions
+//
+//	GET /api/sources?url=https://example.com/story
+func (h *Handler) handleSourceHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rawURL := r.URL.Query().Get("url")
+	if rawURL == "" {
+		respondError(w, "url parameter is required", http.StatusBadRequest)
+		return
+	}
+	sourceURL, err := analyzer.NormalizeSourceURL(rawURL)
+	if err != nil {
+		respondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultSourceHistoryLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			respondError(w, "Invalid limit: must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = min(parsed, maxSourceHistoryLimit)
+	}
+
+	// One version more than the limit is loaded so the oldest one listed is
+	// still compared with its predecessor
+	analyses, err := h.db.GetAnalysesBySourceURL(r.Context(), sourceURL, limit+1)
+	if err != nil {
+		respondError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	versions := sourceHistory(analyses)
+	if len(versions) > limit {
+		versions = versions[len(versions)-limit:]
+	}
+
+	respondJSON(w, map[string]interface{}{
+		"source_url": sourceURL,
+		"count":      len(versions),
+		"limit":      limit,
+		"analyses":   versions,
+	}, http.StatusOK)
+}
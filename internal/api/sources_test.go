@@ -0,0 +1,186 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/docutag/textanalyzer/internal/models"
+)
+
+func TestAnalyzeSourceURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     map[string]interface{}
+		code     int
+		expected string
+	}{
+		{"normalized", map[string]interface{}{"source_url": "HTTPS://Example.com/story/?utm_source=feed"}, http.StatusAccepted, "https://example.com/story"},
+		{"trivial redirect", map[string]interface{}{"source_url": "http://example.com/story", "fetched_url": "https://www.example.com/story"}, http.StatusAccepted, "https://www.example.com/story"},
+		{"from client metadata", map[string]interface{}{"client_metadata": map[string]string{"source_url": "https://example.com/story#top"}}, http.StatusAccepted, "https://example.com/story"},
+		{"opaque client metadata", map[string]interface{}{"client_metadata": map[string]string{"source_url": "crawl-42"}}, http.StatusAccepted, ""},
+		{"invalid", map[string]interface{}{"source_url": "example.com/story"}, http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := setupStatelessHandler()
+			mockQueue := &mockQueueClient{}
+			handler.queueClient = mockQueue
+
+			tt.body["text"] = "The council approved the plan."
+			body, _ := json.Marshal(tt.body)
+			w := httptest.NewRecorder()
+			handler.mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/analyze", bytes.NewReader(body)))
+			if w.Code != tt.code {
+				t.Fatalf("Expected status %d, got %d: %s", tt.code, w.Code, w.Body.String())
+			}
+			if tt.code != http.StatusAccepted {
+				return
+			}
+
+			var response map[string]interface{}
+			json.NewDecoder(w.Body).Decode(&response)
+			if got, _ := response["source_url"].(string); got != tt.expected {
+				t.Errorf("Expected source_url %q in the response, got %q", tt.expected, got)
+			}
+			if mockQueue.lastOptions.SourceURL != tt.expected {
+				t.Errorf("Expected source_url %q on the queued task, got %q", tt.expected, mockQueue.lastOptions.SourceURL)
+			}
+		})
+	}
+}
+
+func TestSourceHistoryDeltas(t *testing.T) {
+	created := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+	analyses := []*models.Analysis{
+		{ID: "v1", CreatedAt: created, Metadata: models.Metadata{
+			Tags:         []string{"transit", "council"},
+			QualityScore: &models.TextQualityScore{Score: 0.6},
+		}},
+		{ID: "v2", CreatedAt: created.Add(time.Hour), Metadata: models.Metadata{
+			Tags:               []string{"transit", "budget"},
+			QualityScore:       &models.TextQualityScore{Score: 0.75},
+			PreviousAnalysisID: "v1",
+		}},
+		{ID: "v3", CreatedAt: created.Add(2 * time.Hour), Metadata: models.Metadata{
+			Tags:               []string{"budget", "transit"},
+			PreviousAnalysisID: "v2",
+			ContentUnchanged:   true,
+		}},
+	}
+
+	versions := sourceHistory(analyses)
+	if len(versions) != 3 {
+		t.Fatalf("Expected 3 versions, got %d", len(versions))
+	}
+	if versions[0].Changes != nil || versions[0].ContentUnchanged {
+		t.Errorf("Expected no changes for the first version, got %+v", versions[0])
+	}
+
+	changes := versions[1].Changes
+	if changes == nil || changes.PreviousAnalysisID != "v1" || changes.QualityScoreDelta == nil || *changes.QualityScoreDelta != 0.15 {
+		t.Fatalf("Expected a quality delta of 0.15 from v1, got %+v", changes)
+	}
+	if !reflect.DeepEqual(changes.TagsAdded, []string{"budget"}) || !reflect.DeepEqual(changes.TagsRemoved, []string{"council"}) {
+		t.Errorf("Expected budget added and council removed, got %v and %v", changes.TagsAdded, changes.TagsRemoved)
+	}
+
+	// Reordered tags are not a change, and unscored versions have no delta
+	changes = versions[2].Changes
+	if len(changes.TagsAdded) != 0 || len(changes.TagsRemoved) != 0 || changes.QualityScoreDelta != nil {
+		t.Errorf("Expected no tag or quality changes for v3, got %+v", changes)
+	}
+	if !versions[2].ContentUnchanged {
+		t.Error("Expected v3 to be reported as unchanged")
+	}
+}
+
+func TestSourceHistoryEndpoint(t *testing.T) {
+	handler, db, cleanup := setupTestHandler(t)
+	defer cleanup()
+
+	created := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for i, tags := range [][]string{{"transit"}, {"transit", "budget"}} {
+		analysis := &models.Analysis{
+			ID:        []string{"test-source-001", "test-source-002"}[i],
+			Text:      "The council approved the plan.",
+			SourceURL: "https://example.com/story",
+			Metadata: models.Metadata{
+				Tags:         tags,
+				QualityScore: &models.TextQualityScore{Score: 0.5 + 0.1*float64(i)},
+			},
+			CreatedAt: created.Add(time.Duration(i) * time.Minute),
+			UpdatedAt: created,
+		}
+		if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+			t.Fatalf("Failed to save test analysis: %v", err)
+		}
+	}
+	other := &models.Analysis{ID: "test-source-other", Text: "Elsewhere.", SourceURL: "https://example.com/other", CreatedAt: created, UpdatedAt: created}
+	if err := db.SaveAnalysis(context.Background(), other); err != nil {
+		t.Fatalf("Failed to save test analysis: %v", err)
+	}
+
+	// Tracking parameters and case in the query URL are normalized away
+	req := httptest.NewRequest(http.MethodGet, "/api/sources?url=https://EXAMPLE.com/story/%3Futm_source%3Dfeed", nil)
+	w := httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var response struct {
+		SourceURL string          `json:"source_url"`
+		Count     int             `json:"count"`
+		Analyses  []sourceVersion `json:"analyses"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.SourceURL != "https://example.com/story" || response.Count != 2 {
+		t.Fatalf("Expected 2 analyses of https://example.com/story, got %d of %s", response.Count, response.SourceURL)
+	}
+	if response.Analyses[0].AnalysisID != "test-source-001" || response.Analyses[1].AnalysisID != "test-source-002" {
+		t.Errorf("Expected analyses oldest first, got %+v", response.Analyses)
+	}
+	if changes := response.Analyses[1].Changes; changes == nil || !reflect.DeepEqual(changes.TagsAdded, []string{"budget"}) {
+		t.Errorf("Expected budget added in the second version, got %+v", changes)
+	}
+
+	// A limit keeps the latest versions, still compared with their predecessor
+	w = httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/sources?url=https://example.com/story&limit=1", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	response.Analyses = nil
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Count != 1 || response.Analyses[0].AnalysisID != "test-source-002" {
+		t.Fatalf("Expected only test-source-002, got %+v", response.Analyses)
+	}
+	if changes := response.Analyses[0].Changes; changes == nil || changes.PreviousAnalysisID != "test-source-001" {
+		t.Errorf("Expected test-source-002 compared with test-source-001, got %+v", changes)
+	}
+
+	// The source URL is returned with the analysis
+	stored, err := db.GetAnalysis(context.Background(), "test-source-001")
+	if err != nil || stored.SourceURL != "https://example.com/story" {
+		t.Errorf("Expected the stored source URL, got %+v, %v", stored, err)
+	}
+
+	for _, query := range []string{"", "?url=not-a-url", "?url=https://example.com/story&limit=0"} {
+		w = httptest.NewRecorder()
+		handler.mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/sources"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %q, got %d", query, w.Code)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/docutag/textanalyzer/internal/models"
+)
+
+// statsCacheTTL is how long corpus statistics are served from memory, so
+// dashboards polling GET /api/stats do not rerun the aggregates each time
+const statsCacheTTL = 30 * time.Second
+
+// statsCache holds recently computed corpus statistics, keyed by since
+type statsCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	now     func() time.Time
+	entries map[string]statsEntry
+}
+
+type statsEntry struct {
+	stats     *models.CorpusStats
+	expiresAt time.Time
+}
+
+func newStatsCache(ttl time.Duration) *statsCache {
+	return &statsCache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]statsEntry),
+	}
+}
+
+// get returns the cached statistics for key if they have not expired
+func (c *statsCache) get(key string) (*models.CorpusStats, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if c.now().After(entry.expiresAt) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry.stats, true
+}
+
+// set caches statistics for key, dropping expired entries
+func (c *statsCache) set(key string, stats *models.CorpusStats) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	for k, entry := range c.entries {
+		if now.After(entry.expiresAt) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = statsEntry{stats: stats, expiresAt: now.Add(c.ttl)}
+}
+
+// parseSince parses the since parameter: an RFC 3339 time or a date, taken
+// as midnight UTC. An empty value is the zero time, matching everything.
+func parseSince(raw string) (time.Time, bool) {
+	if raw == "" {
+		return time.Time{}, true
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t.UTC(), true
+	}
+	if t, err := time.Parse("2006-01-02", raw); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// handleStats reports corpus-level aggregates
+//
+//	GET /api/stats?since=2025-01-01
+func (h *Handler) handleStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	since, ok := parseSince(r.URL.Query().Get("since"))
+	if !ok {
+		respondError(w, "since must be an RFC 3339 time or a YYYY-MM-DD date", http.StatusBadRequest)
+		return
+	}
+
+	key := since.Format(time.RFC3339Nano)
+	stats, ok := h.stats.get(key)
+	if !ok {
+		var err error
+		stats, err = h.db.CorpusStats(r.Context(), since)
+		if err != nil {
+			respondError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		h.stats.set(key, stats)
+	}
+
+	respondJSON(w, stats, http.StatusOK)
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/docutag/textanalyzer/internal/models"
+)
+
+func TestStatsCached(t *testing.T) {
+	handler := setupStatelessHandler()
+	since := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	handler.stats.set(since.Format(time.RFC3339Nano), &models.CorpusStats{
+		Since:         &since,
+		TotalAnalyses: 42,
+		ByStage:       map[string]int{"enriched": 42},
+		ByLanguage:    map[string]int{"en": 42},
+		TopTags:       []models.TagCount{{Tag: "policy", Count: 7}},
+		PerDay:        []models.DailyCount{},
+	})
+
+	// The stateless handler has no database, so only a cache hit can succeed;
+	// a date and the same instant in another zone share the entry
+	for _, path := range []string{"/api/stats?since=2025-01-01", "/api/stats?since=2025-01-01T01:00:00%2B01:00"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		handler.mux.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200 for %s, got %d: %s", path, w.Code, w.Body.String())
+		}
+		var stats models.CorpusStats
+		if err := json.NewDecoder(w.Body).Decode(&stats); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if stats.TotalAnalyses != 42 || len(stats.TopTags) != 1 {
+			t.Errorf("Expected cached stats for %s, got %+v", path, stats)
+		}
+	}
+
+	for _, path := range []string{"/api/stats?since=yesterday", "/api/stats?since=2025-13-01"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		handler.mux.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", path, w.Code)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/stats", nil)
+	w := httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405 for POST, got %d", w.Code)
+	}
+}
+
+func TestStatsCacheExpiry(t *testing.T) {
+	now := time.Now()
+	cache := newStatsCache(statsCacheTTL)
+	cache.now = func() time.Time { return now }
+
+	cache.set("", &models.CorpusStats{})
+	if _, ok := cache.get(""); !ok {
+		t.Fatal("Expected fresh stats to be cached")
+	}
+
+	now = now.Add(statsCacheTTL + time.Second)
+	if _, ok := cache.get(""); ok {
+		t.Error("Expected expired stats to be dropped")
+	}
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/docutag/textanalyzer/internal/tags"
+)
+
+// Tag listing defaults
+const (
+	defaultTagsLimit = 100
+	maxTagsLimit     = 1000
+)
+
+// handleListTags lists tags with their usage counts, most used first
+//
+//	GET /api/tags?prefix=cli&limit=100
+func (h *Handler) handleListTags(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit := defaultTagsLimit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil || l < 1 || l > maxTagsLimit {
+			respondError(w, "limit must be between 1 and 1000", http.StatusBadRequest)
+			return
+		}
+		limit = l
+	}
+
+	// Tags are stored normalized, so the prefix is too
+	prefix := tags.Normalize(r.URL.Query().Get("prefix"))
+	list, err := h.db.ListTags(r.Context(), prefix, limit)
+	if err != nil {
+		respondError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, map[string]interface{}{
+		"prefix": prefix,
+		"tags":   list,
+	}, http.StatusOK)
+}
+
+// renameTagRequest is the body of POST /api/tags/rename
+type renameTagRequest struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	DryRun bool   `json:"dry_run"`
+}
+
+// handleRenameTag renames a tag, or merges it into an existing one, on every
+// analysis carrying it. It rewrites the whole corpus, so it requires the
+// admin token.
+//
+//	POST /api/tags/rename {"from": "ml", "to": "machine-learning"}
+func (h *Handler) handleRenameTag(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxBody)
+
+	var req renameTagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		reqErr := bodyError(err)
+		respondError(w, reqErr.message, reqErr.status)
+		return
+	}
+
+	from, to := tags.Normalize(req.From), tags.Normalize(req.To)
+	if err := tags.Validate(from); err != nil {
+		respondError(w, "Invalid from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := tags.Validate(to); err != nil {
+		respondError(w, "Invalid to: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if from == to {
+		respondError(w, "from and to are the same tag", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.db.RenameTag(r.Context(), from, to, req.DryRun)
+	if err != nil {
+		respondError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, result, http.StatusOK)
+}
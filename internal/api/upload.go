@@ -0,0 +1,124 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/docutag/textanalyzer/internal/analyzer"
+	"github.com/docutag/textanalyzer/internal/queue"
+	"github.com/docutag/textanalyzer/internal/textutil"
+)
+
+// uploadField is the multipart form field holding an uploaded file
+const uploadField = "file"
+
+// uploadTypes maps the extensions of accepted uploads to whether the file
+// is HTML
+var uploadTypes = map[string]bool{
+	".txt":  false,
+	".md":   false,
+	".html": true,
+	".htm":  true,
+}
+
+// decodeAnalyzeRequest reads an analysis request from its body: a JSON
+// request, the text alone as text/plain, or a multipart/form-data upload of
+// a text, Markdown or HTML file in the "file" field. Bodies without a
+// Content-Type are read as JSON.
+func decodeAnalyzeRequest(r *http.Request, req *analyzeRequest) *requestError {
+	mediaType := ""
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		var err error
+		if mediaType, _, err = mime.ParseMediaType(contentType); err != nil {
+			return badRequest("Invalid Content-Type: " + err.Error())
+		}
+	}
+
+	switch mediaType {
+	case "", "application/json":
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			return bodyError(err)
+		}
+	case "text/plain":
+		text, err := io.ReadAll(r.Body)
+		if err != nil {
+			return bodyError(err)
+		}
+		req.Text = textutil.ValidUTF8(string(text))
+	case "multipart/form-data":
+		return readUpload(r, req)
+	default:
+		return &requestError{
+			message: fmt.Sprintf("Unsupported Content-Type %q: use application/json, text/plain or multipart/form-data", mediaType),
+			status:  http.StatusUnsupportedMediaType,
+		}
+	}
+	return nil
+}
+
+// readUpload sets the text of an analysis request from an uploaded file.
+// HTML files are also compressed into original_html, so offline cleaning and
+// enrichment can use the page structure.
+func readUpload(r *http.Request, req *analyzeRequest) *requestError {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return badRequest("Invalid multipart body: " + err.Error())
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return badRequest(fmt.Sprintf("Missing %q file field", uploadField))
+		}
+		if err != nil {
+			return bodyError(err)
+		}
+		if part.FormName() != uploadField {
+			continue
+		}
+
+		ext := strings.ToLower(filepath.Ext(part.FileName()))
+		isHTML, ok := uploadTypes[ext]
+		if !ok {
+			return &requestError{
+				message: fmt.Sprintf("Unsupported file type %q: upload a .txt, .md or .html file", ext),
+				status:  http.StatusUnsupportedMediaType,
+			}
+		}
+
+		content, err := io.ReadAll(part)
+		if err != nil {
+			return bodyError(err)
+		}
+		text := textutil.ValidUTF8(string(content))
+		if !isHTML {
+			req.Text = text
+			return nil
+		}
+
+		req.Text = analyzer.HTMLText(text)
+		if req.OriginalHTML, err = queue.CompressHTML(text); err != nil {
+			return &requestError{message: "Failed to compress HTML: " + err.Error(), status: http.StatusInternalServerError}
+		}
+		return nil
+	}
+}
+
+// bodyError rejects a request body that could not be read: 413 when it
+// exceeds the size limit, 400 otherwise
+func bodyError(err error) *requestError {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return &requestError{
+			message: fmt.Sprintf("Request body exceeds maximum size of %d bytes", tooLarge.Limit),
+			status:  http.StatusRequestEntityTooLarge,
+		}
+	}
+	return badRequest("Invalid request body")
+}
@@ -0,0 +1,223 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/docutag/textanalyzer/internal/queue"
+)
+
+// multipartBody builds a multipart/form-data body with one file
+func multipartBody(t *testing.T, field, filename, content string) (*bytes.Buffer, string) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile(field, filename)
+	if err != nil {
+		t.Fatalf("Failed to create form file: %v", err)
+	}
+	part.Write([]byte(content))
+	writer.Close()
+	return &body, writer.FormDataContentType()
+}
+
+func TestAnalyzeContentTypes(t *testing.T) {
+	handler := setupStatelessHandler()
+	mockQueue := handler.queueClient.(*mockQueueClient)
+
+	// Control characters need escaping in JSON but not in a plain text body
+	pasted := "Pasted text\twith a tab,\x0ba vertical tab and a bell\x07. It is long enough to analyze."
+	html := `<html><head><title>Page</title></head><body><h1>Heading</h1><p>Body &amp; text.</p></body></html>`
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        func() (*bytes.Buffer, string)
+		text        string
+		html        bool
+	}{
+		{
+			name: "json",
+			body: func() (*bytes.Buffer, string) {
+				return bytes.NewBufferString(`{"text": "JSON text."}`), "application/json; charset=utf-8"
+			},
+			text: "JSON text.",
+		},
+		{
+			name: "json without content type",
+			body: func() (*bytes.Buffer, string) {
+				return bytes.NewBufferString(`{"text": "JSON text."}`), ""
+			},
+			text: "JSON text.",
+		},
+		{
+			name: "plain text",
+			body: func() (*bytes.Buffer, string) {
+				return bytes.NewBufferString(pasted), "text/plain; charset=utf-8"
+			},
+			text: pasted,
+		},
+		{
+			name: "text file",
+			body: func() (*bytes.Buffer, string) {
+				return multipartBody(t, "file", "notes.txt", "Uploaded text.")
+			},
+			text: "Uploaded text.",
+		},
+		{
+			name: "markdown file",
+			body: func() (*bytes.Buffer, string) {
+				return multipartBody(t, "file", "README.MD", "# Title\n\nUploaded *markdown*.")
+			},
+			text: "# Title\n\nUploaded *markdown*.",
+		},
+		{
+			name: "html file",
+			body: func() (*bytes.Buffer, string) {
+				return multipartBody(t, "file", "page.html", html)
+			},
+			text: "Heading\n\nBody & text.",
+			html: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, contentType := tt.body()
+			req := httptest.NewRequest(http.MethodPost, "/api/analyze", body)
+			if contentType != "" {
+				req.Header.Set("Content-Type", contentType)
+			}
+			w := httptest.NewRecorder()
+
+			handler.mux.ServeHTTP(w, req)
+
+			if w.Code != http.StatusAccepted {
+				t.Fatalf("Expected status 202, got %d: %s", w.Code, w.Body.String())
+			}
+			if mockQueue.lastText != tt.text {
+				t.Errorf("Expected text %q, got %q", tt.text, mockQueue.lastText)
+			}
+			if !tt.html {
+				if mockQueue.lastHTML != "" {
+					t.Errorf("Expected no original HTML, got %q", mockQueue.lastHTML)
+				}
+				return
+			}
+			if size, err := queue.DecompressedHTMLSize(mockQueue.lastHTML, 1<<20); err != nil || size != int64(len(html)) {
+				t.Errorf("Expected the uploaded HTML compressed into original_html, got size %d, %v", size, err)
+			}
+		})
+	}
+}
+
+func TestAnalyzeRejectedUploads(t *testing.T) {
+	handler := setupStatelessHandler()
+	handler.maxBody = 64 << 10
+	mockQueue := handler.queueClient.(*mockQueueClient)
+
+	tests := []struct {
+		name     string
+		body     func() (*bytes.Buffer, string)
+		expected int
+	}{
+		{
+			name: "unknown content type",
+			body: func() (*bytes.Buffer, string) {
+				return bytes.NewBufferString("<text>XML</text>"), "application/xml"
+			},
+			expected: http.StatusUnsupportedMediaType,
+		},
+		{
+			name: "unsupported file type",
+			body: func() (*bytes.Buffer, string) {
+				return multipartBody(t, "file", "report.pdf", "%PDF-1.7")
+			},
+			expected: http.StatusUnsupportedMediaType,
+		},
+		{
+			name: "missing file field",
+			body: func() (*bytes.Buffer, string) {
+				return multipartBody(t, "document", "notes.txt", "Uploaded text.")
+			},
+			expected: http.StatusBadRequest,
+		},
+		{
+			name: "file over size limit",
+			body: func() (*bytes.Buffer, string) {
+				return multipartBody(t, "file", "big.txt", strings.Repeat("word ", 20000))
+			},
+			expected: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name: "plain text over size limit",
+			body: func() (*bytes.Buffer, string) {
+				return bytes.NewBufferString(strings.Repeat("word ", 20000)), "text/plain"
+			},
+			expected: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name: "empty plain text",
+			body: func() (*bytes.Buffer, string) {
+				return &bytes.Buffer{}, "text/plain"
+			},
+			expected: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockQueue.enqueued = 0
+			body, contentType := tt.body()
+			req := httptest.NewRequest(http.MethodPost, "/api/analyze", body)
+			req.Header.Set("Content-Type", contentType)
+			w := httptest.NewRecorder()
+
+			handler.mux.ServeHTTP(w, req)
+
+			if w.Code != tt.expected {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expected, w.Code, w.Body.String())
+			}
+			var response map[string]interface{}
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || response["error"] == nil {
+				t.Errorf("Expected a JSON error, got %q", w.Body.String())
+			}
+			if mockQueue.enqueued != 0 {
+				t.Errorf("Expected nothing enqueued, got %d tasks", mockQueue.enqueued)
+			}
+		})
+	}
+}
+
+func TestSegmentPlainText(t *testing.T) {
+	handler := setupStatelessHandler()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/segment", strings.NewReader("First sentence. Second sentence."))
+	req.Header.Set("Content-Type", "text/plain")
+	w := httptest.NewRecorder()
+
+	handler.mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var response map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response["sentence_count"] != float64(2) {
+		t.Errorf("Expected 2 sentences, got %v", response["sentence_count"])
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/segment", strings.NewReader("text=First"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	handler.mux.ServeHTTP(w, req)
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("Expected status 415, got %d", w.Code)
+	}
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/docutag/textanalyzer/internal/models"
+	"github.com/docutag/textanalyzer/internal/queue"
+)
+
+// WorkerConfigurer reports and changes the queue worker's settings at runtime
+type WorkerConfigurer interface {
+	Settings() models.WorkerSettings
+	Reconfigure(settings models.WorkerSettings) error
+}
+
+// WorkerSettingsStore persists worker settings so they survive a restart
+type WorkerSettingsStore interface {
+	SaveWorkerSettings(ctx context.Context, settings *models.WorkerSettings) error
+}
+
+// authorizeAdmin checks the request's bearer token against the configured
+// admin token, responding 403 when no token is configured and 401 when the
+// token is missing or wrong
+func (h *Handler) authorizeAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if h.adminToken == "" {
+		respondError(w, "Admin API is disabled: no admin token configured", http.StatusForbidden)
+		return false
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.adminToken)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		respondError(w, "Invalid or missing admin token", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// handleWorkerConfig handles GET and POST /api/admin/worker/config. GET
+// returns the worker's effective settings; POST changes them, restarting the
+// worker gracefully, and persists them for the next start. Omitted fields
+// keep their current values, and queue_weights only replaces the weights of
+// the queues it lists.
+func (h *Handler) handleWorkerConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+	if h.worker == nil {
+		respondError(w, "Worker configuration is not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		respondJSON(w, h.worker.Settings(), http.StatusOK)
+		return
+	}
+
+	var req struct {
+		Concurrency    *int           `json:"concurrency"`
+		QueueWeights   map[string]int `json:"queue_weights"`
+		StrictPriority *bool          `json:"strict_priority"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	settings := h.worker.Settings()
+	if req.Concurrency != nil {
+		settings.Concurrency = *req.Concurrency
+	}
+	for name, weight := range req.QueueWeights {
+		settings.QueueWeights[name] = weight
+	}
+	if req.StrictPriority != nil {
+		settings.StrictPriority = *req.StrictPriority
+	}
+
+	if err := h.worker.Reconfigure(settings); err != nil {
+		switch {
+		case errors.Is(err, queue.ErrInvalidWorkerSettings):
+			respondError(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, queue.ErrReconfigureInProgress):
+			respondError(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, queue.ErrWorkerStopped):
+			respondError(w, err.Error(), http.StatusServiceUnavailable)
+		default:
+			respondError(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	settings = h.worker.Settings()
+	if h.workerSettings != nil {
+		if err := h.workerSettings.SaveWorkerSettings(r.Context(), &settings); err != nil {
+			slog.Error("failed to persist worker settings", "error", err)
+			respondError(w, "Worker settings applied but not persisted: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	slog.Info("worker settings changed",
+		"concurrency", settings.Concurrency,
+		"queues", settings.QueueWeights,
+		"strict_priority", settings.StrictPriority,
+	)
+	respondJSON(w, settings, http.StatusOK)
+}
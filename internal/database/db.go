@@ -1,30 +1,140 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"log/slog"
+	"time"
 
 	_ "github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 )
 
+// Errors returned when a looked-up row does not exist. Callers match them
+// with errors.Is, so they may be wrapped with more context.
+var (
+	ErrNotFound         = errors.New("analysis not found")
+	ErrRevisionNotFound = errors.New("revision not found")
+)
+
+// ErrCancelled is returned when a task records progress on an analysis
+// whose job was cancelled; the cancelled stage is kept
+var ErrCancelled = errors.New("analysis cancelled")
+
+// Default connection pool settings. Most connections sit idle between
+// bursts of requests, so only a few are kept open, and connections are
+// recycled so the shared Postgres can rebalance them.
+const (
+	DefaultMaxOpenConns    = 25
+	DefaultMaxIdleConns    = 5
+	DefaultConnMaxLifetime = 30 * time.Minute
+	DefaultConnMaxIdleTime = 5 * time.Minute
+)
+
+// Options configure the connection pool. Zero values mean what they mean to
+// database/sql: no limit on open connections or connection age, and no idle
+// connections kept.
+type Options struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+
+	// Registerer receives the pool statistics and the connection wait
+	// histogram; they are not exported when it is nil
+	Registerer prometheus.Registerer
+}
+
+// DefaultOptions returns the default pool settings, without metrics
+func DefaultOptions() Options {
+	return Options{
+		MaxOpenConns:    DefaultMaxOpenConns,
+		MaxIdleConns:    DefaultMaxIdleConns,
+		ConnMaxLifetime: DefaultConnMaxLifetime,
+		ConnMaxIdleTime: DefaultConnMaxIdleTime,
+	}
+}
+
 // DB represents the database connection
 type DB struct {
 	conn *sql.DB
+
+	// acquireSeconds observes how long query helpers wait for a connection
+	acquireSeconds prometheus.Histogram
 }
 
-// New creates a new PostgreSQL database connection
+// New creates a new PostgreSQL database connection with the default pool
+// settings
 // PostgreSQL format: "host=... user=... password=... dbname=... port=..."
 func New(connStr string) (*DB, error) {
+	return NewWithOptions(connStr, DefaultOptions())
+}
+
+// NewWithOptions creates a new PostgreSQL database connection with the pool
+// settings of opts
+func NewWithOptions(connStr string, opts Options) (*DB, error) {
 	conn, err := sql.Open("postgres", connStr)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
+	configurePool(conn, opts)
 
 	if err := conn.Ping(); err != nil {
+		conn.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	return &DB{conn: conn}, nil
+	db := &DB{
+		conn: conn,
+		acquireSeconds: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "textanalyzer_db_conn_acquire_seconds",
+			Help:    "Time query helpers waited to acquire a database connection from the pool",
+			Buckets: prometheus.ExponentialBuckets(0.0001, 4, 10),
+		}),
+	}
+	if opts.Registerer != nil {
+		db.registerMetrics(opts.Registerer)
+	}
+	return db, nil
+}
+
+// configurePool applies the pool settings of opts to conn
+func configurePool(conn *sql.DB, opts Options) {
+	conn.SetMaxOpenConns(opts.MaxOpenConns)
+	conn.SetMaxIdleConns(opts.MaxIdleConns)
+	conn.SetConnMaxLifetime(opts.ConnMaxLifetime)
+	conn.SetConnMaxIdleTime(opts.ConnMaxIdleTime)
+}
+
+// registerMetrics registers the connection wait histogram and the pool
+// statistics, including how often and how long callers waited for a
+// connection, as go_sql_* metrics labeled db_name="textanalyzer"
+func (db *DB) registerMetrics(registerer prometheus.Registerer) {
+	if err := registerer.Register(db.acquireSeconds); err != nil {
+		if existing, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			db.acquireSeconds = existing.ExistingCollector.(prometheus.Histogram)
+		} else {
+			slog.Default().Warn("failed to register connection acquire histogram", "error", err)
+		}
+	}
+	if err := registerer.Register(collectors.NewDBStatsCollector(db.conn, "textanalyzer")); err != nil {
+		slog.Default().Warn("failed to register database pool metrics", "error", err)
+	}
+}
+
+// acquire takes a connection from the pool for a query helper, observing
+// how long it waited. Closing the connection returns it to the pool.
+func (db *DB) acquire(ctx context.Context) (*sql.Conn, error) {
+	start := time.Now()
+	conn, err := db.conn.Conn(ctx)
+	db.acquireSeconds.Observe(time.Since(start).Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	return conn, nil
 }
 
 // Close closes the database connection
@@ -1,7 +1,16 @@
 package database
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"testing"
+	"time"
+
+	"github.com/docutag/textanalyzer/internal/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 )
 
 func TestNew(t *testing.T) {
@@ -138,6 +147,109 @@ func TestConnectionPoolSettings(t *testing.T) {
 	}
 }
 
+func TestConfigurePool(t *testing.T) {
+	// sql.Open does not connect, so no database is needed
+	conn, err := sql.Open("postgres", "host=localhost dbname=pool_test sslmode=disable")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer conn.Close()
+
+	configurePool(conn, Options{MaxOpenConns: 7, MaxIdleConns: 3})
+	if got := conn.Stats().MaxOpenConnections; got != 7 {
+		t.Errorf("Expected 7 max open connections, got %d", got)
+	}
+
+	configurePool(conn, DefaultOptions())
+	if got := conn.Stats().MaxOpenConnections; got != DefaultMaxOpenConns {
+		t.Errorf("Expected %d max open connections, got %d", DefaultMaxOpenConns, got)
+	}
+}
+
+func TestConnectionPoolExhaustion(t *testing.T) {
+	connStr, cleanup := setupTestDB(t, "test_pool_exhaustion")
+	defer cleanup()
+
+	registry := prometheus.NewRegistry()
+	db, err := NewWithOptions(connStr, Options{
+		MaxOpenConns: 2,
+		MaxIdleConns: 1,
+		Registerer:   registry,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	var held []*sql.Conn
+	for i := 0; i < 2; i++ {
+		conn, err := db.acquire(ctx)
+		if err != nil {
+			t.Fatalf("Failed to acquire connection %d: %v", i, err)
+		}
+		held = append(held, conn)
+	}
+
+	// A third caller waits for a free connection until its context ends
+	waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := db.acquire(waitCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded from exhausted pool, got %v", err)
+	}
+
+	for _, conn := range held {
+		conn.Close()
+	}
+
+	stats := db.conn.Stats()
+	if stats.WaitCount == 0 {
+		t.Error("Expected the exhausted pool to record a wait")
+	}
+	if stats.MaxIdleClosed == 0 {
+		t.Error("Expected a connection beyond the idle limit to be closed")
+	}
+
+	if got := testutil.CollectAndCount(registry, "textanalyzer_db_conn_acquire_seconds"); got != 1 {
+		t.Errorf("Expected the acquire histogram to be exported, got %d series", got)
+	}
+	if got := testutil.CollectAndCount(registry, "go_sql_wait_count_total"); got != 1 {
+		t.Errorf("Expected the pool wait count to be exported, got %d series", got)
+	}
+}
+
+// TestQueryHelpersObserveAcquire tests that writes and transactions wait
+// for their connection through acquire, as reads do
+func TestQueryHelpersObserveAcquire(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	acquired := func() uint64 {
+		var m dto.Metric
+		if err := db.acquireSeconds.Write(&m); err != nil {
+			t.Fatalf("Failed to read acquire histogram: %v", err)
+		}
+		return m.GetHistogram().GetSampleCount()
+	}
+
+	ctx := context.Background()
+	before := acquired()
+	analysis := createTestAnalysis("test-acquire-001")
+	if err := db.SaveAnalysis(ctx, analysis); err != nil {
+		t.Fatalf("Failed to save analysis: %v", err)
+	}
+	if err := db.UpdateProcessingStage(ctx, analysis.ID, models.ProcessingStageOfflineComplete); err != nil {
+		t.Fatalf("Failed to update processing stage: %v", err)
+	}
+	if err := db.DeleteAnalysis(ctx, analysis.ID); err != nil {
+		t.Fatalf("Failed to delete analysis: %v", err)
+	}
+
+	if got := acquired() - before; got != 3 {
+		t.Errorf("Expected 3 connection acquisitions observed, got %d", got)
+	}
+}
+
 func TestConcurrentAccess(t *testing.T) {
 	connStr, cleanup := setupTestDB(t, "test_concurrent")
 	defer cleanup()
@@ -1,15 +1,26 @@
 package database
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"log/slog"
+	"strings"
 )
 
-// Migration represents a database migration
+// Migration represents a database migration. Its SQL runs in one
+// transaction with the row recording its version, so it must not use
+// statements that cannot run in a transaction, such as CREATE INDEX
+// CONCURRENTLY. It must also be idempotent, so that a schema whose recorded
+// versions are out of step with its tables can be migrated again.
 type Migration struct {
 	Version int
 	Name    string
 	SQL     string
+
+	// DownSQL reverts SQL, also idempotently and in one transaction; a
+	// migration without it is irreversible
+	DownSQL string
 }
 
 // migrations contains all PostgreSQL database migrations in order
@@ -27,6 +38,9 @@ var migrations = []Migration{
 			);
 			CREATE INDEX IF NOT EXISTS idx_textanalyzer_analyses_created_at ON textanalyzer_analyses(created_at);
 		`,
+		DownSQL: `
+			DROP TABLE IF EXISTS textanalyzer_analyses;
+		`,
 	},
 	{
 		Version: 2,
@@ -41,10 +55,14 @@ var migrations = []Migration{
 			CREATE INDEX IF NOT EXISTS idx_textanalyzer_tags_analysis_id ON textanalyzer_tags(analysis_id);
 			CREATE INDEX IF NOT EXISTS idx_textanalyzer_tags_tag ON textanalyzer_tags(tag);
 		`,
+		DownSQL: `
+			DROP TABLE IF EXISTS textanalyzer_tags;
+		`,
 	},
 	{
 		Version: 3,
 		Name:    "create_schema_version_table",
+		// Irreversible: reverting it would drop the record of every version
 		SQL: `
 			CREATE TABLE IF NOT EXISTS textanalyzer_schema_version (
 				version INTEGER PRIMARY KEY,
@@ -69,6 +87,9 @@ var migrations = []Migration{
 			CREATE INDEX IF NOT EXISTS idx_textanalyzer_text_references_text ON textanalyzer_text_references(text);
 			CREATE INDEX IF NOT EXISTS idx_textanalyzer_text_references_type ON textanalyzer_text_references(type);
 		`,
+		DownSQL: `
+			DROP TABLE IF EXISTS textanalyzer_text_references;
+		`,
 	},
 	{
 		Version: 5,
@@ -84,6 +105,18 @@ var migrations = []Migration{
 			CREATE INDEX IF NOT EXISTS idx_textanalyzer_analyses_processing_stage ON textanalyzer_analyses(processing_stage);
 			CREATE INDEX IF NOT EXISTS idx_textanalyzer_analyses_enqueued_at ON textanalyzer_analyses(enqueued_at);
 		`,
+		DownSQL: `
+			DROP INDEX IF EXISTS idx_textanalyzer_analyses_enqueued_at;
+			DROP INDEX IF EXISTS idx_textanalyzer_analyses_processing_stage;
+			ALTER TABLE textanalyzer_analyses
+				DROP COLUMN IF EXISTS last_error,
+				DROP COLUMN IF EXISTS max_retries,
+				DROP COLUMN IF EXISTS retry_count,
+				DROP COLUMN IF EXISTS completed_at,
+				DROP COLUMN IF EXISTS started_at,
+				DROP COLUMN IF EXISTS enqueued_at,
+				DROP COLUMN IF EXISTS processing_stage;
+		`,
 	},
 	{
 		Version: 6,
@@ -91,57 +124,528 @@ var migrations = []Migration{
 		SQL: `
 			ALTER TABLE textanalyzer_analyses ADD COLUMN IF NOT EXISTS original_html TEXT;
 		`,
+		DownSQL: `
+			ALTER TABLE textanalyzer_analyses DROP COLUMN IF EXISTS original_html;
+		`,
+	},
+	{
+		Version: 7,
+		Name:    "create_analysis_images_table",
+		SQL: `
+			CREATE TABLE IF NOT EXISTS textanalyzer_analysis_images (
+				id SERIAL PRIMARY KEY,
+				analysis_id TEXT NOT NULL,
+				image_index INTEGER NOT NULL,
+				url TEXT NOT NULL,
+				domain TEXT,
+				format TEXT NOT NULL DEFAULT 'unknown',
+				content_type TEXT,
+				content_length BIGINT DEFAULT 0,
+				width INTEGER DEFAULT 0,
+				height INTEGER DEFAULT 0,
+				status_code INTEGER DEFAULT 0,
+				fetched BOOLEAN DEFAULT FALSE,
+				oversized BOOLEAN DEFAULT FALSE,
+				is_tracking_pixel BOOLEAN DEFAULT FALSE,
+				error TEXT,
+				created_at TIMESTAMPTZ DEFAULT NOW(),
+				updated_at TIMESTAMPTZ DEFAULT NOW(),
+				UNIQUE (analysis_id, image_index),
+				FOREIGN KEY (analysis_id) REFERENCES textanalyzer_analyses(id) ON DELETE CASCADE
+			);
+			CREATE INDEX IF NOT EXISTS idx_textanalyzer_analysis_images_analysis_id ON textanalyzer_analysis_images(analysis_id);
+		`,
+		DownSQL: `
+			DROP TABLE IF EXISTS textanalyzer_analysis_images;
+		`,
+	},
+	{
+		Version: 8,
+		Name:    "create_worker_heartbeats_table",
+		SQL: `
+			CREATE TABLE IF NOT EXISTS textanalyzer_worker_heartbeats (
+				worker_id TEXT PRIMARY KEY,
+				heartbeat_at TIMESTAMPTZ NOT NULL,
+				started_at TIMESTAMPTZ NOT NULL,
+				active_tasks INTEGER DEFAULT 0,
+				processed_tasks BIGINT DEFAULT 0,
+				failed_tasks BIGINT DEFAULT 0,
+				last_task_completed_at TIMESTAMPTZ
+			);
+		`,
+		DownSQL: `
+			DROP TABLE IF EXISTS textanalyzer_worker_heartbeats;
+		`,
+	},
+	{
+		Version: 9,
+		Name:    "create_analysis_revisions_table",
+		SQL: `
+			CREATE TABLE IF NOT EXISTS textanalyzer_analysis_revisions (
+				id SERIAL PRIMARY KEY,
+				analysis_id TEXT NOT NULL,
+				revision INTEGER NOT NULL,
+				fields JSONB NOT NULL,
+				model TEXT,
+				created_at TIMESTAMPTZ DEFAULT NOW(),
+				UNIQUE (analysis_id, revision),
+				FOREIGN KEY (analysis_id) REFERENCES textanalyzer_analyses(id) ON DELETE CASCADE
+			);
+		`,
+		DownSQL: `
+			DROP TABLE IF EXISTS textanalyzer_analysis_revisions;
+		`,
+	},
+	{
+		Version: 10,
+		Name:    "add_client_metadata",
+		SQL: `
+			ALTER TABLE textanalyzer_analyses ADD COLUMN IF NOT EXISTS client_metadata JSONB NOT NULL DEFAULT '{}';
+			CREATE INDEX IF NOT EXISTS idx_textanalyzer_analyses_client_metadata ON textanalyzer_analyses USING GIN (client_metadata);
+		`,
+		DownSQL: `
+			DROP INDEX IF EXISTS idx_textanalyzer_analyses_client_metadata;
+			ALTER TABLE textanalyzer_analyses DROP COLUMN IF EXISTS client_metadata;
+		`,
+	},
+	{
+		Version: 11,
+		Name:    "add_tags_unique_constraint",
+		SQL: `
+			DELETE FROM textanalyzer_tags a
+				USING textanalyzer_tags b
+				WHERE a.analysis_id = b.analysis_id AND a.tag = b.tag AND a.id > b.id;
+			DO $$
+			BEGIN
+				IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'textanalyzer_tags_analysis_id_tag_key') THEN
+					ALTER TABLE textanalyzer_tags
+						ADD CONSTRAINT textanalyzer_tags_analysis_id_tag_key UNIQUE (analysis_id, tag);
+				END IF;
+			END $$;
+		`,
+		// Duplicate tags removed by SQL are not brought back
+		DownSQL: `
+			ALTER TABLE textanalyzer_tags DROP CONSTRAINT IF EXISTS textanalyzer_tags_analysis_id_tag_key;
+		`,
+	},
+	{
+		Version: 12,
+		Name:    "create_worker_settings_table",
+		SQL: `
+			CREATE TABLE IF NOT EXISTS textanalyzer_worker_settings (
+				id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+				settings JSONB NOT NULL,
+				updated_at TIMESTAMPTZ DEFAULT NOW()
+			);
+		`,
+		DownSQL: `
+			DROP TABLE IF EXISTS textanalyzer_worker_settings;
+		`,
+	},
+	{
+		Version: 13,
+		Name:    "add_source_url",
+		SQL: `
+			ALTER TABLE textanalyzer_analyses ADD COLUMN IF NOT EXISTS source_url TEXT;
+			CREATE INDEX IF NOT EXISTS idx_textanalyzer_analyses_source_url ON textanalyzer_analyses(source_url, created_at);
+		`,
+		DownSQL: `
+			DROP INDEX IF EXISTS idx_textanalyzer_analyses_source_url;
+			ALTER TABLE textanalyzer_analyses DROP COLUMN IF EXISTS source_url;
+		`,
+	},
+	{
+		Version: 14,
+		Name:    "add_shadow_results",
+		SQL: `
+			ALTER TABLE textanalyzer_analyses ADD COLUMN IF NOT EXISTS shadow JSONB;
+			ALTER TABLE textanalyzer_analyses ADD COLUMN IF NOT EXISTS shadow_at TIMESTAMPTZ;
+			CREATE INDEX IF NOT EXISTS idx_textanalyzer_analyses_shadow_at ON textanalyzer_analyses(shadow_at) WHERE shadow_at IS NOT NULL;
+		`,
+		DownSQL: `
+			DROP INDEX IF EXISTS idx_textanalyzer_analyses_shadow_at;
+			ALTER TABLE textanalyzer_analyses DROP COLUMN IF EXISTS shadow_at, DROP COLUMN IF EXISTS shadow;
+		`,
+	},
+	{
+		Version: 15,
+		Name:    "add_list_filter_indexes",
+		SQL: `
+			CREATE INDEX IF NOT EXISTS idx_textanalyzer_analyses_language ON textanalyzer_analyses((metadata->>'language'), created_at);
+			CREATE INDEX IF NOT EXISTS idx_textanalyzer_analyses_quality_score ON textanalyzer_analyses(((metadata->'quality_score'->>'score')::float8));
+		`,
+		DownSQL: `
+			DROP INDEX IF EXISTS idx_textanalyzer_analyses_quality_score;
+			DROP INDEX IF EXISTS idx_textanalyzer_analyses_language;
+		`,
+	},
+	{
+		Version: 16,
+		Name:    "add_text_search",
+		// Only the first 100000 characters of each text are indexed
+		// (searchableChars), keeping long documents under the 1MB tsvector limit.
+		// Adding a stored generated column rewrites the whole table under an
+		// ACCESS EXCLUSIVE lock, so reads and writes of analyses block until
+		// every existing text is vectorized and indexed: upgrade large
+		// databases past this version in a maintenance window.
+		SQL: `
+			ALTER TABLE textanalyzer_analyses ADD COLUMN IF NOT EXISTS search_vector tsvector
+				GENERATED ALWAYS AS (
+					to_tsvector('english', left(text, 100000) || ' ' || left(COALESCE(metadata->>'cleaned_text', ''), 100000))
+				) STORED;
+			CREATE INDEX IF NOT EXISTS idx_textanalyzer_analyses_search_vector ON textanalyzer_analyses USING GIN (search_vector);
+		`,
+		DownSQL: `
+			DROP INDEX IF EXISTS idx_textanalyzer_analyses_search_vector;
+			ALTER TABLE textanalyzer_analyses DROP COLUMN IF EXISTS search_vector;
+		`,
+	},
+	{
+		Version: 17,
+		Name:    "add_text_hash",
+		// Hashes stored text normalized as TextHash does (\x0B is \v); only
+		// the oldest analysis of each text holds its hash, and redacted
+		// analyses are left unhashed since their text was not stored
+		SQL: `
+			ALTER TABLE textanalyzer_analyses ADD COLUMN IF NOT EXISTS text_hash TEXT;
+			WITH hashed AS (
+				SELECT DISTINCT ON (hash) id, hash
+				FROM (
+					SELECT id, created_at, encode(sha256(convert_to(
+						regexp_replace(btrim(text, E' \t\n\r\f\x0B'), E'[ \t\n\r\f\x0B]+', ' ', 'g'),
+						'UTF8')), 'hex') AS hash
+					FROM textanalyzer_analyses
+					WHERE metadata->'redaction' IS NULL
+				) texts
+				ORDER BY hash, created_at, id
+			)
+			UPDATE textanalyzer_analyses a SET text_hash = hashed.hash
+			FROM hashed
+			WHERE a.id = hashed.id AND a.text_hash IS NULL
+				AND NOT EXISTS (SELECT 1 FROM textanalyzer_analyses h WHERE h.text_hash = hashed.hash);
+			CREATE UNIQUE INDEX IF NOT EXISTS idx_textanalyzer_analyses_text_hash ON textanalyzer_analyses(text_hash) WHERE text_hash IS NOT NULL;
+		`,
+		DownSQL: `
+			DROP INDEX IF EXISTS idx_textanalyzer_analyses_text_hash;
+			ALTER TABLE textanalyzer_analyses DROP COLUMN IF EXISTS text_hash;
+		`,
+	},
+	{
+		Version: 18,
+		Name:    "add_callback_url",
+		SQL: `
+			ALTER TABLE textanalyzer_analyses ADD COLUMN IF NOT EXISTS callback_url TEXT;
+		`,
+		DownSQL: `
+			ALTER TABLE textanalyzer_analyses DROP COLUMN IF EXISTS callback_url;
+		`,
+	},
+	{
+		Version: 19,
+		Name:    "add_stats_indexes",
+		// Lets GET /api/stats count analyses by stage and by day from the
+		// index alone
+		SQL: `
+			CREATE INDEX IF NOT EXISTS idx_textanalyzer_analyses_created_at_stage ON textanalyzer_analyses(created_at, processing_stage);
+		`,
+		DownSQL: `
+			DROP INDEX IF EXISTS idx_textanalyzer_analyses_created_at_stage;
+		`,
+	},
+	{
+		Version: 20,
+		Name:    "add_tag_prefix_index",
+		// LIKE 'prefix%' only uses a B-tree index under the C collation or
+		// with the pattern operator class
+		SQL: `
+			CREATE INDEX IF NOT EXISTS idx_textanalyzer_tags_tag_pattern ON textanalyzer_tags(tag text_pattern_ops);
+		`,
+		DownSQL: `
+			DROP INDEX IF EXISTS idx_textanalyzer_tags_tag_pattern;
+		`,
+	},
+	{
+		Version: 21,
+		Name:    "add_named_entities_index",
+		SQL: `
+			CREATE INDEX IF NOT EXISTS idx_textanalyzer_analyses_named_entities ON textanalyzer_analyses USING GIN ((metadata->'named_entities') jsonb_path_ops);
+		`,
+		DownSQL: `
+			DROP INDEX IF EXISTS idx_textanalyzer_analyses_named_entities;
+		`,
+	},
+	{
+		Version: 22,
+		Name:    "add_created_at_id_index",
+		// Serves the listing order and its cursor condition,
+		// (created_at, id) < ($1, $2), scanned backwards
+		SQL: `
+			CREATE INDEX IF NOT EXISTS idx_textanalyzer_analyses_created_at_id ON textanalyzer_analyses(created_at, id);
+		`,
+		DownSQL: `
+			DROP INDEX IF EXISTS idx_textanalyzer_analyses_created_at_id;
+		`,
+	},
+	{
+		Version: 23,
+		Name:    "add_deleted_at",
+		// Soft-deleted analyses keep their rows, tags and references until
+		// they are hard-deleted
+		SQL: `
+			ALTER TABLE textanalyzer_analyses ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+		`,
+		// The earlier schema cannot hide analyses, so soft-deleted ones are
+		// purged rather than shown again
+		DownSQL: `
+			DELETE FROM textanalyzer_analyses WHERE deleted_at IS NOT NULL;
+			ALTER TABLE textanalyzer_analyses DROP COLUMN IF EXISTS deleted_at;
+		`,
+	},
+	{
+		Version: 24,
+		Name:    "create_analysis_history_table",
+		SQL: `
+			CREATE TABLE IF NOT EXISTS textanalyzer_analysis_history (
+				id BIGSERIAL PRIMARY KEY,
+				analysis_id TEXT NOT NULL,
+				stage TEXT NOT NULL,
+				task_id TEXT,
+				metadata JSONB NOT NULL,
+				created_at TIMESTAMPTZ DEFAULT NOW(),
+				FOREIGN KEY (analysis_id) REFERENCES textanalyzer_analyses(id) ON DELETE CASCADE
+			);
+			CREATE INDEX IF NOT EXISTS idx_textanalyzer_analysis_history_analysis_id ON textanalyzer_analysis_history(analysis_id, id);
+		`,
+		DownSQL: `
+			DROP TABLE IF EXISTS textanalyzer_analysis_history;
+		`,
+	},
+	{
+		Version: 25,
+		Name:    "add_image_ai_caption",
+		SQL: `
+			ALTER TABLE textanalyzer_analysis_images ADD COLUMN IF NOT EXISTS ai_caption TEXT;
+		`,
+		DownSQL: `
+			ALTER TABLE textanalyzer_analysis_images DROP COLUMN IF EXISTS ai_caption;
+		`,
+	},
+	{
+		Version: 26,
+		Name:    "add_image_ai_tags",
+		SQL: `
+			ALTER TABLE textanalyzer_analysis_images ADD COLUMN IF NOT EXISTS ai_tags JSONB;
+		`,
+		DownSQL: `
+			ALTER TABLE textanalyzer_analysis_images DROP COLUMN IF EXISTS ai_tags;
+		`,
+	},
+	{
+		Version: 27,
+		Name:    "add_editorial_indexes",
+		SQL: `
+			CREATE INDEX IF NOT EXISTS idx_textanalyzer_analyses_editorial_bias ON textanalyzer_analyses((metadata->'editorial'->>'bias'), created_at);
+			CREATE INDEX IF NOT EXISTS idx_textanalyzer_analyses_editorial_purpose ON textanalyzer_analyses((metadata->'editorial'->>'purpose'), created_at);
+		`,
+		DownSQL: `
+			DROP INDEX IF EXISTS idx_textanalyzer_analyses_editorial_purpose;
+			DROP INDEX IF EXISTS idx_textanalyzer_analyses_editorial_bias;
+		`,
+	},
+	{
+		Version: 28,
+		Name:    "add_entities_index",
+		// Serves searches for named entities of a type
+		SQL: `
+			CREATE INDEX IF NOT EXISTS idx_textanalyzer_analyses_entities ON textanalyzer_analyses USING GIN ((metadata->'entities') jsonb_path_ops);
+		`,
+		DownSQL: `
+			DROP INDEX IF EXISTS idx_textanalyzer_analyses_entities;
+		`,
+	},
+	{
+		Version: 29,
+		Name:    "scope_text_hash_to_live_analyses",
+		// Soft-deleted analyses no longer hold their text hash, so
+		// resubmitting a deleted text claims it for the new analysis
+		SQL: `
+			DROP INDEX IF EXISTS idx_textanalyzer_analyses_text_hash;
+			CREATE UNIQUE INDEX IF NOT EXISTS idx_textanalyzer_analyses_text_hash ON textanalyzer_analyses(text_hash) WHERE text_hash IS NOT NULL AND deleted_at IS NULL;
+		`,
+		// Only one analysis of each text keeps its hash: a live one, else
+		// the oldest
+		DownSQL: `
+			UPDATE textanalyzer_analyses a SET text_hash = NULL
+			WHERE a.text_hash IS NOT NULL AND EXISTS (
+				SELECT 1 FROM textanalyzer_analyses b
+				WHERE b.text_hash = a.text_hash AND b.id <> a.id
+					AND ((b.deleted_at IS NULL AND a.deleted_at IS NOT NULL)
+						OR ((b.deleted_at IS NULL) = (a.deleted_at IS NULL) AND (b.created_at, b.id) < (a.created_at, a.id)))
+			);
+			DROP INDEX IF EXISTS idx_textanalyzer_analyses_text_hash;
+			CREATE UNIQUE INDEX IF NOT EXISTS idx_textanalyzer_analyses_text_hash ON textanalyzer_analyses(text_hash) WHERE text_hash IS NOT NULL;
+		`,
+	},
+	{
+		Version: 30,
+		Name:    "language_names_to_codes",
+		// Analyses stored before languages were reported as ISO 639-1
+		// codes name them in full, which the language filter and stats
+		// would otherwise count apart
+		SQL: `
+			UPDATE textanalyzer_analyses SET metadata = jsonb_set(metadata, '{language}', to_jsonb(CASE metadata->>'language'
+				WHEN 'english' THEN 'en' WHEN 'spanish' THEN 'es' WHEN 'french' THEN 'fr' WHEN 'german' THEN 'de' END))
+			WHERE metadata->>'language' IN ('english', 'spanish', 'french', 'german');
+			UPDATE textanalyzer_analysis_history SET metadata = jsonb_set(metadata, '{language}', to_jsonb(CASE metadata->>'language'
+				WHEN 'english' THEN 'en' WHEN 'spanish' THEN 'es' WHEN 'french' THEN 'fr' WHEN 'german' THEN 'de' END))
+			WHERE metadata->>'language' IN ('english', 'spanish', 'french', 'german');
+		`,
+		// Codes cannot be told apart from those of newer analyses, so all
+		// of them go back to names
+		DownSQL: `
+			UPDATE textanalyzer_analyses SET metadata = jsonb_set(metadata, '{language}', to_jsonb(CASE metadata->>'language'
+				WHEN 'en' THEN 'english' WHEN 'es' THEN 'spanish' WHEN 'fr' THEN 'french' WHEN 'de' THEN 'german' END))
+			WHERE metadata->>'language' IN ('en', 'es', 'fr', 'de');
+			UPDATE textanalyzer_analysis_history SET metadata = jsonb_set(metadata, '{language}', to_jsonb(CASE metadata->>'language'
+				WHEN 'en' THEN 'english' WHEN 'es' THEN 'spanish' WHEN 'fr' THEN 'french' WHEN 'de' THEN 'german' END))
+			WHERE metadata->>'language' IN ('en', 'es', 'fr', 'de');
+		`,
 	},
 }
 
+// migrationLockKey is the advisory lock held while migrating, so instances
+// starting at the same time apply migrations one after another
+const migrationLockKey = 7248190353
+
+// LatestVersion is the schema version Migrate brings the database to
+func LatestVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
 // Migrate runs all pending PostgreSQL migrations
 func (db *DB) Migrate() error {
+	return db.MigrateTo(LatestVersion())
+}
+
+// MigrateTo applies or reverts migrations until the database is at version:
+// migrations above it that were applied are reverted, newest first, and
+// those up to it that were not are applied, oldest first. Nothing is
+// reverted when one of the migrations to revert is irreversible; the error
+// lists them. It holds an advisory lock throughout, so when several
+// instances start together one migrates while the others wait, then finds
+// nothing left to do.
+func (db *DB) MigrateTo(version int) error {
+	if version < 0 || version > LatestVersion() {
+		return fmt.Errorf("unknown schema version %d: must be between 0 and %d", version, LatestVersion())
+	}
+	ctx := context.Background()
+
+	// A session lock belongs to one connection, so every statement runs on it
+	conn, err := db.conn.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection for migrations: %w", err)
+	}
+	defer conn.Close()
+
+	slog.Default().Info("waiting for migration lock")
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockKey); err != nil {
+		return fmt.Errorf("failed to take migration lock: %w", err)
+	}
+	defer conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", migrationLockKey)
+
 	slog.Default().Info("creating schema_version table")
 	// Ensure schema_version table exists
-	if _, err := db.conn.Exec(migrations[2].SQL); err != nil {
+	if _, err := conn.ExecContext(ctx, migrations[2].SQL); err != nil {
 		return fmt.Errorf("failed to create schema_version table: %w", err)
 	}
 
-	slog.Default().Info("checking current schema version")
-	// Get current version
-	var currentVersion int
-	err := db.conn.QueryRow("SELECT COALESCE(MAX(version), 0) FROM textanalyzer_schema_version").Scan(&currentVersion)
+	slog.Default().Info("checking applied schema versions")
+	applied := map[int]bool{}
+	rows, err := conn.QueryContext(ctx, "SELECT version FROM textanalyzer_schema_version")
 	if err != nil {
-		return fmt.Errorf("failed to get current version: %w", err)
+		return fmt.Errorf("failed to get applied versions: %w", err)
 	}
-	slog.Default().Info("current schema version", "version", currentVersion)
+	for rows.Next() {
+		var v int
+		if err := rows.Scan(&v); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan version: %w", err)
+		}
+		applied[v] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to get applied versions: %w", err)
+	}
+	slog.Default().Info("applied schema versions", "count", len(applied), "target", version)
 
-	// Run pending migrations
-	for _, migration := range migrations {
-		if migration.Version <= currentVersion {
-			slog.Default().Debug("skipping migration (already applied)", "version", migration.Version)
+	var revert []Migration
+	var irreversible []string
+	for i := len(migrations) - 1; i >= 0; i-- {
+		migration := migrations[i]
+		if migration.Version <= version || !applied[migration.Version] {
 			continue
 		}
+		revert = append(revert, migration)
+		if migration.DownSQL == "" {
+			irreversible = append(irreversible, fmt.Sprintf("%d (%s)", migration.Version, migration.Name))
+		}
+	}
+	if len(irreversible) > 0 {
+		return fmt.Errorf("cannot migrate to version %d: irreversible migrations %s", version, strings.Join(irreversible, ", "))
+	}
 
-		slog.Default().Info("applying migration", "version", migration.Version, "name", migration.Name)
-		tx, err := db.conn.Begin()
+	for _, migration := range revert {
+		slog.Default().Info("reverting migration", "version", migration.Version, "name", migration.Name)
+		err := migrationStep(ctx, conn, migration.DownSQL,
+			"DELETE FROM textanalyzer_schema_version WHERE version = $1", migration.Version)
 		if err != nil {
-			return fmt.Errorf("failed to begin transaction for migration %d: %w", migration.Version, err)
+			return fmt.Errorf("failed to revert migration %d (%s): %w", migration.Version, migration.Name, err)
 		}
+		slog.Default().Info("migration reverted successfully", "version", migration.Version, "name", migration.Name)
+	}
 
-		if _, err := tx.Exec(migration.SQL); err != nil {
-			tx.Rollback()
-			return fmt.Errorf("failed to run migration %d (%s): %w", migration.Version, migration.Name, err)
+	// Run pending migrations, including any skipped by an earlier run
+	for _, migration := range migrations {
+		if migration.Version > version {
+			break
 		}
-
-		// Use PostgreSQL $1 placeholder instead of ?
-		if _, err := tx.Exec("INSERT INTO textanalyzer_schema_version (version) VALUES ($1)", migration.Version); err != nil {
-			tx.Rollback()
-			return fmt.Errorf("failed to record migration %d: %w", migration.Version, err)
+		if applied[migration.Version] {
+			slog.Default().Debug("skipping migration (already applied)", "version", migration.Version)
+			continue
 		}
 
-		if err := tx.Commit(); err != nil {
-			return fmt.Errorf("failed to commit migration %d: %w", migration.Version, err)
+		slog.Default().Info("applying migration", "version", migration.Version, "name", migration.Name)
+		err := migrationStep(ctx, conn, migration.SQL,
+			"INSERT INTO textanalyzer_schema_version (version) VALUES ($1)", migration.Version)
+		if err != nil {
+			return fmt.Errorf("failed to run migration %d (%s): %w", migration.Version, migration.Name, err)
 		}
-
 		slog.Default().Info("migration applied successfully", "version", migration.Version, "name", migration.Name)
 	}
 
-	slog.Default().Info("all migrations complete")
+	slog.Default().Info("all migrations complete", "version", version)
+	return nil
+}
+
+// migrationStep runs the SQL of a migration and the statement recording
+// it, given the version, in one transaction, so a failed step leaves
+// neither its changes nor its record behind
+func migrationStep(ctx context.Context, conn *sql.Conn, migrationSQL, record string, version int) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, migrationSQL); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, record, version); err != nil {
+		return fmt.Errorf("failed to record version: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit: %w", err)
+	}
 	return nil
 }
@@ -1,97 +1,234 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"math"
+	"strings"
 	"time"
 
 	"github.com/docutag/textanalyzer/internal/models"
+	"github.com/docutag/textanalyzer/internal/tags"
+	"github.com/docutag/textanalyzer/internal/textutil"
+	"github.com/lib/pq"
 )
 
 // SaveAnalysis saves an analysis to the database
-func (db *DB) SaveAnalysis(analysis *models.Analysis) error {
+func (db *DB) SaveAnalysis(ctx context.Context, analysis *models.Analysis) error {
+	return db.saveAnalysis(ctx, analysis, nil)
+}
+
+// SaveAnalysisWithRevision saves an analysis together with the revision of
+// the AI-derived fields it replaces, setting revision.Revision. Both are
+// saved in one transaction, so a failed save leaves no revision behind.
+func (db *DB) SaveAnalysisWithRevision(ctx context.Context, analysis *models.Analysis, revision *models.AnalysisRevision) error {
+	return db.saveAnalysis(ctx, analysis, revision)
+}
+
+// saveAnalysis saves an analysis with its tags and references and, when
+// revision is not nil, the revision, in a single transaction
+func (db *DB) saveAnalysis(ctx context.Context, analysis *models.Analysis, revision *models.AnalysisRevision) error {
 	metadataJSON, err := json.Marshal(analysis.Metadata)
 	if err != nil {
 		return fmt.Errorf("failed to marshal metadata: %w", err)
 	}
 
-	tx, err := db.conn.Begin()
+	clientMetadataJSON, err := marshalClientMetadata(analysis.ClientMetadata)
+	if err != nil {
+		return err
+	}
+
+	conn, err := db.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	tx, err := conn.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
-	// Insert or replace analysis (use ON CONFLICT to handle updates during enrichment)
-	_, err = tx.Exec(`
-		INSERT INTO textanalyzer_analyses (id, text, metadata, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5)
+	var next int
+	if revision != nil {
+		if next, err = insertRevision(ctx, tx, revision); err != nil {
+			return err
+		}
+	}
+
+	if err := upsertAnalysis(ctx, tx, analysis, metadataJSON, clientMetadataJSON); err != nil {
+		return err
+	}
+
+	if err := replaceTagsAndReferences(ctx, tx, analysis); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	if revision != nil {
+		revision.Revision = next
+	}
+	return nil
+}
+
+// upsertAnalysis inserts an analysis or updates the stored one
+func upsertAnalysis(ctx context.Context, tx *sql.Tx, analysis *models.Analysis, metadataJSON []byte, clientMetadataJSON string) error {
+	// A new analysis claims its text hash. ON CONFLICT DO NOTHING skips the
+	// insert when the analysis exists or another analysis holds the hash,
+	// waiting for a concurrent claim to commit; either way the upsert below
+	// saves it without one.
+	if analysis.TextHash != "" {
+		res, err := tx.ExecContext(ctx, `
+			INSERT INTO textanalyzer_analyses (id, text, metadata, client_metadata, source_url, original_html, text_hash, created_at, updated_at, callback_url)
+			VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), $7, $8, $9, NULLIF($10, ''))
+			ON CONFLICT DO NOTHING
+		`, analysis.ID, storedText(analysis), metadataJSON, clientMetadataJSON, analysis.SourceURL, storedHTML(analysis),
+			analysis.TextHash, analysis.CreatedAt, analysis.UpdatedAt, analysis.CallbackURL)
+		if err != nil {
+			return fmt.Errorf("failed to insert analysis: %w", err)
+		}
+		if rows, err := res.RowsAffected(); err != nil {
+			return fmt.Errorf("failed to insert analysis: %w", err)
+		} else if rows == 1 {
+			return nil
+		}
+	}
+
+	// Insert or replace analysis (use ON CONFLICT to handle updates during enrichment).
+	// Analyses are usually loaded without their original HTML, so an empty
+	// OriginalHTML keeps the stored HTML unless the text is redacted, and an
+	// empty CallbackURL keeps the stored callback.
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO textanalyzer_analyses (id, text, metadata, client_metadata, source_url, original_html, created_at, updated_at, callback_url)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), $7, $8, NULLIF($10, ''))
 		ON CONFLICT (id) DO UPDATE SET
 			text = EXCLUDED.text,
 			metadata = EXCLUDED.metadata,
+			client_metadata = EXCLUDED.client_metadata,
+			source_url = EXCLUDED.source_url,
+			original_html = CASE WHEN $9 THEN NULL
+				ELSE COALESCE(EXCLUDED.original_html, textanalyzer_analyses.original_html) END,
+			callback_url = COALESCE(EXCLUDED.callback_url, textanalyzer_analyses.callback_url),
 			updated_at = EXCLUDED.updated_at
-	`, analysis.ID, analysis.Text, metadataJSON, analysis.CreatedAt, analysis.UpdatedAt)
+	`, analysis.ID, storedText(analysis), metadataJSON, clientMetadataJSON, analysis.SourceURL, storedHTML(analysis),
+		analysis.CreatedAt, analysis.UpdatedAt, analysis.Metadata.Redaction != nil, analysis.CallbackURL)
 	if err != nil {
 		return fmt.Errorf("failed to insert analysis: %w", err)
 	}
+	return nil
+}
 
+// replaceTagsAndReferences deletes the stored tags and references of an
+// analysis and inserts its current ones, each in a single statement however
+// many rows there are
+func replaceTagsAndReferences(ctx context.Context, tx *sql.Tx, analysis *models.Analysis) error {
 	// Delete existing tags and references for this analysis to avoid duplicates
-	_, err = tx.Exec(`DELETE FROM textanalyzer_tags WHERE analysis_id = $1`, analysis.ID)
-	if err != nil {
-		return fmt.Errorf("failed to delete existing tags: %w", err)
-	}
-
-	_, err = tx.Exec(`DELETE FROM textanalyzer_text_references WHERE analysis_id = $1`, analysis.ID)
+	_, err := tx.ExecContext(ctx, `
+		WITH deleted_tags AS (
+			DELETE FROM textanalyzer_tags WHERE analysis_id = $1
+		)
+		DELETE FROM textanalyzer_text_references WHERE analysis_id = $1
+	`, analysis.ID)
 	if err != nil {
-		return fmt.Errorf("failed to delete existing references: %w", err)
+		return fmt.Errorf("failed to delete existing tags and references: %w", err)
 	}
 
-	// Insert tags
-	for _, tag := range analysis.Metadata.Tags {
-		_, err = tx.Exec(`
+	if len(analysis.Metadata.Tags) > 0 {
+		// Tags equal once sanitized are stored once, keeping the first
+		tagValues := make([]string, 0, len(analysis.Metadata.Tags))
+		seen := make(map[string]bool, len(analysis.Metadata.Tags))
+		for _, tag := range analysis.Metadata.Tags {
+			tag = textutil.ValidUTF8(tag)
+			if seen[tag] {
+				continue
+			}
+			seen[tag] = true
+			tagValues = append(tagValues, tag)
+		}
+		_, err = tx.ExecContext(ctx, `
 			INSERT INTO textanalyzer_tags (analysis_id, tag)
-			VALUES ($1, $2)
-		`, analysis.ID, tag)
+			SELECT $1, tag FROM unnest($2::text[]) WITH ORDINALITY AS t(tag, n)
+			ORDER BY n
+		`, analysis.ID, pq.Array(tagValues))
 		if err != nil {
-			return fmt.Errorf("failed to insert tag: %w", err)
+			return fmt.Errorf("failed to insert tags: %w", err)
 		}
 	}
 
-	// Insert references
-	for _, ref := range analysis.Metadata.References {
-		_, err = tx.Exec(`
+	if refs := analysis.Metadata.References; len(refs) > 0 {
+		texts := make([]string, len(refs))
+		types := make([]string, len(refs))
+		contexts := make([]string, len(refs))
+		confidences := make([]string, len(refs))
+		for i, ref := range refs {
+			texts[i] = textutil.ValidUTF8(ref.Text)
+			types[i] = ref.Type
+			contexts[i] = textutil.ValidUTF8(ref.Context)
+			confidences[i] = ref.Confidence
+		}
+		_, err = tx.ExecContext(ctx, `
 			INSERT INTO textanalyzer_text_references (analysis_id, text, type, context, confidence)
-			VALUES ($1, $2, $3, $4, $5)
-		`, analysis.ID, ref.Text, ref.Type, ref.Context, ref.Confidence)
+			SELECT $1, text, type, context, confidence
+			FROM unnest($2::text[], $3::text[], $4::text[], $5::text[])
+				WITH ORDINALITY AS r(text, type, context, confidence, n)
+			ORDER BY n
+		`, analysis.ID, pq.Array(texts), pq.Array(types), pq.Array(contexts), pq.Array(confidences))
 		if err != nil {
-			return fmt.Errorf("failed to insert reference: %w", err)
+			return fmt.Errorf("failed to insert references: %w", err)
 		}
 	}
 
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
-	}
-
 	return nil
 }
 
-// GetAnalysis retrieves an analysis by ID
-func (db *DB) GetAnalysis(id string) (*models.Analysis, error) {
+// GetAnalysis retrieves an analysis by ID, without its original HTML
+func (db *DB) GetAnalysis(ctx context.Context, id string) (*models.Analysis, error) {
+	return db.getAnalysis(ctx, id, false)
+}
+
+// GetAnalysisWithHTML retrieves an analysis by ID with its original HTML,
+// which can be large
+func (db *DB) GetAnalysisWithHTML(ctx context.Context, id string) (*models.Analysis, error) {
+	return db.getAnalysis(ctx, id, true)
+}
+
+// getAnalysis retrieves an analysis by ID, reading the original HTML only
+// when includeHTML is set
+func (db *DB) getAnalysis(ctx context.Context, id string, includeHTML bool) (*models.Analysis, error) {
 	var (
-		text         string
-		metadataJSON string
-		createdAt    time.Time
-		updatedAt    time.Time
+		text               string
+		metadataJSON       string
+		clientMetadataJSON string
+		sourceURL          string
+		originalHTML       string
+		textHash           string
+		callbackURL        string
+		createdAt          time.Time
+		updatedAt          time.Time
 	)
 
-	err := db.conn.QueryRow(`
-		SELECT text, metadata, created_at, updated_at
+	conn, err := db.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	err = conn.QueryRowContext(ctx, `
+		SELECT text, metadata, client_metadata, COALESCE(source_url, ''),
+			CASE WHEN $2 THEN COALESCE(original_html, '') ELSE '' END, COALESCE(text_hash, ''),
+			COALESCE(callback_url, ''), created_at, updated_at
 		FROM textanalyzer_analyses
-		WHERE id = $1
-	`, id).Scan(&text, &metadataJSON, &createdAt, &updatedAt)
+		WHERE id = $1 AND deleted_at IS NULL
+	`, id, includeHTML).Scan(&text, &metadataJSON, &clientMetadataJSON, &sourceURL, &originalHTML, &textHash, &callbackURL, &createdAt, &updatedAt)
 
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("analysis not found")
+		return nil, ErrNotFound
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get analysis: %w", err)
@@ -102,24 +239,64 @@ func (db *DB) GetAnalysis(id string) (*models.Analysis, error) {
 		return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
 	}
 
+	clientMetadata, err := unmarshalClientMetadata(clientMetadataJSON)
+	if err != nil {
+		return nil, err
+	}
+
 	return &models.Analysis{
-		ID:        id,
-		Text:      text,
-		Metadata:  metadata,
-		CreatedAt: createdAt,
-		UpdatedAt: updatedAt,
+		ID:             id,
+		Text:           loadedText(text, metadata),
+		OriginalHTML:   originalHTML,
+		Metadata:       metadata,
+		ClientMetadata: clientMetadata,
+		SourceURL:      sourceURL,
+		TextHash:       textHash,
+		CallbackURL:    callbackURL,
+		CreatedAt:      createdAt,
+		UpdatedAt:      updatedAt,
 	}, nil
 }
 
-// GetAnalysesByTag retrieves all analyses with a specific tag
-func (db *DB) GetAnalysesByTag(tag string) ([]*models.Analysis, error) {
-	rows, err := db.conn.Query(`
-		SELECT DISTINCT a.id, a.text, a.metadata, a.created_at, a.updated_at
+// GetAnalysisByTextHash retrieves the analysis holding a text hash (see
+// TextHash), without its original HTML
+func (db *DB) GetAnalysisByTextHash(ctx context.Context, hash string) (*models.Analysis, error) {
+	conn, err := db.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// The connection is returned before getAnalysis takes another
+	var id string
+	err = conn.QueryRowContext(ctx, `
+		SELECT id FROM textanalyzer_analyses WHERE text_hash = $1 AND deleted_at IS NULL
+	`, hash).Scan(&id)
+	conn.Close()
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get analysis by text hash: %w", err)
+	}
+	return db.getAnalysis(ctx, id, false)
+}
+
+// GetAnalysesByTag retrieves all analyses with a specific tag. Their text and
+// cleaned texts are only loaded with includeText.
+func (db *DB) GetAnalysesByTag(ctx context.Context, tag string, includeText bool) ([]*models.Analysis, error) {
+	conn, err := db.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	rows, err := conn.QueryContext(ctx, `
+		SELECT DISTINCT a.id, `+textColumns("a.", 2)+`, a.client_metadata, COALESCE(a.source_url, ''), a.created_at, a.updated_at
 		FROM textanalyzer_analyses a
-		INNER JOIN tags t ON a.id = t.analysis_id
-		WHERE t.tag = $1
+		INNER JOIN textanalyzer_tags t ON a.id = t.analysis_id
+		WHERE t.tag = $1 AND a.deleted_at IS NULL
 		ORDER BY a.created_at DESC
-	`, tag)
+	`, tag, includeText)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query analyses by tag: %w", err)
 	}
@@ -128,14 +305,16 @@ func (db *DB) GetAnalysesByTag(tag string) ([]*models.Analysis, error) {
 	var analyses []*models.Analysis
 	for rows.Next() {
 		var (
-			id           string
-			text         string
-			metadataJSON string
-			createdAt    time.Time
-			updatedAt    time.Time
+			id                 string
+			text               string
+			metadataJSON       string
+			clientMetadataJSON string
+			sourceURL          string
+			createdAt          time.Time
+			updatedAt          time.Time
 		)
 
-		if err := rows.Scan(&id, &text, &metadataJSON, &createdAt, &updatedAt); err != nil {
+		if err := rows.Scan(&id, &text, &metadataJSON, &clientMetadataJSON, &sourceURL, &createdAt, &updatedAt); err != nil {
 			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
 
@@ -144,12 +323,19 @@ func (db *DB) GetAnalysesByTag(tag string) ([]*models.Analysis, error) {
 			return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
 		}
 
+		clientMetadata, err := unmarshalClientMetadata(clientMetadataJSON)
+		if err != nil {
+			return nil, err
+		}
+
 		analyses = append(analyses, &models.Analysis{
-			ID:        id,
-			Text:      text,
-			Metadata:  metadata,
-			CreatedAt: createdAt,
-			UpdatedAt: updatedAt,
+			ID:             id,
+			Text:           loadedText(text, metadata),
+			Metadata:       metadata,
+			ClientMetadata: clientMetadata,
+			SourceURL:      sourceURL,
+			CreatedAt:      createdAt,
+			UpdatedAt:      updatedAt,
 		})
 	}
 
@@ -160,14 +346,157 @@ func (db *DB) GetAnalysesByTag(tag string) ([]*models.Analysis, error) {
 	return analyses, nil
 }
 
+// Cursor is a position in the analyses listing: the creation time and ID of
+// the last analysis seen. The listing is ordered by both, so a position stays
+// valid while analyses are added or deleted.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+// ListFilter restricts the analyses returned by ListAnalysesFiltered and
+// selects what is loaded for them
+type ListFilter struct {
+	// ClientMetadata keeps analyses whose client metadata contains every pair
+	ClientMetadata map[string]string
+
+	// CreatedAfter and CreatedBefore keep analyses created at or after and
+	// strictly before the times that are set
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+
+	// Language keeps analyses whose metadata records this language
+	Language string
+
+	// Bias keeps analyses whose editorial analysis classified their slant
+	// as this bias
+	Bias string
+
+	// MinQuality and MaxQuality keep analyses whose quality score is within
+	// the bounds that are set; analyses without a score never match them
+	MinQuality *float64
+	MaxQuality *float64
+
+	// After keeps the analyses listed after the cursor. It replaces an
+	// offset, which makes the database read and discard every skipped row.
+	After *Cursor
+
+	// IncludeText loads each analysis's text and cleaned texts, which are
+	// left empty otherwise to keep listings small
+	IncludeText bool
+
+	// IncludeHTML loads each analysis's original HTML, which is left empty
+	// otherwise to keep large pages out of listings
+	IncludeHTML bool
+}
+
+// textColumns selects the text and metadata columns of the analyses table
+// prefixed by alias. Unless the boolean placeholder param is true, the text
+// is replaced by an empty string and the cleaned and translated texts are
+// dropped from the metadata, so their bytes are never sent by the database.
+func textColumns(alias string, param int) string {
+	return fmt.Sprintf(`CASE WHEN $%[2]d THEN %[1]stext ELSE '' END,
+		CASE WHEN $%[2]d THEN %[1]smetadata ELSE %[1]smetadata - '{cleaned_text,heuristic_cleaned_text,translated_text}'::text[] END`, alias, param)
+}
+
+// qualityScoreExpr is the quality score of an analysis, written exactly as
+// in the expression index that serves quality filters
+const qualityScoreExpr = `((metadata->'quality_score'->>'score')::float8)`
+
+// where returns the WHERE clause for the filter, numbering its placeholders
+// after args and returning args with their values appended
+func (f ListFilter) where(args []interface{}) (string, []interface{}, error) {
+	// An empty object is contained in every row, so the containment check
+	// is a no-op without a client metadata filter
+	containsJSON, err := marshalClientMetadata(f.ClientMetadata)
+	if err != nil {
+		return "", nil, err
+	}
+
+	conditions := []string{"deleted_at IS NULL"}
+	add := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	add("client_metadata @> $%d::jsonb", containsJSON)
+	if f.CreatedAfter != nil {
+		add("created_at >= $%d", *f.CreatedAfter)
+	}
+	if f.CreatedBefore != nil {
+		add("created_at < $%d", *f.CreatedBefore)
+	}
+	if f.Language != "" {
+		add("metadata->>'language' = $%d", f.Language)
+	}
+	if f.Bias != "" {
+		add("metadata->'editorial'->>'bias' = $%d", f.Bias)
+	}
+	if f.MinQuality != nil {
+		add(qualityScoreExpr+" >= $%d", *f.MinQuality)
+	}
+	if f.MaxQuality != nil {
+		add(qualityScoreExpr+" <= $%d", *f.MaxQuality)
+	}
+	if f.After != nil {
+		args = append(args, f.After.CreatedAt, f.After.ID)
+		conditions = append(conditions, fmt.Sprintf("(created_at, id) < ($%d, $%d)", len(args)-1, len(args)))
+	}
+	return "WHERE " + strings.Join(conditions, " AND "), args, nil
+}
+
+// CountAnalyses returns the number of stored analyses
+func (db *DB) CountAnalyses(ctx context.Context) (int, error) {
+	return db.CountAnalysesFiltered(ctx, ListFilter{})
+}
+
+// CountAnalysesFiltered returns the number of analyses matching filter
+func (db *DB) CountAnalysesFiltered(ctx context.Context, filter ListFilter) (int, error) {
+	where, args, err := filter.where(nil)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	conn, err := db.acquire(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	err = conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM textanalyzer_analyses "+where, args...).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count analyses: %w", err)
+	}
+	return count, nil
+}
+
 // ListAnalyses retrieves all analyses with pagination
-func (db *DB) ListAnalyses(limit, offset int) ([]*models.Analysis, error) {
-	rows, err := db.conn.Query(`
-		SELECT id, text, metadata, created_at, updated_at
+func (db *DB) ListAnalyses(ctx context.Context, limit, offset int) ([]*models.Analysis, error) {
+	return db.ListAnalysesFiltered(ctx, limit, offset, ListFilter{})
+}
+
+// ListAnalysesFiltered retrieves the analyses matching filter with pagination,
+// newest first, ordered by ID among analyses created at the same time
+func (db *DB) ListAnalysesFiltered(ctx context.Context, limit, offset int, filter ListFilter) ([]*models.Analysis, error) {
+	where, args, err := filter.where([]interface{}{limit, offset, filter.IncludeHTML, filter.IncludeText})
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := db.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	rows, err := conn.QueryContext(ctx, `
+		SELECT id, `+textColumns("", 4)+`, client_metadata, COALESCE(source_url, ''),
+			CASE WHEN $3 THEN COALESCE(original_html, '') ELSE '' END, created_at, updated_at
 		FROM textanalyzer_analyses
-		ORDER BY created_at DESC
+		`+where+`
+		ORDER BY created_at DESC, id DESC
 		LIMIT $1 OFFSET $2
-	`, limit, offset)
+	`, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query analyses: %w", err)
 	}
@@ -176,14 +505,17 @@ func (db *DB) ListAnalyses(limit, offset int) ([]*models.Analysis, error) {
 	var analyses []*models.Analysis
 	for rows.Next() {
 		var (
-			id           string
-			text         string
-			metadataJSON string
-			createdAt    time.Time
-			updatedAt    time.Time
+			id                 string
+			text               string
+			metadataJSON       string
+			clientMetadataJSON string
+			sourceURL          string
+			originalHTML       string
+			createdAt          time.Time
+			updatedAt          time.Time
 		)
 
-		if err := rows.Scan(&id, &text, &metadataJSON, &createdAt, &updatedAt); err != nil {
+		if err := rows.Scan(&id, &text, &metadataJSON, &clientMetadataJSON, &sourceURL, &originalHTML, &createdAt, &updatedAt); err != nil {
 			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
 
@@ -192,12 +524,20 @@ func (db *DB) ListAnalyses(limit, offset int) ([]*models.Analysis, error) {
 			return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
 		}
 
+		clientMetadata, err := unmarshalClientMetadata(clientMetadataJSON)
+		if err != nil {
+			return nil, err
+		}
+
 		analyses = append(analyses, &models.Analysis{
-			ID:        id,
-			Text:      text,
-			Metadata:  metadata,
-			CreatedAt: createdAt,
-			UpdatedAt: updatedAt,
+			ID:             id,
+			Text:           loadedText(text, metadata),
+			OriginalHTML:   originalHTML,
+			Metadata:       metadata,
+			ClientMetadata: clientMetadata,
+			SourceURL:      sourceURL,
+			CreatedAt:      createdAt,
+			UpdatedAt:      updatedAt,
 		})
 	}
 
@@ -208,9 +548,185 @@ func (db *DB) ListAnalyses(limit, offset int) ([]*models.Analysis, error) {
 	return analyses, nil
 }
 
-// DeleteAnalysis deletes an analysis by ID
-func (db *DB) DeleteAnalysis(id string) error {
-	result, err := db.conn.Exec("DELETE FROM textanalyzer_analyses WHERE id = $1", id)
+// searchableChars is how many characters of the text and of the cleaned text
+// are indexed for full-text search, as in the add_text_search migration
+const searchableChars = 100000
+
+// headlineOptions configure the excerpts returned with search matches
+const headlineOptions = "StartSel=<mark>, StopSel=</mark>, MaxWords=35, MinWords=15, MaxFragments=2"
+
+// SearchAnalyses finds the analyses whose text or cleaned text matches a
+// plain-text query, most relevant first, with an excerpt of each match.
+// Their text and cleaned texts are only loaded with includeText.
+func (db *DB) SearchAnalyses(ctx context.Context, query string, limit, offset int, includeText bool) ([]*models.SearchMatch, error) {
+	// Excerpts are only built for the page of matches, as ts_headline
+	// reparses each document
+	conn, err := db.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	rows, err := conn.QueryContext(ctx, `
+		SELECT id, `+textColumns("", 6)+`, client_metadata, source_url, created_at, updated_at, rank,
+			ts_headline('english',
+				CASE WHEN to_tsvector('english', left(text, $4)) @@ query THEN left(text, $4)
+				ELSE left(COALESCE(metadata->>'cleaned_text', ''), $4) END,
+				query, $5)
+		FROM (
+			SELECT a.id, a.text, a.metadata, a.client_metadata, COALESCE(a.source_url, '') AS source_url,
+				a.created_at, a.updated_at, q.query, ts_rank(a.search_vector, q.query) AS rank
+			FROM textanalyzer_analyses a, plainto_tsquery('english', $1) AS q(query)
+			WHERE a.search_vector @@ q.query AND a.deleted_at IS NULL
+			ORDER BY rank DESC, a.created_at DESC
+			LIMIT $2 OFFSET $3
+		) matches
+		ORDER BY rank DESC, created_at DESC
+	`, query, limit, offset, searchableChars, headlineOptions, includeText)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search analyses: %w", err)
+	}
+	defer rows.Close()
+
+	var matches []*models.SearchMatch
+	for rows.Next() {
+		var (
+			match              models.SearchMatch
+			id                 string
+			text               string
+			metadataJSON       string
+			clientMetadataJSON string
+			sourceURL          string
+			createdAt          time.Time
+			updatedAt          time.Time
+		)
+
+		if err := rows.Scan(&id, &text, &metadataJSON, &clientMetadataJSON, &sourceURL, &createdAt, &updatedAt, &match.Rank, &match.Headline); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		var metadata models.Metadata
+		if err := json.Unmarshal([]byte(metadataJSON), &metadata); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+		}
+
+		clientMetadata, err := unmarshalClientMetadata(clientMetadataJSON)
+		if err != nil {
+			return nil, err
+		}
+
+		match.Analysis = &models.Analysis{
+			ID:             id,
+			Text:           loadedText(text, metadata),
+			Metadata:       metadata,
+			ClientMetadata: clientMetadata,
+			SourceURL:      sourceURL,
+			CreatedAt:      createdAt,
+			UpdatedAt:      updatedAt,
+		}
+		matches = append(matches, &match)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return matches, nil
+}
+
+// CountSearchAnalyses returns the number of analyses matching a plain-text query
+func (db *DB) CountSearchAnalyses(ctx context.Context, query string) (int, error) {
+	var count int
+	conn, err := db.acquire(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	err = conn.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM textanalyzer_analyses
+		WHERE search_vector @@ plainto_tsquery('english', $1) AND deleted_at IS NULL
+	`, query).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count search matches: %w", err)
+	}
+	return count, nil
+}
+
+// DeleteAnalysis soft-deletes an analysis by ID: it is hidden from every
+// read until RestoreAnalysis, keeping its tags and references. Its text hash
+// no longer counts, so the same text submitted again is analyzed anew.
+func (db *DB) DeleteAnalysis(ctx context.Context, id string) error {
+	conn, err := db.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	result, err := conn.ExecContext(ctx, `
+		UPDATE textanalyzer_analyses SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL
+	`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete analysis: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// RestoreAnalysis makes a soft-deleted analysis visible again. Restoring an
+// analysis that is not deleted does nothing. When its text was analyzed again
+// while it was deleted, the new analysis keeps the text hash and the restored
+// one is not matched by GetAnalysisByTextHash.
+func (db *DB) RestoreAnalysis(ctx context.Context, id string) error {
+	conn, err := db.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	result, err := conn.ExecContext(ctx, `
+		UPDATE textanalyzer_analyses a SET deleted_at = NULL,
+			text_hash = CASE WHEN EXISTS (
+				SELECT 1 FROM textanalyzer_analyses live
+				WHERE live.text_hash = a.text_hash AND live.id <> a.id AND live.deleted_at IS NULL
+			) THEN NULL ELSE a.text_hash END
+		WHERE a.id = $1
+	`, id)
+	if err != nil {
+		return fmt.Errorf("failed to restore analysis: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// HardDeleteAnalysis permanently deletes an analysis by ID, soft-deleted or
+// not, with its tags, references, images, revisions and history
+func (db *DB) HardDeleteAnalysis(ctx context.Context, id string) error {
+	conn, err := db.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	result, err := conn.ExecContext(ctx, "DELETE FROM textanalyzer_analyses WHERE id = $1", id)
 	if err != nil {
 		return fmt.Errorf("failed to delete analysis: %w", err)
 	}
@@ -221,37 +737,150 @@ func (db *DB) DeleteAnalysis(id string) error {
 	}
 
 	if rows == 0 {
-		return fmt.Errorf("analysis not found")
+		return ErrNotFound
 	}
 
 	return nil
 }
 
-// GetAnalysesByReference retrieves all analyses containing a specific reference text
-func (db *DB) GetAnalysesByReference(referenceText string) ([]*models.Analysis, error) {
-	rows, err := db.conn.Query(`
-		SELECT DISTINCT a.id, a.text, a.metadata, a.created_at, a.updated_at
+// GetAnalysesByReference retrieves a page of the analyses containing a
+// reference text, newest first; with refType, only references of that type
+// match. Their text and cleaned texts are only loaded with includeText.
+func (db *DB) GetAnalysesByReference(ctx context.Context, referenceText, refType string, limit, offset int, includeText bool) ([]*models.Analysis, error) {
+	conn, err := db.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	rows, err := conn.QueryContext(ctx, `
+		SELECT a.id, `+textColumns("a.", 5)+`, a.client_metadata, COALESCE(a.source_url, ''), a.created_at, a.updated_at
 		FROM textanalyzer_analyses a
-		INNER JOIN text_references r ON a.id = r.analysis_id
-		WHERE r.text LIKE $1
-		ORDER BY a.created_at DESC
-	`, "%"+referenceText+"%")
+		WHERE `+referenceCondition+`
+		ORDER BY a.created_at DESC, a.id
+		LIMIT $3 OFFSET $4
+	`, referencePattern(referenceText), refType, limit, offset, includeText)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query analyses by reference: %w", err)
 	}
 	defer rows.Close()
 
-	var analyses []*models.Analysis
+	return scanAnalyses(rows)
+}
+
+// CountAnalysesByReference returns the number of analyses matched by
+// GetAnalysesByReference
+func (db *DB) CountAnalysesByReference(ctx context.Context, referenceText, refType string) (int, error) {
+	var count int
+	conn, err := db.acquire(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	err = conn.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM textanalyzer_analyses a WHERE `+referenceCondition,
+		referencePattern(referenceText), refType).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count analyses by reference: %w", err)
+	}
+	return count, nil
+}
+
+// referenceCondition matches visible analyses alias a with a reference whose
+// text matches the LIKE pattern $1 and, unless $2 is empty, whose type is $2
+const referenceCondition = `a.deleted_at IS NULL AND EXISTS (
+			SELECT 1 FROM textanalyzer_text_references r
+			WHERE r.analysis_id = a.id AND r.text LIKE $1 AND ($2::text = '' OR r.type = $2)
+		)`
+
+// referencePattern is the LIKE pattern of references containing text
+func referencePattern(text string) string {
+	return "%" + likeEscaper.Replace(text) + "%"
+}
+
+// GetAnalysesByEntity retrieves a page of the analyses whose named entities
+// include name exactly, newest first. Unless entityType is empty, the entity
+// must also have been classified as entityType. Their text and cleaned texts
+// are only loaded with includeText.
+func (db *DB) GetAnalysesByEntity(ctx context.Context, name, entityType string, limit, offset int, includeText bool) ([]*models.Analysis, error) {
+	conn, err := db.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	rows, err := conn.QueryContext(ctx, `
+		SELECT id, `+textColumns("", 4)+`, client_metadata, COALESCE(source_url, ''), created_at, updated_at
+		FROM textanalyzer_analyses
+		WHERE `+entityCondition(entityType)+`
+		ORDER BY created_at DESC, id
+		LIMIT $2 OFFSET $3
+	`, entityJSON(name, entityType), limit, offset, includeText)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query analyses by entity: %w", err)
+	}
+	defer rows.Close()
+
+	return scanAnalyses(rows)
+}
+
+// CountAnalysesByEntity returns the number of analyses matched by
+// GetAnalysesByEntity
+func (db *DB) CountAnalysesByEntity(ctx context.Context, name, entityType string) (int, error) {
+	var count int
+	conn, err := db.acquire(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	err = conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM textanalyzer_analyses WHERE `+entityCondition(entityType), entityJSON(name, entityType)).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count analyses by entity: %w", err)
+	}
+	return count, nil
+}
+
+// entityCondition matches visible analyses whose named entities contain the
+// JSON array $1 of entityJSON, written exactly as in the indexes that serve
+// it. With an entity type, the classified entities are searched; analyses
+// saved before entities were classified only have the plain list of names.
+func entityCondition(entityType string) string {
+	if entityType != "" {
+		return `metadata->'entities' @> $1::jsonb AND deleted_at IS NULL`
+	}
+	return `metadata->'named_entities' @> $1::jsonb AND deleted_at IS NULL`
+}
+
+// entityJSON is the JSON array containing only name, or with an entity type
+// only the entity of that name and type
+func entityJSON(name, entityType string) string {
+	var data []byte
+	if entityType != "" {
+		data, _ = json.Marshal([]map[string]string{{"text": name, "type": entityType}})
+	} else {
+		data, _ = json.Marshal([]string{name})
+	}
+	return string(data)
+}
+
+// scanAnalyses reads rows of id, text, metadata, client metadata, source
+// URL, created_at and updated_at into analyses
+func scanAnalyses(rows *sql.Rows) ([]*models.Analysis, error) {
+	analyses := []*models.Analysis{}
 	for rows.Next() {
 		var (
-			id           string
-			text         string
-			metadataJSON string
-			createdAt    time.Time
-			updatedAt    time.Time
+			id                 string
+			text               string
+			metadataJSON       string
+			clientMetadataJSON string
+			sourceURL          string
+			createdAt          time.Time
+			updatedAt          time.Time
 		)
 
-		if err := rows.Scan(&id, &text, &metadataJSON, &createdAt, &updatedAt); err != nil {
+		if err := rows.Scan(&id, &text, &metadataJSON, &clientMetadataJSON, &sourceURL, &createdAt, &updatedAt); err != nil {
 			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
 
@@ -260,12 +889,19 @@ func (db *DB) GetAnalysesByReference(referenceText string) ([]*models.Analysis,
 			return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
 		}
 
+		clientMetadata, err := unmarshalClientMetadata(clientMetadataJSON)
+		if err != nil {
+			return nil, err
+		}
+
 		analyses = append(analyses, &models.Analysis{
-			ID:        id,
-			Text:      text,
-			Metadata:  metadata,
-			CreatedAt: createdAt,
-			UpdatedAt: updatedAt,
+			ID:             id,
+			Text:           loadedText(text, metadata),
+			Metadata:       metadata,
+			ClientMetadata: clientMetadata,
+			SourceURL:      sourceURL,
+			CreatedAt:      createdAt,
+			UpdatedAt:      updatedAt,
 		})
 	}
 
@@ -276,12 +912,1408 @@ func (db *DB) GetAnalysesByReference(referenceText string) ([]*models.Analysis,
 	return analyses, nil
 }
 
-// GetAnalysisByUUID retrieves an analysis by UUID (alias for GetAnalysis)
-func (db *DB) GetAnalysisByUUID(uuid string) (*models.Analysis, error) {
-	return db.GetAnalysis(uuid)
+// GetAnalysesBySourceURL retrieves the latest limit analyses of a page,
+// oldest first, without their text or cleaned texts. sourceURL must already
+// be normalized.
+func (db *DB) GetAnalysesBySourceURL(ctx context.Context, sourceURL string, limit int) ([]*models.Analysis, error) {
+	conn, err := db.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	rows, err := conn.QueryContext(ctx, `
+		SELECT * FROM (
+			SELECT id, `+textColumns("", 3)+`, client_metadata, COALESCE(source_url, ''), created_at, updated_at
+			FROM textanalyzer_analyses
+			WHERE source_url = $1 AND deleted_at IS NULL
+			ORDER BY created_at DESC, id DESC
+			LIMIT $2
+		) latest
+		ORDER BY created_at ASC, id ASC
+	`, sourceURL, limit, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query analyses by source URL: %w", err)
+	}
+	defer rows.Close()
+
+	return scanAnalyses(rows)
 }
 
-// DeleteAnalysisByUUID deletes an analysis by UUID (alias for DeleteAnalysis)
-func (db *DB) DeleteAnalysisByUUID(uuid string) error {
-	return db.DeleteAnalysis(uuid)
+// GetPreviousAnalysisBySourceURL retrieves the ID and text hash (see
+// TextHash) of the latest analysis of a page before the analysis with id,
+// which is placed at before unless it is already stored. The hash is
+// computed from the stored text when the analysis does not hold it, and is
+// empty for a redacted analysis without one. Returns ErrNotFound when the
+// page has no earlier analysis. sourceURL must already be normalized.
+func (db *DB) GetPreviousAnalysisBySourceURL(ctx context.Context, sourceURL, id string, before time.Time) (*models.Analysis, error) {
+	conn, err := db.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var previous models.Analysis
+	err = conn.QueryRowContext(ctx, `
+		SELECT id, COALESCE(text_hash, CASE WHEN metadata->'redaction' IS NULL THEN `+textHashExpr+` END, '')
+		FROM textanalyzer_analyses
+		WHERE source_url = $1 AND deleted_at IS NULL
+			AND (created_at, id) < (COALESCE((SELECT created_at FROM textanalyzer_analyses WHERE id = $2), $3), $2)
+		ORDER BY created_at DESC, id DESC
+		LIMIT 1
+	`, sourceURL, id, before).Scan(&previous.ID, &previous.TextHash)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get previous analysis by source URL: %w", err)
+	}
+	return &previous, nil
+}
+
+// marshalClientMetadata encodes client metadata for a JSONB column, storing
+// nil as an empty object
+func marshalClientMetadata(clientMetadata map[string]string) (string, error) {
+	if clientMetadata == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(clientMetadata)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal client metadata: %w", err)
+	}
+	return string(data), nil
+}
+
+// unmarshalClientMetadata decodes a client metadata column, returning nil
+// when it is empty
+func unmarshalClientMetadata(data string) (map[string]string, error) {
+	var clientMetadata map[string]string
+	if err := json.Unmarshal([]byte(data), &clientMetadata); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal client metadata: %w", err)
+	}
+	if len(clientMetadata) == 0 {
+		return nil, nil
+	}
+	return clientMetadata, nil
+}
+
+// storedText returns the value of the text column for an analysis: its text,
+// or a placeholder with the text's hash when the text is redacted
+func storedText(analysis *models.Analysis) string {
+	if redaction := analysis.Metadata.Redaction; redaction != nil {
+		return "[redacted sha256:" + redaction.TextSHA256 + "]"
+	}
+	return textutil.ValidUTF8(analysis.Text)
+}
+
+// storedHTML returns the original HTML to store for an analysis, which is
+// dropped along with redacted text
+func storedHTML(analysis *models.Analysis) string {
+	if analysis.Metadata.Redaction != nil {
+		return ""
+	}
+	return textutil.ValidUTF8(analysis.OriginalHTML)
+}
+
+// loadedText returns the text of an analysis read from the text column, which
+// only holds a placeholder for redacted analyses
+func loadedText(text string, metadata models.Metadata) string {
+	if metadata.Redaction != nil {
+		return ""
+	}
+	return text
+}
+
+// GetAnalysisByUUID retrieves an analysis by UUID (alias for GetAnalysis)
+func (db *DB) GetAnalysisByUUID(ctx context.Context, uuid string) (*models.Analysis, error) {
+	return db.GetAnalysis(ctx, uuid)
+}
+
+// DeleteAnalysisByUUID soft-deletes an analysis by UUID (alias for DeleteAnalysis)
+func (db *DB) DeleteAnalysisByUUID(ctx context.Context, uuid string) error {
+	return db.DeleteAnalysis(ctx, uuid)
+}
+
+// SaveImageMetadata inserts or updates the metadata for one image of an
+// analysis. An empty AI caption or tag list keeps the stored one.
+func (db *DB) SaveImageMetadata(ctx context.Context, image *models.ImageMetadata) error {
+	var aiTagsJSON []byte
+	if len(image.AITags) > 0 {
+		var err error
+		if aiTagsJSON, err = json.Marshal(image.AITags); err != nil {
+			return fmt.Errorf("failed to marshal image tags: %w", err)
+		}
+	}
+
+	conn, err := db.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, `
+		INSERT INTO textanalyzer_analysis_images (
+			analysis_id, image_index, url, domain, format, content_type, content_length,
+			width, height, status_code, fetched, oversized, is_tracking_pixel, error, ai_caption, ai_tags, updated_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, NULLIF($15, ''), $16, NOW())
+		ON CONFLICT (analysis_id, image_index) DO UPDATE SET
+			url = EXCLUDED.url,
+			domain = EXCLUDED.domain,
+			format = EXCLUDED.format,
+			content_type = EXCLUDED.content_type,
+			content_length = EXCLUDED.content_length,
+			width = EXCLUDED.width,
+			height = EXCLUDED.height,
+			status_code = EXCLUDED.status_code,
+			fetched = EXCLUDED.fetched,
+			oversized = EXCLUDED.oversized,
+			is_tracking_pixel = EXCLUDED.is_tracking_pixel,
+			error = EXCLUDED.error,
+			ai_caption = COALESCE(EXCLUDED.ai_caption, textanalyzer_analysis_images.ai_caption),
+			ai_tags = COALESCE(EXCLUDED.ai_tags, textanalyzer_analysis_images.ai_tags),
+			updated_at = EXCLUDED.updated_at
+	`, image.AnalysisID, image.ImageIndex, image.URL, image.Domain, image.Format, image.ContentType,
+		image.ContentLength, image.Width, image.Height, image.StatusCode, image.Fetched, image.Oversized,
+		image.IsTrackingPixel, image.Error, image.AICaption, aiTagsJSON)
+	if err != nil {
+		return fmt.Errorf("failed to save image metadata: %w", err)
+	}
+	return nil
+}
+
+// AddAnalysisTags merges tags into the stored tags of an analysis, after the
+// ones it has, dropping duplicates. The analysis is locked while merging, so
+// tags added concurrently are not lost.
+func (db *DB) AddAnalysisTags(ctx context.Context, id string, newTags []string) error {
+	conn, err := db.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var tagsJSON string
+	err = tx.QueryRowContext(ctx, `
+		SELECT COALESCE(metadata->'tags', 'null') FROM textanalyzer_analyses
+		WHERE id = $1 AND deleted_at IS NULL
+		FOR UPDATE
+	`, id).Scan(&tagsJSON)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock analysis: %w", err)
+	}
+
+	var existing []string
+	if err := json.Unmarshal([]byte(tagsJSON), &existing); err != nil {
+		return fmt.Errorf("failed to unmarshal tags: %w", err)
+	}
+	merged := tags.MergeWithLimit(0, existing, newTags)
+	mergedJSON, err := json.Marshal(merged)
+	if err != nil {
+		return fmt.Errorf("failed to marshal tags: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE textanalyzer_analyses
+		SET metadata = jsonb_set(metadata, '{tags}', $2::jsonb), updated_at = NOW()
+		WHERE id = $1
+	`, id, mergedJSON)
+	if err != nil {
+		return fmt.Errorf("failed to update tags: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO textanalyzer_tags (analysis_id, tag)
+		SELECT $1, tag FROM unnest($2::text[]) WITH ORDINALITY AS t(tag, n)
+		ORDER BY n
+		ON CONFLICT (analysis_id, tag) DO NOTHING
+	`, id, pq.Array(merged))
+	if err != nil {
+		return fmt.Errorf("failed to insert tags: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// GetAnalysisImages retrieves the image metadata recorded for an analysis, ordered by image index
+func (db *DB) GetAnalysisImages(ctx context.Context, analysisID string) ([]*models.ImageMetadata, error) {
+	conn, err := db.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	rows, err := conn.QueryContext(ctx, `
+		SELECT image_index, url, COALESCE(domain, ''), format, COALESCE(content_type, ''), content_length,
+			width, height, status_code, fetched, oversized, is_tracking_pixel, COALESCE(error, ''),
+			COALESCE(ai_caption, ''), COALESCE(ai_tags, 'null'), created_at, updated_at
+		FROM textanalyzer_analysis_images
+		WHERE analysis_id = $1
+		ORDER BY image_index
+	`, analysisID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query analysis images: %w", err)
+	}
+	defer rows.Close()
+
+	var images []*models.ImageMetadata
+	for rows.Next() {
+		image := &models.ImageMetadata{AnalysisID: analysisID}
+		var aiTagsJSON string
+		if err := rows.Scan(&image.ImageIndex, &image.URL, &image.Domain, &image.Format, &image.ContentType,
+			&image.ContentLength, &image.Width, &image.Height, &image.StatusCode, &image.Fetched,
+			&image.Oversized, &image.IsTrackingPixel, &image.Error, &image.AICaption, &aiTagsJSON,
+			&image.CreatedAt, &image.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		if err := json.Unmarshal([]byte(aiTagsJSON), &image.AITags); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal image tags: %w", err)
+		}
+		images = append(images, image)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return images, nil
+}
+
+// SaveWorkerHeartbeat records the latest heartbeat for a worker
+func (db *DB) SaveWorkerHeartbeat(ctx context.Context, heartbeat *models.WorkerHeartbeat) error {
+	conn, err := db.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, `
+		INSERT INTO textanalyzer_worker_heartbeats (
+			worker_id, heartbeat_at, started_at, active_tasks, processed_tasks, failed_tasks, last_task_completed_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (worker_id) DO UPDATE SET
+			heartbeat_at = EXCLUDED.heartbeat_at,
+			started_at = EXCLUDED.started_at,
+			active_tasks = EXCLUDED.active_tasks,
+			processed_tasks = EXCLUDED.processed_tasks,
+			failed_tasks = EXCLUDED.failed_tasks,
+			last_task_completed_at = EXCLUDED.last_task_completed_at
+	`, heartbeat.WorkerID, heartbeat.HeartbeatAt, heartbeat.StartedAt, heartbeat.ActiveTasks,
+		heartbeat.ProcessedTasks, heartbeat.FailedTasks, heartbeat.LastTaskCompletedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save worker heartbeat: %w", err)
+	}
+	return nil
+}
+
+// ListWorkerHeartbeats retrieves the latest heartbeat of every worker, most recent first
+func (db *DB) ListWorkerHeartbeats(ctx context.Context) ([]*models.WorkerHeartbeat, error) {
+	conn, err := db.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	rows, err := conn.QueryContext(ctx, `
+		SELECT worker_id, heartbeat_at, started_at, active_tasks, processed_tasks, failed_tasks, last_task_completed_at
+		FROM textanalyzer_worker_heartbeats
+		ORDER BY heartbeat_at DESC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query worker heartbeats: %w", err)
+	}
+	defer rows.Close()
+
+	heartbeats := []*models.WorkerHeartbeat{}
+	for rows.Next() {
+		var (
+			heartbeat     models.WorkerHeartbeat
+			lastCompleted sql.NullTime
+		)
+		if err := rows.Scan(&heartbeat.WorkerID, &heartbeat.HeartbeatAt, &heartbeat.StartedAt, &heartbeat.ActiveTasks,
+			&heartbeat.ProcessedTasks, &heartbeat.FailedTasks, &lastCompleted); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		if lastCompleted.Valid {
+			heartbeat.LastTaskCompletedAt = &lastCompleted.Time
+		}
+		heartbeats = append(heartbeats, &heartbeat)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return heartbeats, nil
+}
+
+// SaveWorkerSettings stores the runtime override of the queue worker
+// settings, replacing any previous override
+func (db *DB) SaveWorkerSettings(ctx context.Context, settings *models.WorkerSettings) error {
+	settingsJSON, err := json.Marshal(settings)
+	if err != nil {
+		return fmt.Errorf("failed to marshal worker settings: %w", err)
+	}
+
+	conn, err := db.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, `
+		INSERT INTO textanalyzer_worker_settings (id, settings, updated_at)
+		VALUES (TRUE, $1, NOW())
+		ON CONFLICT (id) DO UPDATE SET
+			settings = EXCLUDED.settings,
+			updated_at = EXCLUDED.updated_at
+	`, settingsJSON)
+	if err != nil {
+		return fmt.Errorf("failed to save worker settings: %w", err)
+	}
+	return nil
+}
+
+// GetWorkerSettings retrieves the stored override of the queue worker
+// settings, or nil when none has been saved
+func (db *DB) GetWorkerSettings(ctx context.Context) (*models.WorkerSettings, error) {
+	var settingsJSON []byte
+	conn, err := db.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	err = conn.QueryRowContext(ctx, `SELECT settings FROM textanalyzer_worker_settings WHERE id`).Scan(&settingsJSON)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query worker settings: %w", err)
+	}
+
+	var settings models.WorkerSettings
+	if err := json.Unmarshal(settingsJSON, &settings); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal worker settings: %w", err)
+	}
+	return &settings, nil
+}
+
+// maxRevisionsPerAnalysis caps the revisions kept for an analysis; older ones are pruned
+const maxRevisionsPerAnalysis = 20
+
+// SaveAnalysisRevision stores a snapshot of an analysis's AI-derived fields as
+// its next revision, setting revision.Revision, and prunes revisions beyond
+// maxRevisionsPerAnalysis
+func (db *DB) SaveAnalysisRevision(ctx context.Context, revision *models.AnalysisRevision) error {
+	conn, err := db.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	next, err := insertRevision(ctx, tx, revision)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	revision.Revision = next
+	return nil
+}
+
+// insertRevision inserts a revision as the next one of its analysis, pruning
+// revisions beyond maxRevisionsPerAnalysis, and returns its number
+func insertRevision(ctx context.Context, tx *sql.Tx, revision *models.AnalysisRevision) (int, error) {
+	fieldsJSON, err := json.Marshal(revision.Fields)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal revision fields: %w", err)
+	}
+
+	// Lock the analysis so concurrent enrichments get distinct revision numbers
+	var id string
+	err = tx.QueryRowContext(ctx, `SELECT id FROM textanalyzer_analyses WHERE id = $1 FOR UPDATE`, revision.AnalysisID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to lock analysis: %w", err)
+	}
+
+	var next int
+	if err := tx.QueryRowContext(ctx, `
+		SELECT COALESCE(MAX(revision), 0) + 1
+		FROM textanalyzer_analysis_revisions
+		WHERE analysis_id = $1
+	`, revision.AnalysisID).Scan(&next); err != nil {
+		return 0, fmt.Errorf("failed to get next revision: %w", err)
+	}
+
+	if revision.CreatedAt.IsZero() {
+		revision.CreatedAt = time.Now()
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO textanalyzer_analysis_revisions (analysis_id, revision, fields, model, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, revision.AnalysisID, next, fieldsJSON, revision.Model, revision.CreatedAt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert revision: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM textanalyzer_analysis_revisions
+		WHERE analysis_id = $1 AND revision <= $2
+	`, revision.AnalysisID, next-maxRevisionsPerAnalysis)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune revisions: %w", err)
+	}
+
+	return next, nil
+}
+
+// ListAnalysisRevisions retrieves the stored revisions of an analysis, oldest first
+func (db *DB) ListAnalysisRevisions(ctx context.Context, analysisID string) ([]*models.AnalysisRevision, error) {
+	conn, err := db.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	rows, err := conn.QueryContext(ctx, `
+		SELECT revision, fields, COALESCE(model, ''), created_at
+		FROM textanalyzer_analysis_revisions
+		WHERE analysis_id = $1
+		ORDER BY revision
+	`, analysisID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query revisions: %w", err)
+	}
+	defer rows.Close()
+
+	revisions := []*models.AnalysisRevision{}
+	for rows.Next() {
+		revision := &models.AnalysisRevision{AnalysisID: analysisID}
+		var fieldsJSON string
+		if err := rows.Scan(&revision.Revision, &fieldsJSON, &revision.Model, &revision.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		if err := json.Unmarshal([]byte(fieldsJSON), &revision.Fields); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal revision fields: %w", err)
+		}
+		revisions = append(revisions, revision)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return revisions, nil
+}
+
+// maxHistoryPerAnalysis caps the history entries kept for an analysis; older ones are pruned
+const maxHistoryPerAnalysis = 50
+
+// SaveAnalysisHistory records entry in the history of its analysis, setting
+// entry.ID, and prunes entries beyond maxHistoryPerAnalysis. The cleaned and
+// translated texts are dropped from the metadata snapshot.
+func (db *DB) SaveAnalysisHistory(ctx context.Context, entry *models.AnalysisHistoryEntry) error {
+	entry.Metadata.CleanedText = ""
+	entry.Metadata.HeuristicCleanedText = ""
+	entry.Metadata.TranslatedText = ""
+	metadataJSON, err := json.Marshal(entry.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal history metadata: %w", err)
+	}
+
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+
+	conn, err := db.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO textanalyzer_analysis_history (analysis_id, stage, task_id, metadata, created_at)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5)
+		RETURNING id
+	`, entry.AnalysisID, entry.Stage, entry.TaskID, metadataJSON, entry.CreatedAt).Scan(&entry.ID)
+	if err != nil {
+		return fmt.Errorf("failed to insert history entry: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM textanalyzer_analysis_history
+		WHERE analysis_id = $1 AND id NOT IN (
+			SELECT id FROM textanalyzer_analysis_history
+			WHERE analysis_id = $1
+			ORDER BY id DESC
+			LIMIT $2
+		)
+	`, entry.AnalysisID, maxHistoryPerAnalysis)
+	if err != nil {
+		return fmt.Errorf("failed to prune history: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// ListAnalysisHistory retrieves the history of an analysis, oldest first
+func (db *DB) ListAnalysisHistory(ctx context.Context, analysisID string) ([]*models.AnalysisHistoryEntry, error) {
+	conn, err := db.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	rows, err := conn.QueryContext(ctx, `
+		SELECT id, stage, COALESCE(task_id, ''), metadata, created_at
+		FROM textanalyzer_analysis_history
+		WHERE analysis_id = $1
+		ORDER BY id
+	`, analysisID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query history: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []*models.AnalysisHistoryEntry{}
+	for rows.Next() {
+		entry := &models.AnalysisHistoryEntry{AnalysisID: analysisID}
+		var metadataJSON string
+		if err := rows.Scan(&entry.ID, &entry.Stage, &entry.TaskID, &metadataJSON, &entry.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		if err := json.Unmarshal([]byte(metadataJSON), &entry.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal history metadata: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return entries, nil
+}
+
+// GetAnalysisRevision retrieves a single revision of an analysis
+func (db *DB) GetAnalysisRevision(ctx context.Context, analysisID string, revisionNumber int) (*models.AnalysisRevision, error) {
+	revision := &models.AnalysisRevision{AnalysisID: analysisID, Revision: revisionNumber}
+	var fieldsJSON string
+
+	conn, err := db.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	err = conn.QueryRowContext(ctx, `
+		SELECT fields, COALESCE(model, ''), created_at
+		FROM textanalyzer_analysis_revisions
+		WHERE analysis_id = $1 AND revision = $2
+	`, analysisID, revisionNumber).Scan(&fieldsJSON, &revision.Model, &revision.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, ErrRevisionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get revision: %w", err)
+	}
+
+	if err := json.Unmarshal([]byte(fieldsJSON), &revision.Fields); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal revision fields: %w", err)
+	}
+
+	return revision, nil
+}
+
+// UpdateProcessingStage records the processing stage of an analysis.
+// Completing offline analysis starts a new run, clearing the timestamps,
+// retry count and last error; the first enrichment attempt sets started_at
+// and a saved enrichment sets completed_at. Cancelling releases the text
+// hash, so the text is analyzed again when resubmitted.
+func (db *DB) UpdateProcessingStage(ctx context.Context, analysisID, stage string) error {
+	return db.updateProcessingStage(ctx, analysisID, stage, false)
+}
+
+// AdvanceProcessingStage records the processing stage reached by a task as
+// UpdateProcessingStage does, except that a cancelled job keeps its stage
+// and ErrCancelled is returned
+func (db *DB) AdvanceProcessingStage(ctx context.Context, analysisID, stage string) error {
+	return db.updateProcessingStage(ctx, analysisID, stage, true)
+}
+
+// updateProcessingStage records the processing stage of an analysis,
+// leaving a cancelled one as is when keepCancelled is set
+func (db *DB) updateProcessingStage(ctx context.Context, analysisID, stage string, keepCancelled bool) error {
+	conn, err := db.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	res, err := conn.ExecContext(ctx, `
+		UPDATE textanalyzer_analyses SET
+			processing_stage = $2,
+			started_at = CASE WHEN $3 THEN NULL WHEN $4 THEN COALESCE(started_at, NOW()) ELSE started_at END,
+			completed_at = CASE WHEN $5 THEN NOW() WHEN $3 THEN NULL ELSE completed_at END,
+			retry_count = CASE WHEN $3 THEN 0 ELSE retry_count END,
+			last_error = CASE WHEN $3 THEN NULL ELSE last_error END,
+			text_hash = CASE WHEN $6 THEN NULL ELSE text_hash END
+		WHERE id = $1 AND NOT ($7 AND processing_stage IS NOT DISTINCT FROM $8)
+	`, analysisID, stage,
+		stage == models.ProcessingStageOfflineComplete,
+		stage == models.ProcessingStageEnriching,
+		stage == models.ProcessingStageEnriched,
+		stage == models.ProcessingStageCancelled,
+		keepCancelled, models.ProcessingStageCancelled)
+	if err != nil {
+		return fmt.Errorf("failed to update processing stage: %w", err)
+	}
+	if rows, err := res.RowsAffected(); err == nil && rows == 0 {
+		return notUpdated(ctx, conn, analysisID)
+	}
+	return nil
+}
+
+// notUpdated explains why an update of the processing state of an analysis
+// changed no row: ErrCancelled when its job was cancelled, ErrNotFound
+// otherwise
+func notUpdated(ctx context.Context, conn *sql.Conn, analysisID string) error {
+	var cancelled bool
+	err := conn.QueryRowContext(ctx, `
+		SELECT processing_stage IS NOT DISTINCT FROM $2 FROM textanalyzer_analyses WHERE id = $1
+	`, analysisID, models.ProcessingStageCancelled).Scan(&cancelled)
+	if err == nil && cancelled {
+		return ErrCancelled
+	}
+	return ErrNotFound
+}
+
+// maxLastErrorRunes caps the stored error of a failed enrichment attempt
+const maxLastErrorRunes = 1000
+
+// MarkEnrichmentFailed records a failed enrichment attempt with its retry
+// count and error. The attempt that exhausts maxRetries leaves the analysis
+// in the terminal failed stage; earlier ones in enrichment_failed. A
+// cancelled job keeps its stage and ErrCancelled is returned.
+func (db *DB) MarkEnrichmentFailed(ctx context.Context, analysisID string, retryCount, maxRetries int, cause error) error {
+	final := retryCount >= maxRetries
+	stage := models.ProcessingStageEnrichmentFailed
+	if final {
+		stage = models.ProcessingStageFailed
+	}
+
+	conn, err := db.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	res, err := conn.ExecContext(ctx, `
+		UPDATE textanalyzer_analyses SET
+			processing_stage = $2,
+			retry_count = $3,
+			max_retries = $4,
+			last_error = $5,
+			completed_at = CASE WHEN $6 THEN NOW() ELSE NULL END
+		WHERE id = $1 AND processing_stage IS DISTINCT FROM $7
+	`, analysisID, stage, retryCount, maxRetries,
+		textutil.Preview(textutil.ValidUTF8(cause.Error()), maxLastErrorRunes), final, models.ProcessingStageCancelled)
+	if err != nil {
+		return fmt.Errorf("failed to mark enrichment failed: %w", err)
+	}
+	if rows, err := res.RowsAffected(); err == nil && rows == 0 {
+		return notUpdated(ctx, conn, analysisID)
+	}
+	return nil
+}
+
+// GetProcessingState retrieves the stored processing stage of an analysis
+func (db *DB) GetProcessingState(ctx context.Context, analysisID string) (*models.ProcessingState, error) {
+	var (
+		state       models.ProcessingState
+		startedAt   sql.NullTime
+		completedAt sql.NullTime
+	)
+	conn, err := db.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	err = conn.QueryRowContext(ctx, `
+		SELECT COALESCE(processing_stage, $2), started_at, completed_at,
+			COALESCE(retry_count, 0), COALESCE(max_retries, 0), COALESCE(last_error, '')
+		FROM textanalyzer_analyses
+		WHERE id = $1 AND deleted_at IS NULL
+	`, analysisID, models.ProcessingStageOffline).Scan(
+		&state.Stage, &startedAt, &completedAt, &state.RetryCount, &state.MaxRetries, &state.LastError)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get processing state: %w", err)
+	}
+
+	if startedAt.Valid {
+		state.StartedAt = &startedAt.Time
+	}
+	if completedAt.Valid {
+		state.CompletedAt = &completedAt.Time
+	}
+	return &state, nil
+}
+
+// SaveShadowResult stores a shadow model's results for an analysis,
+// replacing any earlier ones. The analysis metadata is left untouched.
+func (db *DB) SaveShadowResult(ctx context.Context, analysisID string, result *models.ShadowResult) error {
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to marshal shadow result: %w", err)
+	}
+
+	conn, err := db.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	res, err := conn.ExecContext(ctx, `
+		UPDATE textanalyzer_analyses SET shadow = $2, shadow_at = $3 WHERE id = $1
+	`, analysisID, resultJSON, result.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save shadow result: %w", err)
+	}
+	if rows, err := res.RowsAffected(); err == nil && rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetShadowResult retrieves the shadow model's results for an analysis, or
+// nil when it was not shadowed
+func (db *DB) GetShadowResult(ctx context.Context, analysisID string) (*models.ShadowResult, error) {
+	var resultJSON []byte
+	conn, err := db.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	err = conn.QueryRowContext(ctx, `SELECT shadow FROM textanalyzer_analyses WHERE id = $1 AND deleted_at IS NULL`, analysisID).Scan(&resultJSON)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get shadow result: %w", err)
+	}
+	if resultJSON == nil {
+		return nil, nil
+	}
+
+	var result models.ShadowResult
+	if err := json.Unmarshal(resultJSON, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal shadow result: %w", err)
+	}
+	return &result, nil
+}
+
+// ListShadowResults retrieves the shadow results recorded since a time, oldest first
+func (db *DB) ListShadowResults(ctx context.Context, since time.Time) ([]*models.ShadowResult, error) {
+	conn, err := db.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	rows, err := conn.QueryContext(ctx, `
+		SELECT shadow
+		FROM textanalyzer_analyses
+		WHERE shadow_at >= $1 AND deleted_at IS NULL
+		ORDER BY shadow_at
+	`, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query shadow results: %w", err)
+	}
+	defer rows.Close()
+
+	results := []*models.ShadowResult{}
+	for rows.Next() {
+		var resultJSON []byte
+		if err := rows.Scan(&resultJSON); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		var result models.ShadowResult
+		if err := json.Unmarshal(resultJSON, &result); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal shadow result: %w", err)
+		}
+		results = append(results, &result)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return results, nil
+}
+
+// TagCooccurrence returns the tags that most often appear on the same
+// analyses as tag, ranked by how many analyses carry both and then by lift.
+// Lift compares the co-occurrence rate with what independent tags would
+// give: count * corpus / (analyses with tag * analyses with the other tag).
+// Structural tags such as sentiment and length are excluded.
+func (db *DB) TagCooccurrence(ctx context.Context, tag string, limit int) ([]models.RelatedTag, error) {
+	conn, err := db.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	rows, err := conn.QueryContext(ctx, `
+		WITH `+visibleTagsCTE+`,
+		corpus AS (
+			SELECT COUNT(DISTINCT analysis_id) AS total FROM visible_tags
+		),
+		target AS (
+			SELECT COUNT(*) AS total FROM visible_tags WHERE tag = $1
+		),
+		pairs AS (
+			SELECT b.tag, COUNT(*) AS together
+			FROM visible_tags a
+			INNER JOIN visible_tags b ON a.analysis_id = b.analysis_id AND b.tag <> a.tag
+			WHERE a.tag = $1 AND NOT (b.tag = ANY($2))
+			GROUP BY b.tag
+		),
+		totals AS (
+			SELECT tag, COUNT(*) AS total
+			FROM visible_tags
+			WHERE tag IN (SELECT tag FROM pairs)
+			GROUP BY tag
+		)
+		SELECT p.tag, p.together,
+			p.together::float8 * corpus.total / (target.total * totals.total) AS lift
+		FROM pairs p
+		INNER JOIN totals ON totals.tag = p.tag
+		CROSS JOIN corpus
+		CROSS JOIN target
+		ORDER BY p.together DESC, lift DESC, p.tag
+		LIMIT $3
+	`, tag, pq.Array(tags.StructuralTags()), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tag co-occurrence: %w", err)
+	}
+	defer rows.Close()
+
+	related := []models.RelatedTag{}
+	for rows.Next() {
+		var r models.RelatedTag
+		if err := rows.Scan(&r.Tag, &r.Count, &r.Lift); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		r.Lift = math.Round(r.Lift*100) / 100
+		related = append(related, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return related, nil
+}
+
+// visibleTagsCTE is a common table expression, visible_tags, of the tags of
+// the analyses that are not soft-deleted
+const visibleTagsCTE = `visible_tags AS (
+			SELECT t.analysis_id, t.tag
+			FROM textanalyzer_tags t
+			INNER JOIN textanalyzer_analyses v ON v.id = t.analysis_id
+			WHERE v.deleted_at IS NULL
+		)`
+
+// pruneLockKey is the advisory lock held while pruning a batch, so instances
+// pruning at the same time take turns instead of competing for rows
+const pruneLockKey = 7248190354
+
+// prunableStages are the processing stages of analyses that were never
+// enriched and are not waiting for enrichment
+var prunableStages = []string{
+	models.ProcessingStageOffline,
+	models.ProcessingStageOfflineComplete,
+	models.ProcessingStageFailed,
+	models.ProcessingStageCancelled,
+}
+
+// PruneAnalyses deletes up to limit of the oldest analyses created before
+// cutoff that were never enriched and score below minQuality, or have no
+// quality score, whether or not they were soft-deleted. They are removed for
+// good, with their tags, references, images, revisions and history. It
+// reports locked false, deleting nothing, while another instance holds the
+// prune lock.
+func (db *DB) PruneAnalyses(ctx context.Context, cutoff time.Time, minQuality float64, limit int) (deleted int, locked bool, err error) {
+	conn, err := db.acquire(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	defer conn.Close()
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := tx.QueryRowContext(ctx, `SELECT pg_try_advisory_xact_lock($1)`, pruneLockKey).Scan(&locked); err != nil {
+		return 0, false, fmt.Errorf("failed to take prune lock: %w", err)
+	}
+	if !locked {
+		return 0, false, nil
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		DELETE FROM textanalyzer_analyses
+		WHERE id IN (
+			SELECT id FROM textanalyzer_analyses
+			WHERE created_at < $1
+				AND COALESCE(processing_stage, $2) = ANY($3)
+				AND COALESCE(`+qualityScoreExpr+`, 0) < $4
+			ORDER BY created_at
+			LIMIT $5
+		)
+	`, cutoff, models.ProcessingStageOffline, pq.Array(prunableStages), minQuality, limit)
+	if err != nil {
+		return 0, true, fmt.Errorf("failed to prune analyses: %w", err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return 0, true, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, true, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return int(rows), true, nil
+}
+
+// relatedTagWeight is what a shared topic tag counts for in RelatedAnalyses,
+// against 1 for a shared key term
+const relatedTagWeight = 3
+
+// RelatedAnalyses returns the analyses sharing at least one topic tag with
+// analysis id, scored as relatedTagWeight per shared tag plus one per shared
+// key term, highest score first and then newest first. Structural tags such
+// as sentiment and length are not counted as shared.
+func (db *DB) RelatedAnalyses(ctx context.Context, id string, limit int) ([]models.RelatedAnalysis, error) {
+	conn, err := db.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	rows, err := conn.QueryContext(ctx, `
+		WITH source AS (
+			SELECT `+keyTermsExpr("")+` AS terms
+			FROM textanalyzer_analyses
+			WHERE id = $1
+		),
+		shared AS (
+			SELECT b.analysis_id, array_agg(b.tag ORDER BY b.tag) AS tags
+			FROM textanalyzer_tags a
+			INNER JOIN textanalyzer_tags b ON b.tag = a.tag AND b.analysis_id <> a.analysis_id
+			WHERE a.analysis_id = $1 AND NOT (a.tag = ANY($2))
+			GROUP BY b.analysis_id
+		)
+		SELECT id, source_url, synopsis, created_at, tags, terms,
+			$3 * cardinality(tags) + cardinality(terms) AS score
+		FROM (
+			SELECT r.id, COALESCE(r.source_url, '') AS source_url,
+				COALESCE(r.metadata->>'synopsis', '') AS synopsis, r.created_at, s.tags,
+				COALESCE((
+					SELECT array_agg(DISTINCT term ORDER BY term)
+					FROM jsonb_array_elements_text(`+keyTermsExpr("r.")+`) AS term
+					WHERE (SELECT terms FROM source) ? term
+				), '{}') AS terms
+			FROM shared s
+			INNER JOIN textanalyzer_analyses r ON r.id = s.analysis_id
+			WHERE r.deleted_at IS NULL
+		) related
+		ORDER BY score DESC, created_at DESC, id
+		LIMIT $4
+	`, id, pq.Array(tags.StructuralTags()), relatedTagWeight, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query related analyses: %w", err)
+	}
+	defer rows.Close()
+
+	related := []models.RelatedAnalysis{}
+	for rows.Next() {
+		var r models.RelatedAnalysis
+		if err := rows.Scan(&r.ID, &r.SourceURL, &r.Synopsis, &r.CreatedAt,
+			pq.Array(&r.SharedTags), pq.Array(&r.SharedKeyTerms), &r.Score); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		if r.SharedKeyTerms == nil {
+			r.SharedKeyTerms = []string{}
+		}
+		related = append(related, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return related, nil
+}
+
+// keyTermsExpr is the key terms array of the analyses prefixed by alias,
+// empty when the metadata has none
+func keyTermsExpr(alias string) string {
+	return fmt.Sprintf(`CASE WHEN jsonb_typeof(%[1]smetadata->'key_terms') = 'array'
+				THEN %[1]smetadata->'key_terms' ELSE '[]'::jsonb END`, alias)
+}
+
+// likeEscaper escapes the LIKE wildcards in a literal pattern
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// ListTags returns the tags starting with prefix, or every tag when prefix
+// is empty, with the number of analyses carrying each, most used first and
+// then alphabetically
+func (db *DB) ListTags(ctx context.Context, prefix string, limit int) ([]models.TagCount, error) {
+	conn, err := db.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	rows, err := conn.QueryContext(ctx, `
+		SELECT t.tag, COUNT(*) AS count
+		FROM textanalyzer_tags t
+		INNER JOIN textanalyzer_analyses a ON a.id = t.analysis_id
+		WHERE t.tag LIKE $1 AND a.deleted_at IS NULL
+		GROUP BY t.tag
+		ORDER BY count DESC, t.tag
+		LIMIT $2
+	`, likeEscaper.Replace(prefix)+"%", limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+	defer rows.Close()
+
+	list := []models.TagCount{}
+	for rows.Next() {
+		var tag models.TagCount
+		if err := rows.Scan(&tag.Tag, &tag.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		list = append(list, tag)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	return list, nil
+}
+
+// renameTagChunkSize is how many analyses RenameTag rewrites per transaction
+const renameTagChunkSize = 1000
+
+// RenameTag renames tag from to to on every analysis carrying it, in the
+// tags table and in the metadata tags, merging it into to where an analysis
+// already has both. Analyses are rewritten renameTagChunkSize at a time, each
+// chunk in its own transaction, so a failure leaves earlier chunks renamed
+// and the rename can be run again. A dry run only counts the analyses.
+func (db *DB) RenameTag(ctx context.Context, from, to string, dryRun bool) (*models.TagRename, error) {
+	result := &models.TagRename{From: from, To: to, DryRun: dryRun}
+
+	if dryRun {
+		conn, err := db.acquire(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer conn.Close()
+
+		err = conn.QueryRowContext(ctx, `
+			SELECT COUNT(*), COUNT(t.analysis_id)
+			FROM textanalyzer_tags f
+			LEFT JOIN textanalyzer_tags t ON t.analysis_id = f.analysis_id AND t.tag = $2
+			WHERE f.tag = $1
+		`, from, to).Scan(&result.Analyses, &result.Merged)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count tagged analyses: %w", err)
+		}
+		return result, nil
+	}
+
+	for {
+		renamed, merged, err := db.renameTagChunk(ctx, from, to)
+		if err != nil {
+			return nil, err
+		}
+		result.Analyses += renamed
+		result.Merged += merged
+		if renamed < renameTagChunkSize {
+			return result, nil
+		}
+	}
+}
+
+// renameTagChunk renames the tag on up to renameTagChunkSize analyses in one
+// transaction, returning how many it renamed and how many of those merged
+func (db *DB) renameTagChunk(ctx context.Context, from, to string) (renamed, merged int, err error) {
+	conn, err := db.acquire(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer conn.Close()
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT analysis_id FROM textanalyzer_tags
+		WHERE tag = $1
+		ORDER BY analysis_id
+		LIMIT $2
+		FOR UPDATE
+	`, from, renameTagChunkSize)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to query tagged analyses: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, 0, fmt.Errorf("failed to scan row: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, fmt.Errorf("row iteration error: %w", err)
+	}
+	if len(ids) == 0 {
+		return 0, 0, nil
+	}
+
+	// Analyses already carrying the target keep their row for it
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO textanalyzer_tags (analysis_id, tag)
+		SELECT id, $2 FROM unnest($1::text[]) AS id
+		ON CONFLICT (analysis_id, tag) DO NOTHING
+	`, pq.Array(ids), to)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to insert renamed tags: %w", err)
+	}
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM textanalyzer_tags WHERE tag = $1 AND analysis_id = ANY($2)
+	`, from, pq.Array(ids)); err != nil {
+		return 0, 0, fmt.Errorf("failed to delete renamed tags: %w", err)
+	}
+
+	// Rewrite the metadata tags in order, keeping the first of duplicates
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE textanalyzer_analyses SET
+			metadata = jsonb_set(metadata, '{tags}', (
+				SELECT COALESCE(jsonb_agg(tag ORDER BY first), '[]'::jsonb)
+				FROM (
+					SELECT CASE WHEN e.tag = $2 THEN $3 ELSE e.tag END AS tag, MIN(e.ord) AS first
+					FROM jsonb_array_elements_text(metadata->'tags') WITH ORDINALITY AS e(tag, ord)
+					GROUP BY 1
+				) renamed
+			)),
+			updated_at = NOW()
+		WHERE id = ANY($1) AND jsonb_typeof(metadata->'tags') = 'array'
+	`, pq.Array(ids), from, to); err != nil {
+		return 0, 0, fmt.Errorf("failed to rewrite metadata tags: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return len(ids), len(ids) - int(inserted), nil
+}
+
+// Corpus statistics limits
+const (
+	statsTopTags = 20
+	statsDays    = 30
+)
+
+// CorpusStats aggregates the analyses created since a time, or every
+// analysis when since is zero: counts by processing stage and detected
+// language, quality score average and median, the most common topic tags
+// and analyses per day over the last statsDays days (UTC).
+func (db *DB) CorpusStats(ctx context.Context, since time.Time) (*models.CorpusStats, error) {
+	now := time.Now().UTC()
+	stats := &models.CorpusStats{
+		ByStage:     map[string]int{},
+		ByLanguage:  map[string]int{},
+		TopTags:     []models.TagCount{},
+		PerDay:      []models.DailyCount{},
+		GeneratedAt: now,
+	}
+	if !since.IsZero() {
+		stats.Since = &since
+	}
+
+	conn, err := db.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// A zero time matches every analysis
+	if err := countGroups(ctx, conn, stats.ByStage, `
+		SELECT COALESCE(processing_stage, $2), COUNT(*)
+		FROM textanalyzer_analyses
+		WHERE created_at >= $1 AND deleted_at IS NULL
+		GROUP BY 1
+	`, since, models.ProcessingStageOffline); err != nil {
+		return nil, fmt.Errorf("failed to count analyses by stage: %w", err)
+	}
+	for _, count := range stats.ByStage {
+		stats.TotalAnalyses += count
+	}
+
+	if err := countGroups(ctx, conn, stats.ByLanguage, `
+		SELECT COALESCE(NULLIF(metadata->>'language', ''), 'unknown'), COUNT(*)
+		FROM textanalyzer_analyses
+		WHERE created_at >= $1 AND deleted_at IS NULL
+		GROUP BY 1
+	`, since); err != nil {
+		return nil, fmt.Errorf("failed to count analyses by language: %w", err)
+	}
+
+	var average, median sql.NullFloat64
+	err = conn.QueryRowContext(ctx, `
+		SELECT COUNT(score), AVG(score), percentile_cont(0.5) WITHIN GROUP (ORDER BY score)
+		FROM (
+			SELECT (metadata->'quality_score'->>'score')::float8 AS score
+			FROM textanalyzer_analyses
+			WHERE created_at >= $1 AND deleted_at IS NULL AND metadata->'quality_score'->>'score' IS NOT NULL
+		) scores
+	`, since).Scan(&stats.Quality.Scored, &average, &median)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize quality scores: %w", err)
+	}
+	if average.Valid {
+		v := math.Round(average.Float64*1000) / 1000
+		stats.Quality.Average = &v
+	}
+	if median.Valid {
+		v := math.Round(median.Float64*1000) / 1000
+		stats.Quality.Median = &v
+	}
+
+	rows, err := conn.QueryContext(ctx, `
+		SELECT t.tag, COUNT(*) AS count
+		FROM textanalyzer_tags t
+		INNER JOIN textanalyzer_analyses a ON a.id = t.analysis_id
+		WHERE a.created_at >= $1 AND a.deleted_at IS NULL AND NOT (t.tag = ANY($2))
+		GROUP BY t.tag
+		ORDER BY count DESC, t.tag
+		LIMIT $3
+	`, since, pq.Array(tags.StructuralTags()), statsTopTags)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query top tags: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var tag models.TagCount
+		if err := rows.Scan(&tag.Tag, &tag.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		stats.TopTags = append(stats.TopTags, tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("row iteration error: %w", err)
+	}
+
+	// Days without analyses are reported as zero
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	first := today.AddDate(0, 0, 1-statsDays)
+	from := first
+	if since.After(from) {
+		from = since
+	}
+	perDay := map[string]int{}
+	if err := countGroups(ctx, conn, perDay, `
+		SELECT to_char(created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD'), COUNT(*)
+		FROM textanalyzer_analyses
+		WHERE created_at >= $1 AND deleted_at IS NULL
+		GROUP BY 1
+	`, from); err != nil {
+		return nil, fmt.Errorf("failed to count analyses per day: %w", err)
+	}
+	for day := first; !day.After(today); day = day.AddDate(0, 0, 1) {
+		date := day.Format("2006-01-02")
+		stats.PerDay = append(stats.PerDay, models.DailyCount{Date: date, Count: perDay[date]})
+	}
+
+	return stats, nil
+}
+
+// countGroups runs a query returning (key, count) rows into counts
+func countGroups(ctx context.Context, conn *sql.Conn, counts map[string]int, query string, args ...interface{}) error {
+	rows, err := conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			key   string
+			count int
+		)
+		if err := rows.Scan(&key, &count); err != nil {
+			return fmt.Errorf("failed to scan row: %w", err)
+		}
+		counts[key] = count
+	}
+	return rows.Err()
 }
@@ -1,14 +1,21 @@
 package database
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/docutag/textanalyzer/internal/models"
 )
 
-func setupTestDatabase(t *testing.T) (*DB, func()) {
+func setupTestDatabase(t testing.TB) (*DB, func()) {
 	t.Helper()
 	testName := fmt.Sprintf("queries_%d", time.Now().UnixNano())
 	connStr, dbCleanup := setupTestDB(t, testName)
@@ -59,7 +66,7 @@ func createTestAnalysis(id string) *models.Analysis {
 			AvgSentenceLength:  7.0,
 			References:         []models.Reference{},
 			Tags:               []string{"short", "neutral", "easy"},
-			Language:           "english",
+			Language:           "en",
 			QuestionCount:      0,
 			ExclamationCount:   0,
 			CapitalizedPercent: 14.29,
@@ -75,7 +82,7 @@ func TestSaveAnalysis(t *testing.T) {
 
 	analysis := createTestAnalysis("test-001")
 
-	err := db.SaveAnalysis(analysis)
+	err := db.SaveAnalysis(context.Background(), analysis)
 	if err != nil {
 		t.Fatalf("Failed to save analysis: %v", err)
 	}
@@ -87,11 +94,11 @@ func TestGetAnalysis(t *testing.T) {
 
 	analysis := createTestAnalysis("test-002")
 
-	if err := db.SaveAnalysis(analysis); err != nil {
+	if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
 		t.Fatalf("Failed to save analysis: %v", err)
 	}
 
-	retrieved, err := db.GetAnalysis("test-002")
+	retrieved, err := db.GetAnalysis(context.Background(), "test-002")
 	if err != nil {
 		t.Fatalf("Failed to get analysis: %v", err)
 	}
@@ -113,13 +120,13 @@ func TestGetAnalysisNotFound(t *testing.T) {
 	db, cleanup := setupTestDatabase(t)
 	defer cleanup()
 
-	_, err := db.GetAnalysis("nonexistent")
+	_, err := db.GetAnalysis(context.Background(), "nonexistent")
 	if err == nil {
 		t.Error("Expected error for nonexistent analysis")
 	}
 
-	if err.Error() != "analysis not found" {
-		t.Errorf("Expected 'analysis not found' error, got %v", err)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
 	}
 }
 
@@ -130,14 +137,14 @@ func TestListAnalyses(t *testing.T) {
 	// Save multiple analyses
 	for i := 1; i <= 5; i++ {
 		analysis := createTestAnalysis("test-" + string(rune('0'+i)))
-		if err := db.SaveAnalysis(analysis); err != nil {
+		if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
 			t.Fatalf("Failed to save analysis %d: %v", i, err)
 		}
 		time.Sleep(10 * time.Millisecond) // Ensure different timestamps
 	}
 
 	// Test pagination
-	analyses, err := db.ListAnalyses(3, 0)
+	analyses, err := db.ListAnalyses(context.Background(), 3, 0)
 	if err != nil {
 		t.Fatalf("Failed to list analyses: %v", err)
 	}
@@ -147,7 +154,7 @@ func TestListAnalyses(t *testing.T) {
 	}
 
 	// Test offset
-	analyses, err = db.ListAnalyses(3, 3)
+	analyses, err = db.ListAnalyses(context.Background(), 3, 3)
 	if err != nil {
 		t.Fatalf("Failed to list analyses with offset: %v", err)
 	}
@@ -157,6 +164,348 @@ func TestListAnalyses(t *testing.T) {
 	}
 }
 
+func TestListAnalysesCursor(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	// Analyses created at the same time are ordered by ID
+	base := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	var want []string
+	for i := 0; i < 25; i++ {
+		analysis := createTestAnalysis(fmt.Sprintf("test-cursor-%02d", i))
+		analysis.CreatedAt = base.Add(time.Duration(i/3) * time.Minute)
+		if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+			t.Fatalf("Failed to save analysis %d: %v", i, err)
+		}
+		want = append(want, analysis.ID)
+	}
+	sort.Slice(want, func(i, j int) bool { return want[i] > want[j] })
+
+	// Analyses added while paging, before and behind the cursor, neither
+	// repeat nor skip the analyses already there
+	var (
+		wg       sync.WaitGroup
+		inserted = make(chan int)
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := range inserted {
+			analysis := createTestAnalysis(fmt.Sprintf("test-cursor-new-%d", i))
+			analysis.CreatedAt = time.Now()
+			if i%2 == 1 {
+				analysis.CreatedAt = base.Add(-time.Duration(i) * time.Hour)
+			}
+			if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+				t.Errorf("Failed to save concurrent analysis: %v", err)
+			}
+		}
+	}()
+
+	var (
+		seen  []string
+		after *Cursor
+	)
+	for page := 0; page < 20; page++ {
+		inserted <- page
+		analyses, err := db.ListAnalysesFiltered(context.Background(), 4, 0, ListFilter{After: after})
+		if err != nil {
+			t.Fatalf("Failed to list page %d: %v", page, err)
+		}
+		if len(analyses) == 0 {
+			break
+		}
+		for _, analysis := range analyses {
+			if !strings.HasPrefix(analysis.ID, "test-cursor-new-") {
+				seen = append(seen, analysis.ID)
+			}
+		}
+		last := analyses[len(analyses)-1]
+		after = &Cursor{CreatedAt: last.CreatedAt, ID: last.ID}
+	}
+	close(inserted)
+	wg.Wait()
+
+	if !reflect.DeepEqual(seen, want) {
+		t.Errorf("Expected every analysis once in order:\n%v\ngot:\n%v", want, seen)
+	}
+
+	// A cursor combines with filters
+	old := base.Add(2 * time.Minute)
+	analyses, err := db.ListAnalysesFiltered(context.Background(), 10, 0, ListFilter{
+		CreatedBefore: &old,
+		After:         &Cursor{CreatedAt: base.Add(time.Minute), ID: "test-cursor-04"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to list filtered page: %v", err)
+	}
+	var ids []string
+	for _, analysis := range analyses {
+		ids = append(ids, analysis.ID)
+	}
+	if want := []string{"test-cursor-03", "test-cursor-02", "test-cursor-01", "test-cursor-00"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("Expected %v, got %v", want, ids)
+	}
+}
+
+func TestCountAnalyses(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	assertCount := func(want int, filter ListFilter) {
+		t.Helper()
+		count, err := db.CountAnalysesFiltered(context.Background(), filter)
+		if err != nil {
+			t.Fatalf("Failed to count analyses: %v", err)
+		}
+		if count != want {
+			t.Errorf("Expected %d analyses, got %d", want, count)
+		}
+	}
+
+	count, err := db.CountAnalyses(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to count analyses: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("Expected no analyses, got %d", count)
+	}
+
+	acme := ListFilter{ClientMetadata: map[string]string{"customer": "acme"}}
+	for i := 1; i <= 4; i++ {
+		analysis := createTestAnalysis(fmt.Sprintf("test-count-%d", i))
+		if i%2 == 0 {
+			analysis.ClientMetadata = map[string]string{"customer": "acme"}
+		}
+		if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+			t.Fatalf("Failed to save analysis %d: %v", i, err)
+		}
+		assertCount(i, ListFilter{})
+	}
+	assertCount(2, acme)
+
+	if err := db.DeleteAnalysis(context.Background(), "test-count-2"); err != nil {
+		t.Fatalf("Failed to delete analysis: %v", err)
+	}
+	assertCount(3, ListFilter{})
+	assertCount(1, acme)
+}
+
+func TestListAnalysesFilters(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	day := func(d int) time.Time { return time.Date(2025, 1, d, 12, 0, 0, 0, time.UTC) }
+	score := func(s float64) *models.TextQualityScore { return &models.TextQualityScore{Score: s} }
+	bias := func(b string) *models.EditorialResult {
+		return &models.EditorialResult{Purpose: "informational", Bias: b}
+	}
+	rows := []struct {
+		id        string
+		created   time.Time
+		language  string
+		quality   *models.TextQualityScore
+		editorial *models.EditorialResult
+	}{
+		{"test-filter-1", day(1), "en", score(0.2), bias("none")},
+		{"test-filter-2", day(5), "en", score(0.9), bias("center-left")},
+		{"test-filter-3", day(8), "es", score(0.3), bias("none")},
+		{"test-filter-4", day(9), "en", score(0.45), nil},
+		{"test-filter-5", day(10), "unknown", nil, nil},
+	}
+	for _, row := range rows {
+		analysis := createTestAnalysis(row.id)
+		analysis.CreatedAt, analysis.UpdatedAt = row.created, row.created
+		analysis.Metadata.Language = row.language
+		analysis.Metadata.QualityScore = row.quality
+		analysis.Metadata.Editorial = row.editorial
+		if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+			t.Fatalf("Failed to save analysis %s: %v", row.id, err)
+		}
+	}
+
+	after, before := day(4), day(10)
+	low, high := 0.5, 0.4
+	zero, one := 0.0, 1.0
+	tests := []struct {
+		name   string
+		filter ListFilter
+		want   []string
+	}{
+		{"no filter", ListFilter{}, []string{"test-filter-5", "test-filter-4", "test-filter-3", "test-filter-2", "test-filter-1"}},
+		{"created after", ListFilter{CreatedAfter: &after}, []string{"test-filter-5", "test-filter-4", "test-filter-3", "test-filter-2"}},
+		{"created before is exclusive", ListFilter{CreatedBefore: &before}, []string{"test-filter-4", "test-filter-3", "test-filter-2", "test-filter-1"}},
+		{"language", ListFilter{Language: "en"}, []string{"test-filter-4", "test-filter-2", "test-filter-1"}},
+		{"max quality", ListFilter{MaxQuality: &high}, []string{"test-filter-3", "test-filter-1"}},
+		{"min quality", ListFilter{MinQuality: &low}, []string{"test-filter-2"}},
+		{"quality bounds skip unscored analyses", ListFilter{MinQuality: &zero, MaxQuality: &one}, []string{"test-filter-4", "test-filter-3", "test-filter-2", "test-filter-1"}},
+		{"low quality english from last week", ListFilter{CreatedAfter: &after, CreatedBefore: &before, Language: "en", MaxQuality: &low}, []string{"test-filter-4"}},
+		{"bias", ListFilter{Bias: "none"}, []string{"test-filter-3", "test-filter-1"}},
+		{"english with a bias", ListFilter{Language: "en", Bias: "center-left"}, []string{"test-filter-2"}},
+		{"no match", ListFilter{Language: "de"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analyses, err := db.ListAnalysesFiltered(context.Background(), 10, 0, tt.filter)
+			if err != nil {
+				t.Fatalf("Failed to list analyses: %v", err)
+			}
+			var ids []string
+			for _, analysis := range analyses {
+				ids = append(ids, analysis.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, ids)
+			}
+
+			count, err := db.CountAnalysesFiltered(context.Background(), tt.filter)
+			if err != nil {
+				t.Fatalf("Failed to count analyses: %v", err)
+			}
+			if count != len(tt.want) {
+				t.Errorf("Expected a count of %d, got %d", len(tt.want), count)
+			}
+		})
+	}
+}
+
+func TestSearchAnalyses(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	texts := map[string]string{
+		"test-search-1": "Carbon capture plants pull carbon from the air. Carbon capture is costly, and carbon capture projects need grants.",
+		"test-search-2": "The council approved a carbon capture pilot at the power station.",
+		"test-search-3": "The council approved the new transit plan on Tuesday.",
+	}
+	for id, text := range texts {
+		analysis := createTestAnalysis(id)
+		analysis.Text = text
+		if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+			t.Fatalf("Failed to save analysis %s: %v", id, err)
+		}
+	}
+
+	// Matches in the cleaned text count, and redacted text is never shown
+	cleaned := createTestAnalysis("test-search-4")
+	cleaned.Text = "Menu | Login | The utility captures carbon underground."
+	cleaned.Metadata.CleanedText = "The utility captures carbon underground."
+	cleaned.Metadata.Redaction = &models.TextRedaction{TextSHA256: "abc123"}
+	if err := db.SaveAnalysis(context.Background(), cleaned); err != nil {
+		t.Fatalf("Failed to save analysis: %v", err)
+	}
+
+	matches, err := db.SearchAnalyses(context.Background(), "carbon capture", 10, 0, true)
+	if err != nil {
+		t.Fatalf("Failed to search analyses: %v", err)
+	}
+	var ids []string
+	for _, match := range matches {
+		ids = append(ids, match.ID)
+		if !strings.Contains(match.Headline, "<mark>") {
+			t.Errorf("Expected a highlighted headline for %s, got %q", match.ID, match.Headline)
+		}
+	}
+	if len(ids) != 3 || ids[0] != "test-search-1" {
+		t.Fatalf("Expected the three carbon capture analyses, most mentions first, got %v", ids)
+	}
+	for i := 1; i < len(matches); i++ {
+		if matches[i].Rank > matches[i-1].Rank {
+			t.Errorf("Expected matches ordered by rank, got %v after %v", matches[i].Rank, matches[i-1].Rank)
+		}
+	}
+	for _, match := range matches {
+		if match.ID == cleaned.ID {
+			if match.Text != "" || strings.Contains(match.Headline, "sha256") || !strings.Contains(match.Headline, "underground") {
+				t.Errorf("Expected the cleaned text excerpt of the redacted analysis, got %q and %q", match.Text, match.Headline)
+			}
+		}
+	}
+
+	// Common terms are bounded by the limit but counted in full
+	matches, err = db.SearchAnalyses(context.Background(), "council", 1, 0, true)
+	if err != nil {
+		t.Fatalf("Failed to search analyses: %v", err)
+	}
+	count, err := db.CountSearchAnalyses(context.Background(), "council")
+	if err != nil {
+		t.Fatalf("Failed to count search matches: %v", err)
+	}
+	if len(matches) != 1 || count != 2 {
+		t.Errorf("Expected 1 of 2 matches, got %d of %d", len(matches), count)
+	}
+
+	// Updating the text updates the index
+	updated := createTestAnalysis("test-search-3")
+	updated.Text = "The transit plan now includes carbon capture buses."
+	if err := db.SaveAnalysis(context.Background(), updated); err != nil {
+		t.Fatalf("Failed to update analysis: %v", err)
+	}
+	if count, err := db.CountSearchAnalyses(context.Background(), "carbon capture"); err != nil || count != 4 {
+		t.Errorf("Expected 4 matches after the update, got %d (%v)", count, err)
+	}
+
+	matches, err = db.SearchAnalyses(context.Background(), "geothermal", 10, 0, true)
+	if err != nil || len(matches) != 0 {
+		t.Errorf("Expected no matches, got %d (%v)", len(matches), err)
+	}
+}
+
+func TestClientMetadata(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	acme := createTestAnalysis("test-client-001")
+	acme.ClientMetadata = map[string]string{"customer": "acme", "crawl_id": "42"}
+	other := createTestAnalysis("test-client-002")
+	other.ClientMetadata = map[string]string{"customer": "globex", "crawl_id": "42"}
+	plain := createTestAnalysis("test-client-003")
+
+	for _, analysis := range []*models.Analysis{acme, other, plain} {
+		if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+			t.Fatalf("Failed to save analysis %s: %v", analysis.ID, err)
+		}
+	}
+
+	retrieved, err := db.GetAnalysis(context.Background(), acme.ID)
+	if err != nil {
+		t.Fatalf("Failed to get analysis: %v", err)
+	}
+	if !reflect.DeepEqual(retrieved.ClientMetadata, acme.ClientMetadata) {
+		t.Errorf("Expected client metadata %v, got %v", acme.ClientMetadata, retrieved.ClientMetadata)
+	}
+
+	retrieved, err = db.GetAnalysis(context.Background(), plain.ID)
+	if err != nil {
+		t.Fatalf("Failed to get analysis: %v", err)
+	}
+	if retrieved.ClientMetadata != nil {
+		t.Errorf("Expected no client metadata, got %v", retrieved.ClientMetadata)
+	}
+
+	tests := []struct {
+		name   string
+		filter map[string]string
+		want   int
+	}{
+		{"no filter", nil, 3},
+		{"shared key", map[string]string{"crawl_id": "42"}, 2},
+		{"all pairs must match", map[string]string{"crawl_id": "42", "customer": "acme"}, 1},
+		{"no match", map[string]string{"customer": "initech"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analyses, err := db.ListAnalysesFiltered(context.Background(), 10, 0, ListFilter{ClientMetadata: tt.filter})
+			if err != nil {
+				t.Fatalf("Failed to list analyses: %v", err)
+			}
+			if len(analyses) != tt.want {
+				t.Errorf("Expected %d analyses, got %d", tt.want, len(analyses))
+			}
+		})
+	}
+}
+
 func TestGetAnalysesByTag(t *testing.T) {
 	db, cleanup := setupTestDatabase(t)
 	defer cleanup()
@@ -171,18 +520,18 @@ func TestGetAnalysesByTag(t *testing.T) {
 	analysis3 := createTestAnalysis("test-tag-003")
 	analysis3.Metadata.Tags = []string{"negative", "long"}
 
-	if err := db.SaveAnalysis(analysis1); err != nil {
+	if err := db.SaveAnalysis(context.Background(), analysis1); err != nil {
 		t.Fatalf("Failed to save analysis 1: %v", err)
 	}
-	if err := db.SaveAnalysis(analysis2); err != nil {
+	if err := db.SaveAnalysis(context.Background(), analysis2); err != nil {
 		t.Fatalf("Failed to save analysis 2: %v", err)
 	}
-	if err := db.SaveAnalysis(analysis3); err != nil {
+	if err := db.SaveAnalysis(context.Background(), analysis3); err != nil {
 		t.Fatalf("Failed to save analysis 3: %v", err)
 	}
 
 	// Search by tag
-	analyses, err := db.GetAnalysesByTag("positive")
+	analyses, err := db.GetAnalysesByTag(context.Background(), "positive", true)
 	if err != nil {
 		t.Fatalf("Failed to get analyses by tag: %v", err)
 	}
@@ -192,7 +541,7 @@ func TestGetAnalysesByTag(t *testing.T) {
 	}
 
 	// Search by another tag
-	analyses, err = db.GetAnalysesByTag("long")
+	analyses, err = db.GetAnalysesByTag(context.Background(), "long", true)
 	if err != nil {
 		t.Fatalf("Failed to get analyses by tag: %v", err)
 	}
@@ -202,7 +551,7 @@ func TestGetAnalysesByTag(t *testing.T) {
 	}
 
 	// Search by nonexistent tag
-	analyses, err = db.GetAnalysesByTag("nonexistent")
+	analyses, err = db.GetAnalysesByTag(context.Background(), "nonexistent", true)
 	if err != nil {
 		t.Fatalf("Failed to get analyses by tag: %v", err)
 	}
@@ -212,115 +561,2211 @@ func TestGetAnalysesByTag(t *testing.T) {
 	}
 }
 
-func TestDeleteAnalysis(t *testing.T) {
+func TestSaveAnalysisUpsert(t *testing.T) {
 	db, cleanup := setupTestDatabase(t)
 	defer cleanup()
 
-	analysis := createTestAnalysis("test-delete-001")
-
-	if err := db.SaveAnalysis(analysis); err != nil {
+	analysis := createTestAnalysis("test-upsert-001")
+	analysis.CreatedAt = time.Now().Add(-time.Hour)
+	analysis.Metadata.Tags = []string{"offline"}
+	if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
 		t.Fatalf("Failed to save analysis: %v", err)
 	}
 
-	// Delete the analysis
-	err := db.DeleteAnalysis("test-delete-001")
+	// Enrichment saves the same analysis again with new metadata
+	enriched := createTestAnalysis("test-upsert-001")
+	enriched.Text = "This is the enriched text."
+	enriched.Metadata.Tags = []string{"enriched"}
+	enriched.UpdatedAt = time.Now().Add(time.Minute)
+	if err := db.SaveAnalysis(context.Background(), enriched); err != nil {
+		t.Fatalf("Failed to update analysis: %v", err)
+	}
+
+	retrieved, err := db.GetAnalysis(context.Background(), "test-upsert-001")
 	if err != nil {
-		t.Fatalf("Failed to delete analysis: %v", err)
+		t.Fatalf("Failed to get analysis: %v", err)
+	}
+	if retrieved.Text != enriched.Text {
+		t.Errorf("Expected text %q, got %q", enriched.Text, retrieved.Text)
+	}
+	if diff := retrieved.CreatedAt.Sub(analysis.CreatedAt).Abs(); diff > time.Millisecond {
+		t.Errorf("Expected created_at %v to be preserved, got %v", analysis.CreatedAt, retrieved.CreatedAt)
+	}
+	if diff := retrieved.UpdatedAt.Sub(enriched.UpdatedAt).Abs(); diff > time.Millisecond {
+		t.Errorf("Expected updated_at %v, got %v", enriched.UpdatedAt, retrieved.UpdatedAt)
 	}
 
-	// Verify it's deleted
-	_, err = db.GetAnalysis("test-delete-001")
-	if err == nil {
-		t.Error("Expected error when getting deleted analysis")
+	// Tags are replaced, not accumulated
+	for tag, expected := range map[string]int{"offline": 0, "enriched": 1} {
+		analyses, err := db.GetAnalysesByTag(context.Background(), tag, true)
+		if err != nil {
+			t.Fatalf("Failed to get analyses by tag: %v", err)
+		}
+		if len(analyses) != expected {
+			t.Errorf("Expected %d analyses with %q tag, got %d", expected, tag, len(analyses))
+		}
 	}
 }
 
-func TestDeleteAnalysisNotFound(t *testing.T) {
+func TestSaveAnalysisTagsAndReferences(t *testing.T) {
 	db, cleanup := setupTestDatabase(t)
 	defer cleanup()
 
-	err := db.DeleteAnalysis("nonexistent")
-	if err == nil {
-		t.Error("Expected error when deleting nonexistent analysis")
+	rows := func(id string) (tags []string, refs []models.Reference) {
+		t.Helper()
+		tagRows, err := db.conn.Query("SELECT tag FROM textanalyzer_tags WHERE analysis_id = $1 ORDER BY id", id)
+		if err != nil {
+			t.Fatalf("Failed to query tags: %v", err)
+		}
+		defer tagRows.Close()
+		for tagRows.Next() {
+			var tag string
+			if err := tagRows.Scan(&tag); err != nil {
+				t.Fatalf("Failed to scan tag: %v", err)
+			}
+			tags = append(tags, tag)
+		}
+		refRows, err := db.conn.Query("SELECT text, type, context, confidence FROM textanalyzer_text_references WHERE analysis_id = $1 ORDER BY id", id)
+		if err != nil {
+			t.Fatalf("Failed to query references: %v", err)
+		}
+		defer refRows.Close()
+		for refRows.Next() {
+			var ref models.Reference
+			if err := refRows.Scan(&ref.Text, &ref.Type, &ref.Context, &ref.Confidence); err != nil {
+				t.Fatalf("Failed to scan reference: %v", err)
+			}
+			refs = append(refs, ref)
+		}
+		return tags, refs
+	}
+
+	analysis := createTestAnalysis("test-tags-refs-001")
+	analysis.Metadata.Tags = []string{"zeta", "alpha", "zeta", "m\xffid", "m\xfeid"}
+	analysis.Metadata.References = []models.Reference{
+		{Text: "Sales grew 40%", Type: "statistic", Context: "In 2024, sales grew 40%", Confidence: "high"},
+		{Text: "Experts agree", Type: "claim", Context: "", Confidence: "low"},
+	}
+	if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+		t.Fatalf("Failed to save analysis: %v", err)
+	}
+
+	// Rows are stored in order, with invalid UTF-8 replaced and duplicates,
+	// including those equal once replaced, stored once
+	tags, refs := rows(analysis.ID)
+	if want := []string{"zeta", "alpha", "m\uFFFDid"}; !reflect.DeepEqual(tags, want) {
+		t.Errorf("Expected tags %q, got %q", want, tags)
+	}
+	if !reflect.DeepEqual(refs, analysis.Metadata.References) {
+		t.Errorf("Expected references %+v, got %+v", analysis.Metadata.References, refs)
+	}
+
+	// A row that fails rolls back the whole save
+	if _, err := db.conn.Exec("ALTER TABLE textanalyzer_tags ADD CONSTRAINT test_rejected_tag CHECK (tag <> 'rejected')"); err != nil {
+		t.Fatalf("Failed to add constraint: %v", err)
+	}
+	failing := createTestAnalysis(analysis.ID)
+	failing.Text = "This save fails."
+	failing.Metadata.Tags = []string{"fine", "rejected"}
+	if err := db.SaveAnalysis(context.Background(), failing); err == nil {
+		t.Fatal("Expected a rejected tag to fail the save")
+	}
+	if stored, err := db.GetAnalysis(context.Background(), analysis.ID); err != nil {
+		t.Fatalf("Failed to get analysis: %v", err)
+	} else if stored.Text != analysis.Text {
+		t.Errorf("Expected the failed save rolled back, got text %q", stored.Text)
+	}
+	if got, _ := rows(analysis.ID); len(got) != 3 {
+		t.Errorf("Expected the failed save to keep the 3 stored tags, got %q", got)
 	}
 
-	if err.Error() != "analysis not found" {
-		t.Errorf("Expected 'analysis not found' error, got %v", err)
+	// Saving without tags or references clears them
+	analysis.Metadata.Tags = nil
+	analysis.Metadata.References = nil
+	if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+		t.Fatalf("Failed to save analysis: %v", err)
+	}
+	if tags, refs := rows(analysis.ID); len(tags) != 0 || len(refs) != 0 {
+		t.Errorf("Expected no tags or references, got %q and %+v", tags, refs)
 	}
 }
 
-func TestMigrations(t *testing.T) {
-	connStr, dbCleanup := setupTestDB(t, "test_migrations")
-	defer dbCleanup()
+// replaceTagsAndReferencesPerRow is the statement-per-row replacement that
+// replaceTagsAndReferences superseded, kept to benchmark against
+func replaceTagsAndReferencesPerRow(ctx context.Context, tx *sql.Tx, analysis *models.Analysis) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM textanalyzer_tags WHERE analysis_id = $1`, analysis.ID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM textanalyzer_text_references WHERE analysis_id = $1`, analysis.ID); err != nil {
+		return err
+	}
+	for _, tag := range analysis.Metadata.Tags {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO textanalyzer_tags (analysis_id, tag) VALUES ($1, $2)`, analysis.ID, tag); err != nil {
+			return err
+		}
+	}
+	for _, ref := range analysis.Metadata.References {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO textanalyzer_text_references (analysis_id, text, type, context, confidence)
+			VALUES ($1, $2, $3, $4, $5)
+		`, analysis.ID, ref.Text, ref.Type, ref.Context, ref.Confidence); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	db, err := New(connStr)
+func BenchmarkReplaceTagsAndReferences(b *testing.B) {
+	db, cleanup := setupTestDatabase(b)
+	defer cleanup()
+
+	analysis := createTestAnalysis("bench-tags-001")
+	for i := range 50 {
+		analysis.Metadata.Tags = append(analysis.Metadata.Tags, fmt.Sprintf("tag-%d", i))
+	}
+	for i := range 10 {
+		analysis.Metadata.References = append(analysis.Metadata.References, models.Reference{
+			Text: fmt.Sprintf("Reference %d", i), Type: "claim", Context: "Context", Confidence: "medium",
+		})
+	}
+	if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+		b.Fatalf("Failed to save analysis: %v", err)
+	}
+
+	for name, replace := range map[string]func(context.Context, *sql.Tx, *models.Analysis) error{
+		"per-row": replaceTagsAndReferencesPerRow,
+		"batched": replaceTagsAndReferences,
+	} {
+		b.Run(name, func(b *testing.B) {
+			for b.Loop() {
+				tx, err := db.conn.BeginTx(context.Background(), nil)
+				if err != nil {
+					b.Fatalf("Failed to begin transaction: %v", err)
+				}
+				if err := replace(context.Background(), tx, analysis); err != nil {
+					tx.Rollback()
+					b.Fatalf("Failed to replace tags and references: %v", err)
+				}
+				if err := tx.Commit(); err != nil {
+					b.Fatalf("Failed to commit: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestOriginalHTML(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	analysis := createTestAnalysis("test-html-001")
+	analysis.OriginalHTML = "H4sIAAAAAAAA/7IpyEhUSM7PS0nNSy9JTVFIT1VIyclMzyxJzUkFAAAA//8="
+	if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+		t.Fatalf("Failed to save analysis: %v", err)
+	}
+
+	plain, err := db.GetAnalysis(context.Background(), "test-html-001")
 	if err != nil {
-		t.Fatalf("Failed to create database: %v", err)
+		t.Fatalf("Failed to get analysis: %v", err)
+	}
+	if plain.OriginalHTML != "" {
+		t.Errorf("Expected GetAnalysis to leave out the original HTML, got %q", plain.OriginalHTML)
 	}
-	defer db.Close()
 
-	// Run migrations
-	if err := db.Migrate(); err != nil {
-		t.Fatalf("Failed to run migrations: %v", err)
+	// Saving an analysis loaded without its HTML keeps the stored HTML
+	plain.Metadata.Synopsis = "Enriched"
+	if err := db.SaveAnalysis(context.Background(), plain); err != nil {
+		t.Fatalf("Failed to update analysis: %v", err)
 	}
 
-	// Verify tables exist using PostgreSQL information_schema
-	var count int
-	err = db.conn.QueryRow("SELECT COUNT(*) FROM information_schema.tables WHERE table_schema='public' AND table_name='analyses'").Scan(&count)
+	withHTML, err := db.GetAnalysisWithHTML(context.Background(), "test-html-001")
 	if err != nil {
-		t.Fatalf("Failed to check analyses table: %v", err)
+		t.Fatalf("Failed to get analysis with HTML: %v", err)
 	}
-	if count != 1 {
-		t.Error("analyses table should exist")
+	if withHTML.OriginalHTML != analysis.OriginalHTML {
+		t.Errorf("Expected original HTML %q, got %q", analysis.OriginalHTML, withHTML.OriginalHTML)
+	}
+	if withHTML.Metadata.Synopsis != "Enriched" {
+		t.Errorf("Expected the updated synopsis, got %q", withHTML.Metadata.Synopsis)
 	}
 
-	err = db.conn.QueryRow("SELECT COUNT(*) FROM information_schema.tables WHERE table_schema='public' AND table_name='tags'").Scan(&count)
+	listed, err := db.ListAnalysesFiltered(context.Background(), 10, 0, ListFilter{})
 	if err != nil {
-		t.Fatalf("Failed to check tags table: %v", err)
+		t.Fatalf("Failed to list analyses: %v", err)
 	}
-	if count != 1 {
-		t.Error("tags table should exist")
+	if len(listed) != 1 || listed[0].OriginalHTML != "" {
+		t.Errorf("Expected one listed analysis without HTML, got %d", len(listed))
 	}
 
-	// Run migrations again (should be idempotent)
-	if err := db.Migrate(); err != nil {
-		t.Fatalf("Failed to run migrations again: %v", err)
+	listed, err = db.ListAnalysesFiltered(context.Background(), 10, 0, ListFilter{IncludeHTML: true})
+	if err != nil {
+		t.Fatalf("Failed to list analyses: %v", err)
+	}
+	if len(listed) != 1 || listed[0].OriginalHTML != analysis.OriginalHTML {
+		t.Errorf("Expected one listed analysis with its HTML, got %d", len(listed))
+	}
+
+	// Redacting the text drops the HTML with it
+	withHTML.Metadata.Redaction = &models.TextRedaction{TextSHA256: "abc"}
+	if err := db.SaveAnalysis(context.Background(), withHTML); err != nil {
+		t.Fatalf("Failed to save redacted analysis: %v", err)
+	}
+	redacted, err := db.GetAnalysisWithHTML(context.Background(), "test-html-001")
+	if err != nil {
+		t.Fatalf("Failed to get analysis with HTML: %v", err)
+	}
+	if redacted.OriginalHTML != "" {
+		t.Errorf("Expected redaction to drop the original HTML, got %q", redacted.OriginalHTML)
 	}
 }
 
-func TestCascadeDelete(t *testing.T) {
+func TestListAnalysesWithoutText(t *testing.T) {
 	db, cleanup := setupTestDatabase(t)
 	defer cleanup()
 
-	analysis := createTestAnalysis("test-cascade-001")
-
-	if err := db.SaveAnalysis(analysis); err != nil {
+	analysis := createTestAnalysis("test-projection-001")
+	analysis.Text = "Carbon capture cuts emissions at the pilot plant."
+	analysis.Metadata.CleanedText = "Carbon capture cuts emissions."
+	analysis.Metadata.HeuristicCleanedText = "Carbon capture cuts emissions at the plant."
+	if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
 		t.Fatalf("Failed to save analysis: %v", err)
 	}
 
-	// Verify tags exist (using PostgreSQL placeholder $1)
-	var tagCount int
-	err := db.conn.QueryRow("SELECT COUNT(*) FROM textanalyzer_tags WHERE analysis_id = $1", "test-cascade-001").Scan(&tagCount)
+	assertTrimmed := func(source string, analyses []*models.Analysis) {
+		t.Helper()
+		if len(analyses) != 1 {
+			t.Fatalf("Expected one analysis from %s, got %d", source, len(analyses))
+		}
+		got := analyses[0]
+		if got.Text != "" || got.Metadata.CleanedText != "" || got.Metadata.HeuristicCleanedText != "" {
+			t.Errorf("Expected %s to leave out the texts, got %q, %q and %q", source, got.Text, got.Metadata.CleanedText, got.Metadata.HeuristicCleanedText)
+		}
+		if got.Metadata.WordCount != analysis.Metadata.WordCount || len(got.Metadata.Tags) != len(analysis.Metadata.Tags) {
+			t.Errorf("Expected %s to keep the rest of the metadata, got %+v", source, got.Metadata)
+		}
+	}
+
+	listed, err := db.ListAnalysesFiltered(context.Background(), 10, 0, ListFilter{})
 	if err != nil {
-		t.Fatalf("Failed to count tags: %v", err)
+		t.Fatalf("Failed to list analyses: %v", err)
 	}
+	assertTrimmed("the listing", listed)
 
-	if tagCount != len(analysis.Metadata.Tags) {
-		t.Errorf("Expected %d tags, got %d", len(analysis.Metadata.Tags), tagCount)
+	tagged, err := db.GetAnalysesByTag(context.Background(), "short", false)
+	if err != nil {
+		t.Fatalf("Failed to get analyses by tag: %v", err)
 	}
+	assertTrimmed("the tag search", tagged)
 
-	// Delete the analysis
-	if err := db.DeleteAnalysis("test-cascade-001"); err != nil {
-		t.Fatalf("Failed to delete analysis: %v", err)
+	matches, err := db.SearchAnalyses(context.Background(), "carbon capture", 10, 0, false)
+	if err != nil {
+		t.Fatalf("Failed to search analyses: %v", err)
 	}
+	if len(matches) != 1 || !strings.Contains(matches[0].Headline, "<mark>") {
+		t.Fatalf("Expected one match with a headline built from the text, got %+v", matches)
+	}
+	assertTrimmed("the text search", []*models.Analysis{matches[0].Analysis})
 
-	// Verify tags are deleted (using PostgreSQL placeholder $1)
-	err = db.conn.QueryRow("SELECT COUNT(*) FROM textanalyzer_tags WHERE analysis_id = $1", "test-cascade-001").Scan(&tagCount)
+	listed, err = db.ListAnalysesFiltered(context.Background(), 10, 0, ListFilter{IncludeText: true})
 	if err != nil {
-		t.Fatalf("Failed to count tags after delete: %v", err)
+		t.Fatalf("Failed to list analyses: %v", err)
+	}
+	if len(listed) != 1 || listed[0].Text != analysis.Text || listed[0].Metadata.CleanedText != analysis.Metadata.CleanedText ||
+		listed[0].Metadata.HeuristicCleanedText != analysis.Metadata.HeuristicCleanedText {
+		t.Errorf("Expected the listing to include the texts, got %+v", listed)
 	}
 
-	if tagCount != 0 {
-		t.Errorf("Expected 0 tags after delete, got %d", tagCount)
+	// The stored analysis keeps its texts
+	stored, err := db.GetAnalysis(context.Background(), "test-projection-001")
+	if err != nil {
+		t.Fatalf("Failed to get analysis: %v", err)
+	}
+	if stored.Text != analysis.Text || stored.Metadata.CleanedText != analysis.Metadata.CleanedText {
+		t.Errorf("Expected GetAnalysis to return the texts, got %q and %q", stored.Text, stored.Metadata.CleanedText)
+	}
+}
+
+func TestGetAnalysesByReference(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	analysis := createTestAnalysis("test-ref-001")
+	analysis.Metadata.References = []models.Reference{
+		{Text: "Studies show that 75% of users prefer it", Type: "statistic", Context: "Studies show", Confidence: "medium"},
+	}
+	if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+		t.Fatalf("Failed to save analysis: %v", err)
+	}
+	analysis = createTestAnalysis("test-ref-002")
+	analysis.Metadata.References = []models.Reference{
+		{Text: "Experts claim 75 users prefer it", Type: "claim", Context: "Experts claim", Confidence: "low"},
+	}
+	if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+		t.Fatalf("Failed to save analysis: %v", err)
+	}
+	if err := db.SaveAnalysis(context.Background(), createTestAnalysis("test-ref-003")); err != nil {
+		t.Fatalf("Failed to save analysis: %v", err)
+	}
+
+	tests := []struct {
+		reference string
+		refType   string
+		want      []string
+	}{
+		{"75% of users", "", []string{"test-ref-001"}},
+		// LIKE wildcards in the reference match only themselves
+		{"75%", "", []string{"test-ref-001"}},
+		{"5_", "", []string{}},
+		{"prefer it", "", []string{"test-ref-001", "test-ref-002"}},
+		{"prefer it", "claim", []string{"test-ref-002"}},
+		{"prefer it", "quote", []string{}},
+	}
+	for _, tt := range tests {
+		analyses, err := db.GetAnalysesByReference(context.Background(), tt.reference, tt.refType, 10, 0, true)
+		if err != nil {
+			t.Fatalf("Failed to get analyses by reference: %v", err)
+		}
+		ids := []string{}
+		for _, a := range analyses {
+			ids = append(ids, a.ID)
+		}
+		sort.Strings(ids)
+		if !reflect.DeepEqual(ids, tt.want) {
+			t.Errorf("GetAnalysesByReference(%q, %q) = %v, want %v", tt.reference, tt.refType, ids, tt.want)
+		}
+		count, err := db.CountAnalysesByReference(context.Background(), tt.reference, tt.refType)
+		if err != nil {
+			t.Fatalf("Failed to count analyses by reference: %v", err)
+		}
+		if count != len(tt.want) {
+			t.Errorf("CountAnalysesByReference(%q, %q) = %d, want %d", tt.reference, tt.refType, count, len(tt.want))
+		}
+	}
+
+	analyses, err := db.GetAnalysesByReference(context.Background(), "prefer it", "", 1, 1, false)
+	if err != nil {
+		t.Fatalf("Failed to get analyses by reference: %v", err)
+	}
+	if len(analyses) != 1 || analyses[0].Text != "" {
+		t.Errorf("Expected the second match without its text, got %d analyses", len(analyses))
+	}
+}
+
+func TestGetAnalysesByEntity(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	// test-entity-004 was saved before entities were classified
+	corpus := map[string][]models.NamedEntity{
+		"test-entity-001": {{Text: "Paris", Type: models.EntityLocation, Count: 2}, {Text: "Anne Hidalgo", Type: models.EntityPerson, Count: 1}},
+		"test-entity-002": {{Text: "Paris Hilton", Type: models.EntityPerson, Count: 1}},
+		"test-entity-003": {{Text: "Paris", Type: models.EntityOther, Count: 1}, {Text: "Berlin", Type: models.EntityLocation, Count: 1}},
+		"test-entity-004": nil,
+	}
+	for id, entities := range corpus {
+		analysis := createTestAnalysis(id)
+		analysis.Metadata.Entities = entities
+		analysis.Metadata.NamedEntities = []string{}
+		for _, entity := range entities {
+			analysis.Metadata.NamedEntities = append(analysis.Metadata.NamedEntities, entity.Text)
+		}
+		if entities == nil {
+			analysis.Metadata.NamedEntities = []string{"Paris"}
+		}
+		if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+			t.Fatalf("Failed to save analysis %s: %v", id, err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		entityType string
+		want       []string
+	}{
+		{"Paris", "", []string{"test-entity-001", "test-entity-003", "test-entity-004"}},
+		{"Paris", models.EntityLocation, []string{"test-entity-001"}},
+		{"Paris", models.EntityPerson, []string{}},
+		{"Paris Hilton", "", []string{"test-entity-002"}},
+		{"Paris Hilton", models.EntityPerson, []string{"test-entity-002"}},
+		{"paris", "", []string{}},
+		{`"Paris"`, "", []string{}},
+	}
+	for _, tt := range tests {
+		analyses, err := db.GetAnalysesByEntity(context.Background(), tt.name, tt.entityType, 10, 0, false)
+		if err != nil {
+			t.Fatalf("Failed to get analyses by entity: %v", err)
+		}
+		ids := []string{}
+		for _, a := range analyses {
+			ids = append(ids, a.ID)
+		}
+		sort.Strings(ids)
+		if !reflect.DeepEqual(ids, tt.want) {
+			t.Errorf("GetAnalysesByEntity(%q, %q) = %v, want %v", tt.name, tt.entityType, ids, tt.want)
+		}
+		count, err := db.CountAnalysesByEntity(context.Background(), tt.name, tt.entityType)
+		if err != nil {
+			t.Fatalf("Failed to count analyses by entity: %v", err)
+		}
+		if count != len(tt.want) {
+			t.Errorf("CountAnalysesByEntity(%q, %q) = %d, want %d", tt.name, tt.entityType, count, len(tt.want))
+		}
+	}
+}
+
+func TestDeleteAnalysis(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	analysis := createTestAnalysis("test-delete-001")
+
+	if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+		t.Fatalf("Failed to save analysis: %v", err)
+	}
+
+	// Delete the analysis
+	err := db.DeleteAnalysis(context.Background(), "test-delete-001")
+	if err != nil {
+		t.Fatalf("Failed to delete analysis: %v", err)
+	}
+
+	// Verify it's deleted
+	_, err = db.GetAnalysis(context.Background(), "test-delete-001")
+	if err == nil {
+		t.Error("Expected error when getting deleted analysis")
+	}
+}
+
+func TestDeleteAnalysisNotFound(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	err := db.DeleteAnalysis(context.Background(), "nonexistent")
+	if err == nil {
+		t.Error("Expected error when deleting nonexistent analysis")
+	}
+
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	connStr, dbCleanup := setupTestDB(t, "test_migrations")
+	defer dbCleanup()
+
+	db, err := New(connStr)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	// Run migrations
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Failed to run migrations: %v", err)
+	}
+
+	// Verify tables exist using PostgreSQL information_schema
+	var count int
+	err = db.conn.QueryRow("SELECT COUNT(*) FROM information_schema.tables WHERE table_schema='public' AND table_name='analyses'").Scan(&count)
+	if err != nil {
+		t.Fatalf("Failed to check analyses table: %v", err)
+	}
+	if count != 1 {
+		t.Error("analyses table should exist")
+	}
+
+	err = db.conn.QueryRow("SELECT COUNT(*) FROM information_schema.tables WHERE table_schema='public' AND table_name='tags'").Scan(&count)
+	if err != nil {
+		t.Fatalf("Failed to check tags table: %v", err)
+	}
+	if count != 1 {
+		t.Error("tags table should exist")
+	}
+
+	// Run migrations again (should be idempotent)
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Failed to run migrations again: %v", err)
+	}
+}
+
+func TestMigrateConcurrently(t *testing.T) {
+	connStr, dbCleanup := setupTestDB(t, "test_migrate_concurrently")
+	defer dbCleanup()
+
+	// Each instance has its own connection pool, as separate replicas do
+	var instances []*DB
+	for range 2 {
+		db, err := New(connStr)
+		if err != nil {
+			t.Fatalf("Failed to create database: %v", err)
+		}
+		defer db.Close()
+		instances = append(instances, db)
+	}
+
+	errs := make(chan error, len(instances))
+	for _, db := range instances {
+		go func() { errs <- db.Migrate() }()
+	}
+	for range instances {
+		if err := <-errs; err != nil {
+			t.Errorf("Concurrent migration failed: %v", err)
+		}
+	}
+
+	var recorded, distinct int
+	err := instances[0].conn.QueryRow("SELECT COUNT(*), COUNT(DISTINCT version) FROM textanalyzer_schema_version").Scan(&recorded, &distinct)
+	if err != nil {
+		t.Fatalf("Failed to count schema versions: %v", err)
+	}
+	if recorded != len(migrations) || distinct != len(migrations) {
+		t.Errorf("Expected %d versions recorded once each, got %d rows of %d versions", len(migrations), recorded, distinct)
+	}
+}
+
+func TestMigrateReappliesUnrecordedVersions(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	// A schema whose versions were lost after its migrations ran: every
+	// migration is applied again over the existing tables
+	if _, err := db.conn.Exec("DELETE FROM textanalyzer_schema_version"); err != nil {
+		t.Fatalf("Failed to clear schema versions: %v", err)
+	}
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Failed to reapply migrations: %v", err)
+	}
+
+	// A gap in the recorded versions is filled in
+	if _, err := db.conn.Exec("DELETE FROM textanalyzer_schema_version WHERE version = $1", migrations[len(migrations)/2].Version); err != nil {
+		t.Fatalf("Failed to delete schema version: %v", err)
+	}
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Failed to reapply migration: %v", err)
+	}
+
+	var recorded int
+	if err := db.conn.QueryRow("SELECT COUNT(*) FROM textanalyzer_schema_version").Scan(&recorded); err != nil {
+		t.Fatalf("Failed to count schema versions: %v", err)
+	}
+	if recorded != len(migrations) {
+		t.Errorf("Expected %d versions recorded, got %d", len(migrations), recorded)
+	}
+}
+
+func TestMigrateTo(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	columnExists := func(column string) bool {
+		t.Helper()
+		var exists bool
+		err := db.conn.QueryRow(`
+			SELECT EXISTS (
+				SELECT 1 FROM information_schema.columns
+				WHERE table_name = 'textanalyzer_analyses' AND column_name = $1
+			)
+		`, column).Scan(&exists)
+		if err != nil {
+			t.Fatalf("Failed to check column %s: %v", column, err)
+		}
+		return exists
+	}
+	recordedVersion := func() int {
+		t.Helper()
+		var version int
+		if err := db.conn.QueryRow("SELECT COALESCE(MAX(version), 0) FROM textanalyzer_schema_version").Scan(&version); err != nil {
+			t.Fatalf("Failed to get schema version: %v", err)
+		}
+		return version
+	}
+
+	// Version 16 adds search_vector, 17 text_hash and 23 deleted_at
+	if err := db.MigrateTo(16); err != nil {
+		t.Fatalf("Failed to migrate down: %v", err)
+	}
+	if v := recordedVersion(); v != 16 {
+		t.Errorf("Expected schema version 16, got %d", v)
+	}
+	if !columnExists("search_vector") || columnExists("text_hash") || columnExists("deleted_at") {
+		t.Error("Expected only the columns of migrations up to 16")
+	}
+
+	if err := db.MigrateTo(LatestVersion()); err != nil {
+		t.Fatalf("Failed to migrate up: %v", err)
+	}
+	if v := recordedVersion(); v != LatestVersion() {
+		t.Errorf("Expected schema version %d, got %d", LatestVersion(), v)
+	}
+	if !columnExists("text_hash") || !columnExists("deleted_at") {
+		t.Error("Expected the reapplied columns")
+	}
+	if err := db.SaveAnalysis(context.Background(), createTestAnalysis("test-migrate-to-001")); err != nil {
+		t.Errorf("Failed to save analysis after migrating up: %v", err)
+	}
+
+	// An irreversible migration blocks the rollback before anything is reverted
+	err := db.MigrateTo(2)
+	if err == nil || !strings.Contains(err.Error(), "3 (create_schema_version_table)") {
+		t.Errorf("Expected the irreversible migration named, got %v", err)
+	}
+	if v := recordedVersion(); v != LatestVersion() {
+		t.Errorf("Expected the blocked rollback to keep version %d, got %d", LatestVersion(), v)
+	}
+
+	for _, version := range []int{-1, LatestVersion() + 1} {
+		if err := db.MigrateTo(version); err == nil {
+			t.Errorf("Expected error migrating to unknown version %d", version)
+		}
+	}
+}
+
+func TestMigrateLanguageNamesToCodes(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	analysis := createTestAnalysis("test-language-codes-001")
+	if err := db.SaveAnalysis(ctx, analysis); err != nil {
+		t.Fatalf("Failed to save analysis: %v", err)
+	}
+	language := func() string {
+		t.Helper()
+		var language string
+		if err := db.conn.QueryRow("SELECT metadata->>'language' FROM textanalyzer_analyses WHERE id = $1", analysis.ID).Scan(&language); err != nil {
+			t.Fatalf("Failed to get language: %v", err)
+		}
+		return language
+	}
+
+	if err := db.MigrateTo(29); err != nil {
+		t.Fatalf("Failed to migrate down: %v", err)
+	}
+	if got := language(); got != "english" {
+		t.Errorf("Expected the rollback to restore the name, got %q", got)
+	}
+	if err := db.MigrateTo(LatestVersion()); err != nil {
+		t.Fatalf("Failed to migrate up: %v", err)
+	}
+	if got := language(); got != "en" {
+		t.Errorf("Expected language code en, got %q", got)
+	}
+}
+
+func TestMigrationVersions(t *testing.T) {
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			t.Errorf("Expected migration %s to have version %d, got %d", migration.Name, i+1, migration.Version)
+		}
+		// Only the schema version table cannot be reverted
+		if reversible := migration.DownSQL != ""; reversible != (migration.Version != 3) {
+			t.Errorf("Migration %d (%s) reversible = %v", migration.Version, migration.Name, reversible)
+		}
+	}
+	if LatestVersion() != len(migrations) {
+		t.Errorf("Expected latest version %d, got %d", len(migrations), LatestVersion())
+	}
+}
+
+func TestCascadeDelete(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	analysis := createTestAnalysis("test-cascade-001")
+
+	if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+		t.Fatalf("Failed to save analysis: %v", err)
+	}
+
+	// Verify tags exist (using PostgreSQL placeholder $1)
+	var tagCount int
+	err := db.conn.QueryRow("SELECT COUNT(*) FROM textanalyzer_tags WHERE analysis_id = $1", "test-cascade-001").Scan(&tagCount)
+	if err != nil {
+		t.Fatalf("Failed to count tags: %v", err)
+	}
+
+	if tagCount != len(analysis.Metadata.Tags) {
+		t.Errorf("Expected %d tags, got %d", len(analysis.Metadata.Tags), tagCount)
+	}
+
+	// Soft-deleting keeps the tags attached
+	if err := db.DeleteAnalysis(context.Background(), "test-cascade-001"); err != nil {
+		t.Fatalf("Failed to delete analysis: %v", err)
+	}
+	err = db.conn.QueryRow("SELECT COUNT(*) FROM textanalyzer_tags WHERE analysis_id = $1", "test-cascade-001").Scan(&tagCount)
+	if err != nil {
+		t.Fatalf("Failed to count tags after soft delete: %v", err)
+	}
+	if tagCount != len(analysis.Metadata.Tags) {
+		t.Errorf("Expected %d tags after soft delete, got %d", len(analysis.Metadata.Tags), tagCount)
+	}
+
+	// Hard-delete the analysis
+	if err := db.HardDeleteAnalysis(context.Background(), "test-cascade-001"); err != nil {
+		t.Fatalf("Failed to hard-delete analysis: %v", err)
+	}
+
+	// Verify tags are deleted (using PostgreSQL placeholder $1)
+	err = db.conn.QueryRow("SELECT COUNT(*) FROM textanalyzer_tags WHERE analysis_id = $1", "test-cascade-001").Scan(&tagCount)
+	if err != nil {
+		t.Fatalf("Failed to count tags after delete: %v", err)
+	}
+
+	if tagCount != 0 {
+		t.Errorf("Expected 0 tags after delete, got %d", tagCount)
+	}
+
+	if err := db.HardDeleteAnalysis(context.Background(), "test-cascade-001"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound hard-deleting a deleted analysis, got %v", err)
+	}
+}
+
+func TestSoftDeleteRestore(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	analysis := createTestAnalysis("test-soft-delete-001")
+	analysis.Text = "The committee met in Geneva to discuss renewable subsidies."
+	analysis.Metadata.Tags = []string{"renewables", "short"}
+	analysis.Metadata.NamedEntities = []string{"Geneva"}
+	analysis.Metadata.References = []models.Reference{
+		{Text: "Subsidies rose 12%", Type: "statistic", Context: "Subsidies rose 12%", Confidence: "high"},
+	}
+	if err := db.SaveAnalysis(ctx, analysis); err != nil {
+		t.Fatalf("Failed to save analysis: %v", err)
+	}
+	if err := db.SaveAnalysis(ctx, createTestAnalysis("test-soft-delete-002")); err != nil {
+		t.Fatalf("Failed to save analysis: %v", err)
+	}
+
+	// visible reports whether every read finds the analysis, failing when
+	// only some of them do
+	visible := func() bool {
+		t.Helper()
+		found := map[string]bool{}
+
+		_, err := db.GetAnalysis(ctx, analysis.ID)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Failed to get analysis: %v", err)
+		}
+		found["GetAnalysis"] = err == nil
+
+		listed, err := db.ListAnalyses(ctx, 10, 0)
+		if err != nil {
+			t.Fatalf("Failed to list analyses: %v", err)
+		}
+		found["ListAnalyses"] = len(listed) == 2
+		count, err := db.CountAnalyses(ctx)
+		if err != nil {
+			t.Fatalf("Failed to count analyses: %v", err)
+		}
+		found["CountAnalyses"] = count == 2
+
+		tagged, err := db.GetAnalysesByTag(ctx, "renewables", false)
+		if err != nil {
+			t.Fatalf("Failed to get analyses by tag: %v", err)
+		}
+		found["GetAnalysesByTag"] = len(tagged) == 1
+		tagCounts, err := db.ListTags(ctx, "renew", 10)
+		if err != nil {
+			t.Fatalf("Failed to list tags: %v", err)
+		}
+		found["ListTags"] = len(tagCounts) == 1
+
+		byReference, err := db.GetAnalysesByReference(ctx, "Subsidies", "", 10, 0, false)
+		if err != nil {
+			t.Fatalf("Failed to get analyses by reference: %v", err)
+		}
+		found["GetAnalysesByReference"] = len(byReference) == 1
+		byEntity, err := db.GetAnalysesByEntity(ctx, "Geneva", "", 10, 0, false)
+		if err != nil {
+			t.Fatalf("Failed to get analyses by entity: %v", err)
+		}
+		found["GetAnalysesByEntity"] = len(byEntity) == 1
+		matches, err := db.SearchAnalyses(ctx, "renewable subsidies", 10, 0, false)
+		if err != nil {
+			t.Fatalf("Failed to search analyses: %v", err)
+		}
+		found["SearchAnalyses"] = len(matches) == 1
+
+		stats, err := db.CorpusStats(ctx, time.Time{})
+		if err != nil {
+			t.Fatalf("Failed to get corpus stats: %v", err)
+		}
+		found["CorpusStats"] = stats.TotalAnalyses == 2
+
+		for read, ok := range found {
+			if ok != found["GetAnalysis"] {
+				t.Errorf("Expected %s to agree with GetAnalysis (%v), got %v", read, found["GetAnalysis"], ok)
+			}
+		}
+		return found["GetAnalysis"]
+	}
+
+	if !visible() {
+		t.Fatal("Expected the saved analysis to be visible")
+	}
+
+	if err := db.DeleteAnalysis(ctx, analysis.ID); err != nil {
+		t.Fatalf("Failed to delete analysis: %v", err)
+	}
+	if visible() {
+		t.Error("Expected the deleted analysis to be invisible")
+	}
+	if err := db.DeleteAnalysis(ctx, analysis.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting a deleted analysis, got %v", err)
+	}
+
+	if err := db.RestoreAnalysis(ctx, analysis.ID); err != nil {
+		t.Fatalf("Failed to restore analysis: %v", err)
+	}
+	if !visible() {
+		t.Error("Expected the restored analysis to be visible")
+	}
+	restored, err := db.GetAnalysis(ctx, analysis.ID)
+	if err != nil {
+		t.Fatalf("Failed to get restored analysis: %v", err)
+	}
+	if !reflect.DeepEqual(restored.Metadata.Tags, analysis.Metadata.Tags) {
+		t.Errorf("Expected restored tags %v, got %v", analysis.Metadata.Tags, restored.Metadata.Tags)
+	}
+
+	// Restoring is idempotent, but only for stored analyses
+	if err := db.RestoreAnalysis(ctx, analysis.ID); err != nil {
+		t.Errorf("Expected restoring a visible analysis to succeed, got %v", err)
+	}
+	if err := db.RestoreAnalysis(ctx, "nonexistent"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound restoring a missing analysis, got %v", err)
+	}
+}
+
+func TestSoftDeleteResubmit(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+	ctx := context.Background()
+	hash := TextHash("This is a test text for analysis.")
+
+	// submit saves an analysis of the text unless one is found by its hash,
+	// as the analyze endpoint and offline processing do, returning the match
+	submit := func(id string) string {
+		t.Helper()
+		if found, err := db.GetAnalysisByTextHash(ctx, hash); err == nil {
+			return found.ID
+		} else if !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Failed to get analysis by text hash: %v", err)
+		}
+		analysis := createTestAnalysis(id)
+		analysis.TextHash = hash
+		if err := db.SaveAnalysis(ctx, analysis); err != nil {
+			t.Fatalf("Failed to save analysis: %v", err)
+		}
+		return id
+	}
+
+	if got := submit("resubmit-1"); got != "resubmit-1" {
+		t.Fatalf("Expected a new analysis, got %s", got)
+	}
+	if err := db.DeleteAnalysis(ctx, "resubmit-1"); err != nil {
+		t.Fatalf("Failed to delete analysis: %v", err)
+	}
+
+	// The resubmitted text is analyzed again and claims the hash, so the
+	// next submission is deduplicated against it
+	if got := submit("resubmit-2"); got != "resubmit-2" {
+		t.Fatalf("Expected the deleted text to be analyzed again, got %s", got)
+	}
+	if got := submit("resubmit-3"); got != "resubmit-2" {
+		t.Errorf("Expected the second resubmission deduplicated against resubmit-2, got %s", got)
+	}
+
+	// Restoring the deleted analysis leaves the hash with the new one
+	if err := db.RestoreAnalysis(ctx, "resubmit-1"); err != nil {
+		t.Fatalf("Failed to restore analysis: %v", err)
+	}
+	if restored, err := db.GetAnalysis(ctx, "resubmit-1"); err != nil || restored.TextHash != "" {
+		t.Errorf("Expected resubmit-1 restored without its hash, got %v, %v", restored, err)
+	}
+	if got := submit("resubmit-4"); got != "resubmit-2" {
+		t.Errorf("Expected submissions still deduplicated against resubmit-2, got %s", got)
+	}
+}
+
+func TestAnalysisRevisions(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	analysis := createTestAnalysis("test-revisions-001")
+	if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+		t.Fatalf("Failed to save analysis: %v", err)
+	}
+
+	for i := 1; i <= maxRevisionsPerAnalysis+3; i++ {
+		revision := &models.AnalysisRevision{
+			AnalysisID: analysis.ID,
+			Fields: models.RevisionFields{
+				Synopsis: fmt.Sprintf("Synopsis %d", i),
+				Tags:     []string{"tag"},
+			},
+			Model: "model-a",
+		}
+		if err := db.SaveAnalysisRevision(context.Background(), revision); err != nil {
+			t.Fatalf("Failed to save revision %d: %v", i, err)
+		}
+		if revision.Revision != i {
+			t.Errorf("Expected revision number %d, got %d", i, revision.Revision)
+		}
+	}
+
+	// Oldest revisions are pruned beyond the cap
+	revisions, err := db.ListAnalysisRevisions(context.Background(), analysis.ID)
+	if err != nil {
+		t.Fatalf("Failed to list revisions: %v", err)
+	}
+	if len(revisions) != maxRevisionsPerAnalysis {
+		t.Fatalf("Expected %d revisions, got %d", maxRevisionsPerAnalysis, len(revisions))
+	}
+	if revisions[0].Revision != 4 {
+		t.Errorf("Expected oldest kept revision to be 4, got %d", revisions[0].Revision)
+	}
+
+	revision, err := db.GetAnalysisRevision(context.Background(), analysis.ID, 5)
+	if err != nil {
+		t.Fatalf("Failed to get revision: %v", err)
+	}
+	if revision.Fields.Synopsis != "Synopsis 5" || revision.Model != "model-a" {
+		t.Errorf("Unexpected revision: %+v", revision)
+	}
+
+	if _, err := db.GetAnalysisRevision(context.Background(), analysis.ID, 1); !errors.Is(err, ErrRevisionNotFound) {
+		t.Error("Expected pruned revision to be gone")
+	}
+	if err := db.SaveAnalysisRevision(context.Background(), &models.AnalysisRevision{AnalysisID: "missing"}); err == nil {
+		t.Error("Expected error saving a revision for a missing analysis")
+	}
+
+	// Revisions are removed with their analysis
+	if err := db.HardDeleteAnalysis(context.Background(), analysis.ID); err != nil {
+		t.Fatalf("Failed to delete analysis: %v", err)
+	}
+	revisions, err = db.ListAnalysisRevisions(context.Background(), analysis.ID)
+	if err != nil {
+		t.Fatalf("Failed to list revisions: %v", err)
+	}
+	if len(revisions) != 0 {
+		t.Errorf("Expected revisions to cascade delete, got %d", len(revisions))
+	}
+}
+
+// TestSaveAnalysisWithRevision tests that a failed save leaves no revision
+// behind, so the retried save stores exactly one
+func TestSaveAnalysisWithRevision(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	analysis := createTestAnalysis("test-revision-retry-001")
+	if err := db.SaveAnalysis(ctx, analysis); err != nil {
+		t.Fatalf("Failed to save analysis: %v", err)
+	}
+
+	enriched := func(text string) (*models.Analysis, *models.AnalysisRevision) {
+		enriched := createTestAnalysis(analysis.ID)
+		enriched.Text = text
+		enriched.Metadata.Synopsis = "New synopsis"
+		return enriched, &models.AnalysisRevision{
+			AnalysisID: analysis.ID,
+			Fields:     models.RevisionFields{Synopsis: "Old synopsis"},
+			Model:      "model-a",
+		}
+	}
+
+	// PostgreSQL rejects NUL bytes in text, failing the save after the
+	// revision is inserted
+	failing, revision := enriched("bad \x00 text")
+	if err := db.SaveAnalysisWithRevision(ctx, failing, revision); err == nil {
+		t.Fatal("Expected error saving text with a NUL byte")
+	}
+	if revision.Revision != 0 {
+		t.Errorf("Expected no revision number after a failed save, got %d", revision.Revision)
+	}
+
+	retried, revision := enriched(analysis.Text)
+	if err := db.SaveAnalysisWithRevision(ctx, retried, revision); err != nil {
+		t.Fatalf("Failed to save analysis with revision: %v", err)
+	}
+	if revision.Revision != 1 {
+		t.Errorf("Expected revision number 1, got %d", revision.Revision)
+	}
+
+	revisions, err := db.ListAnalysisRevisions(ctx, analysis.ID)
+	if err != nil {
+		t.Fatalf("Failed to list revisions: %v", err)
+	}
+	if len(revisions) != 1 {
+		t.Fatalf("Expected 1 revision after the retry, got %d", len(revisions))
+	}
+	if revisions[0].Fields.Synopsis != "Old synopsis" {
+		t.Errorf("Unexpected revision: %+v", revisions[0])
+	}
+
+	saved, err := db.GetAnalysis(ctx, analysis.ID)
+	if err != nil {
+		t.Fatalf("Failed to get analysis: %v", err)
+	}
+	if saved.Metadata.Synopsis != "New synopsis" {
+		t.Errorf("Expected the enriched synopsis to be saved, got %q", saved.Metadata.Synopsis)
+	}
+}
+
+// TestGetPreviousAnalysisBySourceURL tests that the latest earlier analysis
+// of a page is found with its text hash, ignoring anything saved after the
+// analysis looking it up
+func TestGetPreviousAnalysisBySourceURL(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	const sourceURL = "https://example.com/story"
+	created := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for i, text := range []string{"First  version.", "Second version."} {
+		analysis := createTestAnalysis(fmt.Sprintf("test-previous-%d", i+1))
+		analysis.Text = text
+		analysis.SourceURL = sourceURL
+		analysis.CreatedAt = created.Add(time.Duration(i) * time.Minute)
+		analysis.UpdatedAt = analysis.CreatedAt
+		if i == 1 {
+			analysis.TextHash = TextHash(text)
+		}
+		if err := db.SaveAnalysis(ctx, analysis); err != nil {
+			t.Fatalf("Failed to save analysis: %v", err)
+		}
+	}
+
+	if _, err := db.GetPreviousAnalysisBySourceURL(ctx, sourceURL, "test-previous-1", time.Now()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound before the first analysis, got %v", err)
+	}
+
+	// An analysis saved by an earlier attempt is compared with what preceded it
+	previous, err := db.GetPreviousAnalysisBySourceURL(ctx, sourceURL, "test-previous-2", time.Now())
+	if err != nil {
+		t.Fatalf("Failed to get previous analysis: %v", err)
+	}
+	if previous.ID != "test-previous-1" || previous.TextHash != TextHash("First version.") {
+		t.Errorf("Expected test-previous-1 hashed from its text, got %+v", previous)
+	}
+
+	previous, err = db.GetPreviousAnalysisBySourceURL(ctx, sourceURL, "test-previous-3", time.Now())
+	if err != nil {
+		t.Fatalf("Failed to get previous analysis: %v", err)
+	}
+	if previous.ID != "test-previous-2" || previous.TextHash != TextHash("Second version.") {
+		t.Errorf("Expected test-previous-2 with its stored hash, got %+v", previous)
+	}
+}
+
+func TestAnalysisImages(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	analysis := createTestAnalysis("test-images-001")
+	if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+		t.Fatalf("Failed to save analysis: %v", err)
+	}
+
+	images := []*models.ImageMetadata{
+		{AnalysisID: analysis.ID, ImageIndex: 1, URL: "https://cdn.example.com/b.png", Domain: "cdn.example.com", Format: "png"},
+		{AnalysisID: analysis.ID, ImageIndex: 0, URL: "https://cdn.example.com/a.jpg", Domain: "cdn.example.com", Format: "jpeg",
+			Width: 640, Height: 480, Fetched: true, StatusCode: 200, AICaption: "A transit map", AITags: []string{"map", "transit"}},
+	}
+	for _, image := range images {
+		if err := db.SaveImageMetadata(context.Background(), image); err != nil {
+			t.Fatalf("Failed to save image %d: %v", image.ImageIndex, err)
+		}
+	}
+
+	// Re-probing an image updates it and keeps its caption and tags
+	reprobed := *images[1]
+	reprobed.Width, reprobed.AICaption, reprobed.AITags = 800, "", nil
+	if err := db.SaveImageMetadata(context.Background(), &reprobed); err != nil {
+		t.Fatalf("Failed to update image: %v", err)
+	}
+
+	stored, err := db.GetAnalysisImages(context.Background(), analysis.ID)
+	if err != nil {
+		t.Fatalf("Failed to get images: %v", err)
+	}
+	if len(stored) != 2 {
+		t.Fatalf("Expected 2 images, got %d", len(stored))
+	}
+	if stored[0].ImageIndex != 0 || stored[1].ImageIndex != 1 {
+		t.Errorf("Expected images ordered by index, got %d, %d", stored[0].ImageIndex, stored[1].ImageIndex)
+	}
+	if stored[0].Format != "jpeg" || stored[0].Width != 800 || stored[0].Height != 480 || !stored[0].Fetched {
+		t.Errorf("Unexpected image: %+v", stored[0])
+	}
+	if stored[0].AICaption != "A transit map" {
+		t.Errorf("Expected caption to be kept, got %q", stored[0].AICaption)
+	}
+	if !reflect.DeepEqual(stored[0].AITags, []string{"map", "transit"}) {
+		t.Errorf("Expected tags to be kept, got %v", stored[0].AITags)
+	}
+	if stored[1].AICaption != "" || stored[1].AITags != nil || stored[1].Width != 0 {
+		t.Errorf("Unexpected image: %+v", stored[1])
+	}
+
+	if err := db.SaveImageMetadata(context.Background(), &models.ImageMetadata{AnalysisID: "missing", URL: "https://example.com/x.png"}); err == nil {
+		t.Error("Expected error saving an image for a missing analysis")
+	}
+
+	// Images are removed with their analysis
+	if err := db.HardDeleteAnalysis(context.Background(), analysis.ID); err != nil {
+		t.Fatalf("Failed to delete analysis: %v", err)
+	}
+	stored, err = db.GetAnalysisImages(context.Background(), analysis.ID)
+	if err != nil {
+		t.Fatalf("Failed to get images: %v", err)
+	}
+	if len(stored) != 0 {
+		t.Errorf("Expected images to cascade delete, got %d", len(stored))
+	}
+}
+
+func TestAddAnalysisTags(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	analysis := createTestAnalysis("test-add-tags-001")
+	if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+		t.Fatalf("Failed to save analysis: %v", err)
+	}
+
+	if err := db.AddAnalysisTags(context.Background(), analysis.ID, []string{"transit-map", "easy"}); err != nil {
+		t.Fatalf("Failed to add tags: %v", err)
+	}
+
+	stored, err := db.GetAnalysis(context.Background(), analysis.ID)
+	if err != nil {
+		t.Fatalf("Failed to get analysis: %v", err)
+	}
+	expected := []string{"short", "neutral", "easy", "transit-map"}
+	if !reflect.DeepEqual(stored.Metadata.Tags, expected) {
+		t.Errorf("Expected tags %v, got %v", expected, stored.Metadata.Tags)
+	}
+
+	tagged, err := db.GetAnalysesByTag(context.Background(), "transit-map", false)
+	if err != nil {
+		t.Fatalf("Failed to get analyses by tag: %v", err)
+	}
+	if len(tagged) != 1 || tagged[0].ID != analysis.ID {
+		t.Errorf("Expected analysis to be indexed under the new tag, got %d analyses", len(tagged))
+	}
+
+	if err := db.AddAnalysisTags(context.Background(), "missing", []string{"map"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a missing analysis, got %v", err)
+	}
+}
+
+func TestAnalysisHistory(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	analysis := createTestAnalysis("test-history-001")
+	analysis.Metadata.CleanedText = "Cleaned text"
+	if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+		t.Fatalf("Failed to save analysis: %v", err)
+	}
+
+	stages := []string{models.HistoryStageOffline, models.HistoryStageEnrichment}
+	for i := 0; i < maxHistoryPerAnalysis+1; i++ {
+		stages = append(stages, models.HistoryStageReenrichment)
+	}
+	for i, stage := range stages {
+		entry := &models.AnalysisHistoryEntry{
+			AnalysisID: analysis.ID,
+			Stage:      stage,
+			TaskID:     fmt.Sprintf("task-%d", i),
+			Metadata:   analysis.Metadata,
+		}
+		if err := db.SaveAnalysisHistory(context.Background(), entry); err != nil {
+			t.Fatalf("Failed to save history entry %d: %v", i, err)
+		}
+		if entry.ID == 0 {
+			t.Errorf("Expected entry %d to get an ID", i)
+		}
+	}
+
+	// Oldest entries are pruned beyond the cap
+	history, err := db.ListAnalysisHistory(context.Background(), analysis.ID)
+	if err != nil {
+		t.Fatalf("Failed to list history: %v", err)
+	}
+	if len(history) != maxHistoryPerAnalysis {
+		t.Fatalf("Expected %d history entries, got %d", maxHistoryPerAnalysis, len(history))
+	}
+	if history[0].TaskID != "task-3" {
+		t.Errorf("Expected oldest kept entry to be task-3, got %s", history[0].TaskID)
+	}
+	last := history[len(history)-1]
+	if last.Stage != models.HistoryStageReenrichment || last.Metadata.Synopsis != analysis.Metadata.Synopsis {
+		t.Errorf("Unexpected history entry: %+v", last)
+	}
+	if last.Metadata.CleanedText != "" {
+		t.Error("Expected the cleaned text to be left out of the snapshot")
+	}
+
+	if err := db.SaveAnalysisHistory(context.Background(), &models.AnalysisHistoryEntry{AnalysisID: "missing", Stage: models.HistoryStageOffline}); err == nil {
+		t.Error("Expected error saving history for a missing analysis")
+	}
+
+	// History is removed with its analysis
+	if err := db.HardDeleteAnalysis(context.Background(), analysis.ID); err != nil {
+		t.Fatalf("Failed to delete analysis: %v", err)
+	}
+	history, err = db.ListAnalysisHistory(context.Background(), analysis.ID)
+	if err != nil {
+		t.Fatalf("Failed to list history: %v", err)
+	}
+	if len(history) != 0 {
+		t.Errorf("Expected history to cascade delete, got %d", len(history))
+	}
+}
+
+func TestTagCooccurrence(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	corpus := map[string][]string{
+		"test-cooc-001": {"climate-change", "policy", "energy", "positive"},
+		"test-cooc-002": {"climate-change", "policy", "long"},
+		"test-cooc-003": {"climate-change", "energy"},
+		"test-cooc-004": {"policy", "economy"},
+		"test-cooc-005": {"sports", "football"},
+		"test-cooc-006": {"sports"},
+	}
+	for id, analysisTags := range corpus {
+		analysis := createTestAnalysis(id)
+		analysis.Metadata.Tags = analysisTags
+		if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+			t.Fatalf("Failed to save analysis %s: %v", id, err)
+		}
+	}
+
+	related, err := db.TagCooccurrence(context.Background(), "climate-change", 10)
+	if err != nil {
+		t.Fatalf("Failed to get tag co-occurrence: %v", err)
+	}
+
+	// Both co-occur twice; energy is rarer in the corpus so its lift is higher:
+	// energy 2*6/(3*2) = 2.0, policy 2*6/(3*3) = 1.33. Structural tags are excluded.
+	want := []models.RelatedTag{
+		{Tag: "energy", Count: 2, Lift: 2},
+		{Tag: "policy", Count: 2, Lift: 1.33},
+	}
+	if !reflect.DeepEqual(related, want) {
+		t.Errorf("Expected %+v, got %+v", want, related)
+	}
+
+	related, err = db.TagCooccurrence(context.Background(), "climate-change", 1)
+	if err != nil {
+		t.Fatalf("Failed to get tag co-occurrence: %v", err)
+	}
+	if len(related) != 1 || related[0].Tag != "energy" {
+		t.Errorf("Expected only energy with limit 1, got %+v", related)
+	}
+
+	related, err = db.TagCooccurrence(context.Background(), "unknown-tag", 10)
+	if err != nil {
+		t.Fatalf("Failed to get tag co-occurrence: %v", err)
+	}
+	if related == nil || len(related) != 0 {
+		t.Errorf("Expected empty result for unknown tag, got %#v", related)
+	}
+}
+
+func TestWorkerSettings(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	settings, err := db.GetWorkerSettings(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get worker settings: %v", err)
+	}
+	if settings != nil {
+		t.Errorf("Expected no stored settings, got %+v", settings)
+	}
+
+	for _, concurrency := range []int{4, 8} {
+		saved := &models.WorkerSettings{
+			Concurrency:    concurrency,
+			QueueWeights:   map[string]int{"text-enrichment": 7, "offline-processing": 5},
+			StrictPriority: true,
+		}
+		if err := db.SaveWorkerSettings(context.Background(), saved); err != nil {
+			t.Fatalf("Failed to save worker settings: %v", err)
+		}
+
+		settings, err = db.GetWorkerSettings(context.Background())
+		if err != nil {
+			t.Fatalf("Failed to get worker settings: %v", err)
+		}
+		if !reflect.DeepEqual(settings, saved) {
+			t.Errorf("Expected %+v, got %+v", saved, settings)
+		}
+	}
+}
+
+func TestRedactedAnalysis(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	const hash = "8dc1292a569d1968da194087e570b4a28d3212f0ac986c2e350e811ef02189cf"
+	analysis := createTestAnalysis("redacted-1")
+	analysis.Text = ""
+	analysis.Metadata.Redaction = &models.TextRedaction{TextSHA256: hash}
+	if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+		t.Fatalf("Failed to save analysis: %v", err)
+	}
+
+	// The text column only holds the placeholder
+	var stored string
+	if err := db.conn.QueryRow(`SELECT text FROM textanalyzer_analyses WHERE id = $1`, "redacted-1").Scan(&stored); err != nil {
+		t.Fatalf("Failed to read text column: %v", err)
+	}
+	if stored != "[redacted sha256:"+hash+"]" {
+		t.Errorf("Expected a placeholder with the hash, got %q", stored)
+	}
+
+	retrieved, err := db.GetAnalysis(context.Background(), "redacted-1")
+	if err != nil {
+		t.Fatalf("Failed to get analysis: %v", err)
+	}
+	if retrieved.Text != "" {
+		t.Errorf("Expected no text for a redacted analysis, got %q", retrieved.Text)
+	}
+	if retrieved.Metadata.Redaction == nil || retrieved.Metadata.Redaction.TextSHA256 != hash {
+		t.Errorf("Expected the redaction hash to be kept, got %+v", retrieved.Metadata.Redaction)
+	}
+
+	// Derived metadata stays searchable
+	var tagCount int
+	if err := db.conn.QueryRow(`SELECT COUNT(*) FROM textanalyzer_tags WHERE analysis_id = $1`, "redacted-1").Scan(&tagCount); err != nil {
+		t.Fatalf("Failed to count tags: %v", err)
+	}
+	if tagCount != len(analysis.Metadata.Tags) {
+		t.Errorf("Expected %d tags, got %d", len(analysis.Metadata.Tags), tagCount)
+	}
+
+	// Saving the retrieved analysis again, as enrichment does, keeps the placeholder
+	if err := db.SaveAnalysis(context.Background(), retrieved); err != nil {
+		t.Fatalf("Failed to resave analysis: %v", err)
+	}
+	if err := db.conn.QueryRow(`SELECT text FROM textanalyzer_analyses WHERE id = $1`, "redacted-1").Scan(&stored); err != nil {
+		t.Fatalf("Failed to read text column: %v", err)
+	}
+	if stored != "[redacted sha256:"+hash+"]" {
+		t.Errorf("Expected the placeholder to survive a resave, got %q", stored)
+	}
+}
+
+func TestShadowResults(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	analysis := createTestAnalysis("shadow-1")
+	if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+		t.Fatalf("Failed to save analysis: %v", err)
+	}
+
+	result, err := db.GetShadowResult(context.Background(), analysis.ID)
+	if err != nil {
+		t.Fatalf("Failed to get shadow result: %v", err)
+	}
+	if result != nil {
+		t.Errorf("Expected no shadow result, got %+v", result)
+	}
+
+	score, primary, delta := 0.4, 0.7, -0.3
+	saved := &models.ShadowResult{
+		Model:             "candidate",
+		PrimaryModel:      "primary",
+		Tags:              []string{"neutral", "transit"},
+		PrimaryTags:       analysis.Metadata.Tags,
+		TagJaccard:        0.5,
+		QualityScore:      &score,
+		PrimaryQuality:    &primary,
+		QualityScoreDelta: &delta,
+		CreatedAt:         time.Now().UTC().Truncate(time.Microsecond),
+	}
+	if err := db.SaveShadowResult(context.Background(), analysis.ID, saved); err != nil {
+		t.Fatalf("Failed to save shadow result: %v", err)
+	}
+
+	// Re-saving the analysis keeps its shadow result and metadata apart
+	if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+		t.Fatalf("Failed to save analysis: %v", err)
+	}
+	result, err = db.GetShadowResult(context.Background(), analysis.ID)
+	if err != nil {
+		t.Fatalf("Failed to get shadow result: %v", err)
+	}
+	if !reflect.DeepEqual(result, saved) {
+		t.Errorf("Expected %+v, got %+v", saved, result)
+	}
+	stored, err := db.GetAnalysis(context.Background(), analysis.ID)
+	if err != nil {
+		t.Fatalf("Failed to get analysis: %v", err)
+	}
+	if !reflect.DeepEqual(stored.Metadata.Tags, analysis.Metadata.Tags) {
+		t.Errorf("Expected primary tags %v, got %v", analysis.Metadata.Tags, stored.Metadata.Tags)
+	}
+
+	results, err := db.ListShadowResults(context.Background(), saved.CreatedAt.Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to list shadow results: %v", err)
+	}
+	if len(results) != 1 {
+		t.Errorf("Expected 1 shadow result in the window, got %d", len(results))
+	}
+	results, err = db.ListShadowResults(context.Background(), saved.CreatedAt.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to list shadow results: %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("Expected no shadow results after the window, got %d", len(results))
+	}
+
+	if err := db.SaveShadowResult(context.Background(), "missing", saved); err == nil {
+		t.Error("Expected an error for a missing analysis")
+	}
+}
+
+func TestSaveAnalysisInvalidUTF8(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	analysis := createTestAnalysis("utf8-1")
+	analysis.Text = "Sales grew 40% 😀 last quarter \xff"
+	analysis.Metadata.Tags = []string{"sales", "caf\xc3"}
+	analysis.Metadata.References = []models.Reference{
+		{Text: "40%", Type: "statistic", Context: "😀 Sales grew 40% 😀", Confidence: "medium"},
+		{Text: "grew", Type: "claim", Context: "😀"[:2] + " grew", Confidence: "low"},
+	}
+	if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+		t.Fatalf("Failed to save analysis with invalid UTF-8: %v", err)
+	}
+
+	stored, err := db.GetAnalysis(context.Background(), analysis.ID)
+	if err != nil {
+		t.Fatalf("Failed to get analysis: %v", err)
+	}
+	if stored.Text != "Sales grew 40% 😀 last quarter �" {
+		t.Errorf("Expected invalid bytes replaced, got %q", stored.Text)
+	}
+	if stored.Metadata.References[0].Context != "😀 Sales grew 40% 😀" {
+		t.Errorf("Expected the emoji context to round-trip, got %q", stored.Metadata.References[0].Context)
+	}
+}
+
+func TestProcessingStage(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	analysis := createTestAnalysis("stage-1")
+	if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+		t.Fatalf("Failed to save analysis: %v", err)
+	}
+
+	state, err := db.GetProcessingState(context.Background(), analysis.ID)
+	if err != nil {
+		t.Fatalf("Failed to get processing state: %v", err)
+	}
+	if state.Stage != models.ProcessingStageOffline {
+		t.Errorf("Expected default stage %q, got %q", models.ProcessingStageOffline, state.Stage)
+	}
+
+	for _, stage := range []string{models.ProcessingStageOfflineComplete, models.ProcessingStageEnriching} {
+		if err := db.UpdateProcessingStage(context.Background(), analysis.ID, stage); err != nil {
+			t.Fatalf("Failed to update processing stage to %q: %v", stage, err)
+		}
+	}
+	if err := db.MarkEnrichmentFailed(context.Background(), analysis.ID, 1, 3, fmt.Errorf("ollama: connection refused")); err != nil {
+		t.Fatalf("Failed to mark enrichment failed: %v", err)
+	}
+
+	state, err = db.GetProcessingState(context.Background(), analysis.ID)
+	if err != nil {
+		t.Fatalf("Failed to get processing state: %v", err)
+	}
+	if state.Stage != models.ProcessingStageEnrichmentFailed || state.RetryCount != 1 || state.MaxRetries != 3 {
+		t.Errorf("Expected a retriable failure after 1 of 3 retries, got %+v", state)
+	}
+	if state.LastError != "ollama: connection refused" {
+		t.Errorf("Expected the last error to be recorded, got %q", state.LastError)
+	}
+	if state.StartedAt == nil || state.CompletedAt != nil {
+		t.Errorf("Expected a started but not completed run, got started %v completed %v", state.StartedAt, state.CompletedAt)
+	}
+	startedAt := *state.StartedAt
+
+	// A retry keeps the start of the run
+	if err := db.UpdateProcessingStage(context.Background(), analysis.ID, models.ProcessingStageEnriching); err != nil {
+		t.Fatalf("Failed to update processing stage: %v", err)
+	}
+	if err := db.UpdateProcessingStage(context.Background(), analysis.ID, models.ProcessingStageEnriched); err != nil {
+		t.Fatalf("Failed to update processing stage: %v", err)
+	}
+	state, err = db.GetProcessingState(context.Background(), analysis.ID)
+	if err != nil {
+		t.Fatalf("Failed to get processing state: %v", err)
+	}
+	if state.Stage != models.ProcessingStageEnriched || state.CompletedAt == nil {
+		t.Errorf("Expected a completed enrichment, got %+v", state)
+	}
+	if state.StartedAt == nil || !state.StartedAt.Equal(startedAt) {
+		t.Errorf("Expected the run to start at %v, got %v", startedAt, state.StartedAt)
+	}
+
+	// The last retry fails for good
+	if err := db.MarkEnrichmentFailed(context.Background(), analysis.ID, 3, 3, fmt.Errorf("failed to update enriched analysis")); err != nil {
+		t.Fatalf("Failed to mark enrichment failed: %v", err)
+	}
+	state, err = db.GetProcessingState(context.Background(), analysis.ID)
+	if err != nil {
+		t.Fatalf("Failed to get processing state: %v", err)
+	}
+	if state.Stage != models.ProcessingStageFailed || state.CompletedAt == nil {
+		t.Errorf("Expected a terminal failure, got %+v", state)
+	}
+
+	// Reprocessing starts a new run
+	if err := db.UpdateProcessingStage(context.Background(), analysis.ID, models.ProcessingStageOfflineComplete); err != nil {
+		t.Fatalf("Failed to update processing stage: %v", err)
+	}
+	state, err = db.GetProcessingState(context.Background(), analysis.ID)
+	if err != nil {
+		t.Fatalf("Failed to get processing state: %v", err)
+	}
+	if state.RetryCount != 0 || state.LastError != "" || state.StartedAt != nil || state.CompletedAt != nil {
+		t.Errorf("Expected a reset processing state, got %+v", state)
+	}
+
+	if err := db.UpdateProcessingStage(context.Background(), "missing", models.ProcessingStageEnriched); err == nil {
+		t.Error("Expected an error for a missing analysis")
+	}
+	if err := db.MarkEnrichmentFailed(context.Background(), "missing", 0, 3, fmt.Errorf("boom")); err == nil {
+		t.Error("Expected an error for a missing analysis")
+	}
+	if _, err := db.GetProcessingState(context.Background(), "missing"); err == nil {
+		t.Error("Expected an error for a missing analysis")
+	}
+}
+
+// TestAdvanceProcessingStageKeepsCancelled tests that a task cannot move a
+// cancelled job on, while a new run started through the API can
+func TestAdvanceProcessingStageKeepsCancelled(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	analysis := createTestAnalysis("stage-cancelled-1")
+	if err := db.SaveAnalysis(ctx, analysis); err != nil {
+		t.Fatalf("Failed to save analysis: %v", err)
+	}
+	if err := db.AdvanceProcessingStage(ctx, analysis.ID, models.ProcessingStageOfflineComplete); err != nil {
+		t.Fatalf("Failed to advance processing stage: %v", err)
+	}
+	if err := db.UpdateProcessingStage(ctx, analysis.ID, models.ProcessingStageCancelled); err != nil {
+		t.Fatalf("Failed to cancel: %v", err)
+	}
+
+	for _, stage := range []string{models.ProcessingStageOfflineComplete, models.ProcessingStageEnriching, models.ProcessingStageEnriched} {
+		if err := db.AdvanceProcessingStage(ctx, analysis.ID, stage); !errors.Is(err, ErrCancelled) {
+			t.Errorf("Expected ErrCancelled advancing to %q, got %v", stage, err)
+		}
+	}
+	if err := db.MarkEnrichmentFailed(ctx, analysis.ID, 1, 3, fmt.Errorf("timeout")); !errors.Is(err, ErrCancelled) {
+		t.Errorf("Expected ErrCancelled marking enrichment failed, got %v", err)
+	}
+	state, err := db.GetProcessingState(ctx, analysis.ID)
+	if err != nil {
+		t.Fatalf("Failed to get processing state: %v", err)
+	}
+	if state.Stage != models.ProcessingStageCancelled {
+		t.Errorf("Expected the job to stay cancelled, got %q", state.Stage)
+	}
+
+	if err := db.UpdateProcessingStage(ctx, analysis.ID, models.ProcessingStageOfflineComplete); err != nil {
+		t.Fatalf("Failed to start a new run: %v", err)
+	}
+	if err := db.AdvanceProcessingStage(ctx, analysis.ID, models.ProcessingStageEnriching); err != nil {
+		t.Errorf("Expected the new run to advance, got %v", err)
+	}
+	if err := db.AdvanceProcessingStage(ctx, "missing", models.ProcessingStageEnriched); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a missing analysis, got %v", err)
+	}
+}
+
+func TestGetAnalysisByTextHash(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	hash := TextHash("This is a test text for analysis.")
+	if _, err := db.GetAnalysisByTextHash(context.Background(), hash); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected 'analysis not found' error, got %v", err)
+	}
+
+	first := createTestAnalysis("hash-1")
+	first.TextHash = hash
+	if err := db.SaveAnalysis(context.Background(), first); err != nil {
+		t.Fatalf("Failed to save analysis: %v", err)
+	}
+
+	// A later analysis of the same text is saved without the hash
+	second := createTestAnalysis("hash-2")
+	second.TextHash = hash
+	if err := db.SaveAnalysis(context.Background(), second); err != nil {
+		t.Fatalf("Failed to save analysis: %v", err)
+	}
+
+	found, err := db.GetAnalysisByTextHash(context.Background(), hash)
+	if err != nil {
+		t.Fatalf("Failed to get analysis by text hash: %v", err)
+	}
+	if found.ID != first.ID || found.TextHash != hash {
+		t.Errorf("Expected %s holding the hash, got %s with %q", first.ID, found.ID, found.TextHash)
+	}
+	if saved, err := db.GetAnalysis(context.Background(), second.ID); err != nil || saved.TextHash != "" {
+		t.Errorf("Expected %s saved without a hash, got %v, %v", second.ID, saved, err)
+	}
+
+	// Saving again, as enrichment does, keeps the hash
+	first.Metadata.Synopsis = "Enriched"
+	if err := db.SaveAnalysis(context.Background(), first); err != nil {
+		t.Fatalf("Failed to update analysis: %v", err)
+	}
+	if found, err := db.GetAnalysisByTextHash(context.Background(), hash); err != nil || found.Metadata.Synopsis != "Enriched" {
+		t.Errorf("Expected the enriched analysis by its hash, got %v, %v", found, err)
+	}
+
+	// Cancelling releases the hash
+	if err := db.UpdateProcessingStage(context.Background(), first.ID, models.ProcessingStageCancelled); err != nil {
+		t.Fatalf("Failed to cancel analysis: %v", err)
+	}
+	if _, err := db.GetAnalysisByTextHash(context.Background(), hash); err == nil {
+		t.Error("Expected a cancelled analysis to release its hash")
+	}
+}
+
+func TestSaveAnalysisTextHashRace(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	// Identical submissions processed at the same time
+	hash := TextHash("This is a test text for analysis.")
+	const submissions = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, submissions)
+	for i := 0; i < submissions; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			analysis := createTestAnalysis(fmt.Sprintf("race-%d", i))
+			analysis.TextHash = hash
+			errs <- db.SaveAnalysis(context.Background(), analysis)
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Failed to save analysis: %v", err)
+		}
+	}
+
+	var total, hashed int
+	if err := db.conn.QueryRow(`
+		SELECT COUNT(*), COUNT(text_hash) FROM textanalyzer_analyses WHERE id LIKE 'race-%'
+	`).Scan(&total, &hashed); err != nil {
+		t.Fatalf("Failed to count analyses: %v", err)
+	}
+	if total != submissions || hashed != 1 {
+		t.Errorf("Expected %d analyses with one holding the hash, got %d with %d", submissions, total, hashed)
+	}
+	if _, err := db.GetAnalysisByTextHash(context.Background(), hash); err != nil {
+		t.Errorf("Failed to get analysis by text hash: %v", err)
+	}
+}
+
+func TestTextHashBackfill(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	older := createTestAnalysis("backfill-1")
+	older.Text = "  Same text,\n\nsaved twice. "
+	older.CreatedAt = time.Now().Add(-time.Hour)
+	newer := createTestAnalysis("backfill-2")
+	newer.Text = "Same text, saved twice."
+	redacted := createTestAnalysis("backfill-3")
+	redacted.Text = "Redacted text."
+	redacted.Metadata.Redaction = &models.TextRedaction{TextSHA256: "abc"}
+	for _, analysis := range []*models.Analysis{older, newer, redacted} {
+		if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+			t.Fatalf("Failed to save analysis: %v", err)
+		}
+	}
+
+	// Rerun the migration over analyses saved without hashes
+	var migration Migration
+	for _, m := range migrations {
+		if m.Name == "add_text_hash" {
+			migration = m
+		}
+	}
+	if _, err := db.conn.Exec(migration.SQL); err != nil {
+		t.Fatalf("Failed to backfill text hashes: %v", err)
+	}
+
+	found, err := db.GetAnalysisByTextHash(context.Background(), TextHash(newer.Text))
+	if err != nil {
+		t.Fatalf("Expected the backfilled hash to match TextHash: %v", err)
+	}
+	if found.ID != older.ID {
+		t.Errorf("Expected the oldest analysis %s to hold the hash, got %s", older.ID, found.ID)
+	}
+	for _, id := range []string{newer.ID, redacted.ID} {
+		if saved, err := db.GetAnalysis(context.Background(), id); err != nil || saved.TextHash != "" {
+			t.Errorf("Expected %s left without a hash, got %v, %v", id, saved, err)
+		}
+	}
+}
+
+func TestSaveAnalysisCallbackURL(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	analysis := createTestAnalysis("callback-1")
+	analysis.CallbackURL = "https://pipeline.example.com/hooks/analysis"
+	if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+		t.Fatalf("Failed to save analysis: %v", err)
+	}
+
+	saved, err := db.GetAnalysis(context.Background(), analysis.ID)
+	if err != nil {
+		t.Fatalf("Failed to get analysis: %v", err)
+	}
+	if saved.CallbackURL != analysis.CallbackURL {
+		t.Errorf("Expected callback URL %q, got %q", analysis.CallbackURL, saved.CallbackURL)
+	}
+
+	// An update without a callback URL keeps the stored one
+	saved.CallbackURL = ""
+	saved.Metadata.Synopsis = "Enriched"
+	if err := db.SaveAnalysis(context.Background(), saved); err != nil {
+		t.Fatalf("Failed to update analysis: %v", err)
+	}
+	if updated, err := db.GetAnalysis(context.Background(), analysis.ID); err != nil || updated.CallbackURL != analysis.CallbackURL {
+		t.Errorf("Expected the callback URL kept, got %v, %v", updated, err)
+	}
+}
+
+func TestCorpusStats(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	now := time.Now().UTC()
+	corpus := []struct {
+		id       string
+		age      time.Duration
+		language string
+		quality  float64 // 0 for unscored
+		tags     []string
+	}{
+		{"test-stats-001", 0, "en", 0.9, []string{"climate-change", "policy", "positive"}},
+		{"test-stats-002", 0, "en", 0.5, []string{"climate-change"}},
+		{"test-stats-003", 48 * time.Hour, "de", 0.1, []string{"policy", "climate-change"}},
+		{"test-stats-004", 90 * 24 * time.Hour, "", 0, []string{"sports"}},
+	}
+	for _, doc := range corpus {
+		analysis := createTestAnalysis(doc.id)
+		analysis.CreatedAt = now.Add(-doc.age)
+		analysis.Metadata.Language = doc.language
+		analysis.Metadata.Tags = doc.tags
+		if doc.quality > 0 {
+			analysis.Metadata.QualityScore = &models.TextQualityScore{Score: doc.quality}
+		}
+		if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+			t.Fatalf("Failed to save analysis %s: %v", doc.id, err)
+		}
+	}
+	if err := db.UpdateProcessingStage(context.Background(), "test-stats-001", models.ProcessingStageEnriched); err != nil {
+		t.Fatalf("Failed to update processing stage: %v", err)
+	}
+
+	stats, err := db.CorpusStats(context.Background(), time.Time{})
+	if err != nil {
+		t.Fatalf("Failed to get corpus stats: %v", err)
+	}
+	if stats.TotalAnalyses != 4 || stats.Since != nil {
+		t.Errorf("Expected 4 analyses without since, got %d since %v", stats.TotalAnalyses, stats.Since)
+	}
+	if want := map[string]int{"enriched": 1, "offline": 3}; !reflect.DeepEqual(stats.ByStage, want) {
+		t.Errorf("Expected stages %v, got %v", want, stats.ByStage)
+	}
+	if want := map[string]int{"en": 2, "de": 1, "unknown": 1}; !reflect.DeepEqual(stats.ByLanguage, want) {
+		t.Errorf("Expected languages %v, got %v", want, stats.ByLanguage)
+	}
+	if stats.Quality.Scored != 3 || *stats.Quality.Average != 0.5 || *stats.Quality.Median != 0.5 {
+		t.Errorf("Expected 3 scores averaging 0.5, got %+v", stats.Quality)
+	}
+	// Structural tags such as sentiment are excluded
+	wantTags := []models.TagCount{{Tag: "climate-change", Count: 3}, {Tag: "policy", Count: 2}, {Tag: "sports", Count: 1}}
+	if !reflect.DeepEqual(stats.TopTags, wantTags) {
+		t.Errorf("Expected tags %+v, got %+v", wantTags, stats.TopTags)
+	}
+	if len(stats.PerDay) != 30 {
+		t.Fatalf("Expected 30 days, got %d", len(stats.PerDay))
+	}
+	if last := stats.PerDay[29]; last.Date != now.Format("2006-01-02") || last.Count != 2 {
+		t.Errorf("Expected 2 analyses today, got %+v", last)
+	}
+	if twoDaysAgo := stats.PerDay[27]; twoDaysAgo.Count != 1 {
+		t.Errorf("Expected 1 analysis two days ago, got %+v", twoDaysAgo)
+	}
+
+	stats, err = db.CorpusStats(context.Background(), now.Add(-24*time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to get corpus stats: %v", err)
+	}
+	if stats.TotalAnalyses != 2 || stats.Since == nil || len(stats.TopTags) != 2 {
+		t.Errorf("Expected the 2 recent analyses, got %+v", stats)
+	}
+	if stats.PerDay[27].Count != 0 {
+		t.Errorf("Expected analyses before since left out of the daily counts, got %+v", stats.PerDay[27])
+	}
+
+	// An empty window still has every field
+	stats, err = db.CorpusStats(context.Background(), now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Failed to get corpus stats: %v", err)
+	}
+	if stats.TotalAnalyses != 0 || stats.Quality.Average != nil || stats.TopTags == nil || len(stats.PerDay) != 30 {
+		t.Errorf("Expected empty stats, got %+v", stats)
+	}
+}
+
+func TestListTags(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	corpus := map[string][]string{
+		"test-tags-001": {"climate-change", "climate-policy", "energy"},
+		"test-tags-002": {"climate-change", "energy"},
+		"test-tags-003": {"climate-change", "clinical-trials"},
+		"test-tags-004": {"c++", "energy"},
+		"test-tags-005": {"climate-change"},
+	}
+	for id, analysisTags := range corpus {
+		analysis := createTestAnalysis(id)
+		analysis.Metadata.Tags = analysisTags
+		if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+			t.Fatalf("Failed to save analysis %s: %v", id, err)
+		}
+	}
+
+	tests := []struct {
+		prefix string
+		limit  int
+		want   []models.TagCount
+	}{
+		{"", 3, []models.TagCount{{Tag: "climate-change", Count: 4}, {Tag: "energy", Count: 3}, {Tag: "c++", Count: 1}}},
+		{"cli", 10, []models.TagCount{{Tag: "climate-change", Count: 4}, {Tag: "climate-policy", Count: 1}, {Tag: "clinical-trials", Count: 1}}},
+		{"climate-p", 10, []models.TagCount{{Tag: "climate-policy", Count: 1}}},
+		{"c+", 10, []models.TagCount{{Tag: "c++", Count: 1}}},
+		// Wildcards in the prefix match only themselves
+		{"%", 10, []models.TagCount{}},
+		{"c_", 10, []models.TagCount{}},
+	}
+	for _, tt := range tests {
+		list, err := db.ListTags(context.Background(), tt.prefix, tt.limit)
+		if err != nil {
+			t.Fatalf("ListTags(%q) error = %v", tt.prefix, err)
+		}
+		if !reflect.DeepEqual(list, tt.want) {
+			t.Errorf("ListTags(%q, %d) = %+v, want %+v", tt.prefix, tt.limit, list, tt.want)
+		}
+	}
+}
+
+func TestRenameTag(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	corpus := map[string][]string{
+		"test-rename-001": {"ml", "python"},
+		"test-rename-002": {"machine-learning", "ai", "ml"},
+		"test-rename-003": {"sports"},
+	}
+	for id, analysisTags := range corpus {
+		analysis := createTestAnalysis(id)
+		analysis.Metadata.Tags = analysisTags
+		if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+			t.Fatalf("Failed to save analysis %s: %v", id, err)
+		}
+	}
+
+	dryRun, err := db.RenameTag(context.Background(), "ml", "machine-learning", true)
+	if err != nil {
+		t.Fatalf("Failed to dry-run rename: %v", err)
+	}
+	if dryRun.Analyses != 2 || dryRun.Merged != 1 || !dryRun.DryRun {
+		t.Errorf("Expected 2 analyses with 1 merge, got %+v", dryRun)
+	}
+	if list, _ := db.ListTags(context.Background(), "ml", 10); len(list) != 1 || list[0].Count != 2 {
+		t.Errorf("Expected a dry run to change nothing, got %+v", list)
+	}
+
+	result, err := db.RenameTag(context.Background(), "ml", "machine-learning", false)
+	if err != nil {
+		t.Fatalf("Failed to rename tag: %v", err)
+	}
+	if result.Analyses != 2 || result.Merged != 1 || result.DryRun {
+		t.Errorf("Expected 2 analyses with 1 merge, got %+v", result)
+	}
+
+	// Metadata tags keep their order, with the merged duplicate dropped
+	want := map[string][]string{
+		"test-rename-001": {"machine-learning", "python"},
+		"test-rename-002": {"machine-learning", "ai"},
+		"test-rename-003": {"sports"},
+	}
+	for id, wantTags := range want {
+		analysis, err := db.GetAnalysis(context.Background(), id)
+		if err != nil {
+			t.Fatalf("Failed to get analysis %s: %v", id, err)
+		}
+		if !reflect.DeepEqual(analysis.Metadata.Tags, wantTags) {
+			t.Errorf("Expected %s tags %v, got %v", id, wantTags, analysis.Metadata.Tags)
+		}
+	}
+	list, err := db.ListTags(context.Background(), "", 10)
+	if err != nil {
+		t.Fatalf("Failed to list tags: %v", err)
+	}
+	if list[0] != (models.TagCount{Tag: "machine-learning", Count: 2}) {
+		t.Errorf("Expected machine-learning on 2 analyses, got %+v", list)
+	}
+	if analyses, _ := db.GetAnalysesByTag(context.Background(), "ml", false); len(analyses) != 0 {
+		t.Errorf("Expected no analyses left tagged ml, got %d", len(analyses))
+	}
+
+	// Renaming a tag nothing carries touches nothing
+	result, err = db.RenameTag(context.Background(), "ml", "machine-learning", false)
+	if err != nil {
+		t.Fatalf("Failed to rename tag: %v", err)
+	}
+	if result.Analyses != 0 {
+		t.Errorf("Expected no analyses touched, got %+v", result)
+	}
+}
+
+func TestRelatedAnalyses(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	now := time.Now()
+	corpus := []struct {
+		id       string
+		age      time.Duration
+		tags     []string
+		keyTerms []string
+	}{
+		{"test-rel-src", 0, []string{"climate-change", "policy", "energy", "positive"}, []string{"carbon", "emissions", "solar"}},
+		{"test-rel-a", 5 * time.Hour, []string{"climate-change", "policy", "positive"}, []string{"carbon", "tax"}},
+		{"test-rel-b", 4 * time.Hour, []string{"energy", "positive"}, []string{"carbon", "emissions", "solar"}},
+		{"test-rel-c", 1 * time.Hour, []string{"climate-change"}, []string{"carbon"}},
+		{"test-rel-d", 2 * time.Hour, []string{"energy"}, []string{"emissions"}},
+		{"test-rel-e", 3 * time.Hour, []string{"positive", "long"}, []string{"carbon", "emissions", "solar"}},
+		{"test-rel-f", 3 * time.Hour, nil, []string{"carbon"}},
+	}
+	for _, doc := range corpus {
+		analysis := createTestAnalysis(doc.id)
+		analysis.CreatedAt = now.Add(-doc.age)
+		analysis.Metadata.Tags = doc.tags
+		analysis.Metadata.KeyTerms = doc.keyTerms
+		if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+			t.Fatalf("Failed to save analysis %s: %v", doc.id, err)
+		}
+	}
+
+	related, err := db.RelatedAnalyses(context.Background(), "test-rel-src", 10)
+	if err != nil {
+		t.Fatalf("Failed to get related analyses: %v", err)
+	}
+
+	// Each shared topic tag counts 3 and each shared key term 1:
+	// a 2*3+1 = 7, b 1*3+3 = 6, c and d 1*3+1 = 4 with the newer c first.
+	// e shares only structural tags and f has no tags, so neither is related.
+	want := []struct {
+		id    string
+		score int
+		tags  []string
+		terms []string
+	}{
+		{"test-rel-a", 7, []string{"climate-change", "policy"}, []string{"carbon"}},
+		{"test-rel-b", 6, []string{"energy"}, []string{"carbon", "emissions", "solar"}},
+		{"test-rel-c", 4, []string{"climate-change"}, []string{"carbon"}},
+		{"test-rel-d", 4, []string{"energy"}, []string{"emissions"}},
+	}
+	if len(related) != len(want) {
+		t.Fatalf("Expected %d related analyses, got %+v", len(want), related)
+	}
+	for i, w := range want {
+		r := related[i]
+		if r.ID != w.id || r.Score != w.score || !reflect.DeepEqual(r.SharedTags, w.tags) || !reflect.DeepEqual(r.SharedKeyTerms, w.terms) {
+			t.Errorf("Expected related[%d] = %s scoring %d with %v and %v, got %+v", i, w.id, w.score, w.tags, w.terms, r)
+		}
+	}
+
+	related, err = db.RelatedAnalyses(context.Background(), "test-rel-src", 2)
+	if err != nil {
+		t.Fatalf("Failed to get related analyses: %v", err)
+	}
+	if len(related) != 2 || related[1].ID != "test-rel-b" {
+		t.Errorf("Expected the top 2 with limit 2, got %+v", related)
+	}
+
+	related, err = db.RelatedAnalyses(context.Background(), "test-rel-f", 10)
+	if err != nil {
+		t.Fatalf("Failed to get related analyses: %v", err)
+	}
+	if related == nil || len(related) != 0 {
+		t.Errorf("Expected no related analyses without tags, got %#v", related)
+	}
+}
+
+func TestPruneAnalyses(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	now := time.Now()
+	old := now.AddDate(0, 0, -100)
+	corpus := []struct {
+		id        string
+		createdAt time.Time
+		stage     string // "" keeps the column default
+		quality   float64
+	}{
+		{"test-prune-old-low", old, "", 0.2},
+		{"test-prune-old-failed", old.Add(time.Hour), models.ProcessingStageFailed, 0.1},
+		{"test-prune-old-unscored", old.Add(2 * time.Hour), models.ProcessingStageOfflineComplete, -1},
+		{"test-prune-old-high", old, models.ProcessingStageOfflineComplete, 0.8},
+		{"test-prune-old-enriched", old, models.ProcessingStageEnriched, 0.2},
+		{"test-prune-old-retrying", old, models.ProcessingStageEnrichmentFailed, 0.2},
+		{"test-prune-new-low", now, models.ProcessingStageOfflineComplete, 0.2},
+	}
+	for _, doc := range corpus {
+		analysis := createTestAnalysis(doc.id)
+		analysis.CreatedAt = doc.createdAt
+		analysis.Metadata.Tags = []string{"noise"}
+		if doc.quality >= 0 {
+			analysis.Metadata.QualityScore = &models.TextQualityScore{Score: doc.quality}
+		}
+		if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+			t.Fatalf("Failed to save analysis %s: %v", doc.id, err)
+		}
+		if doc.stage != "" {
+			if _, err := db.Conn().Exec(`UPDATE textanalyzer_analyses SET processing_stage = $2 WHERE id = $1`, doc.id, doc.stage); err != nil {
+				t.Fatalf("Failed to set processing stage: %v", err)
+			}
+		}
+	}
+
+	cutoff := now.AddDate(0, 0, -90)
+	deleted, locked, err := db.PruneAnalyses(context.Background(), cutoff, 0.35, 2)
+	if err != nil || !locked {
+		t.Fatalf("PruneAnalyses() = %d, %v, %v", deleted, locked, err)
+	}
+	if deleted != 2 {
+		t.Errorf("Expected the batch limit of 2 deleted, got %d", deleted)
+	}
+	// The oldest go first
+	if _, err := db.GetAnalysis(context.Background(), "test-prune-old-unscored"); err != nil {
+		t.Errorf("Expected the newest prunable analysis left for the next batch, got %v", err)
+	}
+
+	deleted, _, err = db.PruneAnalyses(context.Background(), cutoff, 0.35, 2)
+	if err != nil || deleted != 1 {
+		t.Fatalf("Expected the last prunable analysis deleted, got %d, %v", deleted, err)
+	}
+	for _, id := range []string{"test-prune-old-high", "test-prune-old-enriched", "test-prune-old-retrying", "test-prune-new-low"} {
+		if _, err := db.GetAnalysis(context.Background(), id); err != nil {
+			t.Errorf("Expected %s kept, got %v", id, err)
+		}
+	}
+	if analyses, _ := db.GetAnalysesByTag(context.Background(), "noise", false); len(analyses) != 4 {
+		t.Errorf("Expected the tags of pruned analyses deleted with them, got %d tagged", len(analyses))
+	}
+
+	// Another session holding the lock makes the batch a no-op
+	conn, err := db.Conn().Conn(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_lock($1)`, pruneLockKey); err != nil {
+		t.Fatalf("Failed to take lock: %v", err)
+	}
+	deleted, locked, err = db.PruneAnalyses(context.Background(), now, 1, 10)
+	if err != nil || locked || deleted != 0 {
+		t.Errorf("Expected nothing deleted while locked, got %d, %v, %v", deleted, locked, err)
+	}
+}
+
+func TestQueryContextCancellation(t *testing.T) {
+	db, cleanup := setupTestDatabase(t)
+	defer cleanup()
+
+	analysis := createTestAnalysis("test-ctx-001")
+	if err := db.SaveAnalysis(context.Background(), analysis); err != nil {
+		t.Fatalf("Failed to save analysis: %v", err)
+	}
+
+	// Another transaction holding the row lock makes the delete wait
+	tx, err := db.Conn().BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(context.Background(), `SELECT id FROM textanalyzer_analyses WHERE id = $1 FOR UPDATE`, analysis.ID); err != nil {
+		t.Fatalf("Failed to lock analysis: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := db.DeleteAnalysis(ctx, analysis.ID); err == nil {
+		t.Fatal("Expected the blocked delete to fail once its context ended")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the delete aborted at the deadline, took %v", elapsed)
+	}
+
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Failed to release lock: %v", err)
+	}
+	if _, err := db.GetAnalysis(context.Background(), analysis.ID); err != nil {
+		t.Errorf("Expected the analysis kept after the aborted delete, got %v", err)
+	}
+
+	// A context that has already ended runs nothing
+	done, cancelDone := context.WithCancel(context.Background())
+	cancelDone()
+	if _, err := db.GetAnalysis(done, analysis.ID); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if err := db.SaveAnalysis(done, analysis); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled saving, got %v", err)
 	}
 }
@@ -12,10 +12,16 @@ import (
 
 // setupTestDB creates a test PostgreSQL database connection string
 // It uses environment variables or defaults to localhost
-// Tests will skip if PostgreSQL is not available
-func setupTestDB(t *testing.T, testName string) (connStr string, cleanup func()) {
+// Tests will skip if PostgreSQL is not available, or fail when
+// TEST_DB_REQUIRED is set
+func setupTestDB(t testing.TB, testName string) (connStr string, cleanup func()) {
 	t.Helper()
 
+	unavailable := t.Skipf
+	if os.Getenv("TEST_DB_REQUIRED") != "" {
+		unavailable = t.Fatalf
+	}
+
 	// Get PostgreSQL connection parameters from environment or use defaults
 	host := getEnvOrDefault("TEST_DB_HOST", "localhost")
 	port := getEnvOrDefault("TEST_DB_PORT", "5432")
@@ -31,21 +37,21 @@ func setupTestDB(t *testing.T, testName string) (connStr string, cleanup func())
 
 	adminDB, err := sql.Open("postgres", adminConnStr)
 	if err != nil {
-		t.Skipf("Could not connect to PostgreSQL for testing: %v (set TEST_DB_* env vars if needed)", err)
+		unavailable("Could not connect to PostgreSQL for testing: %v (set TEST_DB_* env vars if needed)", err)
 		return "", func() {}
 	}
 	defer adminDB.Close()
 
 	// Test connection
 	if err := adminDB.Ping(); err != nil {
-		t.Skipf("Could not ping PostgreSQL for testing: %v", err)
+		unavailable("Could not ping PostgreSQL for testing: %v", err)
 		return "", func() {}
 	}
 
 	// Create test database
 	_, err = adminDB.Exec(fmt.Sprintf("CREATE DATABASE %s", dbName))
 	if err != nil {
-		t.Skipf("Could not create test database: %v", err)
+		unavailable("Could not create test database: %v", err)
 		return "", func() {}
 	}
 
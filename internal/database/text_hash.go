@@ -0,0 +1,29 @@
+package database
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// hashWhitespace is the whitespace collapsed before a text is hashed. The
+// add_text_hash migration collapses the same characters when it backfills
+// existing analyses.
+const hashWhitespace = " \t\n\r\f\v"
+
+// TextHash returns the hex SHA-256 of text with surrounding whitespace
+// trimmed and each run of whitespace inside it replaced by one space, so
+// resubmissions that differ only in spacing share a hash
+func TextHash(text string) string {
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		return strings.ContainsRune(hashWhitespace, r)
+	})
+	sum := sha256.Sum256([]byte(strings.Join(fields, " ")))
+	return hex.EncodeToString(sum[:])
+}
+
+// textHashExpr computes TextHash of the text column in SQL (\x0B is \v), as
+// the add_text_hash migration does
+const textHashExpr = `encode(sha256(convert_to(
+	regexp_replace(btrim(text, E' \t\n\r\f\x0B'), E'[ \t\n\r\f\x0B]+', ' ', 'g'),
+	'UTF8')), 'hex')`
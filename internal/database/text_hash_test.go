@@ -0,0 +1,33 @@
+package database
+
+import "testing"
+
+func TestTextHash(t *testing.T) {
+	base := TextHash("The quick brown fox.\nJumps over the dog.")
+
+	tests := []struct {
+		name string
+		text string
+		same bool
+	}{
+		{"identical", "The quick brown fox.\nJumps over the dog.", true},
+		{"surrounding whitespace", "\n  The quick brown fox.\nJumps over the dog.\t\n", true},
+		{"collapsed whitespace", "The  quick brown\tfox.\r\n\r\nJumps over the dog.", true},
+		{"different text", "The quick brown fox.\nJumps over the cat.", false},
+		{"different case", "the quick brown fox.\nJumps over the dog.", false},
+		{"non-breaking space kept", "The\u00a0quick brown fox.\nJumps over the dog.", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TextHash(tt.text) == base; got != tt.same {
+				t.Errorf("TextHash(%q) matches = %v, want %v", tt.text, got, tt.same)
+			}
+		})
+	}
+
+	// SHA-256 of "a b"
+	if got := TextHash(" a \n b "); got != "c8687a08aa5d6ed2044328fa6a697ab8e96dc34291e8c2034ae8c38e6fcc6d65" {
+		t.Errorf("TextHash() = %s", got)
+	}
+}
@@ -0,0 +1,252 @@
+// Package evaluation measures how well the quality scorers agree with a
+// labeled corpus, so heuristic changes can be judged by numbers rather than
+// by feel.
+package evaluation
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Quality buckets, matching the score ranges the AI scorer is prompted with
+const (
+	BucketLow      = "low"      // Under 0.4: spam, trivial or incoherent
+	BucketModerate = "moderate" // 0.4 to 0.7: some value but significant issues
+	BucketHigh     = "high"     // 0.7 and over: coherent and informative
+)
+
+// Buckets lists the quality buckets from worst to best
+var Buckets = []string{BucketLow, BucketModerate, BucketHigh}
+
+// BucketFor returns the bucket a quality score falls in
+func BucketFor(score float64) string {
+	switch {
+	case score < 0.4:
+		return BucketLow
+	case score < 0.7:
+		return BucketModerate
+	}
+	return BucketHigh
+}
+
+// Sample is a labeled text in a corpus
+type Sample struct {
+	ID             string `json:"id"`
+	Text           string `json:"text"`
+	ExpectedBucket string `json:"expected_bucket"`
+}
+
+// LoadCorpus reads a JSONL corpus with one sample per line
+func LoadCorpus(path string) ([]Sample, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open corpus: %w", err)
+	}
+	defer f.Close()
+	return ReadCorpus(f)
+}
+
+// ReadCorpus reads JSONL samples, rejecting empty texts and unknown buckets
+func ReadCorpus(r io.Reader) ([]Sample, error) {
+	var samples []Sample
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		var sample Sample
+		if err := json.Unmarshal(scanner.Bytes(), &sample); err != nil {
+			return nil, fmt.Errorf("line %d: invalid sample: %w", line, err)
+		}
+		if strings.TrimSpace(sample.Text) == "" {
+			return nil, fmt.Errorf("line %d: sample has no text", line)
+		}
+		if !isBucket(sample.ExpectedBucket) {
+			return nil, fmt.Errorf("line %d: unknown bucket %q: must be one of %s",
+				line, sample.ExpectedBucket, strings.Join(Buckets, ", "))
+		}
+		if sample.ID == "" {
+			sample.ID = fmt.Sprintf("line-%d", line)
+		}
+		samples = append(samples, sample)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read corpus: %w", err)
+	}
+	return samples, nil
+}
+
+func isBucket(name string) bool {
+	for _, bucket := range Buckets {
+		if name == bucket {
+			return true
+		}
+	}
+	return false
+}
+
+// SampleCorpus returns n samples drawn at random with seed, in corpus order,
+// or the whole corpus when n is zero or at least its size. Sampling keeps
+// runs against a slow model short while staying repeatable.
+func SampleCorpus(samples []Sample, n int, seed int64) []Sample {
+	if n <= 0 || n >= len(samples) {
+		return samples
+	}
+
+	picked := rand.New(rand.NewSource(seed)).Perm(len(samples))[:n]
+	sort.Ints(picked)
+	subset := make([]Sample, n)
+	for i, index := range picked {
+		subset[i] = samples[index]
+	}
+	return subset
+}
+
+// Scorer scores a text from 0 to 1
+type Scorer struct {
+	Name  string
+	Score func(ctx context.Context, text string) (float64, error)
+}
+
+// Report summarizes how a scorer's buckets agree with the labels
+type Report struct {
+	Scorer       string                 `json:"scorer"`
+	Samples      int                    `json:"samples"`          // Samples scored
+	Errors       int                    `json:"errors,omitempty"` // Samples the scorer failed on, excluded from the counts
+	Agreement    float64                `json:"agreement"`        // Fraction scored in their labeled bucket
+	Buckets      map[string]BucketStats `json:"buckets"`
+	Distribution map[string]ScoreStats  `json:"distribution"` // Scores by labeled bucket
+	Misses       []Miss                 `json:"misses,omitempty"`
+}
+
+// BucketStats are the precision and recall of one bucket
+type BucketStats struct {
+	Expected  int     `json:"expected"`  // Samples labeled with the bucket
+	Predicted int     `json:"predicted"` // Samples scored into the bucket
+	Correct   int     `json:"correct"`
+	Precision float64 `json:"precision"` // Correct / Predicted (0 when nothing was predicted)
+	Recall    float64 `json:"recall"`    // Correct / Expected (0 when nothing was expected)
+}
+
+// ScoreStats describe the scores of a group of samples
+type ScoreStats struct {
+	Count     int     `json:"count"`
+	Min       float64 `json:"min"`
+	Mean      float64 `json:"mean"`
+	Max       float64 `json:"max"`
+	Histogram [10]int `json:"histogram"` // Counts in tenths from [0, 0.1) to [0.9, 1]
+}
+
+// Miss is a sample scored outside its labeled bucket
+type Miss struct {
+	ID        string  `json:"id"`
+	Expected  string  `json:"expected"`
+	Predicted string  `json:"predicted"`
+	Score     float64 `json:"score"`
+}
+
+// Evaluate scores every sample and compares the buckets with the labels. It
+// stops early only when ctx is done.
+func Evaluate(ctx context.Context, samples []Sample, scorer Scorer) (Report, error) {
+	report := Report{
+		Scorer:       scorer.Name,
+		Buckets:      make(map[string]BucketStats, len(Buckets)),
+		Distribution: make(map[string]ScoreStats, len(Buckets)),
+	}
+	scores := make(map[string][]float64, len(Buckets))
+
+	for _, sample := range samples {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		score, err := scorer.Score(ctx, sample.Text)
+		if err != nil {
+			report.Errors++
+			continue
+		}
+
+		predicted := BucketFor(score)
+		expected := report.Buckets[sample.ExpectedBucket]
+		expected.Expected++
+		if predicted == sample.ExpectedBucket {
+			expected.Correct++
+		} else {
+			report.Misses = append(report.Misses, Miss{sample.ID, sample.ExpectedBucket, predicted, score})
+		}
+		report.Buckets[sample.ExpectedBucket] = expected
+
+		stats := report.Buckets[predicted]
+		stats.Predicted++
+		report.Buckets[predicted] = stats
+
+		scores[sample.ExpectedBucket] = append(scores[sample.ExpectedBucket], score)
+		report.Samples++
+	}
+
+	correct := 0
+	for _, bucket := range Buckets {
+		stats := report.Buckets[bucket]
+		stats.Precision = ratio(stats.Correct, stats.Predicted)
+		stats.Recall = ratio(stats.Correct, stats.Expected)
+		report.Buckets[bucket] = stats
+		report.Distribution[bucket] = describe(scores[bucket])
+		correct += stats.Correct
+	}
+	report.Agreement = ratio(correct, report.Samples)
+	return report, nil
+}
+
+func ratio(n, d int) float64 {
+	if d == 0 {
+		return 0
+	}
+	return float64(n) / float64(d)
+}
+
+// describe returns the statistics of scores
+func describe(scores []float64) ScoreStats {
+	stats := ScoreStats{Count: len(scores)}
+	if len(scores) == 0 {
+		return stats
+	}
+
+	stats.Min, stats.Max = math.Inf(1), math.Inf(-1)
+	sum := 0.0
+	for _, score := range scores {
+		stats.Min = math.Min(stats.Min, score)
+		stats.Max = math.Max(stats.Max, score)
+		sum += score
+		stats.Histogram[min(int(score*10), 9)]++
+	}
+	stats.Mean = sum / float64(len(scores))
+	return stats
+}
+
+// WriteText writes the report as a plain text table
+func (r Report) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "%s: %d samples, agreement %.1f%%", r.Scorer, r.Samples, r.Agreement*100)
+	if r.Errors > 0 {
+		fmt.Fprintf(w, ", %d errors", r.Errors)
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintf(w, "  %-9s %9s %7s %9s %9s %6s %6s %6s\n", "bucket", "precision", "recall", "expected", "predicted", "min", "mean", "max")
+	for _, bucket := range Buckets {
+		stats, dist := r.Buckets[bucket], r.Distribution[bucket]
+		fmt.Fprintf(w, "  %-9s %9.2f %7.2f %9d %9d %6.2f %6.2f %6.2f\n",
+			bucket, stats.Precision, stats.Recall, stats.Expected, stats.Predicted, dist.Min, dist.Mean, dist.Max)
+	}
+	for _, miss := range r.Misses {
+		fmt.Fprintf(w, "  miss %s: expected %s, scored %.2f (%s)\n", miss.ID, miss.Expected, miss.Score, miss.Predicted)
+	}
+}
@@ -0,0 +1,307 @@
+package evaluation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/docutag/textanalyzer/internal/analyzer"
+	"github.com/docutag/textanalyzer/internal/ollama"
+)
+
+const corpusPath = "testdata/quality_corpus.jsonl"
+
+// ruleBasedAgreementFloor is the rule-based scorer's agreement with the
+// starter corpus. Heuristic changes should raise it, never lower it.
+const ruleBasedAgreementFloor = 0.62
+
+func loadStarterCorpus(t *testing.T) []Sample {
+	t.Helper()
+	samples, err := LoadCorpus(corpusPath)
+	if err != nil {
+		t.Fatalf("Failed to load corpus: %v", err)
+	}
+	return samples
+}
+
+// newFakeOllama serves quality scores from scores, keyed by sample text, and
+// fails for texts it does not know. Cleaning returns the text unchanged.
+func newFakeOllama(t *testing.T, scores map[string]float64) *ollama.Client {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Prompt string `json:"prompt"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		// Match the longest sample in the prompt, since short samples can
+		// appear inside longer ones
+		matched := ""
+		for text := range scores {
+			if len(text) > len(matched) && strings.Contains(req.Prompt, text) {
+				matched = text
+			}
+		}
+		if matched == "" {
+			http.Error(w, `{"error":"unknown text"}`, http.StatusInternalServerError)
+			return
+		}
+		response := fmt.Sprintf(`{"score": %g, "reason": "fake", "categories": []}`, scores[matched])
+		if strings.HasPrefix(req.Prompt, "Your task is to clean") {
+			response = matched
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"response": response, "done": true})
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := ollama.New(server.URL, "test-model")
+	if err != nil {
+		t.Fatalf("Failed to create Ollama client: %v", err)
+	}
+	return client
+}
+
+func TestStarterCorpus(t *testing.T) {
+	samples := loadStarterCorpus(t)
+	if len(samples) < 50 {
+		t.Errorf("Expected at least 50 samples, got %d", len(samples))
+	}
+
+	kinds := map[string]int{}
+	buckets := map[string]int{}
+	for _, sample := range samples {
+		kind, _, _ := strings.Cut(sample.ID, "-")
+		kinds[kind]++
+		buckets[sample.ExpectedBucket]++
+	}
+	for _, kind := range []string{"spam", "headline", "essay", "code"} {
+		if kinds[kind] == 0 {
+			t.Errorf("Expected %s samples in the corpus, got kinds %v", kind, kinds)
+		}
+	}
+	for _, bucket := range Buckets {
+		if buckets[bucket] == 0 {
+			t.Errorf("Expected %s samples in the corpus, got buckets %v", bucket, buckets)
+		}
+	}
+}
+
+func TestReadCorpusRejectsInvalidSamples(t *testing.T) {
+	tests := []struct {
+		corpus string
+		errMsg string
+	}{
+		{`{"text": "A sample.", "expected_bucket": "great"}`, `line 1: unknown bucket "great"`},
+		{"\n" + `{"text": " ", "expected_bucket": "low"}`, "line 2: sample has no text"},
+		{`{"text": `, "line 1: invalid sample"},
+	}
+
+	for _, tt := range tests {
+		_, err := ReadCorpus(strings.NewReader(tt.corpus))
+		if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+			t.Errorf("Expected error containing %q, got %v", tt.errMsg, err)
+		}
+	}
+
+	samples, err := ReadCorpus(strings.NewReader(`{"text": "A sample.", "expected_bucket": "low"}`))
+	if err != nil || len(samples) != 1 || samples[0].ID != "line-1" {
+		t.Errorf("Expected one sample named after its line, got %+v, %v", samples, err)
+	}
+}
+
+func TestEvaluatePrecisionAndRecall(t *testing.T) {
+	samples := []Sample{
+		{ID: "a", Text: "a", ExpectedBucket: BucketLow},
+		{ID: "b", Text: "b", ExpectedBucket: BucketLow},
+		{ID: "c", Text: "c", ExpectedBucket: BucketModerate},
+		{ID: "d", Text: "d", ExpectedBucket: BucketHigh},
+		{ID: "e", Text: "e", ExpectedBucket: BucketHigh},
+	}
+	scores := map[string]float64{"a": 0.1, "b": 0.5, "c": 0.5, "d": 0.9, "e": 1.0}
+	scorer := Scorer{Name: "fixed", Score: func(ctx context.Context, text string) (float64, error) {
+		return scores[text], nil
+	}}
+
+	report, err := Evaluate(context.Background(), samples, scorer)
+	if err != nil {
+		t.Fatalf("Evaluate failed: %v", err)
+	}
+
+	if report.Samples != 5 || report.Agreement != 0.8 {
+		t.Errorf("Expected 5 samples at 0.8 agreement, got %d at %v", report.Samples, report.Agreement)
+	}
+	want := map[string]BucketStats{
+		BucketLow:      {Expected: 2, Predicted: 1, Correct: 1, Precision: 1, Recall: 0.5},
+		BucketModerate: {Expected: 1, Predicted: 2, Correct: 1, Precision: 0.5, Recall: 1},
+		BucketHigh:     {Expected: 2, Predicted: 2, Correct: 2, Precision: 1, Recall: 1},
+	}
+	for bucket, stats := range want {
+		if report.Buckets[bucket] != stats {
+			t.Errorf("Bucket %s: expected %+v, got %+v", bucket, stats, report.Buckets[bucket])
+		}
+	}
+
+	high := report.Distribution[BucketHigh]
+	if high.Count != 2 || high.Min != 0.9 || high.Max != 1.0 || high.Mean != 0.95 || high.Histogram[9] != 2 {
+		t.Errorf("Expected high scores 0.9 and 1.0 in the top decile, got %+v", high)
+	}
+	if len(report.Misses) != 1 || report.Misses[0].ID != "b" || report.Misses[0].Predicted != BucketModerate {
+		t.Errorf("Expected sample b to be the only miss, got %+v", report.Misses)
+	}
+
+	// Scorer failures are counted and excluded
+	failing := Scorer{Name: "failing", Score: func(ctx context.Context, text string) (float64, error) {
+		return 0, errors.New("model unavailable")
+	}}
+	report, _ = Evaluate(context.Background(), samples, failing)
+	if report.Errors != 5 || report.Samples != 0 || report.Agreement != 0 {
+		t.Errorf("Expected 5 errors and nothing scored, got %+v", report)
+	}
+}
+
+func TestRuleBasedScorerAgreement(t *testing.T) {
+	samples := loadStarterCorpus(t)
+
+	report, err := Evaluate(context.Background(), samples, RuleBasedScorer(analyzer.New(), analyzer.DefaultQualityWeights()))
+	if err != nil {
+		t.Fatalf("Evaluate failed: %v", err)
+	}
+	if report.Samples != len(samples) || report.Errors != 0 {
+		t.Errorf("Expected all %d samples scored, got %d with %d errors", len(samples), report.Samples, report.Errors)
+	}
+	if report.Agreement < ruleBasedAgreementFloor {
+		var out strings.Builder
+		report.WriteText(&out)
+		t.Errorf("Expected agreement of at least %.2f, got %.2f\n%s", ruleBasedAgreementFloor, report.Agreement, out.String())
+	}
+
+	// Spam is what the rule-based scorer must catch
+	if recall := report.Buckets[BucketLow].Recall; recall < 0.9 {
+		t.Errorf("Expected low bucket recall of at least 0.9, got %.2f", recall)
+	}
+}
+
+func TestAIAndCompositeScorersWithFakeOllama(t *testing.T) {
+	samples := loadStarterCorpus(t)
+
+	// The fake model agrees with every label
+	labelScores := map[string]float64{BucketLow: 0.2, BucketModerate: 0.55, BucketHigh: 0.85}
+	scores := make(map[string]float64, len(samples))
+	for _, sample := range samples {
+		scores[sample.Text] = labelScores[sample.ExpectedBucket]
+	}
+	client := newFakeOllama(t, scores)
+	ai := AIScorer(client)
+	ruleBased := RuleBasedScorer(analyzer.New(), analyzer.DefaultQualityWeights())
+
+	report, err := Evaluate(context.Background(), samples, ai)
+	if err != nil {
+		t.Fatalf("Evaluate failed: %v", err)
+	}
+	if report.Agreement != 1 || report.Errors != 0 {
+		t.Errorf("Expected full agreement from the fake model, got %.2f with %d errors", report.Agreement, report.Errors)
+	}
+
+	// The composite takes the worse score, so it never rates above either scorer
+	composite, err := Evaluate(context.Background(), samples, CompositeScorer(ai, ruleBased, AICleaner(client)))
+	if err != nil {
+		t.Fatalf("Evaluate failed: %v", err)
+	}
+	ruleReport, _ := Evaluate(context.Background(), samples, ruleBased)
+	for _, bucket := range Buckets {
+		if composite.Distribution[bucket].Mean > report.Distribution[bucket].Mean ||
+			composite.Distribution[bucket].Mean > ruleReport.Distribution[bucket].Mean {
+			t.Errorf("Bucket %s: expected composite mean at most the AI and rule-based means, got %.2f, %.2f and %.2f", bucket,
+				composite.Distribution[bucket].Mean, report.Distribution[bucket].Mean, ruleReport.Distribution[bucket].Mean)
+		}
+	}
+
+	// Texts the model fails on are reported as errors
+	report, _ = Evaluate(context.Background(), []Sample{{ID: "unknown", Text: "Not in the corpus.", ExpectedBucket: BucketLow}}, ai)
+	if report.Errors != 1 {
+		t.Errorf("Expected the failed call to be counted, got %+v", report)
+	}
+}
+
+func TestCompositeScorerScoresCleanedText(t *testing.T) {
+	fixed := func(name string, score float64) Scorer {
+		return Scorer{Name: name, Score: func(ctx context.Context, text string) (float64, error) { return score, nil }}
+	}
+	// The rule-based half only sees what the cleaner returns
+	ruleBased := Scorer{Name: "rule-based", Score: func(ctx context.Context, text string) (float64, error) {
+		if text == "cleaned" {
+			return 0.3, nil
+		}
+		return 0.9, nil
+	}}
+	cleanTo := func(cleaned string) Cleaner {
+		return func(ctx context.Context, text string) (string, error) { return cleaned, nil }
+	}
+
+	score, err := CompositeScorer(fixed("ai", 0.8), ruleBased, cleanTo("cleaned")).Score(context.Background(), "raw")
+	if err != nil || score != 0.3 {
+		t.Errorf("Expected the cleaned text's rule-based score 0.3, got %.2f (%v)", score, err)
+	}
+	score, err = CompositeScorer(fixed("ai", 0.8), ruleBased, cleanTo("")).Score(context.Background(), "raw")
+	if err != nil || score != 0.8 {
+		t.Errorf("Expected the AI score 0.8 for text that cleans to nothing, got %.2f (%v)", score, err)
+	}
+
+	failing := func(ctx context.Context, text string) (string, error) { return "", errors.New("clean failed") }
+	if _, err := CompositeScorer(fixed("ai", 0.8), ruleBased, failing).Score(context.Background(), "raw"); err == nil {
+		t.Error("Expected the cleaning error")
+	}
+}
+
+func TestSweepWeight(t *testing.T) {
+	samples := loadStarterCorpus(t)
+	a := analyzer.New()
+
+	results, err := SweepWeight(context.Background(), a, samples, analyzer.DefaultQualityWeights(), "Base", []float64{0.5, 0.6})
+	if err != nil {
+		t.Fatalf("SweepWeight failed: %v", err)
+	}
+	if len(results) != 2 || results[1].Value != 0.6 || results[1].Report.Scorer != "rule-based Base=0.6" {
+		t.Fatalf("Expected a report per value, got %+v", results)
+	}
+
+	// The first value reproduces the default weights
+	defaults, _ := Evaluate(context.Background(), samples, RuleBasedScorer(a, analyzer.DefaultQualityWeights()))
+	if results[0].Report.Agreement != defaults.Agreement {
+		t.Errorf("Expected the default base to match the default report, got %.2f and %.2f", results[0].Report.Agreement, defaults.Agreement)
+	}
+
+	// A higher starting score moves essays into the high bucket
+	if results[1].Report.Buckets[BucketHigh].Recall <= results[0].Report.Buckets[BucketHigh].Recall {
+		t.Errorf("Expected a higher base to raise high bucket recall, got %.2f then %.2f",
+			results[0].Report.Buckets[BucketHigh].Recall, results[1].Report.Buckets[BucketHigh].Recall)
+	}
+
+	if _, err := SweepWeight(context.Background(), a, samples, analyzer.DefaultQualityWeights(), "Nonsense", []float64{1}); err == nil {
+		t.Error("Expected an error for an unknown weight")
+	}
+}
+
+func TestSampleCorpus(t *testing.T) {
+	samples := loadStarterCorpus(t)
+
+	subset := SampleCorpus(samples, 10, 7)
+	if len(subset) != 10 {
+		t.Fatalf("Expected 10 samples, got %d", len(subset))
+	}
+	again := SampleCorpus(samples, 10, 7)
+	for i := range subset {
+		if subset[i].ID != again[i].ID {
+			t.Errorf("Expected the same seed to pick the same samples, got %s and %s", subset[i].ID, again[i].ID)
+		}
+	}
+	if got := SampleCorpus(samples, 0, 7); len(got) != len(samples) {
+		t.Errorf("Expected the whole corpus for n = 0, got %d samples", len(got))
+	}
+}
@@ -0,0 +1,97 @@
+package evaluation
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docutag/textanalyzer/internal/analyzer"
+	"github.com/docutag/textanalyzer/internal/ollama"
+)
+
+// RuleBasedScorer scores with the rule-based checks using weights
+func RuleBasedScorer(a *analyzer.Analyzer, weights analyzer.QualityWeights) Scorer {
+	return Scorer{
+		Name: "rule-based",
+		Score: func(ctx context.Context, text string) (float64, error) {
+			return a.ScoreQualityRuleBased(text, weights).Score, nil
+		},
+	}
+}
+
+// AIScorer scores with the Ollama model
+func AIScorer(client *ollama.Client) Scorer {
+	return Scorer{
+		Name: "ai",
+		Score: func(ctx context.Context, text string) (float64, error) {
+			result, err := client.ScoreTextQuality(ctx, text)
+			if err != nil {
+				return 0, err
+			}
+			return result.Score, nil
+		},
+	}
+}
+
+// Cleaner cleans a text before the rule-based half of the composite scores it
+type Cleaner func(ctx context.Context, text string) (string, error)
+
+// AICleaner cleans with the Ollama model, as enrichment does
+func AICleaner(client *ollama.Client) Cleaner {
+	return client.CleanText
+}
+
+// CompositeScorer blends the AI and rule-based scores as enrichment does:
+// the AI scores the text, the rule-based scorer the text clean returns, and
+// the worse of the two wins so either scorer can reject a text. A text that
+// cleans to nothing keeps the AI score.
+func CompositeScorer(ai, ruleBased Scorer, clean Cleaner) Scorer {
+	return Scorer{
+		Name: "composite",
+		Score: func(ctx context.Context, text string) (float64, error) {
+			aiScore, err := ai.Score(ctx, text)
+			if err != nil {
+				return 0, err
+			}
+			cleaned, err := clean(ctx, text)
+			if err != nil {
+				return 0, err
+			}
+			if cleaned == "" {
+				return aiScore, nil
+			}
+			ruleScore, err := ruleBased.Score(ctx, cleaned)
+			if err != nil {
+				return 0, err
+			}
+			return min(aiScore, ruleScore), nil
+		},
+	}
+}
+
+// SweepResult is the rule-based report for one value of a swept weight
+type SweepResult struct {
+	Weight string  `json:"weight"`
+	Value  float64 `json:"value"`
+	Report Report  `json:"report"`
+}
+
+// SweepWeight evaluates the rule-based scorer with the named weight set to
+// each value in turn, leaving the other weights at base
+func SweepWeight(ctx context.Context, a *analyzer.Analyzer, samples []Sample, base analyzer.QualityWeights, weight string, values []float64) ([]SweepResult, error) {
+	results := make([]SweepResult, 0, len(values))
+	for _, value := range values {
+		weights, err := base.With(weight, value)
+		if err != nil {
+			return nil, err
+		}
+
+		scorer := RuleBasedScorer(a, weights)
+		scorer.Name = fmt.Sprintf("rule-based %s=%g", weight, value)
+		report, err := Evaluate(ctx, samples, scorer)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, SweepResult{Weight: weight, Value: value, Report: report})
+	}
+	return results, nil
+}
@@ -0,0 +1,267 @@
+//go:build integration
+
+// Package integration drives the full pipeline: the API enqueues a document,
+// the worker processes it against a real Postgres database and Redis, and a
+// fake Ollama server stands in for the model.
+//
+// Run with: go test -tags integration ./internal/integration/...
+package integration
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hibiken/asynq"
+	_ "github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/docutag/textanalyzer/internal/analyzer"
+	"github.com/docutag/textanalyzer/internal/api"
+	"github.com/docutag/textanalyzer/internal/database"
+	"github.com/docutag/textanalyzer/internal/ollama"
+	"github.com/docutag/textanalyzer/internal/queue"
+)
+
+// fakeSynopsis is the response the fake Ollama server gives to every prompt
+const fakeSynopsis = "The council approved twelve new bus routes for the eastern districts."
+
+// pollTimeout bounds how long a scenario waits for the worker
+const pollTimeout = 30 * time.Second
+
+// fakeOllama serves /api/generate, failing every request while failing is set
+type fakeOllama struct {
+	server  *httptest.Server
+	failing atomic.Bool
+	prompts atomic.Int64
+}
+
+func newFakeOllama(t *testing.T) *fakeOllama {
+	t.Helper()
+
+	f := &fakeOllama{}
+	f.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.prompts.Add(1)
+		if f.failing.Load() {
+			http.Error(w, `{"error":"model unavailable"}`, http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"response": fakeSynopsis, "done": true})
+	}))
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+// newImageServer serves a small PNG at any path
+func newImageServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 320, 200))))
+	data := buf.Bytes()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// environment is one running pipeline: API server, worker, database and fakes
+type environment struct {
+	api       *httptest.Server
+	db        *database.DB
+	inspector *queue.Inspector
+	redisAddr string
+	ollama    *fakeOllama
+	images    *httptest.Server
+}
+
+// setupEnvironment starts the pipeline for a test, skipping it when Postgres
+// or Redis is unavailable. Redis is read from TEST_REDIS_ADDR and Postgres
+// from the TEST_DB_* variables used by the API tests.
+func setupEnvironment(t *testing.T) *environment {
+	t.Helper()
+
+	// Reset Prometheus registry to avoid metric registration conflicts between tests
+	prometheus.DefaultRegisterer = prometheus.NewRegistry()
+
+	redisAddr := getEnvOrDefault("TEST_REDIS_ADDR", "localhost:6379")
+	ping := asynq.NewInspector(asynq.RedisClientOpt{Addr: redisAddr})
+	_, err := ping.Queues()
+	ping.Close()
+	if err != nil {
+		t.Skipf("Could not connect to Redis at %s: %v (set TEST_REDIS_ADDR if needed)", redisAddr, err)
+	}
+
+	db := setupDatabase(t)
+
+	env := &environment{
+		db:        db,
+		redisAddr: redisAddr,
+		ollama:    newFakeOllama(t),
+		images:    newImageServer(t),
+	}
+
+	ollamaClient, err := ollama.New(env.ollama.server.URL, "test-model")
+	require.NoError(t, err)
+	a := analyzer.NewWithOllama(ollamaClient)
+	a.SetImageFetchConfig(analyzer.ImageFetchConfig{AllowPrivate: true})
+
+	queueClient := queue.NewClient(queue.ClientConfig{RedisAddr: redisAddr})
+	t.Cleanup(func() { queueClient.Close() })
+
+	env.inspector = queue.NewInspector(queue.ClientConfig{RedisAddr: redisAddr})
+	t.Cleanup(func() { env.inspector.Close() })
+
+	worker := queue.NewWorker(queue.WorkerConfig{
+		RedisAddr:   redisAddr,
+		Concurrency: 1,
+		MaxRetries:  3,
+		WorkerID:    "integration-" + t.Name(),
+	}, db, a, queueClient)
+	go func() {
+		if err := worker.Start(); err != nil {
+			t.Errorf("Worker stopped: %v", err)
+		}
+	}()
+	t.Cleanup(worker.Shutdown)
+
+	env.api = httptest.NewServer(api.NewHandler(db, a, queueClient, api.Config{Inspector: env.inspector}))
+	t.Cleanup(env.api.Close)
+
+	return env
+}
+
+// setupDatabase creates and migrates a dedicated Postgres database for a test
+func setupDatabase(t *testing.T) *database.DB {
+	t.Helper()
+
+	host := getEnvOrDefault("TEST_DB_HOST", "localhost")
+	port := getEnvOrDefault("TEST_DB_PORT", "5432")
+	user := getEnvOrDefault("TEST_DB_USER", "postgres")
+	password := getEnvOrDefault("TEST_DB_PASSWORD", "postgres")
+	dbName := fmt.Sprintf("test_integration_%d", time.Now().UnixNano())
+
+	adminConnStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=postgres sslmode=disable",
+		host, port, user, password)
+	adminDB, err := sql.Open("postgres", adminConnStr)
+	if err != nil {
+		t.Skipf("Could not connect to PostgreSQL for testing: %v (set TEST_DB_* env vars if needed)", err)
+	}
+	defer adminDB.Close()
+
+	if err := adminDB.Ping(); err != nil {
+		t.Skipf("Could not ping PostgreSQL for testing: %v", err)
+	}
+	if _, err := adminDB.Exec(fmt.Sprintf("CREATE DATABASE %s", dbName)); err != nil {
+		t.Skipf("Could not create test database: %v", err)
+	}
+
+	db, err := database.New(fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+		host, port, user, password, dbName))
+	require.NoError(t, err)
+	require.NoError(t, db.Migrate())
+
+	t.Cleanup(func() {
+		db.Close()
+
+		adminDB, err := sql.Open("postgres", adminConnStr)
+		if err != nil {
+			return
+		}
+		defer adminDB.Close()
+
+		adminDB.Exec(fmt.Sprintf("SELECT pg_terminate_backend(pg_stat_activity.pid) FROM pg_stat_activity WHERE pg_stat_activity.datname = '%s'", dbName))
+		adminDB.Exec(fmt.Sprintf("DROP DATABASE IF EXISTS %s", dbName))
+	})
+
+	return db
+}
+
+// request sends a JSON request to the API and decodes the JSON response
+func (e *environment) request(t *testing.T, method, path string, body interface{}) (int, map[string]interface{}) {
+	t.Helper()
+
+	var reader bytes.Buffer
+	if body != nil {
+		require.NoError(t, json.NewEncoder(&reader).Encode(body))
+	}
+	req, err := http.NewRequest(method, e.api.URL+path, &reader)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var decoded map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&decoded))
+	return resp.StatusCode, decoded
+}
+
+// submit posts a document to /api/analyze and returns its job ID
+func (e *environment) submit(t *testing.T, body map[string]interface{}) string {
+	t.Helper()
+
+	status, resp := e.request(t, http.MethodPost, "/api/analyze", body)
+	require.Equal(t, http.StatusAccepted, status, "analyze response: %v", resp)
+	jobID, _ := resp["job_id"].(string)
+	require.NotEmpty(t, jobID)
+	return jobID
+}
+
+// waitForTasks waits until no task spawned for the job is queued, scheduled
+// or running, and returns the final task states
+func (e *environment) waitForTasks(t *testing.T, jobID string, imageCount int) []queue.TaskStatus {
+	t.Helper()
+
+	var tasks []queue.TaskStatus
+	waitFor(t, "tasks of "+jobID+" to finish", func() bool {
+		var err error
+		tasks, err = e.inspector.FamilyTasks(jobID, imageCount)
+		require.NoError(t, err)
+		if tasks[0].State == queue.TaskStateNotFound {
+			return false
+		}
+		for _, task := range tasks {
+			switch task.State {
+			case "pending", "active", "scheduled", "retry", "aggregating":
+				return false
+			}
+		}
+		return true
+	})
+	return tasks
+}
+
+// waitFor polls condition until it holds or pollTimeout passes
+func waitFor(t *testing.T, description string, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(pollTimeout)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", description)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func getEnvOrDefault(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
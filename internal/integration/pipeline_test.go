@@ -0,0 +1,282 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/docutag/textanalyzer/internal/models"
+	"github.com/docutag/textanalyzer/internal/queue"
+)
+
+// articleText scores well above the default enrichment threshold
+const articleText = `The city council approved the new transit plan on Tuesday after a lengthy public hearing. The plan adds twelve bus routes across the eastern districts and extends service hours on weekends. Construction of new shelters begins in the spring, and the first routes are expected to open next year.
+
+Critics say the plan ignores cyclists and pedestrians. Supporters point to faster commutes for thousands of residents. Funding comes from a regional levy approved by voters last autumn.`
+
+// lowQualityText is too short to reach the default enrichment threshold
+const lowQualityText = "click here click here buy now buy now click here"
+
+func TestPipelineWithImages(t *testing.T) {
+	env := setupEnvironment(t)
+
+	jobID := env.submit(t, map[string]interface{}{
+		"text": articleText,
+		"images": []string{
+			env.images.URL + "/photo.png",
+			env.images.URL + "/chart.png",
+		},
+		"client_metadata": map[string]string{"crawl_id": "42"},
+	})
+
+	tasks := env.waitForTasks(t, jobID, 2)
+	require.Len(t, tasks, 4)
+	for _, task := range tasks {
+		assert.Equal(t, "completed", task.State, "task %s", task.TaskID)
+	}
+
+	// Database state
+	analysis, err := env.db.GetAnalysis(context.Background(), jobID)
+	require.NoError(t, err)
+	require.NotNil(t, analysis.Metadata.EnrichedAt)
+	assert.False(t, analysis.Metadata.EnrichmentSkipped)
+	assert.Equal(t, fakeSynopsis, analysis.Metadata.Synopsis)
+	assert.Equal(t, map[string]string{"crawl_id": "42"}, analysis.ClientMetadata)
+	require.NotNil(t, analysis.Metadata.Images)
+	assert.Equal(t, 2, analysis.Metadata.Images.Accepted)
+
+	images, err := env.db.GetAnalysisImages(context.Background(), jobID)
+	require.NoError(t, err)
+	require.Len(t, images, 2)
+	for _, image := range images {
+		assert.True(t, image.Fetched, "image %s", image.URL)
+		assert.Equal(t, 320, image.Width)
+		assert.Equal(t, 200, image.Height)
+	}
+	assert.Positive(t, env.ollama.prompts.Load())
+
+	// API responses
+	status, job := env.request(t, http.MethodGet, "/api/jobs/"+jobID, nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "completed", job["status"])
+	assert.Equal(t, false, job["enrichment_skipped"])
+	require.Contains(t, job, "analysis")
+
+	status, family := env.request(t, http.MethodGet, "/api/jobs/"+jobID+"/tasks", nil)
+	require.Equal(t, http.StatusOK, status)
+	listed, _ := family["tasks"].([]interface{})
+	assert.Len(t, listed, 4)
+	enrichment, _ := family["enrichment"].(map[string]interface{})
+	imageRows, _ := enrichment["images"].([]interface{})
+	assert.Len(t, imageRows, 2)
+}
+
+func TestPipelineLowQualitySkipsEnrichment(t *testing.T) {
+	env := setupEnvironment(t)
+
+	jobID := env.submit(t, map[string]interface{}{
+		"text":   lowQualityText,
+		"images": []string{env.images.URL + "/photo.png"},
+	})
+
+	tasks := env.waitForTasks(t, jobID, 1)
+	require.Len(t, tasks, 3)
+	assert.Equal(t, "completed", tasks[0].State)
+	assert.Equal(t, queue.TaskStateNotFound, tasks[1].State, "text enrichment should not be enqueued")
+	assert.Equal(t, queue.TaskStateNotFound, tasks[2].State, "image enrichment should not be enqueued")
+
+	analysis, err := env.db.GetAnalysis(context.Background(), jobID)
+	require.NoError(t, err)
+	assert.True(t, analysis.Metadata.EnrichmentSkipped)
+	assert.Nil(t, analysis.Metadata.EnrichedAt)
+	assert.Zero(t, env.ollama.prompts.Load(), "Ollama should not be called for skipped documents")
+
+	images, err := env.db.GetAnalysisImages(context.Background(), jobID)
+	require.NoError(t, err)
+	assert.Empty(t, images)
+
+	status, job := env.request(t, http.MethodGet, "/api/jobs/"+jobID, nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "completed_offline_only", job["status"])
+	assert.Equal(t, true, job["enrichment_skipped"])
+	assert.Contains(t, job["message"], "AI enrichment skipped")
+}
+
+// Ollama being down fails every enrichment step, so the text enrichment
+// task is retried rather than saving the offline results as enriched; a
+// reanalysis after the model recovers enriches the document
+func TestPipelineOllamaFailureThenRecovery(t *testing.T) {
+	env := setupEnvironment(t)
+	env.ollama.failing.Store(true)
+
+	jobID := env.submit(t, map[string]interface{}{"text": articleText})
+
+	var job map[string]interface{}
+	waitFor(t, "text enrichment of "+jobID+" to fail", func() bool {
+		var status int
+		status, job = env.request(t, http.MethodGet, "/api/jobs/"+jobID, nil)
+		return status == http.StatusOK && job["processing_stage"] == models.ProcessingStageEnrichmentFailed
+	})
+	// The queue reports the task still running or awaiting its retry
+	assert.Contains(t, []interface{}{"active", "retrying"}, job["status"])
+	assert.LessOrEqual(t, job["retry_count"], float64(1))
+	assert.Contains(t, job["last_error"], "AI enrichment steps failed")
+	assert.Positive(t, env.ollama.prompts.Load(), "enrichment should have tried Ollama")
+
+	// The stage is recorded just before the task is handed back for retry
+	waitFor(t, "text enrichment of "+jobID+" to be retried", func() bool {
+		tasks, err := env.inspector.FamilyTasks(jobID, 0)
+		require.NoError(t, err)
+		return tasks[1].State == "retry"
+	})
+
+	analysis, err := env.db.GetAnalysis(context.Background(), jobID)
+	require.NoError(t, err)
+	assert.Nil(t, analysis.Metadata.EnrichedAt, "a failed enrichment should not be saved")
+
+	// Once Ollama recovers, reanalysis regenerates the synopsis with the model
+	env.ollama.failing.Store(false)
+	status, reanalyzed := env.request(t, http.MethodPost, fmt.Sprintf("/api/analyses/%s/reanalyze", jobID), nil)
+	require.Equal(t, http.StatusOK, status, "reanalyze response: %v", reanalyzed)
+
+	analysis, err = env.db.GetAnalysis(context.Background(), jobID)
+	require.NoError(t, err)
+	assert.Equal(t, fakeSynopsis, analysis.Metadata.Synopsis)
+}
+
+// Documents submitted with store_text false keep only derived metadata, and
+// their tasks are not retained once complete
+func TestPipelineRedactedText(t *testing.T) {
+	env := setupEnvironment(t)
+
+	jobID := env.submit(t, map[string]interface{}{
+		"text":               articleText,
+		"store_text":         false,
+		"store_cleaned_text": false,
+	})
+
+	var job map[string]interface{}
+	waitFor(t, "job "+jobID+" to complete", func() bool {
+		var status int
+		status, job = env.request(t, http.MethodGet, "/api/jobs/"+jobID, nil)
+		return status == http.StatusOK && job["status"] == "completed"
+	})
+
+	analysis, err := env.db.GetAnalysis(context.Background(), jobID)
+	require.NoError(t, err)
+	assert.Empty(t, analysis.Text)
+	assert.Empty(t, analysis.Metadata.CleanedText)
+	require.NotNil(t, analysis.Metadata.Redaction)
+	sum := sha256.Sum256([]byte(articleText))
+	assert.Equal(t, hex.EncodeToString(sum[:]), analysis.Metadata.Redaction.TextSHA256)
+	assert.Equal(t, fakeSynopsis, analysis.Metadata.Synopsis, "enrichment still sees the text")
+	assert.NotEmpty(t, analysis.Metadata.Tags)
+
+	tasks, err := env.inspector.FamilyTasks(jobID, 0)
+	require.NoError(t, err)
+	for _, task := range tasks {
+		assert.Equal(t, queue.TaskStateNotFound, task.State, "task %s should not be retained", task.TaskID)
+	}
+
+	returned, _ := job["analysis"].(map[string]interface{})
+	assert.NotContains(t, returned, "text")
+
+	status, resp := env.request(t, http.MethodPost, fmt.Sprintf("/api/analyses/%s/reanalyze", jobID), nil)
+	assert.Equal(t, http.StatusConflict, status, "reanalyze response: %v", resp)
+}
+
+// A task that exhausts its retries before the analysis is saved is reported
+// as archived, with its last error, instead of not found
+func TestPipelineArchivedTask(t *testing.T) {
+	env := setupEnvironment(t)
+
+	jobID := fmt.Sprintf("archived-%d", time.Now().UnixNano())
+	client := asynq.NewClient(asynq.RedisClientOpt{Addr: env.redisAddr})
+	t.Cleanup(func() { client.Close() })
+
+	// The worker cannot decode the payload, and the task allows no retries
+	_, err := client.Enqueue(asynq.NewTask(queue.TypeProcessDocument, []byte("not a payload")),
+		asynq.TaskID(queue.ProcessDocumentTaskID(jobID)),
+		asynq.Queue("offline-processing"),
+		asynq.MaxRetry(0),
+		asynq.Retention(time.Hour))
+	require.NoError(t, err)
+
+	var job map[string]interface{}
+	waitFor(t, "task of "+jobID+" to be archived", func() bool {
+		var status int
+		status, job = env.request(t, http.MethodGet, "/api/jobs/"+jobID, nil)
+		return status == http.StatusOK && job["status"] == "archived"
+	})
+	assert.NotEmpty(t, job["last_error"])
+	assert.Contains(t, job["message"], "archived after 0 retries")
+	assert.NotContains(t, job, "analysis")
+
+	task, _ := job["queue"].(map[string]interface{})
+	assert.Equal(t, jobID, task["task_id"])
+	assert.Equal(t, "archived", task["state"])
+}
+
+// Cancelling a job whose enrichment waits to retry deletes the retry and
+// leaves the analysis cancelled with its offline results
+func TestPipelineCancelRetryingEnrichment(t *testing.T) {
+	env := setupEnvironment(t)
+	env.ollama.failing.Store(true)
+
+	jobID := env.submit(t, map[string]interface{}{"text": articleText})
+	waitFor(t, "text enrichment of "+jobID+" to be retried", func() bool {
+		tasks, err := env.inspector.FamilyTasks(jobID, 0)
+		require.NoError(t, err)
+		return tasks[1].State == "retry"
+	})
+
+	status, resp := env.request(t, http.MethodDelete, "/api/jobs/"+jobID, nil)
+	require.Equal(t, http.StatusOK, status, "cancel response: %v", resp)
+	assert.Equal(t, false, resp["active"])
+	assert.Equal(t, models.ProcessingStageCancelled, resp["processing_stage"])
+	cancelled, _ := resp["cancelled"].([]interface{})
+	require.Len(t, cancelled, 1)
+	assert.Equal(t, queue.TextEnrichTaskID(jobID), cancelled[0].(map[string]interface{})["task_id"])
+
+	tasks, err := env.inspector.FamilyTasks(jobID, 0)
+	require.NoError(t, err)
+	assert.Equal(t, queue.TaskStateNotFound, tasks[1].State)
+
+	status, job := env.request(t, http.MethodGet, "/api/jobs/"+jobID, nil)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "cancelled", job["status"])
+
+	// Nothing is left to cancel
+	status, _ = env.request(t, http.MethodDelete, "/api/jobs/"+jobID, nil)
+	assert.Equal(t, http.StatusConflict, status)
+}
+
+// A page scraped again is answered with its first analysis once that has
+// been saved, unless the submission forces a new one
+func TestPipelineDeduplicatesResubmission(t *testing.T) {
+	env := setupEnvironment(t)
+
+	jobID := env.submit(t, map[string]interface{}{"text": articleText})
+	waitFor(t, "offline analysis of "+jobID, func() bool {
+		_, err := env.db.GetAnalysis(context.Background(), jobID)
+		return err == nil
+	})
+
+	status, resp := env.request(t, http.MethodPost, "/api/analyze", map[string]interface{}{"text": "\n" + articleText + "\n"})
+	require.Equal(t, http.StatusOK, status, "analyze response: %v", resp)
+	assert.Equal(t, jobID, resp["job_id"])
+	assert.Equal(t, true, resp["deduplicated"])
+
+	forcedID := env.submit(t, map[string]interface{}{"text": articleText, "force": true})
+	assert.NotEqual(t, jobID, forcedID)
+}
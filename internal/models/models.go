@@ -4,12 +4,17 @@ import "time"
 
 // Analysis represents a text analysis with its metadata
 type Analysis struct {
-	ID           string    `json:"id"`
-	Text         string    `json:"text"`
-	OriginalHTML string    `json:"original_html,omitempty"` // Compressed + base64 encoded original HTML/raw text
-	Metadata     Metadata  `json:"metadata"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID             string            `json:"id"`
+	Text           string            `json:"text,omitempty"`          // Empty when the text was not stored (see Metadata.Redaction)
+	OriginalHTML   string            `json:"original_html,omitempty"` // Compressed + base64 encoded original HTML/raw text
+	Metadata       Metadata          `json:"metadata"`
+	ClientMetadata map[string]string `json:"client_metadata,omitempty"` // Caller-supplied identifiers, returned as submitted
+	SourceURL      string            `json:"source_url,omitempty"`      // Normalized URL of the page the text came from
+	TextHash       string            `json:"text_hash,omitempty"`       // Hash of the normalized text, held by one analysis of each text
+	CallbackURL    string            `json:"callback_url,omitempty"`    // Notified when processing reaches a terminal state
+	Images         []*ImageMetadata  `json:"images,omitempty"`          // Enriched images, only loaded when a single analysis is requested
+	CreatedAt      time.Time         `json:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at"`
 }
 
 // Metadata contains all extracted information from text analysis
@@ -21,9 +26,20 @@ type Metadata struct {
 	ParagraphCount    int     `json:"paragraph_count"`
 	AverageWordLength float64 `json:"average_word_length"`
 
+	// Format the text was read as ("markdown", or empty for plain text), and
+	// the code blocks left out of the statistics of Markdown
+	Format     string `json:"format,omitempty"`
+	CodeBlocks int    `json:"code_blocks,omitempty"`
+
 	// Sentiment analysis
-	Sentiment      string  `json:"sentiment"`       // positive, negative, neutral
-	SentimentScore float64 `json:"sentiment_score"` // -1.0 to 1.0
+	Sentiment           string    `json:"sentiment"`                      // positive, negative, neutral
+	SentimentScore      float64   `json:"sentiment_score"`                // -1.0 to 1.0
+	SentimentTrajectory []float64 `json:"sentiment_trajectory,omitempty"` // Average sentiment of up to 10 equal runs of sentences
+	SentimentArc        string    `json:"sentiment_arc,omitempty"`        // steady, rising, falling, valley or peak
+
+	// Sentiment of the sentences with sentiment words, at most the 100 most
+	// strongly worded
+	SentenceSentiments []SentenceSentiment `json:"sentence_sentiments,omitempty"`
 
 	// Important words and phrases
 	TopWords    []WordFrequency `json:"top_words"`
@@ -37,11 +53,44 @@ type Metadata struct {
 	PotentialURLs  []string `json:"potential_urls"`
 	EmailAddresses []string `json:"email_addresses"`
 
+	// Dates in PotentialDates and relative dates such as "last Tuesday",
+	// normalized to one ISO 8601 day each, in chronological order
+	Dates []DateMention `json:"dates,omitempty"`
+
+	// Named entities with their type and count, most frequent first.
+	// NamedEntities lists the same names for clients reading the flat list.
+	Entities []NamedEntity `json:"entities,omitempty"`
+
+	// Phone numbers, E.164 when written with a country code, and monetary
+	// amounts and percentages in order of appearance
+	PhoneNumbers    []string         `json:"phone_numbers,omitempty"`
+	MonetaryAmounts []MonetaryAmount `json:"monetary_amounts,omitempty"`
+	Percentages     []Percentage     `json:"percentages,omitempty"`
+
+	// Personal data found by the rule-based scanner
+	PII *PIISummary `json:"pii,omitempty"`
+
+	// Top words, key terms and sentiment per section, when requested
+	Sections []SectionSummary `json:"sections,omitempty"`
+
 	// Readability
-	ReadabilityScore  float64 `json:"readability_score"`
-	ReadabilityLevel  string  `json:"readability_level"`
-	ComplexWordCount  int     `json:"complex_word_count"`
-	AvgSentenceLength float64 `json:"avg_sentence_length"`
+	ReadabilityScore   float64 `json:"readability_score"`
+	ReadabilityLevel   string  `json:"readability_level"`
+	ReadabilityFormula string  `json:"readability_formula,omitempty"` // Primary English formula (flesch_reading_ease by default), "lix" (other languages) or empty when none applies
+	ComplexWordCount   int     `json:"complex_word_count"`
+	AvgSentenceLength  float64 `json:"avg_sentence_length"`
+
+	// Score of every readability formula that applies, by formula, and the
+	// US school grade averaged from the grade formulas among them
+	ReadabilityScores map[string]float64 `json:"readability_scores,omitempty"`
+	GradeLevel        float64            `json:"grade_level,omitempty"`
+
+	// Reading time and vocabulary measures, all zero for texts without words
+	ReadingTimeSeconds int     `json:"reading_time_seconds,omitempty"` // At the configured words per minute, rounded up
+	LexicalDiversity   float64 `json:"lexical_diversity"`              // Unique words over total words (type-token ratio)
+	MTLD               float64 `json:"mtld,omitempty"`                 // Measure of textual lexical diversity, stable across text lengths; omitted when no word repeats
+	StopwordRatio      float64 `json:"stopword_ratio"`                 // Share of words that are stop words of the detected language
+	LongWordRatio      float64 `json:"long_word_ratio"`                // Share of words of 7 or more letters
 
 	// References to verify
 	References []Reference `json:"references"`
@@ -50,7 +99,8 @@ type Metadata struct {
 	Tags []string `json:"tags"`
 
 	// Language indicators
-	Language           string  `json:"language"`
+	Language           string  `json:"language"`            // ISO 639-1 code, or "unknown" for texts under 50 words
+	LanguageConfidence float64 `json:"language_confidence"` // Share of the evidence for Language, 0-1
 	QuestionCount      int     `json:"question_count"`
 	ExclamationCount   int     `json:"exclamation_count"`
 	CapitalizedPercent float64 `json:"capitalized_percent"`
@@ -59,11 +109,209 @@ type Metadata struct {
 	Synopsis               string            `json:"synopsis"`                  // 3-4 sentence summary
 	CleanedText            string            `json:"cleaned_text"`              // AI-cleaned text with artifacts removed
 	HeuristicCleanedText   string            `json:"heuristic_cleaned_text"`    // Rule-based/heuristic cleaned text
+	TranslatedText         string            `json:"translated_text"`           // Cleaned text translated for AI enrichment, when not in the TRANSLATE_TO language
 	EditorialAnalysis      string            `json:"editorial_analysis"`        // Bias, motivation, and slant analysis
+	Editorial              *EditorialResult  `json:"editorial,omitempty"`       // Purpose, bias and tone classification behind EditorialAnalysis
 	AIDetection            AIDetectionResult `json:"ai_detection"`              // AI-generated content detection
 
 	// Quality scoring
 	QualityScore *TextQualityScore `json:"quality_score,omitempty"` // Text quality assessment
+	Coherence    *CoherenceMetrics `json:"coherence,omitempty"`     // Rule-based coherence measures behind the quality score
+
+	// Enrichment gating
+	Source              string   `json:"source,omitempty"`               // Content source label supplied with the request
+	EnrichmentThreshold *float64 `json:"enrichment_threshold,omitempty"` // Quality score required for AI enrichment
+	EnrichmentSkipped   bool     `json:"enrichment_skipped,omitempty"`   // Whether AI enrichment was skipped due to the threshold
+	OfflineOnly         bool     `json:"offline_only,omitempty"`         // Whether AI enrichment was skipped because the queues were saturated
+	ForceEnrichment     bool     `json:"force_enrichment,omitempty"`     // Whether AI enrichment was requested whatever the quality score
+	SkipEnrichment      bool     `json:"skip_enrichment,omitempty"`      // Whether the request asked for no AI enrichment
+	RedactPII           bool     `json:"redact_pii,omitempty"`           // Whether the request had personal data masked in text sent to the model
+
+	// Queue priority the document was processed at (high, normal, low)
+	Priority string `json:"priority,omitempty"`
+
+	// Latest earlier analysis of the same source URL, and whether the text
+	// is identical to it apart from whitespace
+	PreviousAnalysisID string `json:"previous_analysis_id,omitempty"`
+	ContentUnchanged   bool   `json:"content_unchanged,omitempty"`
+
+	// Synopsis length and style requested for this analysis
+	SynopsisStyle    string `json:"synopsis_style,omitempty"`     // teaser, standard or abstract
+	SynopsisMaxWords int    `json:"synopsis_max_words,omitempty"` // Word limit for the synopsis (0 for none)
+
+	// Model that produced the AI-derived fields
+	EnrichmentModel string `json:"enrichment_model,omitempty"`
+
+	// Models that produced the AI responses, comma-separated in order of first
+	// use. It differs from EnrichmentModel when calls fell back to other models.
+	AIModel string `json:"ai_model,omitempty"`
+
+	// Images submitted with the document and how many were enriched
+	Images *ImageCounts `json:"images,omitempty"`
+
+	// Notable processing events, such as images skipped over the limit
+	Events []AnalysisEvent `json:"events,omitempty"`
+
+	// AI enrichment steps requested for this analysis (nil enables every step)
+	Enrichment   *EnrichmentOptions `json:"enrichment,omitempty"`
+	SkippedSteps []string           `json:"skipped_steps,omitempty"` // Disabled steps whose fields are left empty
+	EnrichedAt   *time.Time         `json:"enriched_at,omitempty"`   // When AI enrichment completed
+
+	// Outcome of each AI enrichment step, keyed by step name: done, failed,
+	// skipped_low_quality, skipped_disabled or fallback_rule_based
+	EnrichmentStatus map[string]string `json:"enrichment_status,omitempty"`
+
+	// Configuration that produced the AI-derived fields, captured when the
+	// analysis was last enriched
+	Provenance *Provenance `json:"provenance,omitempty"`
+
+	// Degenerate is set when the input had no words to analyze (empty,
+	// whitespace or punctuation only) and only minimal metadata was produced
+	Degenerate bool `json:"degenerate,omitempty"`
+
+	// Redaction is set when the source text was not stored
+	Redaction *TextRedaction `json:"redaction,omitempty"`
+
+	// FrequencyTruncation is set when the text had more distinct words or
+	// phrases than are tracked, so rare entries were dropped from the counts
+	FrequencyTruncation *FrequencyTruncation `json:"frequency_truncation,omitempty"`
+
+	// Chunking is set when the text was too long for one prompt and was sent
+	// to the model in chunks
+	Chunking *Chunking `json:"chunking,omitempty"`
+}
+
+// Provenance is a snapshot of the model, prompts and options an analysis was
+// enriched with, so odd results can be traced to the configuration behind
+// them and re-enrichment can target analyses whose configuration changed
+type Provenance struct {
+	Model               string            `json:"model,omitempty"`         // Ollama model (empty when enrichment was rule-based)
+	PromptHashes        map[string]string `json:"prompt_hashes,omitempty"` // Short SHA-256 of each prompt template by step
+	EnrichmentThreshold float64           `json:"enrichment_threshold"`    // Quality score required for AI enrichment
+	EnrichmentSteps     []string          `json:"enrichment_steps"`        // AI enrichment steps enabled
+	SynopsisStyle       string            `json:"synopsis_style,omitempty"`
+	SynopsisMaxWords    int               `json:"synopsis_max_words,omitempty"`
+	MaxSections         int               `json:"max_sections"`        // Sections summarized per document
+	MaxTrackedWords     int               `json:"max_tracked_words"`   // Cap on distinct words counted
+	MaxTrackedPhrases   int               `json:"max_tracked_phrases"` // Cap on distinct phrases counted
+	ServiceVersion      string            `json:"service_version,omitempty"`
+	CapturedAt          time.Time         `json:"captured_at"`
+}
+
+// FrequencyTruncation records which frequency counts hit their cap on
+// distinct entries. Top entries stay accurate but their counts may be
+// slightly low, and the unique word count is an estimate.
+type FrequencyTruncation struct {
+	Words   bool `json:"words,omitempty"`   // TopWords, KeyTerms and UniqueWords
+	Phrases bool `json:"phrases,omitempty"` // TopPhrases
+}
+
+// Chunking records how a text too long for one prompt was split for AI
+// enrichment. Synopses, tags and references are merged across chunks, the
+// cleaned text is the cleaned chunks joined, and the remaining steps judge
+// the first chunk.
+type Chunking struct {
+	Chunks    int  `json:"chunks"`              // Chunks the text was processed in
+	Truncated bool `json:"truncated,omitempty"` // Text past the last chunk was dropped
+}
+
+// TextRedaction describes an analysis whose source text was not stored; only
+// derived metadata and a hash of the text are kept
+type TextRedaction struct {
+	TextSHA256         string `json:"text_sha256"`                    // Hex SHA-256 of the original text
+	CleanedTextDropped bool   `json:"cleaned_text_dropped,omitempty"` // CleanedText was not stored either
+}
+
+// EnrichmentOptions enables or disables individual AI enrichment steps.
+// Disabled steps make no Ollama calls and leave their fields empty.
+type EnrichmentOptions struct {
+	Synopsis    bool `json:"synopsis"`     // Synopsis
+	Clean       bool `json:"clean"`        // CleanedText
+	Editorial   bool `json:"editorial"`    // EditorialAnalysis
+	Tags        bool `json:"tags"`         // AI-generated tags
+	References  bool `json:"references"`   // AI-extracted references
+	AIDetection bool `json:"ai_detection"` // AIDetection
+	Quality     bool `json:"quality"`      // AI quality score
+}
+
+// ImageCounts reports how many of a document's image URLs were accepted for enrichment
+type ImageCounts struct {
+	Submitted int `json:"submitted"`
+	Accepted  int `json:"accepted"`
+	Skipped   int `json:"skipped"` // Duplicate, invalid and over-limit URLs
+}
+
+// Analysis event types
+const (
+	EventImagesSkipped = "images_skipped"
+	EventOfflineOnly   = "offline_only"
+)
+
+// AnalysisEvent records something notable that happened while processing an analysis
+type AnalysisEvent struct {
+	Type      string    `json:"type"`
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ProcessingOptions holds per-request settings that travel with a document
+// through the processing pipeline
+type ProcessingOptions struct {
+	Source              string   `json:"source,omitempty"`               // Content source label (e.g. "memo", "forum")
+	SourceURL           string   `json:"source_url,omitempty"`           // Normalized URL of the page the text came from
+	EnrichmentThreshold *float64 `json:"enrichment_threshold,omitempty"` // Effective quality threshold for AI enrichment
+	Priority            string   `json:"priority,omitempty"`             // Queue priority: high, normal or low
+	SynopsisStyle       string   `json:"synopsis_style,omitempty"`       // Synopsis style: teaser, standard or abstract
+	SynopsisMaxWords    int      `json:"synopsis_max_words,omitempty"`   // Word limit for the synopsis (0 for none)
+	ImagesSubmitted     int      `json:"images_submitted,omitempty"`     // Image URLs in the request, before the API dropped any
+	TextHash            string   `json:"text_hash,omitempty"`            // Hash of the normalized text, claimed unless another analysis holds it
+	CallbackURL         string   `json:"callback_url,omitempty"`         // URL notified when processing reaches a terminal state
+
+	// AI enrichment steps to run (nil enables every step)
+	Enrichment *EnrichmentOptions `json:"enrichment,omitempty"`
+
+	// Caller-supplied identifiers stored with the analysis
+	ClientMetadata map[string]string `json:"client_metadata,omitempty"`
+
+	// Summarize top words, key terms and sentiment per section
+	Sections bool `json:"sections,omitempty"`
+
+	// Store only derived metadata, replacing the text with its hash, and
+	// optionally drop the cleaned text as well
+	RedactText      bool `json:"redact_text,omitempty"`
+	DropCleanedText bool `json:"drop_cleaned_text,omitempty"`
+
+	// Skip AI enrichment because the submission was accepted while the
+	// enrichment queue was saturated
+	OfflineOnly bool `json:"offline_only,omitempty"`
+
+	// Run AI enrichment whatever the quality score, or never enqueue it
+	ForceEnrichment bool `json:"force_enrichment,omitempty"`
+	SkipEnrichment  bool `json:"skip_enrichment,omitempty"`
+
+	// Mask personal data in the text sent to the model during AI enrichment
+	RedactPII bool `json:"redact_pii,omitempty"`
+
+	// Format of the text: "markdown", "text" or "auto" (empty) to detect Markdown
+	Format string `json:"format,omitempty"`
+}
+
+// SectionSummary describes one section of a long document, split at its
+// headings or, without headings, into fixed runs of paragraphs
+type SectionSummary struct {
+	Title     string          `json:"title,omitempty"` // Heading text, empty for untitled sections
+	Offset    int             `json:"offset"`          // Rune offset of the section in the text
+	TopWords  []WordFrequency `json:"top_words"`
+	KeyTerms  []string        `json:"key_terms"`
+	Sentiment string          `json:"sentiment"` // positive, negative, neutral
+}
+
+// SentenceSentiment is the sentiment of one sentence of the text
+type SentenceSentiment struct {
+	Offset    int     `json:"offset"`    // Rune offset of the sentence in the text
+	Length    int     `json:"length"`    // Length of the sentence in runes
+	Sentiment string  `json:"sentiment"` // positive, negative, neutral
+	Score     float64 `json:"score"`     // -1.0 to 1.0
 }
 
 // WordFrequency represents a word and its frequency
@@ -86,6 +334,57 @@ type Reference struct {
 	Confidence string `json:"confidence"` // high, medium, low
 }
 
+// MonetaryAmount is an amount of money found in a text
+type MonetaryAmount struct {
+	Raw      string  `json:"raw"`      // As written
+	Currency string  `json:"currency"` // ISO 4217 code
+	Value    float64 `json:"value"`    // With scale words applied: "$1.2bn" is 1200000000
+}
+
+// Percentage is a percentage found in a text
+type Percentage struct {
+	Raw   string  `json:"raw"`   // As written
+	Value float64 `json:"value"` // 12.5 for "12.5%"
+}
+
+// Categories of personal data counted in PIISummary
+const (
+	PIIEmail      = "email"
+	PIIPhone      = "phone"
+	PIISSN        = "ssn"
+	PIICreditCard = "credit_card"
+	PIIIBAN       = "iban"
+	PIIAddress    = "address"
+)
+
+// PIISummary counts the personal data found in a text by category
+type PIISummary struct {
+	ContainsPII bool           `json:"contains_pii"`
+	Counts      map[string]int `json:"counts,omitempty"` // Occurrences by category, for the categories found
+}
+
+// DateMention is a date found in a text and the day it names
+type DateMention struct {
+	Raw        string `json:"raw"`        // As written
+	Normalized string `json:"normalized"` // YYYY-MM-DD
+	Confidence string `json:"confidence"` // high, medium, low
+}
+
+// Named entity types
+const (
+	EntityPerson       = "person"
+	EntityOrganization = "organization"
+	EntityLocation     = "location"
+	EntityOther        = "other"
+)
+
+// NamedEntity is a name found in a text, classified by heuristics
+type NamedEntity struct {
+	Text  string `json:"text"`
+	Type  string `json:"type"`  // person, organization, location or other
+	Count int    `json:"count"` // Occurrences in the text
+}
+
 // AIDetectionResult represents the analysis of whether content was AI-generated
 type AIDetectionResult struct {
 	Likelihood string   `json:"likelihood"`  // very_likely, likely, possible, unlikely, very_unlikely
@@ -95,6 +394,14 @@ type AIDetectionResult struct {
 	HumanScore float64  `json:"human_score"` // 0-100, higher means more likely human-written
 }
 
+// EditorialResult classifies the purpose, slant and tone of a text
+type EditorialResult struct {
+	Purpose string `json:"purpose"` // informational, persuasive, entertainment, advertorial, or empty when unclear
+	Bias    string `json:"bias"`    // left, center-left, center, center-right, right, commercial, academic, none, or empty when unclear
+	Tone    string `json:"tone"`    // Free-form, such as neutral or alarmist
+	Summary string `json:"summary"` // Short assessment, also stored as EditorialAnalysis
+}
+
 // TextQualityScore represents quality assessment for text content
 type TextQualityScore struct {
 	Score               float64  `json:"score"`                // 0.0 to 1.0, higher is better quality
@@ -105,3 +412,195 @@ type TextQualityScore struct {
 	ProblemsDetected    []string `json:"problems_detected"`    // Issues found in the text
 	AIUsed              bool     `json:"ai_used"`              // Whether AI (Ollama) was used for scoring (true) or rule-based fallback (false)
 }
+
+// CoherenceMetrics holds rule-based measures of how well a text's sentences connect
+type CoherenceMetrics struct {
+	TransitionWordsPerSentence float64 `json:"transition_words_per_sentence"` // Connective words ("however", "therefore") per sentence
+	CoherenceMarkerRatio       float64 `json:"coherence_marker_ratio"`        // Back-references ("it", "this", "the") per word
+	MeanSentenceOverlap        float64 `json:"mean_sentence_overlap"`         // Mean Jaccard similarity of adjacent sentences' vocabulary
+	LowOverlapRatio            float64 `json:"low_overlap_ratio"`             // Share of adjacent sentences with almost no shared vocabulary
+	StopwordRatio              float64 `json:"stopword_ratio"`                // Share of words that are stop words
+	IsListLike                 bool    `json:"is_list_like"`                  // Whether the text reads as disconnected list items
+}
+
+// ImageMetadata represents offline metadata gathered for an image referenced by an analysis
+type ImageMetadata struct {
+	AnalysisID      string    `json:"analysis_id"`
+	ImageIndex      int       `json:"image_index"`
+	URL             string    `json:"url"`
+	Domain          string    `json:"domain,omitempty"`
+	Format          string    `json:"format"`                   // jpeg, png, gif, webp, svg, unknown
+	ContentType     string    `json:"content_type,omitempty"`   // Content-Type reported by the server
+	ContentLength   int64     `json:"content_length,omitempty"` // Size in bytes reported by the server (0 if unknown)
+	Width           int       `json:"width,omitempty"`
+	Height          int       `json:"height,omitempty"`
+	StatusCode      int       `json:"status_code,omitempty"` // HTTP status of the probe (0 if not fetched)
+	Fetched         bool      `json:"fetched"`               // Whether the image was probed over HTTP
+	Oversized       bool      `json:"oversized,omitempty"`   // Whether the image exceeded the probe size limit
+	IsTrackingPixel bool      `json:"is_tracking_pixel,omitempty"`
+	Error           string    `json:"error,omitempty"`      // Probe failure, if any
+	AICaption       string    `json:"ai_caption,omitempty"` // Caption from the vision model, when images are described
+	AITags          []string  `json:"ai_tags,omitempty"`    // Tags from the vision model, also merged into the analysis tags
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// RelatedTag is a tag that appears on the same analyses as another tag
+type RelatedTag struct {
+	Tag   string  `json:"tag"`
+	Count int     `json:"count"` // Analyses carrying both tags
+	Lift  float64 `json:"lift"`  // How much more often the tags co-occur than if independent (1.0 = no association)
+}
+
+// RelatedAnalysis is an analysis sharing topic tags with another, scored by
+// the tags and key terms they share
+type RelatedAnalysis struct {
+	ID             string    `json:"id"`
+	SourceURL      string    `json:"source_url,omitempty"`
+	Synopsis       string    `json:"synopsis,omitempty"`
+	SharedTags     []string  `json:"shared_tags"`
+	SharedKeyTerms []string  `json:"shared_key_terms"`
+	Score          int       `json:"score"` // Weighted shared tags plus shared key terms
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// CorpusStats aggregates the analyses created since a time, or all of them
+type CorpusStats struct {
+	Since         *time.Time     `json:"since,omitempty"`
+	TotalAnalyses int            `json:"total_analyses"`
+	ByStage       map[string]int `json:"by_stage"`    // Analyses per processing stage
+	ByLanguage    map[string]int `json:"by_language"` // Analyses per detected language
+	Quality       QualityStats   `json:"quality"`
+	TopTags       []TagCount     `json:"top_tags"` // Most common topic tags, structural tags excluded
+	PerDay        []DailyCount   `json:"per_day"`  // Analyses created on each of the last 30 days (UTC), oldest first
+	GeneratedAt   time.Time      `json:"generated_at"`
+}
+
+// QualityStats summarizes the quality scores of the analyses that have one
+type QualityStats struct {
+	Scored  int      `json:"scored"`
+	Average *float64 `json:"average"` // Nil when no analysis is scored
+	Median  *float64 `json:"median"`
+}
+
+// TagCount is a tag and the number of analyses carrying it
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// TagRename reports a tag renamed, or merged into an existing tag, across
+// the analyses carrying it
+type TagRename struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Analyses int    `json:"analyses"` // Analyses carrying From
+	Merged   int    `json:"merged"`   // Of those, analyses already carrying To
+	DryRun   bool   `json:"dry_run"`
+}
+
+// DailyCount is the number of analyses created on a day
+type DailyCount struct {
+	Date  string `json:"date"` // YYYY-MM-DD
+	Count int    `json:"count"`
+}
+
+// SearchMatch is an analysis found by full-text search, with its relevance
+// and an excerpt of the text around the matched terms
+type SearchMatch struct {
+	*Analysis
+	Rank     float64 `json:"rank"`     // ts_rank of the match; higher is more relevant
+	Headline string  `json:"headline"` // Excerpt with matched terms wrapped in <mark> tags
+}
+
+// WorkerHeartbeat is a periodic liveness report written by a queue worker
+type WorkerHeartbeat struct {
+	WorkerID            string     `json:"worker_id"`
+	HeartbeatAt         time.Time  `json:"heartbeat_at"`
+	StartedAt           time.Time  `json:"started_at"`
+	ActiveTasks         int        `json:"active_tasks"`
+	ProcessedTasks      int64      `json:"processed_tasks"`
+	FailedTasks         int64      `json:"failed_tasks"`
+	LastTaskCompletedAt *time.Time `json:"last_task_completed_at,omitempty"`
+}
+
+// WorkerSettings are the queue worker settings that can be changed at runtime
+type WorkerSettings struct {
+	Concurrency    int            `json:"concurrency"`     // Tasks processed at once
+	QueueWeights   map[string]int `json:"queue_weights"`   // Processing weight of each queue
+	StrictPriority bool           `json:"strict_priority"` // Drain higher-weight queues before lower ones
+}
+
+// RevisionFields holds the AI-derived fields of an analysis that are replaced on re-enrichment
+type RevisionFields struct {
+	Synopsis          string            `json:"synopsis"`
+	EditorialAnalysis string            `json:"editorial_analysis"`
+	Editorial         *EditorialResult  `json:"editorial,omitempty"`
+	Tags              []string          `json:"tags"`
+	QualityScore      *TextQualityScore `json:"quality_score,omitempty"`
+	AIDetection       AIDetectionResult `json:"ai_detection"`
+}
+
+// AnalysisRevision is a snapshot of an analysis's AI-derived fields taken before re-enrichment
+type AnalysisRevision struct {
+	AnalysisID string         `json:"analysis_id"`
+	Revision   int            `json:"revision"` // Sequence number per analysis, starting at 1
+	Fields     RevisionFields `json:"fields"`
+	Model      string         `json:"model,omitempty"` // Model that produced the fields
+	CreatedAt  time.Time      `json:"created_at"`
+}
+
+// Stages recorded in an analysis's history
+const (
+	HistoryStageOffline      = "offline"      // Offline analysis saved
+	HistoryStageEnrichment   = "enrichment"   // First AI enrichment saved
+	HistoryStageReenrichment = "reenrichment" // AI enrichment of an already enriched analysis saved
+	HistoryStageReanalysis   = "reanalysis"   // Synopsis regenerated on request
+)
+
+// AnalysisHistoryEntry records the metadata an analysis was saved with after
+// a processing stage. The cleaned texts are left out of the snapshot.
+type AnalysisHistoryEntry struct {
+	ID         int64     `json:"id"`
+	AnalysisID string    `json:"analysis_id"`
+	Stage      string    `json:"stage"`
+	TaskID     string    `json:"task_id,omitempty"` // Queue task that saved the analysis, if any
+	Metadata   Metadata  `json:"metadata"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ShadowResult holds the tags and quality score a candidate model produced
+// for an analysis alongside the primary model, and how far they agree. It is
+// stored apart from the analysis metadata.
+type ShadowResult struct {
+	Model             string    `json:"model"`                         // Candidate (shadow) model
+	PrimaryModel      string    `json:"primary_model"`                 // Model behind the analysis metadata
+	Tags              []string  `json:"tags"`                          // Tags with the shadow model's AI tags
+	PrimaryTags       []string  `json:"primary_tags"`                  // Tags on the analysis when compared
+	TagJaccard        float64   `json:"tag_jaccard"`                   // Overlap of Tags and PrimaryTags, 0.0 to 1.0
+	QualityScore      *float64  `json:"quality_score,omitempty"`       // Shadow model's quality score
+	PrimaryQuality    *float64  `json:"primary_quality,omitempty"`     // Primary quality score, when AI scored
+	QualityScoreDelta *float64  `json:"quality_score_delta,omitempty"` // QualityScore minus PrimaryQuality
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+// Processing stages recorded for an analysis as it moves through the pipeline
+const (
+	ProcessingStageOffline          = "offline"           // Column default for analyses saved before stages were recorded
+	ProcessingStageOfflineComplete  = "offline_complete"  // Offline analysis saved
+	ProcessingStageEnriching        = "enriching"         // AI enrichment running
+	ProcessingStageEnriched         = "enriched"          // AI enrichment saved
+	ProcessingStageEnrichmentFailed = "enrichment_failed" // An enrichment attempt failed and will be retried
+	ProcessingStageFailed           = "failed"            // Enrichment failed on its last retry
+	ProcessingStageCancelled        = "cancelled"         // Queued tasks were cancelled; remaining tasks skip their work
+)
+
+// ProcessingState is the stored progress of an analysis through the pipeline
+type ProcessingState struct {
+	Stage       string     `json:"processing_stage"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`   // First enrichment attempt
+	CompletedAt *time.Time `json:"completed_at,omitempty"` // Enrichment saved, or failed for good
+	RetryCount  int        `json:"retry_count"`            // Retries of the last failed enrichment attempt
+	MaxRetries  int        `json:"max_retries"`
+	LastError   string     `json:"last_error,omitempty"`
+}
@@ -0,0 +1,146 @@
+package ollama
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// DefaultBreakerThreshold is how many calls in a row must fail before
+	// the circuit breaker opens
+	DefaultBreakerThreshold = 5
+	// DefaultBreakerCooldown is how long the circuit breaker stays open
+	// before letting a probe call through
+	DefaultBreakerCooldown = 30 * time.Second
+)
+
+// ErrCircuitOpen is returned without calling Ollama while the circuit
+// breaker is open, after Ollama failed too many calls in a row
+var ErrCircuitOpen = errors.New("ollama circuit breaker is open")
+
+// Circuit breaker states, exported as the value of circuitState
+const (
+	breakerClosed   = 0 // Calls go through
+	breakerHalfOpen = 1 // One probe call goes through
+	breakerOpen     = 2 // Calls fail with ErrCircuitOpen
+)
+
+// breakerStateNames names the states in logs
+var breakerStateNames = map[int]string{
+	breakerClosed:   "closed",
+	breakerHalfOpen: "half-open",
+	breakerOpen:     "open",
+}
+
+// circuitState is the circuit breaker state of the clients of each model
+var circuitState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "textanalyzer_ollama_circuit_state",
+	Help: "Circuit breaker state of the Ollama clients, by model: 0 closed, 1 half-open, 2 open",
+}, []string{"model"})
+
+// breaker stops calls to an Ollama server that failed threshold calls in a
+// row. After cooldown one probe call is let through: its success closes the
+// breaker and its failure opens it again.
+type breaker struct {
+	model     string
+	threshold int // Zero disables the breaker
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    int
+	failures int       // Consecutive failures while closed
+	openedAt time.Time // When the breaker last opened
+	probing  bool      // Whether the half-open probe is in flight
+}
+
+// newBreaker creates a closed breaker for the client of model
+func newBreaker(model string, threshold int, cooldown time.Duration) *breaker {
+	b := &breaker{
+		model:     model,
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+	}
+	circuitState.WithLabelValues(model).Set(breakerClosed)
+	return b
+}
+
+// allow reports whether a call may go through, returning ErrCircuitOpen
+// while the breaker is open or its probe is in flight
+func (b *breaker) allow() error {
+	if b.threshold <= 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == breakerOpen && b.now().Sub(b.openedAt) >= b.cooldown {
+		b.setState(breakerHalfOpen)
+	}
+	switch {
+	case b.state == breakerOpen:
+		return ErrCircuitOpen
+	case b.state == breakerHalfOpen && b.probing:
+		return ErrCircuitOpen
+	case b.state == breakerHalfOpen:
+		b.probing = true
+	}
+	return nil
+}
+
+// record updates the breaker with the outcome of a call it allowed. Only
+// failures suggesting Ollama is down count; a canceled call leaves the
+// breaker as it was, and any other error means Ollama answered.
+func (b *breaker) record(err error) {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	wasProbe := b.state == breakerHalfOpen && b.probing
+	b.probing = false
+
+	switch {
+	case err != nil && errors.Is(err, context.Canceled):
+		return
+	case err != nil && (isTransient(err) || errors.Is(err, context.DeadlineExceeded)):
+		b.failures++
+		if wasProbe || b.failures >= b.threshold {
+			b.openedAt = b.now()
+			b.setState(breakerOpen)
+		}
+	default:
+		b.failures = 0
+		if wasProbe {
+			b.setState(breakerClosed)
+		}
+	}
+}
+
+// setState moves the breaker to state, logging and exporting the change.
+// The caller holds b.mu.
+func (b *breaker) setState(state int) {
+	if b.state == state {
+		return
+	}
+	slog.Warn("ollama circuit breaker state changed",
+		"model", b.model,
+		"from", breakerStateNames[b.state],
+		"to", breakerStateNames[state],
+		"consecutive_failures", b.failures,
+	)
+	b.state = state
+	if state == breakerClosed {
+		b.failures = 0
+	}
+	circuitState.WithLabelValues(b.model).Set(float64(state))
+}
@@ -0,0 +1,144 @@
+package ollama
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ollama/ollama/api"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// newTestBreaker returns a breaker whose clock only moves when advance is called
+func newTestBreaker(model string, threshold int, cooldown time.Duration) (*breaker, func(time.Duration)) {
+	now := time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC)
+	b := newBreaker(model, threshold, cooldown)
+	b.now = func() time.Time { return now }
+	return b, func(d time.Duration) { now = now.Add(d) }
+}
+
+// expectBreakerState checks the state of b and its exported gauge
+func expectBreakerState(t *testing.T, b *breaker, state int) {
+	t.Helper()
+	if b.state != state {
+		t.Errorf("Expected breaker %s, got %s", breakerStateNames[state], breakerStateNames[b.state])
+	}
+	if got := testutil.ToFloat64(circuitState.WithLabelValues(b.model)); got != float64(state) {
+		t.Errorf("Expected circuit state gauge %d, got %v", state, got)
+	}
+}
+
+func TestBreakerStateMachine(t *testing.T) {
+	b, advance := newTestBreaker("breaker-state-machine", 3, 30*time.Second)
+	unavailable := &ServerError{StatusCode: http.StatusBadGateway, Status: "502 Bad Gateway"}
+
+	// A success resets the count of consecutive failures
+	for _, err := range []error{unavailable, unavailable, nil, unavailable, unavailable} {
+		if allowErr := b.allow(); allowErr != nil {
+			t.Fatalf("Expected call to be allowed while closed, got %v", allowErr)
+		}
+		b.record(err)
+	}
+	expectBreakerState(t, b, breakerClosed)
+
+	// The third failure in a row opens the breaker
+	b.allow()
+	b.record(unavailable)
+	expectBreakerState(t, b, breakerOpen)
+	if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected ErrCircuitOpen while open, got %v", err)
+	}
+
+	// Calls fail fast until the cooldown has passed
+	advance(29 * time.Second)
+	if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected ErrCircuitOpen before the cooldown, got %v", err)
+	}
+
+	// After the cooldown one probe goes through; a failed probe reopens
+	advance(time.Second)
+	if err := b.allow(); err != nil {
+		t.Fatalf("Expected probe to be allowed after the cooldown, got %v", err)
+	}
+	expectBreakerState(t, b, breakerHalfOpen)
+	if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected ErrCircuitOpen while the probe is in flight, got %v", err)
+	}
+	b.record(context.DeadlineExceeded)
+	expectBreakerState(t, b, breakerOpen)
+	if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected ErrCircuitOpen after a failed probe, got %v", err)
+	}
+
+	// A successful probe closes the breaker
+	advance(30 * time.Second)
+	if err := b.allow(); err != nil {
+		t.Fatalf("Expected probe to be allowed after the cooldown, got %v", err)
+	}
+	b.record(nil)
+	expectBreakerState(t, b, breakerClosed)
+	if err := b.allow(); err != nil {
+		t.Errorf("Expected call to be allowed once closed, got %v", err)
+	}
+	b.record(unavailable)
+	expectBreakerState(t, b, breakerClosed)
+}
+
+func TestBreakerIgnoredErrors(t *testing.T) {
+	b, advance := newTestBreaker("breaker-ignored-errors", 2, time.Minute)
+
+	// Ollama answered, so client errors do not count as failures
+	badRequest := api.StatusError{StatusCode: http.StatusBadRequest, Status: "400 Bad Request"}
+	for i := 0; i < 3; i++ {
+		b.allow()
+		b.record(badRequest)
+	}
+	expectBreakerState(t, b, breakerClosed)
+
+	// A canceled probe leaves the breaker half-open for the next probe
+	b.allow()
+	b.record(&ServerError{StatusCode: http.StatusServiceUnavailable})
+	b.allow()
+	b.record(&ServerError{StatusCode: http.StatusServiceUnavailable})
+	expectBreakerState(t, b, breakerOpen)
+	advance(time.Minute)
+	b.allow()
+	b.record(context.Canceled)
+	expectBreakerState(t, b, breakerHalfOpen)
+	if err := b.allow(); err != nil {
+		t.Errorf("Expected another probe after a canceled one, got %v", err)
+	}
+}
+
+func TestBreakerDisabled(t *testing.T) {
+	b, _ := newTestBreaker("breaker-disabled", 0, time.Minute)
+	for i := 0; i < 10; i++ {
+		if err := b.allow(); err != nil {
+			t.Fatalf("Expected disabled breaker to allow calls, got %v", err)
+		}
+		b.record(&ServerError{StatusCode: http.StatusBadGateway})
+	}
+	expectBreakerState(t, b, breakerClosed)
+}
+
+func TestGenerateResponseCircuitOpen(t *testing.T) {
+	client, requests := newFlakyOllama(t, 10, http.StatusBadGateway, "hello")
+	client.SetRetryPolicy(1, 0)
+	client.SetCircuitBreaker(2, time.Hour)
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.GenerateResponse(context.Background(), "prompt"); errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("Call %d: expected the request to be sent, got %v", i+1, err)
+		}
+	}
+
+	_, err := client.GenerateResponse(context.Background(), "prompt")
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected ErrCircuitOpen, got %v", err)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("Expected no request while the circuit is open, got %d requests", got)
+	}
+}
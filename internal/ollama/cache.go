@@ -0,0 +1,243 @@
+package ollama
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// DefaultCacheTTL is how long a cached response is reused
+	DefaultCacheTTL = 24 * time.Hour
+	// DefaultCacheMaxEntries is the most responses a memory cache holds
+	DefaultCacheMaxEntries = 10000
+	// DefaultCacheMaxEntryBytes is the largest response cached
+	DefaultCacheMaxEntryBytes = 64 << 10
+)
+
+// redisCachePrefix prefixes the keys of responses cached in Redis
+const redisCachePrefix = "textanalyzer:llmcache:"
+
+// cachedCalls are the calls whose responses are cached. Cleaning, whose
+// response is as long as the text, and editorial analysis are not.
+var cachedCalls = map[string]bool{
+	CallSynopsis:    true,
+	CallTags:        true,
+	CallReferences:  true,
+	CallAIDetection: true,
+	CallQuality:     true,
+}
+
+// cacheRequests counts response cache lookups by call and result
+var cacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "textanalyzer_ollama_cache_requests_total",
+	Help: "Lookups in the LLM response cache, by call and result: hit, miss or error",
+}, []string{"call", "result"})
+
+// Cache stores model responses by key. Get reports whether the key was
+// found; an error is a failure of the cache itself.
+type Cache interface {
+	Get(ctx context.Context, key string) (string, bool, error)
+	Set(ctx context.Context, key, value string, ttl time.Duration) error
+}
+
+// WithCache reuses the responses of synopsis, tags, references, AI detection
+// and quality calls from cache for ttl, keyed by the model, the call, its
+// model options and its prompt. Responses over maxEntryBytes are not cached.
+// A nil cache disables caching.
+func WithCache(cache Cache, ttl time.Duration, maxEntryBytes int) Option {
+	return func(c *Client) {
+		c.cache = cache
+		c.cacheTTL = ttl
+		c.cacheMaxEntryBytes = maxEntryBytes
+	}
+}
+
+// cacheEntry is a cached response and the model that produced it, which
+// differs from the client's when a fallback model answered
+type cacheEntry struct {
+	Model    string `json:"model"`
+	Response string `json:"response"`
+}
+
+// cacheKey returns the key of the response to prompt for call
+func (c *Client) cacheKey(call, prompt string, options map[string]any) string {
+	// Maps are encoded with sorted keys, so equal options hash alike
+	encodedOptions, _ := json.Marshal(options)
+	hash := sha256.New()
+	for _, part := range []string{c.model, call, string(encodedOptions), prompt} {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// cachedResponse returns the cached response to key, if any. Failures of
+// the cache are logged and count as misses.
+func (c *Client) cachedResponse(ctx context.Context, call, key string) (cacheEntry, bool) {
+	value, ok, err := c.cache.Get(ctx, key)
+	if err != nil {
+		cacheRequests.WithLabelValues(call, "error").Inc()
+		slog.Warn("llm cache lookup failed", "model", c.model, "call", call, "error", err)
+		return cacheEntry{}, false
+	}
+	var entry cacheEntry
+	if ok {
+		if err := json.Unmarshal([]byte(value), &entry); err != nil {
+			ok = false
+		}
+	}
+	if !ok {
+		cacheRequests.WithLabelValues(call, "miss").Inc()
+		return cacheEntry{}, false
+	}
+	cacheRequests.WithLabelValues(call, "hit").Inc()
+	slog.Info("llm cache hit", "model", entry.Model, "call", call)
+	return entry, true
+}
+
+// cacheResponse caches the response model produced for key, unless it is
+// over the size limit
+func (c *Client) cacheResponse(ctx context.Context, call, key string, entry cacheEntry) {
+	if c.cacheMaxEntryBytes > 0 && len(entry.Response) > c.cacheMaxEntryBytes {
+		return
+	}
+	value, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	if err := c.cache.Set(ctx, key, string(value), c.cacheTTL); err != nil {
+		slog.Warn("llm cache store failed", "model", c.model, "call", call, "error", err)
+	}
+}
+
+// MemoryCache is a Cache holding the most recently used responses in memory
+type MemoryCache struct {
+	maxEntries int
+	now        func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // Most recently used first
+}
+
+// memoryEntry is an element of MemoryCache.order
+type memoryEntry struct {
+	key       string
+	value     string
+	expiresAt time.Time // Never when zero
+}
+
+// NewMemoryCache creates a cache holding up to maxEntries responses,
+// evicting the least recently used. A maxEntries below one uses
+// DefaultCacheMaxEntries.
+func NewMemoryCache(maxEntries int) *MemoryCache {
+	if maxEntries < 1 {
+		maxEntries = DefaultCacheMaxEntries
+	}
+	return &MemoryCache{
+		maxEntries: maxEntries,
+		now:        time.Now,
+		entries:    map[string]*list.Element{},
+		order:      list.New(),
+	}
+}
+
+// Get returns the value of key unless it is missing or expired
+func (m *MemoryCache) Get(ctx context.Context, key string) (string, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	element, ok := m.entries[key]
+	if !ok {
+		return "", false, nil
+	}
+	entry := element.Value.(*memoryEntry)
+	if !entry.expiresAt.IsZero() && !m.now().Before(entry.expiresAt) {
+		m.order.Remove(element)
+		delete(m.entries, key)
+		return "", false, nil
+	}
+	m.order.MoveToFront(element)
+	return entry.value, true, nil
+}
+
+// Set stores value under key for ttl, or until evicted when ttl is not
+// positive
+func (m *MemoryCache) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = m.now().Add(ttl)
+	}
+	if element, ok := m.entries[key]; ok {
+		entry := element.Value.(*memoryEntry)
+		entry.value, entry.expiresAt = value, expiresAt
+		m.order.MoveToFront(element)
+		return nil
+	}
+
+	m.entries[key] = m.order.PushFront(&memoryEntry{key: key, value: value, expiresAt: expiresAt})
+	for m.order.Len() > m.maxEntries {
+		oldest := m.order.Back()
+		m.order.Remove(oldest)
+		delete(m.entries, oldest.Value.(*memoryEntry).key)
+	}
+	return nil
+}
+
+// Len returns the number of cached responses, expired ones included until
+// they are looked up or evicted
+func (m *MemoryCache) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.order.Len()
+}
+
+// RedisCache is a Cache in Redis, shared by every replica of the service.
+// Entries expire through Redis.
+type RedisCache struct {
+	client *redis.Client
+}
+
+// NewRedisCache creates a cache in the Redis server at addr
+func NewRedisCache(addr string) *RedisCache {
+	return &RedisCache{client: redis.NewClient(&redis.Options{Addr: addr})}
+}
+
+// Get returns the value of key unless it is missing or expired
+func (r *RedisCache) Get(ctx context.Context, key string) (string, bool, error) {
+	value, err := r.client.Get(ctx, redisCachePrefix+key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return value, true, nil
+}
+
+// Set stores value under key for ttl, or without expiry when ttl is not
+// positive
+func (r *RedisCache) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	if ttl < 0 {
+		ttl = 0
+	}
+	return r.client.Set(ctx, redisCachePrefix+key, value, ttl).Err()
+}
+
+// Close closes the connections to Redis
+func (r *RedisCache) Close() error {
+	return r.client.Close()
+}
@@ -0,0 +1,180 @@
+package ollama
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMemoryCache(t *testing.T) {
+	ctx := context.Background()
+	cache := NewMemoryCache(2)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	cache.Set(ctx, "a", "1", time.Minute)
+	cache.Set(ctx, "b", "2", 0)
+	// Reading a makes b the least recently used
+	if value, ok, _ := cache.Get(ctx, "a"); !ok || value != "1" {
+		t.Errorf("Expected a to be cached, got %q, %v", value, ok)
+	}
+	cache.Set(ctx, "c", "3", time.Minute)
+	if _, ok, _ := cache.Get(ctx, "b"); ok {
+		t.Error("Expected the least recently used entry to be evicted")
+	}
+	if cache.Len() != 2 {
+		t.Errorf("Expected 2 entries, got %d", cache.Len())
+	}
+
+	// Entries expire after their TTL
+	now = now.Add(time.Minute)
+	if _, ok, _ := cache.Get(ctx, "a"); ok {
+		t.Error("Expected the entry to expire after its TTL")
+	}
+
+	// Setting a key again replaces its value and expiry
+	cache.Set(ctx, "c", "4", 0)
+	now = now.Add(time.Hour)
+	if value, ok, _ := cache.Get(ctx, "c"); !ok || value != "4" {
+		t.Errorf("Expected the replaced entry without expiry, got %q, %v", value, ok)
+	}
+}
+
+func TestCachedResponses(t *testing.T) {
+	cache := NewMemoryCache(0)
+	client, requests := newRecordingOllama(t, WithCache(cache, time.Hour, 0))
+	ctx := context.Background()
+
+	hits := testutil.ToFloat64(cacheRequests.WithLabelValues(CallTags, "hit"))
+	for range 2 {
+		tags, err := client.GenerateTags(ctx, "Some text.", nil)
+		if err != nil {
+			t.Fatalf("GenerateTags failed: %v", err)
+		}
+		if !reflect.DeepEqual(tags, []string{"transit"}) {
+			t.Errorf("Expected the tags of the response, got %v", tags)
+		}
+	}
+	if got := len(requests()); got != 1 {
+		t.Errorf("Expected the second call to be served from the cache, got %d requests", got)
+	}
+	if got := testutil.ToFloat64(cacheRequests.WithLabelValues(CallTags, "hit")) - hits; got != 1 {
+		t.Errorf("Expected 1 cache hit, got %v", got)
+	}
+
+	// A cached response is attributed to the model that produced it
+	usageCtx, usage := WithModelUsage(ctx)
+	if _, err := client.GenerateTags(usageCtx, "Some text.", nil); err != nil {
+		t.Fatalf("GenerateTags failed: %v", err)
+	}
+	if models := usage.Models(); !reflect.DeepEqual(models, []string{"test-model"}) {
+		t.Errorf("Expected the model to be recorded for a cached response, got %v", models)
+	}
+
+	// Other texts, other calls and uncached calls go to the model
+	client.GenerateTags(ctx, "Other text.", nil)
+	client.ExtractReferences(ctx, "Some text.")
+	client.CleanText(ctx, "Some text.")
+	client.CleanText(ctx, "Some text.")
+	if got := len(requests()); got != 5 {
+		t.Errorf("Expected 5 requests, got %d", got)
+	}
+
+	// Another model does not share the cached responses
+	other, otherRequests := newRecordingOllama(t, WithCache(cache, time.Hour, 0))
+	other.model = "other-model"
+	if _, err := other.GenerateTags(ctx, "Some text.", nil); err != nil {
+		t.Fatalf("GenerateTags failed: %v", err)
+	}
+	if got := len(otherRequests()); got != 1 {
+		t.Errorf("Expected a request for another model, got %d", got)
+	}
+}
+
+func TestCachedResponsesMaxEntryBytes(t *testing.T) {
+	cache := NewMemoryCache(0)
+	client, requests := newRecordingOllama(t, WithCache(cache, time.Hour, 5))
+
+	for range 2 {
+		if _, err := client.GenerateTags(context.Background(), "Some text.", nil); err != nil {
+			t.Fatalf("GenerateTags failed: %v", err)
+		}
+	}
+	if got := len(requests()); got != 2 || cache.Len() != 0 {
+		t.Errorf("Expected responses over the size limit not to be cached, got %d requests and %d entries", got, cache.Len())
+	}
+}
+
+func TestCachedResponsesOnlyParsed(t *testing.T) {
+	cache := NewMemoryCache(0)
+	responses := []string{"Sorry, I cannot produce tags for this.", `{"tags": ["transit"]}`}
+	calls := 0
+	client := NewWithGenerator("test-model", func(ctx context.Context, prompt string) (string, error) {
+		response := responses[min(calls, len(responses)-1)]
+		calls++
+		return response, nil
+	}, WithCache(cache, time.Hour, 0))
+	ctx := context.Background()
+
+	if _, err := client.GenerateTags(ctx, "Some text.", nil); err == nil {
+		t.Fatal("Expected the malformed response to fail")
+	}
+	if cache.Len() != 0 {
+		t.Errorf("Expected the malformed response not to be cached, got %d entries", cache.Len())
+	}
+
+	// The second call is generated again, and its parsed response cached
+	for range 2 {
+		tags, err := client.GenerateTags(ctx, "Some text.", nil)
+		if err != nil {
+			t.Fatalf("GenerateTags failed: %v", err)
+		}
+		if !reflect.DeepEqual(tags, []string{"transit"}) {
+			t.Errorf("Expected the tags of the second response, got %v", tags)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("Expected 2 generations, got %d", calls)
+	}
+
+	// A malformed entry cached before is generated again
+	responses = []string{`{"tags": ["rail"]}`}
+	cache.Set(ctx, client.cacheKey(CallTags, fmt.Sprintf(tagsPrompt, "", "Other text."), nil), `{"model":"test-model","response":"not json"}`, 0)
+	tags, err := client.GenerateTags(ctx, "Other text.", nil)
+	if err != nil || !reflect.DeepEqual(tags, []string{"rail"}) {
+		t.Errorf("Expected the malformed cached response to be generated again, got %v, %v", tags, err)
+	}
+}
+
+func TestRedisCache(t *testing.T) {
+	addr := os.Getenv("TEST_REDIS_ADDR")
+	if addr == "" {
+		addr = "localhost:6379"
+	}
+	cache := NewRedisCache(addr)
+	defer cache.Close()
+	ctx := context.Background()
+	if err := cache.client.Ping(ctx).Err(); err != nil {
+		t.Skipf("Could not connect to Redis at %s: %v (set TEST_REDIS_ADDR if needed)", addr, err)
+	}
+
+	key := "test-" + time.Now().Format(time.RFC3339Nano)
+	if _, ok, err := cache.Get(ctx, key); ok || err != nil {
+		t.Errorf("Expected a missing key, got %v, %v", ok, err)
+	}
+	if err := cache.Set(ctx, key, "value", time.Minute); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	defer cache.client.Del(ctx, redisCachePrefix+key)
+	if value, ok, err := cache.Get(ctx, key); !ok || err != nil || value != "value" {
+		t.Errorf("Expected the stored value, got %q, %v, %v", value, ok, err)
+	}
+	if ttl := cache.client.TTL(ctx, redisCachePrefix+key).Val(); ttl <= 0 || ttl > time.Minute {
+		t.Errorf("Expected the key to expire within a minute, got %v", ttl)
+	}
+}
@@ -0,0 +1,324 @@
+package ollama
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/docutag/textanalyzer/internal/tags"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// DefaultChunkBudget is the most characters of text sent in one prompt,
+	// besides the prompt's instructions. Longer texts are split into chunks.
+	DefaultChunkBudget = 24000
+	// DefaultChunkOverlap is how many characters from the end of a chunk
+	// are repeated at the start of the next, so context carries over
+	DefaultChunkOverlap = 500
+	// DefaultMaxChunks is the most chunks of a text processed; text past
+	// the last chunk is dropped, except from cleaned text
+	DefaultMaxChunks = 8
+)
+
+// chunkConcurrency is the most chunks of one text generated at a time, so a
+// long text fits in the task timeout. The client's concurrency limit still
+// applies across all callers.
+const chunkConcurrency = 4
+
+// maxMergedReferences is the most references ExtractReferences returns from
+// a chunked text, as the prompt asks for from a single one
+const maxMergedReferences = 10
+
+// chunkSeparator joins the paragraphs of a chunk and the chunks of cleaned text
+const chunkSeparator = "\n\n"
+
+// paragraphBreak matches the blank lines between paragraphs
+var paragraphBreak = regexp.MustCompile(`\n[ \t\r]*\n\s*`)
+
+// SetChunking sets the most characters of text sent in one prompt, how many
+// characters consecutive chunks share, and the most chunks processed per
+// text. A budget or maxChunks below one uses the default.
+func (c *Client) SetChunking(budget, overlap, maxChunks int) {
+	if budget < 1 {
+		budget = DefaultChunkBudget
+	}
+	if overlap < 0 {
+		overlap = 0
+	}
+	if maxChunks < 1 {
+		maxChunks = DefaultMaxChunks
+	}
+	c.chunkBudget = budget
+	c.chunkOverlap = overlap
+	c.maxChunks = maxChunks
+}
+
+// ChunkCount returns how many chunks text is processed in: one when it fits
+// in a single prompt. Text past the most chunks processed is dropped, which
+// truncated reports.
+func (c *Client) ChunkCount(text string) (chunks int, truncated bool) {
+	split, remainder := c.chunks(text, c.chunkOverlap)
+	return len(split), remainder != ""
+}
+
+// chunks splits text into the chunks sent in separate prompts, repeating
+// overlap characters of each chunk at the start of the next, and returns
+// the text past the most chunks processed
+func (c *Client) chunks(text string, overlap int) ([]string, string) {
+	return splitChunks(text, c.chunkBudget, overlap, c.maxChunks)
+}
+
+// splitChunks splits text into chunks of at most budget characters at
+// paragraph boundaries, keeping at most maxChunks and returning the dropped
+// chunks joined, or "" when none were. A paragraph longer than a chunk is
+// split after a sentence, or failing that at a space. Each chunk after the
+// first starts with the last overlap characters of the one before it.
+func splitChunks(text string, budget, overlap, maxChunks int) ([]string, string) {
+	if budget < 1 || utf8.RuneCountInString(text) <= budget {
+		return []string{text}, ""
+	}
+	// Keep half of each chunk for new text
+	if overlap > budget/2 {
+		overlap = budget / 2
+	}
+	// The overlap is joined to the chunk with a separator
+	if overlap <= len(chunkSeparator) {
+		overlap = 0
+	}
+	limit := budget - overlap
+
+	var chunks []string
+	var current strings.Builder
+	currentLen := 0
+	flush := func() {
+		if currentLen > 0 {
+			chunks = append(chunks, current.String())
+			current.Reset()
+			currentLen = 0
+		}
+	}
+	for _, paragraph := range paragraphBreak.Split(strings.TrimSpace(text), -1) {
+		for _, piece := range splitLong(paragraph, limit) {
+			pieceLen := utf8.RuneCountInString(piece)
+			if currentLen > 0 && currentLen+len(chunkSeparator)+pieceLen > limit {
+				flush()
+			}
+			if currentLen > 0 {
+				current.WriteString(chunkSeparator)
+				currentLen += len(chunkSeparator)
+			}
+			current.WriteString(piece)
+			currentLen += pieceLen
+		}
+	}
+	flush()
+
+	var remainder string
+	if maxChunks > 0 && len(chunks) > maxChunks {
+		remainder = strings.Join(chunks[maxChunks:], chunkSeparator)
+		chunks = chunks[:maxChunks]
+	}
+	if overlap > 0 {
+		overlapped := make([]string, len(chunks))
+		overlapped[0] = chunks[0]
+		for i := 1; i < len(chunks); i++ {
+			overlapped[i] = overlapTail(chunks[i-1], overlap-len(chunkSeparator)) + chunkSeparator + chunks[i]
+		}
+		chunks = overlapped
+	}
+	return chunks, remainder
+}
+
+// splitLong splits text into pieces of at most limit characters, each ending
+// after a sentence when one ends in its second half, else at its last space
+func splitLong(text string, limit int) []string {
+	var pieces []string
+	text = strings.TrimSpace(text)
+	for utf8.RuneCountInString(text) > limit {
+		cut := cutPoint(text[:runeOffset(text, limit)])
+		if piece := strings.TrimSpace(text[:cut]); piece != "" {
+			pieces = append(pieces, piece)
+		}
+		text = strings.TrimSpace(text[cut:])
+	}
+	if text != "" {
+		pieces = append(pieces, text)
+	}
+	return pieces
+}
+
+// cutPoint returns where to end a piece taken from the start of s: after
+// the last sentence ending in the second half of s, else at the last space,
+// else at the end of s
+func cutPoint(s string) int {
+	for i := len(s) - 1; i > len(s)/2; i-- {
+		if isSpace(s[i]) && strings.IndexByte(".!?", s[i-1]) >= 0 {
+			return i
+		}
+	}
+	if i := strings.LastIndexAny(s, " \t\r\n"); i > 0 {
+		return i
+	}
+	return len(s)
+}
+
+// overlapTail returns at most n characters from the end of s, starting at
+// a word
+func overlapTail(s string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	if count := utf8.RuneCountInString(s); count > n {
+		s = s[runeOffset(s, count-n):]
+		if i := strings.IndexAny(s, " \t\r\n"); i >= 0 {
+			s = s[i:]
+		}
+	}
+	return strings.TrimSpace(s)
+}
+
+// runeOffset returns the byte offset of the nth rune of s
+func runeOffset(s string, n int) int {
+	for i := range s {
+		if n == 0 {
+			return i
+		}
+		n--
+	}
+	return len(s)
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\r' || b == '\n'
+}
+
+// mapChunks calls generate for each chunk, up to chunkConcurrency at a
+// time, and returns the results in chunk order. The first error cancels the
+// calls still running.
+func mapChunks[T any](ctx context.Context, chunks []string, generate func(ctx context.Context, chunk string) (T, error)) ([]T, error) {
+	results := make([]T, len(chunks))
+	group, ctx := errgroup.WithContext(ctx)
+	group.SetLimit(chunkConcurrency)
+	for i, chunk := range chunks {
+		group.Go(func() error {
+			result, err := generate(ctx, chunk)
+			if err != nil {
+				return fmt.Errorf("chunk %d of %d: %w", i+1, len(chunks), err)
+			}
+			results[i] = result
+			return nil
+		})
+	}
+	if err := group.Wait(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// generateSynopsis summarizes each chunk of a long text, then summarizes the
+// partial synopses in the requested style
+func (c *Client) generateSynopsis(ctx context.Context, chunks []string, style string, maxWords int) (string, error) {
+	partials, err := mapChunks(ctx, chunks, func(ctx context.Context, chunk string) (string, error) {
+		return c.generateText(ctx, CallSynopsis, synopsisPrompt(chunk, SynopsisStandard, 0))
+	})
+	if err != nil {
+		return "", fmt.Errorf("synopsis of %w", err)
+	}
+	return c.generateText(ctx, CallSynopsis, synopsisPrompt(strings.Join(partials, chunkSeparator), style, maxWords))
+}
+
+// generateTags generates tags for each chunk of a long text, returning those
+// generated for the most chunks first
+func (c *Client) generateTags(ctx context.Context, chunks []string, sentiment string) ([]string, error) {
+	generated, err := mapChunks(ctx, chunks, func(ctx context.Context, chunk string) ([]string, error) {
+		return generateJSON(ctx, c, CallTags, fmt.Sprintf(tagsPrompt, sentiment, chunk), parseTags)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("tags of %w", err)
+	}
+	counts := map[string]int{}
+	var order []string
+	for _, chunkTags := range generated {
+		for _, tag := range chunkTags {
+			if counts[tag] == 0 {
+				order = append(order, tag)
+			}
+			counts[tag]++
+		}
+	}
+	return rankTags(order, counts), nil
+}
+
+// rankTags orders tags by how many chunks they were generated for, keeping
+// their first-seen order on ties, and limits them to maxGeneratedTags
+func rankTags(order []string, counts map[string]int) []string {
+	sort.SliceStable(order, func(i, j int) bool {
+		return counts[order[i]] > counts[order[j]]
+	})
+	return tags.MergeWithLimit(maxGeneratedTags, order)
+}
+
+// cleanText cleans each chunk of a long text independently and joins them.
+// The remainder past the most chunks processed is appended uncleaned, so
+// the cleaned text never loses the end of the text.
+func (c *Client) cleanText(ctx context.Context, chunks []string, remainder string) (string, error) {
+	cleaned, err := mapChunks(ctx, chunks, func(ctx context.Context, chunk string) (string, error) {
+		return c.generateText(ctx, CallClean, fmt.Sprintf(cleanTextPrompt, chunk))
+	})
+	if err != nil {
+		return "", fmt.Errorf("cleaning %w", err)
+	}
+	if remainder != "" {
+		cleaned = append(cleaned, remainder)
+	}
+	return strings.Join(cleaned, chunkSeparator), nil
+}
+
+// translate translates each chunk of a long text into language and joins them
+func (c *Client) translate(ctx context.Context, chunks []string, language string) (string, error) {
+	translated, err := mapChunks(ctx, chunks, func(ctx context.Context, chunk string) (string, error) {
+		return c.generateText(ctx, CallTranslate, fmt.Sprintf(translatePrompt, language, chunk))
+	})
+	if err != nil {
+		return "", fmt.Errorf("translating %w", err)
+	}
+	return strings.Join(translated, chunkSeparator), nil
+}
+
+// extractReferences extracts the references of each chunk of a long text,
+// dropping repeats from overlapping chunks
+func (c *Client) extractReferences(ctx context.Context, chunks []string) ([]Reference, error) {
+	extracted, err := mapChunks(ctx, chunks, func(ctx context.Context, chunk string) ([]Reference, error) {
+		return generateJSON(ctx, c, CallReferences, fmt.Sprintf(referencesPrompt, chunk), parseReferences)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("references of %w", err)
+	}
+	seen := map[string]bool{}
+	var references []Reference
+	for _, chunkReferences := range extracted {
+		for _, reference := range chunkReferences {
+			key := strings.ToLower(strings.TrimSpace(reference.Text))
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			references = append(references, reference)
+		}
+	}
+	if len(references) > maxMergedReferences {
+		references = references[:maxMergedReferences]
+	}
+	return references, nil
+}
+
+// firstChunk returns the first chunk of text, or text when it fits in one
+// prompt, for the steps that judge a sample of the text
+func (c *Client) firstChunk(text string) string {
+	chunks, _ := c.chunks(text, 0)
+	return chunks[0]
+}
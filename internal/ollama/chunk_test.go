@@ -0,0 +1,309 @@
+package ollama
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// promptOverhead bounds the characters a prompt template adds to its text
+const promptOverhead = 2500
+
+// longText returns a text of about n characters in paragraphs of a few
+// numbered sentences
+func longText(n int) string {
+	var b strings.Builder
+	for i := 0; b.Len() < n; i++ {
+		fmt.Fprintf(&b, "Sentence %d describes the transit plan in some detail. ", i)
+		if i%5 == 4 {
+			b.WriteString("\n\n")
+		}
+	}
+	return b.String()
+}
+
+// recordingGenerator returns a generator answering every prompt with
+// response, and the prompts it received
+func recordingGenerator(response func(prompt string) string) (*Client, func() []string) {
+	var mu sync.Mutex
+	var prompts []string
+	client := NewWithGenerator("model", func(ctx context.Context, prompt string) (string, error) {
+		mu.Lock()
+		prompts = append(prompts, prompt)
+		mu.Unlock()
+		return response(prompt), nil
+	})
+	return client, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), prompts...)
+	}
+}
+
+func TestSplitChunks(t *testing.T) {
+	text := longText(10000)
+
+	chunks, remainder := splitChunks(text, 2000, 200, 0)
+	if remainder != "" {
+		t.Error("Expected no truncation without a chunk limit")
+	}
+	if len(chunks) < 5 {
+		t.Fatalf("Expected at least 5 chunks, got %d", len(chunks))
+	}
+	for i, chunk := range chunks {
+		if n := utf8.RuneCountInString(chunk); n > 2000 {
+			t.Errorf("Chunk %d has %d characters, over the budget", i, n)
+		}
+		// Chunks end at a paragraph, the one before carrying over
+		if !strings.HasSuffix(chunk, "detail.") {
+			t.Errorf("Chunk %d does not end at a paragraph: %q", i, chunk[len(chunk)-20:])
+		}
+		if i > 0 {
+			previous := chunks[i-1]
+			overlap := overlapTail(previous, 200-len(chunkSeparator))
+			if overlap == "" || !strings.HasSuffix(previous, overlap) || !strings.HasPrefix(chunk, overlap+chunkSeparator) {
+				t.Errorf("Chunk %d does not start with the end of chunk %d: %q", i, i-1, overlap)
+			}
+		}
+	}
+
+	// Every sentence is kept without overlap
+	chunks, _ = splitChunks(text, 2000, 0, 0)
+	if joined := strings.Join(chunks, chunkSeparator); joined != strings.ReplaceAll(strings.TrimSpace(text), " \n\n", "\n\n") {
+		t.Error("Expected the chunks without overlap to join into the text")
+	}
+
+	// Chunks past the limit are returned as the remainder
+	limited, remainder := splitChunks(text, 2000, 0, 2)
+	if len(limited) != 2 || remainder == "" {
+		t.Errorf("Expected 2 chunks and a remainder, got %d chunks, remainder %q", len(limited), remainder)
+	}
+	if joined := strings.Join(append(limited, remainder), chunkSeparator); joined != strings.Join(chunks, chunkSeparator) {
+		t.Error("Expected the kept chunks and the remainder to join into the text")
+	}
+
+	// A text within the budget is one chunk
+	if chunks, remainder := splitChunks("Short text.", 2000, 200, 1); len(chunks) != 1 || chunks[0] != "Short text." || remainder != "" {
+		t.Errorf("Expected the short text as one chunk, got %q", chunks)
+	}
+}
+
+func TestSplitChunksLongParagraph(t *testing.T) {
+	// A paragraph over the budget is split after a sentence
+	paragraph := strings.ReplaceAll(longText(5000), "\n\n", "")
+	chunks, _ := splitChunks(paragraph, 1000, 0, 0)
+	for i, chunk := range chunks {
+		if n := utf8.RuneCountInString(chunk); n > 1000 {
+			t.Errorf("Chunk %d has %d characters, over the budget", i, n)
+		}
+		if !strings.HasSuffix(chunk, ".") {
+			t.Errorf("Chunk %d does not end after a sentence: %q", i, chunk[len(chunk)-20:])
+		}
+	}
+
+	// Without sentences it is split at spaces, and without spaces anywhere
+	words := strings.Repeat("überweisung ", 500)
+	for i, chunk := range mustSplit(t, words, 1000) {
+		if strings.Contains(chunk, "  ") || strings.HasPrefix(chunk, "berweisung") {
+			t.Errorf("Chunk %d was not split at a space", i)
+		}
+	}
+	mustSplit(t, strings.Repeat("ü", 2500), 1000)
+}
+
+// mustSplit splits text without overlap, checking that the chunks are
+// within budget and valid UTF-8
+func mustSplit(t *testing.T, text string, budget int) []string {
+	t.Helper()
+	chunks, _ := splitChunks(text, budget, 0, 0)
+	if len(chunks) < 2 {
+		t.Fatalf("Expected the text to be split, got %d chunks", len(chunks))
+	}
+	for i, chunk := range chunks {
+		if n := utf8.RuneCountInString(chunk); n > budget {
+			t.Errorf("Chunk %d has %d characters, over the budget", i, n)
+		}
+		if !utf8.ValidString(chunk) {
+			t.Errorf("Chunk %d is not valid UTF-8", i)
+		}
+	}
+	return chunks
+}
+
+func TestChunkedPromptsStayWithinBudget(t *testing.T) {
+	client, prompts := recordingGenerator(func(prompt string) string {
+		switch {
+		case strings.Contains(prompt, "Tags (JSON object only)"):
+			return `{"tags": ["transit"]}`
+		case strings.Contains(prompt, "References (JSON object)"):
+			return `{"references": []}`
+		case strings.Contains(prompt, "human_score"):
+			return `{"likelihood": "unlikely", "human_score": 80}`
+		case strings.Contains(prompt, "quality_indicators"):
+			return `{"score": 0.8}`
+		case strings.Contains(prompt, "center-left"):
+			return `{"purpose": "informational", "bias": "none", "summary": "Reports on transit."}`
+		}
+		return "Cleaned."
+	})
+	text := longText(100000)
+	ctx := context.Background()
+
+	chunks, truncated := client.ChunkCount(text)
+	if chunks < 2 || truncated {
+		t.Fatalf("Expected a 100k-character text to be chunked without truncation, got %d chunks, truncated %v", chunks, truncated)
+	}
+
+	steps := map[string]func() error{
+		"synopsis": func() error { _, err := client.GenerateSynopsis(ctx, text, SynopsisAbstract, 100); return err },
+		"clean":    func() error { _, err := client.CleanText(ctx, text); return err },
+		"clean html": func() error {
+			_, err := client.CleanTextWithHTMLContext(ctx, text, text, "<p>"+text+"</p>")
+			return err
+		},
+		"editorial": func() error { _, err := client.EditorialAnalysis(ctx, text); return err },
+		"tags": func() error {
+			_, err := client.GenerateTags(ctx, text, map[string]interface{}{"sentiment": "neutral"})
+			return err
+		},
+		"references":   func() error { _, err := client.ExtractReferences(ctx, text); return err },
+		"ai detection": func() error { _, err := client.DetectAIContent(ctx, text); return err },
+		"quality":      func() error { _, err := client.ScoreTextQuality(ctx, text); return err },
+	}
+	for name, step := range steps {
+		if err := step(); err != nil {
+			t.Errorf("%s failed: %v", name, err)
+		}
+	}
+
+	for i, prompt := range prompts() {
+		if n := utf8.RuneCountInString(prompt); n > DefaultChunkBudget+promptOverhead {
+			t.Errorf("Prompt %d has %d characters, over the chunk budget", i, n)
+		}
+	}
+}
+
+func TestChunkedMerges(t *testing.T) {
+	text := longText(5000)
+	chunks, remainder := splitChunks(text, 1000, 0, 3)
+	if len(chunks) != 3 || remainder == "" {
+		t.Fatalf("Expected 3 chunks and a remainder, got %d chunks", len(chunks))
+	}
+	// chunkIndex returns which chunk a prompt holds, as chunks run concurrently
+	chunkIndex := func(prompt string) int {
+		for i, chunk := range chunks {
+			if strings.Contains(prompt, chunk) {
+				return i
+			}
+		}
+		return -1
+	}
+
+	chunkTags := [][]string{{"transit", "budget"}, {"transit", "council"}, {"council", "transit", "cycling"}}
+	client, prompts := recordingGenerator(func(prompt string) string {
+		i := chunkIndex(prompt)
+		switch {
+		case strings.Contains(prompt, "Tags (JSON object only)"):
+			return `{"tags": ["` + strings.Join(chunkTags[i], `", "`) + `"]}`
+		case strings.Contains(prompt, "Text to process"):
+			return fmt.Sprintf("Cleaned %d.", i)
+		case i < 0:
+			return "Final."
+		}
+		return fmt.Sprintf("Partial %d.", i+1)
+	})
+	client.SetChunking(1000, 0, 3)
+	ctx := context.Background()
+
+	// Tags are ranked by the chunks they were generated for
+	tags, err := client.GenerateTags(ctx, text, nil)
+	if err != nil {
+		t.Fatalf("GenerateTags failed: %v", err)
+	}
+	if expected := []string{"transit", "council", "budget", "cycling"}; strings.Join(tags, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected tags %v, got %v", expected, tags)
+	}
+
+	// Cleaned chunks are joined in order, followed by the uncleaned remainder
+	cleaned, err := client.CleanText(ctx, text)
+	if err != nil {
+		t.Fatalf("CleanText failed: %v", err)
+	}
+	if expected := "Cleaned 0.\n\nCleaned 1.\n\nCleaned 2.\n\n" + remainder; cleaned != expected {
+		t.Errorf("Expected the cleaned chunks and the remainder joined, got %q", cleaned)
+	}
+
+	// The synopsis is written from the partial synopses
+	before := len(prompts())
+	synopsis, err := client.GenerateSynopsis(ctx, text, SynopsisTeaser, 20)
+	if err != nil {
+		t.Fatalf("GenerateSynopsis failed: %v", err)
+	}
+	sent := prompts()[before:]
+	if len(sent) != 4 || synopsis != "Final." {
+		t.Fatalf("Expected 3 partial synopses and a final one, got %d prompts and %q", len(sent), synopsis)
+	}
+	final := sent[3]
+	if !strings.Contains(final, "Partial 1.\n\nPartial 2.\n\nPartial 3.") || !strings.Contains(final, "no more than 20 words") {
+		t.Errorf("Expected the final prompt to summarize the partials in the requested style, got %q", final)
+	}
+}
+
+func TestChunksRunConcurrently(t *testing.T) {
+	var mu sync.Mutex
+	running, most := 0, 0
+	client := NewWithGenerator("model", func(ctx context.Context, prompt string) (string, error) {
+		mu.Lock()
+		running++
+		most = max(most, running)
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return "Cleaned.", nil
+	})
+	client.SetChunking(1000, 0, 8)
+
+	if _, err := client.CleanText(context.Background(), longText(8000)); err != nil {
+		t.Fatalf("CleanText failed: %v", err)
+	}
+	if most < 2 || most > chunkConcurrency {
+		t.Errorf("Expected between 2 and %d chunks generated at once, got %d", chunkConcurrency, most)
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	client, prompts := recordingGenerator(func(prompt string) string {
+		return "Translated."
+	})
+	ctx := context.Background()
+
+	translated, err := client.Translate(ctx, "Le conseil a approuvé le plan.", "fr")
+	if err != nil {
+		t.Fatalf("Translate failed: %v", err)
+	}
+	prompt := prompts()[0]
+	if translated != "Translated." || !strings.Contains(prompt, "into French") || !strings.Contains(prompt, "Le conseil a approuvé le plan.") {
+		t.Errorf("Expected the text in a prompt naming the language, got %q for %q", translated, prompt)
+	}
+	// Unknown codes are passed on as given
+	client.Translate(ctx, "Text.", "Klingon")
+	if prompt := prompts()[1]; !strings.Contains(prompt, "into Klingon") {
+		t.Errorf("Expected the language as given, got %q", prompt)
+	}
+	if _, err := client.Translate(ctx, "Text.", " "); err == nil {
+		t.Error("Expected an error without a target language")
+	}
+
+	// A long text is translated chunk by chunk and joined
+	client.SetChunking(1000, 0, 3)
+	translated, _ = client.Translate(ctx, longText(2500), "en")
+	if translated != "Translated.\n\nTranslated.\n\nTranslated." {
+		t.Errorf("Expected the translated chunks joined, got %q", translated)
+	}
+}
@@ -2,32 +2,97 @@ package ollama
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"math"
+	"math/rand/v2"
+	"net"
 	"net/http"
 	"net/url"
 	"strings"
 	"time"
+	"unicode/utf8"
 
+	"github.com/docutag/textanalyzer/internal/tags"
+	"github.com/docutag/textanalyzer/internal/textutil"
 	"github.com/ollama/ollama/api"
+	"github.com/prometheus/client_golang/prometheus"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"golang.org/x/sync/semaphore"
 )
 
 const (
 	DefaultModel   = "gpt-oss:20b"
 	DefaultTimeout = 360 * time.Second
+
+	// DefaultMaxAttempts is how many times a request to the Ollama API is
+	// sent before a transient failure is returned
+	DefaultMaxAttempts = 3
+	// DefaultRetryBackoff is the wait before the first retry, doubled after
+	// each retry
+	DefaultRetryBackoff = time.Second
 )
 
+// maxGeneratedTags is the most tags GenerateTags returns
+const maxGeneratedTags = 10
+
+// maxErrorPreview is the most runes of a model response logged when it
+// cannot be parsed, and of an Ollama error body quoted in errors
+const maxErrorPreview = 200
+
+// retries counts requests to the Ollama API retried after a transient failure
+var retries = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "textanalyzer_ollama_retries_total",
+	Help: "Requests to the Ollama API retried after a transient failure, by model",
+}, []string{"model"})
+
+// RegisterMetrics registers the retry, response and cache counters, queue
+// wait and circuit breaker state of Ollama clients
+func RegisterMetrics(registerer prometheus.Registerer) error {
+	for _, collector := range []prometheus.Collector{retries, circuitState, responses, cacheRequests, queueWait} {
+		if err := registerer.Register(collector); err != nil {
+			if _, ok := err.(prometheus.AlreadyRegisteredError); !ok {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // Client wraps the Ollama API client
 type Client struct {
-	client  *api.Client
-	model   string
-	timeout time.Duration
+	client       *api.Client
+	model        string
+	fallbacks    []string // Models tried in turn when model fails with a model error
+	timeout      time.Duration
+	keepAlive    *time.Duration         // Server default when nil
+	options      map[string]any         // Model options sent with every call
+	calls        map[string]CallOptions // Overrides by call
+	maxAttempts  int
+	retryBackoff time.Duration
+	breaker      *breaker
+	chunkBudget  int          // Most characters of text per prompt
+	chunkOverlap int          // Characters consecutive chunks share
+	maxChunks    int          // Most chunks processed per text
+	generate     GenerateFunc // Replaces the Ollama API when set
+
+	limiter *semaphore.Weighted // Bounds concurrent generations when set
+
+	cache              Cache // Responses are not cached when nil
+	cacheTTL           time.Duration
+	cacheMaxEntryBytes int // Largest response cached, any size when zero
 }
 
-// New creates a new Ollama client
-func New(ollamaURL, model string) (*Client, error) {
+// GenerateFunc sends a prompt to a model and returns its response
+type GenerateFunc func(ctx context.Context, prompt string) (string, error)
+
+// New creates a new Ollama client configured by opts
+func New(ollamaURL, model string, opts ...Option) (*Client, error) {
 	if ollamaURL == "" {
 		ollamaURL = "http://localhost:11434"
 	}
@@ -41,10 +106,25 @@ func New(ollamaURL, model string) (*Client, error) {
 		return nil, fmt.Errorf("invalid Ollama URL: %w", err)
 	}
 
+	c := &Client{
+		model:        model,
+		timeout:      DefaultTimeout,
+		maxAttempts:  DefaultMaxAttempts,
+		retryBackoff: DefaultRetryBackoff,
+		breaker:      newBreaker(model, DefaultBreakerThreshold, DefaultBreakerCooldown),
+		limiter:      semaphore.NewWeighted(DefaultMaxConcurrent),
+		chunkBudget:  DefaultChunkBudget,
+		chunkOverlap: DefaultChunkOverlap,
+		maxChunks:    DefaultMaxChunks,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
 	// Create HTTP client with OpenTelemetry instrumentation
 	httpClient := &http.Client{
-		Timeout: DefaultTimeout,
-		Transport: otelhttp.NewTransport(http.DefaultTransport,
+		Timeout: c.longestTimeout(),
+		Transport: otelhttp.NewTransport(serverErrorTransport{base: http.DefaultTransport},
 			otelhttp.WithSpanNameFormatter(func(operation string, r *http.Request) string {
 				return "ollama " + r.Method + " " + r.URL.Path
 			}),
@@ -52,51 +132,319 @@ func New(ollamaURL, model string) (*Client, error) {
 	}
 
 	// Create Ollama API client with instrumented HTTP client
-	client := api.NewClient(baseURL, httpClient)
+	c.client = api.NewClient(baseURL, httpClient)
+	return c, nil
+}
 
-	return &Client{
-		client:  client,
-		model:   model,
-		timeout: DefaultTimeout,
-	}, nil
+// NewWithGenerator creates a client that sends its prompts through generate
+// instead of the Ollama API, so other backends share the prompts and the
+// parsing of responses. Only the timeouts, cache and concurrency limit of
+// opts apply, as generate takes no model options. Generations are not
+// limited unless opts include WithMaxConcurrent.
+func NewWithGenerator(model string, generate GenerateFunc, opts ...Option) *Client {
+	c := &Client{
+		model:        model,
+		timeout:      DefaultTimeout,
+		chunkBudget:  DefaultChunkBudget,
+		chunkOverlap: DefaultChunkOverlap,
+		maxChunks:    DefaultMaxChunks,
+		generate:     generate,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
-// GenerateResponse generates a response from the LLM
+// Model returns the name of the model used for generation
+func (c *Client) Model() string {
+	return c.model
+}
+
+// SetRetryPolicy sets how many times a request to the Ollama API is sent
+// before a transient failure is returned, and the wait before the first
+// retry. A maxAttempts below one uses DefaultMaxAttempts, and a negative
+// backoff retries at once.
+func (c *Client) SetRetryPolicy(maxAttempts int, backoff time.Duration) {
+	if maxAttempts < 1 {
+		maxAttempts = DefaultMaxAttempts
+	}
+	if backoff < 0 {
+		backoff = 0
+	}
+	c.maxAttempts = maxAttempts
+	c.retryBackoff = backoff
+}
+
+// SetCircuitBreaker sets how many calls to the Ollama API must fail in a row
+// before further calls fail with ErrCircuitOpen, and how long until a probe
+// call is let through. A threshold of zero disables the breaker.
+func (c *Client) SetCircuitBreaker(threshold int, cooldown time.Duration) {
+	c.breaker = newBreaker(c.model, threshold, cooldown)
+}
+
+// GenerateResponse generates a response from the LLM with the client's
+// timeout and model options
 func (c *Client) GenerateResponse(ctx context.Context, prompt string) (string, error) {
-	slog.Info("ollama sending request", "model", c.model, "timeout", c.timeout)
+	return c.generateResponse(ctx, "", prompt, nil, nil)
+}
+
+// generateText generates a free text response for call
+func (c *Client) generateText(ctx context.Context, call, prompt string) (string, error) {
+	return c.generateResponse(ctx, call, prompt, nil, nil)
+}
+
+// generateJSON generates a response for call constrained to a JSON object
+// and parses it with parse. Backends replacing the Ollama API may not honor
+// the constraint, so parse still decodes with decodeJSON, and only responses
+// it accepts are cached.
+func generateJSON[T any](ctx context.Context, c *Client, call, prompt string, parse func(string) (T, error)) (T, error) {
+	var value T
+	_, err := c.generateResponse(ctx, call, prompt, jsonFormat, func(response string) error {
+		var err error
+		value, err = parse(response)
+		return err
+	})
+	return value, err
+}
+
+// generateResponse generates a response in format, or free text when format
+// is nil, with the timeout and model options of call. When validate is set,
+// a response it rejects fails the call and is not cached, and a cached
+// response it rejects is generated again.
+func (c *Client) generateResponse(ctx context.Context, call, prompt string, format json.RawMessage, validate func(string) error) (string, error) {
+	timeout, options := c.callSettings(call)
+
+	var cacheKey string
+	if c.cache != nil && cachedCalls[call] {
+		cacheKey = c.cacheKey(call, prompt, options)
+		if entry, ok := c.cachedResponse(ctx, call, cacheKey); ok && (validate == nil || validate(entry.Response) == nil) {
+			recordModel(ctx, entry.Model)
+			return entry.Response, nil
+		}
+	}
+
+	// Waiting for a slot is bounded by ctx, not by the call timeout
+	release, err := c.acquire(ctx)
+	if err != nil {
+		return "", fmt.Errorf("waiting for a generation slot: %w", err)
+	}
+	defer release()
+	slog.Info("ollama sending request", "model", c.model, "call", call, "timeout", timeout)
 
-	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	callCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
+	var result, model string
+	if c.generate != nil {
+		response, err := c.generate(callCtx, prompt)
+		if err != nil {
+			slog.Error("generation failed", "model", c.model, "error", err)
+			return "", fmt.Errorf("generation failed: %w", err)
+		}
+		result, model = strings.TrimSpace(response), c.model
+	} else {
+		req := c.newRequest(prompt, options)
+		req.Format = format
+
+		response, responseModel, err := c.callWithFallback(callCtx, req)
+		if err != nil {
+			slog.Error("ollama generation failed", "model", responseModel, "error", err)
+			return "", fmt.Errorf("generation failed: %w", err)
+		}
+		result, model = strings.TrimSpace(response), responseModel
+		slog.Info("ollama response received", "model", model, "length", len(result))
+	}
+	recordResponse(ctx, model)
+
+	if validate != nil {
+		if err := validate(result); err != nil {
+			return "", err
+		}
+	}
+	if cacheKey != "" {
+		c.cacheResponse(ctx, call, cacheKey, cacheEntry{Model: model, Response: result})
+	}
+	return result, nil
+}
+
+// newRequest creates a request for prompt with the model options and the
+// client's keep-alive
+func (c *Client) newRequest(prompt string, options map[string]any) *api.GenerateRequest {
 	req := &api.GenerateRequest{
-		Model:  c.model,
-		Prompt: prompt,
-		Stream: new(bool), // false
+		Model:   c.model,
+		Prompt:  prompt,
+		Options: options,
+		Stream:  new(bool), // false
 	}
+	if c.keepAlive != nil {
+		req.KeepAlive = &api.Duration{Duration: *c.keepAlive}
+	}
+	return req
+}
 
-	var response strings.Builder
-	err := c.client.Generate(ctx, req, func(resp api.GenerateResponse) error {
-		response.WriteString(resp.Response)
-		return nil
-	})
+// callAPI sends req to the Ollama API through the circuit breaker. A call
+// counts once however many times it was retried.
+func (c *Client) callAPI(ctx context.Context, req *api.GenerateRequest) (string, error) {
+	if err := c.breaker.allow(); err != nil {
+		return "", err
+	}
+	response, err := c.generateWithRetry(ctx, req)
+	c.breaker.record(err)
+	return response, err
+}
 
-	if err != nil {
-		slog.Error("ollama generation failed", "error", err)
-		return "", fmt.Errorf("generation failed: %w", err)
+// generateWithRetry sends req to the Ollama API, retrying transient failures
+// with exponential backoff and jitter. Retries stop when the next wait would
+// pass the deadline of ctx, so they stay within the call timeout.
+func (c *Client) generateWithRetry(ctx context.Context, req *api.GenerateRequest) (string, error) {
+	backoff := c.retryBackoff
+	for attempt := 1; ; attempt++ {
+		var response strings.Builder
+		err := c.client.Generate(ctx, req, func(resp api.GenerateResponse) error {
+			response.WriteString(resp.Response)
+			return nil
+		})
+		if err == nil {
+			return response.String(), nil
+		}
+		if attempt >= c.maxAttempts || ctx.Err() != nil || !isTransient(err) {
+			return "", err
+		}
+
+		// Wait between half and all of the backoff, so concurrent calls
+		// failing together do not retry together
+		wait := backoff/2 + rand.N(backoff/2+1)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= wait {
+			return "", err
+		}
+
+		retries.WithLabelValues(req.Model).Inc()
+		slog.Warn("ollama request failed, retrying",
+			"model", req.Model,
+			"attempt", attempt,
+			"max_attempts", c.maxAttempts,
+			"backoff", wait,
+			"error", err,
+		)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return "", err
+		case <-timer.C:
+		}
+		// Stop doubling before the wait overflows
+		if backoff <= math.MaxInt64/2 {
+			backoff *= 2
+		}
 	}
+}
 
-	result := strings.TrimSpace(response.String())
-	slog.Info("ollama response received", "length", len(result))
-	return result, nil
+// isTransient reports whether a failed request to the Ollama API may
+// succeed if sent again: connection failures, timeouts and 5xx responses.
+// A model that could not be loaded fails the same way again.
+func isTransient(err error) bool {
+	if isModelError(err) {
+		return false
+	}
+	var serverErr *ServerError
+	if errors.As(err, &serverErr) {
+		return true
+	}
+	var statusErr api.StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= http.StatusInternalServerError
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	// The connection closed before a whole response was read
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// ServerError is a 5xx response from Ollama or a proxy in front of it
+type ServerError struct {
+	StatusCode int
+	Status     string
+	Body       string // Start of the response body
+}
+
+func (e *ServerError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("ollama server error: %s", e.Status)
+	}
+	return fmt.Sprintf("ollama server error: %s: %s", e.Status, e.Body)
+}
+
+// serverErrorTransport turns 5xx responses into a ServerError. The Ollama
+// API client cannot read the HTML or empty bodies proxies send with them,
+// and would report them as malformed or empty responses.
+type serverErrorTransport struct {
+	base http.RoundTripper
+}
+
+func (t serverErrorTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode < http.StatusInternalServerError {
+		return resp, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4*maxErrorPreview))
+	return nil, &ServerError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Body:       textutil.Preview(strings.TrimSpace(string(body)), maxErrorPreview),
+	}
 }
 
-// GenerateSynopsis creates a 3-4 sentence synopsis of the text
-func (c *Client) GenerateSynopsis(ctx context.Context, text string) (string, error) {
-	prompt := fmt.Sprintf(`Analyze the following text and provide a concise synopsis that captures the main points and key ideas.
+// Synopsis styles control how long a generated synopsis is
+const (
+	SynopsisTeaser   = "teaser"   // A single sentence for list views
+	SynopsisStandard = "standard" // Two or three short sentences (default)
+	SynopsisAbstract = "abstract" // About five sentences for detail views
+)
+
+// GenerateSynopsis creates a synopsis of the text in the given style. An empty
+// or unknown style uses SynopsisStandard; maxWords > 0 caps the total length.
+// A text too long for one prompt is summarized chunk by chunk, and the
+// synopsis is written from the partial synopses.
+func (c *Client) GenerateSynopsis(ctx context.Context, text, style string, maxWords int) (string, error) {
+	if chunks, _ := c.chunks(text, c.chunkOverlap); len(chunks) > 1 {
+		return c.generateSynopsis(ctx, chunks, style, maxWords)
+	}
+	return c.generateText(ctx, CallSynopsis, synopsisPrompt(text, style, maxWords))
+}
+
+// synopsisPrompt builds the synopsis prompt for a style and word limit
+func synopsisPrompt(text, style string, maxWords int) string {
+	var length string
+	switch style {
+	case SynopsisTeaser:
+		length = `- Write EXACTLY 1 short sentence that tells a reader what the content is about
+- Keep the sentence under 20 words`
+	case SynopsisAbstract:
+		length = `- Write 4 or 5 sentences covering the main argument, key evidence, and conclusions
+- Keep each sentence under 25 words`
+	default:
+		length = `- Write EXACTLY 2 or 3 short sentences summarizing the content
+- Keep each sentence under 15 words`
+	}
+	if maxWords > 0 {
+		length += fmt.Sprintf("\n- Use no more than %d words in total", maxWords)
+	}
+
+	return fmt.Sprintf(`Analyze the following text and provide a concise synopsis that captures the main points and key ideas.
 
 Requirements:
-- Write EXACTLY 2 or 3 short sentences summarizing the content
-- Keep each sentence under 15 words
+%s
 - Use simple, clear language
 - Avoid complex or compound sentences
 - Do NOT use numbering or bullet points
@@ -106,14 +454,11 @@ Requirements:
 Text:
 %s
 
-Synopsis:`, text)
-
-	return c.GenerateResponse(ctx, prompt)
+Synopsis:`, length, text)
 }
 
-// CleanText removes artifacts and non-relevant content from the text
-func (c *Client) CleanText(ctx context.Context, text string) (string, error) {
-	prompt := fmt.Sprintf(`Your task is to clean the following text by removing artifacts, formatting issues, advertisements, navigation elements, and other non-relevant content.
+// cleanTextPrompt is the CleanText prompt, formatted with the text
+const cleanTextPrompt = `Your task is to clean the following text by removing artifacts, formatting issues, advertisements, navigation elements, and other non-relevant content.
 
 If the text is already clean and well-formatted, return it EXACTLY as provided. If there are issues to clean, return ONLY the cleaned article content without any commentary, explanations, or meta-analysis. Simply return the text, cleaned or as-is.
 
@@ -124,15 +469,23 @@ Never modify, paraphrase, or alter any quoted text. Keep all quotes exactly as t
 Text to process:
 %s
 
-Output the text:`, text)
+Output the text:`
 
-	return c.GenerateResponse(ctx, prompt)
+// CleanText removes artifacts and non-relevant content from the text. A text
+// too long for one prompt is cleaned chunk by chunk, without overlap, and the
+// cleaned chunks are joined.
+func (c *Client) CleanText(ctx context.Context, text string) (string, error) {
+	if chunks, remainder := c.chunks(text, 0); len(chunks) > 1 {
+		return c.cleanText(ctx, chunks, remainder)
+	}
+	prompt := fmt.Sprintf(cleanTextPrompt, text)
+
+	return c.generateText(ctx, CallClean, prompt)
 }
 
-// CleanTextWithHTMLContext performs enhanced text cleaning using offline analysis as a template
-// and original HTML to extract the cleanest possible article text
-func (c *Client) CleanTextWithHTMLContext(ctx context.Context, text, offlineText, originalHTML string) (string, error) {
-	prompt := fmt.Sprintf(`You are an expert text extraction and cleaning assistant. Your task is to extract the cleanest possible article text from the provided HTML.
+// cleanHTMLPrompt is the CleanTextWithHTMLContext prompt, formatted with the
+// offline text and the original HTML
+const cleanHTMLPrompt = `You are an expert text extraction and cleaning assistant. Your task is to extract the cleanest possible article text from the provided HTML.
 
 You have three inputs available: the original extracted text which may contain artifacts, the offline cleaned text which should be used as a template and reference for what content to keep, and the original HTML source which contains the raw article.
 
@@ -150,112 +503,146 @@ OFFLINE TEMPLATE TEXT (shows what content to keep):
 ORIGINAL HTML TO EXTRACT FROM:
 %s
 
-Extract and output the clean article text in English:`, offlineText, originalHTML)
+Extract and output the clean article text in English:`
+
+// CleanTextWithHTMLContext performs enhanced text cleaning using offline analysis as a template
+// and original HTML to extract the cleanest possible article text. When the
+// template and HTML together are too long for one prompt, the text is cleaned
+// with CleanText instead.
+func (c *Client) CleanTextWithHTMLContext(ctx context.Context, text, offlineText, originalHTML string) (string, error) {
+	if c.chunkBudget > 0 && utf8.RuneCountInString(offlineText)+utf8.RuneCountInString(originalHTML) > c.chunkBudget {
+		slog.Info("HTML context exceeds the chunk budget, cleaning text without it",
+			"budget", c.chunkBudget, "html_length", len(originalHTML))
+		return c.CleanText(ctx, text)
+	}
+	prompt := fmt.Sprintf(cleanHTMLPrompt, offlineText, originalHTML)
 
-	return c.GenerateResponse(ctx, prompt)
+	return c.generateText(ctx, CallCleanHTML, prompt)
 }
 
-// EditorialAnalysis provides analysis of bias, motivation, and editorial slant
-func (c *Client) EditorialAnalysis(ctx context.Context, text string) (string, error) {
-	prompt := fmt.Sprintf(`Analyze the following text and provide an unbiased assessment of the nature and purpose of this text (informational, persuasive, entertainment, etc.), possible motivations behind the writing, any editorial slant or bias (left/right, commercial, academic, etc.), and the overall tone and approach.
+// translatePrompt is the Translate prompt, formatted with the name of the
+// target language and the text
+const translatePrompt = `Translate the following text into %[1]s.
 
-Requirements:
-- Write EXACTLY 2 short sentences
-- Keep each sentence under 15 words
-- Use simple, clear language
-- Avoid complex or compound sentences
-- Be objective and analytical
-- Do NOT use numbering or bullet points
+Translate the full text faithfully, sentence by sentence, keeping its meaning, tone, paragraph breaks and structure. Translate quoted text too, keeping it marked as a quote. Keep the names of people, organizations and places, numbers, dates and URLs as they are. Leave any parts already in %[1]s unchanged.
 
-Text:
-%s
+Return only the translated text, without commentary, explanations or notes on the translation.
+
+Text to translate:
+%[2]s
 
-Analysis:`, text)
+Translation in %[1]s:`
 
-	return c.GenerateResponse(ctx, prompt)
+// languageNames names the languages Translate is most often asked for by
+// their ISO 639-1 codes; other codes are passed to the model as given
+var languageNames = map[string]string{
+	"de": "German",
+	"en": "English",
+	"es": "Spanish",
+	"fr": "French",
+	"it": "Italian",
+	"ja": "Japanese",
+	"ko": "Korean",
+	"nl": "Dutch",
+	"pt": "Portuguese",
+	"zh": "Chinese",
 }
 
-// GenerateTags generates up to 5 relevant tags for the text
-func (c *Client) GenerateTags(ctx context.Context, text string, metadata map[string]interface{}) ([]string, error) {
-	// Include some context from metadata if available
-	sentiment := ""
-	if s, ok := metadata["sentiment"].(string); ok {
-		sentiment = s
+// languageName returns the name of the language with the given code, or the
+// code itself when it is not known
+func languageName(code string) string {
+	if name, ok := languageNames[strings.ToLower(code)]; ok {
+		return name
 	}
+	return code
+}
 
-	prompt := fmt.Sprintf(`Analyze the following text and generate up to 10 relevant tags that categorize and describe the content.
+// Translate translates text into targetLang, an ISO 639-1 code such as "en"
+// or a language name. A text too long for one prompt is translated chunk by
+// chunk, without overlap, and the translated chunks are joined.
+func (c *Client) Translate(ctx context.Context, text, targetLang string) (string, error) {
+	targetLang = strings.TrimSpace(targetLang)
+	if targetLang == "" {
+		return "", fmt.Errorf("no language to translate into")
+	}
+	language := languageName(targetLang)
+	if chunks, _ := c.chunks(text, 0); len(chunks) > 1 {
+		return c.translate(ctx, chunks, language)
+	}
 
-Tag formatting rules: Prefer single-word tags whenever possible. Multi-word tags should use hyphens only, with no spaces or underscores. Names of people, places, and things make excellent tags. All tags should be lowercase. Examples include "technology", "climate-change", "new-york", "machine-learning", and "einstein".
+	return c.generateText(ctx, CallTranslate, fmt.Sprintf(translatePrompt, language, text))
+}
 
-Consider the topic, domain, sentiment (%s), content type, key themes, and named entities such as people, places, and organizations.
+// editorialPrompt is the EditorialAnalysis prompt, formatted with the text
+const editorialPrompt = `Analyze the following text and provide an unbiased assessment of the nature and purpose of this text, possible motivations behind the writing, any editorial slant or bias, and the overall tone and approach.
+
+Provide your assessment as a JSON object with:
+- purpose: "informational" | "persuasive" | "entertainment" | "advertorial"
+- bias: "left" | "center-left" | "center" | "center-right" | "right" | "commercial" | "academic" | "none"
+- tone: one or two words describing the tone (e.g., "neutral", "alarmist", "humorous")
+- summary: EXACTLY 2 short sentences, each under 15 words, in simple, clear and objective language, without numbering or bullet points
 
-CRITICAL: Your response MUST be a valid JSON array of strings and nothing else. Do not include any explanatory text, commentary, or prose. Output only the JSON array in this exact format: ["tag1", "tag2", "tag3"]
+Use this exact format:
+{"purpose": "informational", "bias": "none", "tone": "neutral", "summary": "..."}
 
-Text:
+Text to analyze:
 %s
 
-Tags (JSON array only):`, sentiment, text)
+Return ONLY the JSON object, nothing else:`
 
-	response, err := c.GenerateResponse(ctx, prompt)
-	if err != nil {
-		return nil, err
-	}
+// EditorialAnalysis classifies the purpose, editorial slant and tone of the
+// text, with a short summary of the assessment. A text too long for one
+// prompt is judged by its first chunk.
+func (c *Client) EditorialAnalysis(ctx context.Context, text string) (*EditorialResult, error) {
+	prompt := fmt.Sprintf(editorialPrompt, c.firstChunk(text))
 
-	// Parse JSON response
-	var tags []string
+	return generateJSON(ctx, c, CallEditorial, prompt, parseEditorial)
+}
 
-	// Try to find JSON array in response
-	start := strings.Index(response, "[")
-	end := strings.LastIndex(response, "]")
-	if start >= 0 && end > start {
-		jsonStr := response[start : end+1]
-		if err := json.Unmarshal([]byte(jsonStr), &tags); err != nil {
-			return nil, fmt.Errorf("failed to parse tags JSON: %w", err)
-		}
-	} else {
-		return nil, fmt.Errorf("no JSON array found in response")
-	}
+// tagsPrompt is the GenerateTags prompt, formatted with the sentiment and text
+const tagsPrompt = `Analyze the following text and generate up to 10 relevant tags that categorize and describe the content.
 
-	// Normalize tags
-	for i, tag := range tags {
-		tags[i] = normalizeTag(tag)
-	}
+Tag formatting rules: Prefer single-word tags whenever possible. Multi-word tags should use hyphens only, with no spaces or underscores. Names of people, places, and things make excellent tags. All tags should be lowercase. Examples include "technology", "climate-change", "new-york", "machine-learning", and "einstein".
 
-	// Limit to 10 tags
-	if len(tags) > 10 {
-		tags = tags[:10]
-	}
+Consider the topic, domain, sentiment (%s), content type, key themes, and named entities such as people, places, and organizations.
 
-	return tags, nil
-}
+CRITICAL: Your response MUST be a valid JSON object and nothing else. Do not include any explanatory text, commentary, or prose. Output only the JSON object in this exact format: {"tags": ["tag1", "tag2", "tag3"]}
 
-// normalizeTag normalizes a tag according to the tagging rules:
-// - Converts to lowercase
-// - Replaces spaces and underscores with hyphens
-// - Removes multiple consecutive hyphens
-// - Trims leading/trailing hyphens and whitespace
-func normalizeTag(tag string) string {
-	// Convert to lowercase
-	tag = strings.ToLower(tag)
+Text:
+%s
 
-	// Replace spaces and underscores with hyphens
-	tag = strings.ReplaceAll(tag, " ", "-")
-	tag = strings.ReplaceAll(tag, "_", "-")
+Tags (JSON object only):`
 
-	// Remove multiple consecutive hyphens
-	for strings.Contains(tag, "--") {
-		tag = strings.ReplaceAll(tag, "--", "-")
+// GenerateTags generates up to 10 relevant tags for the text. The tags of a
+// text too long for one prompt are generated chunk by chunk and ranked by how
+// many chunks they were generated for.
+func (c *Client) GenerateTags(ctx context.Context, text string, metadata map[string]interface{}) ([]string, error) {
+	// Include some context from metadata if available
+	sentiment := ""
+	if s, ok := metadata["sentiment"].(string); ok {
+		sentiment = s
 	}
 
-	// Trim leading/trailing hyphens and whitespace
-	tag = strings.Trim(tag, "- \t\n\r")
+	if chunks, _ := c.chunks(text, c.chunkOverlap); len(chunks) > 1 {
+		return c.generateTags(ctx, chunks, sentiment)
+	}
+	prompt := fmt.Sprintf(tagsPrompt, sentiment, text)
 
-	return tag
+	return generateJSON(ctx, c, CallTags, prompt, parseTags)
 }
 
-// ExtractReferences extracts and validates references from text
-func (c *Client) ExtractReferences(ctx context.Context, text string) ([]Reference, error) {
-	prompt := fmt.Sprintf(`Analyze the following text and extract factual claims, statistics, quotes, and assertions that would benefit from verification or citation.
+// parseTags reads the tags from a GenerateTags response, normalizing,
+// deduplicating and limiting them
+func parseTags(response string) ([]string, error) {
+	generated, err := decodeJSON[tagList](response)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse tags JSON: %w", err)
+	}
+	return tags.MergeWithLimit(maxGeneratedTags, generated), nil
+}
+
+// referencesPrompt is the ExtractReferences prompt, formatted with the text
+const referencesPrompt = `Analyze the following text and extract factual claims, statistics, quotes, and assertions that would benefit from verification or citation.
 
 For each reference, identify:
 - The exact text of the claim/statistic/quote
@@ -263,33 +650,31 @@ For each reference, identify:
 - Brief context (surrounding text)
 - Confidence level (high, medium, low)
 
-Return ONLY a JSON array of objects with fields: text, type, context, confidence. Limit to the 10 most significant references.
+Return ONLY a JSON object in this exact format, with at most the 10 most significant references:
+{"references": [{"text": "...", "type": "statistic|quote|claim|citation", "context": "...", "confidence": "high|medium|low"}]}
 
 Text:
 %s
 
-References (JSON array):`, text)
+References (JSON object):`
 
-	response, err := c.GenerateResponse(ctx, prompt)
-	if err != nil {
-		return nil, err
+// ExtractReferences extracts and validates references from text. The
+// references of a text too long for one prompt are extracted chunk by chunk.
+func (c *Client) ExtractReferences(ctx context.Context, text string) ([]Reference, error) {
+	if chunks, _ := c.chunks(text, c.chunkOverlap); len(chunks) > 1 {
+		return c.extractReferences(ctx, chunks)
 	}
+	prompt := fmt.Sprintf(referencesPrompt, text)
 
-	// Parse JSON response
-	var references []Reference
+	return generateJSON(ctx, c, CallReferences, prompt, parseReferences)
+}
 
-	// Try to find JSON array in response
-	start := strings.Index(response, "[")
-	end := strings.LastIndex(response, "]")
-	if start >= 0 && end > start {
-		jsonStr := response[start : end+1]
-		if err := json.Unmarshal([]byte(jsonStr), &references); err != nil {
-			return nil, fmt.Errorf("failed to parse references JSON: %w", err)
-		}
-	} else {
-		return nil, fmt.Errorf("no JSON array found in response")
+// parseReferences reads the references from an ExtractReferences response
+func parseReferences(response string) ([]Reference, error) {
+	references, err := decodeJSON[referenceList](response)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse references JSON: %w", err)
 	}
-
 	return references, nil
 }
 
@@ -310,9 +695,8 @@ type AIDetectionResult struct {
 	HumanScore float64  `json:"human_score"`
 }
 
-// DetectAIContent analyzes whether the text was likely written by AI
-func (c *Client) DetectAIContent(ctx context.Context, text string) (*AIDetectionResult, error) {
-	prompt := fmt.Sprintf(`Analyze the following text to determine if it was written by an AI or a human. Consider factors such as:
+// aiDetectionPrompt is the DetectAIContent prompt, formatted with the text
+const aiDetectionPrompt = `Analyze the following text to determine if it was written by an AI or a human. Consider factors such as:
 
 1. Writing patterns (repetitive structures, overly formal tone, perfect grammar)
 2. Vocabulary choices (overuse of certain words, lack of colloquialisms)
@@ -328,31 +712,28 @@ Provide your assessment as a JSON object with:
 - indicators: array of specific markers you found (e.g., "repetitive sentence structure", "lack of personal voice", "perfect grammar")
 - human_score: 0-100 where 0 = definitely AI, 100 = definitely human
 
+Use this exact format:
+{"likelihood": "possible", "confidence": "medium", "reasoning": "...", "indicators": ["..."], "human_score": 50}
+
 Text to analyze:
 %s
 
-Return ONLY the JSON object, nothing else:`, text)
+Return ONLY the JSON object, nothing else:`
 
-	response, err := c.GenerateResponse(ctx, prompt)
-	if err != nil {
-		return nil, err
-	}
+// DetectAIContent analyzes whether the text was likely written by AI. A text
+// too long for one prompt is judged by its first chunk.
+func (c *Client) DetectAIContent(ctx context.Context, text string) (*AIDetectionResult, error) {
+	prompt := fmt.Sprintf(aiDetectionPrompt, c.firstChunk(text))
 
-	// Parse JSON response
-	var result AIDetectionResult
+	return generateJSON(ctx, c, CallAIDetection, prompt, parseAIDetection)
+}
 
-	// Try to find JSON object in response
-	start := strings.Index(response, "{")
-	end := strings.LastIndex(response, "}")
-	if start >= 0 && end > start {
-		jsonStr := response[start : end+1]
-		if err := json.Unmarshal([]byte(jsonStr), &result); err != nil {
-			return nil, fmt.Errorf("failed to parse AI detection JSON: %w", err)
-		}
-	} else {
-		return nil, fmt.Errorf("no JSON object found in response")
+// parseAIDetection reads the assessment from a DetectAIContent response
+func parseAIDetection(response string) (*AIDetectionResult, error) {
+	result, err := decodeJSON[AIDetectionResult](response)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse AI detection JSON: %w", err)
 	}
-
 	return &result, nil
 }
 
@@ -365,9 +746,8 @@ type TextQualityScoreResult struct {
 	ProblemsDetected  []string `json:"problems_detected"`
 }
 
-// ScoreTextQuality analyzes and scores the quality of text content
-func (c *Client) ScoreTextQuality(ctx context.Context, text string) (*TextQualityScoreResult, error) {
-	prompt := fmt.Sprintf(`You are a content quality assessment assistant. Analyze the following text and determine its quality for information and knowledge purposes.
+// qualityPrompt is the ScoreTextQuality prompt, formatted with the text
+const qualityPrompt = `You are a content quality assessment assistant. Analyze the following text and determine its quality for information and knowledge purposes.
 
 Evaluate the text and assign a quality score from 0.0 to 1.0 where:
 - 1.0 = Excellent quality (well-written, informative, coherent, valuable)
@@ -415,26 +795,22 @@ Problems detected list issues found: "poor_grammar", "incoherent", "too_short",
 Text to analyze:
 %s
 
-Return ONLY the JSON object, nothing else:`, text)
+Return ONLY the JSON object, nothing else:`
 
-	response, err := c.GenerateResponse(ctx, prompt)
-	if err != nil {
-		return nil, err
-	}
+// ScoreTextQuality analyzes and scores the quality of text content. A text
+// too long for one prompt is scored by its first chunk.
+func (c *Client) ScoreTextQuality(ctx context.Context, text string) (*TextQualityScoreResult, error) {
+	prompt := fmt.Sprintf(qualityPrompt, c.firstChunk(text))
 
-	// Parse JSON response
-	var result TextQualityScoreResult
+	return generateJSON(ctx, c, CallQuality, prompt, parseQualityScore)
+}
 
-	// Try to find JSON object in response
-	start := strings.Index(response, "{")
-	end := strings.LastIndex(response, "}")
-	if start >= 0 && end > start {
-		jsonStr := response[start : end+1]
-		if err := json.Unmarshal([]byte(jsonStr), &result); err != nil {
-			return nil, fmt.Errorf("failed to parse quality score JSON: %w", err)
-		}
-	} else {
-		return nil, fmt.Errorf("no JSON object found in response")
+// parseQualityScore reads the score from a ScoreTextQuality response,
+// clamping it to [0, 1]
+func parseQualityScore(response string) (*TextQualityScoreResult, error) {
+	result, err := decodeJSON[TextQualityScoreResult](response)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse quality score JSON: %w", err)
 	}
 
 	// Ensure score is within bounds
@@ -458,3 +834,102 @@ Return ONLY the JSON object, nothing else:`, text)
 
 	return &result, nil
 }
+
+// maxImageTags is the most tags DescribeImage returns
+const maxImageTags = 5
+
+// describeImagePrompt is the DescribeImage prompt, sent with the image
+const describeImagePrompt = `Describe the attached image for someone who cannot see it.
+
+Return ONLY a JSON object with these fields:
+- "caption": one or two plain sentences describing what the image shows, including any legible text that matters
+- "tags": up to 5 lowercase topic tags for the image content (e.g. "transit-map", "city-council")
+
+Do not include any explanatory text outside the JSON object. Output only the JSON object in this exact format: {"caption": "...", "tags": ["tag1", "tag2"]}
+
+JSON:`
+
+// DescribeImage captions an image and suggests tags for it. The client's
+// model must be a vision model, such as llava or llama3.2-vision; clients
+// created with NewWithGenerator cannot send images.
+func (c *Client) DescribeImage(ctx context.Context, imageData []byte) (string, []string, error) {
+	if c.generate != nil {
+		return "", nil, fmt.Errorf("image description requires the Ollama API")
+	}
+	if len(imageData) == 0 {
+		return "", nil, fmt.Errorf("no image data to describe")
+	}
+
+	release, err := c.acquire(ctx)
+	if err != nil {
+		return "", nil, fmt.Errorf("waiting for a generation slot: %w", err)
+	}
+	defer release()
+	slog.Info("ollama describing image", "model", c.model, "image_bytes", len(imageData))
+
+	timeout, options := c.callSettings(CallImage)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req := c.newRequest(describeImagePrompt, options)
+	req.Images = []api.ImageData{imageData}
+	req.Format = jsonFormat
+
+	response, model, err := c.callWithFallback(ctx, req)
+	if err != nil {
+		slog.Error("ollama image description failed", "model", model, "error", err)
+		return "", nil, fmt.Errorf("image description failed: %w", err)
+	}
+	recordResponse(ctx, model)
+
+	return parseImageDescription(response)
+}
+
+// parseImageDescription reads the caption and tags from a DescribeImage
+// response, normalizing and limiting the tags
+func parseImageDescription(response string) (string, []string, error) {
+	description, err := decodeJSON[struct {
+		Caption string   `json:"caption"`
+		Tags    []string `json:"tags"`
+	}](response)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to parse image description JSON: %w", err)
+	}
+
+	caption := strings.TrimSpace(description.Caption)
+	if caption == "" {
+		logUnparsed("image description has no caption", response)
+		return "", nil, errors.New("image description has no caption")
+	}
+	return caption, tags.MergeWithLimit(maxImageTags, description.Tags), nil
+}
+
+// PromptHashes returns a short SHA-256 of each prompt template, keyed by the
+// enrichment step it serves. A changed hash means results produced before the
+// change came from a different prompt. The synopsis hash covers every style,
+// with and without a word limit.
+func PromptHashes() map[string]string {
+	var synopsis strings.Builder
+	for _, style := range []string{SynopsisTeaser, SynopsisStandard, SynopsisAbstract} {
+		synopsis.WriteString(synopsisPrompt("%s", style, 0))
+		synopsis.WriteString(synopsisPrompt("%s", style, 1))
+	}
+
+	templates := map[string]string{
+		CallSynopsis:    synopsis.String(),
+		CallClean:       cleanTextPrompt,
+		CallCleanHTML:   cleanHTMLPrompt,
+		CallEditorial:   editorialPrompt,
+		CallTags:        tagsPrompt,
+		CallReferences:  referencesPrompt,
+		CallAIDetection: aiDetectionPrompt,
+		CallQuality:     qualityPrompt,
+	}
+
+	hashes := make(map[string]string, len(templates))
+	for name, template := range templates {
+		sum := sha256.Sum256([]byte(template))
+		hashes[name] = hex.EncodeToString(sum[:])[:12]
+	}
+	return hashes
+}
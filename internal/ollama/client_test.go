@@ -3,8 +3,16 @@ package ollama
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 func TestNew(t *testing.T) {
@@ -117,27 +125,40 @@ func TestParseTagsFromJSON(t *testing.T) {
 			response:    `["invalid"`,
 			expectError: true,
 		},
+		{
+			name:        "structured output object",
+			response:    `{"tags": ["climate-change", "policy"]}`,
+			expected:    []string{"climate-change", "policy"},
+			expectError: false,
+		},
+		{
+			name:        "fenced JSON",
+			response:    "```json\n{\"tags\": [\"rust\", \"compilers\"]}\n```",
+			expected:    []string{"rust", "compilers"},
+			expectError: false,
+		},
+		{
+			name:        "reasoning with brackets before the JSON",
+			response:    `The text covers [mostly] economics, so: {"tags": ["economics", "inflation"]}`,
+			expected:    []string{"economics", "inflation"},
+			expectError: false,
+		},
+		{
+			name:        "trailing commentary with brackets",
+			response:    `{"tags": ["golang"]} Note: I skipped generic tags [like "news"].`,
+			expected:    []string{"golang"},
+			expectError: false,
+		},
+		{
+			name:        "object without tags",
+			response:    `{"topics": ["golang"]}`,
+			expectError: true,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			var tags []string
-			var err error
-
-			// Simulate the parsing logic from GenerateTags
-			start := strings.Index(tt.response, "[")
-			end := strings.LastIndex(tt.response, "]")
-			if start >= 0 && end > start {
-				jsonStr := tt.response[start : end+1]
-				err = json.Unmarshal([]byte(jsonStr), &tags)
-			} else {
-				err = &jsonParseError{}
-			}
-
-			// Limit to 10 tags
-			if err == nil && len(tags) > 10 {
-				tags = tags[:10]
-			}
+			tags, err := parseTags(tt.response)
 
 			if tt.expectError {
 				if err == nil {
@@ -160,12 +181,6 @@ func TestParseTagsFromJSON(t *testing.T) {
 	}
 }
 
-type jsonParseError struct{}
-
-func (e *jsonParseError) Error() string {
-	return "no JSON array found in response"
-}
-
 func TestParseReferencesFromJSON(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -201,22 +216,25 @@ func TestParseReferencesFromJSON(t *testing.T) {
 			expectedLen: 1,
 			expectError: false,
 		},
+		{
+			name:        "structured output object",
+			response:    `{"references": [{"text": "GDP grew 3% [1]", "type": "statistic", "context": "Economy", "confidence": "high"}]}`,
+			expectedLen: 1,
+			expectError: false,
+		},
+		{
+			name: "fenced JSON with trailing commentary",
+			response: "Sure! Here you go:\n```\n" +
+				`{"references": [{"text": "a", "type": "claim", "context": "b", "confidence": "low"}, {"text": "c", "type": "quote", "context": "d", "confidence": "low"}]}` +
+				"\n```\nLet me know if you need [more].",
+			expectedLen: 2,
+			expectError: false,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			var references []Reference
-			var err error
-
-			// Simulate the parsing logic from ExtractReferences
-			start := strings.Index(tt.response, "[")
-			end := strings.LastIndex(tt.response, "]")
-			if start >= 0 && end > start {
-				jsonStr := tt.response[start : end+1]
-				err = json.Unmarshal([]byte(jsonStr), &references)
-			} else {
-				err = &jsonParseError{}
-			}
+			references, err := parseReferences(tt.response)
 
 			if tt.expectError {
 				if err == nil {
@@ -271,22 +289,24 @@ func TestParseAIDetectionFromJSON(t *testing.T) {
 			response:    `{"likelihood": "likely"`,
 			expectError: true,
 		},
+		{
+			name:        "brace in the reasoning before the JSON",
+			response:    `Looking at the {tone} and structure... {"likelihood": "possible", "confidence": "low", "reasoning": "Mixed {signals}", "indicators": [], "human_score": 55}`,
+			expectError: false,
+			checkFields: true,
+		},
+		{
+			name: "two objects",
+			response: `{"likelihood": "likely", "confidence": "high", "reasoning": "Formulaic", "indicators": ["hedging"], "human_score": 20}
+{"likelihood": "unlikely", "confidence": "low", "reasoning": "Second guess", "indicators": [], "human_score": 80}`,
+			expectError: false,
+			checkFields: true,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			var result AIDetectionResult
-			var err error
-
-			// Simulate the parsing logic from DetectAIContent
-			start := strings.Index(tt.response, "{")
-			end := strings.LastIndex(tt.response, "}")
-			if start >= 0 && end > start {
-				jsonStr := tt.response[start : end+1]
-				err = json.Unmarshal([]byte(jsonStr), &result)
-			} else {
-				err = &jsonParseError{}
-			}
+			result, err := parseAIDetection(tt.response)
 
 			if tt.expectError {
 				if err == nil {
@@ -388,66 +408,357 @@ func TestContextHandling(t *testing.T) {
 
 	// These should fail with context canceled error (or succeed quickly if mocked)
 	// We're mainly testing that the methods accept context properly
-	_, err = client.GenerateSynopsis(ctx, "test")
+	_, err = client.GenerateSynopsis(ctx, "test", SynopsisStandard, 0)
 	if err == nil {
 		t.Log("Note: GenerateSynopsis didn't fail with canceled context (likely no Ollama server)")
 	}
 }
 
-func TestNormalizeTag(t *testing.T) {
+// newFakeOllama starts a server that answers /api/generate with a fixed
+// response and records the prompts it receives
+func newFakeOllama(t *testing.T, response string) (*Client, *[]string) {
+	t.Helper()
+
+	var prompts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/generate" {
+			http.NotFound(w, r)
+			return
+		}
+		var req struct {
+			Prompt string `json:"prompt"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		prompts = append(prompts, req.Prompt)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"model":    "test-model",
+			"response": response,
+			"done":     true,
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := New(server.URL, "test-model")
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	return client, &prompts
+}
+
+func TestGenerateSynopsisStyles(t *testing.T) {
+	client, prompts := newFakeOllama(t, "A short synopsis.")
+	ctx := context.Background()
+
+	tests := []struct {
+		style    string
+		maxWords int
+		contains []string
+	}{
+		{SynopsisTeaser, 0, []string{"EXACTLY 1 short sentence"}},
+		{SynopsisStandard, 0, []string{"EXACTLY 2 or 3 short sentences"}},
+		{"", 0, []string{"EXACTLY 2 or 3 short sentences"}},
+		{SynopsisAbstract, 0, []string{"4 or 5 sentences"}},
+		{SynopsisAbstract, 80, []string{"4 or 5 sentences", "no more than 80 words"}},
+	}
+
+	for _, tt := range tests {
+		synopsis, err := client.GenerateSynopsis(ctx, "The text to summarize.", tt.style, tt.maxWords)
+		if err != nil {
+			t.Fatalf("GenerateSynopsis(%q) failed: %v", tt.style, err)
+		}
+		if synopsis != "A short synopsis." {
+			t.Errorf("Expected fake response, got %q", synopsis)
+		}
+
+		prompt := (*prompts)[len(*prompts)-1]
+		for _, want := range tt.contains {
+			if !strings.Contains(prompt, want) {
+				t.Errorf("Style %q: expected prompt to contain %q", tt.style, want)
+			}
+		}
+		if tt.maxWords == 0 && strings.Contains(prompt, "words in total") {
+			t.Errorf("Style %q: unexpected word limit in prompt", tt.style)
+		}
+		if !strings.Contains(prompt, "The text to summarize.") {
+			t.Errorf("Style %q: prompt is missing the text", tt.style)
+		}
+	}
+
+	if (*prompts)[0] == (*prompts)[1] || (*prompts)[1] == (*prompts)[3] {
+		t.Error("Expected prompts to differ between styles")
+	}
+}
+
+func TestPromptHashes(t *testing.T) {
+	hashes := PromptHashes()
+
+	steps := []string{"synopsis", "clean", "clean_html", "editorial", "tags", "references", "ai_detection", "quality"}
+	if len(hashes) != len(steps) {
+		t.Errorf("Expected %d prompt hashes, got %v", len(steps), hashes)
+	}
+	seen := make(map[string]string)
+	for _, step := range steps {
+		hash := hashes[step]
+		if len(hash) != 12 {
+			t.Errorf("Expected a 12 character hash for %s, got %q", step, hash)
+		}
+		if other, ok := seen[hash]; ok {
+			t.Errorf("Expected distinct hashes, %s and %s share %q", step, other, hash)
+		}
+		seen[hash] = step
+	}
+
+	// Hashes depend only on the templates
+	for step, hash := range PromptHashes() {
+		if hashes[step] != hash {
+			t.Errorf("Expected a stable hash for %s, got %q then %q", step, hashes[step], hash)
+		}
+	}
+}
+
+func TestDescribeImage(t *testing.T) {
+	imageData := []byte("\x89PNG\r\n\x1a\nfake image")
+	var received struct {
+		Model  string   `json:"model"`
+		Prompt string   `json:"prompt"`
+		Images [][]byte `json:"images"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"model":    "llava",
+			"response": `Here it is: {"caption": "A map of the new tram line.", "tags": ["Transit Map", "tram", "tram"]}`,
+			"done":     true,
+		})
+	}))
+	defer server.Close()
+
+	client, err := New(server.URL, "llava")
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	caption, tags, err := client.DescribeImage(context.Background(), imageData)
+	if err != nil {
+		t.Fatalf("DescribeImage failed: %v", err)
+	}
+	if caption != "A map of the new tram line." {
+		t.Errorf("Unexpected caption %q", caption)
+	}
+	if len(tags) != 2 || tags[0] != "transit-map" || tags[1] != "tram" {
+		t.Errorf("Expected normalized, deduplicated tags, got %v", tags)
+	}
+	if received.Model != "llava" {
+		t.Errorf("Expected the vision model, got %q", received.Model)
+	}
+	if len(received.Images) != 1 || string(received.Images[0]) != string(imageData) {
+		t.Errorf("Expected the image to be sent, got %d images", len(received.Images))
+	}
+
+	generator := NewWithGenerator("model", func(ctx context.Context, prompt string) (string, error) {
+		return `{"caption": "A map."}`, nil
+	})
+	if _, _, err := generator.DescribeImage(context.Background(), imageData); err == nil {
+		t.Error("Expected error describing an image without the Ollama API")
+	}
+	if _, _, err := client.DescribeImage(context.Background(), nil); err == nil {
+		t.Error("Expected error describing an empty image")
+	}
+}
+
+func TestParseImageDescription(t *testing.T) {
 	tests := []struct {
 		name     string
-		input    string
-		expected string
+		response string
+		caption  string
+		wantErr  bool
 	}{
-		{
-			name:     "lowercase conversion",
-			input:    "Machine Learning",
-			expected: "machine-learning",
-		},
-		{
-			name:     "underscore to hyphen",
-			input:    "climate_change",
-			expected: "climate-change",
-		},
-		{
-			name:     "multiple spaces",
-			input:    "New  York  City",
-			expected: "new-york-city",
-		},
-		{
-			name:     "mixed spaces and underscores",
-			input:    "Social_Media Platform",
-			expected: "social-media-platform",
-		},
-		{
-			name:     "leading and trailing spaces",
-			input:    "  einstein  ",
-			expected: "einstein",
-		},
-		{
-			name:     "multiple consecutive hyphens",
-			input:    "foo--bar---baz",
-			expected: "foo-bar-baz",
-		},
-		{
-			name:     "already normalized",
-			input:    "machine-learning",
-			expected: "machine-learning",
-		},
-		{
-			name:     "single word uppercase",
-			input:    "TECHNOLOGY",
-			expected: "technology",
-		},
+		{"plain JSON", `{"caption": "A chart.", "tags": ["chart"]}`, "A chart.", false},
+		{"no tags", `{"caption": "A chart."}`, "A chart.", false},
+		{"no JSON", "A chart of rainfall.", "", true},
+		{"invalid JSON", `{"caption": }`, "", true},
+		{"empty caption", `{"caption": " ", "tags": ["chart"]}`, "", true},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := normalizeTag(tt.input)
-			if result != tt.expected {
-				t.Errorf("Expected %q, got %q", tt.expected, result)
+			caption, _, err := parseImageDescription(tt.response)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			// Errors are recorded as the task's last error, so they never
+			// quote the response
+			if err != nil && strings.Contains(err.Error(), "chart") {
+				t.Errorf("Expected the error to leave out the response, got %v", err)
+			}
+			if caption != tt.caption {
+				t.Errorf("Expected caption %q, got %q", tt.caption, caption)
 			}
 		})
 	}
 }
+
+// newFlakyOllama starts a server that answers the first failures requests to
+// /api/generate with status and the rest with response
+func newFlakyOllama(t *testing.T, failures, status int, response string) (*Client, *atomic.Int32) {
+	t.Helper()
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if int(requests.Add(1)) <= failures {
+			// Proxies answer with HTML the Ollama API client cannot parse
+			w.Header().Set("Content-Type", "text/html")
+			w.WriteHeader(status)
+			w.Write([]byte("<html><body>upstream unavailable</body></html>"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"model":    "test-model",
+			"response": response,
+			"done":     true,
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := New(server.URL, "test-model")
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	client.SetRetryPolicy(DefaultMaxAttempts, time.Millisecond)
+	return client, &requests
+}
+
+func TestGenerateResponseRetries(t *testing.T) {
+	t.Run("recovers from transient failures", func(t *testing.T) {
+		client, requests := newFlakyOllama(t, 2, http.StatusBadGateway, "hello")
+		before := testutil.ToFloat64(retries.WithLabelValues("test-model"))
+
+		response, err := client.GenerateResponse(context.Background(), "prompt")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if response != "hello" {
+			t.Errorf("Expected response %q, got %q", "hello", response)
+		}
+		if got := requests.Load(); got != 3 {
+			t.Errorf("Expected 3 requests, got %d", got)
+		}
+		if got := testutil.ToFloat64(retries.WithLabelValues("test-model")) - before; got != 2 {
+			t.Errorf("Expected 2 retries counted, got %v", got)
+		}
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		client, requests := newFlakyOllama(t, 5, http.StatusServiceUnavailable, "hello")
+
+		_, err := client.GenerateResponse(context.Background(), "prompt")
+		var serverErr *ServerError
+		if !errors.As(err, &serverErr) || serverErr.StatusCode != http.StatusServiceUnavailable {
+			t.Fatalf("Expected a 503 server error, got %v", err)
+		}
+		if !strings.Contains(err.Error(), "upstream unavailable") {
+			t.Errorf("Expected error to quote the response body, got %v", err)
+		}
+		if got := requests.Load(); got != DefaultMaxAttempts {
+			t.Errorf("Expected %d requests, got %d", DefaultMaxAttempts, got)
+		}
+	})
+
+	t.Run("does not retry client errors", func(t *testing.T) {
+		client, requests := newFlakyOllama(t, 1, http.StatusBadRequest, "hello")
+
+		if _, err := client.GenerateResponse(context.Background(), "prompt"); err == nil {
+			t.Fatal("Expected error for a bad request")
+		}
+		if got := requests.Load(); got != 1 {
+			t.Errorf("Expected 1 request, got %d", got)
+		}
+	})
+
+	t.Run("retries at once with a negative backoff", func(t *testing.T) {
+		client, requests := newFlakyOllama(t, 2, http.StatusBadGateway, "hello")
+		client.SetRetryPolicy(3, -time.Second)
+
+		if _, err := client.GenerateResponse(context.Background(), "prompt"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if got := requests.Load(); got != 3 {
+			t.Errorf("Expected 3 requests, got %d", got)
+		}
+	})
+
+	t.Run("stays within the deadline", func(t *testing.T) {
+		client, requests := newFlakyOllama(t, 5, http.StatusBadGateway, "hello")
+		client.SetRetryPolicy(5, time.Second)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		if _, err := client.GenerateResponse(ctx, "prompt"); err == nil {
+			t.Fatal("Expected error")
+		}
+		if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+			t.Errorf("Expected retries to stop at the deadline, took %v", elapsed)
+		}
+		if got := requests.Load(); got != 1 {
+			t.Errorf("Expected no retry past the deadline, got %d requests", got)
+		}
+	})
+}
+
+func TestStructuredOutputFormat(t *testing.T) {
+	var formats []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Format json.RawMessage `json:"format"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		formats = append(formats, string(req.Format))
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"model":    "test-model",
+			"response": `{"tags": ["golang"], "references": [], "score": 0.8, "likelihood": "unlikely"}`,
+			"done":     true,
+		})
+	}))
+	defer server.Close()
+
+	client, err := New(server.URL, "test-model")
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	ctx := context.Background()
+
+	if _, err := client.GenerateTags(ctx, "text", nil); err != nil {
+		t.Errorf("GenerateTags failed: %v", err)
+	}
+	if _, err := client.ExtractReferences(ctx, "text"); err != nil {
+		t.Errorf("ExtractReferences failed: %v", err)
+	}
+	if _, err := client.DetectAIContent(ctx, "text"); err != nil {
+		t.Errorf("DetectAIContent failed: %v", err)
+	}
+	if _, err := client.ScoreTextQuality(ctx, "text"); err != nil {
+		t.Errorf("ScoreTextQuality failed: %v", err)
+	}
+	if _, err := client.GenerateResponse(ctx, "text"); err != nil {
+		t.Errorf("GenerateResponse failed: %v", err)
+	}
+
+	expected := []string{`"json"`, `"json"`, `"json"`, `"json"`, ""}
+	if !reflect.DeepEqual(formats, expected) {
+		t.Errorf("Expected formats %v, got %v", expected, formats)
+	}
+}
@@ -0,0 +1,125 @@
+package ollama
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Editorial purposes classify why a text was written
+const (
+	PurposeInformational = "informational"
+	PurposePersuasive    = "persuasive"
+	PurposeEntertainment = "entertainment"
+	PurposeAdvertorial   = "advertorial"
+)
+
+// Editorial biases classify the slant of a text
+const (
+	BiasLeft        = "left"
+	BiasCenterLeft  = "center-left"
+	BiasCenter      = "center"
+	BiasCenterRight = "center-right"
+	BiasRight       = "right"
+	BiasCommercial  = "commercial"
+	BiasAcademic    = "academic"
+	BiasNone        = "none"
+)
+
+// EditorialPurposes and EditorialBiases are the values an editorial
+// analysis classifies texts with
+var (
+	EditorialPurposes = []string{PurposeInformational, PurposePersuasive, PurposeEntertainment, PurposeAdvertorial}
+	EditorialBiases   = []string{BiasLeft, BiasCenterLeft, BiasCenter, BiasCenterRight, BiasRight, BiasCommercial, BiasAcademic, BiasNone}
+)
+
+// purposeSynonyms maps other words models use for a purpose to it
+var purposeSynonyms = map[string]string{
+	"informative":   PurposeInformational,
+	"information":   PurposeInformational,
+	"educational":   PurposeInformational,
+	"news":          PurposeInformational,
+	"opinion":       PurposePersuasive,
+	"persuasion":    PurposePersuasive,
+	"argumentative": PurposePersuasive,
+	"entertaining":  PurposeEntertainment,
+	"advertising":   PurposeAdvertorial,
+	"advertisement": PurposeAdvertorial,
+	"promotional":   PurposeAdvertorial,
+	"sponsored":     PurposeAdvertorial,
+}
+
+// biasSynonyms maps other words models use for a bias to it
+var biasSynonyms = map[string]string{
+	"neutral":      BiasNone,
+	"unbiased":     BiasNone,
+	"balanced":     BiasNone,
+	"objective":    BiasNone,
+	"n/a":          BiasNone,
+	"centrist":     BiasCenter,
+	"moderate":     BiasCenter,
+	"liberal":      BiasLeft,
+	"progressive":  BiasLeft,
+	"conservative": BiasRight,
+	"promotional":  BiasCommercial,
+	"corporate":    BiasCommercial,
+	"scholarly":    BiasAcademic,
+}
+
+// EditorialResult classifies the purpose, slant and tone of a text. Purpose
+// and Bias are empty when the model's answer matched none of the values.
+type EditorialResult struct {
+	Purpose string `json:"purpose"`
+	Bias    string `json:"bias"`
+	Tone    string `json:"tone"`
+	Summary string `json:"summary"`
+}
+
+// editorialResponse is an EditorialAnalysis response as models send it,
+// sometimes giving a list where one value was asked for
+type editorialResponse struct {
+	Purpose looseString `json:"purpose"`
+	Bias    looseString `json:"bias"`
+	Tone    looseString `json:"tone"`
+	Summary looseString `json:"summary"`
+}
+
+// parseEditorial reads the classification from an EditorialAnalysis
+// response, normalizing the spelling of purposes and biases
+func parseEditorial(response string) (*EditorialResult, error) {
+	decoded, err := decodeJSON[editorialResponse](response)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse editorial analysis JSON: %w", err)
+	}
+
+	result := &EditorialResult{
+		Purpose: normalizeEditorial(string(decoded.Purpose), EditorialPurposes, purposeSynonyms),
+		Bias:    normalizeEditorial(string(decoded.Bias), EditorialBiases, biasSynonyms),
+		Tone:    strings.ToLower(strings.TrimSpace(string(decoded.Tone))),
+		Summary: strings.TrimSpace(string(decoded.Summary)),
+	}
+	if result.Summary == "" && result.Purpose == "" && result.Bias == "" {
+		return nil, errors.New("editorial analysis has no summary, purpose or bias")
+	}
+	return result, nil
+}
+
+// normalizeEditorial returns the value in allowed that a model's answer
+// spells, such as "center-left" for "Centre Left" or "left-leaning" for
+// "left", or an empty string when it matches none
+func normalizeEditorial(value string, allowed []string, synonyms map[string]string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	value = strings.ReplaceAll(value, "centre", "center")
+	value = strings.Join(strings.FieldsFunc(value, func(r rune) bool {
+		return r == ' ' || r == '_' || r == '-'
+	}), "-")
+	for _, suffix := range []string{"-leaning", "-wing", "-bias", "-biased"} {
+		value = strings.TrimSuffix(value, suffix)
+	}
+
+	if slices.Contains(allowed, value) {
+		return value
+	}
+	return synonyms[value]
+}
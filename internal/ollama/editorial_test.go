@@ -0,0 +1,85 @@
+package ollama
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseEditorial(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		expected EditorialResult
+	}{
+		{
+			name:     "clean JSON",
+			response: `{"purpose": "informational", "bias": "center-left", "tone": "neutral", "summary": "Reports on transit funding. Tone is measured."}`,
+			expected: EditorialResult{Purpose: "informational", Bias: "center-left", Tone: "neutral", Summary: "Reports on transit funding. Tone is measured."},
+		},
+		{
+			name:     "code fence and prose",
+			response: "Here is my assessment:\n```json\n{\"purpose\": \"Persuasive\", \"bias\": \"Centre Right\", \"tone\": \"Urgent\", \"summary\": \"Argues for tax cuts.\"}\n```\nLet me know if you need more.",
+			expected: EditorialResult{Purpose: "persuasive", Bias: "center-right", Tone: "urgent", Summary: "Argues for tax cuts."},
+		},
+		{
+			name:     "synonyms and spellings",
+			response: `{"Purpose": "advertising", "Bias": "right_wing", "tone": " Upbeat ", "summary": " Promotes a product. "}`,
+			expected: EditorialResult{Purpose: "advertorial", Bias: "right", Tone: "upbeat", Summary: "Promotes a product."},
+		},
+		{
+			name:     "neutral means no bias",
+			response: `{"purpose": "news", "bias": "Neutral", "tone": "dry", "summary": "Lists election results."}`,
+			expected: EditorialResult{Purpose: "informational", Bias: "none", Tone: "dry", Summary: "Lists election results."},
+		},
+		{
+			name:     "lists and unknown values",
+			response: `{"purpose": ["persuasive", "informational"], "bias": "libertarian", "tone": null, "summary": ["Makes a case for deregulation."]}`,
+			expected: EditorialResult{Purpose: "persuasive", Bias: "", Tone: "", Summary: "Makes a case for deregulation."},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parseEditorial(tt.response)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if *result != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, *result)
+			}
+		})
+	}
+
+	for _, response := range []string{"The text is informational and neutral.", `{"tone": "neutral"}`, `{"purpose": {"kind": "news"}}`} {
+		if _, err := parseEditorial(response); err == nil {
+			t.Errorf("Expected an error for %q", response)
+		}
+	}
+}
+
+func TestEditorialAnalysis(t *testing.T) {
+	var prompts []string
+	client := NewWithGenerator("model", func(ctx context.Context, prompt string) (string, error) {
+		prompts = append(prompts, prompt)
+		return `{"purpose": "entertainment", "bias": "none", "tone": "playful", "summary": "A light review of a film."}`, nil
+	})
+
+	result, err := client.EditorialAnalysis(context.Background(), "The film is a delight.")
+	if err != nil {
+		t.Fatalf("EditorialAnalysis failed: %v", err)
+	}
+	expected := &EditorialResult{Purpose: PurposeEntertainment, Bias: BiasNone, Tone: "playful", Summary: "A light review of a film."}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, result)
+	}
+	if len(prompts) != 1 || !strings.Contains(prompts[0], "The film is a delight.") {
+		t.Errorf("Expected one prompt with the text, got %q", prompts)
+	}
+	for _, value := range append(append([]string(nil), EditorialPurposes...), EditorialBiases...) {
+		if !strings.Contains(prompts[0], `"`+value+`"`) {
+			t.Errorf("Expected the prompt to offer %q", value)
+		}
+	}
+}
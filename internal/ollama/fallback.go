@@ -0,0 +1,143 @@
+package ollama
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/ollama/ollama/api"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// responses counts the responses each model produced, fallback models included
+var responses = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "textanalyzer_ollama_responses_total",
+	Help: "Responses produced by the LLM, by the model that produced them, fallback models included",
+}, []string{"model"})
+
+// memoryErrorMarkers are phrases of Ollama errors for a model that could not
+// be loaded, usually for lack of memory
+var memoryErrorMarkers = []string{
+	"out of memory",
+	"requires more system memory",
+	"unable to load model",
+	"failed to load model",
+}
+
+// WithFallbackModels sets the models a call is sent to in turn when the
+// client's model fails with a model error, such as the model not being
+// pulled or not fitting in memory. The fallbacks are served by the same
+// Ollama server, so connection failures are not retried against them.
+func WithFallbackModels(models ...string) Option {
+	return func(c *Client) {
+		c.fallbacks = nil
+		for _, model := range models {
+			if model = strings.TrimSpace(model); model != "" && model != c.model {
+				c.fallbacks = append(c.fallbacks, model)
+			}
+		}
+	}
+}
+
+// FallbackModels returns the models calls fall back to, in order
+func (c *Client) FallbackModels() []string {
+	return slices.Clone(c.fallbacks)
+}
+
+// isModelError reports whether Ollama failed a request because of the model
+// itself: it is not pulled, or it could not be loaded
+func isModelError(err error) bool {
+	var message string
+	var statusErr api.StatusError
+	var serverErr *ServerError
+	switch {
+	case errors.As(err, &statusErr):
+		message = statusErr.ErrorMessage + " " + statusErr.Status
+		if statusErr.StatusCode == http.StatusNotFound {
+			return strings.Contains(strings.ToLower(message), "model")
+		}
+	case errors.As(err, &serverErr):
+		message = serverErr.Body
+	default:
+		return false
+	}
+
+	message = strings.ToLower(message)
+	for _, marker := range memoryErrorMarkers {
+		if strings.Contains(message, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// callWithFallback sends req to the client's model and, while it fails with
+// a model error, to each fallback model in turn. It returns the model that
+// produced the response.
+func (c *Client) callWithFallback(ctx context.Context, req *api.GenerateRequest) (string, string, error) {
+	req.Model = c.model
+	response, err := c.callAPI(ctx, req)
+	for _, fallback := range c.fallbacks {
+		if err == nil || !isModelError(err) {
+			break
+		}
+		slog.Warn("ollama model failed, falling back to the next model",
+			"model", req.Model,
+			"fallback", fallback,
+			"error", err,
+		)
+		req.Model = fallback
+		response, err = c.callAPI(ctx, req)
+	}
+	return response, req.Model, err
+}
+
+// modelUsageKey is the context key of a ModelUsage
+type modelUsageKey struct{}
+
+// ModelUsage records which models produced the responses to calls made with
+// its context, so results can be attributed to fallback models
+type ModelUsage struct {
+	mu     sync.Mutex
+	models []string
+}
+
+// WithModelUsage returns a context recording the models that produce the
+// responses to calls made with it
+func WithModelUsage(ctx context.Context) (context.Context, *ModelUsage) {
+	usage := &ModelUsage{}
+	return context.WithValue(ctx, modelUsageKey{}, usage), usage
+}
+
+// Models returns the models that produced responses, in the order they were
+// first used
+func (u *ModelUsage) Models() []string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return slices.Clone(u.models)
+}
+
+// recordResponse counts a response produced by model, recording the model
+// in the ModelUsage of ctx if any
+func recordResponse(ctx context.Context, model string) {
+	responses.WithLabelValues(model).Inc()
+	recordModel(ctx, model)
+}
+
+// recordModel records model in the ModelUsage of ctx if any, for responses
+// produced now or earlier and reused from the cache
+func recordModel(ctx context.Context, model string) {
+	usage, ok := ctx.Value(modelUsageKey{}).(*ModelUsage)
+	if !ok {
+		return
+	}
+	usage.mu.Lock()
+	defer usage.mu.Unlock()
+	if !slices.Contains(usage.models, model) {
+		usage.models = append(usage.models, model)
+	}
+}
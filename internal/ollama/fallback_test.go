@@ -0,0 +1,146 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/ollama/ollama/api"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// newModelErrorOllama starts a server that fails requests for the models in
+// failures with the given status and error, answers the others, and records
+// the model of each request
+func newModelErrorOllama(t *testing.T, model string, failures map[string]api.StatusError, opts ...Option) (*Client, func() []string) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var models []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req api.GenerateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		models = append(models, req.Model)
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		if failure, ok := failures[req.Model]; ok {
+			w.WriteHeader(failure.StatusCode)
+			json.NewEncoder(w).Encode(map[string]string{"error": failure.ErrorMessage})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"model":    req.Model,
+			"response": "answer from " + req.Model,
+			"done":     true,
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := New(server.URL, model, opts...)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	client.SetRetryPolicy(3, 0)
+	return client, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), models...)
+	}
+}
+
+func TestFallbackModels(t *testing.T) {
+	failures := map[string]api.StatusError{
+		"fallback-big":    {StatusCode: http.StatusNotFound, ErrorMessage: `model "fallback-big" not found, try pulling it first`},
+		"fallback-medium": {StatusCode: http.StatusInternalServerError, ErrorMessage: "model requires more system memory (20.1 GiB) than is available (7.6 GiB)"},
+	}
+	client, requested := newModelErrorOllama(t, "fallback-big", failures,
+		WithFallbackModels("fallback-medium", " ", "fallback-big", "fallback-small"))
+	if expected := []string{"fallback-medium", "fallback-small"}; !reflect.DeepEqual(client.FallbackModels(), expected) {
+		t.Errorf("Expected fallbacks %v, got %v", expected, client.FallbackModels())
+	}
+
+	before := testutil.ToFloat64(responses.WithLabelValues("fallback-small"))
+	ctx, usage := WithModelUsage(context.Background())
+	response, err := client.GenerateResponse(ctx, "prompt")
+	if err != nil {
+		t.Fatalf("Expected a fallback model to answer, got %v", err)
+	}
+	if response != "answer from fallback-small" {
+		t.Errorf("Unexpected response %q", response)
+	}
+
+	// Model errors are not retried against the same model
+	if expected := []string{"fallback-big", "fallback-medium", "fallback-small"}; !reflect.DeepEqual(requested(), expected) {
+		t.Errorf("Expected requests to %v, got %v", expected, requested())
+	}
+	if models := usage.Models(); !reflect.DeepEqual(models, []string{"fallback-small"}) {
+		t.Errorf("Expected the fallback model to be recorded, got %v", models)
+	}
+	if got := testutil.ToFloat64(responses.WithLabelValues("fallback-small")) - before; got != 1 {
+		t.Errorf("Expected 1 response counted for the fallback model, got %v", got)
+	}
+
+	// Every model failing returns the last error
+	failures = map[string]api.StatusError{
+		"failing-big":   {StatusCode: http.StatusNotFound, ErrorMessage: `model "failing-big" not found, try pulling it first`},
+		"failing-small": {StatusCode: http.StatusInternalServerError, ErrorMessage: "CUDA error: out of memory"},
+	}
+	failing, _ := newModelErrorOllama(t, "failing-big", failures, WithFallbackModels("failing-small"))
+	if _, err := failing.GenerateResponse(context.Background(), "prompt"); err == nil || !isModelError(err) {
+		t.Errorf("Expected the last model error, got %v", err)
+	}
+}
+
+func TestFallbackModelsIgnoreServerFailures(t *testing.T) {
+	failures := map[string]api.StatusError{
+		"unavailable-big": {StatusCode: http.StatusServiceUnavailable, ErrorMessage: "server busy, please try again"},
+	}
+	client, requested := newModelErrorOllama(t, "unavailable-big", failures, WithFallbackModels("unavailable-small"))
+
+	if _, err := client.GenerateResponse(context.Background(), "prompt"); err == nil {
+		t.Fatal("Expected the server failure to be returned")
+	}
+	// The failure is retried against the same model, never the fallback
+	for _, model := range requested() {
+		if model != "unavailable-big" {
+			t.Errorf("Expected no request to the fallback model, got one to %s", model)
+		}
+	}
+	if got := len(requested()); got != 3 {
+		t.Errorf("Expected the transient failure to be retried 3 times, got %d requests", got)
+	}
+}
+
+func TestIsModelError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"model not found", api.StatusError{StatusCode: http.StatusNotFound, ErrorMessage: `model "llama3" not found, try pulling it first`}, true},
+		{"page not found", api.StatusError{StatusCode: http.StatusNotFound, Status: "404 Not Found"}, false},
+		{"out of memory", &ServerError{StatusCode: http.StatusInternalServerError, Body: `{"error":"llama runner process has terminated: cudaMalloc failed: out of memory"}`}, true},
+		{"not enough memory", &ServerError{StatusCode: http.StatusInternalServerError, Body: `{"error":"model requires more system memory (20 GiB) than is available (8 GiB)"}`}, true},
+		{"proxy failure", &ServerError{StatusCode: http.StatusBadGateway, Body: "<html>bad gateway</html>"}, false},
+		{"bad request", api.StatusError{StatusCode: http.StatusBadRequest, ErrorMessage: "invalid options"}, false},
+		{"connection refused", errors.New("dial tcp: connection refused"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isModelError(tt.err); got != tt.expected {
+				t.Errorf("isModelError(%v) = %v, expected %v", tt.err, got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package ollama
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultHealthInterval is how often HealthMonitor checks the Ollama server
+const DefaultHealthInterval = 30 * time.Second
+
+// healthCheckTimeout bounds each check of HealthMonitor
+const healthCheckTimeout = 5 * time.Second
+
+// Health pings the Ollama server
+func (c *Client) Health(ctx context.Context) error {
+	if c.client == nil {
+		return fmt.Errorf("health check requires the Ollama API")
+	}
+	if err := c.client.Heartbeat(ctx); err != nil {
+		return fmt.Errorf("ollama is unreachable: %w", err)
+	}
+	return nil
+}
+
+// ListModels returns the names of the models the Ollama server has pulled
+func (c *Client) ListModels(ctx context.Context) ([]string, error) {
+	if c.client == nil {
+		return nil, fmt.Errorf("listing models requires the Ollama API")
+	}
+	list, err := c.client.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list models: %w", err)
+	}
+
+	names := make([]string, 0, len(list.Models))
+	for _, model := range list.Models {
+		names = append(names, model.Name)
+	}
+	return names, nil
+}
+
+// HasModel reports whether the Ollama server has pulled the model name. A
+// name without a tag matches the latest tag, as it does for Ollama.
+func (c *Client) HasModel(ctx context.Context, name string) (bool, error) {
+	names, err := c.ListModels(ctx)
+	if err != nil {
+		return false, err
+	}
+	want := qualifiedModelName(name)
+	for _, model := range names {
+		if qualifiedModelName(model) == want {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// qualifiedModelName adds the latest tag to a model name without a tag
+func qualifiedModelName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if i := strings.LastIndex(name, "/"); !strings.Contains(name[i+1:], ":") {
+		name += ":latest"
+	}
+	return name
+}
+
+// Ready checks that the Ollama server is up and has the client's model
+func (c *Client) Ready(ctx context.Context) error {
+	if err := c.Health(ctx); err != nil {
+		return err
+	}
+	found, err := c.HasModel(ctx, c.model)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("model %s not found on the Ollama server", c.model)
+	}
+	return nil
+}
+
+// HealthMonitor checks the Ollama server in the background, exporting
+// whether it is up as the ollama_up gauge
+type HealthMonitor struct {
+	client   *Client
+	interval time.Duration
+	logger   *slog.Logger
+	gauge    prometheus.Gauge
+
+	// newTicker is replaceable for tests
+	newTicker func(d time.Duration) (<-chan time.Time, func())
+
+	upMu sync.RWMutex
+	up   bool
+
+	mu      sync.Mutex
+	stop    chan struct{}
+	done    chan struct{}
+	running bool
+}
+
+// NewHealthMonitor creates a monitor that checks client every interval and
+// registers its gauge with registerer
+func NewHealthMonitor(client *Client, interval time.Duration, registerer prometheus.Registerer, logger *slog.Logger) *HealthMonitor {
+	if interval <= 0 {
+		interval = DefaultHealthInterval
+	}
+	return &HealthMonitor{
+		client:   client,
+		interval: interval,
+		logger:   logger,
+		gauge:    newUpGauge(registerer, logger),
+		newTicker: func(d time.Duration) (<-chan time.Time, func()) {
+			ticker := time.NewTicker(d)
+			return ticker.C, ticker.Stop
+		},
+	}
+}
+
+// newUpGauge registers the ollama_up gauge, reusing one already registered
+// by an earlier monitor
+func newUpGauge(registerer prometheus.Registerer, logger *slog.Logger) prometheus.Gauge {
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "ollama_up",
+		Help: "Whether the Ollama server answered the last health check (1) or not (0)",
+	})
+
+	if err := registerer.Register(gauge); err != nil {
+		if existing, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			return existing.ExistingCollector.(prometheus.Gauge)
+		}
+		logger.Warn("failed to register ollama_up gauge", "error", err)
+	}
+	return gauge
+}
+
+// Start checks the server once and then every interval until Stop is called
+func (m *HealthMonitor) Start() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.running {
+		return
+	}
+
+	m.running = true
+	m.stop = make(chan struct{})
+	m.done = make(chan struct{})
+
+	ticks, stopTicker := m.newTicker(m.interval)
+	m.check()
+
+	go func() {
+		defer close(m.done)
+		defer stopTicker()
+		for {
+			select {
+			case <-m.stop:
+				return
+			case <-ticks:
+				m.check()
+			}
+		}
+	}()
+}
+
+// Stop halts checking and waits for the checking goroutine to exit
+func (m *HealthMonitor) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.running {
+		return
+	}
+
+	close(m.stop)
+	<-m.done
+	m.running = false
+}
+
+// check pings the server, logging when it goes down or comes back up
+func (m *HealthMonitor) check() {
+	ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+	defer cancel()
+	err := m.client.Health(ctx)
+
+	m.upMu.Lock()
+	wasUp := m.up
+	m.up = err == nil
+	m.upMu.Unlock()
+
+	if err != nil {
+		m.gauge.Set(0)
+		if wasUp {
+			m.logger.Warn("ollama went down", "model", m.client.Model(), "error", err)
+		}
+		return
+	}
+	m.gauge.Set(1)
+	if !wasUp {
+		m.logger.Info("ollama is up", "model", m.client.Model())
+	}
+}
+
+// Up reports whether the Ollama server answered the last health check
+func (m *HealthMonitor) Up() bool {
+	m.upMu.RLock()
+	defer m.upMu.RUnlock()
+	return m.up
+}
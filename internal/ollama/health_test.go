@@ -0,0 +1,149 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// newModelServer starts a fake Ollama server with the pulled models, which
+// answers with 503 while down is set
+func newModelServer(t *testing.T, model string, models ...string) (*Client, *atomic.Bool) {
+	t.Helper()
+
+	var down atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte("Ollama is running"))
+		case "/api/tags":
+			var list struct {
+				Models []map[string]string `json:"models"`
+			}
+			for _, name := range models {
+				list.Models = append(list.Models, map[string]string{"name": name, "model": name})
+			}
+			json.NewEncoder(w).Encode(list)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := New(server.URL, model)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	return client, &down
+}
+
+func TestHealthAndModels(t *testing.T) {
+	client, down := newModelServer(t, "gpt-oss:20b", "gpt-oss:20b", "llama3.2-vision:latest", "library/qwen3:8b")
+	ctx := context.Background()
+
+	if err := client.Health(ctx); err != nil {
+		t.Errorf("Expected healthy server, got %v", err)
+	}
+
+	models, err := client.ListModels(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list models: %v", err)
+	}
+	expected := []string{"gpt-oss:20b", "llama3.2-vision:latest", "library/qwen3:8b"}
+	if !reflect.DeepEqual(models, expected) {
+		t.Errorf("Expected models %v, got %v", expected, models)
+	}
+
+	tests := []struct {
+		name     string
+		expected bool
+	}{
+		{"gpt-oss:20b", true},
+		{"llama3.2-vision", true},
+		{"Llama3.2-Vision:latest", true},
+		{"library/qwen3:8b", true},
+		{"gpt-oss:120b", false},
+		{"gpt-oss", false},
+	}
+	for _, tt := range tests {
+		found, err := client.HasModel(ctx, tt.name)
+		if err != nil {
+			t.Fatalf("HasModel(%q) failed: %v", tt.name, err)
+		}
+		if found != tt.expected {
+			t.Errorf("HasModel(%q) = %v, expected %v", tt.name, found, tt.expected)
+		}
+	}
+
+	if err := client.Ready(ctx); err != nil {
+		t.Errorf("Expected client to be ready, got %v", err)
+	}
+
+	missing, _ := newModelServer(t, "gpt-oss:2b", "gpt-oss:20b")
+	if err := missing.Ready(ctx); err == nil || !strings.Contains(err.Error(), "gpt-oss:2b not found") {
+		t.Errorf("Expected missing model error, got %v", err)
+	}
+
+	down.Store(true)
+	if err := client.Health(ctx); err == nil {
+		t.Error("Expected error from a down server")
+	}
+	if err := client.Ready(ctx); err == nil {
+		t.Error("Expected a down server not to be ready")
+	}
+
+	generator := NewWithGenerator("model", func(ctx context.Context, prompt string) (string, error) { return "", nil })
+	if err := generator.Health(ctx); err == nil {
+		t.Error("Expected health check to require the Ollama API")
+	}
+}
+
+func TestHealthMonitor(t *testing.T) {
+	client, down := newModelServer(t, "gpt-oss:20b", "gpt-oss:20b")
+	registry := prometheus.NewRegistry()
+	monitor := NewHealthMonitor(client, time.Minute, registry, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	ticks := make(chan time.Time)
+	monitor.newTicker = func(d time.Duration) (<-chan time.Time, func()) {
+		return ticks, func() {}
+	}
+
+	monitor.Start()
+	defer monitor.Stop()
+	if !monitor.Up() || testutil.ToFloat64(monitor.gauge) != 1 {
+		t.Errorf("Expected ollama_up 1 after the first check, got %v", testutil.ToFloat64(monitor.gauge))
+	}
+
+	down.Store(true)
+	ticks <- time.Now()
+	ticks <- time.Now() // Returns once the previous check has finished
+	if monitor.Up() || testutil.ToFloat64(monitor.gauge) != 0 {
+		t.Errorf("Expected ollama_up 0 while down, got %v", testutil.ToFloat64(monitor.gauge))
+	}
+
+	down.Store(false)
+	ticks <- time.Now()
+	ticks <- time.Now()
+	if !monitor.Up() || testutil.ToFloat64(monitor.gauge) != 1 {
+		t.Errorf("Expected ollama_up 1 once back up, got %v", testutil.ToFloat64(monitor.gauge))
+	}
+
+	if count := testutil.CollectAndCount(registry, "ollama_up"); count != 1 {
+		t.Errorf("Expected ollama_up to be registered, got %d series", count)
+	}
+}
@@ -0,0 +1,202 @@
+package ollama
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"github.com/docutag/textanalyzer/internal/textutil"
+)
+
+// jsonFormat constrains Ollama to respond with a JSON object
+var jsonFormat = json.RawMessage(`"json"`)
+
+// maxJSONCandidates is the most embedded JSON values tried in a response
+const maxJSONCandidates = 32
+
+// fencePattern matches a markdown code block, capturing its content
+var fencePattern = regexp.MustCompile("(?s)```[a-zA-Z]*\\s*\\n?(.*?)```")
+
+// logUnparsed logs a preview of a model response that could not be parsed.
+// Errors leave the response out, since they are recorded as a task's last
+// error and the response can quote a redacted document.
+func logUnparsed(problem, response string) {
+	slog.Debug("unparsable model response", "problem", problem, "response", textutil.Preview(response, maxErrorPreview))
+}
+
+// decodeJSON parses the JSON value a model responded with. Models honoring
+// the JSON format respond with the value alone; otherwise the value is looked
+// for in markdown code blocks and then in the text, trying each balanced
+// {...} or [...] in turn, so prose and stray braces around it are skipped.
+// A response that is valid JSON is not searched.
+func decodeJSON[T any](response string) (T, error) {
+	trimmed := strings.TrimSpace(response)
+
+	var value T
+	err := json.Unmarshal([]byte(trimmed), &value)
+	if err == nil {
+		return value, nil
+	}
+	// A response that is JSON of the wrong shape holds no other value
+	if json.Valid([]byte(trimmed)) {
+		return value, err
+	}
+
+	candidates := jsonCandidates(trimmed)
+	if len(candidates) == 0 {
+		logUnparsed("no JSON found", trimmed)
+		return value, errors.New("no JSON found in response")
+	}
+	for _, candidate := range candidates {
+		var decoded T
+		if err = json.Unmarshal([]byte(candidate), &decoded); err == nil {
+			return decoded, nil
+		}
+	}
+	return value, err
+}
+
+// jsonCandidates returns the contents of the code blocks in s, then the
+// balanced JSON objects and arrays in s in the order they start
+func jsonCandidates(s string) []string {
+	var candidates []string
+	for _, match := range fencePattern.FindAllStringSubmatch(s, -1) {
+		if block := strings.TrimSpace(match[1]); block != "" {
+			candidates = append(candidates, block)
+		}
+	}
+
+	for i := 0; i < len(s) && len(candidates) < maxJSONCandidates; i++ {
+		if s[i] != '{' && s[i] != '[' {
+			continue
+		}
+		if end := balancedEnd(s, i); end > i {
+			candidates = append(candidates, s[i:end+1])
+		}
+	}
+	return candidates
+}
+
+// balancedEnd returns the index of the bracket closing the one at start,
+// skipping brackets inside JSON strings, or -1 when it is not closed or the
+// brackets do not pair up
+func balancedEnd(s string, start int) int {
+	var closers []byte
+	inString, escaped := false, false
+	for i := start; i < len(s); i++ {
+		c := s[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+
+		switch c {
+		case '"':
+			inString = true
+		case '{':
+			closers = append(closers, '}')
+		case '[':
+			closers = append(closers, ']')
+		case '}', ']':
+			if closers[len(closers)-1] != c {
+				return -1
+			}
+			closers = closers[:len(closers)-1]
+			if len(closers) == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// tagList decodes generated tags sent either as a JSON array or as an
+// object holding the array under "tags"
+type tagList []string
+
+func (l *tagList) UnmarshalJSON(data []byte) error {
+	var list []string
+	if err := json.Unmarshal(data, &list); err == nil {
+		*l = list
+		return nil
+	}
+
+	var wrapped struct {
+		Tags *[]string `json:"tags"`
+	}
+	if err := json.Unmarshal(data, &wrapped); err != nil {
+		return err
+	}
+	if wrapped.Tags == nil {
+		logUnparsed("no tags field", string(data))
+		return errors.New("no tags field in response")
+	}
+	*l = *wrapped.Tags
+	return nil
+}
+
+// referenceList decodes extracted references sent either as a JSON array or
+// as an object holding the array under "references"
+type referenceList []Reference
+
+func (l *referenceList) UnmarshalJSON(data []byte) error {
+	var list []Reference
+	if err := json.Unmarshal(data, &list); err == nil {
+		*l = list
+		return nil
+	}
+
+	var wrapped struct {
+		References *[]Reference `json:"references"`
+	}
+	if err := json.Unmarshal(data, &wrapped); err != nil {
+		return err
+	}
+	if wrapped.References == nil {
+		logUnparsed("no references field", string(data))
+		return errors.New("no references field in response")
+	}
+	*l = *wrapped.References
+	return nil
+}
+
+// looseString decodes a string sent as a JSON string, as a list whose first
+// string is taken, or as a number or boolean; null leaves it empty
+type looseString string
+
+func (s *looseString) UnmarshalJSON(data []byte) error {
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	switch value := value.(type) {
+	case nil:
+		*s = ""
+	case string:
+		*s = looseString(value)
+	case []any:
+		*s = ""
+		for _, item := range value {
+			if text, ok := item.(string); ok {
+				*s = looseString(text)
+				break
+			}
+		}
+	case float64, bool:
+		*s = looseString(fmt.Sprint(value))
+	default:
+		logUnparsed("expected a string", string(data))
+		return fmt.Errorf("expected a string, got %T", value)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package ollama
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/semaphore"
+)
+
+// DefaultMaxConcurrent is how many generations an Ollama client runs at a
+// time, the others waiting their turn
+const DefaultMaxConcurrent = 2
+
+// queueWait observes how long generations waited for a free slot
+var queueWait = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "textanalyzer_ollama_queue_wait_seconds",
+	Help:    "Time generations waited for one of the client's OLLAMA_MAX_CONCURRENT slots, by model",
+	Buckets: prometheus.ExponentialBuckets(0.001, 4, 10),
+}, []string{"model"})
+
+// WithMaxConcurrent limits the generations the client runs at a time to n,
+// shared by every caller of the client; further calls wait for one to
+// finish. An n below one removes the limit.
+func WithMaxConcurrent(n int) Option {
+	return func(c *Client) {
+		c.limiter = nil
+		if n > 0 {
+			c.limiter = semaphore.NewWeighted(int64(n))
+		}
+	}
+}
+
+// acquire waits for a generation slot, or until ctx is done. The returned
+// function releases the slot.
+func (c *Client) acquire(ctx context.Context) (func(), error) {
+	if c.limiter == nil {
+		return func() {}, nil
+	}
+	start := time.Now()
+	err := c.limiter.Acquire(ctx, 1)
+	queueWait.WithLabelValues(c.model).Observe(time.Since(start).Seconds())
+	if err != nil {
+		return nil, err
+	}
+	return func() { c.limiter.Release(1) }, nil
+}
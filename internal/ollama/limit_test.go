@@ -0,0 +1,95 @@
+package ollama
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// blockingGenerator returns a client limited to n generations whose
+// generations wait for a value on finish, and a channel receiving each
+// prompt as its generation starts
+func blockingGenerator(n int) (*Client, chan<- struct{}, <-chan string) {
+	finish := make(chan struct{})
+	started := make(chan string, 10)
+	client := NewWithGenerator("model", func(ctx context.Context, prompt string) (string, error) {
+		started <- prompt
+		select {
+		case <-finish:
+			return prompt, nil
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}, WithMaxConcurrent(n))
+	return client, finish, started
+}
+
+func TestMaxConcurrent(t *testing.T) {
+	client, finish, started := blockingGenerator(2)
+	ctx := context.Background()
+
+	var done atomic.Int32
+	for _, prompt := range []string{"first", "second", "third"} {
+		go func() {
+			client.GenerateResponse(ctx, prompt)
+			done.Add(1)
+		}()
+		if prompt != "third" {
+			<-started
+		}
+	}
+
+	// The third call waits while the first two run
+	select {
+	case prompt := <-started:
+		t.Fatalf("Expected the call over the limit to wait, %q started", prompt)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// It starts once one of them completes
+	finish <- struct{}{}
+	select {
+	case prompt := <-started:
+		if prompt != "third" {
+			t.Errorf("Expected the waiting call to start, got %q", prompt)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the waiting call to start after a call completed")
+	}
+	if got := done.Load(); got != 1 {
+		t.Errorf("Expected 1 call completed, got %d", got)
+	}
+
+	finish <- struct{}{}
+	finish <- struct{}{}
+}
+
+func TestMaxConcurrentWaitHonorsContext(t *testing.T) {
+	client, finish, started := blockingGenerator(1)
+	defer close(finish)
+
+	go client.GenerateResponse(context.Background(), "running")
+	<-started
+
+	// A call waiting for a slot gives up when its context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := client.GenerateResponse(ctx, "waiting"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the wait to end with the context, got %v", err)
+	}
+	select {
+	case prompt := <-started:
+		t.Errorf("Expected the call to give up without generating, %q started", prompt)
+	default:
+	}
+
+	// Clients of the Ollama API are limited by default, generators are not
+	if api, err := New("", ""); err != nil || api.limiter == nil {
+		t.Errorf("Expected an Ollama API client to be limited by default")
+	}
+	if client := NewWithGenerator("model", nil, WithMaxConcurrent(0)); client.limiter != nil {
+		t.Errorf("Expected no limit below one")
+	}
+}
@@ -0,0 +1,101 @@
+package ollama
+
+import (
+	"maps"
+	"time"
+)
+
+// Calls the client makes, named as in PromptHashes, for per-call overrides
+// with WithCallOptions
+const (
+	CallSynopsis    = "synopsis"
+	CallClean       = "clean"
+	CallCleanHTML   = "clean_html"
+	CallEditorial   = "editorial"
+	CallTags        = "tags"
+	CallReferences  = "references"
+	CallAIDetection = "ai_detection"
+	CallQuality     = "quality"
+	CallImage       = "image"
+	CallTranslate   = "translate"
+)
+
+// Option configures a Client
+type Option func(*Client)
+
+// CallOptions override the client's timeout and model options for one call
+type CallOptions struct {
+	Timeout time.Duration  // Replaces the client's timeout when positive
+	Options map[string]any // Merged over the client's model options
+}
+
+// WithTimeout bounds each call to the model, retries included. A timeout
+// that is not positive keeps DefaultTimeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		if timeout > 0 {
+			c.timeout = timeout
+		}
+	}
+}
+
+// WithKeepAlive sets how long Ollama keeps the model loaded after a call,
+// instead of the server's default
+func WithKeepAlive(keepAlive time.Duration) Option {
+	return func(c *Client) {
+		c.keepAlive = &keepAlive
+	}
+}
+
+// WithOptions sets the model options sent with every call, such as
+// temperature or num_ctx. See the Ollama documentation for the options.
+func WithOptions(options map[string]any) Option {
+	return func(c *Client) {
+		c.options = maps.Clone(options)
+	}
+}
+
+// WithCallOptions overrides the timeout and model options of one call,
+// named by one of the Call constants, e.g. for deterministic tags or a
+// shorter timeout on quality scoring
+func WithCallOptions(call string, options CallOptions) Option {
+	return func(c *Client) {
+		if c.calls == nil {
+			c.calls = map[string]CallOptions{}
+		}
+		options.Options = maps.Clone(options.Options)
+		c.calls[call] = options
+	}
+}
+
+// callSettings returns the timeout and model options of call, the client's
+// own overridden by those set for the call
+func (c *Client) callSettings(call string) (time.Duration, map[string]any) {
+	timeout, options := c.timeout, c.options
+	override, ok := c.calls[call]
+	if !ok {
+		return timeout, options
+	}
+	if override.Timeout > 0 {
+		timeout = override.Timeout
+	}
+	if len(override.Options) > 0 {
+		merged := maps.Clone(options)
+		if merged == nil {
+			merged = make(map[string]any, len(override.Options))
+		}
+		maps.Copy(merged, override.Options)
+		options = merged
+	}
+	return timeout, options
+}
+
+// longestTimeout returns the longest timeout of any call, which bounds the
+// HTTP client
+func (c *Client) longestTimeout() time.Duration {
+	longest := c.timeout
+	for _, override := range c.calls {
+		longest = max(longest, override.Timeout)
+	}
+	return longest
+}
@@ -0,0 +1,126 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ollama/ollama/api"
+)
+
+// newRecordingOllama starts a server that records the generate requests it
+// receives, answering quality prompts only once the request is canceled
+func newRecordingOllama(t *testing.T, opts ...Option) (*Client, func() []api.GenerateRequest) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var requests []api.GenerateRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req api.GenerateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		requests = append(requests, req)
+		mu.Unlock()
+
+		if strings.Contains(req.Prompt, "quality_indicators") {
+			<-r.Context().Done()
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"model":    req.Model,
+			"response": `{"tags": ["transit"]}`,
+			"done":     true,
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := New(server.URL, "test-model", opts...)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	client.SetRetryPolicy(1, 0)
+	return client, func() []api.GenerateRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]api.GenerateRequest(nil), requests...)
+	}
+}
+
+func TestGenerationOptions(t *testing.T) {
+	modelOptions := map[string]any{"temperature": 0.7, "num_ctx": 8192}
+	client, requests := newRecordingOllama(t,
+		WithTimeout(2*time.Minute),
+		WithKeepAlive(10*time.Minute),
+		WithOptions(modelOptions),
+		WithCallOptions(CallTags, CallOptions{Options: map[string]any{"temperature": 0}}),
+		WithCallOptions(CallQuality, CallOptions{Timeout: 100 * time.Millisecond}),
+	)
+	// The client keeps its own copy of the options
+	modelOptions["temperature"] = 1.5
+	ctx := context.Background()
+
+	if _, err := client.CleanText(ctx, "Some text."); err != nil {
+		t.Fatalf("CleanText failed: %v", err)
+	}
+	if _, err := client.GenerateTags(ctx, "Some text.", nil); err != nil {
+		t.Fatalf("GenerateTags failed: %v", err)
+	}
+
+	sent := requests()
+	if len(sent) != 2 {
+		t.Fatalf("Expected 2 requests, got %d", len(sent))
+	}
+	for i, expected := range []float64{0.7, 0} {
+		req := sent[i]
+		if req.Options["temperature"] != expected || req.Options["num_ctx"] != float64(8192) {
+			t.Errorf("Request %d: expected temperature %v and num_ctx 8192, got %v", i, expected, req.Options)
+		}
+		if req.KeepAlive == nil || req.KeepAlive.Duration != 10*time.Minute {
+			t.Errorf("Request %d: expected keep_alive 10m, got %v", i, req.KeepAlive)
+		}
+	}
+
+	// Quality scoring has its own timeout; the others keep the client's
+	start := time.Now()
+	if _, err := client.ScoreTextQuality(ctx, "Some text."); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected quality scoring to time out, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected quality scoring to give up after its timeout, took %v", elapsed)
+	}
+	if timeout, _ := client.callSettings(CallSynopsis); timeout != 2*time.Minute {
+		t.Errorf("Expected the client timeout for synopses, got %v", timeout)
+	}
+}
+
+func TestGenerationOptionsDefaults(t *testing.T) {
+	client, requests := newRecordingOllama(t)
+	if _, err := client.GenerateResponse(context.Background(), "prompt"); err != nil {
+		t.Fatalf("GenerateResponse failed: %v", err)
+	}
+
+	req := requests()[0]
+	if len(req.Options) != 0 || req.KeepAlive != nil {
+		t.Errorf("Expected no options or keep_alive by default, got %v and %v", req.Options, req.KeepAlive)
+	}
+	if timeout, options := client.callSettings(CallTags); timeout != DefaultTimeout || options != nil {
+		t.Errorf("Expected the default timeout and no options, got %v and %v", timeout, options)
+	}
+
+	// A timeout that is not positive keeps the default
+	generator := NewWithGenerator("model", func(ctx context.Context, prompt string) (string, error) {
+		return "", nil
+	}, WithTimeout(0))
+	if generator.timeout != DefaultTimeout {
+		t.Errorf("Expected timeout %v, got %v", DefaultTimeout, generator.timeout)
+	}
+}
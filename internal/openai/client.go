@@ -0,0 +1,136 @@
+// Package openai generates text with OpenAI-compatible chat completion APIs,
+// such as those served by vLLM, LM Studio and the llama.cpp server.
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/docutag/textanalyzer/internal/ollama"
+	"github.com/docutag/textanalyzer/internal/textutil"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// DefaultURL is the base URL of the OpenAI API
+const DefaultURL = "https://api.openai.com/v1"
+
+// maxResponseBytes caps the size of a chat completion response read
+const maxResponseBytes = 16 << 20
+
+// maxErrorPreview is the most runes of an error response quoted in errors
+const maxErrorPreview = 200
+
+// Client sends the analyzer's prompts to the /chat/completions endpoint of
+// an OpenAI-compatible API. The prompts and the parsing of responses are
+// those of the embedded Ollama client; only the transport differs.
+type Client struct {
+	*ollama.Client
+	endpoint   string
+	apiKey     string
+	httpClient *http.Client
+}
+
+// chatMessage is a message of a chat completion request or response
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatRequest struct {
+	Model    string        `json:"model"`
+	Messages []chatMessage `json:"messages"`
+	Stream   bool          `json:"stream"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+}
+
+// New creates a client for the API at baseURL, e.g. http://localhost:8000/v1
+// for a local vLLM server; an empty baseURL uses DefaultURL. apiKey is sent
+// as a bearer token when set, as local servers usually need none.
+func New(baseURL, model, apiKey string) (*Client, error) {
+	if baseURL == "" {
+		baseURL = DefaultURL
+	}
+	if model == "" {
+		return nil, fmt.Errorf("model is required")
+	}
+	parsed, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OpenAI URL: %w", err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return nil, fmt.Errorf("invalid OpenAI URL %q: scheme must be http or https", baseURL)
+	}
+
+	c := &Client{
+		endpoint: strings.TrimSuffix(baseURL, "/") + "/chat/completions",
+		apiKey:   apiKey,
+		// Instrumented like the Ollama client so model calls appear in traces
+		httpClient: &http.Client{
+			Timeout: ollama.DefaultTimeout,
+			Transport: otelhttp.NewTransport(http.DefaultTransport,
+				otelhttp.WithSpanNameFormatter(func(operation string, r *http.Request) string {
+					return "openai " + r.Method + " " + r.URL.Path
+				}),
+			),
+		},
+	}
+	c.Client = ollama.NewWithGenerator(model, c.chat)
+	return c, nil
+}
+
+// chat sends prompt as a single user message and returns the reply
+func (c *Client) chat(ctx context.Context, prompt string) (string, error) {
+	body, err := json.Marshal(chatRequest{
+		Model:    c.Model(),
+		Messages: []chatMessage{{Role: "user", Content: prompt}},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode chat request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create chat request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("chat completion request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return "", fmt.Errorf("failed to read chat completion: %w", err)
+	}
+	// The status text, e.g. "503 Service Unavailable", lets callers tell
+	// retriable failures apart
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("chat completion failed: %s: %s", resp.Status,
+			textutil.Preview(strings.TrimSpace(string(data)), maxErrorPreview))
+	}
+
+	var completion chatResponse
+	if err := json.Unmarshal(data, &completion); err != nil {
+		return "", fmt.Errorf("failed to parse chat completion: %w", err)
+	}
+	if len(completion.Choices) == 0 {
+		return "", fmt.Errorf("chat completion has no choices")
+	}
+	return completion.Choices[0].Message.Content, nil
+}
@@ -0,0 +1,149 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/docutag/textanalyzer/internal/analyzer"
+)
+
+var _ analyzer.LLMClient = (*Client)(nil)
+
+// newChatServer serves /v1/chat/completions, replying with reply and
+// recording the last request and its authorization header
+func newChatServer(t *testing.T, status int, reply string) (*httptest.Server, *chatRequest, *string) {
+	t.Helper()
+
+	var last chatRequest
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		auth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&last)
+
+		if status != http.StatusOK {
+			http.Error(w, `{"error": {"message": "model is loading"}}`, status)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{
+				{"message": map[string]string{"role": "assistant", "content": reply}},
+			},
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server, &last, &auth
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name        string
+		baseURL     string
+		model       string
+		expectError bool
+	}{
+		{"default URL", "", "gpt-4o-mini", false},
+		{"local server", "http://localhost:8000/v1/", "qwen2.5", false},
+		{"missing model", "http://localhost:8000/v1", "", true},
+		{"invalid URL", "://invalid-url", "qwen2.5", true},
+		{"unsupported scheme", "ftp://localhost/v1", "qwen2.5", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := New(tt.baseURL, tt.model, "")
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if client.Model() != tt.model {
+				t.Errorf("Expected model %q, got %q", tt.model, client.Model())
+			}
+			if !strings.HasSuffix(client.endpoint, "/v1/chat/completions") || strings.Contains(client.endpoint, "//chat") {
+				t.Errorf("Unexpected endpoint %q", client.endpoint)
+			}
+		})
+	}
+}
+
+func TestChatCompletion(t *testing.T) {
+	server, last, auth := newChatServer(t, http.StatusOK, "  The council adds bus routes.\n")
+	client, err := New(server.URL+"/v1", "qwen2.5", "secret")
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	synopsis, err := client.GenerateSynopsis(context.Background(), "The council approved twelve bus routes.", "teaser", 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if synopsis != "The council adds bus routes." {
+		t.Errorf("Expected the trimmed reply, got %q", synopsis)
+	}
+	if *auth != "Bearer secret" {
+		t.Errorf("Expected a bearer token, got %q", *auth)
+	}
+	if last.Model != "qwen2.5" || last.Stream || len(last.Messages) != 1 || last.Messages[0].Role != "user" {
+		t.Errorf("Unexpected chat request %+v", *last)
+	}
+	if !strings.Contains(last.Messages[0].Content, "The council approved twelve bus routes.") {
+		t.Errorf("Expected the prompt to contain the text, got %q", last.Messages[0].Content)
+	}
+}
+
+func TestChatCompletionParsesStructuredReplies(t *testing.T) {
+	server, _, auth := newChatServer(t, http.StatusOK, "Tags:\n```json\n[\"Transit\", \"city council\"]\n```")
+	client, err := New(server.URL+"/v1", "qwen2.5", "")
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	tags, err := client.GenerateTags(context.Background(), "The council approved twelve bus routes.", nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if want := []string{"transit", "city-council"}; !reflect.DeepEqual(tags, want) {
+		t.Errorf("Expected tags %v, got %v", want, tags)
+	}
+	if *auth != "" {
+		t.Errorf("Expected no authorization without an API key, got %q", *auth)
+	}
+}
+
+func TestChatCompletionErrors(t *testing.T) {
+	server, _, _ := newChatServer(t, http.StatusServiceUnavailable, "")
+	client, err := New(server.URL+"/v1", "qwen2.5", "")
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	_, err = client.CleanText(context.Background(), "text")
+	if err == nil {
+		t.Fatal("Expected an error for an unavailable server")
+	}
+	if !strings.Contains(strings.ToLower(err.Error()), "service unavailable") {
+		t.Errorf("Expected the status in the error, got %v", err)
+	}
+
+	empty, _, _ := newChatServer(t, http.StatusOK, "")
+	client, err = New(empty.URL+"/v1/missing", "qwen2.5", "")
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	if _, err := client.EditorialAnalysis(context.Background(), "text"); err == nil {
+		t.Error("Expected an error for a missing endpoint")
+	}
+}
@@ -0,0 +1,23 @@
+// Package promutil registers Prometheus collectors that several handlers or
+// workers in one process may create, so each metric is registered once.
+package promutil
+
+import (
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Register registers c, returning the existing collector when an identical
+// one is already registered
+func Register[C prometheus.Collector](registerer prometheus.Registerer, c C) C {
+	if err := registerer.Register(c); err != nil {
+		var existing prometheus.AlreadyRegisteredError
+		if errors.As(err, &existing) {
+			if collector, ok := existing.ExistingCollector.(C); ok {
+				return collector
+			}
+		}
+	}
+	return c
+}
@@ -0,0 +1,18 @@
+package promutil
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestRegister(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	opts := prometheus.CounterOpts{Name: "test_total", Help: "Test counter"}
+
+	first := Register(registry, prometheus.NewCounter(opts))
+	second := Register(registry, prometheus.NewCounter(opts))
+	if first != second {
+		t.Error("Expected the already registered counter to be reused")
+	}
+}
@@ -7,8 +7,10 @@ import (
 	"time"
 
 	"github.com/hibiken/asynq"
+	"github.com/docutag/textanalyzer/internal/models"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/errgroup"
 )
 
 // Task type constants
@@ -18,12 +20,73 @@ const (
 	TypeEnrichImage     = "textanalyzer:enrich_image"
 )
 
+// Task timeouts. maxTaskTimeout bounds how long any task can run.
+const (
+	processDocumentTimeout = 5 * time.Minute
+	enrichTextTimeout      = 10 * time.Minute
+	enrichImageTimeout     = 15 * time.Minute
+	maxTaskTimeout         = enrichImageTimeout
+)
+
+// Base queue names for each processing stage. Normal-priority tasks use the
+// base name; high and low priority tasks use a suffixed queue with its own
+// weight in the worker's queue map.
+const (
+	queueTextEnrichment    = "text-enrichment"
+	queueOfflineProcessing = "offline-processing"
+	queueImageEnrichment   = "image-enrichment"
+)
+
+// Processing stages, named by their normal-priority queue. Back-pressure
+// checks read the depth of a stage across all of its priorities.
+const (
+	StageTextEnrichment    = queueTextEnrichment
+	StageOfflineProcessing = queueOfflineProcessing
+	StageImageEnrichment   = queueImageEnrichment
+)
+
+// Stages lists the processing stages in pipeline order
+var Stages = []string{StageOfflineProcessing, StageTextEnrichment, StageImageEnrichment}
+
+// Processing priorities accepted on analysis requests
+const (
+	PriorityHigh   = "high"
+	PriorityNormal = "normal"
+	PriorityLow    = "low"
+)
+
+// ParsePriority validates a requested priority, defaulting to normal when empty
+func ParsePriority(priority string) (string, error) {
+	switch priority {
+	case "":
+		return PriorityNormal, nil
+	case PriorityHigh, PriorityNormal, PriorityLow:
+		return priority, nil
+	}
+	return "", fmt.Errorf("priority must be one of %q, %q or %q", PriorityHigh, PriorityNormal, PriorityLow)
+}
+
+// queueName returns the queue for a processing stage at the given priority.
+// Unknown or empty priorities use the normal queue.
+func queueName(base, priority string) string {
+	switch priority {
+	case PriorityHigh:
+		return base + "-high"
+	case PriorityLow:
+		return base + "-low"
+	}
+	return base
+}
+
 // ProcessDocumentPayload represents the payload for offline document processing
 type ProcessDocumentPayload struct {
-	AnalysisID   string   `json:"analysis_id"`
-	Text         string   `json:"text"`
-	OriginalHTML string   `json:"original_html,omitempty"` // Compressed + base64 encoded original HTML/raw text
-	Images       []string `json:"images,omitempty"`
+	PayloadVersion int      `json:"payload_version"` // See PayloadVersion
+	AnalysisID     string   `json:"analysis_id"`
+	Text           string   `json:"text"`
+	OriginalHTML   string   `json:"original_html,omitempty"` // Compressed + base64 encoded original HTML/raw text
+	Images         []string `json:"images,omitempty"`
+	// Per-request processing options
+	Options models.ProcessingOptions `json:"options"`
 	// Tracing and timing fields
 	TraceID    string `json:"trace_id,omitempty"`
 	SpanID     string `json:"span_id,omitempty"`
@@ -32,10 +95,11 @@ type ProcessDocumentPayload struct {
 
 // EnrichTextPayload represents the payload for AI text enrichment
 type EnrichTextPayload struct {
-	AnalysisID   string `json:"analysis_id"`
-	Text         string `json:"text"`
-	OfflineText  string `json:"offline_text,omitempty"`  // Offline analysis text to use as template
-	OriginalHTML string `json:"original_html,omitempty"` // Compressed + base64 encoded original HTML/raw text
+	PayloadVersion int    `json:"payload_version"` // See PayloadVersion
+	AnalysisID     string `json:"analysis_id"`
+	Text           string `json:"text"`
+	OfflineText    string `json:"offline_text,omitempty"`  // Offline analysis text to use as template
+	OriginalHTML   string `json:"original_html,omitempty"` // Compressed + base64 encoded original HTML/raw text
 	// Tracing and timing fields
 	TraceID    string `json:"trace_id,omitempty"`
 	SpanID     string `json:"span_id,omitempty"`
@@ -44,17 +108,25 @@ type EnrichTextPayload struct {
 
 // EnrichImagePayload represents the payload for AI image enrichment
 type EnrichImagePayload struct {
-	AnalysisID string `json:"analysis_id"`
-	ImageURL   string `json:"image_url"`
+	PayloadVersion int    `json:"payload_version"` // See PayloadVersion
+	AnalysisID     string `json:"analysis_id"`
+	ImageURL       string `json:"image_url"`
+	ImageIndex     int    `json:"image_index"`
 	// Tracing and timing fields
 	TraceID    string `json:"trace_id,omitempty"`
 	SpanID     string `json:"span_id,omitempty"`
 	EnqueuedAt int64  `json:"enqueued_at"` // Unix timestamp in nanoseconds
 }
 
+// taskEnqueuer is the subset of asynq.Client used by Client
+type taskEnqueuer interface {
+	Enqueue(task *asynq.Task, opts ...asynq.Option) (*asynq.TaskInfo, error)
+	Close() error
+}
+
 // Client wraps the Asynq client for enqueueing tasks
 type Client struct {
-	client *asynq.Client
+	client taskEnqueuer
 }
 
 // ClientConfig contains configuration for the queue client
@@ -75,14 +147,75 @@ func NewClient(cfg ClientConfig) *Client {
 	}
 }
 
+// textTaskRetention returns how long a completed task carrying document text
+// is kept: 7 days, or not at all when the text must not be stored
+func textTaskRetention(redactText bool) time.Duration {
+	if redactText {
+		return 0
+	}
+	return 7 * 24 * time.Hour
+}
+
+// ProcessDocumentRequest is one document enqueued by EnqueueProcessDocumentBatch
+type ProcessDocumentRequest struct {
+	AnalysisID   string
+	Text         string
+	OriginalHTML string
+	Images       []string
+	Options      models.ProcessingOptions
+}
+
+// EnqueueResult is the outcome of enqueueing one document of a batch
+type EnqueueResult struct {
+	TaskID string
+	Err    error
+}
+
+// batchEnqueueConcurrency is the most documents of a batch enqueued at once
+const batchEnqueueConcurrency = 8
+
 // EnqueueProcessDocument enqueues an offline document processing task
-func (c *Client) EnqueueProcessDocument(ctx context.Context, analysisID, text, originalHTML string, images []string) (string, error) {
-	payload := ProcessDocumentPayload{
+func (c *Client) EnqueueProcessDocument(ctx context.Context, analysisID, text, originalHTML string, images []string, options models.ProcessingOptions) (string, error) {
+	return c.enqueueProcessDocument(ctx, ProcessDocumentRequest{
 		AnalysisID:   analysisID,
 		Text:         text,
 		OriginalHTML: originalHTML,
 		Images:       images,
-		EnqueuedAt:   time.Now().UnixNano(), // Record enqueue time for queue wait metrics
+		Options:      options,
+	})
+}
+
+// EnqueueProcessDocumentBatch enqueues an offline document processing task
+// for each document, returning their outcomes in the same order. A failed
+// document does not stop the others. Asynq enqueues each task with its own
+// Redis script, so the documents are enqueued concurrently to keep a large
+// batch from costing one round trip after another.
+func (c *Client) EnqueueProcessDocumentBatch(ctx context.Context, docs []ProcessDocumentRequest) []EnqueueResult {
+	results := make([]EnqueueResult, len(docs))
+	var group errgroup.Group
+	group.SetLimit(batchEnqueueConcurrency)
+	for i, doc := range docs {
+		group.Go(func() error {
+			taskID, err := c.enqueueProcessDocument(ctx, doc)
+			results[i] = EnqueueResult{TaskID: taskID, Err: err}
+			return nil
+		})
+	}
+	group.Wait()
+	return results
+}
+
+// enqueueProcessDocument enqueues the offline processing task of one document
+func (c *Client) enqueueProcessDocument(ctx context.Context, doc ProcessDocumentRequest) (string, error) {
+	analysisID, options := doc.AnalysisID, doc.Options
+	payload := ProcessDocumentPayload{
+		PayloadVersion: PayloadVersion,
+		AnalysisID:     analysisID,
+		Text:           doc.Text,
+		OriginalHTML:   doc.OriginalHTML,
+		Images:         doc.Images,
+		Options:        options,
+		EnqueuedAt:     time.Now().UnixNano(), // Record enqueue time for queue wait metrics
 	}
 
 	// Add tracing context if available
@@ -94,7 +227,7 @@ func (c *Client) EnqueueProcessDocument(ctx context.Context, analysisID, text, o
 		// Record enqueue event
 		span.AddEvent("task_enqueued", trace.WithAttributes(
 			attribute.String("task.type", TypeProcessDocument),
-			attribute.String("task.id", analysisID),
+			attribute.String("task.id", ProcessDocumentTaskID(analysisID)),
 			attribute.String("analysis_id", analysisID),
 			attribute.Int64("enqueued_at", payload.EnqueuedAt),
 		))
@@ -105,13 +238,14 @@ func (c *Client) EnqueueProcessDocument(ctx context.Context, analysisID, text, o
 		return "", fmt.Errorf("failed to marshal task payload: %w", err)
 	}
 
-	task := asynq.NewTask(TypeProcessDocument, payloadBytes, asynq.TaskID(analysisID))
+	task := asynq.NewTask(TypeProcessDocument, payloadBytes, asynq.TaskID(ProcessDocumentTaskID(analysisID)))
+	queue := queueName(queueOfflineProcessing, options.Priority)
 
 	opts := []asynq.Option{
-		asynq.MaxRetry(3),                   // Standard retry for offline processing
-		asynq.Timeout(5 * time.Minute),      // 5 minute timeout
-		asynq.Queue("offline-processing"),   // Offline processing queue (medium priority)
-		asynq.Retention(7 * 24 * time.Hour), // Keep completed tasks for 7 days
+		asynq.MaxRetry(3),                     // Standard retry for offline processing
+		asynq.Timeout(processDocumentTimeout), // 5 minute timeout
+		asynq.Queue(queue),                    // Offline processing queue (medium priority)
+		asynq.Retention(textTaskRetention(options.RedactText)),
 	}
 
 	info, err := c.client.Enqueue(task, opts...)
@@ -122,14 +256,16 @@ func (c *Client) EnqueueProcessDocument(ctx context.Context, analysisID, text, o
 	return info.ID, nil
 }
 
-// EnqueueEnrichText enqueues a high-priority AI text enrichment task
-func (c *Client) EnqueueEnrichText(ctx context.Context, analysisID, text, offlineText, originalHTML string) (string, error) {
+// EnqueueEnrichText enqueues a high-priority AI text enrichment task on the
+// text enrichment queue for the document's processing priority
+func (c *Client) EnqueueEnrichText(ctx context.Context, analysisID, text, offlineText, originalHTML, priority string, redactText bool) (string, error) {
 	payload := EnrichTextPayload{
-		AnalysisID:   analysisID,
-		Text:         text,
-		OfflineText:  offlineText,
-		OriginalHTML: originalHTML,
-		EnqueuedAt:   time.Now().UnixNano(),
+		PayloadVersion: PayloadVersion,
+		AnalysisID:     analysisID,
+		Text:           text,
+		OfflineText:    offlineText,
+		OriginalHTML:   originalHTML,
+		EnqueuedAt:     time.Now().UnixNano(),
 	}
 
 	// Add tracing context if available
@@ -141,7 +277,7 @@ func (c *Client) EnqueueEnrichText(ctx context.Context, analysisID, text, offlin
 		// Record enqueue event
 		span.AddEvent("task_enqueued", trace.WithAttributes(
 			attribute.String("task.type", TypeEnrichText),
-			attribute.String("task.id", analysisID+"-text-enrich"),
+			attribute.String("task.id", TextEnrichTaskID(analysisID)),
 			attribute.String("analysis_id", analysisID),
 			attribute.Int64("enqueued_at", payload.EnqueuedAt),
 		))
@@ -152,14 +288,15 @@ func (c *Client) EnqueueEnrichText(ctx context.Context, analysisID, text, offlin
 		return "", fmt.Errorf("failed to marshal task payload: %w", err)
 	}
 
-	taskID := analysisID + "-text-enrich"
+	taskID := TextEnrichTaskID(analysisID)
 	task := asynq.NewTask(TypeEnrichText, payloadBytes, asynq.TaskID(taskID))
+	queue := queueName(queueTextEnrichment, priority)
 
 	opts := []asynq.Option{
 		asynq.MaxRetry(10),                    // High retry tolerance for Ollama
-		asynq.Timeout(10 * time.Minute),       // 10 minute timeout for AI processing
-		asynq.Queue("text-enrichment"),        // Text enrichment queue (highest priority)
-		asynq.Retention(7 * 24 * time.Hour),   // Keep completed tasks for 7 days
+		asynq.Timeout(enrichTextTimeout),      // 10 minute timeout for AI processing
+		asynq.Queue(queue),                    // Text enrichment queue (highest priority)
+		asynq.Retention(textTaskRetention(redactText)),
 	}
 
 	info, err := c.client.Enqueue(task, opts...)
@@ -170,12 +307,15 @@ func (c *Client) EnqueueEnrichText(ctx context.Context, analysisID, text, offlin
 	return info.ID, nil
 }
 
-// EnqueueEnrichImage enqueues a low-priority AI image enrichment task
-func (c *Client) EnqueueEnrichImage(ctx context.Context, analysisID, imageURL string, imageIndex int) (string, error) {
+// EnqueueEnrichImage enqueues a low-priority AI image enrichment task on the
+// image enrichment queue for the document's processing priority
+func (c *Client) EnqueueEnrichImage(ctx context.Context, analysisID, imageURL string, imageIndex int, priority string) (string, error) {
 	payload := EnrichImagePayload{
-		AnalysisID: analysisID,
-		ImageURL:   imageURL,
-		EnqueuedAt: time.Now().UnixNano(),
+		PayloadVersion: PayloadVersion,
+		AnalysisID:     analysisID,
+		ImageURL:       imageURL,
+		ImageIndex:     imageIndex,
+		EnqueuedAt:     time.Now().UnixNano(),
 	}
 
 	// Add tracing context if available
@@ -187,7 +327,7 @@ func (c *Client) EnqueueEnrichImage(ctx context.Context, analysisID, imageURL st
 		// Record enqueue event
 		span.AddEvent("task_enqueued", trace.WithAttributes(
 			attribute.String("task.type", TypeEnrichImage),
-			attribute.String("task.id", fmt.Sprintf("%s-image-enrich-%d", analysisID, imageIndex)),
+			attribute.String("task.id", ImageEnrichTaskID(analysisID, imageIndex)),
 			attribute.String("analysis_id", analysisID),
 			attribute.String("image_url", imageURL),
 			attribute.Int("image_index", imageIndex),
@@ -200,13 +340,14 @@ func (c *Client) EnqueueEnrichImage(ctx context.Context, analysisID, imageURL st
 		return "", fmt.Errorf("failed to marshal task payload: %w", err)
 	}
 
-	taskID := fmt.Sprintf("%s-image-enrich-%d", analysisID, imageIndex)
+	taskID := ImageEnrichTaskID(analysisID, imageIndex)
 	task := asynq.NewTask(TypeEnrichImage, payloadBytes, asynq.TaskID(taskID))
+	queue := queueName(queueImageEnrichment, priority)
 
 	opts := []asynq.Option{
 		asynq.MaxRetry(10),                    // High retry tolerance for Ollama
-		asynq.Timeout(15 * time.Minute),       // 15 minute timeout for image AI processing
-		asynq.Queue("image-enrichment"),       // Image enrichment queue (lowest priority)
+		asynq.Timeout(enrichImageTimeout),     // 15 minute timeout for image AI processing
+		asynq.Queue(queue),                    // Image enrichment queue (lowest priority)
 		asynq.Retention(7 * 24 * time.Hour),   // Keep completed tasks for 7 days
 	}
 
@@ -0,0 +1,139 @@
+package queue
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultDepthPollInterval is how often queue depths are read from Redis
+const DefaultDepthPollInterval = 5 * time.Second
+
+// DepthMonitor polls the pending task count of every processing stage and
+// caches it, so request handlers can check queue depth without a Redis round
+// trip. The counts are also exported as a gauge.
+type DepthMonitor struct {
+	inspector *Inspector
+	interval  time.Duration
+	logger    *slog.Logger
+	gauge     *prometheus.GaugeVec
+
+	// newTicker is replaceable for tests
+	newTicker func(d time.Duration) (<-chan time.Time, func())
+
+	depthMu sync.RWMutex
+	pending map[string]int // nil until the first successful poll
+
+	mu      sync.Mutex
+	stop    chan struct{}
+	done    chan struct{}
+	running bool
+}
+
+// NewDepthMonitor creates a monitor that polls inspector every interval and
+// registers its gauge with registerer
+func NewDepthMonitor(inspector *Inspector, interval time.Duration, registerer prometheus.Registerer, logger *slog.Logger) *DepthMonitor {
+	if interval <= 0 {
+		interval = DefaultDepthPollInterval
+	}
+	return &DepthMonitor{
+		inspector: inspector,
+		interval:  interval,
+		logger:    logger,
+		gauge:     newPendingGauge(registerer, logger),
+		newTicker: func(d time.Duration) (<-chan time.Time, func()) {
+			ticker := time.NewTicker(d)
+			return ticker.C, ticker.Stop
+		},
+	}
+}
+
+// newPendingGauge registers the pending task gauge, reusing one already
+// registered by an earlier monitor
+func newPendingGauge(registerer prometheus.Registerer, logger *slog.Logger) *prometheus.GaugeVec {
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "textanalyzer_queue_pending_tasks",
+		Help: "Tasks waiting in each processing stage across all priorities",
+	}, []string{"stage"})
+
+	if err := registerer.Register(gauge); err != nil {
+		if existing, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			return existing.ExistingCollector.(*prometheus.GaugeVec)
+		}
+		logger.Warn("failed to register pending task gauge", "error", err)
+	}
+	return gauge
+}
+
+// Start polls the queues once and then every interval until Stop is called
+func (m *DepthMonitor) Start() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.running {
+		return
+	}
+
+	m.running = true
+	m.stop = make(chan struct{})
+	m.done = make(chan struct{})
+
+	ticks, stopTicker := m.newTicker(m.interval)
+	m.poll()
+
+	go func() {
+		defer close(m.done)
+		defer stopTicker()
+		for {
+			select {
+			case <-m.stop:
+				return
+			case <-ticks:
+				m.poll()
+			}
+		}
+	}()
+}
+
+// Stop halts polling and waits for the polling goroutine to exit
+func (m *DepthMonitor) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.running {
+		return
+	}
+
+	close(m.stop)
+	<-m.done
+	m.running = false
+}
+
+// poll reads the pending counts, keeping the previous counts when Redis
+// cannot be reached
+func (m *DepthMonitor) poll() {
+	pending, err := m.inspector.PendingTasks()
+	if err != nil {
+		m.logger.Warn("failed to read queue depths", "error", err)
+		return
+	}
+
+	m.depthMu.Lock()
+	m.pending = pending
+	m.depthMu.Unlock()
+
+	for stage, count := range pending {
+		m.gauge.WithLabelValues(stage).Set(float64(count))
+	}
+}
+
+// Pending returns the cached number of tasks waiting in a processing stage.
+// It reports false until the queues have been read successfully.
+func (m *DepthMonitor) Pending(stage string) (int, bool) {
+	m.depthMu.RLock()
+	defer m.depthMu.RUnlock()
+	if m.pending == nil {
+		return 0, false
+	}
+	return m.pending[stage], true
+}
@@ -0,0 +1,70 @@
+package queue
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInspectorPendingTasks(t *testing.T) {
+	fake := newFakeInspector()
+	fake.queues = map[string]*asynq.QueueInfo{
+		"text-enrichment-high":   {Queue: "text-enrichment-high", Pending: 2},
+		"text-enrichment":        {Queue: "text-enrichment", Pending: 5},
+		"text-enrichment-low":    {Queue: "text-enrichment-low", Pending: 40},
+		"offline-processing":     {Queue: "offline-processing", Pending: 1, Active: 3},
+		"image-enrichment-other": {Queue: "image-enrichment-other", Pending: 9},
+	}
+	inspector := &Inspector{inspector: fake}
+
+	pending, err := inspector.PendingTasks()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{
+		StageOfflineProcessing: 1,
+		StageTextEnrichment:    47,
+		StageImageEnrichment:   0,
+	}, pending)
+
+	fake.err = errors.New("redis: connection refused")
+	_, err = inspector.PendingTasks()
+	assert.ErrorContains(t, err, "connection refused")
+}
+
+func TestDepthMonitor(t *testing.T) {
+	fake := newFakeInspector()
+	fake.queues = map[string]*asynq.QueueInfo{
+		"text-enrichment": {Queue: "text-enrichment", Pending: 5},
+	}
+	registry := prometheus.NewRegistry()
+	monitor := NewDepthMonitor(&Inspector{inspector: fake}, time.Minute, registry, slog.Default())
+	ticks := make(chan time.Time)
+	monitor.newTicker = func(d time.Duration) (<-chan time.Time, func()) {
+		return ticks, func() {}
+	}
+
+	_, ok := monitor.Pending(StageTextEnrichment)
+	assert.False(t, ok, "depth should be unknown before the first poll")
+
+	monitor.Start()
+	defer monitor.Stop()
+
+	pending, ok := monitor.Pending(StageTextEnrichment)
+	assert.True(t, ok)
+	assert.Equal(t, 5, pending)
+	assert.Equal(t, 5.0, testutil.ToFloat64(monitor.gauge.WithLabelValues(StageTextEnrichment)))
+
+	// A failed poll keeps the last counts
+	fake.err = errors.New("redis: connection refused")
+	ticks <- time.Now()
+	ticks <- time.Now()
+	pending, ok = monitor.Pending(StageTextEnrichment)
+	assert.True(t, ok)
+	assert.Equal(t, 5, pending)
+}
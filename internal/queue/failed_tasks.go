@@ -0,0 +1,211 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hibiken/asynq"
+)
+
+// Errors returned when looking up archived tasks
+var (
+	ErrUnknownQueue    = errors.New("unknown queue")
+	ErrTaskNotFound    = errors.New("task not found")
+	ErrTaskNotArchived = errors.New("task is not archived")
+)
+
+// requeuePageSize is the number of archived tasks read at a time when every
+// archived task of a queue is requeued
+const requeuePageSize = 100
+
+// PayloadSummary describes the payload of a task without its text
+type PayloadSummary struct {
+	AnalysisID     string `json:"analysis_id,omitempty"`
+	PayloadVersion int    `json:"payload_version,omitempty"`
+	TextLength     int    `json:"text_length,omitempty"`
+	ImageCount     int    `json:"image_count,omitempty"`
+	ImageURL       string `json:"image_url,omitempty"`
+	ImageIndex     *int   `json:"image_index,omitempty"`
+	Error          string `json:"error,omitempty"` // Set when the payload cannot be decoded
+}
+
+// FailedTask is an archived task with a summary of its payload
+type FailedTask struct {
+	TaskStatus
+	Payload PayloadSummary `json:"payload"`
+}
+
+// ArchivedQueue holds the archived tasks of one queue
+type ArchivedQueue struct {
+	Queue    string       `json:"queue"`
+	Archived int          `json:"archived"` // Archived tasks in the queue, including those not listed
+	Tasks    []FailedTask `json:"tasks"`
+}
+
+// PipelineQueues returns every queue the pipeline enqueues tasks on, each
+// stage's priorities in turn
+func PipelineQueues() []string {
+	var queues []string
+	for _, stage := range Stages {
+		queues = append(queues, priorityQueues(stage)...)
+	}
+	return queues
+}
+
+// isPipelineQueue reports whether the pipeline enqueues tasks on queue
+func isPipelineQueue(queue string) bool {
+	for _, name := range PipelineQueues() {
+		if name == queue {
+			return true
+		}
+	}
+	return false
+}
+
+// ArchivedTasks lists up to limit archived tasks of a pipeline queue, or of
+// every pipeline queue when queue is empty. Queues that do not exist yet are
+// left out.
+func (i *Inspector) ArchivedTasks(queue string, limit int) ([]ArchivedQueue, error) {
+	queues := PipelineQueues()
+	if queue != "" {
+		if !isPipelineQueue(queue) {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownQueue, queue)
+		}
+		queues = []string{queue}
+	}
+
+	existing, err := i.inspector.Queues()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list queues: %w", err)
+	}
+	exists := make(map[string]bool, len(existing))
+	for _, name := range existing {
+		exists[name] = true
+	}
+
+	archived := []ArchivedQueue{}
+	for _, name := range queues {
+		if !exists[name] {
+			continue
+		}
+		info, err := i.inspector.GetQueueInfo(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to inspect queue %s: %w", name, err)
+		}
+		infos, err := i.inspector.ListArchivedTasks(name, asynq.PageSize(limit))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list archived tasks of %s: %w", name, err)
+		}
+
+		tasks := make([]FailedTask, 0, len(infos))
+		for _, info := range infos {
+			tasks = append(tasks, failedTask(info))
+		}
+		archived = append(archived, ArchivedQueue{Queue: name, Archived: info.Archived, Tasks: tasks})
+	}
+	return archived, nil
+}
+
+// RetryArchivedTask moves an archived task of a pipeline queue back to
+// pending, returning the task as it was before the move
+func (i *Inspector) RetryArchivedTask(queue, taskID string) (*FailedTask, error) {
+	if !isPipelineQueue(queue) {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownQueue, queue)
+	}
+
+	info, err := i.inspector.GetTaskInfo(queue, taskID)
+	if errors.Is(err, asynq.ErrTaskNotFound) || errors.Is(err, asynq.ErrQueueNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrTaskNotFound, taskID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect task %s: %w", taskID, err)
+	}
+	if info.State != asynq.TaskStateArchived {
+		return nil, fmt.Errorf("%w: %s is %s", ErrTaskNotArchived, taskID, info.State)
+	}
+
+	task := failedTask(info)
+	if err := i.inspector.RunTask(queue, taskID); err != nil {
+		return nil, fmt.Errorf("failed to requeue task %s: %w", taskID, err)
+	}
+	return &task, nil
+}
+
+// RetryAllArchivedTasks moves every archived task of a pipeline queue back
+// to pending, returning the tasks as they were before the move. Tasks are
+// read a page at a time, so tasks archived meanwhile may be requeued too.
+func (i *Inspector) RetryAllArchivedTasks(queue string) ([]FailedTask, error) {
+	if !isPipelineQueue(queue) {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownQueue, queue)
+	}
+
+	requeued := []FailedTask{}
+	for {
+		infos, err := i.inspector.ListArchivedTasks(queue, asynq.PageSize(requeuePageSize))
+		if errors.Is(err, asynq.ErrQueueNotFound) {
+			return requeued, nil
+		}
+		if err != nil {
+			return requeued, fmt.Errorf("failed to list archived tasks of %s: %w", queue, err)
+		}
+
+		// Requeued tasks leave the archived set, so the first page holds
+		// the next batch; stop once a batch requeues nothing
+		moved := 0
+		for _, info := range infos {
+			task := failedTask(info)
+			err := i.inspector.RunTask(queue, info.ID)
+			if errors.Is(err, asynq.ErrTaskNotFound) {
+				continue // Deleted or requeued since it was listed
+			}
+			if err != nil {
+				return requeued, fmt.Errorf("failed to requeue task %s: %w", info.ID, err)
+			}
+			requeued = append(requeued, task)
+			moved++
+		}
+		if moved == 0 {
+			return requeued, nil
+		}
+	}
+}
+
+// failedTask converts asynq task info into a FailedTask
+func failedTask(info *asynq.TaskInfo) FailedTask {
+	task := FamilyTask{ID: info.ID, Type: info.Type}
+	return FailedTask{
+		TaskStatus: taskStatus(task, info),
+		Payload:    summarizePayload(info.Type, info.Payload),
+	}
+}
+
+// summarizePayload describes a task payload of any supported version
+func summarizePayload(taskType string, data []byte) PayloadSummary {
+	var summary PayloadSummary
+	var err error
+	switch taskType {
+	case TypeProcessDocument:
+		var payload ProcessDocumentPayload
+		payload, summary.PayloadVersion, err = DecodeProcessDocumentPayload(data)
+		summary.AnalysisID = payload.AnalysisID
+		summary.TextLength = len(payload.Text)
+		summary.ImageCount = len(payload.Images)
+	case TypeEnrichText:
+		var payload EnrichTextPayload
+		payload, summary.PayloadVersion, err = DecodeEnrichTextPayload(data)
+		summary.AnalysisID = payload.AnalysisID
+		summary.TextLength = len(payload.Text)
+	case TypeEnrichImage:
+		var payload EnrichImagePayload
+		payload, summary.PayloadVersion, err = DecodeEnrichImagePayload(data)
+		summary.AnalysisID = payload.AnalysisID
+		summary.ImageURL = payload.ImageURL
+		summary.ImageIndex = &payload.ImageIndex
+	default:
+		err = fmt.Errorf("unknown task type %q", taskType)
+	}
+	if err != nil {
+		return PayloadSummary{PayloadVersion: summary.PayloadVersion, Error: err.Error()}
+	}
+	return summary
+}
@@ -0,0 +1,129 @@
+package queue
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// archivedTask returns an archived task of the given type and payload
+func archivedTask(t *testing.T, queue, id, taskType string, payload interface{}) *asynq.TaskInfo {
+	t.Helper()
+	data, err := json.Marshal(payload)
+	require.NoError(t, err)
+	return &asynq.TaskInfo{
+		ID: id, Queue: queue, Type: taskType, Payload: data, State: asynq.TaskStateArchived,
+		Retried: 10, MaxRetry: 10, LastErr: "ollama timeout", LastFailedAt: time.Now().Truncate(time.Second),
+	}
+}
+
+func TestPipelineQueues(t *testing.T) {
+	queues := PipelineQueues()
+	assert.Len(t, queues, 9)
+	assert.Contains(t, queues, "offline-processing-high")
+	assert.Contains(t, queues, "text-enrichment")
+	assert.Contains(t, queues, "image-enrichment-low")
+}
+
+func TestInspectorArchivedTasks(t *testing.T) {
+	fake := newFakeInspector(
+		archivedTask(t, "text-enrichment", "doc-1-text-enrich", TypeEnrichText,
+			EnrichTextPayload{PayloadVersion: PayloadVersion, AnalysisID: "doc-1", Text: "The council approved the plan."}),
+		archivedTask(t, "image-enrichment", "doc-1-image-enrich-1", TypeEnrichImage,
+			EnrichImagePayload{PayloadVersion: PayloadVersion, AnalysisID: "doc-1", ImageURL: "https://example.com/a.jpg", ImageIndex: 1}),
+		archivedTask(t, "offline-processing", "doc-2", TypeProcessDocument, map[string]int{"payload_version": 99}),
+		&asynq.TaskInfo{ID: "doc-3-text-enrich", Queue: "text-enrichment", Type: TypeEnrichText, State: asynq.TaskStatePending},
+	)
+	fake.queues = map[string]*asynq.QueueInfo{
+		"offline-processing": {Queue: "offline-processing", Archived: 1},
+		"text-enrichment":    {Queue: "text-enrichment", Archived: 1, Pending: 1},
+		"image-enrichment":   {Queue: "image-enrichment", Archived: 1},
+	}
+	inspector := &Inspector{inspector: fake}
+
+	archived, err := inspector.ArchivedTasks("", 50)
+	require.NoError(t, err)
+	require.Len(t, archived, 3, "queues that do not exist are left out")
+	assert.Equal(t, "offline-processing", archived[0].Queue)
+	assert.Equal(t, "text-enrichment", archived[1].Queue)
+
+	text := archived[1].Tasks[0]
+	assert.Equal(t, "doc-1-text-enrich", text.TaskID)
+	assert.Equal(t, "archived", text.State)
+	assert.Equal(t, 10, text.Retried)
+	assert.Equal(t, "ollama timeout", text.LastError)
+	assert.Equal(t, PayloadSummary{AnalysisID: "doc-1", PayloadVersion: PayloadVersion, TextLength: 30}, text.Payload)
+
+	image := archived[2].Tasks[0].Payload
+	assert.Equal(t, "https://example.com/a.jpg", image.ImageURL)
+	require.NotNil(t, image.ImageIndex)
+	assert.Equal(t, 1, *image.ImageIndex)
+
+	undecodable := archived[0].Tasks[0].Payload
+	assert.Equal(t, 99, undecodable.PayloadVersion)
+	assert.Contains(t, undecodable.Error, "unsupported payload version")
+
+	archived, err = inspector.ArchivedTasks("text-enrichment", 50)
+	require.NoError(t, err)
+	require.Len(t, archived, 1)
+	assert.Len(t, archived[0].Tasks, 1)
+
+	_, err = inspector.ArchivedTasks("default", 50)
+	assert.ErrorIs(t, err, ErrUnknownQueue)
+}
+
+func TestInspectorRetryArchivedTask(t *testing.T) {
+	fake := newFakeInspector(
+		archivedTask(t, "text-enrichment", "doc-1-text-enrich", TypeEnrichText,
+			EnrichTextPayload{PayloadVersion: PayloadVersion, AnalysisID: "doc-1"}),
+		&asynq.TaskInfo{ID: "doc-2-text-enrich", Queue: "text-enrichment", Type: TypeEnrichText, State: asynq.TaskStateRetry},
+	)
+	inspector := &Inspector{inspector: fake}
+
+	task, err := inspector.RetryArchivedTask("text-enrichment", "doc-1-text-enrich")
+	require.NoError(t, err)
+	assert.Equal(t, "archived", task.State)
+	assert.Equal(t, "doc-1", task.Payload.AnalysisID)
+	assert.Equal(t, asynq.TaskStatePending, fake.tasks["text-enrichment/doc-1-text-enrich"].State)
+
+	_, err = inspector.RetryArchivedTask("text-enrichment", "doc-1-text-enrich")
+	assert.ErrorIs(t, err, ErrTaskNotArchived)
+
+	_, err = inspector.RetryArchivedTask("text-enrichment", "doc-2-text-enrich")
+	assert.ErrorIs(t, err, ErrTaskNotArchived, "retrying tasks are left to their schedule")
+
+	_, err = inspector.RetryArchivedTask("text-enrichment", "doc-9-text-enrich")
+	assert.ErrorIs(t, err, ErrTaskNotFound)
+
+	_, err = inspector.RetryArchivedTask("default", "doc-1-text-enrich")
+	assert.ErrorIs(t, err, ErrUnknownQueue)
+}
+
+func TestInspectorRetryAllArchivedTasks(t *testing.T) {
+	fake := newFakeInspector(
+		archivedTask(t, "image-enrichment", "doc-1-image-enrich-0", TypeEnrichImage, EnrichImagePayload{PayloadVersion: PayloadVersion}),
+		archivedTask(t, "image-enrichment", "doc-1-image-enrich-1", TypeEnrichImage, EnrichImagePayload{PayloadVersion: PayloadVersion}),
+		archivedTask(t, "image-enrichment-low", "doc-2-image-enrich-0", TypeEnrichImage, EnrichImagePayload{PayloadVersion: PayloadVersion}),
+	)
+	inspector := &Inspector{inspector: fake}
+
+	requeued, err := inspector.RetryAllArchivedTasks("image-enrichment")
+	require.NoError(t, err)
+	require.Len(t, requeued, 2)
+	assert.Equal(t, "doc-1-image-enrich-0", requeued[0].TaskID)
+	assert.Equal(t, "doc-1-image-enrich-1", requeued[1].TaskID)
+	assert.Equal(t, asynq.TaskStateArchived, fake.tasks["image-enrichment-low/doc-2-image-enrich-0"].State, "other queues are untouched")
+
+	requeued, err = inspector.RetryAllArchivedTasks("image-enrichment")
+	require.NoError(t, err)
+	assert.Empty(t, requeued)
+
+	fake.err = errors.New("connection refused")
+	_, err = inspector.RetryAllArchivedTasks("image-enrichment-low")
+	assert.ErrorContains(t, err, "connection refused")
+}
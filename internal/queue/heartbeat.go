@@ -0,0 +1,198 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/docutag/textanalyzer/internal/models"
+	"github.com/hibiken/asynq"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultHeartbeatInterval is how often a worker reports liveness
+const DefaultHeartbeatInterval = 15 * time.Second
+
+// HeartbeatStore persists worker heartbeats
+type HeartbeatStore interface {
+	SaveWorkerHeartbeat(ctx context.Context, heartbeat *models.WorkerHeartbeat) error
+}
+
+// taskStats tracks task activity for heartbeats
+type taskStats struct {
+	active        atomic.Int64
+	processed     atomic.Int64
+	failed        atomic.Int64
+	lastCompleted atomic.Int64 // Unix nanoseconds, 0 if no task has completed
+}
+
+// middleware wraps task handlers to count active, processed and failed tasks
+func (s *taskStats) middleware(next asynq.Handler) asynq.Handler {
+	return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+		s.active.Add(1)
+		defer s.active.Add(-1)
+
+		err := next.ProcessTask(ctx, t)
+		if err != nil {
+			s.failed.Add(1)
+		} else {
+			s.processed.Add(1)
+		}
+		s.lastCompleted.Store(time.Now().UnixNano())
+		return err
+	})
+}
+
+// heartbeat periodically writes a worker's liveness and task stats to a store
+type heartbeat struct {
+	workerID  string
+	store     HeartbeatStore
+	interval  time.Duration
+	stats     *taskStats
+	logger    *slog.Logger
+	startedAt time.Time
+
+	// now and newTicker are replaceable for tests
+	now       func() time.Time
+	newTicker func(d time.Duration) (<-chan time.Time, func())
+
+	lastBeat atomic.Int64 // Unix nanoseconds of the last successful write
+
+	mu      sync.Mutex
+	stop    chan struct{}
+	done    chan struct{}
+	running bool
+}
+
+// newHeartbeat creates a heartbeat for a worker
+func newHeartbeat(workerID string, store HeartbeatStore, interval time.Duration, stats *taskStats, logger *slog.Logger) *heartbeat {
+	if interval <= 0 {
+		interval = DefaultHeartbeatInterval
+	}
+	return &heartbeat{
+		workerID: workerID,
+		store:    store,
+		interval: interval,
+		stats:    stats,
+		logger:   logger,
+		now:      time.Now,
+		newTicker: func(d time.Duration) (<-chan time.Time, func()) {
+			ticker := time.NewTicker(d)
+			return ticker.C, ticker.Stop
+		},
+	}
+}
+
+// Start writes an initial heartbeat and then one per interval until Stop is called
+func (h *heartbeat) Start() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.running {
+		return
+	}
+
+	h.running = true
+	h.startedAt = h.now()
+	h.stop = make(chan struct{})
+	h.done = make(chan struct{})
+
+	ticks, stopTicker := h.newTicker(h.interval)
+	h.beat()
+
+	go func() {
+		defer close(h.done)
+		defer stopTicker()
+		for {
+			select {
+			case <-h.stop:
+				return
+			case <-ticks:
+				h.beat()
+			}
+		}
+	}()
+}
+
+// Stop halts heartbeats and waits for the heartbeat goroutine to exit
+func (h *heartbeat) Stop() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.running {
+		return
+	}
+
+	close(h.stop)
+	<-h.done
+	h.running = false
+}
+
+// beat writes a single heartbeat, giving up when the write takes longer
+// than the interval so a slow database cannot pile up heartbeats
+func (h *heartbeat) beat() {
+	now := h.now()
+	hb := &models.WorkerHeartbeat{
+		WorkerID:       h.workerID,
+		HeartbeatAt:    now,
+		StartedAt:      h.startedAt,
+		ActiveTasks:    int(h.stats.active.Load()),
+		ProcessedTasks: h.stats.processed.Load(),
+		FailedTasks:    h.stats.failed.Load(),
+	}
+	if completed := h.stats.lastCompleted.Load(); completed > 0 {
+		t := time.Unix(0, completed)
+		hb.LastTaskCompletedAt = &t
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.interval)
+	defer cancel()
+	if err := h.store.SaveWorkerHeartbeat(ctx, hb); err != nil {
+		h.logger.Warn("failed to write worker heartbeat", "worker_id", h.workerID, "error", err)
+		return
+	}
+	h.lastBeat.Store(now.UnixNano())
+}
+
+// age returns the time since the last successful heartbeat, measured from
+// the worker's start if none has been written yet
+func (h *heartbeat) age() time.Duration {
+	last := h.lastBeat.Load()
+	if last == 0 {
+		h.mu.Lock()
+		startedAt := h.startedAt
+		h.mu.Unlock()
+		if startedAt.IsZero() {
+			return 0
+		}
+		return h.now().Sub(startedAt)
+	}
+	return h.now().Sub(time.Unix(0, last))
+}
+
+// registerAgeGauge exports seconds since the last heartbeat so alerting can
+// fire on a stalled worker
+func (h *heartbeat) registerAgeGauge(registerer prometheus.Registerer) {
+	gauge := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        "textanalyzer_worker_heartbeat_age_seconds",
+		Help:        "Seconds since the queue worker last wrote a heartbeat",
+		ConstLabels: prometheus.Labels{"worker_id": h.workerID},
+	}, func() float64 {
+		return h.age().Seconds()
+	})
+
+	if err := registerer.Register(gauge); err != nil {
+		h.logger.Warn("failed to register heartbeat gauge", "error", err)
+	}
+}
+
+// defaultWorkerID identifies this worker process by host and PID
+func defaultWorkerID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
@@ -0,0 +1,189 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docutag/textanalyzer/internal/models"
+	"github.com/hibiken/asynq"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeHeartbeatStore records heartbeats and signals each write
+type fakeHeartbeatStore struct {
+	mu     sync.Mutex
+	beats  []models.WorkerHeartbeat
+	writes chan struct{}
+	err    error
+}
+
+func newFakeHeartbeatStore() *fakeHeartbeatStore {
+	return &fakeHeartbeatStore{writes: make(chan struct{}, 100)}
+}
+
+func (s *fakeHeartbeatStore) SaveWorkerHeartbeat(_ context.Context, heartbeat *models.WorkerHeartbeat) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		s.writes <- struct{}{}
+		return s.err
+	}
+	s.beats = append(s.beats, *heartbeat)
+	s.writes <- struct{}{}
+	return nil
+}
+
+func (s *fakeHeartbeatStore) snapshot() []models.WorkerHeartbeat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]models.WorkerHeartbeat(nil), s.beats...)
+}
+
+func (s *fakeHeartbeatStore) waitForWrite(t *testing.T) {
+	t.Helper()
+	select {
+	case <-s.writes:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for heartbeat write")
+	}
+}
+
+// fakeClock is a manually advanced clock
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// newTestHeartbeat creates a heartbeat driven by a fake clock and a manual ticker
+func newTestHeartbeat(store HeartbeatStore, stats *taskStats) (*heartbeat, *fakeClock, chan time.Time, *bool) {
+	clock := &fakeClock{now: time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)}
+	ticks := make(chan time.Time)
+	tickerStopped := false
+
+	hb := newHeartbeat("worker-1", store, 30*time.Second, stats, slog.Default())
+	hb.now = clock.Now
+	hb.newTicker = func(d time.Duration) (<-chan time.Time, func()) {
+		return ticks, func() { tickerStopped = true }
+	}
+	return hb, clock, ticks, &tickerStopped
+}
+
+func TestHeartbeatCadence(t *testing.T) {
+	store := newFakeHeartbeatStore()
+	stats := &taskStats{}
+	hb, clock, ticks, tickerStopped := newTestHeartbeat(store, stats)
+
+	hb.Start()
+	store.waitForWrite(t)
+
+	// One write per tick
+	for i := 0; i < 3; i++ {
+		clock.Advance(30 * time.Second)
+		ticks <- clock.Now()
+		store.waitForWrite(t)
+	}
+
+	beats := store.snapshot()
+	require.Len(t, beats, 4, "expected initial heartbeat plus one per tick")
+	for i, beat := range beats {
+		assert.Equal(t, "worker-1", beat.WorkerID)
+		assert.Equal(t, beats[0].StartedAt, beat.StartedAt)
+		if i > 0 {
+			assert.Equal(t, 30*time.Second, beat.HeartbeatAt.Sub(beats[i-1].HeartbeatAt))
+		}
+	}
+
+	hb.Stop()
+	assert.True(t, *tickerStopped, "ticker should be stopped on shutdown")
+
+	// No more writes after Stop; nothing is receiving from the ticker
+	select {
+	case ticks <- clock.Now():
+		t.Fatal("heartbeat goroutine still running after Stop")
+	case <-time.After(50 * time.Millisecond):
+	}
+	assert.Len(t, store.snapshot(), 4)
+
+	// Stop is idempotent
+	hb.Stop()
+}
+
+func TestHeartbeatReportsTaskStats(t *testing.T) {
+	store := newFakeHeartbeatStore()
+	stats := &taskStats{}
+	hb, clock, ticks, _ := newTestHeartbeat(store, stats)
+
+	handler := stats.middleware(asynq.HandlerFunc(func(ctx context.Context, task *asynq.Task) error {
+		if task.Type() == "fail" {
+			return errors.New("boom")
+		}
+		return nil
+	}))
+	require.NoError(t, handler.ProcessTask(context.Background(), asynq.NewTask("ok", nil)))
+	require.NoError(t, handler.ProcessTask(context.Background(), asynq.NewTask("ok", nil)))
+	require.Error(t, handler.ProcessTask(context.Background(), asynq.NewTask("fail", nil)))
+
+	// Simulate a task still in flight
+	stats.active.Add(1)
+
+	hb.Start()
+	defer hb.Stop()
+	store.waitForWrite(t)
+
+	clock.Advance(30 * time.Second)
+	ticks <- clock.Now()
+	store.waitForWrite(t)
+
+	beats := store.snapshot()
+	last := beats[len(beats)-1]
+	assert.Equal(t, 1, last.ActiveTasks)
+	assert.Equal(t, int64(2), last.ProcessedTasks)
+	assert.Equal(t, int64(1), last.FailedTasks)
+	assert.NotNil(t, last.LastTaskCompletedAt)
+}
+
+func TestHeartbeatAgeGauge(t *testing.T) {
+	store := newFakeHeartbeatStore()
+	hb, clock, ticks, _ := newTestHeartbeat(store, &taskStats{})
+
+	registry := prometheus.NewRegistry()
+	hb.registerAgeGauge(registry)
+
+	hb.Start()
+	defer hb.Stop()
+	store.waitForWrite(t)
+
+	clock.Advance(10 * time.Second)
+	assert.Equal(t, 1, testutil.CollectAndCount(registry))
+	assert.InDelta(t, 10.0, hb.age().Seconds(), 0.001)
+
+	// Failed writes do not reset the age, so a wedged store shows as stalled
+	store.mu.Lock()
+	store.err = errors.New("database unavailable")
+	store.mu.Unlock()
+
+	clock.Advance(30 * time.Second)
+	ticks <- clock.Now()
+	store.waitForWrite(t)
+
+	assert.InDelta(t, 40.0, hb.age().Seconds(), 0.001)
+}
@@ -0,0 +1,290 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// TaskStateNotFound is reported for a task that is in none of its queues,
+// either because it was never enqueued or because it has been removed
+const TaskStateNotFound = "not_found"
+
+// ProcessDocumentTaskID returns the ID of an analysis's offline processing task
+func ProcessDocumentTaskID(analysisID string) string {
+	return analysisID
+}
+
+// TextEnrichTaskID returns the ID of an analysis's AI text enrichment task
+func TextEnrichTaskID(analysisID string) string {
+	return analysisID + "-text-enrich"
+}
+
+// ImageEnrichTaskID returns the ID of the enrichment task for one image of an analysis
+func ImageEnrichTaskID(analysisID string, imageIndex int) string {
+	return fmt.Sprintf("%s-image-enrich-%d", analysisID, imageIndex)
+}
+
+// FamilyTask identifies one of the tasks spawned for an analysis
+type FamilyTask struct {
+	ID     string
+	Type   string
+	Queues []string // Queues the task may be on, one per priority
+}
+
+// TaskFamily returns every task an analysis with imageCount images can
+// spawn: offline processing, text enrichment and one task per image
+func TaskFamily(analysisID string, imageCount int) []FamilyTask {
+	family := []FamilyTask{
+		{ID: ProcessDocumentTaskID(analysisID), Type: TypeProcessDocument, Queues: priorityQueues(queueOfflineProcessing)},
+		{ID: TextEnrichTaskID(analysisID), Type: TypeEnrichText, Queues: priorityQueues(queueTextEnrichment)},
+	}
+	for i := 0; i < imageCount; i++ {
+		family = append(family, FamilyTask{
+			ID:     ImageEnrichTaskID(analysisID, i),
+			Type:   TypeEnrichImage,
+			Queues: priorityQueues(queueImageEnrichment),
+		})
+	}
+	return family
+}
+
+// priorityQueues returns the queues of a processing stage for every priority
+func priorityQueues(base string) []string {
+	return []string{queueName(base, PriorityHigh), base, queueName(base, PriorityLow)}
+}
+
+// TaskStatus is the queue state of a single task
+type TaskStatus struct {
+	TaskID        string     `json:"task_id"`
+	Type          string     `json:"type"`
+	State         string     `json:"state"` // pending, active, scheduled, retry, archived, completed or not_found
+	Queue         string     `json:"queue,omitempty"`
+	Retried       int        `json:"retried"`
+	MaxRetry      int        `json:"max_retry,omitempty"`
+	NextProcessAt *time.Time `json:"next_process_at,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastFailedAt  *time.Time `json:"last_failed_at,omitempty"`
+}
+
+// asynqInspector is the subset of asynq.Inspector used by Inspector
+type asynqInspector interface {
+	GetTaskInfo(queue, id string) (*asynq.TaskInfo, error)
+	DeleteTask(queue, id string) error
+	RunTask(queue, id string) error
+	ListArchivedTasks(queue string, opts ...asynq.ListOption) ([]*asynq.TaskInfo, error)
+	GetQueueInfo(queue string) (*asynq.QueueInfo, error)
+	Queues() ([]string, error)
+	Close() error
+}
+
+// Inspector looks up the queue state of an analysis's tasks and the depth of
+// each processing stage
+type Inspector struct {
+	inspector asynqInspector
+}
+
+// NewInspector creates a new queue inspector
+func NewInspector(cfg ClientConfig) *Inspector {
+	return &Inspector{
+		inspector: asynq.NewInspector(asynq.RedisClientOpt{Addr: cfg.RedisAddr}),
+	}
+}
+
+// Close closes the inspector's Redis connection
+func (i *Inspector) Close() error {
+	return i.inspector.Close()
+}
+
+// Health checks that Redis answers, giving up when ctx is done
+func (i *Inspector) Health(ctx context.Context) error {
+	result := make(chan error, 1)
+	go func() {
+		_, err := i.inspector.Queues()
+		result <- err
+	}()
+
+	select {
+	case err := <-result:
+		if err != nil {
+			return fmt.Errorf("redis is unreachable: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("redis is unreachable: %w", ctx.Err())
+	}
+}
+
+// FamilyTasks returns the state of every task in an analysis's family. A
+// negative imageCount means the count is unknown, in which case it is read
+// from the offline processing task if that is still queued.
+func (i *Inspector) FamilyTasks(analysisID string, imageCount int) ([]TaskStatus, error) {
+	family := TaskFamily(analysisID, 0)
+
+	root, err := i.findTask(family[0])
+	if err != nil {
+		return nil, err
+	}
+	if imageCount < 0 {
+		imageCount = 0
+		if root != nil {
+			if payload, _, err := DecodeProcessDocumentPayload(root.Payload); err == nil {
+				imageCount = len(payload.Images)
+			}
+		}
+	}
+
+	family = TaskFamily(analysisID, imageCount)
+	statuses := []TaskStatus{taskStatus(family[0], root)}
+	for _, task := range family[1:] {
+		info, err := i.findTask(task)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, taskStatus(task, info))
+	}
+	return statuses, nil
+}
+
+// ClearEnrichmentTasks deletes the completed and archived enrichment tasks
+// in an analysis's family, with imageCount as for FamilyTasks, so that its
+// enrichment can be enqueued again under the same task IDs. Tasks waiting to
+// run or running are left alone.
+func (i *Inspector) ClearEnrichmentTasks(analysisID string, imageCount int) error {
+	statuses, err := i.FamilyTasks(analysisID, imageCount)
+	if err != nil {
+		return err
+	}
+
+	for _, status := range statuses {
+		if status.Type == TypeProcessDocument || (status.State != "completed" && status.State != "archived") {
+			continue
+		}
+		err := i.inspector.DeleteTask(status.Queue, status.TaskID)
+		if err != nil && !errors.Is(err, asynq.ErrTaskNotFound) {
+			return fmt.Errorf("failed to delete task %s: %w", status.TaskID, err)
+		}
+	}
+	return nil
+}
+
+// CancelResult is the outcome of cancelling an analysis's tasks
+type CancelResult struct {
+	Deleted []TaskStatus // Tasks removed before they ran, as they were when removed
+	Active  []TaskStatus // Running tasks, which cannot be removed
+}
+
+// CancelFamily deletes the tasks in an analysis's family that are waiting
+// to run or to retry, with imageCount as for FamilyTasks. Running tasks
+// cannot be deleted and are returned as active; completed and archived
+// tasks are left alone.
+func (i *Inspector) CancelFamily(analysisID string, imageCount int) (*CancelResult, error) {
+	statuses, err := i.FamilyTasks(analysisID, imageCount)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &CancelResult{}
+	for _, status := range statuses {
+		switch status.State {
+		case "active":
+			result.Active = append(result.Active, status)
+			continue
+		case "pending", "scheduled", "retry":
+		default:
+			continue
+		}
+
+		err := i.inspector.DeleteTask(status.Queue, status.TaskID)
+		switch {
+		case err == nil:
+			result.Deleted = append(result.Deleted, status)
+		case errors.Is(err, asynq.ErrTaskNotFound):
+			// Finished or removed since it was inspected
+		default:
+			// The task may have started since it was inspected
+			info, infoErr := i.inspector.GetTaskInfo(status.Queue, status.TaskID)
+			if infoErr != nil || info.State != asynq.TaskStateActive {
+				return nil, fmt.Errorf("failed to delete task %s: %w", status.TaskID, err)
+			}
+			status.State = info.State.String()
+			result.Active = append(result.Active, status)
+		}
+	}
+	return result, nil
+}
+
+// PendingTasks returns the number of tasks waiting in each processing stage,
+// summed across priorities and keyed by stage. Queues that do not exist yet
+// count as empty.
+func (i *Inspector) PendingTasks() (map[string]int, error) {
+	queues, err := i.inspector.Queues()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list queues: %w", err)
+	}
+	existing := make(map[string]bool, len(queues))
+	for _, queue := range queues {
+		existing[queue] = true
+	}
+
+	pending := make(map[string]int, len(Stages))
+	for _, stage := range Stages {
+		pending[stage] = 0
+		for _, queue := range priorityQueues(stage) {
+			if !existing[queue] {
+				continue
+			}
+			info, err := i.inspector.GetQueueInfo(queue)
+			if err != nil {
+				return nil, fmt.Errorf("failed to inspect queue %s: %w", queue, err)
+			}
+			pending[stage] += info.Pending
+		}
+	}
+	return pending, nil
+}
+
+// findTask looks for a task in each of its queues, returning nil if it is in none
+func (i *Inspector) findTask(task FamilyTask) (*asynq.TaskInfo, error) {
+	for _, queue := range task.Queues {
+		info, err := i.inspector.GetTaskInfo(queue, task.ID)
+		if errors.Is(err, asynq.ErrTaskNotFound) || errors.Is(err, asynq.ErrQueueNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to inspect task %s: %w", task.ID, err)
+		}
+		return info, nil
+	}
+	return nil, nil
+}
+
+// taskStatus converts asynq task info into a TaskStatus; nil info means not found
+func taskStatus(task FamilyTask, info *asynq.TaskInfo) TaskStatus {
+	status := TaskStatus{
+		TaskID: task.ID,
+		Type:   task.Type,
+		State:  TaskStateNotFound,
+	}
+	if info == nil {
+		return status
+	}
+
+	status.State = info.State.String()
+	status.Queue = info.Queue
+	status.Retried = info.Retried
+	status.MaxRetry = info.MaxRetry
+	status.LastError = info.LastErr
+	if !info.NextProcessAt.IsZero() {
+		next := info.NextProcessAt
+		status.NextProcessAt = &next
+	}
+	if !info.LastFailedAt.IsZero() {
+		failed := info.LastFailedAt
+		status.LastFailedAt = &failed
+	}
+	return status
+}
@@ -0,0 +1,322 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeInspector serves task info from memory, keyed by queue and task ID,
+// and queue info keyed by queue
+type fakeInspector struct {
+	tasks   map[string]*asynq.TaskInfo
+	queues  map[string]*asynq.QueueInfo
+	lookups []string
+	err     error
+}
+
+func newFakeInspector(tasks ...*asynq.TaskInfo) *fakeInspector {
+	f := &fakeInspector{tasks: make(map[string]*asynq.TaskInfo)}
+	for _, task := range tasks {
+		f.tasks[task.Queue+"/"+task.ID] = task
+	}
+	return f
+}
+
+func (f *fakeInspector) GetTaskInfo(queue, id string) (*asynq.TaskInfo, error) {
+	f.lookups = append(f.lookups, queue+"/"+id)
+	if f.err != nil {
+		return nil, f.err
+	}
+	if task, ok := f.tasks[queue+"/"+id]; ok {
+		return task, nil
+	}
+	return nil, asynq.ErrTaskNotFound
+}
+
+func (f *fakeInspector) DeleteTask(queue, id string) error {
+	if f.err != nil {
+		return f.err
+	}
+	task, ok := f.tasks[queue+"/"+id]
+	if !ok {
+		return asynq.ErrTaskNotFound
+	}
+	if task.State == asynq.TaskStateActive {
+		return errors.New("asynq: cannot delete task in active state")
+	}
+	delete(f.tasks, queue+"/"+id)
+	return nil
+}
+
+func (f *fakeInspector) RunTask(queue, id string) error {
+	if f.err != nil {
+		return f.err
+	}
+	task, ok := f.tasks[queue+"/"+id]
+	if !ok {
+		return asynq.ErrTaskNotFound
+	}
+	task.State = asynq.TaskStatePending
+	return nil
+}
+
+// ListArchivedTasks lists the archived tasks of a queue in ID order,
+// ignoring page options
+func (f *fakeInspector) ListArchivedTasks(queue string, opts ...asynq.ListOption) ([]*asynq.TaskInfo, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	var archived []*asynq.TaskInfo
+	for _, task := range f.tasks {
+		if task.Queue == queue && task.State == asynq.TaskStateArchived {
+			archived = append(archived, task)
+		}
+	}
+	sort.Slice(archived, func(i, j int) bool { return archived[i].ID < archived[j].ID })
+	return archived, nil
+}
+
+func (f *fakeInspector) Queues() ([]string, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	var queues []string
+	for queue := range f.queues {
+		queues = append(queues, queue)
+	}
+	return queues, nil
+}
+
+func (f *fakeInspector) GetQueueInfo(queue string) (*asynq.QueueInfo, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.queues[queue], nil
+}
+
+func (f *fakeInspector) Close() error {
+	return nil
+}
+
+func TestTaskFamily(t *testing.T) {
+	family := TaskFamily("doc-1", 2)
+	require.Len(t, family, 4)
+
+	assert.Equal(t, FamilyTask{ID: "doc-1", Type: TypeProcessDocument,
+		Queues: []string{"offline-processing-high", "offline-processing", "offline-processing-low"}}, family[0])
+	assert.Equal(t, FamilyTask{ID: "doc-1-text-enrich", Type: TypeEnrichText,
+		Queues: []string{"text-enrichment-high", "text-enrichment", "text-enrichment-low"}}, family[1])
+	assert.Equal(t, "doc-1-image-enrich-0", family[2].ID)
+	assert.Equal(t, "doc-1-image-enrich-1", family[3].ID)
+	assert.Equal(t, TypeEnrichImage, family[3].Type)
+	assert.Equal(t, []string{"image-enrichment-high", "image-enrichment", "image-enrichment-low"}, family[3].Queues)
+}
+
+func TestInspectorFamilyTasks(t *testing.T) {
+	nextRetry := time.Now().Add(time.Minute).Truncate(time.Second)
+	failedAt := time.Now().Add(-time.Minute).Truncate(time.Second)
+
+	fake := newFakeInspector(
+		&asynq.TaskInfo{ID: "doc-1", Queue: "offline-processing-high", State: asynq.TaskStateCompleted, MaxRetry: 3},
+		&asynq.TaskInfo{ID: "doc-1-text-enrich", Queue: "text-enrichment-high", State: asynq.TaskStatePending, MaxRetry: 10},
+		&asynq.TaskInfo{
+			ID: "doc-1-image-enrich-0", Queue: "image-enrichment-high", State: asynq.TaskStateRetry,
+			Retried: 2, MaxRetry: 10, NextProcessAt: nextRetry, LastErr: "ollama timeout", LastFailedAt: failedAt,
+		},
+		&asynq.TaskInfo{
+			ID: "doc-1-image-enrich-1", Queue: "image-enrichment-high", State: asynq.TaskStateArchived,
+			Retried: 10, MaxRetry: 10, LastErr: "image not found", LastFailedAt: failedAt,
+		},
+	)
+	inspector := &Inspector{inspector: fake}
+
+	statuses, err := inspector.FamilyTasks("doc-1", 3)
+	require.NoError(t, err)
+	require.Len(t, statuses, 5)
+
+	assert.Equal(t, "completed", statuses[0].State)
+	assert.Equal(t, "offline-processing-high", statuses[0].Queue)
+	assert.Nil(t, statuses[0].NextProcessAt)
+
+	assert.Equal(t, "pending", statuses[1].State)
+	assert.Equal(t, TypeEnrichText, statuses[1].Type)
+
+	retry := statuses[2]
+	assert.Equal(t, "retry", retry.State)
+	assert.Equal(t, 2, retry.Retried)
+	assert.Equal(t, "ollama timeout", retry.LastError)
+	require.NotNil(t, retry.NextProcessAt)
+	assert.True(t, retry.NextProcessAt.Equal(nextRetry))
+	require.NotNil(t, retry.LastFailedAt)
+	assert.True(t, retry.LastFailedAt.Equal(failedAt))
+
+	assert.Equal(t, "archived", statuses[3].State)
+	assert.Equal(t, "image not found", statuses[3].LastError)
+
+	assert.Equal(t, TaskStatus{TaskID: "doc-1-image-enrich-2", Type: TypeEnrichImage, State: TaskStateNotFound}, statuses[4])
+}
+
+func TestInspectorFamilyTasksAcrossPriorities(t *testing.T) {
+	fake := newFakeInspector(
+		&asynq.TaskInfo{ID: "doc-2", Queue: "offline-processing-low", State: asynq.TaskStatePending},
+	)
+	inspector := &Inspector{inspector: fake}
+
+	statuses, err := inspector.FamilyTasks("doc-2", 0)
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+	assert.Equal(t, "offline-processing-low", statuses[0].Queue)
+	assert.Equal(t, TaskStateNotFound, statuses[1].State)
+
+	// Every priority queue is tried before a task is reported as not found
+	assert.Contains(t, fake.lookups, "text-enrichment-high/doc-2-text-enrich")
+	assert.Contains(t, fake.lookups, "text-enrichment/doc-2-text-enrich")
+	assert.Contains(t, fake.lookups, "text-enrichment-low/doc-2-text-enrich")
+}
+
+func TestInspectorFamilyTasksImageCountFromPayload(t *testing.T) {
+	payload, err := json.Marshal(ProcessDocumentPayload{
+		PayloadVersion: PayloadVersion,
+		AnalysisID:     "doc-3",
+		Images:         []string{"https://example.com/a.jpg", "https://example.com/b.jpg"},
+	})
+	require.NoError(t, err)
+
+	fake := newFakeInspector(
+		&asynq.TaskInfo{ID: "doc-3", Queue: "offline-processing", State: asynq.TaskStateRetry, Payload: payload},
+	)
+	inspector := &Inspector{inspector: fake}
+
+	statuses, err := inspector.FamilyTasks("doc-3", -1)
+	require.NoError(t, err)
+	require.Len(t, statuses, 4)
+	assert.Equal(t, "doc-3-image-enrich-1", statuses[3].TaskID)
+}
+
+func TestInspectorFamilyTasksError(t *testing.T) {
+	fake := newFakeInspector()
+	fake.err = errors.New("connection refused")
+	inspector := &Inspector{inspector: fake}
+
+	_, err := inspector.FamilyTasks("doc-4", 0)
+	assert.ErrorContains(t, err, "connection refused")
+}
+
+func TestInspectorCancelFamily(t *testing.T) {
+	fake := newFakeInspector(
+		&asynq.TaskInfo{ID: "doc-5", Queue: "offline-processing", State: asynq.TaskStateCompleted},
+		&asynq.TaskInfo{ID: "doc-5-text-enrich", Queue: "text-enrichment-high", State: asynq.TaskStateRetry, Retried: 1},
+		&asynq.TaskInfo{ID: "doc-5-image-enrich-0", Queue: "image-enrichment-high", State: asynq.TaskStateActive},
+		&asynq.TaskInfo{ID: "doc-5-image-enrich-1", Queue: "image-enrichment-high", State: asynq.TaskStatePending},
+		&asynq.TaskInfo{ID: "doc-5-image-enrich-2", Queue: "image-enrichment-high", State: asynq.TaskStateArchived},
+	)
+	inspector := &Inspector{inspector: fake}
+
+	result, err := inspector.CancelFamily("doc-5", 3)
+	require.NoError(t, err)
+
+	require.Len(t, result.Deleted, 2)
+	assert.Equal(t, "doc-5-text-enrich", result.Deleted[0].TaskID)
+	assert.Equal(t, "retry", result.Deleted[0].State)
+	assert.Equal(t, "doc-5-image-enrich-1", result.Deleted[1].TaskID)
+	require.Len(t, result.Active, 1)
+	assert.Equal(t, "doc-5-image-enrich-0", result.Active[0].TaskID)
+
+	// Completed, archived and running tasks stay queued
+	assert.NotContains(t, fake.tasks, "text-enrichment-high/doc-5-text-enrich")
+	assert.NotContains(t, fake.tasks, "image-enrichment-high/doc-5-image-enrich-1")
+	assert.Contains(t, fake.tasks, "offline-processing/doc-5")
+	assert.Contains(t, fake.tasks, "image-enrichment-high/doc-5-image-enrich-0")
+	assert.Contains(t, fake.tasks, "image-enrichment-high/doc-5-image-enrich-2")
+
+	// Nothing is left to cancel the second time
+	result, err = inspector.CancelFamily("doc-5", 3)
+	require.NoError(t, err)
+	assert.Empty(t, result.Deleted)
+	assert.Len(t, result.Active, 1)
+}
+
+func TestInspectorCancelFamilyStartedTask(t *testing.T) {
+	fake := &startingInspector{fakeInspector: newFakeInspector(
+		&asynq.TaskInfo{ID: "doc-6", Queue: "offline-processing", State: asynq.TaskStatePending},
+	)}
+	inspector := &Inspector{inspector: fake}
+
+	result, err := inspector.CancelFamily("doc-6", 0)
+	require.NoError(t, err)
+	assert.Empty(t, result.Deleted)
+	require.Len(t, result.Active, 1)
+	assert.Equal(t, "active", result.Active[0].State)
+}
+
+// startingInspector starts each task just before it is deleted
+type startingInspector struct {
+	*fakeInspector
+}
+
+func (s *startingInspector) DeleteTask(queue, id string) error {
+	if task, ok := s.tasks[queue+"/"+id]; ok {
+		task.State = asynq.TaskStateActive
+	}
+	return s.fakeInspector.DeleteTask(queue, id)
+}
+
+func TestInspectorClearEnrichmentTasks(t *testing.T) {
+	fake := newFakeInspector(
+		&asynq.TaskInfo{ID: "doc-5", Queue: "offline-processing", State: asynq.TaskStateCompleted},
+		&asynq.TaskInfo{ID: "doc-5-text-enrich", Queue: "text-enrichment", State: asynq.TaskStateArchived},
+		&asynq.TaskInfo{ID: "doc-5-image-enrich-0", Queue: "image-enrichment", State: asynq.TaskStateCompleted},
+		&asynq.TaskInfo{ID: "doc-5-image-enrich-1", Queue: "image-enrichment", State: asynq.TaskStatePending},
+	)
+	inspector := &Inspector{inspector: fake}
+
+	require.NoError(t, inspector.ClearEnrichmentTasks("doc-5", 2))
+
+	// Finished enrichment tasks are removed; offline processing and tasks
+	// still waiting to run stay queued
+	assert.NotContains(t, fake.tasks, "text-enrichment/doc-5-text-enrich")
+	assert.NotContains(t, fake.tasks, "image-enrichment/doc-5-image-enrich-0")
+	assert.Contains(t, fake.tasks, "offline-processing/doc-5")
+	assert.Contains(t, fake.tasks, "image-enrichment/doc-5-image-enrich-1")
+
+	fake.err = errors.New("connection refused")
+	assert.ErrorContains(t, inspector.ClearEnrichmentTasks("doc-5", 2), "connection refused")
+}
+
+func TestInspectorHealth(t *testing.T) {
+	fake := newFakeInspector()
+	inspector := &Inspector{inspector: fake}
+	assert.NoError(t, inspector.Health(context.Background()))
+
+	fake.err = errors.New("dial tcp: connection refused")
+	err := inspector.Health(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "redis is unreachable")
+
+	// A Redis call that hangs is abandoned when the context is done
+	hanging := &hangingInspector{fakeInspector: newFakeInspector(), release: make(chan struct{})}
+	defer close(hanging.release)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = (&Inspector{inspector: hanging}).Health(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+// hangingInspector blocks Queues until release is closed
+type hangingInspector struct {
+	*fakeInspector
+	release chan struct{}
+}
+
+func (h *hangingInspector) Queues() ([]string, error) {
+	<-h.release
+	return nil, nil
+}
@@ -0,0 +1,129 @@
+package queue
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/hibiken/asynq"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PayloadVersion is the task payload schema version written by Client.
+//
+// Compatibility policy: workers decode payloads of the current version and
+// the one before it (N-1), so tasks enqueued by the previous release are
+// still processed during a rolling deploy. Bump PayloadVersion whenever a
+// payload field is renamed, removed or changes meaning, and upgrade the
+// previous version in the matching decode function. Adding an optional
+// field does not need a bump. Payloads older than N-1, and versions newer
+// than this worker understands, fail without retrying.
+const PayloadVersion = 2
+
+// minPayloadVersion is the oldest payload version workers still decode
+const minPayloadVersion = PayloadVersion - 1
+
+// legacyPayloadVersion is the version of payloads written before
+// payload_version existed
+const legacyPayloadVersion = 1
+
+// DecodeProcessDocumentPayload decodes a process document payload of any
+// supported version into the current struct, returning the version it was
+// written with
+func DecodeProcessDocumentPayload(data []byte) (ProcessDocumentPayload, int, error) {
+	var payload ProcessDocumentPayload
+	version, err := decodePayload(data, &payload)
+	if err != nil {
+		return payload, version, err
+	}
+
+	// Version 1 payloads may predate per-request options
+	if version == legacyPayloadVersion && payload.Options.Priority == "" {
+		payload.Options.Priority = PriorityNormal
+	}
+	payload.PayloadVersion = PayloadVersion
+	return payload, version, nil
+}
+
+// DecodeEnrichTextPayload decodes an enrich text payload of any supported
+// version into the current struct, returning the version it was written with
+func DecodeEnrichTextPayload(data []byte) (EnrichTextPayload, int, error) {
+	var payload EnrichTextPayload
+	version, err := decodePayload(data, &payload)
+	if err != nil {
+		return payload, version, err
+	}
+	payload.PayloadVersion = PayloadVersion
+	return payload, version, nil
+}
+
+// DecodeEnrichImagePayload decodes an enrich image payload of any supported
+// version into the current struct, returning the version it was written with
+func DecodeEnrichImagePayload(data []byte) (EnrichImagePayload, int, error) {
+	var payload EnrichImagePayload
+	version, err := decodePayload(data, &payload)
+	if err != nil {
+		return payload, version, err
+	}
+	payload.PayloadVersion = PayloadVersion
+	return payload, version, nil
+}
+
+// decodePayload checks the payload version and unmarshals data into payload.
+// Unsupported versions are wrapped in asynq.SkipRetry, since retrying on the
+// same worker cannot succeed.
+func decodePayload(data []byte, payload interface{}) (int, error) {
+	var header struct {
+		PayloadVersion *int `json:"payload_version"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return 0, fmt.Errorf("invalid task payload: %w", err)
+	}
+
+	version := legacyPayloadVersion
+	if header.PayloadVersion != nil {
+		version = *header.PayloadVersion
+	}
+	if version < minPayloadVersion || version > PayloadVersion {
+		return version, fmt.Errorf("unsupported payload version %d (supported %d-%d): %w",
+			version, minPayloadVersion, PayloadVersion, asynq.SkipRetry)
+	}
+
+	if err := json.Unmarshal(data, payload); err != nil {
+		return version, fmt.Errorf("invalid task payload: %w", err)
+	}
+	return version, nil
+}
+
+// newLegacyPayloadCounter counts tasks decoded from payloads older than
+// PayloadVersion, so operators can tell when old tasks have drained
+func newLegacyPayloadCounter(registerer prometheus.Registerer, logger *slog.Logger) *prometheus.CounterVec {
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "textanalyzer_queue_legacy_payloads_total",
+		Help: "Tasks decoded from a payload version older than the current one",
+	}, []string{"task_type", "payload_version"})
+
+	if err := registerer.Register(counter); err != nil {
+		if existing, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			return existing.ExistingCollector.(*prometheus.CounterVec)
+		}
+		logger.Warn("failed to register legacy payload counter", "error", err)
+	}
+	return counter
+}
+
+// observePayloadVersion logs and counts tasks decoded from a legacy payload
+func (w *Worker) observePayloadVersion(taskType string, version int) {
+	if version >= PayloadVersion {
+		return
+	}
+	w.logger.Info("decoded legacy task payload",
+		"task_type", taskType,
+		"payload_version", version,
+		"current_version", PayloadVersion,
+	)
+	if w.legacyPayloads != nil {
+		w.legacyPayloads.WithLabelValues(taskType, strconv.Itoa(version)).Inc()
+	}
+}
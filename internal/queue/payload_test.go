@@ -0,0 +1,101 @@
+package queue
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hibiken/asynq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// loadPayloadFixture reads a captured task payload from testdata
+func loadPayloadFixture(t *testing.T, name string) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	require.NoError(t, err)
+	return data
+}
+
+func TestDecodeProcessDocumentPayloadV1(t *testing.T) {
+	payload, version, err := DecodeProcessDocumentPayload(loadPayloadFixture(t, "payloads_v1/process_document.json"))
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, version)
+	assert.Equal(t, PayloadVersion, payload.PayloadVersion)
+	assert.Equal(t, "20250115103000-123456", payload.AnalysisID)
+	assert.Equal(t, "Climate scientists reported record temperatures across Europe this summer.", payload.Text)
+	assert.NotEmpty(t, payload.OriginalHTML)
+	assert.Equal(t, []string{"https://example.com/heatwave.jpg", "https://example.com/chart.png"}, payload.Images)
+	assert.Equal(t, "news", payload.Options.Source)
+	require.NotNil(t, payload.Options.EnrichmentThreshold)
+	assert.Equal(t, 0.35, *payload.Options.EnrichmentThreshold)
+	assert.Equal(t, PriorityNormal, payload.Options.Priority)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", payload.TraceID)
+	assert.Equal(t, "00f067aa0ba902b7", payload.SpanID)
+	assert.Equal(t, int64(1736937000123456789), payload.EnqueuedAt)
+}
+
+func TestDecodeEnrichTextPayloadV1(t *testing.T) {
+	payload, version, err := DecodeEnrichTextPayload(loadPayloadFixture(t, "payloads_v1/enrich_text.json"))
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, version)
+	assert.Equal(t, PayloadVersion, payload.PayloadVersion)
+	assert.Equal(t, "20250115103000-123456", payload.AnalysisID)
+	assert.Equal(t, "Climate scientists reported record temperatures across Europe this summer.", payload.Text)
+	assert.Equal(t, "Climate scientists reported record temperatures across Europe.", payload.OfflineText)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", payload.TraceID)
+	assert.Equal(t, int64(1736937001123456789), payload.EnqueuedAt)
+}
+
+func TestDecodeEnrichImagePayloadV1(t *testing.T) {
+	payload, version, err := DecodeEnrichImagePayload(loadPayloadFixture(t, "payloads_v1/enrich_image.json"))
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, version)
+	assert.Equal(t, PayloadVersion, payload.PayloadVersion)
+	assert.Equal(t, "20250115103000-123456", payload.AnalysisID)
+	assert.Equal(t, "https://example.com/chart.png", payload.ImageURL)
+	assert.Equal(t, 1, payload.ImageIndex)
+	assert.Equal(t, "00f067aa0ba902b7", payload.SpanID)
+	assert.Equal(t, int64(1736937002123456789), payload.EnqueuedAt)
+}
+
+func TestDecodeCurrentPayload(t *testing.T) {
+	data, err := json.Marshal(EnrichImagePayload{
+		PayloadVersion: PayloadVersion,
+		AnalysisID:     "doc-1",
+		ImageURL:       "https://example.com/a.jpg",
+		ImageIndex:     3,
+	})
+	require.NoError(t, err)
+
+	payload, version, err := DecodeEnrichImagePayload(data)
+	require.NoError(t, err)
+	assert.Equal(t, PayloadVersion, version)
+	assert.Equal(t, 3, payload.ImageIndex)
+}
+
+func TestDecodeUnsupportedPayloadVersion(t *testing.T) {
+	for _, version := range []int{0, PayloadVersion + 1} {
+		data, err := json.Marshal(map[string]interface{}{"payload_version": version, "analysis_id": "doc-1"})
+		require.NoError(t, err)
+
+		_, decoded, err := DecodeEnrichTextPayload(data)
+		require.Error(t, err, "version %d", version)
+		assert.Equal(t, version, decoded)
+		assert.True(t, errors.Is(err, asynq.SkipRetry), "unsupported versions should not be retried")
+		assert.Contains(t, err.Error(), "unsupported payload version")
+	}
+}
+
+func TestDecodeInvalidPayload(t *testing.T) {
+	_, _, err := DecodeProcessDocumentPayload([]byte("not json"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid task payload")
+}
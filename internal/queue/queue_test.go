@@ -1,13 +1,24 @@
 package queue
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/hibiken/asynq"
+	"github.com/docutag/textanalyzer/internal/analyzer"
+	"github.com/docutag/textanalyzer/internal/database"
+	"github.com/docutag/textanalyzer/internal/models"
+	"github.com/docutag/textanalyzer/internal/ollama"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // TestProcessDocumentPayload tests the ProcessDocumentPayload structure
@@ -104,6 +115,11 @@ func TestIsRetriableOllamaError(t *testing.T) {
 			err:      errors.New("network is unreachable"),
 			expected: true,
 		},
+		{
+			name:     "Circuit breaker open",
+			err:      fmt.Errorf("image description failed: %w", ollama.ErrCircuitOpen),
+			expected: true,
+		},
 		{
 			name:     "Invalid request error",
 			err:      errors.New("invalid request format"),
@@ -207,3 +223,531 @@ func TestTaskTypeConstants(t *testing.T) {
 	assert.Equal(t, "textanalyzer:enrich_text", TypeEnrichText)
 	assert.Equal(t, "textanalyzer:enrich_image", TypeEnrichImage)
 }
+
+// TestEnrichmentThreshold tests the threshold carried in task options
+func TestEnrichmentThreshold(t *testing.T) {
+	assert.Equal(t, analyzer.DefaultEnrichmentThreshold, enrichmentThreshold(models.ProcessingOptions{}))
+
+	override := 0.0
+	assert.Equal(t, 0.0, enrichmentThreshold(models.ProcessingOptions{EnrichmentThreshold: &override}))
+
+	// Options survive the payload round trip
+	payload := ProcessDocumentPayload{
+		AnalysisID: "test-threshold",
+		Options:    models.ProcessingOptions{Source: "memo", EnrichmentThreshold: &override},
+	}
+	data, err := json.Marshal(payload)
+	assert.NoError(t, err)
+
+	var decoded ProcessDocumentPayload
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, "memo", decoded.Options.Source)
+	assert.Equal(t, 0.0, enrichmentThreshold(decoded.Options))
+}
+
+// TestEnrichmentOverridesPropagate tests that per-request enrichment gating
+// reaches the worker through the enqueued payload
+func TestEnrichmentOverridesPropagate(t *testing.T) {
+	threshold := 0.8
+	tests := []struct {
+		name    string
+		options models.ProcessingOptions
+	}{
+		{"threshold", models.ProcessingOptions{EnrichmentThreshold: &threshold}},
+		{"force", models.ProcessingOptions{EnrichmentThreshold: &threshold, ForceEnrichment: true}},
+		{"skip", models.ProcessingOptions{EnrichmentThreshold: &threshold, SkipEnrichment: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enqueuer := &fakeEnqueuer{queues: map[string]string{}, payloads: map[string][]byte{}}
+			client := &Client{client: enqueuer}
+
+			_, err := client.EnqueueProcessDocument(context.Background(), "analysis-1", "text", "", nil, tt.options)
+			require.NoError(t, err)
+
+			payload, _, err := DecodeProcessDocumentPayload(enqueuer.payloads[TypeProcessDocument])
+			require.NoError(t, err)
+			assert.Equal(t, threshold, enrichmentThreshold(payload.Options))
+			assert.Equal(t, tt.options.ForceEnrichment, payload.Options.ForceEnrichment)
+			assert.Equal(t, tt.options.SkipEnrichment, payload.Options.SkipEnrichment)
+		})
+	}
+}
+
+// TestMarkSkipEnrichment tests that a submission asking for no enrichment
+// records every step as disabled
+func TestMarkSkipEnrichment(t *testing.T) {
+	var metadata models.Metadata
+	markSkipEnrichment(&metadata)
+
+	assert.True(t, metadata.SkipEnrichment)
+	assert.Len(t, metadata.EnrichmentStatus, len(analyzer.EnrichmentSteps))
+	for step, status := range metadata.EnrichmentStatus {
+		assert.Equal(t, analyzer.StepStatusSkippedDisabled, status, step)
+	}
+	assert.Empty(t, metadata.Events)
+}
+
+// fakeEnqueuer records the queue, retention and payload each task is
+// enqueued with
+type fakeEnqueuer struct {
+	queues    map[string]string        // task type -> queue
+	retention map[string]time.Duration // task type -> retention, when not nil
+	payloads  map[string][]byte        // task type -> payload, when not nil
+}
+
+func (f *fakeEnqueuer) Enqueue(task *asynq.Task, opts ...asynq.Option) (*asynq.TaskInfo, error) {
+	if f.payloads != nil {
+		f.payloads[task.Type()] = task.Payload()
+	}
+	for _, opt := range opts {
+		switch opt.Type() {
+		case asynq.QueueOpt:
+			f.queues[task.Type()] = opt.Value().(string)
+		case asynq.RetentionOpt:
+			if f.retention != nil {
+				f.retention[task.Type()] = opt.Value().(time.Duration)
+			}
+		}
+	}
+	return &asynq.TaskInfo{ID: "task-id"}, nil
+}
+
+func (f *fakeEnqueuer) Close() error { return nil }
+
+// TestClientQueueSelection tests that every stage is enqueued on the queue for its priority
+func TestClientQueueSelection(t *testing.T) {
+	tests := []struct {
+		priority string
+		process  string
+		text     string
+		image    string
+	}{
+		{PriorityHigh, "offline-processing-high", "text-enrichment-high", "image-enrichment-high"},
+		{PriorityNormal, "offline-processing", "text-enrichment", "image-enrichment"},
+		{PriorityLow, "offline-processing-low", "text-enrichment-low", "image-enrichment-low"},
+		{"", "offline-processing", "text-enrichment", "image-enrichment"},
+	}
+
+	for _, tt := range tests {
+		t.Run("priority="+tt.priority, func(t *testing.T) {
+			enqueuer := &fakeEnqueuer{queues: map[string]string{}}
+			client := &Client{client: enqueuer}
+			ctx := context.Background()
+
+			_, err := client.EnqueueProcessDocument(ctx, "analysis-1", "text", "", nil, models.ProcessingOptions{Priority: tt.priority})
+			assert.NoError(t, err)
+			_, err = client.EnqueueEnrichText(ctx, "analysis-1", "text", "offline", "", tt.priority, false)
+			assert.NoError(t, err)
+			_, err = client.EnqueueEnrichImage(ctx, "analysis-1", "https://example.com/a.jpg", 0, tt.priority)
+			assert.NoError(t, err)
+
+			assert.Equal(t, tt.process, enqueuer.queues[TypeProcessDocument])
+			assert.Equal(t, tt.text, enqueuer.queues[TypeEnrichText])
+			assert.Equal(t, tt.image, enqueuer.queues[TypeEnrichImage])
+		})
+	}
+}
+
+// TestClientRedactedTextRetention tests that tasks carrying text that must not
+// be stored are deleted on completion instead of being kept for a week
+func TestClientRedactedTextRetention(t *testing.T) {
+	for _, redact := range []bool{false, true} {
+		enqueuer := &fakeEnqueuer{queues: map[string]string{}, retention: map[string]time.Duration{}}
+		client := &Client{client: enqueuer}
+		ctx := context.Background()
+
+		_, err := client.EnqueueProcessDocument(ctx, "analysis-1", "text", "", nil, models.ProcessingOptions{RedactText: redact})
+		assert.NoError(t, err)
+		_, err = client.EnqueueEnrichText(ctx, "analysis-1", "text", "offline", "", PriorityNormal, redact)
+		assert.NoError(t, err)
+
+		want := 7 * 24 * time.Hour
+		if redact {
+			want = 0
+		}
+		assert.Equal(t, want, enqueuer.retention[TypeProcessDocument], "redact=%v", redact)
+		assert.Equal(t, want, enqueuer.retention[TypeEnrichText], "redact=%v", redact)
+	}
+}
+
+// failingEnqueuer fails process document tasks of the analyses in fail and
+// records the payloads of the others; it is safe for concurrent use
+type failingEnqueuer struct {
+	fail     map[string]bool
+	mu       sync.Mutex
+	payloads map[string]ProcessDocumentPayload
+}
+
+func (f *failingEnqueuer) Enqueue(task *asynq.Task, opts ...asynq.Option) (*asynq.TaskInfo, error) {
+	var payload ProcessDocumentPayload
+	if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+		return nil, err
+	}
+	if f.fail[payload.AnalysisID] {
+		return nil, asynq.ErrTaskIDConflict
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.payloads[payload.AnalysisID] = payload
+	return &asynq.TaskInfo{ID: ProcessDocumentTaskID(payload.AnalysisID)}, nil
+}
+
+func (f *failingEnqueuer) Close() error { return nil }
+
+// TestClientEnqueueBatch tests that a batch reports each document's outcome
+// in submission order and that one failure does not stop the others
+func TestClientEnqueueBatch(t *testing.T) {
+	enqueuer := &failingEnqueuer{
+		fail:     map[string]bool{"analysis-2": true},
+		payloads: map[string]ProcessDocumentPayload{},
+	}
+	client := &Client{client: enqueuer}
+
+	var docs []ProcessDocumentRequest
+	for i := 0; i < 20; i++ {
+		docs = append(docs, ProcessDocumentRequest{
+			AnalysisID: fmt.Sprintf("analysis-%d", i),
+			Text:       fmt.Sprintf("text %d", i),
+			Options:    models.ProcessingOptions{Priority: PriorityLow},
+		})
+	}
+
+	results := client.EnqueueProcessDocumentBatch(context.Background(), docs)
+	require.Len(t, results, len(docs))
+	for i, result := range results {
+		if i == 2 {
+			assert.ErrorIs(t, result.Err, asynq.ErrTaskIDConflict)
+			assert.Empty(t, result.TaskID)
+			continue
+		}
+		require.NoError(t, result.Err)
+		assert.Equal(t, ProcessDocumentTaskID(docs[i].AnalysisID), result.TaskID)
+
+		payload := enqueuer.payloads[docs[i].AnalysisID]
+		assert.Equal(t, docs[i].Text, payload.Text)
+		assert.Equal(t, PriorityLow, payload.Options.Priority)
+	}
+	assert.Len(t, enqueuer.payloads, len(docs)-1)
+}
+
+// TestParsePriority tests priority validation and defaulting
+func TestParsePriority(t *testing.T) {
+	for _, valid := range []string{PriorityHigh, PriorityNormal, PriorityLow} {
+		priority, err := ParsePriority(valid)
+		assert.NoError(t, err)
+		assert.Equal(t, valid, priority)
+	}
+
+	priority, err := ParsePriority("")
+	assert.NoError(t, err)
+	assert.Equal(t, PriorityNormal, priority)
+
+	for _, invalid := range []string{"urgent", "HIGH", " low"} {
+		_, err := ParsePriority(invalid)
+		assert.Error(t, err, "priority %q should be rejected", invalid)
+	}
+
+	// Tasks enqueued before priorities existed run at normal priority
+	assert.Equal(t, PriorityNormal, processingPriority(models.ProcessingOptions{}))
+	assert.Equal(t, PriorityHigh, processingPriority(models.ProcessingOptions{Priority: PriorityHigh}))
+}
+
+// TestQueueWeights tests that the worker serves every priority queue and
+// weights high above normal above low within each stage
+func TestQueueWeights(t *testing.T) {
+	for _, base := range []string{queueTextEnrichment, queueOfflineProcessing, queueImageEnrichment} {
+		high, ok := queueWeights[queueName(base, PriorityHigh)]
+		assert.True(t, ok, "missing high priority queue for %s", base)
+		normal, ok := queueWeights[queueName(base, PriorityNormal)]
+		assert.True(t, ok, "missing normal priority queue for %s", base)
+		low, ok := queueWeights[queueName(base, PriorityLow)]
+		assert.True(t, ok, "missing low priority queue for %s", base)
+
+		assert.Greater(t, high, normal, "%s: high should outweigh normal", base)
+		assert.Greater(t, normal, low, "%s: normal should outweigh low", base)
+	}
+
+	// Existing normal-priority weights are unchanged
+	assert.Equal(t, 7, queueWeights["text-enrichment"])
+	assert.Equal(t, 5, queueWeights["offline-processing"])
+	assert.Equal(t, 3, queueWeights["image-enrichment"])
+	assert.Len(t, queueWeights, 9)
+}
+
+// TestMergeEnrichmentRevisions tests that re-enrichment keeps the previous AI results as a revision
+func TestMergeEnrichmentRevisions(t *testing.T) {
+	analysis := &models.Analysis{
+		ID:   "analysis-revisions",
+		Text: "The council approved a transit plan.",
+		Metadata: models.Metadata{
+			Tags:         []string{"offline"},
+			QualityScore: &models.TextQualityScore{Score: 0.6},
+		},
+	}
+
+	// First enrichment replaces offline results; there is nothing to keep
+	first := models.Metadata{
+		Synopsis:     "The council approved a transit plan.",
+		CleanedText:  "The council approved a transit plan.",
+		Tags:         []string{"transit", "council", "budget"},
+		AIDetection:  models.AIDetectionResult{Likelihood: "unlikely"},
+		Editorial:    &models.EditorialResult{Purpose: "informational", Bias: "none"},
+		Chunking:     &models.Chunking{Chunks: 3},
+		AIModel:      "model-a-small",
+		QualityScore: &models.TextQualityScore{Score: 0.7},
+		References:   []models.Reference{{Text: "twelve routes", Type: "statistic"}},
+	}
+	assert.Nil(t, mergeEnrichment(analysis, first, "model-a"))
+	assert.Equal(t, first.Editorial, analysis.Metadata.Editorial)
+	assert.Equal(t, first.QualityScore, analysis.Metadata.QualityScore)
+	assert.Equal(t, first.References, analysis.Metadata.References)
+	assert.Equal(t, "model-a", analysis.Metadata.EnrichmentModel)
+	assert.Equal(t, "model-a-small", analysis.Metadata.AIModel)
+	assert.Equal(t, first.Chunking, analysis.Metadata.Chunking)
+
+	// Re-enrichment with a newer model snapshots the model-a results
+	second := models.Metadata{
+		Synopsis:     "The city council approved a new transit plan.",
+		CleanedText:  "The city council approved a new transit plan.",
+		Tags:         []string{"transit", "council", "infrastructure"},
+		AIDetection:  models.AIDetectionResult{Likelihood: "very_unlikely"},
+		QualityScore: &models.TextQualityScore{Score: 0.85},
+		References:   []models.Reference{{Text: "fourteen routes", Type: "statistic"}},
+	}
+	revision := mergeEnrichment(analysis, second, "model-b")
+	if assert.NotNil(t, revision) {
+		assert.Equal(t, "analysis-revisions", revision.AnalysisID)
+		assert.Equal(t, "model-a", revision.Model)
+		assert.Equal(t, first.Synopsis, revision.Fields.Synopsis)
+		assert.Equal(t, first.Tags, revision.Fields.Tags)
+		assert.Equal(t, first.Editorial, revision.Fields.Editorial)
+		assert.Equal(t, 0.7, revision.Fields.QualityScore.Score)
+	}
+	assert.Equal(t, second.Synopsis, analysis.Metadata.Synopsis)
+	assert.Equal(t, second.QualityScore, analysis.Metadata.QualityScore)
+	assert.Equal(t, second.References, analysis.Metadata.References)
+	assert.Equal(t, "model-b", analysis.Metadata.EnrichmentModel)
+	assert.Nil(t, analysis.Metadata.Chunking)
+
+	// The snapshot is independent of later changes to the analysis
+	analysis.Metadata.Tags[0] = "changed"
+	assert.Equal(t, "transit", revision.Fields.Tags[0])
+	analysis.Metadata.Tags[0] = "transit"
+
+	diff := analyzer.DiffRevisionFields(revision.Fields, analyzer.SnapshotRevisionFields(analysis.Metadata))
+	assert.Equal(t, []string{"infrastructure"}, diff.TagsAdded)
+	assert.Equal(t, []string{"budget"}, diff.TagsRemoved)
+	assert.True(t, diff.Synopsis.Changed)
+	assert.Equal(t, []analyzer.TextEdit{
+		{Op: analyzer.EditEqual, Text: "The"},
+		{Op: analyzer.EditInsert, Text: "city"},
+		{Op: analyzer.EditEqual, Text: "council approved a"},
+		{Op: analyzer.EditInsert, Text: "new"},
+		{Op: analyzer.EditEqual, Text: "transit plan."},
+	}, diff.Synopsis.Edits)
+	assert.Equal(t, analyzer.StringChange{Old: "unlikely", New: "very_unlikely", Changed: true}, diff.AILikelihood)
+	if assert.NotNil(t, diff.QualityScore.Old) && assert.NotNil(t, diff.QualityScore.New) && assert.NotNil(t, diff.QualityScore.Delta) {
+		assert.Equal(t, 0.7, *diff.QualityScore.Old)
+		assert.Equal(t, 0.85, *diff.QualityScore.New)
+		assert.InDelta(t, 0.15, *diff.QualityScore.Delta, 1e-9)
+	}
+}
+
+func TestMergeEnrichmentSkippedSteps(t *testing.T) {
+	analysis := &models.Analysis{
+		ID:       "analysis-skipped",
+		Metadata: models.Metadata{Tags: []string{"offline"}},
+	}
+
+	// Enrichment with every step disabled leaves no AI fields but still completes
+	skipped := analyzer.SkippedSteps(models.EnrichmentOptions{})
+	assert.Nil(t, mergeEnrichment(analysis, models.Metadata{SkippedSteps: skipped}, "model-a"))
+	assert.Equal(t, skipped, analysis.Metadata.SkippedSteps)
+	assert.NotNil(t, analysis.Metadata.EnrichedAt)
+	assert.Equal(t, []string{"offline"}, analysis.Metadata.Tags)
+	assert.True(t, isEnriched(analysis.Metadata))
+
+	// A later run is recognized as re-enrichment even though no fields were set
+	revision := mergeEnrichment(analysis, models.Metadata{Synopsis: "A synopsis."}, "model-b")
+	assert.NotNil(t, revision)
+	assert.Empty(t, analysis.Metadata.SkippedSteps)
+}
+
+func TestMergeEnrichmentStatus(t *testing.T) {
+	analysis := &models.Analysis{ID: "analysis-status"}
+
+	failed := map[string]string{
+		analyzer.StepSynopsis:  analyzer.StepStatusFallback,
+		analyzer.StepEditorial: analyzer.StepStatusFailed,
+	}
+	mergeEnrichment(analysis, models.Metadata{EnrichmentStatus: failed}, "model-a")
+	assert.Equal(t, failed, analysis.Metadata.EnrichmentStatus)
+
+	// Re-enrichment updates the recorded outcome of each step it ran
+	mergeEnrichment(analysis, models.Metadata{
+		EnrichmentStatus: map[string]string{analyzer.StepEditorial: analyzer.StepStatusDone},
+	}, "model-a")
+	assert.Equal(t, map[string]string{
+		analyzer.StepSynopsis:  analyzer.StepStatusFallback,
+		analyzer.StepEditorial: analyzer.StepStatusDone,
+	}, analysis.Metadata.EnrichmentStatus)
+}
+
+func TestMergeEnrichmentProvenance(t *testing.T) {
+	analysis := &models.Analysis{ID: "analysis-provenance"}
+	a := analyzer.New()
+	a.SetServiceVersion("1.2.3")
+
+	opts := analyzer.AnalysisOptions{Threshold: 0.4, Synopsis: analyzer.SynopsisOptions{Style: "teaser"}}
+	first := a.Provenance(opts)
+	mergeEnrichment(analysis, models.Metadata{Synopsis: "A synopsis.", Provenance: first}, a.ModelName())
+	require.NotNil(t, analysis.Metadata.Provenance)
+	assert.Equal(t, 0.4, analysis.Metadata.Provenance.EnrichmentThreshold)
+	assert.Equal(t, "teaser", analysis.Metadata.Provenance.SynopsisStyle)
+	assert.Equal(t, "1.2.3", analysis.Metadata.Provenance.ServiceVersion)
+
+	// Re-enrichment replaces the snapshot with the configuration it ran under
+	second := a.Provenance(analyzer.AnalysisOptions{Threshold: 0.5})
+	mergeEnrichment(analysis, models.Metadata{Synopsis: "A new synopsis.", Provenance: second}, a.ModelName())
+	assert.Equal(t, second, analysis.Metadata.Provenance)
+	assert.Equal(t, []string{"enrichment_threshold", "synopsis_style"}, analyzer.ProvenanceChanges(first, second))
+}
+
+// TestRedactText tests that redaction keeps a hash of the text and drops the
+// text, the original HTML and, on request, the cleaned text
+func TestRedactText(t *testing.T) {
+	text := "The council approved a transit plan."
+	for _, dropCleanedText := range []bool{false, true} {
+		analysis := &models.Analysis{
+			ID:           "analysis-redacted",
+			Text:         text,
+			OriginalHTML: "H4sIAAAAAAAA",
+			Metadata: models.Metadata{
+				CleanedText: text,
+				Tags:        []string{"transit"},
+			},
+		}
+
+		redactText(analysis, text, dropCleanedText)
+		assert.Empty(t, analysis.Text)
+		assert.Empty(t, analysis.OriginalHTML)
+		assert.Equal(t, []string{"transit"}, analysis.Metadata.Tags)
+		if assert.NotNil(t, analysis.Metadata.Redaction) {
+			// sha256 of the text, as printed by sha256sum
+			assert.Equal(t, "8dc1292a569d1968da194087e570b4a28d3212f0ac986c2e350e811ef02189cf", analysis.Metadata.Redaction.TextSHA256)
+			assert.Equal(t, dropCleanedText, analysis.Metadata.Redaction.CleanedTextDropped)
+		}
+
+		// Enrichment keeps the cleaned and translated texts only when they may be stored
+		mergeEnrichment(analysis, models.Metadata{Synopsis: "A plan.", CleanedText: text, TranslatedText: "Un plan."}, "model-a")
+		assert.Equal(t, "A plan.", analysis.Metadata.Synopsis)
+		if dropCleanedText {
+			assert.Empty(t, analysis.Metadata.CleanedText)
+			assert.Empty(t, analysis.Metadata.TranslatedText)
+		} else {
+			assert.Equal(t, text, analysis.Metadata.CleanedText)
+			assert.Equal(t, "Un plan.", analysis.Metadata.TranslatedText)
+		}
+	}
+}
+
+// TestRedactTextStoresNoText tests that no stored field of a redacted
+// analysis holds the text, from offline analysis through enrichment
+func TestRedactTextStoresNoText(t *testing.T) {
+	text := "The council approved a transit plan on Monday.\n\nThe plan costs $5 million, according to the mayor."
+	a := analyzer.New()
+	analysis := &models.Analysis{
+		ID:           "analysis-redacted",
+		Text:         text,
+		OriginalHTML: "H4sIAAAAAAAA",
+		Metadata:     a.AnalyzeOffline(text),
+	}
+	analysis.Metadata.CleanedText = text
+	analysis.Metadata.HeuristicCleanedText = text
+	analysis.Metadata.TranslatedText = text
+	analysis.Metadata.References = append(analysis.Metadata.References, models.Reference{Text: "$5 million", Type: "statistic", Context: text})
+
+	redactText(analysis, text, true)
+	mergeEnrichment(analysis, models.Metadata{
+		Synopsis:       "A plan.",
+		CleanedText:    text,
+		TranslatedText: text,
+		References:     []models.Reference{{Text: "$5 million", Type: "statistic", Context: text}},
+	}, "model-a")
+
+	stored, err := json.Marshal(analysis)
+	require.NoError(t, err)
+	for _, paragraph := range strings.Split(text, "\n\n") {
+		assert.NotContains(t, string(stored), paragraph)
+	}
+}
+
+// TestSelectImages tests that the worker caps, dedupes and validates images and records what it skipped
+func TestSelectImages(t *testing.T) {
+	now := time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC)
+	images := []string{
+		"https://example.com/a.jpg",
+		"https://example.com/a.jpg",
+		"data:image/png;base64,AAAA",
+		"https://example.com/b.jpg",
+		"https://example.com/c.jpg",
+		"https://example.com/d.jpg",
+	}
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	var metadata models.Metadata
+	accepted := selectImages(logger, &metadata, images, models.ProcessingOptions{ImagesSubmitted: 8}, 2, now)
+
+	assert.Equal(t, []string{"https://example.com/a.jpg", "https://example.com/b.jpg"}, accepted)
+	assert.Equal(t, &models.ImageCounts{Submitted: 8, Accepted: 2, Skipped: 6}, metadata.Images)
+	if assert.Len(t, metadata.Events, 1) {
+		assert.Equal(t, models.AnalysisEvent{
+			Type:      models.EventImagesSkipped,
+			Message:   "Skipped 6 of 8 images (2 dropped at submission, 1 invalid, 1 duplicate, 2 over the limit of 2)",
+			CreatedAt: now,
+		}, metadata.Events[0])
+	}
+	// The invalid URL is logged, not only counted
+	assert.Contains(t, logs.String(), "data:image/png;base64,AAAA")
+
+	// Nothing is skipped within the limit
+	metadata = models.Metadata{}
+	accepted = selectImages(logger, &metadata, images[3:], models.ProcessingOptions{ImagesSubmitted: 3}, 5, now)
+	assert.Len(t, accepted, 3)
+	assert.Equal(t, &models.ImageCounts{Submitted: 3, Accepted: 3}, metadata.Images)
+	assert.Empty(t, metadata.Events)
+
+	// Documents without images report no counts
+	metadata = models.Metadata{}
+	assert.Empty(t, selectImages(logger, &metadata, nil, models.ProcessingOptions{}, 5, now))
+	assert.Nil(t, metadata.Images)
+}
+
+// TestMarkOfflineOnly tests that a degraded submission records every step as skipped with an event
+func TestMarkOfflineOnly(t *testing.T) {
+	now := time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC)
+
+	var metadata models.Metadata
+	markOfflineOnly(&metadata, now)
+
+	assert.True(t, metadata.OfflineOnly)
+	assert.Len(t, metadata.EnrichmentStatus, len(analyzer.EnrichmentSteps))
+	for step, status := range metadata.EnrichmentStatus {
+		assert.Equal(t, analyzer.StepStatusSkippedDisabled, status, step)
+	}
+	if assert.Len(t, metadata.Events, 1) {
+		assert.Equal(t, models.EventOfflineOnly, metadata.Events[0].Type)
+		assert.Equal(t, now, metadata.Events[0].CreatedAt)
+	}
+}
+
+// TestTextHash tests that a resubmission is compared by the hash carried by
+// its submission, or by hashing its text
+func TestTextHash(t *testing.T) {
+	assert.Equal(t, "carried", textHash(&models.Analysis{TextHash: "carried"}, "Some text."))
+	assert.Equal(t, database.TextHash("Some  text."), textHash(&models.Analysis{}, "Some  text."))
+	assert.Equal(t, textHash(&models.Analysis{}, "Some text."), textHash(&models.Analysis{}, " Some\ntext. "), "whitespace does not change the hash")
+}
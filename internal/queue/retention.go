@@ -0,0 +1,183 @@
+package queue
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Retention defaults
+const (
+	DefaultRetentionMinQuality = 0.35
+	DefaultRetentionInterval   = time.Hour
+	DefaultRetentionBatchSize  = 500
+	DefaultRetentionMaxPerRun  = 10000
+)
+
+// RetentionConfig controls which analyses a RetentionJob prunes and how fast
+type RetentionConfig struct {
+	// Days is the age after which analyses may be pruned; 0 disables pruning
+	Days int
+	// MinQuality is the quality score at or above which analyses are kept
+	MinQuality float64
+	// Interval is the time between runs (default: DefaultRetentionInterval)
+	Interval time.Duration
+	// BatchSize is how many analyses are deleted per transaction
+	// (default: DefaultRetentionBatchSize)
+	BatchSize int
+	// MaxPerRun caps the analyses deleted by one run
+	// (default: DefaultRetentionMaxPerRun)
+	MaxPerRun int
+}
+
+// RetentionStore deletes prunable analyses, as database.DB does
+type RetentionStore interface {
+	PruneAnalyses(ctx context.Context, cutoff time.Time, minQuality float64, limit int) (deleted int, locked bool, err error)
+}
+
+// RetentionJob periodically deletes old analyses that were never enriched
+// and score below a quality threshold. Each batch runs under a database
+// advisory lock, so several instances can run the job at once.
+type RetentionJob struct {
+	store  RetentionStore
+	cfg    RetentionConfig
+	logger *slog.Logger
+	pruned prometheus.Counter
+
+	// now and newTicker are replaceable for tests
+	now       func() time.Time
+	newTicker func(d time.Duration) (<-chan time.Time, func())
+
+	mu      sync.Mutex
+	cancel  context.CancelFunc
+	done    chan struct{}
+	running bool
+}
+
+// NewRetentionJob creates a job pruning through store and registers its
+// counter with registerer
+func NewRetentionJob(store RetentionStore, cfg RetentionConfig, registerer prometheus.Registerer, logger *slog.Logger) *RetentionJob {
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultRetentionInterval
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultRetentionBatchSize
+	}
+	if cfg.MaxPerRun <= 0 {
+		cfg.MaxPerRun = DefaultRetentionMaxPerRun
+	}
+
+	pruned := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "textanalyzer_retention_pruned_total",
+		Help: "Analyses deleted by the retention job",
+	})
+	if err := registerer.Register(pruned); err != nil {
+		if existing, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			pruned = existing.ExistingCollector.(prometheus.Counter)
+		} else {
+			logger.Warn("failed to register retention counter", "error", err)
+		}
+	}
+
+	return &RetentionJob{
+		store:  store,
+		cfg:    cfg,
+		logger: logger,
+		pruned: pruned,
+		now:    time.Now,
+		newTicker: func(d time.Duration) (<-chan time.Time, func()) {
+			ticker := time.NewTicker(d)
+			return ticker.C, ticker.Stop
+		},
+	}
+}
+
+// Start runs the job in the background, once right away and then every
+// interval until Stop is called. It does nothing when Days is 0.
+func (j *RetentionJob) Start() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.running || j.cfg.Days <= 0 {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	j.running = true
+	j.cancel = cancel
+	j.done = make(chan struct{})
+
+	ticks, stopTicker := j.newTicker(j.cfg.Interval)
+
+	go func() {
+		defer close(j.done)
+		defer stopTicker()
+		j.run(ctx)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticks:
+				j.run(ctx)
+			}
+		}
+	}()
+}
+
+// Stop halts the job, cancelling a run in progress and waiting for it to
+// return. The batch being deleted is rolled back.
+func (j *RetentionJob) Stop() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if !j.running {
+		return
+	}
+
+	j.cancel()
+	<-j.done
+	j.running = false
+}
+
+// run prunes batches until none is left, MaxPerRun is reached, another
+// instance holds the lock or a batch fails, then logs a summary
+func (j *RetentionJob) run(ctx context.Context) {
+	start := j.now()
+	cutoff := start.AddDate(0, 0, -j.cfg.Days)
+
+	pruned, batches := 0, 0
+	outcome := "completed"
+	for {
+		if pruned >= j.cfg.MaxPerRun {
+			outcome = "capped"
+			break
+		}
+		limit := min(j.cfg.BatchSize, j.cfg.MaxPerRun-pruned)
+		deleted, locked, err := j.store.PruneAnalyses(ctx, cutoff, j.cfg.MinQuality, limit)
+		if err != nil {
+			outcome = "failed"
+			j.logger.Warn("retention batch failed", "error", err)
+			break
+		}
+		if !locked {
+			outcome = "locked"
+			break
+		}
+		batches++
+		pruned += deleted
+		j.pruned.Add(float64(deleted))
+		if deleted < limit {
+			break
+		}
+	}
+
+	j.logger.Info("retention run finished",
+		"outcome", outcome,
+		"pruned", pruned,
+		"batches", batches,
+		"cutoff", cutoff,
+		"min_quality", j.cfg.MinQuality,
+		"duration_ms", j.now().Sub(start).Milliseconds(),
+	)
+}
@@ -0,0 +1,154 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRetentionStore deletes from a count of prunable analyses
+type fakeRetentionStore struct {
+	mu         sync.Mutex
+	prunable   int
+	lockedOut  bool
+	err        error
+	limits     []int
+	cutoff     time.Time
+	minQuality float64
+}
+
+func (s *fakeRetentionStore) PruneAnalyses(_ context.Context, cutoff time.Time, minQuality float64, limit int) (int, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.limits = append(s.limits, limit)
+	s.cutoff, s.minQuality = cutoff, minQuality
+	if s.err != nil {
+		return 0, true, s.err
+	}
+	if s.lockedOut {
+		return 0, false, nil
+	}
+	deleted := min(limit, s.prunable)
+	s.prunable -= deleted
+	return deleted, true, nil
+}
+
+func newTestRetentionJob(store RetentionStore, cfg RetentionConfig) (*RetentionJob, prometheus.Counter) {
+	job := NewRetentionJob(store, cfg, prometheus.NewRegistry(), slog.Default())
+	now := time.Date(2025, 6, 30, 12, 0, 0, 0, time.UTC)
+	job.now = func() time.Time { return now }
+	return job, job.pruned
+}
+
+func TestRetentionRun(t *testing.T) {
+	tests := []struct {
+		name     string
+		prunable int
+		limits   []int
+		pruned   int
+		left     int
+	}{
+		{"nothing to prune", 0, []int{100}, 0, 0},
+		{"stops at a partial batch", 150, []int{100, 100}, 150, 0},
+		{"stops at an exact batch", 200, []int{100, 100, 50}, 200, 0},
+		{"capped per run", 1000, []int{100, 100, 50}, 250, 750},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeRetentionStore{prunable: tt.prunable}
+			job, counter := newTestRetentionJob(store, RetentionConfig{Days: 90, MinQuality: 0.35, BatchSize: 100, MaxPerRun: 250})
+
+			job.run(context.Background())
+
+			assert.Equal(t, tt.limits, store.limits)
+			assert.Equal(t, tt.left, store.prunable)
+			assert.Equal(t, float64(tt.pruned), testutil.ToFloat64(counter))
+			assert.Equal(t, time.Date(2025, 4, 1, 12, 0, 0, 0, time.UTC), store.cutoff)
+			assert.Equal(t, 0.35, store.minQuality)
+		})
+	}
+}
+
+func TestRetentionRunStops(t *testing.T) {
+	// Another instance holding the lock skips the run
+	store := &fakeRetentionStore{prunable: 500, lockedOut: true}
+	job, counter := newTestRetentionJob(store, RetentionConfig{Days: 30, BatchSize: 100})
+	job.run(context.Background())
+	assert.Len(t, store.limits, 1)
+	assert.Equal(t, 500, store.prunable)
+	assert.Equal(t, 0.0, testutil.ToFloat64(counter))
+
+	// A failed batch ends the run until the next one
+	store = &fakeRetentionStore{prunable: 500, err: errors.New("connection refused")}
+	job, _ = newTestRetentionJob(store, RetentionConfig{Days: 30, BatchSize: 100})
+	job.run(context.Background())
+	assert.Len(t, store.limits, 1)
+}
+
+func TestRetentionJobSchedule(t *testing.T) {
+	store := &fakeRetentionStore{prunable: 5}
+	job, counter := newTestRetentionJob(store, RetentionConfig{Days: 90})
+	ticks := make(chan time.Time)
+	job.newTicker = func(d time.Duration) (<-chan time.Time, func()) {
+		assert.Equal(t, DefaultRetentionInterval, d)
+		return ticks, func() {}
+	}
+
+	job.Start()
+	ticks <- time.Now() // Received once the first run is done
+	ticks <- time.Now()
+	job.Stop()
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	require.Len(t, store.limits, 3, "one run at start and one per tick")
+	assert.Equal(t, DefaultRetentionBatchSize, store.limits[0])
+	assert.Equal(t, 5.0, testutil.ToFloat64(counter))
+
+	// Without a retention window the job never runs
+	disabled := &fakeRetentionStore{prunable: 5}
+	job, _ = newTestRetentionJob(disabled, RetentionConfig{})
+	job.Start()
+	job.Stop()
+	assert.Empty(t, disabled.limits)
+}
+
+// blockingRetentionStore holds each batch until its context is cancelled
+type blockingRetentionStore struct {
+	started chan struct{}
+}
+
+func (s *blockingRetentionStore) PruneAnalyses(ctx context.Context, _ time.Time, _ float64, _ int) (int, bool, error) {
+	close(s.started)
+	<-ctx.Done()
+	return 0, true, ctx.Err()
+}
+
+func TestRetentionJobStopCancelsRun(t *testing.T) {
+	store := &blockingRetentionStore{started: make(chan struct{})}
+	job, counter := newTestRetentionJob(store, RetentionConfig{Days: 90})
+
+	job.Start()
+	<-store.started
+
+	stopped := make(chan struct{})
+	go func() {
+		job.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop did not cancel the run in progress")
+	}
+	assert.Equal(t, 0.0, testutil.ToFloat64(counter))
+}
@@ -0,0 +1,134 @@
+package queue
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/docutag/textanalyzer/internal/analyzer"
+	"github.com/docutag/textanalyzer/internal/models"
+	"github.com/docutag/textanalyzer/internal/promutil"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Shadow run outcomes, as counted
+const (
+	shadowCompared = "compared" // Shadow results stored and compared
+	shadowSkipped  = "skipped"  // Not sampled
+	shadowFailed   = "error"    // Shadow model or storage failed
+)
+
+// shadowTimeout bounds a shadow run, which outlives the enrichment task
+const shadowTimeout = 5 * time.Minute
+
+// ShadowStore persists the results of shadow enrichment
+type ShadowStore interface {
+	SaveShadowResult(ctx context.Context, analysisID string, result *models.ShadowResult) error
+}
+
+// shadowEnricher runs a sample of text enrichments against a candidate model
+// as well, storing its tags and quality score apart from the analysis and
+// exporting how far they agree with the primary model
+type shadowEnricher struct {
+	client     analyzer.LLMClient
+	sampleRate float64
+	analyzer   *analyzer.Analyzer
+	store      ShadowStore
+	logger     *slog.Logger
+
+	runs         *prometheus.CounterVec
+	tagJaccard   prometheus.Histogram
+	qualityDelta prometheus.Histogram
+
+	// sample is replaceable for tests
+	sample func() float64
+
+	pending sync.WaitGroup // Shadow runs started in the background
+}
+
+// newShadowEnricher creates a shadow enricher for client, or returns nil
+// when there is no shadow model or nothing is sampled
+func newShadowEnricher(client analyzer.LLMClient, sampleRate float64, analyzer *analyzer.Analyzer, store ShadowStore, registerer prometheus.Registerer, logger *slog.Logger) *shadowEnricher {
+	if client == nil || sampleRate <= 0 {
+		return nil
+	}
+
+	s := &shadowEnricher{
+		client:     client,
+		sampleRate: min(sampleRate, 1),
+		analyzer:   analyzer,
+		store:      store,
+		logger:     logger,
+		sample:     rand.Float64,
+	}
+	s.runs = promutil.Register(registerer, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "textanalyzer_shadow_runs_total",
+		Help: "Text enrichments considered for shadow enrichment, by outcome",
+	}, []string{"result"}))
+	s.tagJaccard = promutil.Register(registerer, prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "textanalyzer_shadow_tag_jaccard",
+		Help:    "Jaccard overlap of topic tags from the shadow and primary models",
+		Buckets: prometheus.LinearBuckets(0, 0.1, 11),
+	}))
+	s.qualityDelta = promutil.Register(registerer, prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "textanalyzer_shadow_quality_score_delta",
+		Help:    "Shadow model quality score minus the primary model quality score",
+		Buckets: prometheus.LinearBuckets(-1, 0.2, 11),
+	}))
+	return s
+}
+
+// start runs run in the background, detached from the enrichment task's
+// context so the candidate model never delays or times out the task
+func (s *shadowEnricher) start(ctx context.Context, analysis *models.Analysis, text string) {
+	s.pending.Add(1)
+	go func() {
+		defer s.pending.Done()
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shadowTimeout)
+		defer cancel()
+		s.run(ctx, analysis, text)
+	}()
+}
+
+// wait blocks until background shadow runs finish
+func (s *shadowEnricher) wait() {
+	s.pending.Wait()
+}
+
+// run shadows a sampled enrichment of analysis, whose metadata already holds
+// the primary model's results. Failures are logged and counted but never
+// fail the enrichment, and the analysis is not modified.
+func (s *shadowEnricher) run(ctx context.Context, analysis *models.Analysis, text string) {
+	if s.sample() >= s.sampleRate {
+		s.runs.WithLabelValues(shadowSkipped).Inc()
+		return
+	}
+
+	result, err := s.analyzer.ShadowEnrich(ctx, s.client, text, analysis.Metadata)
+	if err == nil {
+		err = s.store.SaveShadowResult(ctx, analysis.ID, result)
+	}
+	if err != nil {
+		s.runs.WithLabelValues(shadowFailed).Inc()
+		s.logger.Warn("shadow enrichment failed",
+			"analysis_id", analysis.ID,
+			"shadow_model", s.client.Model(),
+			"error", err,
+		)
+		return
+	}
+
+	s.runs.WithLabelValues(shadowCompared).Inc()
+	s.tagJaccard.Observe(result.TagJaccard)
+	if result.QualityScoreDelta != nil {
+		s.qualityDelta.Observe(*result.QualityScoreDelta)
+	}
+	s.logger.Info("shadow enrichment compared",
+		"analysis_id", analysis.ID,
+		"shadow_model", result.Model,
+		"tag_jaccard", result.TagJaccard,
+		"quality_score_delta", result.QualityScoreDelta,
+	)
+}
@@ -0,0 +1,166 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/docutag/textanalyzer/internal/analyzer"
+	"github.com/docutag/textanalyzer/internal/models"
+	"github.com/docutag/textanalyzer/internal/ollama"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const shadowTestText = "The city council approved the new transit plan after months of public hearings. " +
+	"The plan adds three bus routes and extends service hours on weekends. " +
+	"Funding will come from state grants and a modest increase in parking fees."
+
+// fakeModel serves an Ollama model that answers tag prompts with tags and
+// quality prompts with score, counting the requests it receives
+func fakeModel(t *testing.T, model string, tags []string, score float64) (*ollama.Client, *atomic.Int64) {
+	t.Helper()
+
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		var req struct {
+			Prompt string `json:"prompt"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		var response string
+		switch {
+		case strings.Contains(req.Prompt, "relevant tags"):
+			tagsJSON, _ := json.Marshal(tags)
+			response = string(tagsJSON)
+		case strings.Contains(req.Prompt, "content quality assessment"):
+			response = fmt.Sprintf(`{"score": %g, "reason": "fake", "categories": []}`, score)
+		default:
+			http.Error(w, `{"error":"unexpected prompt"}`, http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"model": model, "response": response, "done": true})
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := ollama.New(server.URL, model)
+	require.NoError(t, err)
+	return client, &requests
+}
+
+// fakeShadowStore keeps shadow results in memory
+type fakeShadowStore struct {
+	results map[string]*models.ShadowResult
+	err     error
+}
+
+func (s *fakeShadowStore) SaveShadowResult(_ context.Context, analysisID string, result *models.ShadowResult) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.results[analysisID] = result
+	return nil
+}
+
+// primaryAnalysis enriches shadowTestText with the primary model's tags and quality score
+func primaryAnalysis(t *testing.T, textAnalyzer *analyzer.Analyzer) *models.Analysis {
+	t.Helper()
+
+	metadata := textAnalyzer.AnalyzeWithOptions(context.Background(), shadowTestText, analyzer.AnalysisOptions{
+		Enrichment: &models.EnrichmentOptions{Tags: true, Quality: true},
+	})
+	require.NotNil(t, metadata.QualityScore)
+	require.True(t, metadata.QualityScore.AIUsed)
+	return &models.Analysis{ID: "analysis-1", Text: shadowTestText, Metadata: metadata}
+}
+
+func TestShadowEnricher(t *testing.T) {
+	primaryClient, _ := fakeModel(t, "primary", []string{"transit", "city-council"}, 0.8)
+	shadowClient, shadowRequests := fakeModel(t, "candidate", []string{"transit", "parking"}, 0.3)
+	textAnalyzer := analyzer.NewWithOllama(primaryClient)
+
+	analysis := primaryAnalysis(t, textAnalyzer)
+	assert.Contains(t, analysis.Metadata.Tags, "city-council")
+	before, err := json.Marshal(analysis.Metadata)
+	require.NoError(t, err)
+
+	store := &fakeShadowStore{results: map[string]*models.ShadowResult{}}
+	registry := prometheus.NewRegistry()
+	shadow := newShadowEnricher(shadowClient, 0.25, textAnalyzer, store, registry, slog.Default())
+	require.NotNil(t, shadow)
+
+	t.Run("not sampled", func(t *testing.T) {
+		shadow.sample = func() float64 { return 0.25 }
+		shadow.run(context.Background(), analysis, shadowTestText)
+
+		assert.Zero(t, shadowRequests.Load(), "unsampled enrichments should not call the shadow model")
+		assert.Empty(t, store.results)
+		assert.Equal(t, 1.0, testutil.ToFloat64(shadow.runs.WithLabelValues(shadowSkipped)))
+	})
+
+	t.Run("sampled", func(t *testing.T) {
+		shadow.sample = func() float64 { return 0.1 }
+		shadow.run(context.Background(), analysis, shadowTestText)
+
+		result := store.results[analysis.ID]
+		require.NotNil(t, result)
+		assert.Equal(t, "candidate", result.Model)
+		assert.Equal(t, "primary", result.PrimaryModel)
+		assert.Contains(t, result.Tags, "parking")
+		assert.NotContains(t, result.Tags, "city-council")
+		assert.Equal(t, analysis.Metadata.Tags, result.PrimaryTags)
+		// Both share the computed topic tags and "transit" but differ in one AI tag each
+		assert.InDelta(t, 4.0/6, result.TagJaccard, 1e-9)
+		require.NotNil(t, result.QualityScoreDelta)
+		assert.InDelta(t, -0.5, *result.QualityScoreDelta, 1e-9)
+		assert.Equal(t, 1.0, testutil.ToFloat64(shadow.runs.WithLabelValues(shadowCompared)))
+		assert.Equal(t, 1, testutil.CollectAndCount(shadow.tagJaccard))
+
+		// The primary results are untouched
+		after, err := json.Marshal(analysis.Metadata)
+		require.NoError(t, err)
+		assert.JSONEq(t, string(before), string(after))
+	})
+
+	t.Run("store failure", func(t *testing.T) {
+		store.err = errors.New("database unavailable")
+		shadow.sample = func() float64 { return 0 }
+		shadow.run(context.Background(), analysis, shadowTestText)
+
+		assert.Equal(t, 1.0, testutil.ToFloat64(shadow.runs.WithLabelValues(shadowFailed)))
+	})
+
+	t.Run("started after the task ends", func(t *testing.T) {
+		store.err = nil
+		delete(store.results, analysis.ID)
+		shadow.sample = func() float64 { return 0 }
+
+		// The task's context is cancelled as soon as the handler returns
+		ctx, cancel := context.WithCancel(context.Background())
+		shadow.start(ctx, analysis, shadowTestText)
+		cancel()
+		shadow.wait()
+
+		assert.NotNil(t, store.results[analysis.ID], "the shadow run should outlive the task's context")
+	})
+}
+
+func TestNewShadowEnricherDisabled(t *testing.T) {
+	client, _ := fakeModel(t, "candidate", nil, 0.5)
+	registry := prometheus.NewRegistry()
+	store := &fakeShadowStore{}
+
+	assert.Nil(t, newShadowEnricher(nil, 1, analyzer.New(), store, registry, slog.Default()))
+	assert.Nil(t, newShadowEnricher(client, 0, analyzer.New(), store, registry, slog.Default()))
+	assert.Equal(t, 1.0, newShadowEnricher(client, 5, analyzer.New(), store, registry, slog.Default()).sampleRate)
+}
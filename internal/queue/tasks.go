@@ -1,19 +1,25 @@
 package queue
 
 import (
-	"log/slog"
 	"bytes"
 	"compress/gzip"
 	"context"
+	"crypto/sha256"
 	"encoding/base64"
-	"encoding/json"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"strings"
 	"time"
 
 	"github.com/hibiken/asynq"
+	"github.com/docutag/textanalyzer/internal/analyzer"
+	"github.com/docutag/textanalyzer/internal/database"
 	"github.com/docutag/textanalyzer/internal/models"
+	"github.com/docutag/textanalyzer/internal/ollama"
+	"github.com/docutag/textanalyzer/internal/tags"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
@@ -22,17 +28,22 @@ import (
 // handleProcessDocument processes offline document analysis (Stage 1)
 func (w *Worker) handleProcessDocument(ctx context.Context, t *asynq.Task) error {
 	// Parse payload
-	var payload ProcessDocumentPayload
-	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
-		w.logger.Error("failed to unmarshal task payload", "error", err)
-		return fmt.Errorf("invalid task payload: %w", err)
+	payload, version, err := DecodeProcessDocumentPayload(t.Payload())
+	if err != nil {
+		w.logger.Error("failed to decode task payload", "payload_version", version, "error", err)
+		return err
 	}
+	w.observePayloadVersion(t.Type(), version)
 
 	analysisID := payload.AnalysisID
 	text := payload.Text
 	originalHTML := payload.OriginalHTML
 	images := payload.Images
 
+	if w.cancelled(ctx, analysisID) {
+		return nil
+	}
+
 	// Calculate queue wait time
 	var queueWaitTime time.Duration
 	if payload.EnqueuedAt > 0 {
@@ -101,31 +112,93 @@ func (w *Worker) handleProcessDocument(ctx context.Context, t *asynq.Task) error
 		}
 	}
 
-	// Perform offline analysis (rule-based, no Ollama)
-	metadata := w.analyzer.AnalyzeOffline(text)
+	// Perform offline analysis (rule-based, no Ollama), resolving relative
+	// dates against the creation time
+	createdAt := time.Now()
+	metadata := w.analyzer.AnalyzeOfflineWithOptions(text, analyzer.OfflineOptions{
+		Sections:      payload.Options.Sections,
+		ReferenceTime: createdAt,
+		Format:        payload.Options.Format,
+	})
+
+	// Record the threshold that gates AI enrichment so job status can report it
+	threshold := enrichmentThreshold(payload.Options)
+	metadata.Source = payload.Options.Source
+	metadata.Priority = processingPriority(payload.Options)
+	metadata.SynopsisStyle = payload.Options.SynopsisStyle
+	metadata.SynopsisMaxWords = payload.Options.SynopsisMaxWords
+	metadata.Enrichment = payload.Options.Enrichment
+	metadata.EnrichmentThreshold = &threshold
+	metadata.ForceEnrichment = payload.Options.ForceEnrichment
+	metadata.RedactPII = payload.Options.RedactPII
+	metadata.EnrichmentSkipped = !analyzer.PassesEnrichmentGate(metadata.QualityScore, threshold, metadata.ForceEnrichment)
+	if metadata.EnrichmentSkipped {
+		metadata.EnrichmentStatus = analyzer.EnrichmentStatusFor(analyzer.ResolveEnrichment(metadata.Enrichment), analyzer.StepStatusSkippedLowQuality)
+	}
+	if payload.Options.SkipEnrichment {
+		markSkipEnrichment(&metadata)
+	}
+	if payload.Options.OfflineOnly {
+		markOfflineOnly(&metadata, time.Now())
+	}
+
+	// Drop invalid and duplicate images and cap image enrichment tasks
+	images = selectImages(w.logger.With("analysis_id", analysisID), &metadata, images, payload.Options, w.maxImages, time.Now())
 
 	// Create analysis record with offline results
 	analysis := &models.Analysis{
-		ID:           analysisID,
-		Text:         text,
-		OriginalHTML: originalHTML,
-		Metadata:     metadata,
-		CreatedAt:    time.Now(),
-		UpdatedAt:    time.Now(),
+		ID:             analysisID,
+		Text:           text,
+		OriginalHTML:   originalHTML,
+		Metadata:       metadata,
+		ClientMetadata: payload.Options.ClientMetadata,
+		SourceURL:      payload.Options.SourceURL,
+		TextHash:       payload.Options.TextHash,
+		CallbackURL:    payload.Options.CallbackURL,
+		CreatedAt:      createdAt,
+		UpdatedAt:      createdAt,
+	}
+	w.linkPreviousAnalysis(ctx, analysis, text)
+	if payload.Options.RedactText {
+		redactText(analysis, text, payload.Options.DropCleanedText)
+	}
+
+	// A retried task finds the analysis saved, and possibly cancelled since
+	if w.cancelled(ctx, analysisID) {
+		return nil
 	}
 
 	// Save offline analysis to database
-	if err := w.db.SaveAnalysis(analysis); err != nil {
+	if err := w.db.SaveAnalysis(ctx, analysis); err != nil {
 		return fmt.Errorf("failed to save offline analysis: %w", err)
 	}
 
 	w.logger.Info("offline analysis saved", "analysis_id", analysisID)
+	if !w.setProcessingStage(ctx, analysisID, models.ProcessingStageOfflineComplete) {
+		return nil
+	}
+	w.recordHistory(ctx, analysis, models.HistoryStageOffline)
 
 	// Enqueue AI enrichment tasks if quality threshold is met
-	if metadata.QualityScore != nil && metadata.QualityScore.Score >= 0.35 {
+	if metadata.OfflineOnly {
+		w.logger.Info("submitted under queue back-pressure, skipping AI enrichment",
+			"analysis_id", analysisID,
+			"source", payload.Options.Source,
+		)
+	} else if metadata.SkipEnrichment {
+		w.logger.Info("skip_enrichment requested, skipping AI enrichment",
+			"analysis_id", analysisID,
+			"source", payload.Options.Source,
+		)
+	} else if w.cancelled(ctx, analysisID) {
+		return nil
+	} else if !metadata.EnrichmentSkipped {
 		w.logger.Info("quality threshold met, enqueueing AI enrichment",
 			"analysis_id", analysisID,
-			"quality_score", metadata.QualityScore.Score,
+			"quality_score", qualityScore(metadata),
+			"threshold", threshold,
+			"forced", metadata.ForceEnrichment,
+			"source", payload.Options.Source,
 		)
 
 		// Prepare offline cleaned text for enrichment (use CleanedText if available, otherwise use Text)
@@ -135,14 +208,14 @@ func (w *Worker) handleProcessDocument(ctx context.Context, t *asynq.Task) error
 		}
 
 		// Enqueue text enrichment (high priority) with offline text and original HTML
-		if _, err := w.queueClient.EnqueueEnrichText(ctx, analysisID, text, offlineText, originalHTML); err != nil {
+		if _, err := w.queueClient.EnqueueEnrichText(ctx, analysisID, text, offlineText, originalHTML, metadata.Priority, payload.Options.RedactText); err != nil {
 			w.logger.Error("failed to enqueue text enrichment", "error", err)
 			// Don't fail the task if enrichment enqueue fails
 		}
 
 		// Enqueue image enrichment tasks (low priority)
 		for i, imageURL := range images {
-			if _, err := w.queueClient.EnqueueEnrichImage(ctx, analysisID, imageURL, i); err != nil {
+			if _, err := w.queueClient.EnqueueEnrichImage(ctx, analysisID, imageURL, i, metadata.Priority); err != nil {
 				w.logger.Error("failed to enqueue image enrichment",
 					"error", err,
 					"image_index", i,
@@ -152,27 +225,342 @@ func (w *Worker) handleProcessDocument(ctx context.Context, t *asynq.Task) error
 			}
 		}
 	} else {
-		qualityScore := 0.0
-		if metadata.QualityScore != nil {
-			qualityScore = metadata.QualityScore.Score
-		}
 		w.logger.Info("quality threshold not met, skipping AI enrichment",
 			"analysis_id", analysisID,
-			"quality_score", qualityScore,
+			"quality_score", qualityScore(metadata),
+			"threshold", threshold,
+			"source", payload.Options.Source,
 		)
 	}
 
+	// Without enrichment, offline processing is as far as the analysis goes
+	if metadata.OfflineOnly || metadata.SkipEnrichment || metadata.EnrichmentSkipped {
+		w.notifyCallback(analysis, WebhookStatusOfflineOnly, "")
+	}
+
 	return nil
 }
 
+// redactText removes the source text from an analysis before it is stored,
+// keeping a SHA-256 hash of it. The original HTML, the heuristic cleaned text
+// and the context quoted around references go too, and the cleaned and
+// translated texts when dropCleanedText is set. Enrichment still receives the
+// text through its task payload.
+func redactText(analysis *models.Analysis, text string, dropCleanedText bool) {
+	analysis.Text = ""
+	analysis.OriginalHTML = ""
+	analysis.Metadata.Redaction = &models.TextRedaction{
+		TextSHA256:         sha256Hex(text),
+		CleanedTextDropped: dropCleanedText,
+	}
+	analysis.Metadata.HeuristicCleanedText = ""
+	dropReferenceContext(analysis.Metadata.References)
+	if dropCleanedText {
+		analysis.Metadata.CleanedText = ""
+		analysis.Metadata.TranslatedText = ""
+	}
+}
+
+// dropReferenceContext clears the text quoted around each reference
+func dropReferenceContext(references []models.Reference) {
+	for i := range references {
+		references[i].Context = ""
+	}
+}
+
+// sha256Hex returns the hex SHA-256 of text
+func sha256Hex(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// selectImages filters a document's images down to those worth enriching,
+// recording accepted and skipped counts on the metadata and an event when any
+// were skipped. Invalid URLs are logged, since the event only counts them. It
+// returns the accepted image URLs.
+func selectImages(logger *slog.Logger, metadata *models.Metadata, images []string, options models.ProcessingOptions, maxImages int, now time.Time) []string {
+	selection := analyzer.SelectImageURLs(images, maxImages)
+	for _, imageURL := range selection.Invalid {
+		logger.Warn("skipping invalid image URL", "image_url", imageURL)
+	}
+
+	// The API may already have dropped duplicates or truncated the list
+	submitted := max(options.ImagesSubmitted, len(images))
+	if submitted == 0 {
+		return selection.Accepted
+	}
+
+	counts := &models.ImageCounts{
+		Submitted: submitted,
+		Accepted:  len(selection.Accepted),
+		Skipped:   submitted - len(selection.Accepted),
+	}
+	metadata.Images = counts
+
+	if counts.Skipped > 0 {
+		var reasons []string
+		if dropped := submitted - len(images); dropped > 0 {
+			reasons = append(reasons, fmt.Sprintf("%d dropped at submission", dropped))
+		}
+		if len(selection.Invalid) > 0 {
+			reasons = append(reasons, fmt.Sprintf("%d invalid", len(selection.Invalid)))
+		}
+		if selection.Duplicates > 0 {
+			reasons = append(reasons, fmt.Sprintf("%d duplicate", selection.Duplicates))
+		}
+		if selection.Overflow > 0 {
+			reasons = append(reasons, fmt.Sprintf("%d over the limit of %d", selection.Overflow, maxImages))
+		}
+
+		metadata.Events = append(metadata.Events, models.AnalysisEvent{
+			Type:      models.EventImagesSkipped,
+			Message:   fmt.Sprintf("Skipped %d of %d images (%s)", counts.Skipped, submitted, strings.Join(reasons, ", ")),
+			CreatedAt: now,
+		})
+	}
+
+	return selection.Accepted
+}
+
+// setProcessingStage records the processing stage of an analysis, reporting
+// false when its job was cancelled, which keeps the cancelled stage. Other
+// failures are logged rather than failing the task, as the results are
+// already saved.
+func (w *Worker) setProcessingStage(ctx context.Context, analysisID, stage string) bool {
+	err := w.db.AdvanceProcessingStage(ctx, analysisID, stage)
+	if errors.Is(err, database.ErrCancelled) {
+		w.logger.Info("job cancelled, stopping task", "analysis_id", analysisID, "stage", stage)
+		return false
+	}
+	if err != nil {
+		w.logger.Warn("failed to update processing stage",
+			"analysis_id", analysisID,
+			"stage", stage,
+			"error", err,
+		)
+	}
+	return true
+}
+
+// recordHistory adds the metadata of a saved analysis to its history under
+// stage, with the ID of the running task. Failures are logged rather than
+// failing the task, as the results are already saved.
+func (w *Worker) recordHistory(ctx context.Context, analysis *models.Analysis, stage string) {
+	taskID, _ := asynq.GetTaskID(ctx)
+	entry := &models.AnalysisHistoryEntry{
+		AnalysisID: analysis.ID,
+		Stage:      stage,
+		TaskID:     taskID,
+		Metadata:   analysis.Metadata,
+	}
+	if err := w.db.SaveAnalysisHistory(ctx, entry); err != nil {
+		w.logger.Warn("failed to record analysis history",
+			"analysis_id", analysis.ID,
+			"stage", stage,
+			"error", err,
+		)
+	}
+}
+
+// cancelled reports whether an analysis's job was cancelled, in which case
+// its tasks complete without doing their work. An analysis not saved yet
+// cannot be cancelled, and lookup failures let the task go ahead.
+func (w *Worker) cancelled(ctx context.Context, analysisID string) bool {
+	state, err := w.db.GetProcessingState(ctx, analysisID)
+	if err != nil {
+		if !errors.Is(err, database.ErrNotFound) {
+			w.logger.Warn("failed to check for cancellation", "analysis_id", analysisID, "error", err)
+		}
+		return false
+	}
+	if state.Stage != models.ProcessingStageCancelled {
+		return false
+	}
+
+	w.logger.Info("job cancelled, skipping task", "analysis_id", analysisID)
+	return true
+}
+
+// recordEnrichmentFailure records a failed text enrichment attempt. The
+// attempt that exhausts the task's retries leaves the analysis failed. It is
+// recorded even when the failure is the task's context expiring.
+func (w *Worker) recordEnrichmentFailure(ctx context.Context, analysisID string, retryCount, maxRetry int, cause error) {
+	err := w.db.MarkEnrichmentFailed(context.WithoutCancel(ctx), analysisID, retryCount, maxRetry, cause)
+	if err != nil && !errors.Is(err, database.ErrCancelled) {
+		w.logger.Warn("failed to record enrichment failure",
+			"analysis_id", analysisID,
+			"retry_count", retryCount,
+			"error", err,
+		)
+	}
+}
+
+// notifyFinalFailure reports a failed enrichment to the analysis's callback
+// once the attempt has exhausted the task's retries
+func (w *Worker) notifyFinalFailure(analysis *models.Analysis, retryCount, maxRetry int, cause error) {
+	if retryCount >= maxRetry {
+		w.notifyCallback(analysis, WebhookStatusFailed, cause.Error())
+	}
+}
+
+// linkPreviousAnalysis links an analysis to the latest earlier analysis of
+// its source URL, so a resubmitted page records the version it follows and
+// whether its text changed. Lookup failures only skip the link.
+func (w *Worker) linkPreviousAnalysis(ctx context.Context, analysis *models.Analysis, text string) {
+	if analysis.SourceURL == "" {
+		return
+	}
+	// A retried task may already have saved the analysis, so the lookup
+	// ignores anything stored after it
+	previous, err := w.db.GetPreviousAnalysisBySourceURL(ctx, analysis.SourceURL, analysis.ID, analysis.CreatedAt)
+	if errors.Is(err, database.ErrNotFound) {
+		return
+	}
+	if err != nil {
+		w.logger.Warn("failed to look up earlier analyses of source URL",
+			"analysis_id", analysis.ID,
+			"source_url", analysis.SourceURL,
+			"error", err,
+		)
+		return
+	}
+
+	analysis.Metadata.PreviousAnalysisID = previous.ID
+	analysis.Metadata.ContentUnchanged = previous.TextHash != "" && previous.TextHash == textHash(analysis, text)
+}
+
+// textHash returns the text hash of an analysis (see database.TextHash),
+// hashing text when the submission did not carry one
+func textHash(analysis *models.Analysis, text string) string {
+	if analysis.TextHash != "" {
+		return analysis.TextHash
+	}
+	return database.TextHash(text)
+}
+
+// markOfflineOnly records that AI enrichment was skipped because the
+// submission was accepted while the enrichment queue was saturated
+func markOfflineOnly(metadata *models.Metadata, now time.Time) {
+	metadata.OfflineOnly = true
+	metadata.EnrichmentStatus = analyzer.EnrichmentStatusFor(models.EnrichmentOptions{}, analyzer.StepStatusSkippedDisabled)
+	metadata.Events = append(metadata.Events, models.AnalysisEvent{
+		Type:      models.EventOfflineOnly,
+		Message:   "AI enrichment skipped: the enrichment queue was saturated when the document was submitted",
+		CreatedAt: now,
+	})
+}
+
+// markSkipEnrichment records that AI enrichment was skipped because the
+// submission asked for none
+func markSkipEnrichment(metadata *models.Metadata) {
+	metadata.SkipEnrichment = true
+	metadata.EnrichmentStatus = analyzer.EnrichmentStatusFor(models.EnrichmentOptions{}, analyzer.StepStatusSkippedDisabled)
+}
+
+// qualityScore returns the quality score in metadata, 0 when unscored
+func qualityScore(metadata models.Metadata) float64 {
+	if metadata.QualityScore == nil {
+		return 0
+	}
+	return metadata.QualityScore.Score
+}
+
+// enrichmentThreshold returns the quality threshold carried in the task
+// options, falling back to the default for tasks enqueued without one
+func enrichmentThreshold(opts models.ProcessingOptions) float64 {
+	if opts.EnrichmentThreshold != nil {
+		return *opts.EnrichmentThreshold
+	}
+	return analyzer.DefaultEnrichmentThreshold
+}
+
+// processingPriority returns the priority carried in the task options,
+// falling back to normal for tasks enqueued without one
+func processingPriority(opts models.ProcessingOptions) string {
+	priority, err := ParsePriority(opts.Priority)
+	if err != nil {
+		return PriorityNormal
+	}
+	return priority
+}
+
+// mergeEnrichment applies AI results to an analysis and marks it enriched.
+// Fields of disabled steps are left empty and the steps are listed in
+// SkippedSteps; the outcome of each step updates EnrichmentStatus, and the
+// provenance snapshot and chunking replace the previous ones. The quality
+// score, references and tags replace the previous ones when the AI results
+// have them. When the analysis had already been enriched, the AI-derived
+// fields being replaced are returned as a revision so model upgrades can be
+// compared; otherwise it returns nil.
+func mergeEnrichment(analysis *models.Analysis, aiMetadata models.Metadata, model string) *models.AnalysisRevision {
+	var revision *models.AnalysisRevision
+	if isEnriched(analysis.Metadata) {
+		revision = &models.AnalysisRevision{
+			AnalysisID: analysis.ID,
+			Fields:     analyzer.SnapshotRevisionFields(analysis.Metadata),
+			Model:      analysis.Metadata.EnrichmentModel,
+			CreatedAt:  time.Now(),
+		}
+	}
+
+	analysis.Metadata.Synopsis = aiMetadata.Synopsis
+	analysis.Metadata.CleanedText = aiMetadata.CleanedText
+	analysis.Metadata.TranslatedText = aiMetadata.TranslatedText
+	if redaction := analysis.Metadata.Redaction; redaction != nil && redaction.CleanedTextDropped {
+		analysis.Metadata.CleanedText = ""
+		analysis.Metadata.TranslatedText = ""
+	}
+	analysis.Metadata.EditorialAnalysis = aiMetadata.EditorialAnalysis
+	analysis.Metadata.Editorial = aiMetadata.Editorial
+	analysis.Metadata.AIDetection = aiMetadata.AIDetection
+	if aiMetadata.QualityScore != nil {
+		analysis.Metadata.QualityScore = aiMetadata.QualityScore
+	}
+	if len(aiMetadata.References) > 0 {
+		analysis.Metadata.References = aiMetadata.References
+		if analysis.Metadata.Redaction != nil {
+			dropReferenceContext(analysis.Metadata.References)
+		}
+	}
+	analysis.Metadata.EnrichmentModel = model
+	analysis.Metadata.AIModel = aiMetadata.AIModel
+	analysis.Metadata.SkippedSteps = aiMetadata.SkippedSteps
+	if len(aiMetadata.EnrichmentStatus) > 0 && analysis.Metadata.EnrichmentStatus == nil {
+		analysis.Metadata.EnrichmentStatus = make(map[string]string, len(aiMetadata.EnrichmentStatus))
+	}
+	for step, status := range aiMetadata.EnrichmentStatus {
+		analysis.Metadata.EnrichmentStatus[step] = status
+	}
+	analysis.Metadata.Provenance = aiMetadata.Provenance
+	analysis.Metadata.Chunking = aiMetadata.Chunking
+	enrichedAt := time.Now()
+	analysis.Metadata.EnrichedAt = &enrichedAt
+
+	// Update tags with AI-generated tags if available
+	if len(aiMetadata.Tags) > 0 {
+		analysis.Metadata.Tags = tags.MergeWithLimit(0, aiMetadata.Tags)
+	}
+
+	return revision
+}
+
+// isEnriched reports whether metadata already holds AI enrichment results.
+// Analyses enriched before completion was recorded are recognized by their
+// AI-derived fields.
+func isEnriched(metadata models.Metadata) bool {
+	return metadata.EnrichedAt != nil ||
+		metadata.Synopsis != "" || metadata.CleanedText != "" || metadata.EditorialAnalysis != ""
+}
+
 // handleEnrichText processes AI text enrichment via Ollama (Stage 2 - High Priority)
 func (w *Worker) handleEnrichText(ctx context.Context, t *asynq.Task) error {
 	// Parse payload
-	var payload EnrichTextPayload
-	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
-		w.logger.Error("failed to unmarshal task payload", "error", err)
-		return fmt.Errorf("invalid task payload: %w", err)
+	payload, version, err := DecodeEnrichTextPayload(t.Payload())
+	if err != nil {
+		w.logger.Error("failed to decode task payload", "payload_version", version, "error", err)
+		return err
 	}
+	w.observePayloadVersion(t.Type(), version)
 
 	analysisID := payload.AnalysisID
 	text := payload.Text
@@ -254,11 +642,26 @@ func (w *Worker) handleEnrichText(ctx context.Context, t *asynq.Task) error {
 		}
 	}
 
+	if w.cancelled(ctx, analysisID) {
+		return nil
+	}
+
 	// Retrieve existing analysis
-	analysis, err := w.db.GetAnalysis(analysisID)
+	analysis, err := w.db.GetAnalysis(ctx, analysisID)
 	if err != nil {
+		w.recordEnrichmentFailure(ctx, analysisID, retryCount, maxRetry, err)
 		return fmt.Errorf("failed to retrieve analysis: %w", err)
 	}
+	if !w.setProcessingStage(ctx, analysisID, models.ProcessingStageEnriching) {
+		return nil
+	}
+
+	// Use the threshold and options recorded during offline processing so AI
+	// analysis does not re-apply the default gate, and start AI analysis from
+	// the offline statistics and quality score rather than recomputing them
+	opts := analyzer.RecordedOptions(analysis.Metadata)
+	opts.Offline = &analysis.Metadata
+	opts.ReferenceTime = analysis.CreatedAt
 
 	// Start metrics timer for analysis duration with exemplar support
 	timer := time.Now()
@@ -284,32 +687,64 @@ func (w *Worker) handleEnrichText(ctx context.Context, t *asynq.Task) error {
 				"analysis_id", analysisID,
 				"error", err,
 			)
-			aiMetadata = w.analyzer.AnalyzeWithContext(ctx, text)
+			aiMetadata = w.analyzer.AnalyzeWithOptions(ctx, text, opts)
 		} else {
 			// Use enhanced analysis with HTML and offline text as template
-			aiMetadata = w.analyzer.AnalyzeWithHTMLContext(ctx, text, offlineText, decompressedHTML)
+			aiMetadata = w.analyzer.AnalyzeWithHTMLContext(ctx, text, offlineText, decompressedHTML, opts)
 		}
 	} else {
 		// Standard AI analysis
-		aiMetadata = w.analyzer.AnalyzeWithContext(ctx, text)
+		aiMetadata = w.analyzer.AnalyzeWithOptions(ctx, text, opts)
 	}
 
-	// Merge AI results with existing offline metadata
-	analysis.Metadata.Synopsis = aiMetadata.Synopsis
-	analysis.Metadata.CleanedText = aiMetadata.CleanedText
-	analysis.Metadata.EditorialAnalysis = aiMetadata.EditorialAnalysis
-	analysis.Metadata.AIDetection = aiMetadata.AIDetection
+	// Ollama errors do not stop the analysis; each step falls back or is left
+	// empty. When no step got a model result, fail the attempt so it is
+	// retried instead of saving the analysis as enriched.
+	if w.analyzer.ModelName() != "" {
+		if result := analyzer.SummarizeEnrichment(aiMetadata.EnrichmentStatus); result.AllFailed() {
+			analysisStatus = "error"
+			err := fmt.Errorf("all %d AI enrichment steps failed", result.Failed)
+			w.recordEnrichmentFailure(ctx, analysisID, retryCount, maxRetry, err)
+			w.notifyFinalFailure(analysis, retryCount, maxRetry, err)
+			w.logger.Warn("no AI enrichment step succeeded, will retry",
+				"analysis_id", analysisID,
+				"failed_steps", result.Failed,
+				"retry_count", retryCount,
+				"max_retries", maxRetry,
+			)
+			return err
+		}
+	}
 
-	// Update tags with AI-generated tags if available
-	if len(aiMetadata.Tags) > 0 {
-		analysis.Metadata.Tags = aiMetadata.Tags
+	// Record the configuration behind the results
+	aiMetadata.Provenance = w.analyzer.Provenance(opts)
+
+	// Merge AI results with existing offline metadata, keeping the previous
+	// AI results as a revision when this is a re-enrichment
+	historyStage := models.HistoryStageEnrichment
+	revision := mergeEnrichment(analysis, aiMetadata, w.analyzer.ModelName())
+	if revision != nil {
+		historyStage = models.HistoryStageReenrichment
 	}
 
 	analysis.UpdatedAt = time.Now()
 
-	// Update analysis in database
-	if err := w.db.SaveAnalysis(analysis); err != nil {
+	// The job may have been cancelled while the model was running
+	if w.cancelled(ctx, analysisID) {
+		return nil
+	}
+
+	// Update analysis in database, with the revision it replaces so a
+	// failed save is retried without leaving a revision behind
+	if revision != nil {
+		err = w.db.SaveAnalysisWithRevision(ctx, analysis, revision)
+	} else {
+		err = w.db.SaveAnalysis(ctx, analysis)
+	}
+	if err != nil {
 		analysisStatus = "error"
+		w.recordEnrichmentFailure(ctx, analysisID, retryCount, maxRetry, err)
+		w.notifyFinalFailure(analysis, retryCount, maxRetry, err)
 		// Check if this is a retriable error (connection/timeout)
 		if isRetriableOllamaError(err) {
 			w.logger.Warn("retriable Ollama error, will retry",
@@ -328,8 +763,29 @@ func (w *Worker) handleEnrichText(ctx context.Context, t *asynq.Task) error {
 		return fmt.Errorf("failed to update enriched analysis: %w", err)
 	}
 
+	// Add back the tags of described images, which the save replaced
+	w.mergeImageTags(ctx, analysis)
+
+	if revision != nil {
+		w.logger.Info("saved analysis revision",
+			"analysis_id", analysisID,
+			"revision", revision.Revision,
+			"previous_model", revision.Model,
+		)
+	}
+
 	// Record successful analysis
 	analysisStatus = "success"
+	if !w.setProcessingStage(ctx, analysisID, models.ProcessingStageEnriched) {
+		return nil
+	}
+	w.recordHistory(ctx, analysis, historyStage)
+	w.notifyCallback(analysis, WebhookStatusCompleted, "")
+
+	// Compare a sample of enrichments with the shadow model, if any
+	if w.shadow != nil {
+		w.shadow.start(ctx, analysis, text)
+	}
 
 	// Record tags and synopsis generated
 	if len(aiMetadata.Tags) > 0 {
@@ -350,15 +806,20 @@ func (w *Worker) handleEnrichText(ctx context.Context, t *asynq.Task) error {
 // handleEnrichImage processes AI image enrichment via Ollama (Stage 2 - Low Priority)
 func (w *Worker) handleEnrichImage(ctx context.Context, t *asynq.Task) error {
 	// Parse payload
-	var payload EnrichImagePayload
-	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
-		w.logger.Error("failed to unmarshal task payload", "error", err)
-		return fmt.Errorf("invalid task payload: %w", err)
+	payload, version, err := DecodeEnrichImagePayload(t.Payload())
+	if err != nil {
+		w.logger.Error("failed to decode task payload", "payload_version", version, "error", err)
+		return err
 	}
+	w.observePayloadVersion(t.Type(), version)
 
 	analysisID := payload.AnalysisID
 	imageURL := payload.ImageURL
 
+	if w.cancelled(ctx, analysisID) {
+		return nil
+	}
+
 	retryCount, _ := asynq.GetRetryCount(ctx)
 	maxRetry, _ := asynq.GetMaxRetry(ctx)
 
@@ -428,26 +889,27 @@ func (w *Worker) handleEnrichImage(ctx context.Context, t *asynq.Task) error {
 		}
 	}
 
-	// Retrieve existing analysis
-	analysis, err := w.db.GetAnalysis(analysisID)
-	if err != nil {
+	// Make sure the analysis still exists before probing the image
+	if _, err := w.db.GetAnalysis(ctx, analysisID); err != nil {
 		return fmt.Errorf("failed to retrieve analysis: %w", err)
 	}
 
-	// Extract offline image metadata
-	imageMetadata := w.analyzer.ExtractImageMetadata(imageURL)
-
-	// TODO: When Ollama supports vision models, add AI image analysis here
-	// For now, just store the offline metadata
-
-	// Store image metadata in analysis (add to metadata or create image-specific field)
-	// This is a placeholder - actual storage structure may need adjustment
-	slog.Info("image metadata extracted", "url", imageURL, "metadata", imageMetadata)
-
-	analysis.UpdatedAt = time.Now()
+	// Probe the image for type, size and dimensions, falling back to URL heuristics
+	imageMetadata, err := w.analyzer.FetchImageMetadata(ctx, imageURL)
+	if err != nil {
+		// An invalid URL will never succeed, so don't retry
+		w.logger.Warn("invalid image URL, skipping enrichment",
+			"analysis_id", analysisID,
+			"image_url", imageURL,
+			"error", err,
+		)
+		return fmt.Errorf("%v: %w", err, asynq.SkipRetry)
+	}
+	imageMetadata.AnalysisID = analysisID
+	imageMetadata.ImageIndex = payload.ImageIndex
 
-	// Update analysis in database
-	if err := w.db.SaveAnalysis(analysis); err != nil {
+	// Store image metadata
+	if err := w.db.SaveImageMetadata(ctx, imageMetadata); err != nil {
 		// Check if this is a retriable error
 		if isRetriableOllamaError(err) {
 			w.logger.Warn("retriable error, will retry",
@@ -463,7 +925,42 @@ func (w *Worker) handleEnrichImage(ctx context.Context, t *asynq.Task) error {
 			"analysis_id", analysisID,
 			"error", err,
 		)
-		return fmt.Errorf("failed to update enriched analysis: %w", err)
+		return fmt.Errorf("failed to save image metadata: %w", err)
+	}
+
+	if imageMetadata.IsTrackingPixel {
+		w.logger.Info("tracking pixel detected, skipping AI description",
+			"analysis_id", analysisID,
+			"image_url", imageURL,
+			"width", imageMetadata.Width,
+			"height", imageMetadata.Height,
+			"content_length", imageMetadata.ContentLength,
+		)
+		return nil
+	}
+
+	// Caption and tag the image with the vision model, when one is configured.
+	// Vision models cannot read SVG, and failed probes have nothing to describe.
+	if w.analyzer.DescribesImages() && imageMetadata.Error == "" && !imageMetadata.Oversized && imageMetadata.Format != "svg" {
+		if err := w.describeImage(ctx, imageMetadata); err != nil {
+			if isRetriableOllamaError(err) {
+				w.logger.Warn("retriable error describing image, will retry",
+					"analysis_id", analysisID,
+					"image_url", imageURL,
+					"error", err,
+					"retry_count", retryCount,
+				)
+				return err // Let Asynq retry
+			}
+
+			// Permanent error
+			w.logger.Error("permanent error describing image",
+				"analysis_id", analysisID,
+				"image_url", imageURL,
+				"error", err,
+			)
+			return fmt.Errorf("failed to describe image: %w", err)
+		}
 	}
 
 	w.logger.Info("image enrichment completed",
@@ -475,6 +972,66 @@ func (w *Worker) handleEnrichImage(ctx context.Context, t *asynq.Task) error {
 	return nil
 }
 
+// describeImage captions and tags an image with the vision model, storing the
+// results with the image metadata and merging the tags into the analysis
+func (w *Worker) describeImage(ctx context.Context, image *models.ImageMetadata) error {
+	caption, imageTags, err := w.analyzer.DescribeImage(ctx, image.URL)
+	if err != nil {
+		return err
+	}
+
+	image.AICaption = caption
+	image.AITags = imageTags
+	if err := w.db.SaveImageMetadata(ctx, image); err != nil {
+		return err
+	}
+	if len(imageTags) > 0 {
+		if err := w.db.AddAnalysisTags(ctx, image.AnalysisID, imageTags); err != nil {
+			return err
+		}
+	}
+
+	w.logger.Info("image described",
+		"analysis_id", image.AnalysisID,
+		"image_url", image.URL,
+		"caption_length", len(caption),
+		"tags", imageTags,
+	)
+	return nil
+}
+
+// mergeImageTags adds the tags of described images to a saved analysis, as
+// text enrichment replaces its tags. The images are read after the save and
+// their tags added with AddAnalysisTags, so tags of an image described while
+// the analysis was saved are not lost. Failures are logged, keeping the text
+// tags.
+func (w *Worker) mergeImageTags(ctx context.Context, analysis *models.Analysis) {
+	images, err := w.db.GetAnalysisImages(ctx, analysis.ID)
+	if err != nil {
+		w.logger.Warn("failed to load image tags",
+			"analysis_id", analysis.ID,
+			"error", err,
+		)
+		return
+	}
+
+	var imageTags []string
+	for _, image := range images {
+		imageTags = append(imageTags, image.AITags...)
+	}
+	if len(imageTags) == 0 {
+		return
+	}
+	if err := w.db.AddAnalysisTags(ctx, analysis.ID, imageTags); err != nil {
+		w.logger.Warn("failed to add image tags",
+			"analysis_id", analysis.ID,
+			"error", err,
+		)
+		return
+	}
+	analysis.Metadata.Tags = tags.MergeWithLimit(0, analysis.Metadata.Tags, imageTags)
+}
+
 // isRetriableOllamaError determines if an error is retriable (connection/timeout)
 // vs permanent (invalid input)
 func isRetriableOllamaError(err error) bool {
@@ -482,6 +1039,11 @@ func isRetriableOllamaError(err error) bool {
 		return false
 	}
 
+	// The circuit breaker fails calls fast while Ollama is down; retry later
+	if errors.Is(err, ollama.ErrCircuitOpen) {
+		return true
+	}
+
 	errStr := strings.ToLower(err.Error())
 
 	// Retriable errors: connection issues, timeouts, temporary failures
@@ -510,8 +1072,8 @@ func isRetriableOllamaError(err error) bool {
 	return false
 }
 
-// compressHTML compresses and base64 encodes HTML text
-func compressHTML(html string) (string, error) {
+// CompressHTML compresses and base64 encodes HTML text
+func CompressHTML(html string) (string, error) {
 	if html == "" {
 		return "", nil
 	}
@@ -536,16 +1098,9 @@ func decompressHTML(encoded string) (string, error) {
 		return "", nil
 	}
 
-	// Decode base64
-	compressed, err := base64.StdEncoding.DecodeString(encoded)
-	if err != nil {
-		return "", fmt.Errorf("failed to decode base64: %w", err)
-	}
-
-	// Decompress gzip
-	gzReader, err := gzip.NewReader(bytes.NewReader(compressed))
+	gzReader, err := openHTML(encoded)
 	if err != nil {
-		return "", fmt.Errorf("failed to create gzip reader: %w", err)
+		return "", err
 	}
 	defer gzReader.Close()
 
@@ -556,3 +1111,42 @@ func decompressHTML(encoded string) (string, error) {
 
 	return string(decompressed), nil
 }
+
+// DecompressedHTMLSize returns the decompressed size of HTML encoded like
+// CompressHTML. At most limit+1 bytes are decompressed, so a size above limit
+// is reported without inflating the whole document.
+func DecompressedHTMLSize(encoded string, limit int64) (int64, error) {
+	if encoded == "" {
+		return 0, nil
+	}
+
+	gzReader, err := openHTML(encoded)
+	if err != nil {
+		return 0, err
+	}
+	defer gzReader.Close()
+
+	size, err := io.Copy(io.Discard, io.LimitReader(gzReader, limit+1))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read decompressed data: %w", err)
+	}
+
+	return size, nil
+}
+
+// openHTML decodes base64 and returns a reader of the decompressed HTML
+func openHTML(encoded string) (*gzip.Reader, error) {
+	// Decode base64
+	compressed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode base64: %w", err)
+	}
+
+	// Decompress gzip
+	gzReader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gzip reader: %w", err)
+	}
+
+	return gzReader, nil
+}
@@ -40,9 +40,9 @@ func TestCompressHTML(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			compressed, err := compressHTML(tt.input)
+			compressed, err := CompressHTML(tt.input)
 			if (err != nil) != tt.wantErr {
-				t.Errorf("compressHTML() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("CompressHTML() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 
@@ -104,7 +104,7 @@ func TestDecompressHTML(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			// For the valid case, compress first
 			if tt.name == "valid compressed HTML" {
-				compressed, err := compressHTML(tt.expected)
+				compressed, err := CompressHTML(tt.expected)
 				if err != nil {
 					t.Fatalf("setup failed: %v", err)
 				}
@@ -162,9 +162,9 @@ func TestCompressDecompressRoundTrip(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Compress
-			compressed, err := compressHTML(tt.html)
+			compressed, err := CompressHTML(tt.html)
 			if err != nil {
-				t.Fatalf("compressHTML() failed: %v", err)
+				t.Fatalf("CompressHTML() failed: %v", err)
 			}
 
 			// Decompress
@@ -192,7 +192,7 @@ func TestCompressionRatio(t *testing.T) {
 		</div>
 	`, 50)
 
-	compressed, err := compressHTML(html)
+	compressed, err := CompressHTML(html)
 	if err != nil {
 		t.Fatalf("compression failed: %v", err)
 	}
@@ -211,6 +211,41 @@ func TestCompressionRatio(t *testing.T) {
 	}
 }
 
+func TestDecompressedHTMLSize(t *testing.T) {
+	html := strings.Repeat("<div>Content</div>", 1000)
+	compressed, err := CompressHTML(html)
+	if err != nil {
+		t.Fatalf("CompressHTML() failed: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		input     string
+		limit     int64
+		want      int64
+		shouldErr bool
+	}{
+		{name: "within limit", input: compressed, limit: 1 << 20, want: int64(len(html))},
+		{name: "exactly at limit", input: compressed, limit: int64(len(html)), want: int64(len(html))},
+		{name: "over limit stops reading", input: compressed, limit: 100, want: 101},
+		{name: "empty string", input: "", limit: 100, want: 0},
+		{name: "invalid base64", input: "not-valid-base64!!!", limit: 100, shouldErr: true},
+		{name: "not gzip", input: "aGVsbG8gd29ybGQ=", limit: 100, shouldErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			size, err := DecompressedHTMLSize(tt.input, tt.limit)
+			if (err != nil) != tt.shouldErr {
+				t.Fatalf("DecompressedHTMLSize() error = %v, shouldErr %v", err, tt.shouldErr)
+			}
+			if size != tt.want {
+				t.Errorf("DecompressedHTMLSize() = %d, want %d", size, tt.want)
+			}
+		})
+	}
+}
+
 // testError is a simple error type for testing
 type testError struct {
 	msg string
@@ -225,13 +260,13 @@ func BenchmarkCompressHTML(b *testing.B) {
 	html := strings.Repeat("<div><p>Test content</p></div>", 100)
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_, _ = compressHTML(html)
+		_, _ = CompressHTML(html)
 	}
 }
 
 func BenchmarkDecompressHTML(b *testing.B) {
 	html := strings.Repeat("<div><p>Test content</p></div>", 100)
-	compressed, _ := compressHTML(html)
+	compressed, _ := CompressHTML(html)
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		_, _ = decompressHTML(compressed)
@@ -242,7 +277,7 @@ func BenchmarkCompressDecompressRoundTrip(b *testing.B) {
 	html := strings.Repeat("<div><p>Test content</p></div>", 100)
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		compressed, _ := compressHTML(html)
+		compressed, _ := CompressHTML(html)
 		_, _ = decompressHTML(compressed)
 	}
 }
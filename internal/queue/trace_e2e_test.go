@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/hibiken/asynq"
+	"github.com/docutag/textanalyzer/internal/models"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/sdk/trace"
 	"go.opentelemetry.io/otel/sdk/trace/tracetest"
@@ -381,7 +382,8 @@ func TestE2ETraceFlowWithRealAsynq(t *testing.T) {
 	taskID, err := queueClient.EnqueueProcessDocument(ctx, analysisID,
 		"Sample text for real Asynq test",
 		"<html>Sample text</html>",
-		[]string{"https://example.com/img1.jpg"})
+		[]string{"https://example.com/img1.jpg"},
+		models.ProcessingOptions{})
 
 	if err != nil {
 		t.Skipf("Could not connect to Redis: %v", err)
@@ -0,0 +1,286 @@
+package queue
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/docutag/textanalyzer/internal/analyzer"
+	"github.com/docutag/textanalyzer/internal/models"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Webhook delivery defaults
+const (
+	DefaultWebhookMaxAttempts = 3
+	webhookTimeout            = 10 * time.Second
+	webhookBackoff            = time.Second // Doubled after each failed attempt
+	webhookMaxRedirects       = 3
+)
+
+// ErrCallbackURL is wrapped by delivery errors for callback URLs that are
+// refused, such as redirects to other schemes or non-public addresses.
+// Refused deliveries are not retried.
+var ErrCallbackURL = errors.New("callback URL refused")
+
+// SignatureHeader carries the HMAC-SHA256 of a notification body, keyed
+// with the webhook secret, as "sha256=<hex>"
+const SignatureHeader = "X-Textanalyzer-Signature"
+
+// Terminal statuses reported to callbacks, matching the job status API
+const (
+	WebhookStatusCompleted   = "completed"
+	WebhookStatusOfflineOnly = "completed_offline_only"
+	WebhookStatusFailed      = "failed"
+)
+
+// Webhook delivery outcomes, as counted
+const (
+	webhookDelivered = "success"
+	webhookFailed    = "failure"
+)
+
+// WebhookNotification is the JSON body POSTed to an analysis's callback URL
+// once its processing reaches a terminal state
+type WebhookNotification struct {
+	AnalysisID     string            `json:"analysis_id"`
+	Status         string            `json:"status"`
+	Error          string            `json:"error,omitempty"` // Last enrichment error of a failed analysis
+	SourceURL      string            `json:"source_url,omitempty"`
+	ClientMetadata map[string]string `json:"client_metadata,omitempty"`
+	Summary        WebhookSummary    `json:"summary"`
+	CompletedAt    time.Time         `json:"completed_at"`
+}
+
+// WebhookSummary is the part of an analysis's metadata sent with a
+// notification; the full analysis is at GET /api/analyses/{id}
+type WebhookSummary struct {
+	WordCount         int      `json:"word_count"`
+	Language          string   `json:"language,omitempty"`
+	Sentiment         string   `json:"sentiment"`
+	QualityScore      *float64 `json:"quality_score,omitempty"`
+	Tags              []string `json:"tags"`
+	Synopsis          string   `json:"synopsis,omitempty"`
+	EnrichmentSkipped bool     `json:"enrichment_skipped,omitempty"`
+	OfflineOnly       bool     `json:"offline_only,omitempty"`
+	SkipEnrichment    bool     `json:"skip_enrichment,omitempty"`
+}
+
+// newWebhookNotification describes an analysis in a terminal status
+func newWebhookNotification(analysis *models.Analysis, status, lastError string, now time.Time) WebhookNotification {
+	metadata := analysis.Metadata
+	summary := WebhookSummary{
+		WordCount:         metadata.WordCount,
+		Language:          metadata.Language,
+		Sentiment:         metadata.Sentiment,
+		Tags:              metadata.Tags,
+		Synopsis:          metadata.Synopsis,
+		EnrichmentSkipped: metadata.EnrichmentSkipped,
+		OfflineOnly:       metadata.OfflineOnly,
+		SkipEnrichment:    metadata.SkipEnrichment,
+	}
+	if summary.Tags == nil {
+		summary.Tags = []string{}
+	}
+	if metadata.QualityScore != nil {
+		score := metadata.QualityScore.Score
+		summary.QualityScore = &score
+	}
+
+	return WebhookNotification{
+		AnalysisID:     analysis.ID,
+		Status:         status,
+		Error:          lastError,
+		SourceURL:      analysis.SourceURL,
+		ClientMetadata: analysis.ClientMetadata,
+		Summary:        summary,
+		CompletedAt:    now,
+	}
+}
+
+// SignWebhook returns the signature header value of a notification body
+func SignWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookNotifier delivers notifications in the background, so a slow or
+// failing callback never holds up or fails a task
+type webhookNotifier struct {
+	client       *http.Client
+	allowPrivate bool
+	secret       string
+	maxAttempts  int
+	backoff      time.Duration
+	logger       *slog.Logger
+	deliveries   *prometheus.CounterVec
+	pending      sync.WaitGroup
+}
+
+// newWebhookNotifier creates a notifier signing with secret. Without a
+// secret, notifications are sent unsigned. Unless allowPrivate, callbacks
+// only reach public addresses, so a callback URL cannot be used to make
+// requests into the internal network.
+func newWebhookNotifier(secret string, maxAttempts int, allowPrivate bool, registerer prometheus.Registerer, logger *slog.Logger) *webhookNotifier {
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultWebhookMaxAttempts
+	}
+	if secret == "" {
+		logger.Warn("no webhook secret configured, callback notifications will be unsigned")
+	}
+
+	deliveries := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "textanalyzer_webhook_deliveries_total",
+		Help: "Callback notifications, by delivery result after retries",
+	}, []string{"result"})
+	if err := registerer.Register(deliveries); err != nil {
+		if existing, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			deliveries = existing.ExistingCollector.(*prometheus.CounterVec)
+		} else {
+			logger.Warn("failed to register webhook delivery counter", "error", err)
+		}
+	}
+
+	dialer := analyzer.PublicDialer(webhookTimeout, allowPrivate, ErrCallbackURL)
+	client := &http.Client{
+		Timeout: webhookTimeout,
+		Transport: &http.Transport{
+			// No proxy: it would dial internal addresses on our behalf
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: webhookTimeout,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > webhookMaxRedirects {
+				return fmt.Errorf("%w: stopped after %d redirects", ErrCallbackURL, webhookMaxRedirects)
+			}
+			return checkCallbackURL(req.URL, allowPrivate)
+		},
+	}
+
+	return &webhookNotifier{
+		client:       client,
+		allowPrivate: allowPrivate,
+		secret:       secret,
+		maxAttempts:  maxAttempts,
+		backoff:      webhookBackoff,
+		logger:       logger,
+		deliveries:   deliveries,
+	}
+}
+
+// notify delivers a notification to callbackURL in the background
+func (n *webhookNotifier) notify(callbackURL string, notification WebhookNotification) {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		n.deliveries.WithLabelValues(webhookFailed).Inc()
+		n.logger.Warn("failed to encode webhook notification", "analysis_id", notification.AnalysisID, "error", err)
+		return
+	}
+
+	n.pending.Add(1)
+	go func() {
+		defer n.pending.Done()
+		n.deliver(callbackURL, notification.AnalysisID, body)
+	}()
+}
+
+// deliver POSTs a notification body, retrying network errors, 429 and 5xx
+// responses with exponential backoff up to maxAttempts attempts
+func (n *webhookNotifier) deliver(callbackURL, analysisID string, body []byte) {
+	backoff := n.backoff
+	var err error
+	for attempt := 1; attempt <= n.maxAttempts; attempt++ {
+		var retry bool
+		if retry, err = n.post(callbackURL, body); err == nil {
+			n.deliveries.WithLabelValues(webhookDelivered).Inc()
+			n.logger.Info("webhook delivered", "analysis_id", analysisID, "attempt", attempt)
+			return
+		}
+		if !retry || attempt == n.maxAttempts {
+			break
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+
+	n.deliveries.WithLabelValues(webhookFailed).Inc()
+	n.logger.Warn("webhook delivery failed",
+		"analysis_id", analysisID,
+		"callback_url", callbackURL,
+		"error", err,
+	)
+}
+
+// post sends one delivery attempt, reporting whether a failure is worth
+// retrying
+func (n *webhookNotifier) post(callbackURL string, body []byte) (retry bool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, callbackURL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	if err := checkCallbackURL(req.URL, n.allowPrivate); err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.secret != "" {
+		req.Header.Set(SignatureHeader, SignWebhook(n.secret, body))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return !errors.Is(err, ErrCallbackURL), err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry = resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("callback responded with status %d", resp.StatusCode)
+}
+
+// checkCallbackURL rejects callback and redirect URLs that are not http(s),
+// and hosts that are literal non-public addresses unless allowPrivate. Names
+// resolving to non-public addresses are refused when dialing.
+func checkCallbackURL(u *url.URL, allowPrivate bool) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: scheme must be http or https", ErrCallbackURL)
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil && !analyzer.PublicIP(ip) && !allowPrivate {
+		return fmt.Errorf("%w: %s is not a public address", ErrCallbackURL, ip)
+	}
+	return nil
+}
+
+// wait blocks until background deliveries finish
+func (n *webhookNotifier) wait() {
+	n.pending.Wait()
+}
+
+// notifyCallback reports an analysis in a terminal status to its callback
+// URL, if it has one
+func (w *Worker) notifyCallback(analysis *models.Analysis, status, lastError string) {
+	if w.webhooks == nil || analysis.CallbackURL == "" {
+		return
+	}
+	w.webhooks.notify(analysis.CallbackURL, newWebhookNotification(analysis, status, lastError, time.Now()))
+}
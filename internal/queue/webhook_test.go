@@ -0,0 +1,185 @@
+package queue
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/docutag/textanalyzer/internal/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// webhookServer answers each delivery with the next status, repeating the
+// last, and records the requests it receives
+type webhookServer struct {
+	*httptest.Server
+	statuses  []int
+	calls     atomic.Int64
+	body      atomic.Value // []byte of the last request
+	signature atomic.Value // string
+}
+
+func newWebhookServer(t *testing.T, statuses ...int) *webhookServer {
+	s := &webhookServer{statuses: statuses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call := int(s.calls.Add(1))
+		body, _ := io.ReadAll(r.Body)
+		s.body.Store(body)
+		s.signature.Store(r.Header.Get(SignatureHeader))
+		w.WriteHeader(s.statuses[min(call, len(s.statuses))-1])
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func newTestNotifier(secret string) *webhookNotifier {
+	notifier := newWebhookNotifier(secret, 3, true, prometheus.NewRegistry(), slog.Default())
+	notifier.backoff = time.Millisecond
+	return notifier
+}
+
+func webhookAnalysis(callbackURL string) *models.Analysis {
+	score := 0.8
+	return &models.Analysis{
+		ID:             "analysis-1",
+		CallbackURL:    callbackURL,
+		SourceURL:      "https://example.com/article",
+		ClientMetadata: map[string]string{"crawl_id": "42"},
+		Text:           "Not sent",
+		Metadata: models.Metadata{
+			WordCount:    120,
+			Language:     "en",
+			Sentiment:    "positive",
+			Tags:         []string{"transit", "city council"},
+			Synopsis:     "The council approved a transit plan.",
+			QualityScore: &models.TextQualityScore{Score: score},
+		},
+	}
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	server := newWebhookServer(t, http.StatusOK)
+	notifier := newTestNotifier("secret")
+	w := &Worker{webhooks: notifier}
+
+	w.notifyCallback(webhookAnalysis(server.URL), WebhookStatusCompleted, "")
+	notifier.wait()
+
+	require.Equal(t, int64(1), server.calls.Load())
+	body := server.body.Load().([]byte)
+	assert.Equal(t, SignWebhook("secret", body), server.signature.Load())
+
+	var notification WebhookNotification
+	require.NoError(t, json.Unmarshal(body, &notification))
+	assert.Equal(t, "analysis-1", notification.AnalysisID)
+	assert.Equal(t, WebhookStatusCompleted, notification.Status)
+	assert.Equal(t, "https://example.com/article", notification.SourceURL)
+	assert.Equal(t, map[string]string{"crawl_id": "42"}, notification.ClientMetadata)
+	assert.Equal(t, []string{"transit", "city council"}, notification.Summary.Tags)
+	require.NotNil(t, notification.Summary.QualityScore)
+	assert.Equal(t, 0.8, *notification.Summary.QualityScore)
+	assert.NotContains(t, string(body), "Not sent", "the text is not sent")
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(notifier.deliveries.WithLabelValues(webhookDelivered)))
+	assert.Equal(t, 0.0, testutil.ToFloat64(notifier.deliveries.WithLabelValues(webhookFailed)))
+}
+
+func TestWebhookRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		calls    int64
+		result   string
+	}{
+		{"recovers after server errors", []int{http.StatusBadGateway, http.StatusTooManyRequests, http.StatusNoContent}, 3, webhookDelivered},
+		{"gives up after max attempts", []int{http.StatusServiceUnavailable}, 3, webhookFailed},
+		{"client errors are not retried", []int{http.StatusNotFound}, 1, webhookFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newWebhookServer(t, tt.statuses...)
+			notifier := newTestNotifier("")
+
+			notifier.notify(server.URL, newWebhookNotification(webhookAnalysis(server.URL), WebhookStatusFailed, "ollama timeout", time.Now()))
+			notifier.wait()
+
+			assert.Equal(t, tt.calls, server.calls.Load())
+			assert.Empty(t, server.signature.Load(), "no secret, no signature")
+			assert.Equal(t, 1.0, testutil.ToFloat64(notifier.deliveries.WithLabelValues(tt.result)))
+		})
+	}
+
+	// Unreachable callbacks count as failures without failing anything else
+	notifier := newTestNotifier("secret")
+	notifier.notify("http://127.0.0.1:1/unreachable", WebhookNotification{AnalysisID: "analysis-1"})
+	notifier.wait()
+	assert.Equal(t, 1.0, testutil.ToFloat64(notifier.deliveries.WithLabelValues(webhookFailed)))
+}
+
+func TestWebhookRefusesPrivateAddresses(t *testing.T) {
+	server := newWebhookServer(t, http.StatusOK)
+	notifier := newWebhookNotifier("secret", 3, false, prometheus.NewRegistry(), slog.Default())
+	notifier.backoff = time.Millisecond
+
+	// Loopback callbacks are refused, by literal address or by name, and
+	// refusals are not retried
+	for _, callbackURL := range []string{server.URL, strings.Replace(server.URL, "127.0.0.1", "localhost", 1)} {
+		retry, err := notifier.post(callbackURL, []byte("{}"))
+		assert.ErrorIs(t, err, ErrCallbackURL, callbackURL)
+		assert.False(t, retry, callbackURL)
+	}
+	notifier.notify(server.URL, WebhookNotification{AnalysisID: "analysis-1"})
+	notifier.wait()
+	assert.Equal(t, int64(0), server.calls.Load())
+	assert.Equal(t, 1.0, testutil.ToFloat64(notifier.deliveries.WithLabelValues(webhookFailed)))
+
+	// Redirect targets are checked again, and redirects are capped
+	first := httptest.NewRequest(http.MethodPost, "https://pipeline.example.com/done", nil)
+	redirect := httptest.NewRequest(http.MethodPost, "http://169.254.169.254/latest/meta-data", nil)
+	assert.ErrorIs(t, notifier.client.CheckRedirect(redirect, []*http.Request{first}), ErrCallbackURL)
+	redirect = httptest.NewRequest(http.MethodPost, "https://hooks.example.com/done", nil)
+	assert.NoError(t, notifier.client.CheckRedirect(redirect, []*http.Request{first}))
+	via := []*http.Request{first, first, first, first}
+	assert.ErrorIs(t, notifier.client.CheckRedirect(redirect, via), ErrCallbackURL)
+}
+
+func TestNotifyCallbackWithoutURL(t *testing.T) {
+	server := newWebhookServer(t, http.StatusOK)
+	notifier := newTestNotifier("secret")
+
+	(&Worker{webhooks: notifier}).notifyCallback(webhookAnalysis(""), WebhookStatusCompleted, "")
+	(&Worker{}).notifyCallback(webhookAnalysis(server.URL), WebhookStatusCompleted, "")
+	notifier.wait()
+
+	assert.Equal(t, int64(0), server.calls.Load())
+}
+
+func TestNotifyFinalFailure(t *testing.T) {
+	server := newWebhookServer(t, http.StatusOK)
+	notifier := newTestNotifier("secret")
+	w := &Worker{webhooks: notifier}
+	analysis := webhookAnalysis(server.URL)
+
+	w.notifyFinalFailure(analysis, 3, 10, assert.AnError)
+	notifier.wait()
+	assert.Equal(t, int64(0), server.calls.Load(), "retries remain")
+
+	w.notifyFinalFailure(analysis, 10, 10, assert.AnError)
+	notifier.wait()
+	require.Equal(t, int64(1), server.calls.Load())
+
+	var notification WebhookNotification
+	require.NoError(t, json.Unmarshal(server.body.Load().([]byte), &notification))
+	assert.Equal(t, WebhookStatusFailed, notification.Status)
+	assert.Equal(t, assert.AnError.Error(), notification.Error)
+}
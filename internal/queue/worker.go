@@ -4,25 +4,53 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"sync"
 	"time"
 
 	"github.com/hibiken/asynq"
 	"github.com/docutag/platform/pkg/metrics"
 	"github.com/docutag/textanalyzer/internal/analyzer"
 	"github.com/docutag/textanalyzer/internal/database"
+	"github.com/docutag/textanalyzer/internal/models"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
+// taskServer is the part of the Asynq server the worker drives, so that
+// reconfiguration can be tested without Redis
+type taskServer interface {
+	Start(handler asynq.Handler) error
+	Stop()
+	Shutdown()
+}
+
 // Worker wraps the Asynq server for processing tasks
 type Worker struct {
-	server          *asynq.Server
+	// mu guards server, settings and running. reconfiguring serializes
+	// Reconfigure against itself and against Shutdown.
+	mu            sync.Mutex
+	reconfiguring sync.Mutex
+	server        taskServer
+	newServer     func(asynq.Config) taskServer
+	settings      models.WorkerSettings
+	running       bool
+	stopped       chan struct{}
+	stopOnce      sync.Once
+	// drainTimeout is how long Reconfigure waits for in-flight tasks
+	drainTimeout time.Duration
+
 	mux             *asynq.ServeMux
 	db              *database.DB
 	analyzer        *analyzer.Analyzer
 	queueClient     *Client
-	concurrency     int
 	maxRetries      int
 	logger          *slog.Logger
 	businessMetrics *metrics.BusinessMetrics
+	stats           *taskStats
+	heartbeat       *heartbeat
+	maxImages       int
+	legacyPayloads  *prometheus.CounterVec
+	shadow          *shadowEnricher // nil when shadow enrichment is off
+	webhooks        *webhookNotifier
 }
 
 // WorkerConfig contains configuration for the queue worker
@@ -30,34 +58,119 @@ type WorkerConfig struct {
 	RedisAddr   string
 	Concurrency int
 	MaxRetries  int
+	// WorkerID identifies this worker in heartbeats (default: hostname-pid)
+	WorkerID string
+	// HeartbeatInterval is how often liveness is written (default: DefaultHeartbeatInterval)
+	HeartbeatInterval time.Duration
+	// MaxImages caps image enrichment tasks per analysis (default: analyzer.DefaultMaxImages)
+	MaxImages int
+	// QueueWeights overrides the default queue weights (default: queueWeights)
+	QueueWeights map[string]int
+	// StrictPriority processes higher weighted queues first instead of proportionally
+	StrictPriority bool
+	// ShadowClient is a candidate model that also tags and scores a sample
+	// of enriched texts for comparison (default: none)
+	ShadowClient analyzer.LLMClient
+	// ShadowSampleRate is the fraction of text enrichments shadowed, from 0 to 1
+	ShadowSampleRate float64
+	// WebhookSecret signs callback notifications (default: unsigned)
+	WebhookSecret string
+	// WebhookMaxAttempts is how many times a callback notification is tried
+	// (default: DefaultWebhookMaxAttempts)
+	WebhookMaxAttempts int
+	// WebhookAllowPrivate lets callback notifications reach private, loopback
+	// and link-local addresses, for development and tests (default: public only)
+	WebhookAllowPrivate bool
+}
+
+// queueWeights maps each queue to its processing weight: higher value = higher priority.
+// Named queues for clarity: text enrichment gets highest priority, then offline
+// processing, then images. Each stage has high and low variants so interactive
+// submissions can jump ahead of bulk backfill traffic.
+var queueWeights = map[string]int{
+	"text-enrichment-high":    14, // Interactive AI text enrichment
+	"text-enrichment":         7,  // AI text enrichment with Ollama (highest priority)
+	"text-enrichment-low":     2,  // Backfill AI text enrichment
+	"offline-processing-high": 10, // Interactive offline processing
+	"offline-processing":      5,  // Offline rule-based document processing (medium priority)
+	"offline-processing-low":  2,  // Backfill offline processing
+	"image-enrichment-high":   6,  // Interactive AI image enrichment
+	"image-enrichment":        3,  // AI image enrichment with Ollama (lowest priority)
+	"image-enrichment-low":    1,  // Backfill AI image enrichment
 }
 
 // NewWorker creates a new queue worker
 func NewWorker(
 	cfg WorkerConfig,
 	db *database.DB,
-	analyzer *analyzer.Analyzer,
+	textAnalyzer *analyzer.Analyzer,
 	queueClient *Client,
 ) *Worker {
 	redisOpt := asynq.RedisClientOpt{
 		Addr: cfg.RedisAddr,
 	}
+	settings := initialSettings(cfg)
+
+	newServer := func(serverCfg asynq.Config) taskServer {
+		return asynq.NewServer(redisOpt, serverCfg)
+	}
+	mux := asynq.NewServeMux()
+
+	// Initialize business metrics
+	businessMetrics := metrics.NewBusinessMetrics("textanalyzer")
+
+	workerID := cfg.WorkerID
+	if workerID == "" {
+		workerID = defaultWorkerID()
+	}
+	stats := &taskStats{}
+
+	maxImages := cfg.MaxImages
+	if maxImages <= 0 {
+		maxImages = analyzer.DefaultMaxImages
+	}
 
-	serverCfg := asynq.Config{
+	w := &Worker{
+		server:          newServer(serverConfig(settings)),
+		newServer:       newServer,
+		settings:        settings,
+		stopped:         make(chan struct{}),
+		drainTimeout:    maxTaskTimeout,
+		mux:             mux,
+		db:              db,
+		analyzer:        textAnalyzer,
+		queueClient:     queueClient,
+		maxRetries:      cfg.MaxRetries,
+		logger:          slog.Default(),
+		businessMetrics: businessMetrics,
+		stats:           stats,
+		heartbeat:       newHeartbeat(workerID, db, cfg.HeartbeatInterval, stats, slog.Default()),
+		maxImages:       maxImages,
+		legacyPayloads:  newLegacyPayloadCounter(prometheus.DefaultRegisterer, slog.Default()),
+		shadow:          newShadowEnricher(cfg.ShadowClient, cfg.ShadowSampleRate, textAnalyzer, db, prometheus.DefaultRegisterer, slog.Default()),
+		webhooks:        newWebhookNotifier(cfg.WebhookSecret, cfg.WebhookMaxAttempts, cfg.WebhookAllowPrivate, prometheus.DefaultRegisterer, slog.Default()),
+	}
+	w.heartbeat.registerAgeGauge(prometheus.DefaultRegisterer)
+
+	// Register task handlers
+	w.mux.Use(stats.middleware)
+	w.registerHandlers()
+
+	return w
+}
+
+// serverConfig builds the asynq server configuration for the given settings
+func serverConfig(settings models.WorkerSettings) asynq.Config {
+	return asynq.Config{
 		// Concurrency determines how many tasks can be processed simultaneously
-		Concurrency: cfg.Concurrency,
+		Concurrency: settings.Concurrency,
 
 		// Queue priority: higher value = higher priority
-		// Named queues for clarity: text enrichment gets highest priority, then offline processing, then images
-		Queues: map[string]int{
-			"text-enrichment":     7, // AI text enrichment with Ollama (highest priority)
-			"offline-processing":  5, // Offline rule-based document processing (medium priority)
-			"image-enrichment":    3, // AI image enrichment with Ollama (lowest priority)
-		},
+		Queues: settings.QueueWeights,
 
 		// StrictPriority: false means queues are processed proportionally
 		// true would mean text-enrichment queue must be empty before processing offline-processing
-		StrictPriority: false,
+		StrictPriority: settings.StrictPriority,
 
 		// Retry configuration with aggressive backoff for Ollama tasks
 		RetryDelayFunc: func(n int, err error, task *asynq.Task) time.Duration {
@@ -112,29 +225,6 @@ func NewWorker(
 			)
 		}),
 	}
-
-	server := asynq.NewServer(redisOpt, serverCfg)
-	mux := asynq.NewServeMux()
-
-	// Initialize business metrics
-	businessMetrics := metrics.NewBusinessMetrics("textanalyzer")
-
-	w := &Worker{
-		server:          server,
-		mux:             mux,
-		db:              db,
-		analyzer:        analyzer,
-		queueClient:     queueClient,
-		concurrency:     cfg.Concurrency,
-		maxRetries:      cfg.MaxRetries,
-		logger:          slog.Default(),
-		businessMetrics: businessMetrics,
-	}
-
-	// Register task handlers
-	w.registerHandlers()
-
-	return w
 }
 
 // registerHandlers registers all task handlers with the worker
@@ -144,31 +234,63 @@ func (w *Worker) registerHandlers() {
 	w.mux.HandleFunc(TypeEnrichImage, w.handleEnrichImage)
 }
 
-// Start starts the worker to begin processing tasks
+// Start starts the worker to begin processing tasks. It blocks until
+// Shutdown is called, across any reconfigurations in between.
 func (w *Worker) Start() error {
+	w.mu.Lock()
 	w.logger.Info("starting asynq worker",
-		"concurrency", w.concurrency,
-		"queues", map[string]int{"text-enrichment": 7, "offline-processing": 5, "image-enrichment": 3},
+		"concurrency", w.settings.Concurrency,
+		"queues", w.settings.QueueWeights,
+		"strict_priority", w.settings.StrictPriority,
 		"ollama_max_retries", w.maxRetries,
 	)
 
-	// Run is blocking - starts processing tasks
-	if err := w.server.Run(w.mux); err != nil {
+	// Report liveness while the server runs
+	w.heartbeat.Start()
+
+	if err := w.server.Start(w.mux); err != nil {
+		w.mu.Unlock()
+		w.heartbeat.Stop()
 		return fmt.Errorf("asynq server error: %w", err)
 	}
+	w.running = true
+	w.mu.Unlock()
 
+	<-w.stopped
 	return nil
 }
 
-// Shutdown gracefully shuts down the worker
+// Shutdown gracefully shuts down the worker, waiting for any reconfiguration
+// in progress to finish first
 func (w *Worker) Shutdown() {
+	w.reconfiguring.Lock()
+	defer w.reconfiguring.Unlock()
+
 	w.logger.Info("shutting down asynq worker")
+	w.mu.Lock()
 	w.server.Shutdown()
+	w.running = false
+	w.mu.Unlock()
+
+	w.stopOnce.Do(func() { close(w.stopped) })
+	w.heartbeat.Stop()
+
+	// Let notifications for finished tasks go out
+	if w.webhooks != nil {
+		w.webhooks.wait()
+	}
+	if w.shadow != nil {
+		w.shadow.wait()
+	}
 }
 
 // Server returns the underlying Asynq server (for testing)
 func (w *Worker) Server() *asynq.Server {
-	return w.server
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	server, _ := w.server.(*asynq.Server)
+	return server
 }
 
 // getRetryDelayFunc returns the retry delay function (for testing)
@@ -0,0 +1,173 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"sort"
+	"time"
+
+	"github.com/docutag/textanalyzer/internal/models"
+)
+
+// Worker setting limits
+const (
+	MaxWorkerConcurrency = 256
+	MaxQueueWeight       = 100
+)
+
+var (
+	// ErrInvalidWorkerSettings is wrapped by validation errors from Reconfigure
+	ErrInvalidWorkerSettings = errors.New("invalid worker settings")
+	// ErrReconfigureInProgress is returned while another reconfiguration runs
+	ErrReconfigureInProgress = errors.New("worker reconfiguration already in progress")
+	// ErrWorkerStopped is returned when reconfiguring a worker that was shut down
+	ErrWorkerStopped = errors.New("worker is stopped")
+)
+
+// DefaultQueueWeights returns a copy of the default queue weights
+func DefaultQueueWeights() map[string]int {
+	return maps.Clone(queueWeights)
+}
+
+// initialSettings returns the settings a new worker starts with
+func initialSettings(cfg WorkerConfig) models.WorkerSettings {
+	weights := cfg.QueueWeights
+	if len(weights) == 0 {
+		weights = queueWeights
+	}
+	return models.WorkerSettings{
+		Concurrency:    cfg.Concurrency,
+		QueueWeights:   maps.Clone(weights),
+		StrictPriority: cfg.StrictPriority,
+	}
+}
+
+// ValidateWorkerSettings checks that concurrency is within limits and that
+// every known queue, and only known queues, has a positive weight. Dropping a
+// queue would strand its tasks, so queues cannot be disabled.
+func ValidateWorkerSettings(settings models.WorkerSettings) error {
+	if settings.Concurrency < 1 || settings.Concurrency > MaxWorkerConcurrency {
+		return fmt.Errorf("%w: concurrency must be between 1 and %d, got %d",
+			ErrInvalidWorkerSettings, MaxWorkerConcurrency, settings.Concurrency)
+	}
+
+	names := make([]string, 0, len(settings.QueueWeights))
+	for name := range settings.QueueWeights {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := queueWeights[name]; !ok {
+			return fmt.Errorf("%w: unknown queue %q", ErrInvalidWorkerSettings, name)
+		}
+		if weight := settings.QueueWeights[name]; weight < 1 || weight > MaxQueueWeight {
+			return fmt.Errorf("%w: weight for queue %q must be between 1 and %d, got %d",
+				ErrInvalidWorkerSettings, name, MaxQueueWeight, weight)
+		}
+	}
+	for name := range queueWeights {
+		if _, ok := settings.QueueWeights[name]; !ok {
+			return fmt.Errorf("%w: missing weight for queue %q", ErrInvalidWorkerSettings, name)
+		}
+	}
+
+	return nil
+}
+
+// Settings returns a copy of the worker's effective settings
+func (w *Worker) Settings() models.WorkerSettings {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	settings := w.settings
+	settings.QueueWeights = maps.Clone(w.settings.QueueWeights)
+	return settings
+}
+
+// Reconfigure applies new concurrency and queue settings. A running worker is
+// restarted: the current server stops fetching tasks, in-flight tasks are
+// given up to the longest task timeout to finish, and a new server starts
+// with the new settings. If the new
+// server fails to start, the previous settings are restored and an error is
+// returned. Only one reconfiguration runs at a time; others fail with
+// ErrReconfigureInProgress.
+func (w *Worker) Reconfigure(settings models.WorkerSettings) error {
+	if err := ValidateWorkerSettings(settings); err != nil {
+		return err
+	}
+	if !w.reconfiguring.TryLock() {
+		return ErrReconfigureInProgress
+	}
+	defer w.reconfiguring.Unlock()
+
+	settings.QueueWeights = maps.Clone(settings.QueueWeights)
+
+	w.mu.Lock()
+	select {
+	case <-w.stopped:
+		w.mu.Unlock()
+		return ErrWorkerStopped
+	default:
+	}
+	if !w.running {
+		// Not started yet: the new server is picked up by Start
+		w.server = w.newServer(serverConfig(settings))
+		w.settings = settings
+		w.mu.Unlock()
+		return nil
+	}
+	previous, current := w.settings, w.server
+	w.mu.Unlock()
+
+	w.logger.Info("reconfiguring asynq worker",
+		"concurrency", settings.Concurrency,
+		"queues", settings.QueueWeights,
+		"strict_priority", settings.StrictPriority,
+	)
+
+	// The server's own shutdown timeout is far shorter than a long enrichment
+	// task, so drain in-flight tasks before shutting it down
+	current.Stop()
+	w.drain()
+	current.Shutdown()
+
+	server := w.newServer(serverConfig(settings))
+	startErr := server.Start(w.mux)
+	if startErr != nil {
+		w.logger.Error("failed to start reconfigured worker, restoring previous settings", "error", startErr)
+		settings = previous
+		server = w.newServer(serverConfig(previous))
+		if err := server.Start(w.mux); err != nil {
+			w.logger.Error("failed to restart worker with previous settings", "error", err)
+		}
+	}
+
+	w.mu.Lock()
+	w.server = server
+	w.settings = settings
+	w.mu.Unlock()
+
+	if startErr != nil {
+		return fmt.Errorf("failed to start reconfigured worker: %w", startErr)
+	}
+	return nil
+}
+
+// drainPollInterval is how often drain checks for in-flight tasks
+const drainPollInterval = 100 * time.Millisecond
+
+// drain waits until no tasks are in flight or drainTimeout passes
+func (w *Worker) drain() {
+	deadline := time.Now().Add(w.drainTimeout)
+	for w.stats.active.Load() > 0 {
+		if time.Now().After(deadline) {
+			w.logger.Warn("in-flight tasks still running after drain timeout, shutting down anyway",
+				"active", w.stats.active.Load(),
+				"timeout", w.drainTimeout,
+			)
+			return
+		}
+		time.Sleep(drainPollInterval)
+	}
+}
@@ -0,0 +1,289 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docutag/textanalyzer/internal/models"
+	"github.com/hibiken/asynq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// eventLog records server lifecycle and task events in order
+type eventLog struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (l *eventLog) add(event string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, event)
+}
+
+func (l *eventLog) snapshot() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.events...)
+}
+
+// fakeServer stands in for the Asynq server. Like the real server with its
+// short shutdown timeout, Shutdown does not wait for in-flight fake tasks.
+type fakeServer struct {
+	id       int
+	cfg      asynq.Config
+	log      *eventLog
+	stats    *taskStats
+	startErr error
+	stopped  bool
+}
+
+func (s *fakeServer) Start(handler asynq.Handler) error {
+	if s.startErr != nil {
+		s.log.add(fmt.Sprintf("start %d failed", s.id))
+		return s.startErr
+	}
+	s.log.add(fmt.Sprintf("start %d", s.id))
+	return nil
+}
+
+func (s *fakeServer) Stop() {
+	s.stopped = true
+	s.log.add(fmt.Sprintf("stopping %d", s.id))
+}
+
+func (s *fakeServer) Shutdown() {
+	if !s.stopped {
+		s.Stop()
+	}
+	s.log.add(fmt.Sprintf("shutdown %d", s.id))
+}
+
+// runTask simulates a task that runs until release is closed
+func (s *fakeServer) runTask(release <-chan struct{}) {
+	s.stats.active.Add(1)
+	go func() {
+		defer s.stats.active.Add(-1)
+		<-release
+		s.log.add(fmt.Sprintf("task on %d done", s.id))
+	}()
+}
+
+// fakeServerFactory creates fake servers, failing to start any whose
+// concurrency is in failConcurrency
+type fakeServerFactory struct {
+	mu              sync.Mutex
+	log             eventLog
+	servers         []*fakeServer
+	failConcurrency map[int]bool
+	stats           *taskStats
+}
+
+func (f *fakeServerFactory) new(cfg asynq.Config) taskServer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	server := &fakeServer{id: len(f.servers), cfg: cfg, log: &f.log, stats: f.stats}
+	if f.failConcurrency[cfg.Concurrency] {
+		server.startErr = errors.New("redis unavailable")
+	}
+	f.servers = append(f.servers, server)
+	return server
+}
+
+func (f *fakeServerFactory) server(i int) *fakeServer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.servers[i]
+}
+
+func (f *fakeServerFactory) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.servers)
+}
+
+// newTestWorker creates a worker backed by fake servers
+func newTestWorker(t *testing.T) (*Worker, *fakeServerFactory) {
+	t.Helper()
+
+	stats := &taskStats{}
+	factory := &fakeServerFactory{stats: stats}
+	w := &Worker{
+		newServer:    factory.new,
+		settings:     initialSettings(WorkerConfig{Concurrency: 4}),
+		stopped:      make(chan struct{}),
+		drainTimeout: time.Minute,
+		mux:          asynq.NewServeMux(),
+		logger:       slog.Default(),
+		stats:        stats,
+		heartbeat:    newHeartbeat("worker-1", newFakeHeartbeatStore(), time.Hour, stats, slog.Default()),
+	}
+	w.server = w.newServer(serverConfig(w.settings))
+	return w, factory
+}
+
+// startTestWorker runs Start in the background and waits for the first server
+func startTestWorker(t *testing.T, w *Worker, factory *fakeServerFactory) <-chan error {
+	t.Helper()
+
+	done := make(chan error, 1)
+	go func() { done <- w.Start() }()
+	require.Eventually(t, func() bool {
+		return len(factory.log.snapshot()) > 0
+	}, time.Second, 5*time.Millisecond)
+	return done
+}
+
+// testSettings returns valid settings with every queue weighted 1
+func testSettings(concurrency int) models.WorkerSettings {
+	weights := DefaultQueueWeights()
+	for name := range weights {
+		weights[name] = 1
+	}
+	return models.WorkerSettings{Concurrency: concurrency, QueueWeights: weights, StrictPriority: true}
+}
+
+func TestWorkerReconfigure(t *testing.T) {
+	w, factory := newTestWorker(t)
+	done := startTestWorker(t, w, factory)
+
+	release := make(chan struct{})
+	factory.server(0).runTask(release)
+
+	reconfigured := make(chan error, 1)
+	go func() { reconfigured <- w.Reconfigure(testSettings(8)) }()
+
+	// The reconfiguration waits for the in-flight task, and any other
+	// reconfiguration is rejected meanwhile
+	require.Eventually(t, func() bool {
+		return len(factory.log.snapshot()) == 2
+	}, time.Second, 5*time.Millisecond)
+	assert.ErrorIs(t, w.Reconfigure(testSettings(2)), ErrReconfigureInProgress)
+	assert.Equal(t, 1, factory.count(), "new server should not be created before in-flight tasks finish")
+
+	close(release)
+	require.NoError(t, <-reconfigured)
+
+	assert.Equal(t, []string{"start 0", "stopping 0", "task on 0 done", "shutdown 0", "start 1"}, factory.log.snapshot())
+
+	cfg := factory.server(1).cfg
+	assert.Equal(t, 8, cfg.Concurrency)
+	assert.Equal(t, testSettings(8).QueueWeights, cfg.Queues)
+	assert.True(t, cfg.StrictPriority)
+	assert.Equal(t, testSettings(8), w.Settings())
+
+	// Start keeps blocking across the reconfiguration until Shutdown
+	select {
+	case err := <-done:
+		t.Fatalf("Start returned early: %v", err)
+	default:
+	}
+	w.Shutdown()
+	require.NoError(t, <-done)
+	assert.Equal(t, []string{"stopping 1", "shutdown 1"}, factory.log.snapshot()[5:])
+
+	assert.ErrorIs(t, w.Reconfigure(testSettings(8)), ErrWorkerStopped)
+}
+
+func TestWorkerReconfigureDrainTimeout(t *testing.T) {
+	w, factory := newTestWorker(t)
+	w.drainTimeout = 20 * time.Millisecond
+	done := startTestWorker(t, w, factory)
+
+	// A task outliving the drain timeout does not block reconfiguration forever
+	release := make(chan struct{})
+	defer close(release)
+	factory.server(0).runTask(release)
+
+	require.NoError(t, w.Reconfigure(testSettings(8)))
+	assert.Equal(t, []string{"start 0", "stopping 0", "shutdown 0", "start 1"}, factory.log.snapshot())
+
+	w.Shutdown()
+	require.NoError(t, <-done)
+}
+
+func TestWorkerReconfigureStartFailureRestoresSettings(t *testing.T) {
+	w, factory := newTestWorker(t)
+	factory.failConcurrency = map[int]bool{16: true}
+	done := startTestWorker(t, w, factory)
+	previous := w.Settings()
+
+	err := w.Reconfigure(testSettings(16))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "redis unavailable")
+
+	assert.Equal(t, []string{"start 0", "stopping 0", "shutdown 0", "start 1 failed", "start 2"}, factory.log.snapshot())
+	assert.Equal(t, previous, w.Settings())
+	assert.Equal(t, 4, factory.server(2).cfg.Concurrency)
+
+	w.Shutdown()
+	require.NoError(t, <-done)
+}
+
+func TestWorkerReconfigureBeforeStart(t *testing.T) {
+	w, factory := newTestWorker(t)
+
+	require.NoError(t, w.Reconfigure(testSettings(8)))
+	assert.Empty(t, factory.log.snapshot(), "servers should not start before Start")
+
+	done := startTestWorker(t, w, factory)
+	assert.Equal(t, []string{"start 1"}, factory.log.snapshot())
+	assert.Equal(t, 8, factory.server(1).cfg.Concurrency)
+
+	w.Shutdown()
+	require.NoError(t, <-done)
+}
+
+func TestWorkerSettingsIsACopy(t *testing.T) {
+	w, _ := newTestWorker(t)
+
+	settings := w.Settings()
+	settings.QueueWeights["text-enrichment"] = 99
+
+	assert.Equal(t, 7, w.Settings().QueueWeights["text-enrichment"])
+	assert.Equal(t, 7, queueWeights["text-enrichment"])
+}
+
+func TestValidateWorkerSettings(t *testing.T) {
+	withWeight := func(name string, weight int) models.WorkerSettings {
+		settings := testSettings(4)
+		settings.QueueWeights[name] = weight
+		return settings
+	}
+	missing := testSettings(4)
+	delete(missing.QueueWeights, "image-enrichment-low")
+
+	tests := []struct {
+		name     string
+		settings models.WorkerSettings
+		errMsg   string
+	}{
+		{"valid", testSettings(4), ""},
+		{"defaults", initialSettings(WorkerConfig{Concurrency: 10}), ""},
+		{"zero concurrency", testSettings(0), "concurrency must be between 1 and 256"},
+		{"excessive concurrency", testSettings(MaxWorkerConcurrency + 1), "concurrency must be between 1 and 256"},
+		{"unknown queue", withWeight("default", 1), `unknown queue "default"`},
+		{"zero weight", withWeight("text-enrichment", 0), `weight for queue "text-enrichment" must be between 1 and 100`},
+		{"excessive weight", withWeight("text-enrichment", MaxQueueWeight+1), "must be between 1 and 100"},
+		{"missing queue", missing, `missing weight for queue "image-enrichment-low"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateWorkerSettings(tt.settings)
+			if tt.errMsg == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.ErrorIs(t, err, ErrInvalidWorkerSettings)
+			assert.Contains(t, err.Error(), tt.errMsg)
+		})
+	}
+}
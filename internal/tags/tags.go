@@ -0,0 +1,200 @@
+// Package tags normalizes, validates and merges analysis tags so that
+// computed, AI-generated and user-supplied tags share one format.
+package tags
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxLength is the maximum length of a tag in characters
+const MaxLength = 50
+
+// allowedPunctuation lists the non-alphanumeric characters a tag may contain,
+// besides hyphens, so that names like "c++", "c#" and "at&t" survive
+const allowedPunctuation = "+#.&'"
+
+// structuralTags are the tags the analyzer derives from document shape
+// rather than topic: sentiment, length, readability level, content type and
+// sentiment arc. They are excluded where tags are used to explore topics.
+var structuralTags = map[string]bool{
+	"positive": true, "negative": true, "neutral": true,
+	"short": true, "medium": true, "long": true,
+	"very-easy": true, "easy": true, "fairly-easy": true, "standard": true,
+	"fairly-difficult": true, "difficult": true, "very-difficult": true,
+	"faq": true, "web-content": true, "research": true,
+	"rising-arc": true, "falling-arc": true, "valley-arc": true, "peak-arc": true,
+}
+
+// IsStructural reports whether tag describes document shape rather than topic
+func IsStructural(tag string) bool {
+	return structuralTags[Normalize(tag)]
+}
+
+// StructuralTags returns the structural tags in sorted order
+func StructuralTags() []string {
+	list := make([]string, 0, len(structuralTags))
+	for tag := range structuralTags {
+		list = append(list, tag)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// Policy filters and rewrites tags after normalization
+type Policy struct {
+	Blacklist []string          // Tags that are always dropped
+	Whitelist []string          // When non-empty, only these tags are kept
+	Aliases   map[string]string // Maps a tag to its canonical form, e.g. "ml" to "machine-learning"
+}
+
+// ParsePolicy builds a policy from comma-separated blacklist and whitelist
+// tags and comma-separated from=to aliases, e.g. "ml=machine-learning"
+func ParsePolicy(blacklist, whitelist, aliases string) (Policy, error) {
+	policy := Policy{
+		Blacklist: splitList(blacklist),
+		Whitelist: splitList(whitelist),
+	}
+	for _, pair := range splitList(aliases) {
+		from, to, ok := strings.Cut(pair, "=")
+		if !ok || Normalize(from) == "" || Normalize(to) == "" {
+			return Policy{}, fmt.Errorf("invalid tag alias %q: expected tag=canonical", pair)
+		}
+		if policy.Aliases == nil {
+			policy.Aliases = make(map[string]string)
+		}
+		policy.Aliases[from] = to
+	}
+	return policy, nil
+}
+
+// splitList splits a comma-separated list, dropping blank entries
+func splitList(spec string) []string {
+	var items []string
+	for _, item := range strings.Split(spec, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Normalize normalizes a tag according to the tagging rules:
+// - Converts to lowercase
+// - Replaces spaces and underscores with hyphens
+// - Removes multiple consecutive hyphens
+// - Trims leading/trailing hyphens and whitespace
+func Normalize(tag string) string {
+	// Convert to lowercase
+	tag = strings.ToLower(tag)
+
+	// Replace spaces and underscores with hyphens
+	tag = strings.ReplaceAll(tag, " ", "-")
+	tag = strings.ReplaceAll(tag, "_", "-")
+
+	// Remove multiple consecutive hyphens
+	for strings.Contains(tag, "--") {
+		tag = strings.ReplaceAll(tag, "--", "-")
+	}
+
+	// Trim leading/trailing hyphens and whitespace
+	tag = strings.Trim(tag, "- \t\n\r")
+
+	return tag
+}
+
+// Validate checks that a normalized tag is non-empty, at most MaxLength
+// characters and made of letters, digits, hyphens and a few punctuation marks
+func Validate(tag string) error {
+	if tag == "" {
+		return fmt.Errorf("tag is empty")
+	}
+	if length := utf8.RuneCountInString(tag); length > MaxLength {
+		return fmt.Errorf("tag %q is %d characters, must be at most %d", tag, length, MaxLength)
+	}
+	for _, r := range tag {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || strings.ContainsRune(allowedPunctuation, r) {
+			continue
+		}
+		return fmt.Errorf("tag %q contains invalid character %q", tag, r)
+	}
+	return nil
+}
+
+// ApplyPolicy normalizes tags, resolves aliases and then drops blacklisted
+// tags and, when a whitelist is set, tags not on it. Order is preserved and
+// duplicates created by aliasing are removed.
+func ApplyPolicy(tags []string, policy Policy) []string {
+	aliases := make(map[string]string, len(policy.Aliases))
+	for from, to := range policy.Aliases {
+		aliases[Normalize(from)] = Normalize(to)
+	}
+	blacklist := normalizedSet(policy.Blacklist)
+	whitelist := normalizedSet(policy.Whitelist)
+
+	seen := make(map[string]bool, len(tags))
+	result := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = Normalize(tag)
+		if canonical, ok := aliases[tag]; ok {
+			tag = canonical
+		}
+		if blacklist[tag] || (len(whitelist) > 0 && !whitelist[tag]) || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		result = append(result, tag)
+	}
+	return result
+}
+
+// MergeWithLimit normalizes the tags from each source, drops invalid tags and
+// duplicates, and returns at most limit tags in the order first seen. A limit
+// of zero or less keeps every tag.
+func MergeWithLimit(limit int, sources ...[]string) []string {
+	seen := make(map[string]bool)
+	merged := make([]string, 0)
+	for _, source := range sources {
+		for _, tag := range source {
+			tag = Normalize(tag)
+			if seen[tag] || Validate(tag) != nil {
+				continue
+			}
+			if limit > 0 && len(merged) >= limit {
+				return merged
+			}
+			seen[tag] = true
+			merged = append(merged, tag)
+		}
+	}
+	return merged
+}
+
+// Jaccard returns the overlap of two tag lists after normalization: the
+// tags they share divided by the tags in either. Two empty lists agree fully.
+func Jaccard(a, b []string) float64 {
+	setA, setB := normalizedSet(a), normalizedSet(b)
+	if len(setA) == 0 && len(setB) == 0 {
+		return 1
+	}
+
+	shared := 0
+	for tag := range setA {
+		if setB[tag] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(setA)+len(setB)-shared)
+}
+
+// normalizedSet returns the normalized tags as a set
+func normalizedSet(tags []string) map[string]bool {
+	set := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		set[Normalize(tag)] = true
+	}
+	return set
+}
@@ -0,0 +1,207 @@
+package tags
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "lowercase conversion",
+			input:    "Machine Learning",
+			expected: "machine-learning",
+		},
+		{
+			name:     "underscore to hyphen",
+			input:    "climate_change",
+			expected: "climate-change",
+		},
+		{
+			name:     "multiple spaces",
+			input:    "New  York  City",
+			expected: "new-york-city",
+		},
+		{
+			name:     "mixed spaces and underscores",
+			input:    "Social_Media Platform",
+			expected: "social-media-platform",
+		},
+		{
+			name:     "leading and trailing spaces",
+			input:    "  einstein  ",
+			expected: "einstein",
+		},
+		{
+			name:     "multiple consecutive hyphens",
+			input:    "foo--bar---baz",
+			expected: "foo-bar-baz",
+		},
+		{
+			name:     "already normalized",
+			input:    "machine-learning",
+			expected: "machine-learning",
+		},
+		{
+			name:     "single word uppercase",
+			input:    "TECHNOLOGY",
+			expected: "technology",
+		},
+		{
+			name:     "readability level",
+			input:    "very_easy",
+			expected: "very-easy",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Normalize(tt.input)
+			if result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := []string{"technology", "new-york", "c++", "c#", "at&t", "o'brien", "zürich", "covid-19"}
+	for _, tag := range valid {
+		if err := Validate(tag); err != nil {
+			t.Errorf("Validate(%q) returned error: %v", tag, err)
+		}
+	}
+
+	invalid := []string{"", "tag!", "a/b", "line\nbreak", "<script>", strings.Repeat("a", MaxLength+1)}
+	for _, tag := range invalid {
+		if err := Validate(tag); err == nil {
+			t.Errorf("Validate(%q) should have returned an error", tag)
+		}
+	}
+}
+
+func TestApplyPolicy(t *testing.T) {
+	input := []string{"ML", "machine-learning", "news", "Neural Networks", "positive"}
+
+	tests := []struct {
+		name   string
+		policy Policy
+		want   []string
+	}{
+		{"empty policy", Policy{}, []string{"ml", "machine-learning", "news", "neural-networks", "positive"}},
+		{"aliases dedupe", Policy{Aliases: map[string]string{"ml": "Machine Learning"}},
+			[]string{"machine-learning", "news", "neural-networks", "positive"}},
+		{"blacklist", Policy{Blacklist: []string{"News", "positive"}},
+			[]string{"ml", "machine-learning", "neural-networks"}},
+		{"whitelist", Policy{Whitelist: []string{"machine_learning", "neural-networks"}},
+			[]string{"machine-learning", "neural-networks"}},
+		{"blacklist applies to alias target", Policy{
+			Aliases:   map[string]string{"ml": "machine-learning"},
+			Blacklist: []string{"machine-learning"},
+		}, []string{"news", "neural-networks", "positive"}},
+		{"whitelist after alias", Policy{
+			Aliases:   map[string]string{"ml": "machine-learning"},
+			Whitelist: []string{"machine-learning"},
+		}, []string{"machine-learning"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ApplyPolicy(input, tt.policy)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	policy, err := ParsePolicy("news, positive,", "", "ml=machine-learning, AI = Artificial Intelligence")
+	if err != nil {
+		t.Fatalf("ParsePolicy failed: %v", err)
+	}
+	want := Policy{
+		Blacklist: []string{"news", "positive"},
+		Aliases:   map[string]string{"ml": "machine-learning", "AI ": " Artificial Intelligence"},
+	}
+	if !reflect.DeepEqual(policy, want) {
+		t.Errorf("Expected %+v, got %+v", want, policy)
+	}
+	if got := ApplyPolicy([]string{"AI", "news", "ML"}, policy); !reflect.DeepEqual(got, []string{"artificial-intelligence", "machine-learning"}) {
+		t.Errorf("Expected the parsed policy to apply, got %v", got)
+	}
+
+	if policy, err := ParsePolicy("", "", ""); err != nil || !reflect.DeepEqual(policy, Policy{}) {
+		t.Errorf("Expected an empty policy, got %+v (%v)", policy, err)
+	}
+	for _, aliases := range []string{"ml", "ml=", "=machine-learning"} {
+		if _, err := ParsePolicy("", "", aliases); err == nil {
+			t.Errorf("Expected error for aliases %q", aliases)
+		}
+	}
+}
+
+func TestMergeWithLimit(t *testing.T) {
+	computed := []string{"positive", "short", "Machine Learning", "", "data_science"}
+	ai := []string{"machine-learning", "tech!", "innovation", "Data Science", "einstein"}
+
+	tests := []struct {
+		name  string
+		limit int
+		want  []string
+	}{
+		{"no limit", 0, []string{"positive", "short", "machine-learning", "data-science", "innovation", "einstein"}},
+		{"limit", 4, []string{"positive", "short", "machine-learning", "data-science"}},
+		{"limit above total", 20, []string{"positive", "short", "machine-learning", "data-science", "innovation", "einstein"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := MergeWithLimit(tt.limit, computed, ai)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	if got := MergeWithLimit(10); got == nil || len(got) != 0 {
+		t.Errorf("Expected empty non-nil slice without sources, got %#v", got)
+	}
+}
+
+func TestIsStructural(t *testing.T) {
+	for _, tag := range []string{"positive", "long", "very_difficult", "FAQ", "valley-arc"} {
+		if !IsStructural(tag) {
+			t.Errorf("Expected %q to be structural", tag)
+		}
+	}
+	for _, tag := range []string{"climate-change", "policy", "einstein"} {
+		if IsStructural(tag) {
+			t.Errorf("Expected %q not to be structural", tag)
+		}
+	}
+}
+
+func TestJaccard(t *testing.T) {
+	tests := []struct {
+		a, b []string
+		want float64
+	}{
+		{[]string{"climate", "policy"}, []string{"Climate", "policy"}, 1},
+		{[]string{"climate", "policy"}, []string{"climate", "energy"}, 1.0 / 3},
+		{[]string{"climate"}, []string{"energy"}, 0},
+		{[]string{"climate"}, nil, 0},
+		{nil, nil, 1},
+	}
+
+	for _, tt := range tests {
+		if got := Jaccard(tt.a, tt.b); got != tt.want {
+			t.Errorf("Jaccard(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
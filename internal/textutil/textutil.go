@@ -0,0 +1,93 @@
+// Package textutil slices and truncates strings without splitting UTF-8
+// encoded runes, so stored and logged excerpts stay valid UTF-8.
+package textutil
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Ellipsis marks text cut by Preview
+const Ellipsis = "…"
+
+// TruncateRunes returns s cut to at most n runes
+func TruncateRunes(s string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	count := 0
+	for i := range s {
+		if count == n {
+			return s[:i]
+		}
+		count++
+	}
+	return s
+}
+
+// Preview returns s cut to at most n runes, ending with Ellipsis when it was
+// cut. It is meant for logs and error messages.
+func Preview(s string, n int) string {
+	truncated := TruncateRunes(s, n)
+	if len(truncated) < len(s) {
+		return truncated + Ellipsis
+	}
+	return truncated
+}
+
+// SafeSlice returns s[start:end] with the byte offsets clamped to s and
+// moved back to the start of the rune they fall inside, so the slice never
+// begins or ends partway through a rune
+func SafeSlice(s string, start, end int) string {
+	start = runeStart(s, start)
+	end = runeStart(s, end)
+	if start >= end {
+		return ""
+	}
+	return s[start:end]
+}
+
+// ContextAround returns the text around s[start:end] with up to radius runes
+// on each side, trimmed of surrounding whitespace. The offsets are bytes and
+// are aligned to rune boundaries like SafeSlice.
+func ContextAround(s string, start, end, radius int) string {
+	start = runeStart(s, start)
+	end = runeStart(s, end)
+	if end < start {
+		end = start
+	}
+
+	for i := 0; i < radius && start > 0; i++ {
+		_, size := utf8.DecodeLastRuneInString(s[:start])
+		start -= size
+	}
+	for i := 0; i < radius && end < len(s); i++ {
+		_, size := utf8.DecodeRuneInString(s[end:])
+		end += size
+	}
+	return strings.TrimSpace(s[start:end])
+}
+
+// ValidUTF8 returns s with each run of invalid UTF-8 bytes replaced by the
+// Unicode replacement character, as JSON encoding and PostgreSQL require
+func ValidUTF8(s string) string {
+	if utf8.ValidString(s) {
+		return s
+	}
+	return strings.ToValidUTF8(s, string(utf8.RuneError))
+}
+
+// runeStart clamps a byte offset to s and moves it back to the first byte
+// of the rune it falls inside
+func runeStart(s string, i int) int {
+	if i <= 0 {
+		return 0
+	}
+	if i >= len(s) {
+		return len(s)
+	}
+	for i > 0 && !utf8.RuneStart(s[i]) {
+		i--
+	}
+	return i
+}
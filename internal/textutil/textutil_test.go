@@ -0,0 +1,102 @@
+package textutil
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// mixed has runes of one, two, three and four bytes
+const mixed = "aé€😀b"
+
+func TestTruncateRunes(t *testing.T) {
+	runes := []rune(mixed)
+	for n := -1; n <= len(runes)+1; n++ {
+		got := TruncateRunes(mixed, n)
+		want := string(runes[:min(max(n, 0), len(runes))])
+		if got != want {
+			t.Errorf("TruncateRunes(%q, %d) = %q, want %q", mixed, n, got, want)
+		}
+	}
+}
+
+func TestPreview(t *testing.T) {
+	if got := Preview(mixed, 3); got != "aé€"+Ellipsis {
+		t.Errorf("Expected a cut preview with an ellipsis, got %q", got)
+	}
+	if got := Preview(mixed, 5); got != mixed {
+		t.Errorf("Expected the whole string, got %q", got)
+	}
+}
+
+func TestSafeSlice(t *testing.T) {
+	// Every pair of byte offsets, including ones inside runes and out of range
+	for start := -1; start <= len(mixed)+1; start++ {
+		for end := -1; end <= len(mixed)+1; end++ {
+			got := SafeSlice(mixed, start, end)
+			if !utf8.ValidString(got) {
+				t.Fatalf("SafeSlice(%q, %d, %d) = %q is not valid UTF-8", mixed, start, end, got)
+			}
+			if !strings.Contains(mixed, got) {
+				t.Fatalf("SafeSlice(%q, %d, %d) = %q is not a substring", mixed, start, end, got)
+			}
+		}
+	}
+
+	// "€" starts at byte 3 and "😀" at byte 6; offsets inside them move back
+	if got := SafeSlice(mixed, 4, 7); got != "€" {
+		t.Errorf("Expected offsets inside runes to align to rune starts, got %q", got)
+	}
+	if got := SafeSlice(mixed, 1, len(mixed)); got != "é€😀b" {
+		t.Errorf("Expected the tail from byte 1, got %q", got)
+	}
+}
+
+func TestContextAround(t *testing.T) {
+	text := "😀😀 the rate rose 5% this year 😀😀"
+	index := strings.Index(text, "5%")
+
+	for radius := 0; radius <= utf8.RuneCountInString(text); radius++ {
+		got := ContextAround(text, index, index+len("5%"), radius)
+		if !utf8.ValidString(got) || !strings.Contains(got, "5%") {
+			t.Fatalf("ContextAround with radius %d = %q", radius, got)
+		}
+	}
+
+	if got := ContextAround(text, index, index+len("5%"), 5); got != "rose 5% this" {
+		t.Errorf("Expected 5 runes on each side trimmed, got %q", got)
+	}
+	if got := ContextAround(text, index, index+len("5%"), 100); got != text {
+		t.Errorf("Expected the whole text for a large radius, got %q", got)
+	}
+
+	// Offsets inside a rune at either edge
+	for start := 0; start <= len(text); start++ {
+		if got := ContextAround(text, start, start+1, 3); !utf8.ValidString(got) {
+			t.Fatalf("ContextAround at byte %d = %q is not valid UTF-8", start, got)
+		}
+	}
+}
+
+func TestValidUTF8(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{mixed, mixed},
+		{"", ""},
+		{"caf\xc3", "caf�"},
+		{"\xff\xfeok", "�ok"},
+		{"😀"[:2] + " cut", "� cut"},
+	}
+
+	for _, tt := range tests {
+		got := ValidUTF8(tt.input)
+		if got != tt.want {
+			t.Errorf("ValidUTF8(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("ValidUTF8(%q) = %q is not valid UTF-8", tt.input, got)
+		}
+	}
+}